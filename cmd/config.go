@@ -3,19 +3,69 @@ package main
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/dimiro1/lunar/internal/api"
+	"github.com/dimiro1/lunar/internal/ids"
+	"github.com/dimiro1/lunar/internal/killswitch"
+	"github.com/dimiro1/lunar/internal/queue"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 )
 
 type Config struct {
-	Port             string
-	DataDir          string
-	ExecutionTimeout time.Duration
-	APIKey           string
-	BaseURL          string
+	Port              string
+	DataDir           string
+	ExecutionTimeout  time.Duration
+	APIKey            string
+	BaseURL           string
+	OutboundAllow     []string
+	OutboundDeny      []string
+	TrustedProxies    []string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	DisableKeepAlives bool
+	TLSCertFile       string
+	TLSKeyFile        string
+	EnableH2C         bool
+	AIPricesFile      string
+	AIBudgetTokens    int64
+	AIBudgetUSD       float64
+	AIMaxRetries      int
+	EmailMaxRetries   int
+	EmailAllowedFrom  []string
+	OutboundDisabled  []killswitch.Integration
+	MaintenanceMode   bool
+	MaintenanceRetry  time.Duration
+	BreakerThreshold  int
+	BreakerCooldown   time.Duration
+	MaxConcurrent     int
+	MaxQueued         int
+	GzipMinSize       int
+	MaxLogEntries     int
+	MaxOutboundCalls  int
+	OutboundBudget    time.Duration
+	IDGenerator       ids.Generator
+	AccessLogSample   float64
+	FunctionDefaults  api.FunctionDefaults
+	PolicyFile        string
+	DevMode           bool
+	SeedFile          string
+	SeedForce         bool
+	CostTracking      bool
+	CostPerSecond     float64
+	CostPerEmail      float64
 }
 
 func loadPort(getenv func(string) string) string {
@@ -47,6 +97,202 @@ func loadTimeout(getenv func(string) string) time.Duration {
 	return timeout
 }
 
+// loadSeconds reads a duration in seconds from the given environment variable.
+// It returns zero when the variable is unset or invalid, leaving the default to the server.
+func loadSeconds(getenv func(string) string, key string) time.Duration {
+	seconds, err := strconv.Atoi(getenv(key))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// loadBool reads a boolean from the given environment variable, defaulting to false
+func loadBool(getenv func(string) string, key string) bool {
+	value, _ := strconv.ParseBool(getenv(key))
+	return value
+}
+
+// loadAIBudget reads the global monthly AI budget. Unset variables mean no limit.
+func loadAIBudget(getenv func(string) string) (tokens int64, costUSD float64, err error) {
+	if value := getenv("AI_MONTHLY_TOKEN_BUDGET"); value != "" {
+		tokens, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || tokens < 0 {
+			return 0, 0, errors.New("AI_MONTHLY_TOKEN_BUDGET must be a non-negative integer")
+		}
+	}
+	if value := getenv("AI_MONTHLY_BUDGET_USD"); value != "" {
+		costUSD, err = strconv.ParseFloat(value, 64)
+		if err != nil || costUSD < 0 {
+			return 0, 0, errors.New("AI_MONTHLY_BUDGET_USD must be a non-negative number")
+		}
+	}
+	return tokens, costUSD, nil
+}
+
+// loadOutboundDisabled reads the outbound integrations switched off at startup
+func loadOutboundDisabled(getenv func(string) string) ([]killswitch.Integration, error) {
+	var disabled []killswitch.Integration
+	for _, name := range loadList(getenv, "OUTBOUND_DISABLED") {
+		integration := killswitch.Integration(strings.ToLower(name))
+		if !integration.Valid() {
+			return nil, fmt.Errorf("OUTBOUND_DISABLED entries must be one of: %v", killswitch.Integrations)
+		}
+		disabled = append(disabled, integration)
+	}
+	return disabled, nil
+}
+
+// loadAIMaxRetries reads how often transient AI provider errors are retried,
+// defaulting to ai.DefaultMaxRetries when unset or invalid
+func loadAIMaxRetries(getenv func(string) string) int {
+	retries, err := strconv.Atoi(getenv("AI_MAX_RETRIES"))
+	if err != nil || retries < 0 || retries > ai.MaxRetriesLimit {
+		return ai.DefaultMaxRetries
+	}
+	return retries
+}
+
+// loadEmailMaxRetries reads how often transient email provider errors are
+// retried, defaulting to email.DefaultMaxRetries when unset or invalid
+func loadEmailMaxRetries(getenv func(string) string) int {
+	retries, err := strconv.Atoi(getenv("EMAIL_MAX_RETRIES"))
+	if err != nil || retries < 0 || retries > email.MaxRetriesLimit {
+		return email.DefaultMaxRetries
+	}
+	return retries
+}
+
+// loadBreakerThreshold reads how many consecutive failures open the circuit for
+// a host, defaulting to internalhttp.DefaultBreakerThreshold when unset or invalid.
+// Zero disables the circuit breaker.
+func loadBreakerThreshold(getenv func(string) string) int {
+	threshold, err := strconv.Atoi(getenv("CIRCUIT_BREAKER_THRESHOLD"))
+	if err != nil || threshold < 0 {
+		return internalhttp.DefaultBreakerThreshold
+	}
+	return threshold
+}
+
+// loadExecutionQueue reads the execution concurrency limit and queue size.
+// Unset variables mean no concurrency limit and queue.DefaultMaxQueued waiting executions.
+func loadExecutionQueue(getenv func(string) string) (maxConcurrent, maxQueued int, err error) {
+	maxQueued = queue.DefaultMaxQueued
+	if value := getenv("MAX_CONCURRENT_EXECUTIONS"); value != "" {
+		maxConcurrent, err = strconv.Atoi(value)
+		if err != nil || maxConcurrent < 0 {
+			return 0, 0, errors.New("MAX_CONCURRENT_EXECUTIONS must be a non-negative integer")
+		}
+	}
+	if value := getenv("MAX_QUEUED_EXECUTIONS"); value != "" {
+		maxQueued, err = strconv.Atoi(value)
+		if err != nil || maxQueued < 0 {
+			return 0, 0, errors.New("MAX_QUEUED_EXECUTIONS must be a non-negative integer")
+		}
+	}
+	return maxConcurrent, maxQueued, nil
+}
+
+// loadMaxOutboundCalls reads the number of outbound http, ai and email calls
+// an execution may make. Unset means no limit.
+func loadMaxOutboundCalls(getenv func(string) string) (int, error) {
+	value := getenv("MAX_OUTBOUND_CALLS")
+	if value == "" {
+		return 0, nil
+	}
+	maxOutboundCalls, err := strconv.Atoi(value)
+	if err != nil || maxOutboundCalls < 0 {
+		return 0, errors.New("MAX_OUTBOUND_CALLS must be a non-negative integer")
+	}
+	return maxOutboundCalls, nil
+}
+
+// loadMaxLogEntries reads the number of log entries an execution may write.
+// Unset means runner.DefaultMaxLogEntries, applied by the runtime.
+func loadMaxLogEntries(getenv func(string) string) (int, error) {
+	value := getenv("MAX_LOG_ENTRIES")
+	if value == "" {
+		return 0, nil
+	}
+	maxLogEntries, err := strconv.Atoi(value)
+	if err != nil || maxLogEntries <= 0 {
+		return 0, errors.New("MAX_LOG_ENTRIES must be a positive integer")
+	}
+	return maxLogEntries, nil
+}
+
+// loadAccessLogSample reads the share of requests written to the access log
+// from ACCESS_LOG_SAMPLE_RATE, between 0 (none) and 1 (all, the default)
+func loadAccessLogSample(getenv func(string) string) (float64, error) {
+	value := getenv("ACCESS_LOG_SAMPLE_RATE")
+	if value == "" {
+		return 1, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, errors.New("ACCESS_LOG_SAMPLE_RATE must be a number between 0 and 1")
+	}
+	return rate, nil
+}
+
+// loadCostRates reads the rates used to price executions from
+// COST_PER_COMPUTE_SECOND and COST_PER_EMAIL. Costs are tracked when either is
+// set; AI requests are priced with the AI price table.
+func loadCostRates(getenv func(string) string) (enabled bool, perSecond, perEmail float64, err error) {
+	for key, rate := range map[string]*float64{"COST_PER_COMPUTE_SECOND": &perSecond, "COST_PER_EMAIL": &perEmail} {
+		value := getenv(key)
+		if value == "" {
+			continue
+		}
+		*rate, err = strconv.ParseFloat(value, 64)
+		if err != nil || *rate < 0 {
+			return false, 0, 0, fmt.Errorf("%s must be a non-negative number", key)
+		}
+		enabled = true
+	}
+	return enabled, perSecond, perEmail, nil
+}
+
+// loadIDGenerator reads the format of generated function and execution IDs
+// from ID_FORMAT (xid or uuidv7, default xid). ID_PREFIX prefixes them with
+// their kind, e.g. fn_ and exec_.
+func loadIDGenerator(getenv func(string) string) (ids.Generator, error) {
+	gen, err := ids.Parse(strings.ToLower(getenv("ID_FORMAT")), loadBool(getenv, "ID_PREFIX"))
+	if err != nil {
+		return nil, fmt.Errorf("ID_FORMAT: %w", err)
+	}
+	return gen, nil
+}
+
+// loadFunctionDefaults reads the settings applied to new functions from
+// DEFAULT_SAVE_RESPONSE and DEFAULT_RETENTION_DAYS, which must be one of
+// api.AllowedRetentionDays. Unset means responses are not saved and
+// executions are kept forever.
+func loadFunctionDefaults(getenv func(string) string) (api.FunctionDefaults, error) {
+	defaults := api.FunctionDefaults{SaveResponse: loadBool(getenv, "DEFAULT_SAVE_RESPONSE")}
+	if value := getenv("DEFAULT_RETENTION_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || !slices.Contains(api.AllowedRetentionDays, days) {
+			return api.FunctionDefaults{}, fmt.Errorf("DEFAULT_RETENTION_DAYS must be one of: %v", api.AllowedRetentionDays)
+		}
+		defaults.RetentionDays = &days
+	}
+	return defaults, nil
+}
+
+// loadGzipMinSize reads the smallest response size that is gzipped, defaulting to
+// api.DefaultGzipMinSize when unset or invalid. DISABLE_GZIP turns compression off.
+func loadGzipMinSize(getenv func(string) string) int {
+	if loadBool(getenv, "DISABLE_GZIP") {
+		return 0
+	}
+	minSize, err := strconv.Atoi(getenv("GZIP_MIN_SIZE"))
+	if err != nil || minSize <= 0 {
+		return api.DefaultGzipMinSize
+	}
+	return minSize
+}
+
 func generateAPIKey() (string, error) {
 	randomBytes := make([]byte, 32)
 	if _, err := rand.Read(randomBytes); err != nil {
@@ -97,6 +343,17 @@ func loadBaseURL(getenv func(string) string, port string) string {
 	return baseURL
 }
 
+// loadList reads a comma-separated list from the given environment variable
+func loadList(getenv func(string) string, key string) []string {
+	var values []string
+	for value := range strings.SplitSeq(getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func initDataDir(getenv func(string) string) (string, error) {
 	dataDir := getenv("DATA_DIR")
 	if dataDir == "" {
@@ -114,16 +371,104 @@ func loadConfig(getenv func(string) string, dataDir string) (Config, error) {
 	timeout := loadTimeout(getenv)
 	baseURL := loadBaseURL(getenv, port)
 
+	tlsCertFile, tlsKeyFile := getenv("TLS_CERT_FILE"), getenv("TLS_KEY_FILE")
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		return Config{}, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	aiBudgetTokens, aiBudgetUSD, err := loadAIBudget(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	outboundDisabled, err := loadOutboundDisabled(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	maxConcurrent, maxQueued, err := loadExecutionQueue(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	maxLogEntries, err := loadMaxLogEntries(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	maxOutboundCalls, err := loadMaxOutboundCalls(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	idGenerator, err := loadIDGenerator(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	accessLogSample, err := loadAccessLogSample(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	functionDefaults, err := loadFunctionDefaults(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	costTracking, costPerSecond, costPerEmail, err := loadCostRates(getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
 	apiKey, err := loadAPIKey(getenv, dataDir)
 	if err != nil {
 		return Config{}, err
 	}
 
 	return Config{
-		Port:             port,
-		DataDir:          dataDir,
-		ExecutionTimeout: timeout,
-		APIKey:           apiKey,
-		BaseURL:          baseURL,
+		Port:              port,
+		DataDir:           dataDir,
+		ExecutionTimeout:  timeout,
+		APIKey:            apiKey,
+		BaseURL:           baseURL,
+		OutboundAllow:     loadList(getenv, "OUTBOUND_ALLOW"),
+		OutboundDeny:      loadList(getenv, "OUTBOUND_DENY"),
+		TrustedProxies:    loadList(getenv, "TRUSTED_PROXIES"),
+		ReadHeaderTimeout: loadSeconds(getenv, "READ_HEADER_TIMEOUT"),
+		ReadTimeout:       loadSeconds(getenv, "READ_TIMEOUT"),
+		WriteTimeout:      loadSeconds(getenv, "WRITE_TIMEOUT"),
+		IdleTimeout:       loadSeconds(getenv, "IDLE_TIMEOUT"),
+		DisableKeepAlives: loadBool(getenv, "DISABLE_KEEP_ALIVES"),
+		TLSCertFile:       tlsCertFile,
+		TLSKeyFile:        tlsKeyFile,
+		EnableH2C:         loadBool(getenv, "ENABLE_H2C"),
+		AIPricesFile:      getenv("AI_PRICES_FILE"),
+		AIBudgetTokens:    aiBudgetTokens,
+		AIBudgetUSD:       aiBudgetUSD,
+		AIMaxRetries:      loadAIMaxRetries(getenv),
+		EmailMaxRetries:   loadEmailMaxRetries(getenv),
+		EmailAllowedFrom:  loadList(getenv, "EMAIL_ALLOWED_FROM"),
+		OutboundDisabled:  outboundDisabled,
+		MaintenanceMode:   loadBool(getenv, "MAINTENANCE_MODE"),
+		MaintenanceRetry:  loadSeconds(getenv, "MAINTENANCE_RETRY_AFTER"),
+		BreakerThreshold:  loadBreakerThreshold(getenv),
+		BreakerCooldown:   loadSeconds(getenv, "CIRCUIT_BREAKER_COOLDOWN"),
+		MaxConcurrent:     maxConcurrent,
+		MaxQueued:         maxQueued,
+		GzipMinSize:       loadGzipMinSize(getenv),
+		MaxLogEntries:     maxLogEntries,
+		MaxOutboundCalls:  maxOutboundCalls,
+		OutboundBudget:    loadSeconds(getenv, "OUTBOUND_TIME_BUDGET"),
+		IDGenerator:       idGenerator,
+		AccessLogSample:   accessLogSample,
+		FunctionDefaults:  functionDefaults,
+		PolicyFile:        getenv("POLICY_FILE"),
+		DevMode:           loadBool(getenv, "DEV_MODE"),
+		SeedFile:          getenv("SEED_FILE"),
+		SeedForce:         loadBool(getenv, "SEED_FORCE"),
+		CostTracking:      costTracking,
+		CostPerSecond:     costPerSecond,
+		CostPerEmail:      costPerEmail,
 	}, nil
 }
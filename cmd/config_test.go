@@ -3,8 +3,13 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/dimiro1/lunar/internal/api"
+	"github.com/dimiro1/lunar/internal/ids"
+	"github.com/dimiro1/lunar/internal/killswitch"
 )
 
 func TestLoadPort_Default(t *testing.T) {
@@ -423,3 +428,587 @@ func TestLoadConfig_BaseURLDefaultsToLocalhost(t *testing.T) {
 		t.Errorf("expected base URL %s, got %s", expected, config.BaseURL)
 	}
 }
+
+func TestLoadConfig_OutboundLists(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{
+		"API_KEY":        "test-key",
+		"OUTBOUND_ALLOW": "10.0.0.5, 192.168.1.0/24",
+		"OUTBOUND_DENY":  "203.0.113.0/24,",
+	}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(config.OutboundAllow) != 2 || config.OutboundAllow[0] != "10.0.0.5" || config.OutboundAllow[1] != "192.168.1.0/24" {
+		t.Errorf("unexpected OutboundAllow: %v", config.OutboundAllow)
+	}
+	if len(config.OutboundDeny) != 1 || config.OutboundDeny[0] != "203.0.113.0/24" {
+		t.Errorf("unexpected OutboundDeny: %v", config.OutboundDeny)
+	}
+}
+
+func TestLoadConfig_EmailAllowedFrom(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{
+		"API_KEY":            "test-key",
+		"EMAIL_ALLOWED_FROM": "noreply@example.com, @example.org",
+	}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(config.EmailAllowedFrom) != 2 || config.EmailAllowedFrom[0] != "noreply@example.com" || config.EmailAllowedFrom[1] != "@example.org" {
+		t.Errorf("unexpected EmailAllowedFrom: %v", config.EmailAllowedFrom)
+	}
+}
+
+func TestLoadConfig_OutboundDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{
+		"API_KEY":           "test-key",
+		"OUTBOUND_DISABLED": "AI, email",
+	}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(config.OutboundDisabled) != 2 || config.OutboundDisabled[0] != killswitch.AI || config.OutboundDisabled[1] != killswitch.Email {
+		t.Errorf("unexpected OutboundDisabled: %v", config.OutboundDisabled)
+	}
+
+	env["OUTBOUND_DISABLED"] = "ftp"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for unknown integration")
+	}
+}
+
+func TestLoadConfig_ExecutionQueue(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.MaxConcurrent != 0 || config.MaxQueued != 100 {
+		t.Errorf("unexpected defaults: max concurrent %d, max queued %d", config.MaxConcurrent, config.MaxQueued)
+	}
+
+	env["MAX_CONCURRENT_EXECUTIONS"] = "8"
+	env["MAX_QUEUED_EXECUTIONS"] = "0"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.MaxConcurrent != 8 || config.MaxQueued != 0 {
+		t.Errorf("unexpected limits: max concurrent %d, max queued %d", config.MaxConcurrent, config.MaxQueued)
+	}
+
+	env["MAX_QUEUED_EXECUTIONS"] = "-5"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for negative MAX_QUEUED_EXECUTIONS")
+	}
+}
+
+func TestLoadConfig_MaxLogEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.MaxLogEntries != 0 {
+		t.Errorf("expected no max log entries by default, got %d", config.MaxLogEntries)
+	}
+
+	env["MAX_LOG_ENTRIES"] = "250"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.MaxLogEntries != 250 {
+		t.Errorf("expected max log entries 250, got %d", config.MaxLogEntries)
+	}
+
+	env["MAX_LOG_ENTRIES"] = "0"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for zero MAX_LOG_ENTRIES")
+	}
+}
+
+func TestLoadConfig_MaxOutboundCalls(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.MaxOutboundCalls != 0 {
+		t.Errorf("expected no outbound call limit by default, got %d", config.MaxOutboundCalls)
+	}
+
+	env["MAX_OUTBOUND_CALLS"] = "50"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.MaxOutboundCalls != 50 {
+		t.Errorf("expected max outbound calls 50, got %d", config.MaxOutboundCalls)
+	}
+
+	env["MAX_OUTBOUND_CALLS"] = "lots"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for non-numeric MAX_OUTBOUND_CALLS")
+	}
+	delete(env, "MAX_OUTBOUND_CALLS")
+
+	env["OUTBOUND_TIME_BUDGET"] = "20"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.OutboundBudget != 20*time.Second {
+		t.Errorf("expected outbound time budget 20s, got %s", config.OutboundBudget)
+	}
+}
+
+func TestLoadConfig_IDGenerator(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id := config.IDGenerator(ids.Function); len(id) != 20 {
+		t.Errorf("expected an xid by default, got %q", id)
+	}
+
+	env["ID_FORMAT"] = "UUIDv7"
+	env["ID_PREFIX"] = "true"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id := config.IDGenerator(ids.Execution); !strings.HasPrefix(id, "exec_") || len(id) != len("exec_")+36 {
+		t.Errorf("expected a prefixed UUID, got %q", id)
+	}
+
+	env["ID_FORMAT"] = "snowflake"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for unknown ID_FORMAT")
+	}
+}
+
+func TestLoadConfig_AccessLogSample(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.AccessLogSample != 1 {
+		t.Errorf("expected every request logged by default, got %v", config.AccessLogSample)
+	}
+
+	env["ACCESS_LOG_SAMPLE_RATE"] = "0.1"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.AccessLogSample != 0.1 {
+		t.Errorf("expected sample rate 0.1, got %v", config.AccessLogSample)
+	}
+
+	env["ACCESS_LOG_SAMPLE_RATE"] = "2"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for a sample rate above 1")
+	}
+}
+
+func TestLoadConfig_CostRates(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.CostTracking {
+		t.Error("expected costs not to be tracked by default")
+	}
+
+	env["COST_PER_EMAIL"] = "0.001"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.CostTracking || config.CostPerEmail != 0.001 || config.CostPerSecond != 0 {
+		t.Errorf("unexpected cost rates: tracking=%v per second=%v per email=%v",
+			config.CostTracking, config.CostPerSecond, config.CostPerEmail)
+	}
+
+	env["COST_PER_COMPUTE_SECOND"] = "-1"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for a negative compute rate")
+	}
+}
+
+func TestLoadConfig_FunctionDefaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.FunctionDefaults.SaveResponse || config.FunctionDefaults.RetentionDays != nil {
+		t.Errorf("expected no function defaults, got %+v", config.FunctionDefaults)
+	}
+
+	env["DEFAULT_SAVE_RESPONSE"] = "true"
+	env["DEFAULT_RETENTION_DAYS"] = "30"
+	config, err = loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.FunctionDefaults.SaveResponse {
+		t.Error("expected responses saved by default")
+	}
+	if days := config.FunctionDefaults.RetentionDays; days == nil || *days != 30 {
+		t.Errorf("expected a 30 day retention, got %v", days)
+	}
+
+	env["DEFAULT_RETENTION_DAYS"] = "10"
+	if _, err := loadConfig(getenv, tmpDir); err == nil {
+		t.Error("expected error for a retention outside the allowed set")
+	}
+}
+
+func TestLoadConfig_Gzip(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{"API_KEY": "test-key"}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.GzipMinSize != api.DefaultGzipMinSize {
+		t.Errorf("expected default gzip min size, got %d", config.GzipMinSize)
+	}
+
+	env["GZIP_MIN_SIZE"] = "4096"
+	config, _ = loadConfig(getenv, tmpDir)
+	if config.GzipMinSize != 4096 {
+		t.Errorf("expected gzip min size 4096, got %d", config.GzipMinSize)
+	}
+
+	env["GZIP_MIN_SIZE"] = "-1"
+	config, _ = loadConfig(getenv, tmpDir)
+	if config.GzipMinSize != api.DefaultGzipMinSize {
+		t.Errorf("expected invalid value to fall back to the default, got %d", config.GzipMinSize)
+	}
+
+	env["DISABLE_GZIP"] = "true"
+	config, _ = loadConfig(getenv, tmpDir)
+	if config.GzipMinSize != 0 {
+		t.Errorf("expected compression to be disabled, got %d", config.GzipMinSize)
+	}
+}
+
+func TestLoadConfig_ServerTimeouts(t *testing.T) {
+	tmpDir := t.TempDir()
+	env := map[string]string{
+		"API_KEY":             "test-key",
+		"READ_HEADER_TIMEOUT": "5",
+		"READ_TIMEOUT":        "20",
+		"WRITE_TIMEOUT":       "invalid",
+		"IDLE_TIMEOUT":        "-1",
+	}
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	config, err := loadConfig(getenv, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if config.ReadHeaderTimeout != 5*time.Second {
+		t.Errorf("expected ReadHeaderTimeout 5s, got %v", config.ReadHeaderTimeout)
+	}
+	if config.ReadTimeout != 20*time.Second {
+		t.Errorf("expected ReadTimeout 20s, got %v", config.ReadTimeout)
+	}
+	if config.WriteTimeout != 0 {
+		t.Errorf("expected invalid WriteTimeout to fall back to 0, got %v", config.WriteTimeout)
+	}
+	if config.IdleTimeout != 0 {
+		t.Errorf("expected negative IdleTimeout to fall back to 0, got %v", config.IdleTimeout)
+	}
+}
+
+func TestLoadConfig_TLS(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("cert and key together", func(t *testing.T) {
+		env := map[string]string{
+			"API_KEY":       "test-key",
+			"TLS_CERT_FILE": "/certs/cert.pem",
+			"TLS_KEY_FILE":  "/certs/key.pem",
+			"ENABLE_H2C":    "true",
+		}
+
+		config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.TLSCertFile != "/certs/cert.pem" || config.TLSKeyFile != "/certs/key.pem" {
+			t.Errorf("unexpected TLS files: %q, %q", config.TLSCertFile, config.TLSKeyFile)
+		}
+		if !config.EnableH2C {
+			t.Error("expected EnableH2C to be true")
+		}
+		if config.DisableKeepAlives {
+			t.Error("expected DisableKeepAlives to default to false")
+		}
+	})
+
+	t.Run("cert without key", func(t *testing.T) {
+		env := map[string]string{
+			"API_KEY":       "test-key",
+			"TLS_CERT_FILE": "/certs/cert.pem",
+		}
+
+		if _, err := loadConfig(func(key string) string { return env[key] }, tmpDir); err == nil {
+			t.Error("expected error when TLS_KEY_FILE is missing")
+		}
+	})
+}
+
+func TestLoadConfig_Maintenance(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	env := map[string]string{
+		"API_KEY":                 "test-key",
+		"MAINTENANCE_MODE":        "true",
+		"MAINTENANCE_RETRY_AFTER": "300",
+	}
+
+	config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.MaintenanceMode {
+		t.Error("expected MaintenanceMode to be true")
+	}
+	if config.MaintenanceRetry != 5*time.Minute {
+		t.Errorf("expected MaintenanceRetry 5m, got %v", config.MaintenanceRetry)
+	}
+}
+
+func TestLoadConfig_DevMode(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	env := map[string]string{"API_KEY": "test-key", "DEV_MODE": "true"}
+	config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.DevMode {
+		t.Error("expected DevMode to be true")
+	}
+
+	config, err = loadConfig(func(key string) string { return map[string]string{"API_KEY": "test-key"}[key] }, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.DevMode {
+		t.Error("expected DevMode to default to false")
+	}
+}
+
+func TestLoadConfig_Seed(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	env := map[string]string{"API_KEY": "test-key", "SEED_FILE": "/etc/lunar/seed.yaml", "SEED_FORCE": "true"}
+	config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.SeedFile != "/etc/lunar/seed.yaml" || !config.SeedForce {
+		t.Errorf("expected the seed file to be forced, got %q (force %v)", config.SeedFile, config.SeedForce)
+	}
+
+	config, err = loadConfig(func(key string) string { return map[string]string{"API_KEY": "test-key"}[key] }, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.SeedFile != "" || config.SeedForce {
+		t.Errorf("expected no seed file by default, got %q (force %v)", config.SeedFile, config.SeedForce)
+	}
+}
+
+func TestLoadConfig_AIBudget(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("valid budget", func(t *testing.T) {
+		env := map[string]string{
+			"API_KEY":                 "test-key",
+			"AI_MONTHLY_TOKEN_BUDGET": "5000000",
+			"AI_MONTHLY_BUDGET_USD":   "25.50",
+		}
+
+		config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.AIBudgetTokens != 5000000 || config.AIBudgetUSD != 25.5 {
+			t.Errorf("unexpected AI budget: %d tokens, $%v", config.AIBudgetTokens, config.AIBudgetUSD)
+		}
+	})
+
+	for _, key := range []string{"AI_MONTHLY_TOKEN_BUDGET", "AI_MONTHLY_BUDGET_USD"} {
+		t.Run("invalid "+key, func(t *testing.T) {
+			env := map[string]string{"API_KEY": "test-key", key: "-1"}
+
+			if _, err := loadConfig(func(key string) string { return env[key] }, tmpDir); err == nil {
+				t.Errorf("expected error for negative %s", key)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_AIMaxRetries(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := map[string]int{
+		"":    2,
+		"0":   0,
+		"5":   5,
+		"-1":  2,
+		"100": 2,
+		"abc": 2,
+	}
+	for value, want := range tests {
+		env := map[string]string{"API_KEY": "test-key", "AI_MAX_RETRIES": value}
+
+		config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.AIMaxRetries != want {
+			t.Errorf("AI_MAX_RETRIES=%q: expected %d retries, got %d", value, want, config.AIMaxRetries)
+		}
+	}
+}
+
+func TestLoadConfig_EmailMaxRetries(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := map[string]int{
+		"":    2,
+		"0":   0,
+		"4":   4,
+		"-1":  2,
+		"100": 2,
+		"abc": 2,
+	}
+	for value, want := range tests {
+		env := map[string]string{"API_KEY": "test-key", "EMAIL_MAX_RETRIES": value}
+
+		config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.EmailMaxRetries != want {
+			t.Errorf("EMAIL_MAX_RETRIES=%q: expected %d retries, got %d", value, want, config.EmailMaxRetries)
+		}
+	}
+}
+
+func TestLoadConfig_CircuitBreaker(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := map[string]int{
+		"":    5,
+		"0":   0,
+		"3":   3,
+		"-1":  5,
+		"abc": 5,
+	}
+	for value, want := range tests {
+		env := map[string]string{"API_KEY": "test-key", "CIRCUIT_BREAKER_THRESHOLD": value}
+
+		config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.BreakerThreshold != want {
+			t.Errorf("CIRCUIT_BREAKER_THRESHOLD=%q: expected %d, got %d", value, want, config.BreakerThreshold)
+		}
+	}
+
+	env := map[string]string{"API_KEY": "test-key", "CIRCUIT_BREAKER_COOLDOWN": "45"}
+	config, err := loadConfig(func(key string) string { return env[key] }, tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.BreakerCooldown != 45*time.Second {
+		t.Errorf("expected 45s cooldown, got %v", config.BreakerCooldown)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -11,16 +12,24 @@ import (
 	"time"
 
 	"github.com/dimiro1/lunar/frontend"
-	"github.com/dimiro1/lunar/internal/services/ai"
 	"github.com/dimiro1/lunar/internal/api"
 	internalcron "github.com/dimiro1/lunar/internal/cron"
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/housekeeping"
+	"github.com/dimiro1/lunar/internal/inflight"
+	"github.com/dimiro1/lunar/internal/killswitch"
+	"github.com/dimiro1/lunar/internal/maintenance"
+	"github.com/dimiro1/lunar/internal/migrate"
+	"github.com/dimiro1/lunar/internal/queue"
+	"github.com/dimiro1/lunar/internal/runner"
+	"github.com/dimiro1/lunar/internal/seed"
+	"github.com/dimiro1/lunar/internal/services/ai"
 	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/services/env"
-	"github.com/dimiro1/lunar/internal/housekeeping"
 	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 	"github.com/dimiro1/lunar/internal/services/kv"
 	"github.com/dimiro1/lunar/internal/services/logger"
-	"github.com/dimiro1/lunar/internal/migrate"
+	"github.com/dimiro1/lunar/internal/services/modules"
 	store "github.com/dimiro1/lunar/internal/store"
 	_ "modernc.org/sqlite"
 )
@@ -67,7 +76,38 @@ func main() {
 	appLogger := logger.NewSQLiteLogger(db)
 	aiRequestTracker := ai.NewSQLiteTracker(db)
 	emailRequestTracker := email.NewSQLiteTracker(db)
-	httpClient := internalhttp.NewDefaultClient()
+	emailTemplates := email.NewSQLiteTemplateStore(db)
+	luaModules := modules.NewSQLiteStore(db)
+	httpRequestTracker := internalhttp.NewSQLiteTracker(db)
+
+	// Import env vars and KV entries from the seed file, keeping existing keys
+	// unless the import is forced
+	if config.SeedFile != "" {
+		seedFile, err := seed.Load(config.SeedFile)
+		if err != nil {
+			slog.Error("Failed to load seed file", "error", err)
+			os.Exit(1)
+		}
+		result, err := seed.Apply(seedFile, kvStore, envStore, config.SeedForce)
+		if err != nil {
+			slog.Error("Failed to apply seed file", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Seed file applied", "file", config.SeedFile, "imported", result.Imported, "skipped", result.Skipped)
+	}
+
+	outboundPolicy, err := internalhttp.NewPolicy(config.OutboundAllow, config.OutboundDeny)
+	if err != nil {
+		slog.Error("Invalid outbound network policy", "error", err)
+		os.Exit(1)
+	}
+	httpClient := internalhttp.NewRestrictedClient(outboundPolicy)
+
+	trustedProxies, err := internalhttp.ParsePrefixes(config.TrustedProxies)
+	if err != nil {
+		slog.Error("Invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize housekeeping scheduler
 	housekeepingScheduler := housekeeping.NewScheduler(apiDB)
@@ -76,33 +116,123 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Maintenance mode pauses HTTP executions and cron schedules
+	maintenanceMode := maintenance.New(config.MaintenanceMode, config.MaintenanceRetry)
+	if config.MaintenanceMode {
+		slog.Warn("Starting in maintenance mode: function executions are paused")
+	}
+
+	// Outbound integrations can be switched off during incidents
+	integrations := killswitch.New(config.OutboundDisabled...)
+	if len(config.OutboundDisabled) > 0 {
+		slog.Warn("Starting with outbound integrations disabled", "integrations", config.OutboundDisabled)
+	}
+
+	// Bound concurrent executions; excess requests wait in a bounded queue
+	executionQueue := queue.New(config.MaxConcurrent, config.MaxQueued)
+	if config.MaxConcurrent > 0 {
+		slog.Info("Execution concurrency limited", "max_concurrent", config.MaxConcurrent, "max_queued", config.MaxQueued)
+	}
+
+	// Executions in progress, so cron fires of functions without overlap wait
+	// for runs of any trigger
+	inFlight := inflight.New()
+
 	// Initialize function cron scheduler
 	functionScheduler := internalcron.NewScheduler(apiDB, config.BaseURL)
+	functionScheduler.SetMaintenance(maintenanceMode)
+	functionScheduler.SetInFlight(inFlight)
+	functionScheduler.SetIDGenerator(config.IDGenerator)
+	// Cron triggers call the function endpoint, so hold them until it is served
+	serverReady := make(chan struct{})
+	functionScheduler.SetReady(serverReady)
 	if err := functionScheduler.Start(); err != nil {
 		slog.Error("Failed to start function cron scheduler", "error", err)
 		os.Exit(1)
 	}
 
+	aiPrices, err := ai.LoadPriceTable(config.AIPricesFile)
+	if err != nil {
+		slog.Error("Failed to load AI price table", "error", err)
+		os.Exit(1)
+	}
+
+	// Operators can allow or deny every execution with a Lua policy
+	var policy engine.Policy
+	if config.PolicyFile != "" {
+		luaPolicy, err := runner.LoadLuaPolicy(config.PolicyFile)
+		if err != nil {
+			slog.Error("Failed to load policy", "error", err)
+			os.Exit(1)
+		}
+		policy = luaPolicy
+		slog.Info("Execution policy enabled", "file", config.PolicyFile)
+	}
+
+	// Executions are priced for chargeback when a cost rate is configured
+	var costs *engine.CostRates
+	if config.CostTracking {
+		costs = &engine.CostRates{
+			ComputePerSecond: config.CostPerSecond,
+			PerEmail:         config.CostPerEmail,
+			AIPrices:         aiPrices,
+		}
+	}
+
 	server := api.NewServer(api.ServerConfig{
-		DB:               apiDB,
-		Logger:           appLogger,
-		KVStore:          kvStore,
-		EnvStore:         envStore,
-		HTTPClient:       httpClient,
-		AITracker:        aiRequestTracker,
-		EmailTracker:     emailRequestTracker,
-		Scheduler:        functionScheduler,
-		ExecutionTimeout: config.ExecutionTimeout,
-		FrontendHandler:  frontend.Handler(),
-		APIKey:           config.APIKey,
-		BaseURL:          config.BaseURL,
+		DB:                apiDB,
+		Logger:            appLogger,
+		KVStore:           kvStore,
+		EnvStore:          envStore,
+		HTTPClient:        httpClient,
+		AITracker:         aiRequestTracker,
+		EmailTracker:      emailRequestTracker,
+		EmailTemplates:    emailTemplates,
+		Modules:           luaModules,
+		HTTPTracker:       httpRequestTracker,
+		Scheduler:         functionScheduler,
+		ExecutionTimeout:  config.ExecutionTimeout,
+		FrontendHandler:   frontend.Handler(),
+		APIKey:            config.APIKey,
+		BaseURL:           config.BaseURL,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		DisableKeepAlives: config.DisableKeepAlives,
+		TLSCertFile:       config.TLSCertFile,
+		TLSKeyFile:        config.TLSKeyFile,
+		EnableH2C:         config.EnableH2C,
+		AIPrices:          aiPrices,
+		AIBudget:          ai.Budget{Tokens: config.AIBudgetTokens, CostUSD: config.AIBudgetUSD},
+		AIRetry:           ai.RetryPolicy{MaxRetries: config.AIMaxRetries},
+		EmailRetry:        email.RetryPolicy{MaxRetries: config.EmailMaxRetries},
+		EmailAllowedFrom:  email.SenderAllowlist(config.EmailAllowedFrom),
+		Maintenance:       maintenanceMode,
+		InFlight:          inFlight,
+		Integrations:      integrations,
+		CircuitBreaker:    internalhttp.BreakerConfig{Threshold: config.BreakerThreshold, Cooldown: config.BreakerCooldown},
+		ExecutionQueue:    executionQueue,
+		GzipMinSize:       config.GzipMinSize,
+		MaxLogEntries:     config.MaxLogEntries,
+		MaxOutboundCalls:  config.MaxOutboundCalls,
+		OutboundBudget:    config.OutboundBudget,
+		IDGenerator:       config.IDGenerator,
+		AccessLogSample:   config.AccessLogSample,
+		TrustedProxies:    trustedProxies,
+		FunctionDefaults:  config.FunctionDefaults,
+		Policy:            policy,
+		DevMode:           config.DevMode,
+		Costs:             costs,
 	})
 
 	addr := ":" + config.Port
 	slog.Info("Starting Lunar server",
 		"port", config.Port,
 		"data_dir", config.DataDir,
-		"execution_timeout", config.ExecutionTimeout)
+		"execution_timeout", config.ExecutionTimeout,
+		"tls", config.TLSCertFile != "",
+		"h2c", config.EnableH2C)
 	slog.Info("Frontend available", "url", "http://localhost:"+config.Port)
 	slog.Info("API available", "url", "http://localhost:"+config.Port+"/api")
 
@@ -110,13 +240,20 @@ func main() {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Error("Failed to listen", "addr", addr, "error", err)
+		os.Exit(1)
+	}
+
 	// Start server in a goroutine
 	serverErr := make(chan error, 1)
 	go func() {
-		if err := server.ListenAndServe(addr); err != nil {
+		if err := server.Serve(ln); err != nil {
 			serverErr <- err
 		}
 	}()
+	close(serverReady)
 
 	// Wait for shutdown signal or server error
 	select {
@@ -14,6 +14,9 @@
  * @typedef {import('./types.js').DiffResponse} DiffResponse
  * @typedef {import('./types.js').ExecuteRequest} ExecuteRequest
  * @typedef {import('./types.js').ExecuteResponse} ExecuteResponse
+ * @typedef {import('./types.js').AIUsageResponse} AIUsageResponse
+ * @typedef {import('./types.js').EmailTemplate} EmailTemplate
+ * @typedef {import('./types.js').EmailTemplatesResponse} EmailTemplatesResponse
  */
 
 /**
@@ -24,7 +27,7 @@
  * @param {Object} [config.body] - Request body
  * @param {Object} [config.headers] - Request headers
  * @returns {Promise<*>} Response data
- * @throws {Error} API error with message from response
+ * @throws {Error} API error with message from response, and per-field messages in `fields`
  */
 const apiRequest = async (config) => {
   try {
@@ -40,6 +43,8 @@ const apiRequest = async (config) => {
       // Throw a proper Error object with the error message
       const error = new Error(err.response.error);
       error.code = err.code;
+      // Field name -> message for validation errors
+      error.fields = err.response.errors || {};
       throw error;
     }
     throw err;
@@ -123,9 +128,16 @@ export const API = {
     /**
      * Gets a single function by ID.
      * @param {string} id - Function ID
+     * @param {string} [include] - Related data to embed, e.g. "recent_executions"
      * @returns {Promise<LunarFunction>} The function
      */
-    get: (id) => apiRequest({ method: "GET", url: `/api/functions/${id}` }),
+    get: (id, include) =>
+      apiRequest({
+        method: "GET",
+        url: include
+          ? `/api/functions/${id}?include=${encodeURIComponent(include)}`
+          : `/api/functions/${id}`,
+      }),
 
     /**
      * Creates a new function.
@@ -138,6 +150,33 @@ export const API = {
     create: (data) =>
       apiRequest({ method: "POST", url: "/api/functions", body: data }),
 
+    /**
+     * Deploys new code with settings and env var changes. Nothing is
+     * applied when any part fails.
+     * @param {string} id - Function ID
+     * @param {Object} data - Update fields with code, plus env_vars changes (null deletes a key)
+     * @returns {Promise<LunarFunction>} The deployed function
+     */
+    deploy: (id, data) =>
+      apiRequest({
+        method: "POST",
+        url: `/api/functions/${id}/deploy`,
+        body: data,
+      }),
+
+    /**
+     * Creates and updates several functions at once. Nothing is applied
+     * when any operation fails.
+     * @param {Object[]} operations - Operations with an action ("create" or "update") and function fields
+     * @returns {Promise<Object>} Response with applied flag and per-operation results
+     */
+    batch: (operations) =>
+      apiRequest({
+        method: "POST",
+        url: "/api/functions/batch",
+        body: { operations },
+      }),
+
     /**
      * Updates an existing function.
      * @param {string} id - Function ID
@@ -172,6 +211,38 @@ export const API = {
         body: { env_vars },
       }),
 
+    /**
+     * Reports which providers a function has the environment variables for.
+     * @param {string} id - Function ID
+     * @returns {Promise<{providers: ProviderStatus[]}>} Provider readiness
+     */
+    integrationsStatus: (id) =>
+      apiRequest({
+        method: "GET",
+        url: `/api/functions/${id}/integrations/status`,
+      }),
+
+    /**
+     * Runs a function in a sandbox, recording its outbound calls instead of sending them.
+     * @param {string} id - Function ID
+     * @param {TestFunctionRequest} [request] - The request the function receives
+     * @returns {Promise<TestFunctionResponse>} The result and side effects
+     */
+    test: (id, request = {}) =>
+      apiRequest({
+        method: "POST",
+        url: `/api/functions/${id}/test`,
+        body: request,
+      }),
+
+    /**
+     * Checks that a function returns a valid response, without recording an execution.
+     * @param {string} id - Function ID
+     * @returns {Promise<SelfTestResponse>} Whether the self-test passed
+     */
+    selfTest: (id) =>
+      apiRequest({ method: "GET", url: `/api/functions/${id}/selftest` }),
+
     /**
      * Gets the next scheduled run time for a function.
      * @param {string} id - Function ID
@@ -179,6 +250,99 @@ export const API = {
      */
     getNextRun: (id) =>
       apiRequest({ method: "GET", url: `/api/functions/${id}/next-run` }),
+
+    /**
+     * Gets the aggregated AI usage and estimated cost for a function.
+     * @param {string} id - Function ID
+     * @param {string} [window="30d"] - Time window (e.g. "7d", "12h")
+     * @returns {Promise<AIUsageResponse>} AI usage report
+     */
+    getAIUsage: (id, window = "30d") =>
+      apiRequest({
+        method: "GET",
+        url: `/api/functions/${id}/ai/usage?window=${encodeURIComponent(window)}`,
+      }),
+
+    /**
+     * Gets a function's execution counts and durations bucketed over time.
+     * @param {string} id - Function ID
+     * @param {string} [window="24h"] - Time window (e.g. "7d", "24h")
+     * @param {string} [bucket="1h"] - Bucket size (e.g. "5m", "1h")
+     * @returns {Promise<MetricsTimeseriesResponse>} Metrics with one entry per bucket
+     */
+    getMetricsTimeseries: (id, window = "24h", bucket = "1h") =>
+      apiRequest({
+        method: "GET",
+        url: `/api/functions/${id}/metrics/timeseries?window=${encodeURIComponent(window)}&bucket=${encodeURIComponent(bucket)}`,
+      }),
+
+    /**
+     * Lists the email templates of a function.
+     * @param {string} id - Function ID
+     * @returns {Promise<EmailTemplatesResponse>} Email templates sorted by name
+     */
+    listEmailTemplates: (id) =>
+      apiRequest({ method: "GET", url: `/api/functions/${id}/email-templates` }),
+
+    /**
+     * Creates or replaces an email template of a function.
+     * @param {string} id - Function ID
+     * @param {string} name - Template name
+     * @param {{subject?: string, html?: string, text?: string}} template - Template parts
+     * @returns {Promise<EmailTemplate>} The saved template
+     */
+    saveEmailTemplate: (id, name, template) =>
+      apiRequest({
+        method: "PUT",
+        url: `/api/functions/${id}/email-templates/${encodeURIComponent(name)}`,
+        body: template,
+      }),
+
+    /**
+     * Deletes an email template of a function.
+     * @param {string} id - Function ID
+     * @param {string} name - Template name
+     * @returns {Promise<void>}
+     */
+    deleteEmailTemplate: (id, name) =>
+      apiRequest({
+        method: "DELETE",
+        url: `/api/functions/${id}/email-templates/${encodeURIComponent(name)}`,
+      }),
+
+    /**
+     * Lists the Lua modules of a function.
+     * @param {string} id - Function ID
+     * @returns {Promise<ModulesResponse>} Modules sorted by name
+     */
+    listModules: (id) =>
+      apiRequest({ method: "GET", url: `/api/functions/${id}/modules` }),
+
+    /**
+     * Creates or replaces a Lua module of a function.
+     * @param {string} id - Function ID
+     * @param {string} name - Module name
+     * @param {string} code - Lua code returning the module value
+     * @returns {Promise<Module>} The saved module
+     */
+    saveModule: (id, name, code) =>
+      apiRequest({
+        method: "PUT",
+        url: `/api/functions/${id}/modules/${encodeURIComponent(name)}`,
+        body: { code },
+      }),
+
+    /**
+     * Deletes a Lua module of a function.
+     * @param {string} id - Function ID
+     * @param {string} name - Module name
+     * @returns {Promise<void>}
+     */
+    deleteModule: (id, name) =>
+      apiRequest({
+        method: "DELETE",
+        url: `/api/functions/${id}/modules/${encodeURIComponent(name)}`,
+      }),
   },
 
   /**
@@ -216,7 +380,7 @@ export const API = {
      * Activates a specific version.
      * @param {string} functionId - Function ID
      * @param {string} versionId - Version ID to activate
-     * @returns {Promise<void>}
+     * @returns {Promise<FunctionVersion>} The activated version
      */
     activate: (functionId, versionId) =>
       apiRequest({
@@ -224,6 +388,33 @@ export const API = {
         url: `/api/functions/${functionId}/versions/${versionId}/activate`,
       }),
 
+    /**
+     * Creates a new active version from code hosted at a URL.
+     * @param {string} functionId - Function ID
+     * @param {Object} data - Request with url, optional auth_header and label
+     * @returns {Promise<FunctionVersion>} The created version
+     */
+    createFromURL: (functionId, data) =>
+      apiRequest({
+        method: "POST",
+        url: `/api/functions/${functionId}/versions/from-url`,
+        body: data,
+      }),
+
+    /**
+     * Sets or removes the label of a version.
+     * @param {string} functionId - Function ID
+     * @param {string} versionId - Version ID to update
+     * @param {string} label - New label (empty string removes it)
+     * @returns {Promise<FunctionVersion>} The updated version
+     */
+    updateLabel: (functionId, versionId, label) =>
+      apiRequest({
+        method: "PATCH",
+        url: `/api/functions/${functionId}/versions/${versionId}`,
+        body: { label },
+      }),
+
     /**
      * Gets a diff between two versions.
      * @param {string} functionId - Function ID
@@ -237,6 +428,19 @@ export const API = {
         url: `/api/functions/${functionId}/diff/${v1}/${v2}`,
       }),
 
+    /**
+     * Summarizes the diff between two versions.
+     * @param {string} functionId - Function ID
+     * @param {number} v1 - First version number
+     * @param {number} v2 - Second version number
+     * @returns {Promise<DiffSummaryResponse>} Line counts and handler changes
+     */
+    diffSummary: (functionId, v1, v2) =>
+      apiRequest({
+        method: "GET",
+        url: `/api/functions/${functionId}/diff/${v1}/${v2}/summary`,
+      }),
+
     /**
      * Deletes a specific version.
      * @param {string} functionId - Function ID
@@ -277,6 +481,20 @@ export const API = {
     get: (executionId) =>
       apiRequest({ method: "GET", url: `/api/executions/${executionId}` }),
 
+    /**
+     * Lists failed executions across all functions.
+     * @param {string} [window="1h"] - Time window (e.g. "1h", "7d")
+     * @param {number} [limit=20] - Maximum number of executions to return
+     * @param {number} [offset=0] - Number of executions to skip
+     * @returns {Promise<RecentErrorsResponse>} Paginated list of recent errors
+     */
+    recentErrors: (window = "1h", limit = 20, offset = 0) =>
+      apiRequest({
+        method: "GET",
+        url:
+          `/api/admin/errors?window=${encodeURIComponent(window)}&limit=${limit}&offset=${offset}`,
+      }),
+
     /**
      * Gets logs for an execution.
      * @param {string} executionId - Execution ID
@@ -318,10 +536,90 @@ export const API = {
         url:
           `/api/executions/${executionId}/email-requests?limit=${limit}&offset=${offset}`,
       }),
+
+    /**
+     * Gets captured outbound HTTP requests for an execution.
+     * @param {string} executionId - Execution ID
+     * @param {number} [limit=20] - Maximum number of HTTP requests to return
+     * @param {number} [offset=0] - Number of HTTP requests to skip
+     * @returns {Promise<HTTPRequestsResponse>} Paginated list of HTTP requests
+     */
+    getHTTPRequests: (executionId, limit = 20, offset = 0) =>
+      apiRequest({
+        method: "GET",
+        url:
+          `/api/executions/${executionId}/http-requests?limit=${limit}&offset=${offset}`,
+      }),
+
+    /**
+     * Gets every outbound call (AI, email and HTTP) of an execution in chronological order.
+     * @param {string} executionId - Execution ID
+     * @param {number} [limit=20] - Maximum number of calls to return
+     * @param {number} [offset=0] - Number of calls to skip
+     * @returns {Promise<ExternalCallsResponse>} Paginated list of external calls
+     */
+    getExternalCalls: (executionId, limit = 20, offset = 0) =>
+      apiRequest({
+        method: "GET",
+        url:
+          `/api/executions/${executionId}/external?limit=${limit}&offset=${offset}`,
+      }),
+
+    /**
+     * Gathers everything known about an execution, for debugging.
+     * @param {string} executionId - Execution ID
+     * @returns {Promise<ExplainExecutionResponse>} Record, event, version, timing and calls
+     */
+    explain: (executionId) =>
+      apiRequest({
+        method: "GET",
+        url: `/api/executions/${executionId}/explain`,
+      }),
+  },
+
+  /**
+   * Server configuration endpoints.
+   */
+  config: {
+    /**
+     * Gets the server's effective limits and enabled integrations.
+     * @returns {Promise<ServerConfig>} Limits and integration switches
+     */
+    get: () => apiRequest({ method: "GET", url: "/api/config" }),
+
+    /**
+     * Lists the known providers and the environment variables they read.
+     * @returns {Promise<{providers: Provider[]}>} Providers
+     */
+    providers: () =>
+      apiRequest({ method: "GET", url: "/api/integrations/providers" }),
+  },
+
+  /**
+   * Lua code tooling endpoints.
+   */
+  lua: {
+    /**
+     * Formats Lua code.
+     * @param {string} code - Lua code
+     * @returns {Promise<{code: string, changed: boolean}>} Formatted code
+     */
+    format: (code) =>
+      apiRequest({ method: "POST", url: "/api/lua/format", body: { code } }),
+
+    /**
+     * Lints Lua code.
+     * @param {string} code - Lua code
+     * @returns {Promise<{findings: LintFinding[]}>} Findings sorted by line
+     */
+    lint: (code) =>
+      apiRequest({ method: "POST", url: "/api/lua/lint", body: { code } }),
   },
 
   /**
    * Executes a function with the given request parameters.
+   * The execution is recorded with the manual trigger unless the request
+   * sets its own X-Trigger header.
    * @param {string} functionId - Function ID to execute
    * @param {ExecuteRequest} request - Request parameters
    * @returns {Promise<ExecuteResponse>} Execution response with headers
@@ -356,7 +654,7 @@ export const API = {
       method: request.method || "GET",
       url: url,
       body: body,
-      headers: request.headers,
+      headers: { "X-Trigger": "manual", ...request.headers },
       /**
        * Extracts response data including execution headers.
        * @param {XMLHttpRequest} xhr - The XHR object
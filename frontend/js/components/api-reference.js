@@ -208,6 +208,65 @@ export function getLuaAPISections() {
               type: "function",
               description: t("luaApi.email.items.send"),
             },
+            {
+              name: "email.send_template(options)",
+              type: "function",
+              description: t("luaApi.email.items.sendTemplate"),
+            },
+          ],
+        },
+      ],
+    },
+    {
+      id: "execution",
+      name: t("luaApi.execution.name"),
+      description: t("luaApi.execution.description"),
+      groups: [
+        {
+          name: t("luaApi.execution.groups.tags"),
+          items: [
+            {
+              name: "execution.tag(key, value)",
+              type: "function",
+              description: t("luaApi.execution.items.tag"),
+            },
+          ],
+        },
+        {
+          name: t("luaApi.execution.groups.spans"),
+          items: [
+            {
+              name: "trace.span(name)",
+              type: "function",
+              description: t("luaApi.execution.items.span"),
+            },
+          ],
+        },
+      ],
+    },
+    {
+      id: "respond",
+      name: t("luaApi.respond.name"),
+      description: t("luaApi.respond.description"),
+      groups: [
+        {
+          name: t("luaApi.respond.groups.files"),
+          items: [
+            {
+              name: "respond.file(bytes, content_type, filename)",
+              type: "function",
+              description: t("luaApi.respond.items.file"),
+            },
+          ],
+        },
+        {
+          name: t("luaApi.respond.groups.redirects"),
+          items: [
+            {
+              name: "respond.redirect(location, status)",
+              type: "function",
+              description: t("luaApi.respond.items.redirect"),
+            },
           ],
         },
       ],
@@ -285,11 +344,56 @@ export function getLuaAPISections() {
               type: "table",
               description: t("luaApi.handler.items.query"),
             },
+            {
+              name: "event.headers_all",
+              type: "table",
+              description: t("luaApi.handler.items.headersAll"),
+            },
+            {
+              name: "event.query_all",
+              type: "table",
+              description: t("luaApi.handler.items.queryAll"),
+            },
+            {
+              name: "event.remote_ip",
+              type: "string",
+              description: t("luaApi.handler.items.remoteIp"),
+            },
+            {
+              name: "event.scheme",
+              type: "string",
+              description: t("luaApi.handler.items.scheme"),
+            },
             {
               name: "event.relativePath",
               type: "string",
               description: t("luaApi.handler.items.relativePath"),
             },
+            {
+              name: "event.basic_auth()",
+              type: "function",
+              description: t("luaApi.handler.items.basicAuth"),
+            },
+          ],
+        },
+        {
+          name: t("luaApi.handler.groups.lifecycle"),
+          items: [
+            {
+              name: "init()",
+              type: "function",
+              description: t("luaApi.handler.items.init"),
+            },
+            {
+              name: "cleanup()",
+              type: "function",
+              description: t("luaApi.handler.items.cleanup"),
+            },
+            {
+              name: "app",
+              type: "table",
+              description: t("luaApi.handler.items.app"),
+            },
           ],
         },
       ],
@@ -229,7 +229,18 @@ export const LogLevelBadge = {
 };
 
 /**
- * Trigger Badge - for execution trigger type (http/cron).
+ * Badge variants for each execution trigger; unknown triggers render as http.
+ * @type {Object.<string, string>}
+ */
+const triggerVariants = {
+  http: BadgeVariant.SUCCESS,
+  cron: BadgeVariant.INFO,
+  manual: BadgeVariant.SECONDARY,
+  test: BadgeVariant.WARNING,
+};
+
+/**
+ * Trigger Badge - for execution trigger type (http/cron/manual/test).
  * @type {Object}
  */
 export const TriggerBadge = {
@@ -237,23 +248,23 @@ export const TriggerBadge = {
    * Renders the trigger badge.
    * @param {Object} vnode - Mithril vnode
    * @param {Object} vnode.attrs - Component attributes
-   * @param {('http'|'cron')} vnode.attrs.trigger - Trigger type
+   * @param {('http'|'cron'|'manual'|'test')} vnode.attrs.trigger - Trigger type
    * @returns {Object} Mithril vnode
    */
   view(vnode) {
-    const { trigger } = vnode.attrs;
-
-    const isCron = trigger === "cron";
+    const trigger = Object.hasOwn(triggerVariants, vnode.attrs.trigger)
+      ? vnode.attrs.trigger
+      : "http";
 
     return m(
       Badge,
       {
-        variant: isCron ? BadgeVariant.INFO : BadgeVariant.SUCCESS,
+        variant: triggerVariants[trigger],
         size: BadgeSize.SM,
         uppercase: true,
         mono: true,
       },
-      t(isCron ? "executions.triggers.cron" : "executions.triggers.http"),
+      t(`executions.triggers.${trigger}`),
     );
   },
 };
@@ -75,12 +75,39 @@ const API_DOCS = {
     snippet: "event.query",
     description: "Query parameters (table with param name as key)",
   },
+  "event.headers_all": {
+    signature: "event.headers_all: table",
+    snippet: "event.headers_all",
+    description: "Every value of each request header (table of arrays)",
+  },
+  "event.query_all": {
+    signature: "event.query_all: table",
+    snippet: "event.query_all",
+    description:
+      "Every value of each query parameter (table of arrays, e.g. ?id=1&id=2)",
+  },
+  "event.remote_ip": {
+    signature: "event.remote_ip: string",
+    snippet: "event.remote_ip",
+    description: "Client IP address, behind any trusted proxies",
+  },
+  "event.scheme": {
+    signature: "event.scheme: string",
+    snippet: "event.scheme",
+    description: "Scheme the client used (http or https)",
+  },
   "event.relativePath": {
     signature: "event.relativePath: string",
     snippet: "event.relativePath",
     description:
       "Request path without /fn/{function_id} prefix (e.g., /api/users)",
   },
+  "event.basic_auth": {
+    signature: "event.basic_auth(): string?, string?, boolean",
+    snippet: "event.basic_auth()",
+    description:
+      "Username, password and ok parsed from an Authorization: Basic header (nil, nil, false when missing or malformed)",
+  },
   "log.info": {
     signature: "log.info(message: string)",
     snippet: 'log.info("${1:message}")',
@@ -355,6 +382,42 @@ const API_DOCS = {
     description:
       "Send email via Resend. Requires RESEND_API_KEY env var. scheduled_at accepts Unix timestamp or ISO 8601 string. Returns {id}.",
   },
+  "email.send_template": {
+    signature: "email.send_template(options: table): table | nil, error | nil",
+    snippet: `email.send_template({
+\ttemplate = "\${1:welcome}",
+\tdata = {\${2:name = "value"}},
+\tfrom = "\${3:sender@example.com}",
+\tto = "\${4:recipient@example.com}"
+})`,
+    description:
+      "Render a stored email template with data and send it via Resend. Takes the same options as email.send plus template and data. Returns {id}.",
+  },
+  "execution.tag": {
+    signature: "execution.tag(key: string, value: string)",
+    snippet: 'execution.tag("${1:customer}", ${2:value})',
+    description:
+      "Tag the current execution so it can be filtered by key:value later. Up to 20 tags per execution.",
+  },
+  "trace.span": {
+    signature: "trace.span(name: string): table",
+    snippet: 'local ${1:span} = trace.span("${2:db_query}")',
+    description:
+      "Start a named timing span shown in the execution detail. Call finish() on the handle to end it; it returns the duration in milliseconds. Up to 100 spans per execution.",
+  },
+  "respond.file": {
+    signature:
+      "respond.file(bytes: string, content_type?: string, filename?: string): table",
+    snippet: 'respond.file(${1:data}, "${2:text/csv}", "${3:report.csv}")',
+    description:
+      "Build a response serving bytes as a file. The body is base64 encoded and decoded by the server; a filename adds Content-Disposition: attachment.",
+  },
+  "respond.redirect": {
+    signature: "respond.redirect(location: string, status?: number): table",
+    snippet: 'respond.redirect("${1:/login}")',
+    description:
+      "Build a response redirecting the client to location with an empty body. The status defaults to 302 and must be a 3xx status.",
+  },
   "router.match": {
     signature: "router.match(path: string, pattern: string): boolean",
     snippet: 'router.match(${1:path}, "${2:/users/:id}")',
@@ -0,0 +1,334 @@
+/**
+ * @fileoverview HTTP Request viewer component with table structure and expandable rows.
+ */
+
+import { icons } from "../icons.js";
+import { Badge, BadgeSize, BadgeVariant } from "./badge.js";
+import { CodeViewer } from "./code-viewer.js";
+import { formatUnixTimestamp } from "../utils.js";
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableEmpty,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from "./table.js";
+import { t } from "../i18n/index.js";
+
+/**
+ * @typedef {import('../types.js').HTTPRequest} HTTPRequest
+ */
+
+/**
+ * Maximum characters to display in a body before truncating.
+ * @type {number}
+ */
+const MAX_BODY_DISPLAY_LENGTH = 5000;
+
+/**
+ * HTTP Request viewer component for displaying outbound HTTP requests in a table with expandable details.
+ * @type {Object}
+ */
+export const HTTPRequestViewer = {
+  /**
+   * Track which rows are expanded.
+   * @type {Set<string>}
+   */
+  expandedRows: new Set(),
+
+  /**
+   * Toggles expansion state for a row.
+   * @param {string} id - Request ID
+   */
+  toggleRow(id) {
+    if (this.expandedRows.has(id)) {
+      this.expandedRows.delete(id);
+    } else {
+      this.expandedRows.add(id);
+    }
+  },
+
+  /**
+   * Formats a body for display, pretty-printing JSON and truncating long content.
+   * @param {string} body - Body string
+   * @returns {{formatted: string, language: string}} Formatted body and its language
+   */
+  formatBody(body) {
+    if (!body) {
+      return { formatted: "", language: "" };
+    }
+
+    let formatted = body;
+    let language = "";
+    try {
+      formatted = JSON.stringify(JSON.parse(body), null, 2);
+      language = "json";
+    } catch {
+      // Not JSON, show as is
+    }
+
+    if (formatted.length > MAX_BODY_DISPLAY_LENGTH) {
+      formatted = formatted.substring(0, MAX_BODY_DISPLAY_LENGTH) +
+        "\n\n" + t("httpRequestViewer.truncated");
+    }
+    return { formatted, language };
+  },
+
+  /**
+   * Formats headers as one "Name: value" line per header.
+   * @param {Object.<string, string>} [headers] - Headers map
+   * @returns {string} Formatted headers
+   */
+  formatHeaders(headers) {
+    if (!headers) {
+      return "";
+    }
+    return Object.keys(headers)
+      .sort()
+      .map((name) => `${name}: ${headers[name]}`)
+      .join("\n");
+  },
+
+  /**
+   * Renders the HTTP request viewer component.
+   * @param {Object} vnode - Mithril vnode
+   * @param {Object} vnode.attrs - Component attributes
+   * @param {HTTPRequest[]} [vnode.attrs.requests=[]] - Array of HTTP requests
+   * @param {string} [vnode.attrs.maxHeight='400px'] - Maximum height
+   * @param {boolean} [vnode.attrs.noBorder=false] - Remove border styling
+   * @returns {Object} Mithril vnode
+   */
+  view(vnode) {
+    const { requests = [], maxHeight = "400px", noBorder = false } =
+      vnode.attrs;
+
+    if (requests.length === 0) {
+      return m(Table, [
+        m(TableBody, [
+          m(TableEmpty, {
+            colspan: 6,
+            icon: "globe",
+            message: t("httpRequestViewer.noRequests"),
+          }),
+        ]),
+      ]);
+    }
+
+    return m(
+      ".http-request-viewer",
+      {
+        class: noBorder ? "http-request-viewer--no-border" : "",
+        style: maxHeight ? `max-height: ${maxHeight}; overflow-y: auto` : "",
+      },
+      [
+        m(Table, { style: "table-layout: fixed;" }, [
+          m(TableHeader, [
+            m(TableRow, [
+              m(TableHead, { style: "width: 2rem;" }, ""),
+              m(
+                TableHead,
+                { style: "width: 10%;" },
+                t("httpRequestViewer.method"),
+              ),
+              m(
+                TableHead,
+                { style: "width: 40%;" },
+                t("httpRequestViewer.url"),
+              ),
+              m(
+                TableHead,
+                { style: "width: 10%;" },
+                t("httpRequestViewer.status"),
+              ),
+              m(
+                TableHead,
+                { style: "width: 15%;" },
+                t("httpRequestViewer.duration"),
+              ),
+              m(
+                TableHead,
+                { style: "width: 15%;" },
+                t("httpRequestViewer.time"),
+              ),
+            ]),
+          ]),
+          m(
+            TableBody,
+            requests.map((req) => this.renderRequestRows(req)),
+          ),
+        ]),
+      ],
+    );
+  },
+
+  /**
+   * Renders the rows for a single HTTP request (main row + optional expanded row).
+   * @param {HTTPRequest} req - The HTTP request
+   * @returns {Object} Mithril fragment with keyed children
+   */
+  renderRequestRows(req) {
+    const isExpanded = this.expandedRows.has(req.id);
+
+    const rows = [
+      // Main row
+      m(
+        TableRow,
+        {
+          key: req.id,
+          class: "http-request-viewer__row" +
+            (isExpanded ? " http-request-viewer__row--expanded" : ""),
+          onclick: () => this.toggleRow(req.id),
+          style: "cursor: pointer;",
+        },
+        [
+          // Chevron
+          m(TableCell, { style: "width: 2rem; padding-right: 0;" }, [
+            m(
+              ".http-request-viewer__chevron",
+              {
+                class: isExpanded
+                  ? "http-request-viewer__chevron--expanded"
+                  : "",
+              },
+              m.trust(icons.chevronRight()),
+            ),
+          ]),
+
+          // Method
+          m(TableCell, [
+            m(
+              Badge,
+              {
+                variant: BadgeVariant.SECONDARY,
+                size: BadgeSize.SM,
+              },
+              req.method,
+            ),
+          ]),
+
+          // URL
+          m(
+            TableCell,
+            {
+              mono: true,
+              style: "overflow: hidden; text-overflow: ellipsis;",
+              title: req.url,
+            },
+            req.url,
+          ),
+
+          // Status
+          m(TableCell, [
+            m(
+              Badge,
+              {
+                variant: req.status === "success"
+                  ? BadgeVariant.SUCCESS
+                  : BadgeVariant.DESTRUCTIVE,
+                size: BadgeSize.SM,
+              },
+              req.status_code ?? t(`common.status.${req.status}`),
+            ),
+          ]),
+
+          // Duration
+          m(TableCell, { mono: true }, `${req.duration_ms}ms`),
+
+          // Time
+          m(TableCell, formatUnixTimestamp(req.created_at, "time")),
+        ],
+      ),
+    ];
+
+    // Add expanded content row if expanded
+    if (isExpanded) {
+      const panels = [
+        this.renderPanel(
+          t("httpRequestViewer.request"),
+          req.request_headers,
+          req.request_body,
+        ),
+      ];
+      if (req.status_code !== undefined && req.status_code !== null) {
+        panels.push(
+          this.renderPanel(
+            t("httpRequestViewer.response"),
+            req.response_headers,
+            req.response_body,
+          ),
+        );
+      }
+
+      rows.push(
+        m(
+          "tr.http-request-viewer__expanded-row",
+          { key: req.id + "-expanded" },
+          [
+            m("td", { colspan: 6 }, [
+              m(".http-request-viewer__content", [
+                // Error message if present
+                req.error_message
+                  ? m(".http-request-viewer__error", [
+                    m("strong", t("httpRequestViewer.error") + ": "),
+                    req.error_message,
+                  ])
+                  : null,
+
+                // Request details
+                m(".http-request-viewer__details", [
+                  m("div", [
+                    m("strong", t("httpRequestViewer.url") + ": "),
+                    m("code", `${req.method} ${req.url}`),
+                  ]),
+                ]),
+
+                // Request/Response panels
+                m(
+                  ".http-request-viewer__panels",
+                  {
+                    class: panels.length === 1
+                      ? "http-request-viewer__panels--single"
+                      : "",
+                  },
+                  panels,
+                ),
+              ]),
+            ]),
+          ],
+        ),
+      );
+    }
+
+    return m.fragment({ key: req.id }, rows);
+  },
+
+  /**
+   * Renders the headers and body of a request or response.
+   * @param {string} title - Panel title
+   * @param {Object.<string, string>} [headers] - Headers map
+   * @param {string} [body] - Body string
+   * @returns {Object} Mithril vnode
+   */
+  renderPanel(title, headers, body) {
+    const { formatted, language } = this.formatBody(body);
+
+    return m(".http-request-viewer__panel", [
+      m(CodeViewer, {
+        code: this.formatHeaders(headers) || t("httpRequestViewer.noHeaders"),
+        language: "",
+        title: `${title} ${t("httpRequestViewer.headers")}`,
+        maxHeight: "120px",
+        padded: true,
+      }),
+      m(CodeViewer, {
+        code: formatted || t("httpRequestViewer.noBody"),
+        language,
+        title: `${title} ${t("httpRequestViewer.body")}`,
+        maxHeight: "200px",
+        padded: true,
+      }),
+    ]);
+  },
+};
@@ -24,6 +24,7 @@ export default {
       success: "SUCCESS",
       error: "ERROR",
       timeout: "TIMEOUT",
+      skipped: "SKIPPED",
     },
   },
 
@@ -158,9 +159,24 @@ export default {
       everyWeek: "Every Sunday at midnight",
     },
     nextRun: "Next scheduled run:",
+    aiUsage: "AI Usage",
+    aiUsageDescription: "Tokens and estimated cost over the last {{window}}",
+    aiUsageModel: "Model",
+    aiUsageRequests: "Requests",
+    aiUsageInputTokens: "Input tokens",
+    aiUsageOutputTokens: "Output tokens",
+    aiUsageCost: "Estimated cost",
+    aiUsageTotal: "Total",
+    aiUsageEmpty: "No AI requests in this period",
     saveResponse: "Save Response",
     saveResponseDescription:
       "Store HTTP responses with executions for debugging.",
+    captureHTTP: "Capture HTTP Requests",
+    captureHTTPDescription:
+      "Record outbound HTTP requests and responses with executions for debugging.",
+    reuseState: "Reuse Lua State",
+    reuseStateDescription:
+      "Keep the Lua state warm between executions so init runs once and the app table persists.",
   },
 
   // Executions
@@ -179,6 +195,8 @@ export default {
     triggers: {
       http: "HTTP",
       cron: "Cron",
+      manual: "Manual",
+      test: "Test",
     },
   },
 
@@ -313,6 +331,20 @@ export default {
     aiRequestsCount: "{{count}} API calls",
     emailRequests: "Email Requests",
     emailsSent: "{{count}} emails sent",
+    httpRequests: "HTTP Requests",
+    httpRequestsCount: "{{count}} outbound requests",
+    externalCalls: "External Calls",
+    externalCallsCount: "{{count}} outbound calls",
+    callKind: "Type",
+    callTarget: "Target",
+    callStatus: "Status",
+    callDuration: "Duration",
+    callTime: "Time",
+    callKinds: {
+      ai: "AI",
+      email: "Email",
+      http: "HTTP",
+    },
     executionLogs: "Execution Logs",
     logEntries: "{{count}} log entries",
     showPreview: "Show Preview",
@@ -355,6 +387,10 @@ export default {
     versionDeleted: "Version {{version}} deleted",
     failedToDelete: "Failed to delete version",
     delete: "Delete",
+    label: "Label",
+    labelPrompt: "Label for version {{version}} (leave empty to remove)",
+    labelUpdated: "Version {{version}} label updated",
+    failedToUpdateLabel: "Failed to update version label",
   },
 
   // AI Request viewer
@@ -412,6 +448,24 @@ export default {
     truncated: "... (truncated)",
   },
 
+  // HTTP request viewer
+  httpRequestViewer: {
+    noRequests: "No HTTP requests recorded for this execution.",
+    method: "Method",
+    url: "URL",
+    status: "Status",
+    duration: "Duration",
+    time: "Time",
+    error: "Error",
+    request: "Request",
+    response: "Response",
+    headers: "Headers",
+    body: "Body",
+    noHeaders: "(no headers)",
+    noBody: "(empty body)",
+    truncated: "... (truncated)",
+  },
+
   // Form
   form: {
     showPassword: "Show password",
@@ -450,7 +504,28 @@ export default {
       name: "Email",
       description: "Email sending via Resend",
       groups: { send: "Send (email)" },
-      items: { send: "Send email via Resend API" },
+      items: {
+        send: "Send email via Resend API",
+        sendTemplate: "Render a stored email template with data and send it",
+      },
+    },
+    execution: {
+      name: "Execution",
+      description: "Current execution metadata",
+      groups: { tags: "Tags (execution)", spans: "Spans (trace)" },
+      items: {
+        tag: "Tag the execution to filter executions by it",
+        span: "Start a named timing span; finish() ends it and returns its duration in ms",
+      },
+    },
+    respond: {
+      name: "Respond",
+      description: "Response helpers",
+      groups: { files: "Files (respond)", redirects: "Redirects (respond)" },
+      items: {
+        file: "Serve bytes as a file download",
+        redirect: "Redirect the client to another URL (default 302)",
+      },
     },
     handler: {
       name: "Handler",
@@ -458,6 +533,7 @@ export default {
       groups: {
         context: "Context (ctx)",
         event: "Event (event)",
+        lifecycle: "Lifecycle Hooks",
       },
       items: {
         executionId: "Unique execution identifier",
@@ -472,7 +548,15 @@ export default {
         body: "Request body as string",
         headers: "Request headers table",
         query: "Query parameters table",
+        headersAll: "Every value of each header, as arrays",
+        queryAll: "Every value of each query parameter, as arrays",
+        remoteIp: "Client IP address, behind any trusted proxies",
+        scheme: "Scheme the client used (http or https)",
         relativePath: "Path without /fn/:id prefix",
+        basicAuth: "Username, password and ok from a Basic Authorization header",
+        init: "Optional, runs once per Lua state; its result is the handler's third argument",
+        cleanup: "Optional, runs when the Lua state is discarded",
+        app: "Values kept across executions while the state is warm (reuse_state)",
       },
     },
     router: {
@@ -24,6 +24,7 @@ export default {
       success: "SUCESSO",
       error: "ERRO",
       timeout: "TIMEOUT",
+      skipped: "PULADO",
     },
   },
 
@@ -160,9 +161,24 @@ export default {
       everyWeek: "Todo domingo à meia-noite",
     },
     nextRun: "Próxima execução agendada:",
+    aiUsage: "Uso de IA",
+    aiUsageDescription: "Tokens e custo estimado nos últimos {{window}}",
+    aiUsageModel: "Modelo",
+    aiUsageRequests: "Requisições",
+    aiUsageInputTokens: "Tokens de entrada",
+    aiUsageOutputTokens: "Tokens de saída",
+    aiUsageCost: "Custo estimado",
+    aiUsageTotal: "Total",
+    aiUsageEmpty: "Nenhuma requisição de IA neste período",
     saveResponse: "Salvar Resposta",
     saveResponseDescription:
       "Armazena respostas HTTP com as execuções para depuração.",
+    captureHTTP: "Capturar Requisições HTTP",
+    captureHTTPDescription:
+      "Registrar requisições HTTP enviadas e suas respostas nas execuções para depuração.",
+    reuseState: "Reutilizar Estado Lua",
+    reuseStateDescription:
+      "Manter o estado Lua ativo entre execuções para que o init rode uma vez e a tabela app persista.",
   },
 
   // Executions
@@ -181,6 +197,8 @@ export default {
     triggers: {
       http: "HTTP",
       cron: "Cron",
+      manual: "Manual",
+      test: "Teste",
     },
   },
 
@@ -315,6 +333,20 @@ export default {
     aiRequestsCount: "{{count}} chamadas de API",
     emailRequests: "Requisições de Email",
     emailsSent: "{{count}} emails enviados",
+    httpRequests: "Requisições HTTP",
+    httpRequestsCount: "{{count}} requisições enviadas",
+    externalCalls: "Chamadas Externas",
+    externalCallsCount: "{{count}} chamadas enviadas",
+    callKind: "Tipo",
+    callTarget: "Destino",
+    callStatus: "Status",
+    callDuration: "Duração",
+    callTime: "Hora",
+    callKinds: {
+      ai: "IA",
+      email: "Email",
+      http: "HTTP",
+    },
     executionLogs: "Logs de Execução",
     logEntries: "{{count}} entradas de log",
     showPreview: "Mostrar Visualização",
@@ -358,6 +390,10 @@ export default {
     versionDeleted: "Versão {{version}} excluída",
     failedToDelete: "Falha ao excluir versão",
     delete: "Excluir",
+    label: "Rótulo",
+    labelPrompt: "Rótulo da versão {{version}} (deixe vazio para remover)",
+    labelUpdated: "Rótulo da versão {{version}} atualizado",
+    failedToUpdateLabel: "Falha ao atualizar rótulo da versão",
   },
 
   // AI Request viewer
@@ -415,6 +451,24 @@ export default {
     truncated: "... (truncado)",
   },
 
+  // HTTP request viewer
+  httpRequestViewer: {
+    noRequests: "Nenhuma requisição HTTP registrada para esta execução.",
+    method: "Método",
+    url: "URL",
+    status: "Status",
+    duration: "Duração",
+    time: "Hora",
+    error: "Erro",
+    request: "Requisição",
+    response: "Resposta",
+    headers: "Cabeçalhos",
+    body: "Corpo",
+    noHeaders: "(sem cabeçalhos)",
+    noBody: "(corpo vazio)",
+    truncated: "... (truncado)",
+  },
+
   // Form
   form: {
     showPassword: "Mostrar senha",
@@ -453,7 +507,31 @@ export default {
       name: "Email",
       description: "Envio de email via Resend",
       groups: { send: "Enviar (email)" },
-      items: { send: "Enviar email via API Resend" },
+      items: {
+        send: "Enviar email via API Resend",
+        sendTemplate: "Renderizar um template de email salvo com dados e enviá-lo",
+      },
+    },
+    execution: {
+      name: "Execução",
+      description: "Metadados da execução atual",
+      groups: { tags: "Tags (execution)", spans: "Spans (trace)" },
+      items: {
+        tag: "Marcar a execução para filtrar execuções por ela",
+        span: "Iniciar um span de tempo nomeado; finish() o encerra e retorna sua duração em ms",
+      },
+    },
+    respond: {
+      name: "Respostas",
+      description: "Auxiliares de resposta",
+      groups: {
+        files: "Arquivos (respond)",
+        redirects: "Redirecionamentos (respond)",
+      },
+      items: {
+        file: "Servir bytes como download de arquivo",
+        redirect: "Redirecionar o cliente para outra URL (padrão 302)",
+      },
     },
     handler: {
       name: "Handler",
@@ -461,6 +539,7 @@ export default {
       groups: {
         context: "Contexto (ctx)",
         event: "Evento (event)",
+        lifecycle: "Hooks de Ciclo de Vida",
       },
       items: {
         executionId: "Identificador único da execução",
@@ -475,7 +554,15 @@ export default {
         body: "Corpo da requisição como string",
         headers: "Tabela de cabeçalhos da requisição",
         query: "Tabela de parâmetros de query",
+        headersAll: "Todos os valores de cada cabeçalho, em arrays",
+        queryAll: "Todos os valores de cada parâmetro de query, em arrays",
+        remoteIp: "Endereço IP do cliente, atrás de proxies confiáveis",
+        scheme: "Esquema usado pelo cliente (http ou https)",
         relativePath: "Caminho sem prefixo /fn/:id",
+        basicAuth: "Usuário, senha e ok de um cabeçalho Authorization Basic",
+        init: "Opcional, executa uma vez por estado Lua; o resultado é o terceiro argumento do handler",
+        cleanup: "Opcional, executa quando o estado Lua é descartado",
+        app: "Valores mantidos entre execuções enquanto o estado está ativo (reuse_state)",
       },
     },
     router: {
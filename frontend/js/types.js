@@ -21,6 +21,7 @@
  * @property {number} version - Version number
  * @property {string} code - Function source code
  * @property {string} created_at - ISO timestamp
+ * @property {string} [label] - Optional human readable label
  */
 
 /**
@@ -33,11 +34,50 @@
  * @property {Object.<string, string>} [env_vars] - Environment variables
  * @property {string} [cron_schedule] - Cron expression for scheduled execution
  * @property {string} [cron_status] - Cron status ('active' or 'paused')
+ * @property {number} [cron_jitter] - Maximum random delay in seconds before cron executions
+ * @property {boolean} cron_no_overlap - Whether cron executions are skipped while a previous execution runs
+ * @property {string} [cron_misfire_policy] - Missed cron fires on startup ('skip' or 'run_once')
+ * @property {number} [cron_last_run_at] - Scheduled Unix time of the last attempted cron execution
+ * @property {number} [cron_last_success_at] - Scheduled Unix time of the last successful cron execution
  * @property {boolean} save_response - Whether to save HTTP responses for debugging
+ * @property {boolean} capture_http - Whether outbound HTTP requests are captured for debugging
+ * @property {boolean} reuse_state - Whether the Lua state is kept warm between executions
+ * @property {number} [max_log_entries] - Log entries an execution may write (server limit when omitted)
+ * @property {number} [max_outbound_calls] - Outbound http, ai and email calls an execution may make (server limit when omitted)
+ * @property {number} [outbound_time_budget] - Seconds an execution may spend in outbound calls (server budget when omitted)
+ * @property {number} [cache_ttl] - Seconds successful responses are cached for (no cache when omitted)
+ * @property {string[]} [cache_vary_headers] - Request headers that are part of the cache key
+ * @property {string[]} [allowed_methods] - HTTP methods accepted by the function (all when omitted)
+ * @property {number} [max_versions] - Maximum number of retained versions (unlimited when omitted)
+ * @property {Object.<string, string>} [default_headers] - Headers added to every function response
+ * @property {string} [route_prefix] - Path prefix routed to the function (e.g. /app/foo)
+ * @property {boolean} websocket_enabled - Whether WebSocket upgrade requests are handed to the function
+ * @property {number} [ai_budget_tokens] - Monthly AI token budget
+ * @property {number} [ai_budget_usd] - Monthly estimated AI cost budget in USD
+ * @property {boolean} ai_budget_override - Whether the function is exempt from AI budgets
+ * @property {string} [ai_default_provider] - AI provider used when ai calls leave it out
+ * @property {string} [ai_default_model] - AI model used when ai calls leave it out
+ * @property {string} [email_default_from] - Sender used when email.send leaves it out
+ * @property {string[]} [email_allowed_from] - Addresses and domains email.send may use as sender (any when omitted)
+ * @property {string[]} [disabled_modules] - Stdlib modules (kv, env, http, ai, email) the function may not use
+ * @property {string[]} [middleware] - Modules whose hooks run around the handler, in order
+ * @property {Object} [input_schema] - JSON Schema request bodies must match before reaching the handler
+ * @property {Object} [output_schema] - JSON Schema responses are checked against in dev mode and tests
+ * @property {string} [signing_secret] - Secret required to sign requests to /fn/{id}
+ * @property {ExecutionSummary[]} [recent_executions] - Latest executions, newest first (only with include=recent_executions)
  * @property {string} created_at - ISO timestamp
  * @property {string} updated_at - ISO timestamp
  */
 
+/**
+ * @typedef {Object} ExecutionSummary
+ * @property {string} id - Execution ID
+ * @property {string} status - Execution status
+ * @property {string} trigger - How the execution was triggered
+ * @property {number} [duration_ms] - Execution duration in milliseconds
+ * @property {number} created_at - Unix timestamp
+ */
+
 /**
  * @typedef {Object} FunctionsListResponse
  * @property {LunarFunction[]} functions - List of functions
@@ -56,10 +96,16 @@
  * @property {string} function_id - Function ID
  * @property {string} version_id - Version ID that was executed
  * @property {number} version - Version number
- * @property {string} status - Execution status (success, error, timeout)
+ * @property {string} status - Execution status (success, error, timeout, skipped)
  * @property {number} duration_ms - Execution duration in milliseconds
  * @property {number} [status_code] - HTTP status code returned
- * @property {string} trigger - Execution trigger ('http' or 'cron')
+ * @property {string} trigger - Execution trigger ('http', 'cron', 'manual' or 'test')
+ * @property {Object<string, string>} [tags] - Tags set by the function with execution.tag
+ * @property {Span[]} [spans] - Timings recorded with trace.span (only on a single execution)
+ * @property {number} [scheduled_at] - Unix timestamp a cron execution was scheduled for
+ * @property {number} [request_bytes] - Size of the request body in bytes
+ * @property {number} [response_bytes] - Size of the response body in bytes
+ * @property {number} [cost_usd] - Estimated cost in USD, when costs are tracked
  * @property {string} [event_json] - Input event data as JSON string
  * @property {string} [response_json] - HTTP response data as JSON string (if save_response enabled)
  * @property {string} created_at - ISO timestamp
@@ -71,6 +117,18 @@
  * @property {Pagination} pagination - Pagination info
  */
 
+/**
+ * @typedef {Execution & {function_name: string}} FunctionExecution
+ */
+
+/**
+ * @typedef {Object} RecentErrorsResponse
+ * @property {string} window - Covered time window (e.g. "1h")
+ * @property {number} since - Start of the window as a Unix timestamp
+ * @property {FunctionExecution[]} errors - Failed executions, newest first
+ * @property {Pagination} pagination - Pagination info
+ */
+
 /**
  * @typedef {Object} ExecutionLog
  * @property {string} id - Log entry ID
@@ -133,6 +191,82 @@
  * @property {Pagination} pagination - Pagination info
  */
 
+/**
+ * @typedef {Object} HTTPRequest
+ * @property {string} id - HTTP request ID
+ * @property {string} execution_id - Parent execution ID
+ * @property {string} method - HTTP method
+ * @property {string} url - Request URL (sensitive query parameters masked)
+ * @property {Object.<string, string>} [request_headers] - Request headers (sensitive values masked)
+ * @property {string} [request_body] - Request body
+ * @property {number} [status_code] - Response status code
+ * @property {Object.<string, string>} [response_headers] - Response headers (sensitive values masked)
+ * @property {string} [response_body] - Response body
+ * @property {string} status - Status (success, error)
+ * @property {string} [error_message] - Error message if failed
+ * @property {number} duration_ms - Duration in milliseconds
+ * @property {number} created_at - Unix timestamp
+ */
+
+/**
+ * @typedef {Object} HTTPRequestsResponse
+ * @property {HTTPRequest[]} http_requests - List of HTTP requests
+ * @property {Pagination} pagination - Pagination info
+ */
+
+/**
+ * @typedef {Object} ExternalCall
+ * @property {string} id - ID of the underlying AI, email or HTTP request
+ * @property {string} execution_id - Parent execution ID
+ * @property {string} kind - Kind of call (ai, email, http)
+ * @property {string} target - What was called (provider/model, recipients or method and URL)
+ * @property {string} status - Status (success, error)
+ * @property {string} [error_message] - Error message if failed
+ * @property {number} duration_ms - Duration in milliseconds
+ * @property {number} created_at - Unix timestamp
+ * @property {number} [created_at_ns] - Unix timestamp in nanoseconds
+ */
+
+/**
+ * @typedef {Object} ExternalCallsResponse
+ * @property {ExternalCall[]} external_calls - List of external calls
+ * @property {Pagination} pagination - Pagination info
+ */
+
+/**
+ * @typedef {Object} ExecutionTiming
+ * @property {number} [duration_ms] - Total duration (absent while running)
+ * @property {number} external_ms - Time spent in outbound calls
+ * @property {number} [function_ms] - Duration not spent in outbound calls
+ * @property {number} [delay_seconds] - Time a cron execution started after it was scheduled
+ * @property {Span[]} [spans] - Spans recorded with trace.span, in start order
+ */
+
+/**
+ * @typedef {Object} Span
+ * @property {string} name - Span name
+ * @property {number} start_ms - Start, in milliseconds since the function began running
+ * @property {number} duration_ms - Duration in milliseconds
+ * @property {number} depth - Number of spans open when it started
+ * @property {boolean} [unfinished] - The function never finished it; it lasts until the run ended
+ */
+
+/**
+ * @typedef {Object} ExplainExecutionResponse
+ * @property {Execution} execution - The record, without event_json and response_json
+ * @property {Object} [event] - The stored event, with sensitive data masked
+ * @property {Object} [response] - The saved response, if the function saves responses
+ * @property {FunctionVersion} [version] - The version that ran (absent when deleted)
+ * @property {ExecutionTiming} timing - Duration breakdown
+ * @property {ExternalCall[]} external_calls - Outbound calls in chronological order
+ */
+
+/**
+ * @typedef {Object} CurlCommandResponse
+ * @property {string} command - Shell command reproducing the request
+ * @property {string[]} [redacted] - Masked values to fill in, as header:Name, query:name or body
+ */
+
 /**
  * @typedef {Object} DiffResponse
  * @property {string} diff - Unified diff string
@@ -140,6 +274,19 @@
  * @property {FunctionVersion} version2 - Second version
  */
 
+/**
+ * @typedef {Object} DiffSummaryResponse
+ * @property {number} old_version - First version number
+ * @property {number} new_version - Second version number
+ * @property {number} lines_added - Lines added
+ * @property {number} lines_removed - Lines removed
+ * @property {number} lines_unchanged - Lines unchanged
+ * @property {number} hunks - Contiguous blocks of changed lines
+ * @property {boolean} handler_signature_changed - Whether the handler's parameters changed
+ * @property {string} [old_handler_signature] - Removed handler definition
+ * @property {string} [new_handler_signature] - Added handler definition
+ */
+
 // ============================================================================
 // Component Prop Types
 // ============================================================================
@@ -184,7 +331,62 @@
  * @property {boolean} [disabled] - Enable/disable function
  * @property {string} [cron_schedule] - Cron expression for scheduled execution
  * @property {string} [cron_status] - Cron status ('active' or 'paused')
+ * @property {number} [cron_jitter] - Maximum random delay in seconds before cron executions (0 to clear)
+ * @property {boolean} [cron_no_overlap] - Skip cron executions while a previous execution runs
+ * @property {string} [cron_misfire_policy] - Missed cron fires on startup ('skip' or 'run_once', empty to clear)
  * @property {boolean} [save_response] - Enable/disable response saving for debugging
+ * @property {boolean} [capture_http] - Enable/disable outbound HTTP request capture
+ * @property {boolean} [reuse_state] - Enable/disable keeping the Lua state warm between executions
+ * @property {number} [max_log_entries] - Log entries an execution may write (0 for the server limit)
+ * @property {number} [max_outbound_calls] - Outbound http, ai and email calls an execution may make (0 for the server limit)
+ * @property {number} [outbound_time_budget] - Seconds an execution may spend in outbound calls (0 for the server budget)
+ * @property {number} [cache_ttl] - Seconds successful responses are cached for (0 to disable)
+ * @property {string[]} [cache_vary_headers] - Request headers that are part of the cache key (empty for none)
+ * @property {string[]} [allowed_methods] - HTTP methods accepted by the function (empty for all)
+ * @property {number} [max_versions] - Maximum number of retained versions (0 for unlimited)
+ * @property {Object.<string, string>} [default_headers] - Headers added to every function response (empty to clear)
+ * @property {string} [route_prefix] - Path prefix routed to the function (empty to clear)
+ * @property {boolean} [websocket_enabled] - Enable/disable WebSocket connections
+ * @property {number} [ai_budget_tokens] - Monthly AI token budget (0 to clear)
+ * @property {number} [ai_budget_usd] - Monthly estimated AI cost budget in USD (0 to clear)
+ * @property {boolean} [ai_budget_override] - Exempt the function from AI budgets
+ * @property {string} [ai_default_provider] - Default AI provider (empty to clear)
+ * @property {string} [ai_default_model] - Default AI model (empty to clear)
+ * @property {string} [email_default_from] - Default email sender (empty to clear)
+ * @property {string[]} [email_allowed_from] - Allowed email senders (empty for any)
+ * @property {string[]} [disabled_modules] - Disabled stdlib modules (empty enables all)
+ * @property {string[]} [middleware] - Modules run around the handler, in order (empty removes all)
+ * @property {Object} [input_schema] - JSON Schema for request bodies ({} removes it)
+ * @property {Object} [output_schema] - JSON Schema for response bodies ({} removes it)
+ * @property {string} [signing_secret] - Request signing secret (empty to turn signing off)
+ */
+
+/**
+ * @typedef {Object} EmailTemplate
+ * @property {string} name - Template name, used by email.send_template
+ * @property {string} [subject] - Subject template (text/template syntax)
+ * @property {string} [html] - HTML body template (html/template syntax)
+ * @property {string} [text] - Plain text body template (text/template syntax)
+ * @property {number} created_at - Unix timestamp
+ * @property {number} updated_at - Unix timestamp
+ */
+
+/**
+ * @typedef {Object} EmailTemplatesResponse
+ * @property {EmailTemplate[]} templates - Templates sorted by name
+ */
+
+/**
+ * @typedef {Object} Module
+ * @property {string} name - Module name, passed to require
+ * @property {string} code - Lua code returning the module value
+ * @property {number} created_at - Unix timestamp
+ * @property {number} updated_at - Unix timestamp
+ */
+
+/**
+ * @typedef {Object} ModulesResponse
+ * @property {Module[]} modules - Modules sorted by name
  */
 
 /**
@@ -197,6 +399,55 @@
  * @property {string} [next_run_human] - Human-friendly next run time
  */
 
+/**
+ * @typedef {Object} AIUsage
+ * @property {string} provider - AI provider name
+ * @property {string} model - Model name
+ * @property {number} requests - Number of requests
+ * @property {number} input_tokens - Input tokens used
+ * @property {number} output_tokens - Output tokens generated
+ * @property {number} [estimated_cost_usd] - Estimated cost in USD (omitted when the model has no known price)
+ */
+
+/**
+ * @typedef {Object} AIUsageResponse
+ * @property {string} function_id - Function ID
+ * @property {string} window - Aggregated time window (e.g. "30d")
+ * @property {number} since - Start of the window as a Unix timestamp
+ * @property {AIUsage[]} usage - Usage per provider and model
+ * @property {number} total_requests - Total number of requests
+ * @property {number} total_input_tokens - Total input tokens
+ * @property {number} total_output_tokens - Total output tokens
+ * @property {number} estimated_cost_usd - Estimated cost in USD of priced models
+ * @property {string[]} [unpriced_models] - Models without a known price
+ */
+
+/**
+ * @typedef {Object} ExecutionBucket
+ * @property {number} start - Unix timestamp the bucket begins at
+ * @property {number} count - Executions created in the bucket
+ * @property {number} errors - Failed executions
+ * @property {number|null} avg_duration_ms - Average duration (null when none finished)
+ * @property {number|null} p50_duration_ms - Median duration
+ * @property {number|null} p95_duration_ms - 95th percentile duration
+ * @property {number|null} p99_duration_ms - 99th percentile duration
+ * @property {number} request_bytes - Total request body size in bytes
+ * @property {number} response_bytes - Total response body size in bytes
+ * @property {number} max_request_bytes - Largest request body in bytes
+ * @property {number} max_response_bytes - Largest response body in bytes
+ * @property {number} cost_usd - Total estimated cost in USD
+ */
+
+/**
+ * @typedef {Object} MetricsTimeseriesResponse
+ * @property {string} function_id - Function ID
+ * @property {string} window - Covered time window (e.g. "24h")
+ * @property {string} bucket - Bucket size (e.g. "1h")
+ * @property {number} since - Start of the first bucket as a Unix timestamp
+ * @property {number} until - End of the last bucket as a Unix timestamp
+ * @property {ExecutionBucket[]} buckets - One bucket per interval, empty ones included
+ */
+
 /**
  * @typedef {Object} ExecuteRequest
  * @property {string} [method] - HTTP method (GET, POST, etc.)
@@ -217,6 +468,99 @@
  * @property {string} headers.X-Execution-Duration-Ms - Duration in ms
  */
 
+/**
+ * @typedef {Object} ServerConfig
+ * @property {number} execution_timeout_seconds - Maximum execution time
+ * @property {number} max_code_length - Maximum function code size in bytes
+ * @property {number} max_function_name_length - Maximum function name length
+ * @property {number} max_description_length - Maximum description length
+ * @property {number} max_env_vars - Maximum environment variables per function
+ * @property {number} max_page_size - Maximum page size of paginated lists
+ * @property {number} max_batch_operations - Maximum operations per batch request
+ * @property {number} max_log_entries - Log entries an execution may write by default
+ * @property {number[]} allowed_retention_days - Accepted retention_days values
+ * @property {{http: boolean, ai: boolean, email: boolean}} integrations - Enabled outbound integrations
+ */
+
+/**
+ * @typedef {Object} ProviderEnvKey
+ * @property {string} name - Environment variable name, e.g. OPENAI_API_KEY
+ * @property {boolean} required - Whether the provider cannot be used without it
+ * @property {boolean} url - Whether the value must be an absolute http or https URL
+ * @property {string} description - What the variable configures
+ */
+
+/**
+ * @typedef {Object} Provider
+ * @property {string} name - Provider name (openai, anthropic, resend)
+ * @property {'http'|'ai'|'email'} integration - Outbound integration it belongs to
+ * @property {ProviderEnvKey[]} env_keys - Environment variables it reads
+ */
+
+/**
+ * @typedef {Object} ProviderStatus
+ * @property {string} name - Provider name (openai, anthropic, resend)
+ * @property {'http'|'ai'|'email'} integration - Outbound integration it belongs to
+ * @property {boolean} enabled - Whether the integration is switched on
+ * @property {boolean} ready - Whether every required environment variable is set
+ * @property {Object<string, boolean>} env_keys - Whether each environment variable is set
+ */
+
+/**
+ * @typedef {Object} LintFinding
+ * @property {number} line - 1-based line of the finding
+ * @property {'error'|'warning'} severity - Errors prevent the code from running
+ * @property {'syntax'|'missing-handler'|'global-assignment'|'undefined-global'|'unused-local'} rule - Rule that found it
+ * @property {string} message - Description of the problem
+ */
+
+/**
+ * @typedef {Object} TestFunctionRequest
+ * @property {string} [method] - HTTP method (defaults to GET)
+ * @property {string} [path] - Path relative to the function's URL (defaults to /)
+ * @property {Object<string, string>} [query] - Query parameters
+ * @property {Object<string, string>} [headers] - Request headers
+ * @property {string} [body] - Request body
+ * @property {HTTPFixture[]} [http_fixtures] - Canned responses for outbound HTTP requests
+ * @property {number} [seed] - Makes the random module deterministic
+ * @property {number} [frozen_time] - Unix time returned by time.now
+ */
+
+/**
+ * @typedef {Object} HTTPFixture
+ * @property {string} [method] - HTTP method to match (defaults to GET)
+ * @property {string} url - URL to match exactly
+ * @property {number} [status_code] - Response status code (defaults to 200)
+ * @property {Object<string, string>} [headers] - Response headers
+ * @property {string} [body] - Response body
+ */
+
+/**
+ * @typedef {Object} TestSideEffects
+ * @property {Array<{method: string, url: string, headers?: Object<string, string>, query?: Object<string, string>, body?: string}>} http - Outbound HTTP requests
+ * @property {Array<{provider: string, model: string, messages: Array<{role: string, content: string}>}>} ai - AI chats
+ * @property {Array<{from: string, to: string[], cc?: string[], bcc?: string[], subject: string, text?: string, html?: string}>} emails - Emails
+ */
+
+/**
+ * @typedef {Object} TestFunctionResponse
+ * @property {string} execution_id - Execution ID
+ * @property {'success'|'error'} status - Execution status
+ * @property {number} duration_ms - Execution duration in milliseconds
+ * @property {string} [error] - Why the execution failed
+ * @property {{statusCode: number, headers: Object<string, string>, body: string, isBase64Encoded: boolean}} [response] - The function's HTTP response
+ * @property {Object<string, string>} [output_errors] - Where the response does not match the output schema, by location
+ * @property {TestSideEffects} side_effects - Outbound calls the function made
+ */
+
+/**
+ * @typedef {Object} SelfTestResponse
+ * @property {boolean} passed - Whether the function returned a valid response
+ * @property {number} [status_code] - Status code the function returned
+ * @property {number} duration_ms - Run duration in milliseconds
+ * @property {string} [error] - Why the self-test failed
+ */
+
 // ============================================================================
 // Icon Types
 // ============================================================================
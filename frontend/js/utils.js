@@ -76,3 +76,23 @@ export const formatUnixTimestamp = (timestamp, format = "datetime") => {
       return date.toLocaleString(locale);
   }
 };
+
+/**
+ * Formats a byte count with a binary unit (B, KB, MB, GB).
+ * @param {number} bytes - Number of bytes
+ * @returns {string} Formatted size
+ * @example
+ * formatBytes(512);     // "512 B"
+ * formatBytes(1536);    // "1.5 KB"
+ * formatBytes(1048576); // "1.0 MB"
+ */
+export const formatBytes = (bytes) => {
+  const units = ["B", "KB", "MB", "GB"];
+  let value = bytes;
+  let unit = 0;
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024;
+    unit++;
+  }
+  return unit === 0 ? `${value} ${units[0]}` : `${value.toFixed(1)} ${units[unit]}`;
+};
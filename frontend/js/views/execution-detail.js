@@ -5,7 +5,7 @@
 import { icons } from "../icons.js";
 import { API } from "../api.js";
 import { Pagination } from "../components/pagination.js";
-import { formatUnixTimestamp } from "../utils.js";
+import { formatBytes, formatUnixTimestamp } from "../utils.js";
 import { routes } from "../routes.js";
 import { BackButton } from "../components/button.js";
 import {
@@ -26,6 +26,15 @@ import { CodeViewer } from "../components/code-viewer.js";
 import { HtmlPreview } from "../components/html-preview.js";
 import { AIRequestViewer } from "../components/ai-request-viewer.js";
 import { EmailRequestViewer } from "../components/email-request-viewer.js";
+import { HTTPRequestViewer } from "../components/http-request-viewer.js";
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from "../components/table.js";
 import { t } from "../i18n/index.js";
 
 /**
@@ -34,6 +43,8 @@ import { t } from "../i18n/index.js";
  * @typedef {import('../types.js').ExecutionLog} ExecutionLog
  * @typedef {import('../types.js').AIRequest} AIRequest
  * @typedef {import('../types.js').EmailRequest} EmailRequest
+ * @typedef {import('../types.js').HTTPRequest} HTTPRequest
+ * @typedef {import('../types.js').ExternalCall} ExternalCall
  */
 
 /**
@@ -132,6 +143,54 @@ export const ExecutionDetail = {
    */
   emailRequestsTotal: 0,
 
+  /**
+   * HTTP requests for this execution.
+   * @type {HTTPRequest[]}
+   */
+  httpRequests: [],
+
+  /**
+   * Number of HTTP requests per page.
+   * @type {number}
+   */
+  httpRequestsLimit: 20,
+
+  /**
+   * Current HTTP requests pagination offset.
+   * @type {number}
+   */
+  httpRequestsOffset: 0,
+
+  /**
+   * Total number of HTTP requests.
+   * @type {number}
+   */
+  httpRequestsTotal: 0,
+
+  /**
+   * External calls (AI, email and HTTP) for this execution in chronological order.
+   * @type {ExternalCall[]}
+   */
+  externalCalls: [],
+
+  /**
+   * Number of external calls per page.
+   * @type {number}
+   */
+  externalCallsLimit: 20,
+
+  /**
+   * Current external calls pagination offset.
+   * @type {number}
+   */
+  externalCallsOffset: 0,
+
+  /**
+   * Total number of external calls.
+   * @type {number}
+   */
+  externalCallsTotal: 0,
+
   /**
    * Initializes the view and loads execution data.
    * @param {Object} vnode - Mithril vnode
@@ -148,8 +207,14 @@ export const ExecutionDetail = {
   loadExecution: async (id) => {
     ExecutionDetail.loading = true;
     try {
-      const [execution, logsData, aiRequestsData, emailRequestsData] =
-        await Promise.all([
+      const [
+        execution,
+        logsData,
+        aiRequestsData,
+        emailRequestsData,
+        httpRequestsData,
+        externalCallsData,
+      ] = await Promise.all([
           API.executions.get(id),
           API.executions.getLogs(
             id,
@@ -166,6 +231,16 @@ export const ExecutionDetail = {
             ExecutionDetail.emailRequestsLimit,
             ExecutionDetail.emailRequestsOffset,
           ),
+          API.executions.getHTTPRequests(
+            id,
+            ExecutionDetail.httpRequestsLimit,
+            ExecutionDetail.httpRequestsOffset,
+          ),
+          API.executions.getExternalCalls(
+            id,
+            ExecutionDetail.externalCallsLimit,
+            ExecutionDetail.externalCallsOffset,
+          ),
         ]);
       ExecutionDetail.execution = execution;
       ExecutionDetail.logs = logsData.logs || [];
@@ -175,6 +250,12 @@ export const ExecutionDetail = {
       ExecutionDetail.emailRequests = emailRequestsData.email_requests || [];
       ExecutionDetail.emailRequestsTotal =
         emailRequestsData.pagination?.total || 0;
+      ExecutionDetail.httpRequests = httpRequestsData.http_requests || [];
+      ExecutionDetail.httpRequestsTotal =
+        httpRequestsData.pagination?.total || 0;
+      ExecutionDetail.externalCalls = externalCallsData.external_calls || [];
+      ExecutionDetail.externalCallsTotal =
+        externalCallsData.pagination?.total || 0;
 
       // Load function details
       ExecutionDetail.func = await API.functions.get(execution.function_id);
@@ -300,6 +381,136 @@ export const ExecutionDetail = {
     ExecutionDetail.loadEmailRequests();
   },
 
+  /**
+   * Reloads HTTP requests with current pagination.
+   * @returns {Promise<void>}
+   */
+  loadHTTPRequests: async () => {
+    try {
+      const data = await API.executions.getHTTPRequests(
+        ExecutionDetail.execution.id,
+        ExecutionDetail.httpRequestsLimit,
+        ExecutionDetail.httpRequestsOffset,
+      );
+      ExecutionDetail.httpRequests = data.http_requests || [];
+      ExecutionDetail.httpRequestsTotal = data.pagination?.total || 0;
+      m.redraw();
+    } catch (e) {
+      console.error("Failed to load HTTP requests:", e);
+    }
+  },
+
+  /**
+   * Handles page change from HTTP requests pagination.
+   * @param {number} newOffset - New pagination offset
+   */
+  handleHTTPRequestsPageChange: (newOffset) => {
+    ExecutionDetail.httpRequestsOffset = newOffset;
+    ExecutionDetail.loadHTTPRequests();
+  },
+
+  /**
+   * Handles limit change from HTTP requests pagination.
+   * @param {number} newLimit - New items per page limit
+   */
+  handleHTTPRequestsLimitChange: (newLimit) => {
+    ExecutionDetail.httpRequestsLimit = newLimit;
+    ExecutionDetail.httpRequestsOffset = 0;
+    ExecutionDetail.loadHTTPRequests();
+  },
+
+  /**
+   * Reloads external calls with current pagination.
+   * @returns {Promise<void>}
+   */
+  loadExternalCalls: async () => {
+    try {
+      const data = await API.executions.getExternalCalls(
+        ExecutionDetail.execution.id,
+        ExecutionDetail.externalCallsLimit,
+        ExecutionDetail.externalCallsOffset,
+      );
+      ExecutionDetail.externalCalls = data.external_calls || [];
+      ExecutionDetail.externalCallsTotal = data.pagination?.total || 0;
+      m.redraw();
+    } catch (e) {
+      console.error("Failed to load external calls:", e);
+    }
+  },
+
+  /**
+   * Handles page change from external calls pagination.
+   * @param {number} newOffset - New pagination offset
+   */
+  handleExternalCallsPageChange: (newOffset) => {
+    ExecutionDetail.externalCallsOffset = newOffset;
+    ExecutionDetail.loadExternalCalls();
+  },
+
+  /**
+   * Handles limit change from external calls pagination.
+   * @param {number} newLimit - New items per page limit
+   */
+  handleExternalCallsLimitChange: (newLimit) => {
+    ExecutionDetail.externalCallsLimit = newLimit;
+    ExecutionDetail.externalCallsOffset = 0;
+    ExecutionDetail.loadExternalCalls();
+  },
+
+  /**
+   * Renders the external calls timeline table.
+   * @returns {Object} Mithril vnode
+   */
+  renderExternalCalls: () =>
+    m(Table, { style: "table-layout: fixed;" }, [
+      m(TableHeader, [
+        m(TableRow, [
+          m(TableHead, { style: "width: 10%;" }, t("execution.callKind")),
+          m(TableHead, { style: "width: 45%;" }, t("execution.callTarget")),
+          m(TableHead, { style: "width: 15%;" }, t("execution.callStatus")),
+          m(TableHead, { style: "width: 15%;" }, t("execution.callDuration")),
+          m(TableHead, { style: "width: 15%;" }, t("execution.callTime")),
+        ]),
+      ]),
+      m(
+        TableBody,
+        ExecutionDetail.externalCalls.map((call) =>
+          m(TableRow, { key: call.kind + "-" + call.id }, [
+            m(TableCell, [
+              m(
+                Badge,
+                { variant: BadgeVariant.SECONDARY, size: BadgeSize.SM },
+                t(`execution.callKinds.${call.kind}`),
+              ),
+            ]),
+            m(
+              TableCell,
+              {
+                mono: true,
+                style: "overflow: hidden; text-overflow: ellipsis;",
+                title: call.error_message || call.target,
+              },
+              call.target,
+            ),
+            m(TableCell, [
+              m(
+                Badge,
+                {
+                  variant: call.status === "success"
+                    ? BadgeVariant.SUCCESS
+                    : BadgeVariant.DESTRUCTIVE,
+                  size: BadgeSize.SM,
+                },
+                t(`common.status.${call.status}`),
+              ),
+            ]),
+            m(TableCell, { mono: true }, `${call.duration_ms}ms`),
+            m(TableCell, formatUnixTimestamp(call.created_at, "time")),
+          ])
+        ),
+      ),
+    ]),
+
   /**
    * Renders the execution detail view.
    * @returns {Object} Mithril vnode
@@ -346,6 +557,8 @@ export const ExecutionDetail = {
                 {
                   variant: exec.status === "success"
                     ? BadgeVariant.SUCCESS
+                    : exec.status === "skipped"
+                    ? BadgeVariant.WARNING
                     : BadgeVariant.DESTRUCTIVE,
                   size: BadgeSize.SM,
                 },
@@ -361,6 +574,26 @@ export const ExecutionDetail = {
                 },
                 `${exec.duration_ms}ms`,
               ),
+              exec.request_bytes != null &&
+              m(
+                Badge,
+                {
+                  variant: BadgeVariant.OUTLINE,
+                  size: BadgeSize.SM,
+                  mono: true,
+                },
+                `in: ${formatBytes(exec.request_bytes)}`,
+              ),
+              exec.response_bytes != null &&
+              m(
+                Badge,
+                {
+                  variant: BadgeVariant.OUTLINE,
+                  size: BadgeSize.SM,
+                  mono: true,
+                },
+                `out: ${formatBytes(exec.response_bytes)}`,
+              ),
             ]),
             m(
               "p.function-details-description",
@@ -513,6 +746,30 @@ export const ExecutionDetail = {
           ];
         })(),
 
+        // External Calls
+        ExecutionDetail.externalCallsTotal > 0 &&
+        m(Card, { style: "margin-bottom: 1.5rem" }, [
+          m(CardHeader, {
+            title: t("execution.externalCalls"),
+            subtitle: t("execution.externalCallsCount", {
+              count: ExecutionDetail.externalCallsTotal,
+            }),
+            icon: "arrowsRightLeft",
+          }),
+          m(CardContent, { noPadding: true }, [
+            ExecutionDetail.renderExternalCalls(),
+          ]),
+          ExecutionDetail.externalCallsTotal >
+            ExecutionDetail.externalCallsLimit &&
+          m(Pagination, {
+            total: ExecutionDetail.externalCallsTotal,
+            limit: ExecutionDetail.externalCallsLimit,
+            offset: ExecutionDetail.externalCallsOffset,
+            onPageChange: ExecutionDetail.handleExternalCallsPageChange,
+            onLimitChange: ExecutionDetail.handleExternalCallsLimitChange,
+          }),
+        ]),
+
         // AI Requests
         ExecutionDetail.aiRequestsTotal > 0 &&
         m(Card, { style: "margin-bottom: 1.5rem" }, [
@@ -568,6 +825,34 @@ export const ExecutionDetail = {
           }),
         ]),
 
+        // HTTP Requests
+        ExecutionDetail.httpRequestsTotal > 0 &&
+        m(Card, { style: "margin-bottom: 1.5rem" }, [
+          m(CardHeader, {
+            title: t("execution.httpRequests"),
+            subtitle: t("execution.httpRequestsCount", {
+              count: ExecutionDetail.httpRequestsTotal,
+            }),
+            icon: "globe",
+          }),
+          m(CardContent, { noPadding: true }, [
+            m(HTTPRequestViewer, {
+              requests: ExecutionDetail.httpRequests,
+              maxHeight: "400px",
+              noBorder: true,
+            }),
+          ]),
+          ExecutionDetail.httpRequestsTotal >
+            ExecutionDetail.httpRequestsLimit &&
+          m(Pagination, {
+            total: ExecutionDetail.httpRequestsTotal,
+            limit: ExecutionDetail.httpRequestsLimit,
+            offset: ExecutionDetail.httpRequestsOffset,
+            onPageChange: ExecutionDetail.handleHTTPRequestsPageChange,
+            onLimitChange: ExecutionDetail.handleHTTPRequestsLimitChange,
+          }),
+        ]),
+
         // Execution Logs
         m(Card, [
           m(CardHeader, {
@@ -260,6 +260,8 @@ export const FunctionExecutions = {
                               {
                                 variant: exec.status === "success"
                                   ? BadgeVariant.SUCCESS
+                                  : exec.status === "skipped"
+                                  ? BadgeVariant.WARNING
                                   : BadgeVariant.DESTRUCTIVE,
                                 size: BadgeSize.SM,
                               },
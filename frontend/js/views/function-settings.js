@@ -34,9 +34,19 @@ import {
   FormTextarea,
 } from "../components/form.js";
 import { EnvEditor } from "../components/env-editor.js";
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableEmpty,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from "../components/table.js";
 
 /**
  * @typedef {import('../types.js').LunarFunction} LunarFunction
+ * @typedef {import('../types.js').AIUsageResponse} AIUsageResponse
  */
 
 /**
@@ -129,12 +139,30 @@ export const FunctionSettings = {
    */
   nextRunInfo: null,
 
+  /**
+   * AI usage report for the last 30 days (null if unavailable).
+   * @type {AIUsageResponse|null}
+   */
+  aiUsage: null,
+
   /**
    * Edited save response state (null if unchanged).
    * @type {boolean|null}
    */
   editedSaveResponse: null,
 
+  /**
+   * Edited capture HTTP state (null if unchanged).
+   * @type {boolean|null}
+   */
+  editedCaptureHTTP: null,
+
+  /**
+   * Edited reuse state setting (null if unchanged).
+   * @type {boolean|null}
+   */
+  editedReuseState: null,
+
   /**
    * Initializes the view and loads the function.
    * @param {Object} vnode - Mithril vnode
@@ -149,8 +177,28 @@ export const FunctionSettings = {
     FunctionSettings.editedCronSchedule = null;
     FunctionSettings.editedCronStatus = null;
     FunctionSettings.nextRunInfo = null;
+    FunctionSettings.aiUsage = null;
     FunctionSettings.editedSaveResponse = null;
+    FunctionSettings.editedCaptureHTTP = null;
+    FunctionSettings.editedReuseState = null;
     FunctionSettings.loadFunction(vnode.attrs.id);
+    FunctionSettings.loadAIUsage(vnode.attrs.id);
+  },
+
+  /**
+   * Loads the AI usage report. Failures are ignored so the rest of the
+   * settings page still works when usage reporting is unavailable.
+   * @param {string} id - Function ID
+   * @returns {Promise<void>}
+   */
+  loadAIUsage: async (id) => {
+    try {
+      FunctionSettings.aiUsage = await API.functions.getAIUsage(id);
+    } catch (e) {
+      console.error("Failed to load AI usage:", e);
+      FunctionSettings.aiUsage = null;
+    }
+    m.redraw();
   },
 
   /**
@@ -174,6 +222,8 @@ export const FunctionSettings = {
       FunctionSettings.editedCronSchedule = null;
       FunctionSettings.editedCronStatus = null;
       FunctionSettings.editedSaveResponse = null;
+      FunctionSettings.editedCaptureHTTP = null;
+      FunctionSettings.editedReuseState = null;
       FunctionSettings.envVars = Object.entries(
         FunctionSettings.func.env_vars || {},
       ).map(([key, value]) => ({
@@ -239,12 +289,14 @@ export const FunctionSettings = {
       FunctionSettings.editedName !== null ||
       FunctionSettings.editedDescription !== null ||
       FunctionSettings.editedRetentionDays !== null ||
-      FunctionSettings.editedSaveResponse !== null
+      FunctionSettings.editedSaveResponse !== null ||
+      FunctionSettings.editedCaptureHTTP !== null ||
+      FunctionSettings.editedReuseState !== null
     );
   },
 
   /**
-   * Saves general settings (name, description, retention, save_response) to the API.
+   * Saves general settings (name, description, retention, save_response, capture_http, reuse_state) to the API.
    * @returns {Promise<void>}
    */
   saveGeneralSettings: async () => {
@@ -264,6 +316,12 @@ export const FunctionSettings = {
       if (FunctionSettings.editedSaveResponse !== null) {
         updates.save_response = FunctionSettings.editedSaveResponse;
       }
+      if (FunctionSettings.editedCaptureHTTP !== null) {
+        updates.capture_http = FunctionSettings.editedCaptureHTTP;
+      }
+      if (FunctionSettings.editedReuseState !== null) {
+        updates.reuse_state = FunctionSettings.editedReuseState;
+      }
 
       await API.functions.update(FunctionSettings.func.id, updates);
       Toast.show(t("toast.settingsSaved"), "success");
@@ -366,6 +424,62 @@ export const FunctionSettings = {
    * @param {Object} _vnode - Mithril vnode
    * @returns {Object} Mithril vnode
    */
+  /**
+   * Renders the AI usage card.
+   * @param {AIUsageResponse} usage - AI usage report
+   * @returns {Object} Mithril vnode
+   */
+  renderAIUsage: (usage) => {
+    const formatCost = (cost) =>
+      cost === undefined || cost === null ? "-" : `$${cost.toFixed(4)}`;
+
+    return m(Card, { style: "margin-bottom: 1.5rem" }, [
+      m(CardHeader, {
+        title: t("settings.aiUsage"),
+        subtitle: t("settings.aiUsageDescription", { window: usage.window }),
+      }),
+      m(CardContent, { noPadding: true }, [
+        m(Table, [
+          m(TableHeader, [
+            m(TableRow, [
+              m(TableHead, t("settings.aiUsageModel")),
+              m(TableHead, t("settings.aiUsageRequests")),
+              m(TableHead, t("settings.aiUsageInputTokens")),
+              m(TableHead, t("settings.aiUsageOutputTokens")),
+              m(TableHead, t("settings.aiUsageCost")),
+            ]),
+          ]),
+          m(
+            TableBody,
+            usage.usage.length === 0
+              ? m(TableEmpty, {
+                colspan: 5,
+                message: t("settings.aiUsageEmpty"),
+              })
+              : [
+                ...usage.usage.map((row) =>
+                  m(TableRow, [
+                    m(TableCell, { mono: true }, `${row.provider}/${row.model}`),
+                    m(TableCell, row.requests),
+                    m(TableCell, row.input_tokens),
+                    m(TableCell, row.output_tokens),
+                    m(TableCell, formatCost(row.estimated_cost_usd)),
+                  ])
+                ),
+                m(TableRow, [
+                  m(TableCell, m("strong", t("settings.aiUsageTotal"))),
+                  m(TableCell, usage.total_requests),
+                  m(TableCell, usage.total_input_tokens),
+                  m(TableCell, usage.total_output_tokens),
+                  m(TableCell, formatCost(usage.estimated_cost_usd)),
+                ]),
+              ],
+          ),
+        ]),
+      ]),
+    ]);
+  },
+
   view: (_vnode) => {
     if (FunctionSettings.loading) {
       return m(".loading", [
@@ -511,6 +625,42 @@ export const FunctionSettings = {
                   }
                 },
               }),
+              m(FormCheckbox, {
+                id: "capture-http",
+                label: t("settings.captureHTTP"),
+                description: t("settings.captureHTTPDescription"),
+                checked: FunctionSettings.editedCaptureHTTP !== null
+                  ? FunctionSettings.editedCaptureHTTP
+                  : func.capture_http,
+                onchange: () => {
+                  const newValue = FunctionSettings.editedCaptureHTTP !== null
+                    ? !FunctionSettings.editedCaptureHTTP
+                    : !func.capture_http;
+                  if (newValue === func.capture_http) {
+                    FunctionSettings.editedCaptureHTTP = null;
+                  } else {
+                    FunctionSettings.editedCaptureHTTP = newValue;
+                  }
+                },
+              }),
+              m(FormCheckbox, {
+                id: "reuse-state",
+                label: t("settings.reuseState"),
+                description: t("settings.reuseStateDescription"),
+                checked: FunctionSettings.editedReuseState !== null
+                  ? FunctionSettings.editedReuseState
+                  : func.reuse_state,
+                onchange: () => {
+                  const newValue = FunctionSettings.editedReuseState !== null
+                    ? !FunctionSettings.editedReuseState
+                    : !func.reuse_state;
+                  if (newValue === func.reuse_state) {
+                    FunctionSettings.editedReuseState = null;
+                  } else {
+                    FunctionSettings.editedReuseState = newValue;
+                  }
+                },
+              }),
             ]),
             m(CardFooter, [
               m(
@@ -608,6 +758,10 @@ export const FunctionSettings = {
             ]),
           ]),
 
+          // AI Usage
+          FunctionSettings.aiUsage &&
+          FunctionSettings.renderAIUsage(FunctionSettings.aiUsage),
+
           // Schedule Configuration
           m(Card, { style: "margin-bottom: 1.5rem" }, [
             m(CardHeader, { title: t("settings.schedule") }),
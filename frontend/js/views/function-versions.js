@@ -206,6 +206,31 @@ export const FunctionVersions = {
     }
   },
 
+  /**
+   * Prompts for a new label and saves it on a version.
+   * @param {FunctionVersion} ver - Version object to label
+   * @returns {Promise<void>}
+   */
+  editLabel: async (ver) => {
+    const label = prompt(
+      t("versionsPage.labelPrompt", { version: ver.version }),
+      ver.label || "",
+    );
+    if (label === null) {
+      return;
+    }
+    try {
+      await API.versions.updateLabel(FunctionVersions.func.id, ver.id, label);
+      Toast.show(
+        t("versionsPage.labelUpdated", { version: ver.version }),
+        "success",
+      );
+      await FunctionVersions.loadVersions();
+    } catch (e) {
+      Toast.show(t("versionsPage.failedToUpdateLabel"), "error");
+    }
+  },
+
   /**
    * Deletes a specific version.
    * @param {FunctionVersion} ver - Version object to delete
@@ -373,9 +398,32 @@ export const FunctionVersions = {
                               },
                               t("versionsPage.active"),
                             ),
+                            ver.label &&
+                            m(
+                              Badge,
+                              {
+                                variant: BadgeVariant.OUTLINE,
+                                size: BadgeSize.SM,
+                                style: "margin-left: 0.5rem;",
+                              },
+                              ver.label,
+                            ),
                           ]),
                           m(TableCell, formatUnixTimestamp(ver.created_at)),
                           m(TableCell, { align: "right" }, [
+                            m(
+                              Button,
+                              {
+                                variant: ButtonVariant.GHOST,
+                                size: ButtonSize.SM,
+                                style: "margin-right: 0.5rem;",
+                                onclick: (e) => {
+                                  e.stopPropagation();
+                                  FunctionVersions.editLabel(ver);
+                                },
+                              },
+                              t("versionsPage.label"),
+                            ),
                             ver.version !== func.active_version.version && [
                               m(
                                 Button,
@@ -405,6 +405,7 @@ export const Preview = {
       m(".preview-row", [
         m(TriggerBadge, { trigger: "http" }),
         m(TriggerBadge, { trigger: "cron" }),
+        m(TriggerBadge, { trigger: "manual" }),
       ]),
     ]);
   },
@@ -323,6 +323,22 @@ describe("TriggerBadge", () => {
       expect(result.children).toContain(t("executions.triggers.http"));
     });
 
+    it("renders manual trigger with secondary variant", () => {
+      const vnode = { attrs: { trigger: "manual" } };
+      const result = TriggerBadge.view(vnode);
+
+      expect(result.attrs.variant).toBe(BadgeVariant.SECONDARY);
+      expect(result.children).toContain(t("executions.triggers.manual"));
+    });
+
+    it("renders test trigger with warning variant", () => {
+      const vnode = { attrs: { trigger: "test" } };
+      const result = TriggerBadge.view(vnode);
+
+      expect(result.attrs.variant).toBe(BadgeVariant.WARNING);
+      expect(result.children).toContain(t("executions.triggers.test"));
+    });
+
     it("renders unknown trigger as http (success variant)", () => {
       const vnode = { attrs: { trigger: "unknown" } };
       const result = TriggerBadge.view(vnode);
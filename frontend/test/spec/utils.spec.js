@@ -2,7 +2,11 @@
  * @fileoverview Tests for utility functions - focused on critical functionality.
  */
 
-import { formatUnixTimestamp, getFunctionTabs } from "../../js/utils.js";
+import {
+  formatBytes,
+  formatUnixTimestamp,
+  getFunctionTabs,
+} from "../../js/utils.js";
 
 describe("getFunctionTabs", () => {
   it("returns all 5 tabs", () => {
@@ -89,3 +93,15 @@ describe("formatUnixTimestamp", () => {
     expect(result).not.toContain("1970");
   });
 });
+
+describe("formatBytes", () => {
+  it("keeps small sizes in bytes", () => {
+    expect(formatBytes(0)).toBe("0 B");
+    expect(formatBytes(512)).toBe("512 B");
+  });
+
+  it("uses binary units for larger sizes", () => {
+    expect(formatBytes(1536)).toBe("1.5 KB");
+    expect(formatBytes(1048576)).toBe("1.0 MB");
+  });
+});
@@ -1,35 +1,48 @@
 package api
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/json"
 	"net/http"
 	"strings"
 )
 
-// AuthMiddleware validates authentication via cookie or Bearer token
+// Ways a request can present the API key
+const (
+	AuthMethodCookie = "cookie"
+	AuthMethodBearer = "bearer"
+)
+
+// RoleAdmin may use every endpoint. The single API key authenticates as it.
+const RoleAdmin = "admin"
+
+// Identity is the principal a request authenticated as
+type Identity struct {
+	Principal   string   `json:"principal"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"` // "*" grants every permission
+	AuthMethod  string   `json:"auth_method"` // cookie or bearer
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity AuthMiddleware attached to the
+// request, and false outside of it
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// AuthMiddleware validates authentication via cookie or Bearer token and
+// attaches the caller's identity to the request context
 func AuthMiddleware(apiKey string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Check cookie first
-			if cookie, err := r.Cookie("auth_token"); err == nil {
-				if isValidAPIKey(cookie.Value, apiKey) {
-					next.ServeHTTP(w, r)
-					return
-				}
-			}
-
-			// Check Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader != "" {
-				// Expected format: "Bearer {token}"
-				parts := strings.SplitN(authHeader, " ", 2)
-				if len(parts) == 2 && parts[0] == "Bearer" {
-					if isValidAPIKey(parts[1], apiKey) {
-						next.ServeHTTP(w, r)
-						return
-					}
-				}
+			if method, ok := authenticate(r, apiKey); ok {
+				identity := Identity{Principal: RoleAdmin, Role: RoleAdmin, Permissions: []string{"*"}, AuthMethod: method}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+				return
 			}
 
 			// No valid authentication found
@@ -42,6 +55,35 @@ func AuthMiddleware(apiKey string) func(http.Handler) http.Handler {
 	}
 }
 
+// isAuthenticated reports whether the request carries the API key in the
+// auth_token cookie or a Bearer token
+func isAuthenticated(r *http.Request, apiKey string) bool {
+	_, ok := authenticate(r, apiKey)
+	return ok
+}
+
+// authenticate checks the API key in the auth_token cookie and then in the
+// Bearer token, returning how the request presented it
+func authenticate(r *http.Request, apiKey string) (method string, ok bool) {
+	// Check cookie first
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		if isValidAPIKey(cookie.Value, apiKey) {
+			return AuthMethodCookie, true
+		}
+	}
+
+	// Check Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		// Expected format: "Bearer {token}"
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) == 2 && parts[0] == "Bearer" && isValidAPIKey(parts[1], apiKey) {
+			return AuthMethodBearer, true
+		}
+	}
+	return "", false
+}
+
 // isValidAPIKey uses constant-time comparison to prevent timing attacks
 func isValidAPIKey(provided, expected string) bool {
 	return subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1
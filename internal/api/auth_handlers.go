@@ -93,3 +93,16 @@ func HandleLogout() http.HandlerFunc {
 		})
 	}
 }
+
+// WhoAmIHandler returns the identity the request authenticated as, so clients
+// can tell what the presented credential may do
+func WhoAmIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		writeJSON(w, http.StatusOK, identity)
+	}
+}
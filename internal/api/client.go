@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Forwarding headers set by reverse proxies, believed only from trusted peers
+const (
+	headerForwardedFor   = "X-Forwarded-For"
+	headerForwardedProto = "X-Forwarded-Proto"
+)
+
+// clientInfo is where a request came from, after trusted proxies are accounted for
+type clientInfo struct {
+	ip     string
+	scheme string // http or https
+}
+
+type clientInfoKey struct{}
+
+// ClientMiddleware resolves the address and scheme of the client behind a
+// request. Only when the connected peer is in trusted, e.g. a reverse proxy,
+// are X-Forwarded-For and X-Forwarded-Proto read; otherwise any client could
+// spoof them. X-Forwarded-For is walked from the right, skipping trusted
+// addresses, so entries prepended by the client are never used.
+func ClientMiddleware(trusted []netip.Prefix) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := resolveClient(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientInfoKey{}, info)))
+		})
+	}
+}
+
+// resolveClient returns the client info of r given the trusted proxies
+func resolveClient(r *http.Request, trusted []netip.Prefix) clientInfo {
+	info := clientInfo{ip: peerIP(r), scheme: "http"}
+	if r.TLS != nil {
+		info.scheme = "https"
+	}
+	if !isTrusted(info.ip, trusted) {
+		return info
+	}
+
+	if proto := strings.ToLower(strings.TrimSpace(firstValue(r.Header.Get(headerForwardedProto)))); proto == "http" || proto == "https" {
+		info.scheme = proto
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values(headerForwardedFor) {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for _, hop := range slices.Backward(hops) {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hop))
+		if err != nil {
+			// A malformed hop was not written by a trusted proxy
+			break
+		}
+		info.ip = addr.Unmap().String()
+		if !isTrusted(info.ip, trusted) {
+			break
+		}
+	}
+	return info
+}
+
+// isTrusted reports whether ip falls within one of the trusted prefixes
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(trusted, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+// firstValue returns the first entry of a comma-separated header value
+func firstValue(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return first
+}
+
+// peerIP returns the address of the peer connected to the server
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns the address of the client behind r, as resolved by
+// ClientMiddleware, or the connected peer outside of it
+func clientIP(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.ip
+	}
+	return peerIP(r)
+}
+
+// clientScheme returns the scheme the client used to reach the server, as
+// resolved by ClientMiddleware
+func clientScheme(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
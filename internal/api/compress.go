@@ -0,0 +1,229 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultGzipMinSize is the smallest response body, in bytes, worth compressing
+const DefaultGzipMinSize = 1024
+
+// incompressibleTypes lists content types (or type prefixes ending in "/")
+// that are already compressed or streamed, so gzip is skipped for them
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/pdf",
+	"text/event-stream",
+}
+
+// GzipMiddleware compresses responses of at least minSize bytes from /fn,
+// /api and the functions routed by prefix in routes, for clients that accept
+// gzip. Already compressed content types, responses with their own
+// Content-Encoding, WebSocket upgrades and responses flushed before reaching
+// minSize (such as event streams) are sent as they are. A minSize of zero or
+// less disables compression.
+func GzipMiddleware(minSize int, routes *RouteTable) Middleware {
+	return func(next http.Handler) http.Handler {
+		if minSize <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !compressedPath(r.URL.Path, routes) || r.Method == http.MethodHead || isWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The response depends on Accept-Encoding whether or not it is compressed
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// compressedPath reports whether path is served by a function or the API,
+// whose responses are compressed. Routes may be nil.
+func compressedPath(path string, routes *RouteTable) bool {
+	if strings.HasPrefix(path, "/fn/") || strings.HasPrefix(path, "/api/") {
+		return true
+	}
+	if routes == nil {
+		return false
+	}
+	_, _, ok := routes.Match(path)
+	return ok
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// A zero quality value (gzip;q=0) refuses the coding
+		value, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err == nil && q > 0
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it is known to be
+// at least minSize bytes, then compresses the rest. Smaller responses and
+// responses that can't be compressed are passed through unchanged.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status  int    // Status written by the handler, sent once compression is decided
+	buf     []byte // Body written before compression is decided
+	decided bool   // Whether the response is compressed or passed through
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	if !w.compressible() {
+		if err := w.passThrough(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// FlushError commits the response before flushing. Responses flushed before
+// compression started, such as streams, are sent uncompressed.
+func (w *gzipResponseWriter) FlushError() error {
+	if !w.decided {
+		if err := w.passThrough(); err != nil {
+			return err
+		}
+	}
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response headers and status allow gzip
+func (w *gzipResponseWriter) compressible() bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	if strings.HasPrefix(contentType, "image/svg+xml") {
+		return true
+	}
+	for _, incompressible := range incompressibleTypes {
+		if strings.HasPrefix(contentType, incompressible) {
+			return false
+		}
+	}
+	return true
+}
+
+// startGzip sends the headers of a compressed response and the buffered body
+func (w *gzipResponseWriter) startGzip() error {
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		// Sniff from the uncompressed body, as net/http would have done
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	_, err := w.gz.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// passThrough sends the status and buffered body without compression
+func (w *gzipResponseWriter) passThrough() error {
+	w.decided = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// close sends a response that stayed under minSize, or finishes the gzip stream
+func (w *gzipResponseWriter) close() {
+	if !w.decided {
+		_ = w.passThrough()
+		return
+	}
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/dimiro1/lunar/internal/events"
+)
+
+// redactedValue is how masking replaces sensitive values in stored events
+const redactedValue = "[REDACTED]"
+
+// curlSkippedHeaders are set by curl itself from the rest of the command
+var curlSkippedHeaders = []string{"Host", "Content-Length", "Accept-Encoding"}
+
+// curlCommand reconstructs a curl command sending the request of event to
+// the server at baseURL. Values masked when the event was stored become
+// <redacted:name> placeholders; the returned list names every value the
+// caller must fill in, as header:Name, query:name or body.
+func curlCommand(baseURL string, event events.HTTPEvent) (string, []string) {
+	var redacted []string
+
+	target := strings.TrimSuffix(baseURL, "/") + event.Path
+	if len(event.Query) > 0 {
+		var params []string
+		for _, name := range slices.Sorted(maps.Keys(event.Query)) {
+			values := event.QueryValues(name)
+			if slices.Contains(values, redactedValue) {
+				redacted = append(redacted, "query:"+name)
+			}
+			for _, value := range values {
+				if value == redactedValue {
+					value = "<redacted:" + name + ">"
+				} else {
+					value = url.QueryEscape(value)
+				}
+				params = append(params, url.QueryEscape(name)+"="+value)
+			}
+		}
+		target += "?" + strings.Join(params, "&")
+	}
+
+	command := "curl"
+	if event.Method != "" && event.Method != "GET" {
+		command += " -X " + event.Method
+	}
+	parts := []string{command + " " + shellQuote(target)}
+
+	for _, name := range slices.Sorted(maps.Keys(event.Headers)) {
+		if isHopByHopHeader(name) || slices.ContainsFunc(curlSkippedHeaders, func(skipped string) bool {
+			return strings.EqualFold(name, skipped)
+		}) {
+			continue
+		}
+		values := event.HeaderValues(name)
+		if slices.Contains(values, redactedValue) {
+			redacted = append(redacted, "header:"+name)
+		}
+		for _, value := range values {
+			if value == redactedValue {
+				value = "<redacted:" + name + ">"
+			}
+			parts = append(parts, "-H "+shellQuote(name+": "+value))
+		}
+	}
+
+	if event.Body != "" {
+		if strings.Contains(event.Body, redactedValue) {
+			redacted = append(redacted, "body")
+		}
+		parts = append(parts, "--data-raw "+shellQuote(event.Body))
+	}
+
+	return strings.Join(parts, " \\\n  "), redacted
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -1,30 +1,52 @@
 package api
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	internalcron "github.com/dimiro1/lunar/internal/cron"
 	"github.com/dimiro1/lunar/internal/diff"
 	"github.com/dimiro1/lunar/internal/engine"
 	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/ids"
+	"github.com/dimiro1/lunar/internal/killswitch"
+	"github.com/dimiro1/lunar/internal/luacode"
+	"github.com/dimiro1/lunar/internal/maintenance"
+	"github.com/dimiro1/lunar/internal/queue"
+	"github.com/dimiro1/lunar/internal/runner"
+	"github.com/dimiro1/lunar/internal/runtime/crypto"
 	"github.com/dimiro1/lunar/internal/services/ai"
 	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/services/env"
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 	"github.com/dimiro1/lunar/internal/services/logger"
+	"github.com/dimiro1/lunar/internal/services/modules"
 	"github.com/dimiro1/lunar/internal/store"
-	"github.com/rs/xid"
 )
 
 // ExecuteFunctionDeps holds dependencies for executing functions
 type ExecuteFunctionDeps struct {
-	Engine  engine.Engine
-	BaseURL string
+	DB          store.DB
+	Engine      engine.Engine
+	BaseURL     string
+	Maintenance *maintenance.Mode // Executions are rejected while enabled
+	Queue       *queue.Queue      // Bounds concurrent executions (nil for no limit)
+	APIKey      string            // Authenticates callers claiming privileged triggers
+	CronToken   string            // Authenticates the cron scheduler (empty without one)
 }
 
 // Helper functions
@@ -41,6 +63,17 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
+// writeValidationError writes err with a 400, or a 422 for code that does not
+// compile, including the message of each invalid field when err is a
+// validation error
+func writeValidationError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if isSyntaxError(err) {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, ErrorResponse{Error: err.Error(), Errors: fieldErrors(err)})
+}
+
 func parsePaginationParams(r *http.Request) store.PaginationParams {
 	params := store.PaginationParams{
 		Limit:  20, // Default
@@ -63,10 +96,6 @@ func parsePaginationParams(r *http.Request) store.PaginationParams {
 	return params
 }
 
-func generateID() string {
-	return xid.New().String()
-}
-
 func generateDiff(oldCode, newCode string, oldVersion, newVersion int) VersionDiffResponse {
 	// Use the diff package to generate the diff
 	result := diff.Compare(oldCode, newCode)
@@ -89,8 +118,68 @@ func generateDiff(oldCode, newCode string, oldVersion, newVersion int) VersionDi
 	}
 }
 
+// handlerSignaturePattern matches the line defining a function's handler,
+// capturing its parameter list
+var handlerSignaturePattern = regexp.MustCompile(`^\s*(?:local\s+)?(?:function\s+handler\s*\(([^)]*)\)|handler\s*=\s*function\s*\(([^)]*)\))`)
+
+// generateDiffSummary counts the changed lines between two versions and
+// reports whether a changed line alters the handler's parameters
+func generateDiffSummary(oldCode, newCode string, oldVersion, newVersion int) VersionDiffSummaryResponse {
+	result := diff.Compare(oldCode, newCode)
+	counts := result.Summary()
+
+	summary := VersionDiffSummaryResponse{
+		OldVersion:     oldVersion,
+		NewVersion:     newVersion,
+		LinesAdded:     counts.Added,
+		LinesRemoved:   counts.Removed,
+		LinesUnchanged: counts.Unchanged,
+		Hunks:          counts.Hunks,
+	}
+
+	// Only changed lines are inspected, so a signature on an unchanged line
+	// is left out of the summary
+	var oldParams, newParams string
+	for _, line := range result.Lines {
+		match := handlerSignaturePattern.FindStringSubmatch(line.Content)
+		if match == nil {
+			continue
+		}
+		params := strings.Join(strings.Fields(strings.ReplaceAll(match[1]+match[2], ",", ", ")), " ")
+		switch {
+		case line.Type == diff.LineTypeRemoved && summary.OldHandlerSignature == "":
+			summary.OldHandlerSignature = strings.TrimSpace(line.Content)
+			oldParams = params
+		case line.Type == diff.LineTypeAdded && summary.NewHandlerSignature == "":
+			summary.NewHandlerSignature = strings.TrimSpace(line.Content)
+			newParams = params
+		}
+	}
+	if summary.OldHandlerSignature != "" || summary.NewHandlerSignature != "" {
+		summary.HandlerSignatureChanged = summary.OldHandlerSignature == "" || summary.NewHandlerSignature == "" || oldParams != newParams
+	}
+
+	return summary
+}
+
+// FunctionDefaults holds the settings applied to every new function. Changes
+// made to a function afterwards override them.
+type FunctionDefaults struct {
+	SaveResponse  bool // Store response bodies of executions
+	RetentionDays *int // Days executions are kept, nil to keep them forever
+}
+
+// apply sets the defaults on a function that is about to be created
+func (d FunctionDefaults) apply(fn *store.Function) {
+	fn.SaveResponse = d.SaveResponse
+	if d.RetentionDays != nil {
+		days := *d.RetentionDays
+		fn.RetentionDays = &days
+	}
+}
+
 // CreateFunctionHandler returns a handler for creating functions
-func CreateFunctionHandler(database store.DB) http.HandlerFunc {
+func CreateFunctionHandler(database store.DB, newID ids.Generator, defaults FunctionDefaults) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req CreateFunctionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -100,12 +189,12 @@ func CreateFunctionHandler(database store.DB) http.HandlerFunc {
 
 		// Validate request
 		if err := ValidateCreateFunctionRequest(&req); err != nil {
-			writeError(w, http.StatusBadRequest, err.Error())
+			writeValidationError(w, err)
 			return
 		}
 
 		// Generate unique ID for the function
-		functionID := generateID()
+		functionID := newID(ids.Function)
 
 		// Create the function
 		fn := store.Function{
@@ -114,6 +203,7 @@ func CreateFunctionHandler(database store.DB) http.HandlerFunc {
 			Description: req.Description,
 			EnvVars:     make(map[string]string),
 		}
+		defaults.apply(&fn)
 
 		createdFn, err := database.CreateFunction(r.Context(), fn)
 		if err != nil {
@@ -122,7 +212,7 @@ func CreateFunctionHandler(database store.DB) http.HandlerFunc {
 		}
 
 		// Create the first version
-		version, err := database.CreateVersion(r.Context(), createdFn.ID, req.Code, nil)
+		version, err := database.CreateVersion(r.Context(), createdFn.ID, req.Code, nil, req.VersionLabel)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "Failed to create initial version")
 			return
@@ -163,42 +253,63 @@ func ListFunctionsHandler(database store.DB) http.HandlerFunc {
 	}
 }
 
-// GetFunctionHandler returns a handler for getting a specific function
+// GetFunctionHandler returns a handler for getting a specific function. With
+// ?include=recent_executions the response also embeds its latest executions,
+// saving the dashboard a second request.
 func GetFunctionHandler(database store.DB, envStore env.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 
-		fn, err := database.GetFunction(r.Context(), id)
-		if err != nil {
+		includeRecent := false
+		if include := r.URL.Query().Get("include"); include != "" {
+			for part := range strings.SplitSeq(include, ",") {
+				if name := strings.TrimSpace(part); name != IncludeRecentExecutions {
+					writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid include %q, want %s", name, IncludeRecentExecutions))
+					return
+				}
+				includeRecent = true
+			}
+		}
+
+		resp, err := store.GetFunctionWithEnv(r.Context(), database, envStore, id)
+		switch {
+		case errors.Is(err, store.ErrFunctionNotFound):
 			writeError(w, http.StatusNotFound, "Function not found")
 			return
+		case errors.Is(err, store.ErrNoActiveVersion):
+			writeError(w, http.StatusInternalServerError, "No active version found")
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "Failed to get function")
+			return
 		}
 
-		activeVersion, err := database.GetActiveVersion(r.Context(), id)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "No active version found")
+		if !includeRecent {
+			writeJSON(w, http.StatusOK, resp)
 			return
 		}
 
-		// Get env vars from the env store
-		envVars, err := envStore.All(id)
+		executions, _, err := database.ListExecutions(r.Context(), id, store.PaginationParams{Limit: RecentExecutionsLimit})
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to get env vars")
+			writeError(w, http.StatusInternalServerError, "Failed to list executions")
 			return
 		}
-		fn.EnvVars = envVars
-
-		resp := store.FunctionWithActiveVersion{
-			Function:      fn,
-			ActiveVersion: activeVersion,
+		recent := make([]ExecutionSummary, len(executions))
+		for i, exec := range executions {
+			recent[i] = ExecutionSummary{
+				ID:         exec.ID,
+				Status:     exec.Status,
+				Trigger:    exec.Trigger,
+				DurationMs: exec.DurationMs,
+				CreatedAt:  exec.CreatedAt,
+			}
 		}
-
-		writeJSON(w, http.StatusOK, resp)
+		writeJSON(w, http.StatusOK, FunctionWithRecentExecutions{FunctionWithActiveVersion: resp, RecentExecutions: recent})
 	}
 }
 
 // UpdateFunctionHandler returns a handler for updating functions
-func UpdateFunctionHandler(database store.DB, scheduler *internalcron.FunctionScheduler) http.HandlerFunc {
+func UpdateFunctionHandler(database store.DB, scheduler *internalcron.FunctionScheduler, routes *RouteTable) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 
@@ -210,600 +321,2315 @@ func UpdateFunctionHandler(database store.DB, scheduler *internalcron.FunctionSc
 
 		// Validate request
 		if err := ValidateUpdateFunctionRequest(&req); err != nil {
-			writeError(w, http.StatusBadRequest, err.Error())
+			writeValidationError(w, err)
 			return
 		}
 
-		// If code is provided, create a new version
-		if req.Code != nil {
-			_, err := database.CreateVersion(r.Context(), id, *req.Code, nil)
-			if err != nil {
-				writeError(w, http.StatusInternalServerError, "Failed to create new version")
-				return
-			}
+		// Create the new version and update the metadata in one transaction,
+		// so a failed update leaves no new version behind
+		if _, err := applyFunctionUpdate(r.Context(), database, id, req); err != nil {
+			writeFunctionUpdateError(w, err)
+			return
 		}
 
-		// Track if cron settings changed
-		cronChanged := req.CronSchedule != nil || req.CronStatus != nil
+		refreshAfterUpdate(r.Context(), database, scheduler, routes, id, req)
 
-		// If metadata is provided, update the function
-		if req.Name != nil || req.Description != nil || req.Disabled != nil || req.RetentionDays != nil || req.CronSchedule != nil || req.CronStatus != nil || req.SaveResponse != nil {
-			err := database.UpdateFunction(r.Context(), id, req)
-			if err != nil {
-				writeError(w, http.StatusNotFound, "Function not found")
-				return
-			}
-		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
 
-		// If cron settings changed, refresh the scheduler
-		if cronChanged && scheduler != nil {
-			if err := scheduler.RefreshFunction(id); err != nil {
-				slog.Error("Failed to refresh cron schedule for function",
-					"function_id", id,
-					"error", err)
-				// Don't fail the request, just log the error
-			}
-		}
+// applyFunctionUpdate creates the version for req.Code and applies the
+// metadata of req in a single transaction
+func applyFunctionUpdate(ctx context.Context, database store.DB, id string, req store.UpdateFunctionRequest) (store.FunctionWithActiveVersion, error) {
+	functions, err := database.ApplyBatch(ctx, []store.BatchOperation{{ID: id, Changes: req}})
+	if err != nil {
+		return store.FunctionWithActiveVersion{}, err
+	}
+	return functions[0], nil
+}
 
-		w.WriteHeader(http.StatusOK)
+// writeFunctionUpdateError writes the response for a failed applyFunctionUpdate
+func writeFunctionUpdateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrFunctionNotFound):
+		writeError(w, http.StatusNotFound, "Function not found")
+	case errors.Is(err, store.ErrRoutePrefixTaken):
+		writeError(w, http.StatusConflict, "Route prefix is already used by another function")
+	default:
+		writeError(w, http.StatusInternalServerError, "Failed to update function")
 	}
 }
 
-// DeleteFunctionHandler returns a handler for deleting functions
-func DeleteFunctionHandler(database store.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := r.PathValue("id")
+// refreshAfterUpdate does the follow-up work of an applied update: it
+// refreshes the route table and the cron schedule and prunes old versions
+// when the update changed them
+func refreshAfterUpdate(ctx context.Context, database store.DB, scheduler *internalcron.FunctionScheduler, routes *RouteTable, id string, req store.UpdateFunctionRequest) {
+	// If the route prefix changed, refresh the route table
+	if req.RoutePrefix != nil {
+		if err := routes.Refresh(ctx, database, id); err != nil {
+			slog.Error("Failed to refresh route for function",
+				"function_id", id,
+				"error", err)
+		}
+	}
 
-		if err := database.DeleteFunction(r.Context(), id); err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to delete function")
-			return
+	// Apply a new version limit right away instead of waiting for the next deploy
+	if req.MaxVersions != nil && *req.MaxVersions > 0 {
+		if _, err := database.DeleteOldVersions(ctx, id, *req.MaxVersions); err != nil {
+			slog.Error("Failed to prune old versions",
+				"function_id", id,
+				"error", err)
 		}
+	}
 
-		w.WriteHeader(http.StatusNoContent)
+	// If cron settings changed, refresh the scheduler
+	cronChanged := req.CronSchedule != nil || req.CronStatus != nil || req.CronJitter != nil || req.CronNoOverlap != nil
+	if cronChanged && scheduler != nil {
+		if err := scheduler.RefreshFunction(id); err != nil {
+			slog.Error("Failed to refresh cron schedule for function",
+				"function_id", id,
+				"error", err)
+			// Don't fail the request, just log the error
+		}
 	}
 }
 
-// UpdateEnvVarsHandler returns a handler for updating environment variables
-func UpdateEnvVarsHandler(database store.DB, envStore env.Store) http.HandlerFunc {
+// DeployHandler returns a handler for deploying a function: it creates a new
+// version from the code, updates the settings and changes the env vars
+// together. Env vars live outside the database transaction, so they are
+// changed first and restored when the version or the settings cannot be
+// saved. A failed deploy leaves no new version and the previous env vars.
+func DeployHandler(database store.DB, envStore env.Store, scheduler *internalcron.FunctionScheduler, routes *RouteTable) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 
-		var req UpdateEnvVarsRequest
+		var req DeployRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 
-		// Validate request
-		if err := ValidateUpdateEnvVarsRequest(&req); err != nil {
-			writeError(w, http.StatusBadRequest, err.Error())
+		if err := ValidateDeployRequest(&req); err != nil {
+			writeValidationError(w, err)
 			return
 		}
 
-		// Verify function exists
-		_, err := database.GetFunction(r.Context(), id)
-		if err != nil {
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
 			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		// Get current env vars from env store
-		currentEnvVars, err := envStore.All(id)
+		previous, err := envStore.All(id)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "Failed to get current env vars")
 			return
 		}
 
-		// Delete removed env vars
-		for key := range currentEnvVars {
-			if _, exists := req.EnvVars[key]; !exists {
-				if err := envStore.Delete(id, key); err != nil {
-					writeError(w, http.StatusInternalServerError, "Failed to delete env var")
-					return
-				}
+		envVars := maps.Clone(previous)
+		for key, value := range req.EnvVars {
+			if value == nil {
+				delete(envVars, key)
+			} else {
+				envVars[key] = *value
 			}
 		}
+		if len(envVars) > MaxEnvVars {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot have more than %d environment variables", MaxEnvVars))
+			return
+		}
 
-		// Set new/updated env vars
-		for key, value := range req.EnvVars {
-			if err := envStore.Set(id, key, value); err != nil {
-				writeError(w, http.StatusInternalServerError, "Failed to set env var")
-				return
+		// restoreEnv puts back the env vars the function had before the deploy
+		restoreEnv := func() {
+			if err := envStore.SetAll(id, previous); err != nil {
+				slog.Error("Failed to restore env vars after a failed deploy",
+					"function_id", id,
+					"error", err)
 			}
 		}
 
-		// Get the active version to return
-		activeVersion, err := database.GetActiveVersion(r.Context(), id)
+		if err := envStore.SetAll(id, envVars); err != nil {
+			restoreEnv()
+			writeError(w, http.StatusInternalServerError, "Failed to update env vars, nothing was deployed")
+			return
+		}
+
+		fn, err := applyFunctionUpdate(r.Context(), database, id, req.UpdateFunctionRequest)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to get active version")
+			restoreEnv()
+			writeFunctionUpdateError(w, err)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, activeVersion)
+		refreshAfterUpdate(r.Context(), database, scheduler, routes, id, req.UpdateFunctionRequest)
+
+		fn.EnvVars = envVars
+		writeJSON(w, http.StatusOK, fn)
 	}
 }
 
-// ListVersionsHandler returns a handler for listing function versions
-func ListVersionsHandler(database store.DB) http.HandlerFunc {
+// BatchFunctionsHandler returns a handler for creating and updating several
+// functions in one request. The batch is all-or-nothing: when any operation is
+// invalid or fails, no changes are made and the results identify the cause.
+func BatchFunctionsHandler(database store.DB, newID ids.Generator, defaults FunctionDefaults, scheduler *internalcron.FunctionScheduler, routes *RouteTable) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := r.PathValue("id")
-		params := parsePaginationParams(r)
-
-		// Verify function exists
-		if _, err := database.GetFunction(r.Context(), id); err != nil {
-			writeError(w, http.StatusNotFound, "Function not found")
+		var req BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 
-		versions, total, err := database.ListVersions(r.Context(), id, params)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to list versions")
+		if err := ValidateBatchRequest(&req); err != nil {
+			writeValidationError(w, err)
 			return
 		}
 
-		params = params.Normalize()
-		resp := PaginatedVersionsResponse{
-			Versions: versions,
-			Pagination: store.PaginationInfo{
-				Total:  total,
-				Limit:  params.Limit,
-				Offset: params.Offset,
-			},
-		}
-
-		writeJSON(w, http.StatusOK, resp)
-	}
-}
+		results := make([]BatchOperationResult, len(req.Operations))
+		ops := make([]store.BatchOperation, len(req.Operations))
+		valid := true
+		for i := range req.Operations {
+			op := &req.Operations[i]
+			results[i] = BatchOperationResult{Index: i, Action: op.Action, Status: BatchStatusNotApplied}
+
+			if err := ValidateBatchOperation(op); err != nil {
+				results[i].Status = BatchStatusFailed
+				results[i].Error = err.Error()
+				valid = false
+				continue
+			}
 
-// GetVersionHandler returns a handler for getting a specific version
-func GetVersionHandler(database store.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := r.PathValue("id")
-		versionStr := r.PathValue("version")
+			ops[i] = store.BatchOperation{ID: op.ID, Changes: op.UpdateFunctionRequest}
+			if op.Action == BatchActionCreate {
+				ops[i].Create = &store.Function{
+					ID:          newID(ids.Function),
+					Name:        *op.Name,
+					Description: op.Description,
+					EnvVars:     make(map[string]string),
+				}
+				defaults.apply(ops[i].Create)
+			}
+		}
 
-		// Parse version number
-		versionNum, err := strconv.Atoi(versionStr)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid version number")
+		if !valid {
+			writeJSON(w, http.StatusBadRequest, BatchResponse{Results: results})
 			return
 		}
 
-		version, err := database.GetVersion(r.Context(), id, versionNum)
+		functions, err := database.ApplyBatch(r.Context(), ops)
 		if err != nil {
-			writeError(w, http.StatusNotFound, "Version not found")
+			status := http.StatusInternalServerError
+			var batchErr *store.BatchError
+			if errors.As(err, &batchErr) {
+				results[batchErr.Index].Status = BatchStatusFailed
+				switch {
+				case errors.Is(err, store.ErrFunctionNotFound):
+					status = http.StatusNotFound
+					results[batchErr.Index].Error = "Function not found"
+				case errors.Is(err, store.ErrRoutePrefixTaken):
+					status = http.StatusConflict
+					results[batchErr.Index].Error = "Route prefix is already used by another function"
+				default:
+					results[batchErr.Index].Error = "Failed to apply operation"
+				}
+			}
+			writeJSON(w, status, BatchResponse{Results: results})
 			return
 		}
 
-		writeJSON(w, http.StatusOK, version)
-	}
-}
-
-// ActivateVersionHandler returns a handler for activating a version
-func ActivateVersionHandler(database store.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		versionID := r.PathValue("versionId")
-
-		// Activate the version
-		if err := database.ActivateVersion(r.Context(), versionID); err != nil {
-			if err == store.ErrVersionNotFound {
-				writeError(w, http.StatusNotFound, "Version not found")
-				return
+		for i, fn := range functions {
+			op := req.Operations[i]
+			results[i].Status = BatchStatusUpdated
+			if op.Action == BatchActionCreate {
+				results[i].Status = BatchStatusCreated
+			}
+			results[i].Function = &fn
+
+			// Apply the same follow-up work as a single update
+			if op.RoutePrefix != nil {
+				if err := routes.Refresh(r.Context(), database, fn.ID); err != nil {
+					slog.Error("Failed to refresh route for function",
+						"function_id", fn.ID,
+						"error", err)
+				}
+			}
+			if op.MaxVersions != nil && *op.MaxVersions > 0 {
+				if _, err := database.DeleteOldVersions(r.Context(), fn.ID, *op.MaxVersions); err != nil {
+					slog.Error("Failed to prune old versions",
+						"function_id", fn.ID,
+						"error", err)
+				}
+			}
+			if (op.CronSchedule != nil || op.CronStatus != nil || op.CronJitter != nil || op.CronNoOverlap != nil) && scheduler != nil {
+				if err := scheduler.RefreshFunction(fn.ID); err != nil {
+					slog.Error("Failed to refresh cron schedule for function",
+						"function_id", fn.ID,
+						"error", err)
+				}
 			}
-			writeError(w, http.StatusInternalServerError, "Failed to activate version")
-			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		writeJSON(w, http.StatusOK, BatchResponse{Applied: true, Results: results})
 	}
 }
 
-// DeleteVersionHandler returns a handler for deleting a version
-func DeleteVersionHandler(database store.DB) http.HandlerFunc {
+// DeleteFunctionHandler returns a handler for deleting functions
+func DeleteFunctionHandler(database store.DB, routes *RouteTable, cache *engine.ResponseCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		versionID := r.PathValue("versionId")
+		id := r.PathValue("id")
 
-		// Delete the version
-		if err := database.DeleteVersion(r.Context(), versionID); err != nil {
-			if err == store.ErrVersionNotFound {
-				writeError(w, http.StatusNotFound, "Version not found")
-				return
-			}
-			if err == store.ErrCannotDeleteActiveVersion {
-				writeError(w, http.StatusBadRequest, "Cannot delete active version")
-				return
-			}
-			writeError(w, http.StatusInternalServerError, "Failed to delete version")
+		if err := database.DeleteFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete function")
 			return
 		}
 
+		routes.Remove(id)
+
+		// The function is gone either way, so a failed purge is only logged
+		if err := cache.Purge(id); err != nil {
+			slog.Error("Failed to purge cache of deleted function", "function_id", id, "error", err)
+		}
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-// GetVersionDiffHandler returns a handler for getting diff between versions
-func GetVersionDiffHandler(database store.DB) http.HandlerFunc {
+// UpdateEnvVarsHandler returns a handler for updating environment variables
+func UpdateEnvVarsHandler(database store.DB, envStore env.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
-		v1Str := r.PathValue("v1")
-		v2Str := r.PathValue("v2")
 
-		// Parse version numbers
-		v1, err := strconv.Atoi(v1Str)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid version number v1")
+		var req UpdateEnvVarsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 
-		v2, err := strconv.Atoi(v2Str)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid version number v2")
+		// Validate request
+		if err := ValidateUpdateEnvVarsRequest(&req); err != nil {
+			writeValidationError(w, err)
 			return
 		}
 
-		// Get both versions from the database
-		version1, err := database.GetVersion(r.Context(), id, v1)
+		// Verify function exists
+		_, err := database.GetFunction(r.Context(), id)
 		if err != nil {
-			writeError(w, http.StatusNotFound, "Version v1 not found")
+			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		version2, err := database.GetVersion(r.Context(), id, v2)
-		if err != nil {
-			writeError(w, http.StatusNotFound, "Version v2 not found")
+		// Replace the env vars in one step, so a failure changes none of them
+		if err := envStore.SetAll(id, req.EnvVars); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to update env vars")
 			return
 		}
 
-		// Generate the diff using our utility function
-		diffResult := generateDiff(version1.Code, version2.Code, v1, v2)
+		// Get the active version to return
+		activeVersion, err := database.GetActiveVersion(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get active version")
+			return
+		}
 
-		writeJSON(w, http.StatusOK, diffResult)
+		writeJSON(w, http.StatusOK, activeVersion)
 	}
 }
 
-// ListExecutionsHandler returns a handler for listing executions
-func ListExecutionsHandler(database store.DB) http.HandlerFunc {
+// PatchEnvVarsHandler returns a handler for setting and deleting individual
+// environment variables, leaving the keys not in the request untouched
+func PatchEnvVarsHandler(database store.DB, envStore env.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
-		params := parsePaginationParams(r)
 
-		// Verify function exists
+		var req PatchEnvVarsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := ValidatePatchEnvVarsRequest(&req); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
 		if _, err := database.GetFunction(r.Context(), id); err != nil {
 			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		executions, total, err := database.ListExecutions(r.Context(), id, params)
+		currentEnvVars, err := envStore.All(id)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to list executions")
+			writeError(w, http.StatusInternalServerError, "Failed to get current env vars")
 			return
 		}
 
-		params = params.Normalize()
-		resp := PaginatedExecutionsResponse{
-			Executions: executions,
-			Pagination: store.PaginationInfo{
-				Total:  total,
-				Limit:  params.Limit,
-				Offset: params.Offset,
-			},
+		// Compute the resulting set of env vars before touching the store
+		result := maps.Clone(currentEnvVars)
+		for key, value := range req.EnvVars {
+			if value == nil {
+				delete(result, key)
+			} else {
+				result[key] = *value
+			}
 		}
 
-		writeJSON(w, http.StatusOK, resp)
+		if len(result) > MaxEnvVars {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot have more than %d environment variables", MaxEnvVars))
+			return
+		}
+
+		// Write the result in one step, so a failure applies none of the patch
+		if err := envStore.SetAll(id, result); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to update env vars")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, EnvVarsResponse{EnvVars: result})
 	}
 }
 
-// GetExecutionHandler returns a handler for getting a specific execution
-func GetExecutionHandler(database store.DB) http.HandlerFunc {
+// DeleteEnvVarHandler returns a handler for deleting a single environment variable
+func DeleteEnvVarHandler(database store.DB, envStore env.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
+		key := r.PathValue("key")
 
-		execution, err := database.GetExecution(r.Context(), id)
-		if err != nil {
-			writeError(w, http.StatusNotFound, "Execution not found")
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		writeJSON(w, http.StatusOK, execution)
+		currentEnvVars, err := envStore.All(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get current env vars")
+			return
+		}
+		if _, exists := currentEnvVars[key]; !exists {
+			writeError(w, http.StatusNotFound, "Environment variable not found")
+			return
+		}
+
+		delete(currentEnvVars, key)
+		if err := envStore.SetAll(id, currentEnvVars); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete env var")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-// GetExecutionLogsHandler returns a handler for getting execution logs
-func GetExecutionLogsHandler(database store.DB, appLogger logger.Logger) http.HandlerFunc {
+// PurgeCacheHandler returns a handler that removes every cached response of a
+// function, so the next requests run it again
+func PurgeCacheHandler(database store.DB, cache *engine.ResponseCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
-		params := parsePaginationParams(r)
 
-		// Get the execution
-		execution, err := database.GetExecution(r.Context(), id)
-		if err != nil {
-			writeError(w, http.StatusNotFound, "Execution not found")
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		// Get the logs for this execution from the logger
-		params = params.Normalize()
-		logEntries, total := appLogger.EntriesPaginated(id, params.Limit, params.Offset)
-
-		// Convert logger.LogEntry to API LogEntry format
-		apiLogs := make([]LogEntry, len(logEntries))
-		for i, entry := range logEntries {
-			// Map logger.LogLevel (int) to API LogLevel (string)
-			var level LogLevel
-			switch entry.Level {
-			case logger.Debug:
-				level = LogLevelDebug
-			case logger.Info:
-				level = LogLevelInfo
-			case logger.Warn:
-				level = LogLevelWarn
-			case logger.Error:
-				level = LogLevelError
-			default:
-				level = LogLevelInfo
-			}
-
-			apiLogs[i] = LogEntry{
-				Level:     level,
-				Message:   entry.Message,
-				CreatedAt: entry.Timestamp,
-			}
-		}
-
-		resp := PaginatedExecutionWithLogs{
-			Execution: execution,
-			Logs:      apiLogs,
-			Pagination: store.PaginationInfo{
-				Total:  total,
-				Limit:  params.Limit,
-				Offset: params.Offset,
-			},
+		if err := cache.Purge(id); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to purge cache")
+			return
 		}
 
-		writeJSON(w, http.StatusOK, resp)
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-// GetExecutionAIRequestsHandler returns a handler for getting AI requests for an execution
-func GetExecutionAIRequestsHandler(database store.DB, aiTracker ai.Tracker) http.HandlerFunc {
+// ListEmailTemplatesHandler returns a handler for listing a function's email templates
+func ListEmailTemplatesHandler(database store.DB, templates email.TemplateStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
-		params := parsePaginationParams(r)
 
-		// Verify execution exists
-		_, err := database.GetExecution(r.Context(), id)
-		if err != nil {
-			writeError(w, http.StatusNotFound, "Execution not found")
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		// Get AI requests for this execution
-		params = params.Normalize()
-		aiRequests, total := aiTracker.RequestsPaginated(id, params.Limit, params.Offset)
-
-		resp := PaginatedAIRequestsResponse{
-			AIRequests: aiRequests,
-			Pagination: store.PaginationInfo{
-				Total:  total,
-				Limit:  params.Limit,
-				Offset: params.Offset,
-			},
+		list, err := templates.All(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list email templates")
+			return
 		}
 
-		writeJSON(w, http.StatusOK, resp)
+		writeJSON(w, http.StatusOK, ListEmailTemplatesResponse{Templates: list})
 	}
 }
 
-// GetExecutionEmailRequestsHandler returns a handler for getting email requests for an execution
-func GetExecutionEmailRequestsHandler(database store.DB, emailTracker email.Tracker) http.HandlerFunc {
+// GetEmailTemplateHandler returns a handler for getting a function's email template
+func GetEmailTemplateHandler(database store.DB, templates email.TemplateStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
-		params := parsePaginationParams(r)
 
-		// Verify execution exists
-		_, err := database.GetExecution(r.Context(), id)
-		if err != nil {
-			writeError(w, http.StatusNotFound, "Execution not found")
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		// Get email requests for this execution
-		params = params.Normalize()
-		emailRequests, total := emailTracker.RequestsPaginated(id, params.Limit, params.Offset)
-
-		resp := PaginatedEmailRequestsResponse{
-			EmailRequests: emailRequests,
-			Pagination: store.PaginationInfo{
-				Total:  total,
-				Limit:  params.Limit,
-				Offset: params.Offset,
-			},
+		tmpl, err := templates.Get(id, r.PathValue("name"))
+		if errors.Is(err, email.ErrTemplateNotFound) {
+			writeError(w, http.StatusNotFound, "Email template not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get email template")
+			return
 		}
 
-		writeJSON(w, http.StatusOK, resp)
+		writeJSON(w, http.StatusOK, tmpl)
 	}
 }
 
-// ExecuteFunctionHandler returns a handler for executing functions
-func ExecuteFunctionHandler(deps ExecuteFunctionDeps) http.HandlerFunc {
+// PutEmailTemplateHandler returns a handler for creating or replacing a function's email template
+func PutEmailTemplateHandler(database store.DB, templates email.TemplateStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		functionID := r.PathValue("function_id")
+		id := r.PathValue("id")
+		name := r.PathValue("name")
 
-		// Parse HTTP event from request
-		httpEvent, err := parseHTTPEvent(r, functionID)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "Failed to read request body")
+		var req PutEmailTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 
-		// Determine trigger (from X-Trigger header or default to HTTP)
-		trigger := store.ExecutionTriggerHTTP
-		if r.Header.Get("X-Trigger") == "cron" {
-			trigger = store.ExecutionTriggerCron
+		if err := ValidatePutEmailTemplateRequest(name, &req); err != nil {
+			writeValidationError(w, err)
+			return
 		}
 
-		// Execute via engine
-		result, err := deps.Engine.Execute(r.Context(), engine.ExecutionRequest{
-			FunctionID: functionID,
-			Event:      httpEvent,
-			Trigger:    trigger,
-			BaseURL:    deps.BaseURL,
-		})
-		// Handle engine errors
-		if err != nil {
-			handleEngineError(w, err)
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		// Set execution metadata headers
-		w.Header().Set("X-Function-Id", functionID)
-		w.Header().Set("X-Function-Version-Id", result.FunctionVersionID)
-		w.Header().Set("X-Execution-Id", result.ExecutionID)
-		w.Header().Set("X-Execution-Duration-Ms", strconv.FormatInt(result.Duration.Milliseconds(), 10))
-
-		// Handle execution errors
-		if result.Error != nil {
-			slog.Error("Function execution failed",
-				"execution_id", result.ExecutionID,
-				"function_id", functionID,
-				"error", result.Error)
-			writeError(w, http.StatusInternalServerError, "Function execution failed")
+		tmpl, err := templates.Set(id, email.Template{
+			Name:    name,
+			Subject: req.Subject,
+			HTML:    req.HTML,
+			Text:    req.Text,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to save email template")
 			return
 		}
 
-		// Write HTTP response
-		writeExecutionResponse(w, result)
+		writeJSON(w, http.StatusOK, tmpl)
 	}
 }
 
-// parseHTTPEvent creates an HTTPEvent from an HTTP request
-func parseHTTPEvent(r *http.Request, functionID string) (events.HTTPEvent, error) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return events.HTTPEvent{}, err
-	}
-
-	// Compute relativePath by stripping /fn/{function_id} prefix
-	prefix := "/fn/" + functionID
-	relativePath := strings.TrimPrefix(r.URL.Path, prefix)
-	if relativePath == "" {
-		relativePath = "/"
-	}
-
-	httpEvent := events.HTTPEvent{
-		Method:       r.Method,
-		Path:         r.URL.Path,
-		RelativePath: relativePath,
-		Headers:      make(map[string]string),
-		Body:         string(body),
-		Query:        make(map[string]string),
-	}
+// DeleteEmailTemplateHandler returns a handler for deleting a function's email template
+func DeleteEmailTemplateHandler(database store.DB, templates email.TemplateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
 
-	// Copy headers
-	for key, values := range r.Header {
-		if len(values) > 0 {
-			httpEvent.Headers[key] = values[0]
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
 		}
-	}
 
-	// Copy query parameters
-	for key, values := range r.URL.Query() {
-		if len(values) > 0 {
-			httpEvent.Query[key] = values[0]
+		err := templates.Delete(id, r.PathValue("name"))
+		if errors.Is(err, email.ErrTemplateNotFound) {
+			writeError(w, http.StatusNotFound, "Email template not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete email template")
+			return
 		}
-	}
 
-	return httpEvent, nil
+		w.WriteHeader(http.StatusNoContent)
+	}
 }
 
-// handleEngineError writes the appropriate HTTP error for engine errors
-func handleEngineError(w http.ResponseWriter, err error) {
-	var fnNotFound *engine.FunctionNotFoundError
-	var fnDisabled *engine.FunctionDisabledError
-	var noVersion *engine.NoActiveVersionError
+// ListModulesHandler returns a handler for listing a function's Lua modules
+func ListModulesHandler(database store.DB, mods modules.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
 
-	switch {
-	case errors.As(err, &fnNotFound):
-		writeError(w, http.StatusNotFound, "Function not found")
-	case errors.As(err, &fnDisabled):
-		writeError(w, http.StatusForbidden, "Function is disabled")
-	case errors.As(err, &noVersion):
-		writeError(w, http.StatusInternalServerError, "No active version found")
-	default:
-		writeError(w, http.StatusInternalServerError, "Internal server error")
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		list, err := mods.All(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list modules")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ListModulesResponse{Modules: list})
 	}
 }
 
-// writeExecutionResponse writes the function's HTTP response to the client
-func writeExecutionResponse(w http.ResponseWriter, result *engine.ExecutionResult) {
-	if result.Response == nil {
-		writeError(w, http.StatusInternalServerError, "Function did not return HTTP response")
-		return
-	}
+// GetModuleHandler returns a handler for getting a function's Lua module
+func GetModuleHandler(database store.DB, mods modules.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
 
-	// Set custom headers from function response
-	for key, value := range result.Response.Headers {
-		w.Header().Set(key, value)
-	}
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
 
-	// Set the status code
-	statusCode := result.Response.StatusCode
-	if statusCode == 0 {
-		statusCode = http.StatusOK
-	}
+		module, err := mods.Get(id, r.PathValue("name"))
+		if errors.Is(err, modules.ErrModuleNotFound) {
+			writeError(w, http.StatusNotFound, "Module not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get module")
+			return
+		}
 
-	// Only set default Content-Type if the function didn't provide one
-	if w.Header().Get("Content-Type") == "" {
-		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusOK, module)
 	}
-
-	w.WriteHeader(statusCode)
-	_, _ = w.Write([]byte(result.Response.Body))
 }
 
-// GetNextRunHandler returns a handler for getting the next scheduled run time
-func GetNextRunHandler(database store.DB) http.HandlerFunc {
+// PutModuleHandler returns a handler for creating or replacing a function's Lua module.
+// Every version of the function loads the current module code.
+func PutModuleHandler(database store.DB, mods modules.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
+		name := r.PathValue("name")
 
-		// Get the function
-		fn, err := database.GetFunction(r.Context(), id)
-		if err != nil {
-			writeError(w, http.StatusNotFound, "Function not found")
+		var req PutModuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 
-		// Check if the function has an active cron schedule
-		if fn.CronSchedule == nil || *fn.CronSchedule == "" {
-			writeJSON(w, http.StatusOK, NextRunResponse{
-				HasSchedule: false,
-			})
+		if err := ValidatePutModuleRequest(name, &req); err != nil {
+			writeValidationError(w, err)
 			return
 		}
 
-		if fn.CronStatus == nil || *fn.CronStatus != string(store.CronStatusActive) {
-			writeJSON(w, http.StatusOK, NextRunResponse{
-				HasSchedule:  true,
-				CronSchedule: fn.CronSchedule,
-				CronStatus:   fn.CronStatus,
-				IsPaused:     true,
-			})
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
 			return
 		}
 
-		// Calculate next run time
-		nextRun, err := internalcron.GetNextRunFromSchedule(*fn.CronSchedule)
+		module, err := mods.Set(id, modules.Module{Name: name, Code: req.Code})
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid cron schedule")
+			writeError(w, http.StatusInternalServerError, "Failed to save module")
 			return
 		}
 
-		var nextRunUnix *int64
-		var nextRunHuman *string
-		if nextRun != nil {
-			unix := nextRun.Unix()
-			nextRunUnix = &unix
-			human := internalcron.FormatNextRun(*nextRun)
-			nextRunHuman = &human
+		writeJSON(w, http.StatusOK, module)
+	}
+}
+
+// DeleteModuleHandler returns a handler for deleting a function's Lua module
+func DeleteModuleHandler(database store.DB, mods modules.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		err := mods.Delete(id, r.PathValue("name"))
+		if errors.Is(err, modules.ErrModuleNotFound) {
+			writeError(w, http.StatusNotFound, "Module not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete module")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListVersionsHandler returns a handler for listing function versions
+func ListVersionsHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		params := parsePaginationParams(r)
+
+		// Verify function exists
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		versions, total, err := database.ListVersions(r.Context(), id, params)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list versions")
+			return
+		}
+
+		params = params.Normalize()
+		resp := PaginatedVersionsResponse{
+			Versions: versions,
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetVersionHandler returns a handler for getting a specific version
+func GetVersionHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		versionStr := r.PathValue("version")
+
+		// Parse version number
+		versionNum, err := strconv.Atoi(versionStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid version number")
+			return
+		}
+
+		version, err := database.GetVersion(r.Context(), id, versionNum)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Version not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, version)
+	}
+}
+
+// GetActiveVersionHandler returns a handler for getting a function's active version
+func GetActiveVersionHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			if errors.Is(err, store.ErrFunctionNotFound) {
+				writeError(w, http.StatusNotFound, "Function not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to get function")
+			return
+		}
+
+		version, err := database.GetActiveVersion(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, store.ErrNoActiveVersion) {
+				writeError(w, http.StatusNotFound, "No active version")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to get active version")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, version)
+	}
+}
+
+// ActivateVersionHandler returns a handler for activating a version
+func ActivateVersionHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versionID := r.PathValue("versionId")
+
+		// Activate the version
+		if err := database.ActivateVersion(r.Context(), versionID); err != nil {
+			if err == store.ErrVersionNotFound {
+				writeError(w, http.StatusNotFound, "Version not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to activate version")
+			return
+		}
+
+		version, err := database.GetVersionByID(r.Context(), versionID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get version")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, version)
+	}
+}
+
+// CreateVersionFromURLHandler returns a handler for creating a version from
+// code hosted at a URL. The fetched code becomes the active version.
+func CreateVersionFromURLHandler(database store.DB, client internalhttp.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		var req CreateVersionFromURLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := ValidateCreateVersionFromURLRequest(&req); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			if errors.Is(err, store.ErrFunctionNotFound) {
+				writeError(w, http.StatusNotFound, "Function not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to get function")
+			return
+		}
+
+		// Stop reading once the body cannot be valid code
+		fetchReq := internalhttp.Request{
+			URL:          strings.TrimSpace(req.URL),
+			Context:      r.Context(),
+			MaxBodyBytes: MaxCodeLength,
+		}
+		if req.AuthHeader != nil && *req.AuthHeader != "" {
+			fetchReq.Headers = internalhttp.Headers{"Authorization": *req.AuthHeader}
+		}
+
+		resp, err := client.Get(fetchReq)
+		var tooLarge *internalhttp.BodyTooLargeError
+		if errors.As(err, &tooLarge) {
+			writeValidationError(w, &ValidationError{
+				Field:   "code",
+				Message: fmt.Sprintf("fetched code is longer than %d bytes", MaxCodeLength),
+			})
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, fmt.Sprintf("Failed to fetch code: %v", err))
+			return
+		}
+		if !resp.IsSuccess() {
+			writeError(w, http.StatusBadGateway, fmt.Sprintf("Failed to fetch code: URL returned status %d", resp.StatusCode))
+			return
+		}
+
+		if err := validateFetchedCode(resp.Body); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		version, err := database.CreateVersion(r.Context(), id, resp.Body, nil, req.Label)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to create version")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, version)
+	}
+}
+
+// UpdateVersionHandler returns a handler for updating a version's label
+func UpdateVersionHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versionID := r.PathValue("versionId")
+
+		var req UpdateVersionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := ValidateUpdateVersionRequest(&req); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		version, err := database.UpdateVersionLabel(r.Context(), versionID, req.Label)
+		if err != nil {
+			if errors.Is(err, store.ErrVersionNotFound) {
+				writeError(w, http.StatusNotFound, "Version not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to update version")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, version)
+	}
+}
+
+// DeleteVersionHandler returns a handler for deleting a version
+func DeleteVersionHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versionID := r.PathValue("versionId")
+
+		// Delete the version
+		if err := database.DeleteVersion(r.Context(), versionID); err != nil {
+			if err == store.ErrVersionNotFound {
+				writeError(w, http.StatusNotFound, "Version not found")
+				return
+			}
+			if err == store.ErrCannotDeleteActiveVersion {
+				writeError(w, http.StatusBadRequest, "Cannot delete active version")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to delete version")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetVersionDiffHandler returns a handler for getting diff between versions
+func GetVersionDiffHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version1, version2, ok := getDiffVersions(w, r, database)
+		if !ok {
+			return
+		}
+
+		// Generate the diff using our utility function
+		diffResult := generateDiff(version1.Code, version2.Code, version1.Version, version2.Version)
+
+		writeJSON(w, http.StatusOK, diffResult)
+	}
+}
+
+// GetVersionDiffSummaryHandler returns a handler for summarizing the diff
+// between versions without the lines themselves
+func GetVersionDiffSummaryHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version1, version2, ok := getDiffVersions(w, r, database)
+		if !ok {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, generateDiffSummary(version1.Code, version2.Code, version1.Version, version2.Version))
+	}
+}
+
+// getDiffVersions loads the versions v1 and v2 of the request's path. It
+// writes the error response and returns false when either cannot be loaded.
+func getDiffVersions(w http.ResponseWriter, r *http.Request, database store.DB) (store.FunctionVersion, store.FunctionVersion, bool) {
+	id := r.PathValue("id")
+
+	// Parse version numbers
+	v1, err := strconv.Atoi(r.PathValue("v1"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid version number v1")
+		return store.FunctionVersion{}, store.FunctionVersion{}, false
+	}
+
+	v2, err := strconv.Atoi(r.PathValue("v2"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid version number v2")
+		return store.FunctionVersion{}, store.FunctionVersion{}, false
+	}
+
+	// Get both versions from the database
+	version1, err := database.GetVersion(r.Context(), id, v1)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Version v1 not found")
+		return store.FunctionVersion{}, store.FunctionVersion{}, false
+	}
+
+	version2, err := database.GetVersion(r.Context(), id, v2)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Version v2 not found")
+		return store.FunctionVersion{}, store.FunctionVersion{}, false
+	}
+
+	return version1, version2, true
+}
+
+// ListExecutionsHandler returns a handler for listing executions
+func ListExecutionsHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		params := parsePaginationParams(r)
+
+		// Verify function exists
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		filter, err := parseExecutionFilter(r)
+		if err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		if acceptsNDJSON(r) {
+			streamExecutions(w, r, database, id, filter)
+			return
+		}
+
+		executions, total, err := database.ListExecutionsFiltered(r.Context(), id, filter, params)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list executions")
+			return
+		}
+
+		params = params.Normalize()
+		resp := PaginatedExecutionsResponse{
+			Executions: executions,
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ndjsonFlushInterval is the number of records streamed between flushes
+const ndjsonFlushInterval = 100
+
+// acceptsNDJSON reports whether the client asked for newline-delimited JSON
+func acceptsNDJSON(r *http.Request) bool {
+	for part := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "application/x-ndjson") {
+			return true
+		}
+	}
+	return false
+}
+
+// streamExecutions writes every execution of a function matching filter as
+// newline-delimited JSON, newest first, as they are read from the database.
+// Pagination does not apply. Once the first record is sent the status can't
+// change, so a failure part-way ends the stream early.
+func streamExecutions(w http.ResponseWriter, r *http.Request, database store.DB, functionID string, filter store.ExecutionFilter) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	streamed := 0
+	err := database.EachExecution(r.Context(), functionID, filter, func(exec store.Execution) error {
+		if err := enc.Encode(exec); err != nil {
+			return err
+		}
+		streamed++
+		if streamed%ndjsonFlushInterval == 0 {
+			// Not every writer can flush; the records are still sent at the end
+			_ = rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to stream executions", "function_id", functionID, "streamed", streamed, "error", err)
+	}
+}
+
+// parseExecutionFilter reads the tag filters of the executions list. Each
+// tag=key:value query parameter must match; repeating a key keeps the last value.
+func parseExecutionFilter(r *http.Request) (store.ExecutionFilter, error) {
+	var filter store.ExecutionFilter
+	for _, tag := range r.URL.Query()["tag"] {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok || !runner.ValidTagKey(key) {
+			return store.ExecutionFilter{}, &ValidationError{Field: "tag", Message: fmt.Sprintf("invalid tag filter %q (use key:value)", tag)}
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(map[string]string)
+		}
+		filter.Tags[key] = value
+	}
+	return filter, nil
+}
+
+// GetExecutionHandler returns a handler for getting a specific execution
+func GetExecutionHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		execution, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, execution)
+	}
+}
+
+// GetExecutionLogsHandler returns a handler for getting execution logs
+func GetExecutionLogsHandler(database store.DB, appLogger logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		params := parsePaginationParams(r)
+
+		// Get the execution
+		execution, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		// Get the logs for this execution from the logger
+		params = params.Normalize()
+		logEntries, total := appLogger.EntriesPaginated(id, params.Limit, params.Offset)
+
+		// Convert logger.LogEntry to API LogEntry format
+		apiLogs := make([]LogEntry, len(logEntries))
+		for i, entry := range logEntries {
+			// Map logger.LogLevel (int) to API LogLevel (string)
+			var level LogLevel
+			switch entry.Level {
+			case logger.Debug:
+				level = LogLevelDebug
+			case logger.Info:
+				level = LogLevelInfo
+			case logger.Warn:
+				level = LogLevelWarn
+			case logger.Error:
+				level = LogLevelError
+			default:
+				level = LogLevelInfo
+			}
+
+			apiLogs[i] = LogEntry{
+				Level:     level,
+				Message:   entry.Message,
+				CreatedAt: entry.Timestamp,
+			}
+		}
+
+		resp := PaginatedExecutionWithLogs{
+			Execution: execution,
+			Logs:      apiLogs,
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetExecutionAIRequestsHandler returns a handler for getting AI requests for an execution
+func GetExecutionAIRequestsHandler(database store.DB, aiTracker ai.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		params := parsePaginationParams(r)
+
+		// Verify execution exists
+		_, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		// Optional filters, all empty by default
+		query := r.URL.Query()
+		filter := ai.RequestFilter{
+			Provider: query.Get("provider"),
+			Model:    query.Get("model"),
+			Status:   store.AIRequestStatus(query.Get("status")),
+		}
+		if filter.Status != "" && filter.Status != store.AIRequestStatusSuccess && filter.Status != store.AIRequestStatusError {
+			writeError(w, http.StatusBadRequest, "status must be 'success' or 'error'")
+			return
+		}
+
+		// Get AI requests for this execution
+		params = params.Normalize()
+		aiRequests, total := aiTracker.RequestsFiltered(id, filter, params.Limit, params.Offset)
+
+		resp := PaginatedAIRequestsResponse{
+			AIRequests: aiRequests,
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetExecutionHTTPRequestsHandler returns a handler for getting the outbound HTTP requests captured for an execution
+func GetExecutionHTTPRequestsHandler(database store.DB, httpTracker internalhttp.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		params := parsePaginationParams(r)
+
+		// Verify execution exists
+		_, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		// Optional filters, all empty by default
+		filter := internalhttp.RequestFilter{
+			Status: store.HTTPRequestStatus(r.URL.Query().Get("status")),
+		}
+		if filter.Status != "" && filter.Status != store.HTTPRequestStatusSuccess && filter.Status != store.HTTPRequestStatusError {
+			writeError(w, http.StatusBadRequest, "status must be 'success' or 'error'")
+			return
+		}
+
+		// Get HTTP requests for this execution
+		params = params.Normalize()
+		httpRequests, total := httpTracker.RequestsFiltered(id, filter, params.Limit, params.Offset)
+
+		resp := PaginatedHTTPRequestsResponse{
+			HTTPRequests: httpRequests,
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetExecutionEmailRequestsHandler returns a handler for getting email requests for an execution
+func GetExecutionEmailRequestsHandler(database store.DB, emailTracker email.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		params := parsePaginationParams(r)
+
+		// Verify execution exists
+		_, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		// Optional filters, all empty by default
+		filter := email.RequestFilter{
+			Status: store.EmailRequestStatus(r.URL.Query().Get("status")),
+		}
+		if filter.Status != "" && filter.Status != store.EmailRequestStatusSuccess && filter.Status != store.EmailRequestStatusError {
+			writeError(w, http.StatusBadRequest, "status must be 'success' or 'error'")
+			return
+		}
+
+		// Get email requests for this execution
+		params = params.Normalize()
+		emailRequests, total := emailTracker.RequestsFiltered(id, filter, params.Limit, params.Offset)
+
+		resp := PaginatedEmailRequestsResponse{
+			EmailRequests: emailRequests,
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetExecutionExternalCallsHandler returns a handler for getting every outbound
+// call (AI, email and HTTP) made by an execution as one chronological timeline
+func GetExecutionExternalCallsHandler(database store.DB, aiTracker ai.Tracker, emailTracker email.Tracker, httpTracker internalhttp.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		params := parsePaginationParams(r)
+
+		// Verify execution exists
+		_, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		// Optional filters, all empty by default
+		kind := store.ExternalCallKind(r.URL.Query().Get("kind"))
+		if kind != "" && kind != store.ExternalCallKindAI && kind != store.ExternalCallKindEmail && kind != store.ExternalCallKindHTTP {
+			writeError(w, http.StatusBadRequest, "kind must be 'ai', 'email' or 'http'")
+			return
+		}
+		status := r.URL.Query().Get("status")
+		if status != "" && status != "success" && status != "error" {
+			writeError(w, http.StatusBadRequest, "status must be 'success' or 'error'")
+			return
+		}
+
+		calls := slices.DeleteFunc(externalCalls(id, aiTracker, emailTracker, httpTracker), func(call store.ExternalCall) bool {
+			return (kind != "" && call.Kind != kind) || (status != "" && call.Status != status)
+		})
+
+		params = params.Normalize()
+		total := int64(len(calls))
+		start := min(params.Offset, len(calls))
+		end := min(start+params.Limit, len(calls))
+
+		resp := PaginatedExternalCallsResponse{
+			ExternalCalls: calls[start:end],
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// externalCalls returns the outbound calls of an execution in chronological
+// order, using the nanosecond timestamps the trackers record. Calls recorded
+// before those existed only carry seconds and fall back to them. Trackers left
+// unconfigured contribute nothing.
+func externalCalls(executionID string, aiTracker ai.Tracker, emailTracker email.Tracker, httpTracker internalhttp.Tracker) []store.ExternalCall {
+	var requests []store.OutboundRequest
+	if aiTracker != nil {
+		for _, req := range aiTracker.Requests(executionID) {
+			requests = append(requests, req)
+		}
+	}
+	if emailTracker != nil {
+		for _, req := range emailTracker.Requests(executionID) {
+			requests = append(requests, req)
+		}
+	}
+	if httpTracker != nil {
+		for _, req := range httpTracker.Requests(executionID) {
+			requests = append(requests, req)
+		}
+	}
+
+	calls := make([]store.ExternalCall, 0, len(requests))
+	for _, req := range requests {
+		calls = append(calls, req.ExternalCall())
+	}
+
+	// Stable so calls with equal timestamps keep their tracker order
+	slices.SortStableFunc(calls, func(a, b store.ExternalCall) int {
+		return a.Time().Compare(b.Time())
+	})
+	return calls
+}
+
+// ExplainExecutionHandler returns a handler that gathers everything known
+// about an execution for debugging: the record, the stored event and
+// response, the version that ran, a breakdown of its duration and its
+// outbound calls. Events are returned as stored, with sensitive data masked.
+func ExplainExecutionHandler(database store.DB, aiTracker ai.Tracker, emailTracker email.Tracker, httpTracker internalhttp.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		execution, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		resp := ExplainExecutionResponse{ExternalCalls: externalCalls(id, aiTracker, emailTracker, httpTracker)}
+
+		// The event and response are returned as JSON rather than strings
+		if execution.EventJSON != nil && json.Valid([]byte(*execution.EventJSON)) {
+			resp.Event = json.RawMessage(*execution.EventJSON)
+		}
+		if execution.ResponseJSON != nil && json.Valid([]byte(*execution.ResponseJSON)) {
+			resp.Response = json.RawMessage(*execution.ResponseJSON)
+		}
+		spans := execution.Spans
+		execution.EventJSON = nil
+		execution.ResponseJSON = nil
+		execution.Spans = nil
+		resp.Execution = execution
+
+		// The version may have been deleted since
+		if version, err := database.GetVersionByID(r.Context(), execution.FunctionVersionID); err == nil {
+			resp.Version = &version
+		}
+
+		resp.Timing = ExecutionTiming{DurationMs: execution.DurationMs, Spans: spans}
+		for _, call := range resp.ExternalCalls {
+			resp.Timing.ExternalMs += call.DurationMs
+		}
+		if execution.DurationMs != nil {
+			// Outbound calls made concurrently can add up to more than the run
+			functionMs := max(*execution.DurationMs-resp.Timing.ExternalMs, 0)
+			resp.Timing.FunctionMs = &functionMs
+		}
+		if execution.ScheduledAt != nil {
+			delay := execution.CreatedAt - *execution.ScheduledAt
+			resp.Timing.DelaySeconds = &delay
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ExecutionAsCurlHandler returns a handler that reconstructs the request of
+// an HTTP execution as a curl command against baseURL, so it can be
+// reproduced locally. Values masked when the event was stored are left as
+// placeholders and listed in the response.
+func ExecutionAsCurlHandler(database store.DB, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		execution, err := database.GetExecution(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		var event events.HTTPEvent
+		if execution.EventJSON == nil || json.Unmarshal([]byte(*execution.EventJSON), &event) != nil || event.Method == "" {
+			writeError(w, http.StatusUnprocessableEntity, "Execution has no stored HTTP request")
+			return
+		}
+
+		if baseURL == "" {
+			baseURL = clientScheme(r) + "://" + r.Host
+		}
+		command, redacted := curlCommand(baseURL, event)
+		writeJSON(w, http.StatusOK, CurlCommandResponse{Command: command, Redacted: redacted})
+	}
+}
+
+// GetMaintenanceHandler returns a handler for reading the maintenance mode
+func GetMaintenanceHandler(mode *maintenance.Mode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, maintenanceResponse(mode))
+	}
+}
+
+// UpdateMaintenanceHandler returns a handler for turning maintenance mode on or off
+func UpdateMaintenanceHandler(mode *maintenance.Mode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req UpdateMaintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if req.Enabled == nil {
+			writeValidationError(w, &ValidationError{Field: "enabled", Message: "enabled is required"})
+			return
+		}
+
+		mode.Set(*req.Enabled)
+		slog.Info("Maintenance mode changed", "enabled", *req.Enabled)
+
+		writeJSON(w, http.StatusOK, maintenanceResponse(mode))
+	}
+}
+
+// maintenanceResponse describes the current maintenance mode
+func maintenanceResponse(mode *maintenance.Mode) MaintenanceResponse {
+	return MaintenanceResponse{
+		Enabled:           mode.Enabled(),
+		RetryAfterSeconds: int(mode.RetryAfter().Seconds()),
+	}
+}
+
+// GetIntegrationsHandler returns a handler for reading which outbound integrations are enabled
+func GetIntegrationsHandler(sw *killswitch.Switch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, integrationsResponse(sw))
+	}
+}
+
+// UpdateIntegrationsHandler returns a handler for switching outbound integrations on or off
+func UpdateIntegrationsHandler(sw *killswitch.Switch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req UpdateIntegrationsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		updates := map[killswitch.Integration]*bool{
+			killswitch.HTTP:  req.HTTP,
+			killswitch.AI:    req.AI,
+			killswitch.Email: req.Email,
+		}
+		for integration, enabled := range updates {
+			if enabled == nil {
+				continue
+			}
+			sw.Set(integration, !*enabled)
+			slog.Info("Outbound integration changed", "integration", integration, "enabled", *enabled)
+		}
+
+		writeJSON(w, http.StatusOK, integrationsResponse(sw))
+	}
+}
+
+// ListProvidersHandler returns a handler for listing the known providers and
+// the environment variables they read
+func ListProvidersHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ProvidersResponse{Providers: Providers})
+	}
+}
+
+// FormatLuaHandler returns a handler that formats Lua code. Code that does
+// not compile is rejected, since it cannot be formatted reliably.
+func FormatLuaHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LuaCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := ValidateLuaCodeRequest(&req); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		formatted, err := luacode.Format(req.Code)
+		if err != nil {
+			writeValidationError(w, checkSyntax("code", req.Code))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, FormatLuaResponse{Code: formatted, Changed: formatted != req.Code})
+	}
+}
+
+// LintLuaHandler returns a handler that reports likely mistakes in Lua code,
+// including syntax errors, as findings with line numbers
+func LintLuaHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LuaCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := ValidateLuaCodeRequest(&req); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		findings := luacode.Lint(req.Code, luacode.Globals)
+		if findings == nil {
+			findings = []luacode.Finding{}
+		}
+		writeJSON(w, http.StatusOK, LintLuaResponse{Findings: findings})
+	}
+}
+
+// IntegrationsStatusHandler returns a handler reporting, for each known
+// provider, whether the function has the environment variables it needs
+func IntegrationsStatusHandler(database store.DB, envStore env.Store, sw *killswitch.Switch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		envVars, err := envStore.All(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get env vars")
+			return
+		}
+
+		resp := IntegrationsStatusResponse{Providers: make([]ProviderStatus, len(Providers))}
+		for i, provider := range Providers {
+			status := ProviderStatus{
+				Name:        provider.Name,
+				Integration: provider.Integration,
+				Enabled:     !sw.Disabled(provider.Integration),
+				Ready:       true,
+				EnvKeys:     make(map[string]bool, len(provider.EnvKeys)),
+			}
+			for _, envKey := range provider.EnvKeys {
+				set := envVars[envKey.Name] != ""
+				status.EnvKeys[envKey.Name] = set
+				if envKey.Required && !set {
+					status.Ready = false
+				}
+			}
+			resp.Providers[i] = status
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// integrationsResponse describes the current state of the outbound integrations
+func integrationsResponse(sw *killswitch.Switch) IntegrationsResponse {
+	return IntegrationsResponse{
+		HTTP:  !sw.Disabled(killswitch.HTTP),
+		AI:    !sw.Disabled(killswitch.AI),
+		Email: !sw.Disabled(killswitch.Email),
+	}
+}
+
+// GetConfigHandler returns a handler for reading the server's effective limits
+// and enabled integrations. It exposes no secrets.
+func GetConfigHandler(executionTimeout time.Duration, maxLogEntries int, sw *killswitch.Switch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ConfigResponse{
+			ExecutionTimeoutSeconds: int(executionTimeout.Seconds()),
+			MaxCodeLength:           MaxCodeLength,
+			MaxFunctionNameLength:   MaxFunctionNameLength,
+			MaxDescriptionLength:    MaxDescriptionLength,
+			MaxEnvVars:              MaxEnvVars,
+			MaxPageSize:             MaxPageSize,
+			MaxBatchOperations:      MaxBatchOperations,
+			MaxLogEntries:           maxLogEntries,
+			AllowedRetentionDays:    AllowedRetentionDays,
+			Integrations:            integrationsResponse(sw),
+		})
+	}
+}
+
+// GetQueueHandler returns a handler for reading the execution queue depth and limits
+func GetQueueHandler(q *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := q.Stats()
+		writeJSON(w, http.StatusOK, QueueResponse{
+			Running:       stats.Running,
+			Queued:        stats.Queued,
+			MaxConcurrent: stats.MaxConcurrent,
+			MaxQueued:     stats.MaxQueued,
+			Rejected:      stats.Rejected,
+		})
+	}
+}
+
+// ExecuteFunctionHandler returns a handler for executing functions
+func ExecuteFunctionHandler(deps ExecuteFunctionDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		functionID := r.PathValue("function_id")
+		executeFunction(w, r, deps, functionID, "/fn/"+functionID)
+	}
+}
+
+// TestFunctionHandler returns a handler that executes a function in a sandbox.
+// Outbound HTTP requests, AI chats and emails are answered by fakes instead of
+// being sent, and are returned as side effects alongside the function's response.
+// HTTP requests matching one of the request's fixtures get its canned response.
+func TestFunctionHandler(deps ExecuteFunctionDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// An empty body tests a GET of the function's root
+		var req TestFunctionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := ValidateTestFunctionRequest(&req); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		if deps.Maintenance.Enabled() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(deps.Maintenance.RetryAfter().Seconds())))
+			writeError(w, http.StatusServiceUnavailable, "Service is in maintenance mode")
+			return
+		}
+
+		release, err := deps.Queue.Acquire(r.Context())
+		if errors.Is(err, queue.ErrFull) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, "Execution queue is full")
+			return
+		}
+		if err != nil {
+			return
+		}
+		defer release()
+
+		// Build the event the function would receive at /fn/{id}
+		httpEvent := events.HTTPEvent{
+			Method:       req.Method,
+			Path:         "/fn/" + id,
+			RelativePath: req.Path,
+			Headers:      make(map[string]string),
+			Body:         req.Body,
+			Query:        make(map[string]string),
+		}
+		if req.Path != "/" {
+			httpEvent.Path += req.Path
+		}
+		maps.Copy(httpEvent.Headers, req.Headers)
+		maps.Copy(httpEvent.Query, req.Query)
+
+		sandbox := engine.NewSandbox()
+		for _, fixture := range req.HTTPFixtures {
+			headers := internalhttp.Headers{}
+			maps.Copy(headers, fixture.Headers)
+			sandbox.HTTP.SetResponse(fixture.Method, fixture.URL, internalhttp.Response{
+				StatusCode: fixture.StatusCode,
+				Headers:    headers,
+				Body:       fixture.Body,
+			})
+		}
+		result, err := deps.Engine.Execute(r.Context(), engine.ExecutionRequest{
+			FunctionID:     id,
+			Event:          httpEvent,
+			Trigger:        store.ExecutionTriggerTest,
+			BaseURL:        deps.BaseURL,
+			Sandbox:        sandbox,
+			EnvOverrides:   req.EnvOverrides,
+			Seed:           req.Seed,
+			FrozenTime:     req.FrozenTime,
+			ValidateOutput: true,
+			ClientIP:       clientIP(r),
+			RequestID:      RequestIDFromContext(r.Context()),
+		})
+		if err != nil {
+			handleEngineError(w, err)
+			return
+		}
+
+		resp := TestFunctionResponse{
+			ExecutionID:  result.ExecutionID,
+			Status:       result.Status,
+			DurationMs:   result.Duration.Milliseconds(),
+			Response:     result.Response,
+			OutputErrors: result.OutputErrors,
+			SideEffects:  sandboxSideEffects(sandbox),
+		}
+		if result.Error != nil {
+			resp.Error = result.Error.Error()
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// SelfTestHandler returns a handler that checks a function is basically
+// working, for monitoring. It runs the function in a sandbox against a GET of
+// its root without recording an execution, and passes when the function
+// returns a response with a valid status code and a body that can be sent.
+func SelfTestHandler(deps ExecuteFunctionDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		if deps.Maintenance.Enabled() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(deps.Maintenance.RetryAfter().Seconds())))
+			writeError(w, http.StatusServiceUnavailable, "Service is in maintenance mode")
+			return
+		}
+
+		release, err := deps.Queue.Acquire(r.Context())
+		if errors.Is(err, queue.ErrFull) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, "Execution queue is full")
+			return
+		}
+		if err != nil {
+			return
+		}
+		defer release()
+
+		result, err := deps.Engine.Execute(r.Context(), engine.ExecutionRequest{
+			FunctionID: id,
+			Event: events.HTTPEvent{
+				Method:       "GET",
+				Path:         "/fn/" + id,
+				RelativePath: "/",
+				Headers:      make(map[string]string),
+				Query:        make(map[string]string),
+			},
+			Trigger:   store.ExecutionTriggerManual,
+			BaseURL:   deps.BaseURL,
+			Sandbox:   engine.NewSandbox(),
+			DryRun:    true,
+			ClientIP:  clientIP(r),
+			RequestID: RequestIDFromContext(r.Context()),
+		})
+		if err != nil {
+			handleEngineError(w, err)
+			return
+		}
+
+		resp := SelfTestResponse{DurationMs: result.Duration.Milliseconds()}
+		if result.Response != nil {
+			resp.StatusCode = result.Response.StatusCode
+		}
+		if result.Error != nil {
+			resp.Error = result.Error.Error()
+		} else if err := checkResponseShape(result.Response); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Passed = true
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// checkResponseShape reports why a function's response could not be sent to
+// a client: a missing response, an invalid status code or a base64 body that
+// does not decode
+func checkResponseShape(resp *events.HTTPResponse) error {
+	if resp == nil {
+		return errors.New("function returned no response")
+	}
+	if resp.StatusCode < 100 || resp.StatusCode > 599 {
+		return fmt.Errorf("function returned invalid status code %d", resp.StatusCode)
+	}
+	if resp.IsBase64Encoded {
+		if _, err := base64.StdEncoding.DecodeString(resp.Body); err != nil {
+			return errors.New("function returned a body marked as base64 that is not valid base64")
+		}
+	}
+	return nil
+}
+
+// sandboxSideEffects lists the calls recorded by the fakes of a sandbox
+func sandboxSideEffects(sandbox *engine.Sandbox) TestSideEffects {
+	effects := TestSideEffects{
+		HTTP:   make([]TestHTTPCall, 0, len(sandbox.HTTP.Requests)),
+		AI:     make([]TestAICall, 0, len(sandbox.AI.Requests)),
+		Emails: make([]TestEmail, 0, len(sandbox.Email.Requests)),
+	}
+	for _, req := range sandbox.HTTP.Requests {
+		effects.HTTP = append(effects.HTTP, TestHTTPCall{
+			Method:  req.Method,
+			URL:     req.URL,
+			Headers: req.Headers,
+			Query:   req.Query,
+			Body:    req.Body,
+		})
+	}
+	for _, req := range sandbox.AI.Requests {
+		effects.AI = append(effects.AI, TestAICall{
+			Provider: req.Provider,
+			Model:    req.Model,
+			Messages: req.Messages,
+		})
+	}
+	for _, req := range sandbox.Email.Requests {
+		effects.Emails = append(effects.Emails, TestEmail{
+			From:    req.From,
+			To:      req.To,
+			Cc:      req.Cc,
+			Bcc:     req.Bcc,
+			Subject: req.Subject,
+			Text:    req.Text,
+			HTML:    req.HTML,
+		})
+	}
+	return effects
+}
+
+// PrefixRouteHandler returns a handler that executes the function owning the
+// longest route prefix matching the request path. Requests that match no
+// prefix are passed to next.
+func PrefixRouteHandler(deps ExecuteFunctionDeps, routes *RouteTable, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		functionID, prefix, ok := routes.Match(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		executeFunction(w, r, deps, functionID, prefix)
+	}
+}
+
+// executeFunction runs a function for an HTTP request. The pathPrefix is the
+// part of the request path that selected the function and is stripped to
+// compute the event's relative path.
+func executeFunction(w http.ResponseWriter, r *http.Request, deps ExecuteFunctionDeps, functionID, pathPrefix string) {
+	// Lookup failures are left to the engine, which reports them consistently
+	fn, _ := deps.DB.GetFunction(r.Context(), functionID)
+
+	// Answer CORS preflight requests unless the function handles OPTIONS itself
+	if r.Method == http.MethodOptions && !fn.HandlesOptions() {
+		preflightHandler(w, r)
+		return
+	}
+
+	// Reject executions while the server is in maintenance mode
+	if deps.Maintenance.Enabled() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(deps.Maintenance.RetryAfter().Seconds())))
+		writeError(w, http.StatusServiceUnavailable, "Service is in maintenance mode")
+		return
+	}
+
+	// Enforce the function's allowed methods before invoking the engine. The
+	// cron scheduler always sends POST, so its calls are exempt.
+	if !fn.AllowsMethod(r.Method) && !isCronScheduler(r, deps.CronToken) {
+		w.Header().Set("Allow", strings.Join(fn.AllowedMethods, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Parse HTTP event from request
+	httpEvent, err := parseHTTPEvent(r, pathPrefix)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	// Functions with a signing secret only accept signed requests
+	if ok, reason := verifyRequestSignature(r, fn, httpEvent.Body, deps); !ok {
+		slog.Warn("Rejected request signature", "function_id", functionID, "reason", reason)
+		writeError(w, http.StatusUnauthorized, "Invalid request signature: "+reason)
+		return
+	}
+
+	// Wait for an execution slot, shedding load once the queue is full.
+	// WebSocket sessions hold theirs until the connection closes.
+	release, err := deps.Queue.Acquire(r.Context())
+	if errors.Is(err, queue.ErrFull) {
+		slog.Warn("Execution rejected, queue is full", "function_id", functionID)
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, "Execution queue is full")
+		return
+	}
+	if err != nil {
+		// The client went away while waiting for a slot
+		return
+	}
+	defer release()
+
+	// Hand upgrade requests to the WebSocket path when the function opted in
+	if fn.WebSocketEnabled && !fn.Disabled && isWebSocketUpgrade(r) {
+		serveWebSocket(w, r, deps, functionID, httpEvent)
+		return
+	}
+
+	trigger, err := executionTrigger(r, deps)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Execute via engine
+	stream := newSSEStream(w, functionID, fn.DefaultHeaders)
+	result, err := deps.Engine.Execute(r.Context(), engine.ExecutionRequest{
+		FunctionID:  functionID,
+		Event:       httpEvent,
+		Trigger:     trigger,
+		ScheduledAt: cronScheduledAt(r, trigger),
+		BaseURL:     deps.BaseURL,
+		EventStream: stream,
+		Trace:       traceRequested(r, deps.APIKey),
+		ClientIP:    clientIP(r),
+		RequestID:   RequestIDFromContext(r.Context()),
+	})
+
+	// Once events were streamed the response is committed and nothing else can be written
+	if stream.Started() {
+		return
+	}
+
+	// Handle engine errors
+	if err != nil {
+		handleEngineError(w, err)
+		return
+	}
+
+	// Set execution metadata headers
+	w.Header().Set("X-Function-Id", functionID)
+	w.Header().Set("X-Function-Version-Id", result.FunctionVersionID)
+	w.Header().Set("X-Execution-Id", result.ExecutionID)
+	w.Header().Set("X-Execution-Duration-Ms", strconv.FormatInt(result.Duration.Milliseconds(), 10))
+
+	// Handle execution errors
+	if result.Error != nil {
+		slog.Error("Function execution failed",
+			"execution_id", result.ExecutionID,
+			"function_id", functionID,
+			"error", result.Error)
+		// A function that ran out of time is told apart from one that failed
+		if errors.As(result.Error, new(*engine.TimeoutError)) {
+			handleEngineError(w, result.Error)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Function execution failed")
+		return
+	}
+
+	// Write HTTP response
+	writeExecutionResponse(w, r, result, fn.DefaultHeaders)
+}
+
+// executionTrigger determines how an execution was triggered from the X-Trigger
+// header, defaulting to http. Unknown values are rejected. Privileged triggers
+// (cron, manual) are only honored for authenticated callers, and cron also for
+// the scheduler, so public requests cannot skew analytics by claiming them;
+// they are recorded as http instead.
+func executionTrigger(r *http.Request, deps ExecuteFunctionDeps) (store.ExecutionTrigger, error) {
+	value := r.Header.Get("X-Trigger")
+	if value == "" {
+		return store.ExecutionTriggerHTTP, nil
+	}
+
+	// Test runs are only started by the test endpoint, never claimed
+	trigger := store.ExecutionTrigger(strings.ToLower(value))
+	if !trigger.Valid() || trigger == store.ExecutionTriggerTest {
+		return "", &ValidationError{Field: "X-Trigger", Message: fmt.Sprintf("unknown trigger %q (use http, cron or manual)", value)}
+	}
+	if trigger == store.ExecutionTriggerCron && isCronScheduler(r, deps.CronToken) {
+		return trigger, nil
+	}
+	if trigger.Privileged() && !isAuthenticated(r, deps.APIKey) {
+		return store.ExecutionTriggerHTTP, nil
+	}
+	return trigger, nil
+}
+
+// isCronScheduler reports whether the request carries the cron scheduler's
+// token in X-Cron-Token
+func isCronScheduler(r *http.Request, token string) bool {
+	return token != "" && isValidAPIKey(r.Header.Get(internalcron.HeaderCronToken), token)
+}
+
+// cronScheduledAt returns the time a cron execution was scheduled for, sent by
+// the scheduler in X-Cron-Scheduled-Time. It is nil for other triggers, which
+// executionTrigger only grants to authenticated callers, and for bad values.
+func cronScheduledAt(r *http.Request, trigger store.ExecutionTrigger) *int64 {
+	if trigger != store.ExecutionTriggerCron {
+		return nil
+	}
+	scheduledAt, err := strconv.ParseInt(r.Header.Get(internalcron.HeaderCronScheduledTime), 10, 64)
+	if err != nil || scheduledAt <= 0 {
+		return nil
+	}
+	return &scheduledAt
+}
+
+// verifyRequestSignature reports whether a request to fn may run. When fn has a
+// signing secret, the X-Signature header must hold the HMAC of the X-Timestamp
+// header and body, and the timestamp must be recent, limiting replays.
+// Authenticated callers (the dashboard, the cron scheduler) skip the check.
+// When the request is rejected, reason explains why.
+func verifyRequestSignature(r *http.Request, fn store.Function, body string, deps ExecuteFunctionDeps) (ok bool, reason string) {
+	if fn.SigningSecret == nil || isAuthenticated(r, deps.APIKey) || isCronScheduler(r, deps.CronToken) {
+		return true, ""
+	}
+	return crypto.VerifyRequest(
+		r.Header.Get(crypto.SignatureHeader), r.Header.Get(crypto.TimestampHeader),
+		body, *fn.SigningSecret, time.Now(), crypto.DefaultSignatureTolerance,
+	)
+}
+
+// traceRequested reports whether the caller asked for the execution to be
+// traced through the X-Trace header. Traces log the arguments of every stdlib
+// call, so only authenticated callers may ask for them; public requests
+// claiming it run untraced.
+func traceRequested(r *http.Request, apiKey string) bool {
+	trace, _ := strconv.ParseBool(r.Header.Get("X-Trace"))
+	return trace && isAuthenticated(r, apiKey)
+}
+
+// parseHTTPEvent creates an HTTPEvent from an HTTP request. The relative path
+// is computed by stripping pathPrefix (e.g. /fn/{function_id}) from the request path.
+func parseHTTPEvent(r *http.Request, pathPrefix string) (events.HTTPEvent, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return events.HTTPEvent{}, err
+	}
+
+	// Compute relativePath by stripping the routing prefix
+	relativePath := strings.TrimPrefix(r.URL.Path, pathPrefix)
+	if relativePath == "" {
+		relativePath = "/"
+	}
+
+	httpEvent := events.HTTPEvent{
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		RelativePath: relativePath,
+		Headers:      make(map[string]string),
+		Body:         string(body),
+		Query:        make(map[string]string),
+		RemoteIP:     clientIP(r),
+		Scheme:       clientScheme(r),
+	}
+
+	// Copy headers, keeping every value of repeated ones in HeadersAll. The
+	// scheduler's token is a credential, so functions never see it.
+	httpEvent.HeadersAll = make(map[string][]string, len(r.Header))
+	for key, values := range r.Header {
+		if len(values) > 0 && key != internalcron.HeaderCronToken {
+			httpEvent.Headers[key] = values[0]
+			httpEvent.HeadersAll[key] = slices.Clone(values)
+		}
+	}
+
+	// Copy query parameters, keeping every value of repeated ones in QueryAll
+	query := r.URL.Query()
+	httpEvent.QueryAll = make(map[string][]string, len(query))
+	for key, values := range query {
+		if len(values) > 0 {
+			httpEvent.Query[key] = values[0]
+			httpEvent.QueryAll[key] = values
+		}
+	}
+
+	return httpEvent, nil
+}
+
+// handleEngineError writes the appropriate HTTP error for engine errors
+func handleEngineError(w http.ResponseWriter, err error) {
+	var fnNotFound *engine.FunctionNotFoundError
+	var fnDisabled *engine.FunctionDisabledError
+	var noVersion *engine.NoActiveVersionError
+	var invalidTrigger *engine.InvalidTriggerError
+	var timeout *engine.TimeoutError
+	var denied *engine.PolicyDeniedError
+	var invalidInput *engine.InvalidInputError
+
+	switch {
+	case errors.As(err, &fnNotFound):
+		writeError(w, http.StatusNotFound, "Function not found")
+	case errors.As(err, &fnDisabled):
+		writeError(w, http.StatusForbidden, "Function is disabled")
+	case errors.As(err, &noVersion):
+		writeError(w, http.StatusInternalServerError, "No active version found")
+	case errors.As(err, &invalidTrigger):
+		writeError(w, http.StatusBadRequest, invalidTrigger.Error())
+	case errors.As(err, &timeout):
+		writeError(w, http.StatusGatewayTimeout, "Function execution timed out after "+timeout.Timeout.String())
+	case errors.As(err, &denied):
+		status := denied.StatusCode
+		if status < 400 || status > 599 {
+			status = http.StatusForbidden
+		}
+		writeError(w, status, denied.Message)
+	case errors.As(err, &invalidInput):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Request body does not match the input schema",
+			Errors: invalidInput.Errors,
+		})
+	default:
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// writeExecutionResponse writes the function's HTTP response to the client.
+// The function's default headers are applied first so that headers returned
+// by the function override them. When a GET or HEAD gets a 2xx response
+// whose etag matches the request's If-None-Match header, a 304 Not Modified
+// is written without a body. Returned headers that could split the response fail with a
+// 500, and hop-by-hop headers are dropped. Bodies marked isBase64Encoded are
+// decoded and default to application/octet-stream instead of JSON.
+func writeExecutionResponse(w http.ResponseWriter, r *http.Request, result *engine.ExecutionResult, defaultHeaders map[string]string) {
+	if result.Response == nil {
+		writeError(w, http.StatusInternalServerError, "Function did not return HTTP response")
+		return
+	}
+
+	headers, err := sanitizeResponseHeaders(result.Response.Headers)
+	if err != nil {
+		slog.Error("Function returned invalid response headers",
+			"execution_id", result.ExecutionID,
+			"error", err)
+		writeError(w, http.StatusInternalServerError, "Function returned invalid response headers")
+		return
+	}
+
+	body := []byte(result.Response.Body)
+	defaultContentType := "application/json"
+	if result.Response.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(result.Response.Body)
+		if err != nil {
+			slog.Error("Function returned an invalid base64 body",
+				"execution_id", result.ExecutionID,
+				"error", err)
+			writeError(w, http.StatusInternalServerError, "Function returned an invalid base64 body")
+			return
+		}
+		defaultContentType = "application/octet-stream"
+	}
+
+	// Set default headers configured on the function
+	for key, value := range defaultHeaders {
+		w.Header().Set(key, value)
+	}
+
+	// Set custom headers from function response
+	for key, value := range headers {
+		w.Header().Set(key, value)
+	}
+
+	// Set the status code
+	statusCode := result.Response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	// Handle conditional requests. Only successful reads can be answered
+	// with 304; errors and other methods always send their response.
+	if result.Response.ETag != "" {
+		etag := quoteETag(result.Response.ETag)
+		w.Header().Set("ETag", etag)
+
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+		success := statusCode >= 200 && statusCode < 300
+		if safe && success && etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Only set default Content-Type if the function didn't provide one and
+	// there is a body to describe, so redirects and other empty responses
+	// go out without one
+	if len(body) > 0 && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", defaultContentType)
+	}
+
+	// HEAD responses keep the headers of the full response, including its
+	// length, and drop the body
+	if r.Method == http.MethodHead {
+		if w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+// quoteETag wraps an entity tag in double quotes unless it is already quoted
+func quoteETag(etag string) string {
+	if strings.HasSuffix(etag, `"`) && (strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`)) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches the given
+// entity tag using weak comparison, as required by RFC 9110.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	target := strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == target {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyEnvVarsHandler returns a handler for copying environment variables from
+// another function. The "mode" query parameter selects "merge" (default), which
+// keeps target variables that the source does not define, or "replace", which
+// makes the target's variables an exact copy of the source's. The result is
+// written with a single SetAll, so a failure leaves the target unchanged.
+// Env vars carry no secret flag, so there is no option to skip secrets: every
+// variable of the source is copied.
+func CopyEnvVarsHandler(database store.DB, envStore env.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		sourceID := r.PathValue("sourceId")
+
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = "merge"
+		}
+		if mode != "merge" && mode != "replace" {
+			writeError(w, http.StatusBadRequest, "mode must be one of: merge, replace")
+			return
+		}
+
+		if id == sourceID {
+			writeError(w, http.StatusBadRequest, "Cannot copy env vars from the same function")
+			return
+		}
+
+		// Verify both functions exist
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+		if _, err := database.GetFunction(r.Context(), sourceID); err != nil {
+			writeError(w, http.StatusNotFound, "Source function not found")
+			return
+		}
+
+		sourceEnvVars, err := envStore.All(sourceID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get source env vars")
+			return
+		}
+
+		currentEnvVars, err := envStore.All(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get current env vars")
+			return
+		}
+
+		// Compute the resulting set of env vars before touching the store
+		result := make(map[string]string, len(currentEnvVars)+len(sourceEnvVars))
+		if mode == "merge" {
+			maps.Copy(result, currentEnvVars)
+		}
+		maps.Copy(result, sourceEnvVars)
+
+		if len(result) > MaxEnvVars {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot have more than %d environment variables", MaxEnvVars))
+			return
+		}
+
+		if err := envStore.SetAll(id, result); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to update env vars")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, EnvVarsResponse{EnvVars: result})
+	}
+}
+
+// GetNextRunHandler returns a handler for getting the next scheduled run time
+func GetNextRunHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// Get the function
+		fn, err := database.GetFunction(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		// Check if the function has an active cron schedule
+		if fn.CronSchedule == nil || *fn.CronSchedule == "" {
+			writeJSON(w, http.StatusOK, NextRunResponse{
+				HasSchedule: false,
+			})
+			return
+		}
+
+		if fn.CronStatus == nil || *fn.CronStatus != string(store.CronStatusActive) {
+			writeJSON(w, http.StatusOK, NextRunResponse{
+				HasSchedule:  true,
+				CronSchedule: fn.CronSchedule,
+				CronStatus:   fn.CronStatus,
+				IsPaused:     true,
+			})
+			return
+		}
+
+		// Calculate next run time
+		nextRun, err := internalcron.GetNextRunFromSchedule(*fn.CronSchedule)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid cron schedule")
+			return
+		}
+
+		var nextRunUnix *int64
+		var nextRunHuman *string
+		if nextRun != nil {
+			unix := nextRun.Unix()
+			nextRunUnix = &unix
+			human := internalcron.FormatNextRun(*nextRun)
+			nextRunHuman = &human
 		}
 
 		writeJSON(w, http.StatusOK, NextRunResponse{
@@ -816,3 +2642,551 @@ func GetNextRunHandler(database store.DB) http.HandlerFunc {
 		})
 	}
 }
+
+// DefaultUsageWindow is the period covered by the AI usage report when no window is given
+const DefaultUsageWindow = "30d"
+
+// MinUsageWindow and MaxUsageWindow bound the period the AI usage report can cover
+const (
+	MinUsageWindow = time.Hour
+	MaxUsageWindow = 366 * 24 * time.Hour
+)
+
+// GetAIUsageHandler returns a handler for a function's AI token usage and estimated cost
+func GetAIUsageHandler(database store.DB, reporter ai.UsageReporter, prices ai.PriceTable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		if reporter == nil {
+			writeError(w, http.StatusNotImplemented, "AI usage reporting is not available")
+			return
+		}
+
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		window := r.URL.Query().Get("window")
+		if window == "" {
+			window = DefaultUsageWindow
+		}
+		duration, err := parseUsageWindow(window)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		since := time.Now().Add(-duration).Unix()
+		usage, err := reporter.Usage(id, since)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get AI usage")
+			return
+		}
+
+		resp := AIUsageResponse{
+			FunctionID: id,
+			Window:     window,
+			Since:      since,
+			Usage:      usage,
+		}
+		for i := range resp.Usage {
+			u := &resp.Usage[i]
+			resp.TotalRequests += u.Requests
+			resp.TotalInputTokens += u.InputTokens
+			resp.TotalOutputTokens += u.OutputTokens
+
+			if cost, ok := prices.Cost(u.Provider, u.Model, u.InputTokens, u.OutputTokens); ok {
+				u.EstimatedCostUSD = &cost
+				resp.EstimatedCostUSD += cost
+			} else {
+				resp.UnpricedModels = append(resp.UnpricedModels, u.Provider+"/"+u.Model)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// Metrics timeseries defaults and limits
+const (
+	DefaultMetricsWindow = "24h"
+	DefaultMetricsBucket = "1h"
+	MinMetricsBucket     = time.Minute
+	MaxMetricsBuckets    = 1000
+)
+
+// GetMetricsTimeseriesHandler returns a handler for a function's execution
+// counts and durations bucketed over time, for dashboard charts. Buckets are
+// aligned to multiples of the bucket size and gaps are returned empty.
+func GetMetricsTimeseriesHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		if _, err := database.GetFunction(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+
+		window := r.URL.Query().Get("window")
+		if window == "" {
+			window = DefaultMetricsWindow
+		}
+		windowDuration, err := parseUsageWindow(window)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		bucket := r.URL.Query().Get("bucket")
+		if bucket == "" {
+			bucket = DefaultMetricsBucket
+		}
+		bucketDuration, err := time.ParseDuration(bucket)
+		if err != nil || bucketDuration < MinMetricsBucket || bucketDuration%time.Second != 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("bucket must be a whole number of seconds and at least %s", MinMetricsBucket))
+			return
+		}
+		if bucketDuration > windowDuration {
+			writeError(w, http.StatusBadRequest, "bucket cannot be longer than window")
+			return
+		}
+
+		count := int64((windowDuration + bucketDuration - 1) / bucketDuration)
+		if count > MaxMetricsBuckets {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("window and bucket give more than %d buckets", MaxMetricsBuckets))
+			return
+		}
+
+		// The last bucket holds the current time
+		size := int64(bucketDuration / time.Second)
+		until := (time.Now().Unix()/size + 1) * size
+		since := until - count*size
+
+		buckets, err := database.ExecutionTimeseries(r.Context(), id, since, until, size)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get metrics")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, MetricsTimeseriesResponse{
+			FunctionID: id,
+			Window:     window,
+			Bucket:     bucket,
+			Since:      since,
+			Until:      until,
+			Buckets:    buckets,
+		})
+	}
+}
+
+// DefaultRecentErrorsWindow is how far back the recent errors feed looks by default
+const DefaultRecentErrorsWindow = "1h"
+
+// ListRecentErrorsHandler returns a handler for the failed executions of all
+// functions within a window, newest first, with their error messages
+func ListRecentErrorsHandler(database store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := parsePaginationParams(r)
+
+		window := r.URL.Query().Get("window")
+		if window == "" {
+			window = DefaultRecentErrorsWindow
+		}
+		windowDuration, err := parseUsageWindow(window)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		since := time.Now().Add(-windowDuration).Unix()
+		failures, total, err := database.ListRecentErrors(r.Context(), since, params)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list recent errors")
+			return
+		}
+
+		params = params.Normalize()
+		writeJSON(w, http.StatusOK, RecentErrorsResponse{
+			Window: window,
+			Since:  since,
+			Errors: failures,
+			Pagination: store.PaginationInfo{
+				Total:  total,
+				Limit:  params.Limit,
+				Offset: params.Offset,
+			},
+		})
+	}
+}
+
+// BackupHandler returns a handler that downloads a backup of the database.
+// By default it streams a copy of the SQLite file, written to a temporary file
+// first so a slow download does not hold the database; with ?format=json it
+// returns a partial, logical export of the functions, their env vars and
+// versions instead.
+func BackupHandler(database store.DB, envStore env.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UTC()
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "sqlite":
+		case "json":
+			export, err := exportFunctions(r.Context(), database, envStore)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to export functions")
+				return
+			}
+			export.ExportedAt = now.Unix()
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lunar-export-%s.json"`, now.Format("20060102T150405Z")))
+			writeJSON(w, http.StatusOK, export)
+			return
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid format %q, want sqlite or json", format))
+			return
+		}
+
+		backuper, ok := database.(store.Backuper)
+		if !ok {
+			writeError(w, http.StatusNotImplemented, "Database backups are not supported by this store")
+			return
+		}
+
+		dir, err := os.MkdirTemp("", "lunar-backup-")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to back up database")
+			return
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		path := filepath.Join(dir, "lunar.db")
+		if err := backuper.Backup(r.Context(), path); err != nil {
+			slog.Error("Failed to back up database", "error", err)
+			writeError(w, http.StatusInternalServerError, "Failed to back up database")
+			return
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to back up database")
+			return
+		}
+		defer func() { _ = file.Close() }()
+		info, err := file.Stat()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to back up database")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lunar-backup-%s.db"`, now.Format("20060102T150405Z")))
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, file)
+	}
+}
+
+// exportFunctions collects every function with its env vars and all of its versions
+func exportFunctions(ctx context.Context, database store.DB, envStore env.Store) (ExportResponse, error) {
+	export := ExportResponse{Functions: []FunctionExport{}}
+	params := store.PaginationParams{Limit: 100}
+	for {
+		functions, total, err := database.ListFunctions(ctx, params)
+		if err != nil {
+			return ExportResponse{}, err
+		}
+		for _, fn := range functions {
+			versions, err := listAllVersions(ctx, database, fn.ID)
+			if err != nil {
+				return ExportResponse{}, err
+			}
+			fn.EnvVars, err = envStore.All(fn.ID)
+			if err != nil {
+				return ExportResponse{}, err
+			}
+			export.Functions = append(export.Functions, FunctionExport{Function: fn.Function, Versions: versions})
+		}
+		params.Offset += len(functions)
+		if len(functions) == 0 || int64(params.Offset) >= total {
+			return export, nil
+		}
+	}
+}
+
+// listAllVersions returns every version of a function, oldest first
+func listAllVersions(ctx context.Context, database store.DB, functionID string) ([]store.FunctionVersion, error) {
+	var all []store.FunctionVersion
+	params := store.PaginationParams{Limit: 100}
+	for {
+		versions, total, err := database.ListVersions(ctx, functionID, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, versions...)
+		params.Offset += len(versions)
+		if len(versions) == 0 || int64(params.Offset) >= total {
+			break
+		}
+	}
+	slices.SortFunc(all, func(a, b store.FunctionVersion) int { return a.Version - b.Version })
+	return all, nil
+}
+
+// ImportHandler returns a handler that restores functions from a JSON export
+// with their settings, env vars and versions. The on_conflict query parameter
+// decides what happens to functions whose ID already exists (skip, overwrite
+// or rename); overwritten functions take exactly the export's settings. The
+// functions are imported in one transaction: when any of them is invalid or
+// fails, nothing is imported, env vars included, and the results identify
+// the cause.
+func ImportHandler(database store.DB, envStore env.Store, newID ids.Generator, scheduler *internalcron.FunctionScheduler, routes *RouteTable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		onConflict := r.URL.Query().Get("on_conflict")
+		switch onConflict {
+		case "":
+			onConflict = ImportConflictSkip
+		case ImportConflictSkip, ImportConflictOverwrite, ImportConflictRename:
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid on_conflict %q, want skip, overwrite or rename", onConflict))
+			return
+		}
+
+		var req ExportResponse
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := ValidateImportRequest(&req); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		results := make([]ImportResult, len(req.Functions))
+		var ops []store.BatchOperation
+		var opResults []int // Index in results of each operation
+		seen := make(map[string]bool, len(req.Functions))
+		valid := true
+		for i := range req.Functions {
+			fn := &req.Functions[i]
+			results[i] = ImportResult{Index: i, SourceID: fn.ID, Name: fn.Name, Status: ImportStatusNotApplied}
+
+			if err := ValidateFunctionExport(fn); err != nil {
+				results[i].Status = ImportStatusFailed
+				results[i].Error = err.Error()
+				valid = false
+				continue
+			}
+			if seen[fn.ID] {
+				results[i].Status = ImportStatusFailed
+				results[i].Error = "Function appears more than once in the import"
+				valid = false
+				continue
+			}
+			seen[fn.ID] = true
+
+			exists := true
+			if _, err := database.GetFunction(r.Context(), fn.ID); errors.Is(err, store.ErrFunctionNotFound) {
+				exists = false
+			} else if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to check existing functions")
+				return
+			}
+
+			op := store.BatchOperation{ID: fn.ID, Changes: functionSettings(fn.Function), Versions: fn.Versions}
+			if exists {
+				switch onConflict {
+				case ImportConflictSkip:
+					results[i].ID = fn.ID
+					results[i].Status = ImportStatusSkipped
+					continue
+				case ImportConflictOverwrite:
+					op.Reset = true
+				case ImportConflictRename:
+					// The route prefix belongs to the existing function
+					op.ID = newID(ids.Function)
+					op.Changes.RoutePrefix = nil
+				}
+			}
+			if !exists || onConflict == ImportConflictRename {
+				op.Create = &store.Function{ID: op.ID, Name: fn.Name, EnvVars: make(map[string]string)}
+			}
+			results[i].ID = op.ID
+			ops = append(ops, op)
+			opResults = append(opResults, i)
+		}
+
+		if !valid {
+			writeJSON(w, http.StatusBadRequest, ImportResponse{Results: results})
+			return
+		}
+
+		// Env vars live outside the database, so they are replaced first and
+		// the previous ones are put back when the import fails
+		previousEnv := make(map[string]map[string]string, len(ops))
+		restoreEnv := func() {
+			for id, envVars := range previousEnv {
+				if err := envStore.SetAll(id, envVars); err != nil {
+					slog.Error("Failed to restore env vars after a failed import",
+						"function_id", id,
+						"error", err)
+				}
+			}
+		}
+		for k, op := range ops {
+			i := opResults[k]
+			previous, err := envStore.All(op.ID)
+			if err == nil {
+				previousEnv[op.ID] = previous
+				err = envStore.SetAll(op.ID, req.Functions[i].EnvVars)
+			}
+			if err != nil {
+				slog.Error("Failed to import env vars for function",
+					"function_id", op.ID,
+					"error", err)
+				restoreEnv()
+				results[i].Status = ImportStatusFailed
+				results[i].Error = "Failed to import env vars"
+				writeJSON(w, http.StatusInternalServerError, ImportResponse{Results: results})
+				return
+			}
+		}
+
+		functions, err := database.ApplyBatch(r.Context(), ops)
+		if err != nil {
+			restoreEnv()
+			status := http.StatusInternalServerError
+			var batchErr *store.BatchError
+			if errors.As(err, &batchErr) {
+				i := opResults[batchErr.Index]
+				results[i].Status = ImportStatusFailed
+				switch {
+				case errors.Is(err, store.ErrRoutePrefixTaken):
+					status = http.StatusConflict
+					results[i].Error = "Route prefix is already used by another function"
+				case errors.Is(err, store.ErrVersionNotFound):
+					results[i].Error = "Active version was pruned by the function's max_versions"
+				default:
+					results[i].Error = "Failed to import function"
+				}
+			}
+			writeJSON(w, status, ImportResponse{Results: results})
+			return
+		}
+
+		for k, fn := range functions {
+			i := opResults[k]
+			switch {
+			case ops[k].Create == nil:
+				results[i].Status = ImportStatusOverwritten
+			case fn.ID != results[i].SourceID:
+				results[i].Status = ImportStatusRenamed
+			default:
+				results[i].Status = ImportStatusCreated
+			}
+
+			// Apply the same follow-up work as a single update
+			if err := routes.Refresh(r.Context(), database, fn.ID); err != nil {
+				slog.Error("Failed to refresh route for function",
+					"function_id", fn.ID,
+					"error", err)
+			}
+			if fn.MaxVersions != nil && *fn.MaxVersions > 0 {
+				if _, err := database.DeleteOldVersions(r.Context(), fn.ID, *fn.MaxVersions); err != nil {
+					slog.Error("Failed to prune old versions",
+						"function_id", fn.ID,
+						"error", err)
+				}
+			}
+			if scheduler != nil {
+				if err := scheduler.RefreshFunction(fn.ID); err != nil {
+					slog.Error("Failed to refresh cron schedule for function",
+						"function_id", fn.ID,
+						"error", err)
+				}
+			}
+		}
+
+		writeJSON(w, http.StatusOK, ImportResponse{Applied: true, Results: results})
+	}
+}
+
+// functionSettings returns an update that sets fn's settings. Optional
+// settings fn leaves unset are left out, so they keep their current value.
+func functionSettings(fn store.Function) store.UpdateFunctionRequest {
+	settings := store.UpdateFunctionRequest{
+		Name:              &fn.Name,
+		Description:       fn.Description,
+		Disabled:          &fn.Disabled,
+		RetentionDays:     fn.RetentionDays,
+		CronSchedule:      fn.CronSchedule,
+		CronStatus:        fn.CronStatus,
+		CronJitter:        fn.CronJitter,
+		CronNoOverlap:     &fn.CronNoOverlap,
+		CronMisfirePolicy: fn.CronMisfirePolicy,
+		SaveResponse:      &fn.SaveResponse,
+		MaxVersions:       fn.MaxVersions,
+		RoutePrefix:       fn.RoutePrefix,
+		WebSocketEnabled:  &fn.WebSocketEnabled,
+		CaptureHTTP:       &fn.CaptureHTTP,
+		ReuseState:        &fn.ReuseState,
+		MaxLogEntries:     fn.MaxLogEntries,
+		MaxOutboundCalls:  fn.MaxOutboundCalls,
+		OutboundBudget:    fn.OutboundBudget,
+		CacheTTL:          fn.CacheTTL,
+		AIBudgetTokens:    fn.AIBudgetTokens,
+		AIBudgetUSD:       fn.AIBudgetUSD,
+		AIBudgetOverride:  &fn.AIBudgetOverride,
+		AIDefaultProvider: fn.AIDefaultProvider,
+		AIDefaultModel:    fn.AIDefaultModel,
+		EmailDefaultFrom:  fn.EmailDefaultFrom,
+		SigningSecret:     fn.SigningSecret,
+	}
+	if fn.AllowedMethods != nil {
+		settings.AllowedMethods = &fn.AllowedMethods
+	}
+	if fn.DefaultHeaders != nil {
+		settings.DefaultHeaders = &fn.DefaultHeaders
+	}
+	if fn.CacheVaryHeaders != nil {
+		settings.CacheVaryHeaders = &fn.CacheVaryHeaders
+	}
+	if fn.EmailAllowedFrom != nil {
+		settings.EmailAllowedFrom = &fn.EmailAllowedFrom
+	}
+	if fn.DisabledModules != nil {
+		settings.DisabledModules = &fn.DisabledModules
+	}
+	if fn.Middleware != nil {
+		settings.Middleware = &fn.Middleware
+	}
+	if len(fn.InputSchema) > 0 {
+		settings.InputSchema = &fn.InputSchema
+	}
+	if len(fn.OutputSchema) > 0 {
+		settings.OutputSchema = &fn.OutputSchema
+	}
+	return settings
+}
+
+// parseUsageWindow parses a window such as "30d" or "12h"
+func parseUsageWindow(window string) (time.Duration, error) {
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", window)
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", window)
+		}
+		duration = d
+	}
+
+	if duration < MinUsageWindow || duration > MaxUsageWindow {
+		return 0, fmt.Errorf("window must be between 1h and 366d")
+	}
+	return duration, nil
+}
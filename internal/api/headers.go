@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders describe the connection between the client and the server,
+// which the server manages, so functions may not set them (RFC 9110 7.6.1)
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// isHopByHopHeader reports whether name is a hop-by-hop header, ignoring case
+func isHopByHopHeader(name string) bool {
+	for _, header := range hopByHopHeaders {
+		if strings.EqualFold(name, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeResponseHeaders checks the headers a function returned and drops
+// hop-by-hop headers. It returns an error when there are too many headers, a
+// name is not a valid token, or a value is too long or contains CR, LF or NUL,
+// which could split the response and inject headers or a body.
+func sanitizeResponseHeaders(headers map[string]string) (map[string]string, error) {
+	if len(headers) > MaxResponseHeaders {
+		return nil, fmt.Errorf("cannot return more than %d headers", MaxResponseHeaders)
+	}
+
+	sanitized := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if !isValidHeaderName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if len(value) > MaxResponseHeaderValueLength {
+			return nil, fmt.Errorf("header %q value cannot be longer than %d characters", name, MaxResponseHeaderValueLength)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("header %q value cannot contain control characters", name)
+		}
+		if isHopByHopHeader(name) {
+			continue
+		}
+		sanitized[http.CanonicalHeaderKey(name)] = value
+	}
+	return sanitized, nil
+}
@@ -1,11 +1,35 @@
 package api
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"time"
+
+	"github.com/dimiro1/lunar/internal/ids"
 )
 
+// HeaderRequestID carries the ID of a request, taken from the client when it
+// sends a usable one and generated otherwise
+const HeaderRequestID = "X-Request-Id"
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// HealthPath is answered by healthHandler and left out of the access log
+const HealthPath = "/healthz"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID RequestIDMiddleware gave the request,
+// or "" outside of it
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
 // Middleware type
 type Middleware func(http.Handler) http.Handler
 
@@ -17,45 +41,97 @@ func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
 	return h
 }
 
-// LoggingMiddleware logs HTTP requests
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// RequestIDMiddleware gives every request an ID, returned in the
+// X-Request-Id header and passed to executions as ctx.requestId. A printable
+// ID sent by the client, e.g. by a proxy, is kept so logs line up end to end.
+func RequestIDMiddleware(newID ids.Generator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(HeaderRequestID)
+			if !validRequestID(id) {
+				id = newID(ids.Request)
+			}
+			w.Header().Set(HeaderRequestID, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
 
-		// Wrap response writer to capture status code
-		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+// validRequestID reports whether a client supplied request ID is safe to log
+// and echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
 
-		next.ServeHTTP(rw, r)
+// LoggingMiddleware writes a structured access log entry for a sampleRate
+// share of requests, between 0 (none) and 1 (all). Server errors are logged
+// regardless of sampling and health checks are never logged.
+func LoggingMiddleware(sampleRate float64) Middleware {
+	return func(next http.Handler) http.Handler {
+		if sampleRate <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == HealthPath {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		duration := time.Since(start)
-		log.Printf(
-			"%s %s %d %v",
-			r.Method,
-			r.URL.Path,
-			rw.statusCode,
-			duration,
-		)
-	})
+			start := time.Now()
+
+			// Wrap response writer to capture status code
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(rw, r)
+
+			if rw.statusCode < http.StatusInternalServerError && sampleRate < 1 && rand.Float64() >= sampleRate {
+				return
+			}
+			slog.Info("HTTP request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rw.statusCode,
+				"duration_ms", time.Since(start).Milliseconds(),
+				"request_id", RequestIDFromContext(r.Context()),
+			)
+		})
+	}
 }
 
-// CORSMiddleware adds CORS headers
+// healthHandler reports that the server is up, for load balancer and
+// container health checks
+func healthHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// CORSMiddleware adds CORS headers. Preflight requests are routed like any
+// other request, so functions that handle OPTIONS can answer them; the
+// routes answer the rest with preflightHandler.
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Expose-Headers", "X-Function-Id, X-Function-Version-Id, X-Execution-Id, X-Execution-Duration-Ms")
-
-		// Handle preflight requests
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+		w.Header().Set("Access-Control-Expose-Headers", "X-Function-Id, X-Function-Version-Id, X-Execution-Id, X-Execution-Duration-Ms, X-Request-Id")
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// preflightHandler answers a CORS preflight request, whose headers were set
+// by CORSMiddleware
+func preflightHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // RecoveryMiddleware recovers from panics and returns 500
 func RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -80,3 +156,9 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap exposes the underlying writer to http.ResponseController,
+// which is needed to hijack connections for WebSocket upgrades
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
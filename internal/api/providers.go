@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dimiro1/lunar/internal/killswitch"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+)
+
+// ProviderEnvKey is an environment variable a provider reads from the
+// function's environment
+type ProviderEnvKey struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	URL         bool   `json:"url"` // The value must be an absolute http or https URL
+	Description string `json:"description"`
+}
+
+// Provider is an external service functions reach through an outbound
+// integration, configured with environment variables
+type Provider struct {
+	Name        string                 `json:"name"`
+	Integration killswitch.Integration `json:"integration"`
+	EnvKeys     []ProviderEnvKey       `json:"env_keys"`
+}
+
+// Providers lists the providers Lunar knows and the environment variables each
+// one reads, so clients can tell users which keys to set
+var Providers = []Provider{
+	{
+		Name:        "openai",
+		Integration: killswitch.AI,
+		EnvKeys: []ProviderEnvKey{
+			{Name: ai.OpenAIAPIKeyEnv, Required: true, Description: "API key for ai.chat with the openai provider"},
+			{Name: ai.OpenAIEndpointEnv, URL: true, Description: "Overrides the OpenAI API endpoint"},
+		},
+	},
+	{
+		Name:        "anthropic",
+		Integration: killswitch.AI,
+		EnvKeys: []ProviderEnvKey{
+			{Name: ai.AnthropicAPIKeyEnv, Required: true, Description: "API key for ai.chat with the anthropic provider"},
+			{Name: ai.AnthropicEndpointEnv, URL: true, Description: "Overrides the Anthropic API endpoint"},
+		},
+	},
+	{
+		Name:        "resend",
+		Integration: killswitch.Email,
+		EnvKeys: []ProviderEnvKey{
+			{Name: email.ResendAPIKeyEnv, Required: true, Description: "API key for email.send"},
+			{Name: email.ResendBaseURLEnv, URL: true, Description: "Overrides the Resend API base URL"},
+		},
+	},
+}
+
+// lookupProviderEnvKey returns the provider environment variable named key.
+// When key only matches one in a different case, it is returned with exact false.
+func lookupProviderEnvKey(key string) (envKey ProviderEnvKey, exact, found bool) {
+	for _, provider := range Providers {
+		for _, envKey := range provider.EnvKeys {
+			if envKey.Name == key {
+				return envKey, true, true
+			}
+			if strings.EqualFold(envKey.Name, key) {
+				return envKey, false, true
+			}
+		}
+	}
+	return ProviderEnvKey{}, false, false
+}
+
+// validateProviderEnvVar rejects environment variables that look like a
+// provider key but would be silently ignored, like a lowercase OPENAI_API_KEY,
+// and provider values the provider cannot use
+func validateProviderEnvVar(key, value string) error {
+	envKey, exact, found := lookupProviderEnvKey(key)
+	if !found {
+		return nil
+	}
+	if !exact {
+		return &ValidationError{
+			Field:   "env_var_key",
+			Message: fmt.Sprintf("environment variable %s is not read by any provider, did you mean %s?", key, envKey.Name),
+		}
+	}
+
+	if strings.TrimSpace(value) != value {
+		return &ValidationError{
+			Field:   "env_var_value",
+			Message: fmt.Sprintf("%s cannot start or end with whitespace", key),
+		}
+	}
+	if envKey.URL {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return &ValidationError{
+				Field:   "env_var_value",
+				Message: fmt.Sprintf("%s must be an absolute http or https URL", key),
+			}
+		}
+	}
+	return nil
+}
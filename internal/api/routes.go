@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+// RouteTable maps path prefixes to the functions that own them.
+// It mirrors the route_prefix column so requests can be routed without a
+// database lookup, and is refreshed whenever a function's prefix changes.
+type RouteTable struct {
+	mu     sync.RWMutex
+	routes map[string]string // prefix -> function ID
+}
+
+// NewRouteTable creates an empty route table
+func NewRouteTable() *RouteTable {
+	return &RouteTable{routes: make(map[string]string)}
+}
+
+// Load replaces the table contents with the route prefixes stored in the database
+func (t *RouteTable) Load(ctx context.Context, database store.DB) error {
+	functions, err := database.ListFunctionsWithRoutePrefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	routes := make(map[string]string, len(functions))
+	for _, fn := range functions {
+		routes[*fn.RoutePrefix] = fn.ID
+	}
+
+	t.mu.Lock()
+	t.routes = routes
+	t.mu.Unlock()
+	return nil
+}
+
+// Refresh reloads the route prefix of a single function from the database
+func (t *RouteTable) Refresh(ctx context.Context, database store.DB, functionID string) error {
+	fn, err := database.GetFunction(ctx, functionID)
+	if errors.Is(err, store.ErrFunctionNotFound) {
+		t.Remove(functionID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.removeLocked(functionID)
+	if fn.RoutePrefix != nil && *fn.RoutePrefix != "" {
+		t.routes[*fn.RoutePrefix] = functionID
+	}
+	return nil
+}
+
+// Remove deletes any route owned by the function
+func (t *RouteTable) Remove(functionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.removeLocked(functionID)
+}
+
+// removeLocked deletes any route owned by the function. The caller must hold the write lock.
+func (t *RouteTable) removeLocked(functionID string) {
+	for prefix, id := range t.routes {
+		if id == functionID {
+			delete(t.routes, prefix)
+		}
+	}
+}
+
+// Match finds the function owning the longest prefix of path. Prefixes only
+// match on segment boundaries, so "/app" matches "/app" and "/app/foo" but not "/apple".
+func (t *RouteTable) Match(path string) (functionID string, prefix string, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for candidate, id := range t.routes {
+		if len(candidate) <= len(prefix) {
+			continue
+		}
+		if path == candidate || strings.HasPrefix(path, candidate+"/") {
+			functionID, prefix, ok = id, candidate, true
+		}
+	}
+	return functionID, prefix, ok
+}
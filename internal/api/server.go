@@ -1,21 +1,30 @@
 package api
 
 import (
+	"cmp"
 	"context"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"time"
 
-	"github.com/dimiro1/lunar/internal/services/ai"
 	internalcron "github.com/dimiro1/lunar/internal/cron"
-	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/ids"
+	"github.com/dimiro1/lunar/internal/inflight"
+	"github.com/dimiro1/lunar/internal/killswitch"
+	"github.com/dimiro1/lunar/internal/maintenance"
+	"github.com/dimiro1/lunar/internal/queue"
+	"github.com/dimiro1/lunar/internal/runner"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/services/env"
 	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 	"github.com/dimiro1/lunar/internal/services/kv"
 	"github.com/dimiro1/lunar/internal/services/logger"
-	"github.com/dimiro1/lunar/internal/runner"
+	"github.com/dimiro1/lunar/internal/services/modules"
 	"github.com/dimiro1/lunar/internal/store"
-	"github.com/rs/xid"
 )
 
 // Server represents the API server
@@ -24,48 +33,166 @@ type Server struct {
 	db              store.DB
 	execDeps        *ExecuteFunctionDeps
 	envStore        env.Store
+	kvStore         kv.Store
+	httpClient      internalhttp.Client
+	maintenance     *maintenance.Mode
+	integrations    *killswitch.Switch
 	logger          logger.Logger
 	aiTracker       ai.Tracker
+	aiPrices        ai.PriceTable
 	emailTracker    email.Tracker
+	emailTemplates  email.TemplateStore
+	modules         modules.Store
+	httpTracker     internalhttp.Tracker
 	scheduler       *internalcron.FunctionScheduler
+	routes          *RouteTable
 	frontendHandler http.Handler
 	apiKey          string
 	httpServer      *http.Server
+
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	executionTimeout  time.Duration
+	disableKeepAlives bool
+	tlsCertFile       string
+	tlsKeyFile        string
+	enableH2C         bool
+	gzipMinSize       int
+	maxLogEntries     int
+	newID             ids.Generator
+	accessLogSample   float64
+	trustedProxies    []netip.Prefix
+	functionDefaults  FunctionDefaults
 }
 
+// Default HTTP server timeouts, used when the matching ServerConfig field is zero.
+// They bound how long a slow client can hold a connection before and between
+// requests, independently of the function execution timeout.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+
+	// writeTimeoutMargin is added on top of the read and execution timeouts
+	// to derive the default write timeout
+	writeTimeoutMargin = 30 * time.Second
+)
+
 // ServerConfig holds configuration for creating a Server
 type ServerConfig struct {
-	DB               store.DB
-	Logger           logger.Logger
-	KVStore          kv.Store
-	EnvStore         env.Store
-	HTTPClient       internalhttp.Client
-	AITracker        ai.Tracker
-	EmailTracker     email.Tracker
-	Scheduler        *internalcron.FunctionScheduler
-	ExecutionTimeout time.Duration
-	FrontendHandler  http.Handler
-	APIKey           string
-	BaseURL          string
+	DB                store.DB
+	Logger            logger.Logger
+	KVStore           kv.Store
+	EnvStore          env.Store
+	HTTPClient        internalhttp.Client
+	AITracker         ai.Tracker
+	EmailTracker      email.Tracker
+	EmailTemplates    email.TemplateStore  // Templates rendered by email.send_template (defaults to in-memory)
+	Modules           modules.Store        // Lua modules loaded with require (defaults to in-memory)
+	HTTPTracker       internalhttp.Tracker // Records outbound requests of functions with capture_http (defaults to in-memory)
+	Scheduler         *internalcron.FunctionScheduler
+	ExecutionTimeout  time.Duration
+	FrontendHandler   http.Handler
+	APIKey            string
+	BaseURL           string
+	ReadHeaderTimeout time.Duration // Time allowed to read request headers
+	ReadTimeout       time.Duration // Time allowed to read the entire request, including the body
+	WriteTimeout      time.Duration // Time allowed to write the response (defaults to read + execution timeout plus a margin)
+	IdleTimeout       time.Duration // Time a keep-alive connection may stay idle
+	DisableKeepAlives bool          // Close connections after each response
+	TLSCertFile       string        // Serve HTTPS (with HTTP/2) when set together with TLSKeyFile
+	TLSKeyFile        string
+	EnableH2C         bool                       // Accept unencrypted HTTP/2 on plain HTTP listeners
+	AIPrices          ai.PriceTable              // Prices for AI usage cost estimates (defaults to ai.DefaultPrices)
+	AIBudget          ai.Budget                  // Monthly AI budget shared by all functions (zero for no limit)
+	AIRetry           ai.RetryPolicy             // Retries for transient AI provider errors (zero disables retries)
+	EmailRetry        email.RetryPolicy          // Retries for transient email provider errors (zero disables retries)
+	EmailAllowedFrom  email.SenderAllowlist      // Addresses and domains every function may send email from (empty allows any)
+	Maintenance       *maintenance.Mode          // Shared maintenance switch (defaults to disabled)
+	InFlight          *inflight.Registry         // Executions in progress, shared with the cron scheduler (nil tracks nothing)
+	Integrations      *killswitch.Switch         // Switches outbound http, ai and email off for all functions (defaults to all enabled)
+	CircuitBreaker    internalhttp.BreakerConfig // Per-host circuit breaker for function and AI provider requests (zero threshold disables)
+	ExecutionQueue    *queue.Queue               // Bounds concurrent function executions (defaults to no limit)
+	GzipMinSize       int                        // Gzip function and /api responses of at least this many bytes (zero disables compression)
+	MaxLogEntries     int                        // Log entries an execution may write unless its function sets a limit (defaults to runner.DefaultMaxLogEntries)
+	MaxOutboundCalls  int                        // Outbound http, ai and email calls an execution may make; functions may set a lower limit (zero for no limit)
+	OutboundBudget    time.Duration              // Total time an execution may spend in outbound calls; functions may set a lower budget (zero for no budget)
+	IDGenerator       ids.Generator              // Generates function, execution and request IDs (defaults to ids.XID)
+	AccessLogSample   float64                    // Share of requests written to the access log, from 0 (none) to 1 (all)
+	TrustedProxies    []netip.Prefix             // Peers whose X-Forwarded-For and X-Forwarded-Proto headers are believed (none by default)
+	FunctionDefaults  FunctionDefaults           // Settings applied to new functions (defaults to no saved responses and no retention)
+	Policy            engine.Policy              // Checked before every execution to allow or deny it (nil allows all)
+	DevMode           bool                       // Check every response against its function's output schema, warning in the execution logs
+	Costs             *engine.CostRates          // Prices every execution for chargeback (nil leaves costs unset)
 }
 
 // NewServer creates a new API server with full configuration
 func NewServer(config ServerConfig) *Server {
-	// Create AI and Email clients
-	aiClient := ai.NewDefaultClient(config.HTTPClient, config.EnvStore)
-	emailClient := email.NewDefaultClient(config.EnvStore)
+	if config.AIPrices == nil {
+		config.AIPrices = ai.DefaultPrices()
+	}
+	if config.HTTPTracker == nil {
+		config.HTTPTracker = internalhttp.NewMemoryTracker()
+	}
+	if config.EmailTemplates == nil {
+		config.EmailTemplates = email.NewMemoryTemplateStore()
+	}
+	if config.Modules == nil {
+		config.Modules = modules.NewMemoryStore()
+	}
+	if config.Maintenance == nil {
+		config.Maintenance = maintenance.New(false, 0)
+	}
+	if config.Integrations == nil {
+		config.Integrations = killswitch.New()
+	}
+	if config.IDGenerator == nil {
+		config.IDGenerator = ids.XID
+	}
+
+	// Requests of functions and AI providers share a circuit breaker, so a failing
+	// host fails fast for every function instead of using up their timeouts.
+	outboundClient := config.HTTPClient
+	if config.CircuitBreaker.Threshold > 0 {
+		outboundClient = internalhttp.NewBreakerClient(outboundClient, internalhttp.NewBreaker(config.CircuitBreaker))
+	}
+
+	// Create AI and Email clients. Budgets are enforced when the tracker can report usage,
+	// and every outbound client used by functions honors the integration switches.
+	var aiClient ai.Client = ai.NewDefaultClient(outboundClient, config.EnvStore)
+	if reporter, ok := config.AITracker.(ai.UsageReporter); ok {
+		aiClient = ai.NewBudgetedClient(aiClient, ai.BudgetConfig{
+			Reporter: reporter,
+			Prices:   config.AIPrices,
+			Global:   config.AIBudget,
+			DB:       config.DB,
+		})
+	}
+	aiClient = ai.NewSwitchedClient(aiClient, config.Integrations)
+	var emailClient email.Client = email.NewSwitchedClient(email.NewDefaultClient(config.EnvStore), config.Integrations)
 
 	// Create Lua runtime
 	luaRuntime := runner.NewLuaRuntime(runner.LuaRuntimeConfig{
-		Logger:       config.Logger,
-		KV:           config.KVStore,
-		Env:          config.EnvStore,
-		HTTP:         config.HTTPClient,
-		AI:           aiClient,
-		AITracker:    config.AITracker,
-		Email:        emailClient,
-		EmailTracker: config.EmailTracker,
-		Timeout:      config.ExecutionTimeout,
+		Logger:         config.Logger,
+		KV:             config.KVStore,
+		Env:            config.EnvStore,
+		HTTP:           internalhttp.NewSwitchedClient(outboundClient, config.Integrations),
+		HTTPTracker:    config.HTTPTracker,
+		AI:             aiClient,
+		AITracker:      config.AITracker,
+		AIRetry:        config.AIRetry,
+		Email:          emailClient,
+		EmailTracker:   config.EmailTracker,
+		EmailRetry:     config.EmailRetry,
+		EmailTemplates: config.EmailTemplates,
+		EmailAllowed:   config.EmailAllowedFrom,
+		Modules:        config.Modules,
+		Timeout:        config.ExecutionTimeout,
+		MaxLogEntries:  config.MaxLogEntries,
+		MaxOutbound:    config.MaxOutboundCalls,
+		OutboundTime:   config.OutboundBudget,
 	})
 
 	// Create execution engine
@@ -81,12 +208,23 @@ func NewServer(config ServerConfig) *Server {
 		EmailClient:      emailClient,
 		EmailTracker:     config.EmailTracker,
 		ExecutionTimeout: config.ExecutionTimeout,
-		IDGenerator:      func() string { return xid.New().String() },
+		IDGenerator:      func() string { return config.IDGenerator(ids.Execution) },
+		Policy:           config.Policy,
+		DevMode:          config.DevMode,
+		Costs:            config.Costs,
+		InFlight:         config.InFlight,
 	})
 
 	execDeps := &ExecuteFunctionDeps{
-		Engine:  eng,
-		BaseURL: config.BaseURL,
+		DB:          config.DB,
+		Engine:      eng,
+		BaseURL:     config.BaseURL,
+		Maintenance: config.Maintenance,
+		Queue:       config.ExecutionQueue,
+		APIKey:      config.APIKey,
+	}
+	if config.Scheduler != nil {
+		execDeps.CronToken = config.Scheduler.Token()
 	}
 
 	s := &Server{
@@ -94,20 +232,60 @@ func NewServer(config ServerConfig) *Server {
 		db:              config.DB,
 		execDeps:        execDeps,
 		envStore:        config.EnvStore,
+		kvStore:         config.KVStore,
+		httpClient:      config.HTTPClient,
+		maintenance:     config.Maintenance,
+		integrations:    config.Integrations,
 		logger:          config.Logger,
 		aiTracker:       config.AITracker,
+		aiPrices:        config.AIPrices,
 		emailTracker:    config.EmailTracker,
+		emailTemplates:  config.EmailTemplates,
+		modules:         config.Modules,
+		httpTracker:     config.HTTPTracker,
 		scheduler:       config.Scheduler,
+		routes:          NewRouteTable(),
 		frontendHandler: config.FrontendHandler,
 		apiKey:          config.APIKey,
+
+		disableKeepAlives: config.DisableKeepAlives,
+		tlsCertFile:       config.TLSCertFile,
+		tlsKeyFile:        config.TLSKeyFile,
+		enableH2C:         config.EnableH2C,
+		gzipMinSize:       config.GzipMinSize,
+		maxLogEntries:     cmp.Or(config.MaxLogEntries, runner.DefaultMaxLogEntries),
+		newID:             config.IDGenerator,
+		accessLogSample:   config.AccessLogSample,
+		trustedProxies:    config.TrustedProxies,
+		functionDefaults:  config.FunctionDefaults,
+	}
+	s.setTimeouts(config)
+
+	if err := s.routes.Load(context.Background(), config.DB); err != nil {
+		slog.Error("Failed to load function routes", "error", err)
 	}
 
 	s.setupRoutes()
 	return s
 }
 
+// setTimeouts resolves the HTTP server timeouts, applying defaults for zero values
+func (s *Server) setTimeouts(config ServerConfig) {
+	s.readHeaderTimeout = cmp.Or(config.ReadHeaderTimeout, DefaultReadHeaderTimeout)
+	s.readTimeout = cmp.Or(config.ReadTimeout, DefaultReadTimeout)
+	s.idleTimeout = cmp.Or(config.IdleTimeout, DefaultIdleTimeout)
+
+	// Responses are written after the function runs, so the write deadline
+	// has to leave room for the whole execution
+	s.executionTimeout = cmp.Or(config.ExecutionTimeout, 5*time.Minute)
+	s.writeTimeout = cmp.Or(config.WriteTimeout, s.readTimeout+s.executionTimeout+writeTimeoutMargin)
+}
+
 // setupRoutes configures all API routes using functional handlers
 func (s *Server) setupRoutes() {
+	// Health check for load balancers (no authentication required)
+	s.mux.HandleFunc("GET "+HealthPath, healthHandler)
+
 	// Auth routes (no authentication required)
 	s.mux.HandleFunc("POST /api/auth/login", HandleLogin(s.apiKey))
 	s.mux.HandleFunc("POST /api/auth/logout", HandleLogout())
@@ -121,21 +299,78 @@ func (s *Server) setupRoutes() {
 	// Protected API routes - wrap with auth middleware
 	authMiddleware := AuthMiddleware(s.apiKey)
 
+	// Identity of the presented credential
+	s.mux.Handle("GET /api/whoami", authMiddleware(http.HandlerFunc(WhoAmIHandler())))
+
 	// Function Management - only need DB
-	s.mux.Handle("POST /api/functions", authMiddleware(http.HandlerFunc(CreateFunctionHandler(s.db))))
+	s.mux.Handle("POST /api/functions", authMiddleware(http.HandlerFunc(CreateFunctionHandler(s.db, s.newID, s.functionDefaults))))
+	s.mux.Handle("POST /api/functions/batch", authMiddleware(http.HandlerFunc(BatchFunctionsHandler(s.db, s.newID, s.functionDefaults, s.scheduler, s.routes))))
 	s.mux.Handle("GET /api/functions", authMiddleware(http.HandlerFunc(ListFunctionsHandler(s.db))))
 	s.mux.Handle("GET /api/functions/{id}", authMiddleware(http.HandlerFunc(GetFunctionHandler(s.db, s.envStore))))
-	s.mux.Handle("PUT /api/functions/{id}", authMiddleware(http.HandlerFunc(UpdateFunctionHandler(s.db, s.scheduler))))
-	s.mux.Handle("DELETE /api/functions/{id}", authMiddleware(http.HandlerFunc(DeleteFunctionHandler(s.db))))
+	s.mux.Handle("PUT /api/functions/{id}", authMiddleware(http.HandlerFunc(UpdateFunctionHandler(s.db, s.scheduler, s.routes))))
+	s.mux.Handle("POST /api/functions/{id}/deploy", authMiddleware(http.HandlerFunc(DeployHandler(s.db, s.envStore, s.scheduler, s.routes))))
+	s.mux.Handle("DELETE /api/functions/{id}", authMiddleware(http.HandlerFunc(DeleteFunctionHandler(s.db, s.routes, engine.NewResponseCache(s.kvStore)))))
 	s.mux.Handle("PUT /api/functions/{id}/env", authMiddleware(http.HandlerFunc(UpdateEnvVarsHandler(s.db, s.envStore))))
+	s.mux.Handle("GET /api/functions/{id}/integrations/status", authMiddleware(http.HandlerFunc(IntegrationsStatusHandler(s.db, s.envStore, s.integrations))))
+	s.mux.Handle("PATCH /api/functions/{id}/env", authMiddleware(http.HandlerFunc(PatchEnvVarsHandler(s.db, s.envStore))))
+	s.mux.Handle("DELETE /api/functions/{id}/env/{key}", authMiddleware(http.HandlerFunc(DeleteEnvVarHandler(s.db, s.envStore))))
+	s.mux.Handle("POST /api/functions/{id}/env/copy-from/{sourceId}", authMiddleware(http.HandlerFunc(CopyEnvVarsHandler(s.db, s.envStore))))
+	s.mux.Handle("GET /api/functions/{id}/email-templates", authMiddleware(http.HandlerFunc(ListEmailTemplatesHandler(s.db, s.emailTemplates))))
+	s.mux.Handle("GET /api/functions/{id}/email-templates/{name}", authMiddleware(http.HandlerFunc(GetEmailTemplateHandler(s.db, s.emailTemplates))))
+	s.mux.Handle("PUT /api/functions/{id}/email-templates/{name}", authMiddleware(http.HandlerFunc(PutEmailTemplateHandler(s.db, s.emailTemplates))))
+	s.mux.Handle("DELETE /api/functions/{id}/email-templates/{name}", authMiddleware(http.HandlerFunc(DeleteEmailTemplateHandler(s.db, s.emailTemplates))))
+	s.mux.Handle("GET /api/functions/{id}/modules", authMiddleware(http.HandlerFunc(ListModulesHandler(s.db, s.modules))))
+	s.mux.Handle("GET /api/functions/{id}/modules/{name}", authMiddleware(http.HandlerFunc(GetModuleHandler(s.db, s.modules))))
+	s.mux.Handle("PUT /api/functions/{id}/modules/{name}", authMiddleware(http.HandlerFunc(PutModuleHandler(s.db, s.modules))))
+	s.mux.Handle("DELETE /api/functions/{id}/modules/{name}", authMiddleware(http.HandlerFunc(DeleteModuleHandler(s.db, s.modules))))
+	s.mux.Handle("POST /api/functions/{id}/test", authMiddleware(http.HandlerFunc(TestFunctionHandler(*s.execDeps))))
+	s.mux.Handle("GET /api/functions/{id}/selftest", authMiddleware(http.HandlerFunc(SelfTestHandler(*s.execDeps))))
+	s.mux.Handle("DELETE /api/functions/{id}/cache", authMiddleware(http.HandlerFunc(PurgeCacheHandler(s.db, engine.NewResponseCache(s.kvStore)))))
 	s.mux.Handle("GET /api/functions/{id}/next-run", authMiddleware(http.HandlerFunc(GetNextRunHandler(s.db))))
 
+	// AI usage reporting is only available when the tracker can aggregate usage
+	usageReporter, _ := s.aiTracker.(ai.UsageReporter)
+	s.mux.Handle("GET /api/functions/{id}/ai/usage", authMiddleware(http.HandlerFunc(GetAIUsageHandler(s.db, usageReporter, s.aiPrices))))
+
+	// Execution metrics over time - only need DB
+	s.mux.Handle("GET /api/functions/{id}/metrics/timeseries", authMiddleware(http.HandlerFunc(GetMetricsTimeseriesHandler(s.db))))
+
 	// Version Management - only need DB
 	s.mux.Handle("GET /api/functions/{id}/versions", authMiddleware(http.HandlerFunc(ListVersionsHandler(s.db))))
+	s.mux.Handle("POST /api/functions/{id}/versions/from-url", authMiddleware(http.HandlerFunc(CreateVersionFromURLHandler(s.db, s.httpClient))))
+	s.mux.Handle("GET /api/functions/{id}/versions/active", authMiddleware(http.HandlerFunc(GetActiveVersionHandler(s.db))))
 	s.mux.Handle("GET /api/functions/{id}/versions/{version}", authMiddleware(http.HandlerFunc(GetVersionHandler(s.db))))
 	s.mux.Handle("POST /api/functions/{id}/versions/{versionId}/activate", authMiddleware(http.HandlerFunc(ActivateVersionHandler(s.db))))
+	s.mux.Handle("PATCH /api/functions/{id}/versions/{versionId}", authMiddleware(http.HandlerFunc(UpdateVersionHandler(s.db))))
 	s.mux.Handle("DELETE /api/functions/{id}/versions/{versionId}", authMiddleware(http.HandlerFunc(DeleteVersionHandler(s.db))))
 	s.mux.Handle("GET /api/functions/{id}/diff/{v1}/{v2}", authMiddleware(http.HandlerFunc(GetVersionDiffHandler(s.db))))
+	s.mux.Handle("GET /api/functions/{id}/diff/{v1}/{v2}/summary", authMiddleware(http.HandlerFunc(GetVersionDiffSummaryHandler(s.db))))
+
+	// Effective limits and enabled integrations, for clients
+	s.mux.Handle("GET /api/config", authMiddleware(http.HandlerFunc(GetConfigHandler(s.executionTimeout, s.maxLogEntries, s.integrations))))
+
+	// Maintenance mode
+	s.mux.Handle("GET /api/maintenance", authMiddleware(http.HandlerFunc(GetMaintenanceHandler(s.maintenance))))
+	s.mux.Handle("PUT /api/maintenance", authMiddleware(http.HandlerFunc(UpdateMaintenanceHandler(s.maintenance))))
+
+	// Outbound integration kill switches
+	s.mux.Handle("GET /api/integrations", authMiddleware(http.HandlerFunc(GetIntegrationsHandler(s.integrations))))
+	s.mux.Handle("PUT /api/integrations", authMiddleware(http.HandlerFunc(UpdateIntegrationsHandler(s.integrations))))
+	s.mux.Handle("GET /api/integrations/providers", authMiddleware(http.HandlerFunc(ListProvidersHandler())))
+
+	// Lua tooling for the editor
+	s.mux.Handle("POST /api/lua/format", authMiddleware(http.HandlerFunc(FormatLuaHandler())))
+	s.mux.Handle("POST /api/lua/lint", authMiddleware(http.HandlerFunc(LintLuaHandler())))
+
+	// Recent errors across all functions
+	s.mux.Handle("GET /api/admin/errors", authMiddleware(http.HandlerFunc(ListRecentErrorsHandler(s.db))))
+
+	// Database backups for disaster recovery
+	s.mux.Handle("GET /api/admin/backup", authMiddleware(http.HandlerFunc(BackupHandler(s.db, s.envStore))))
+	s.mux.Handle("POST /api/admin/import", authMiddleware(http.HandlerFunc(ImportHandler(s.db, s.envStore, s.newID, s.scheduler, s.routes))))
+
+	// Execution queue depth
+	s.mux.Handle("GET /api/queue", authMiddleware(http.HandlerFunc(GetQueueHandler(s.execDeps.Queue))))
 
 	// Execution History - only need DB
 	s.mux.Handle("GET /api/functions/{id}/executions", authMiddleware(http.HandlerFunc(ListExecutionsHandler(s.db))))
@@ -143,19 +378,29 @@ func (s *Server) setupRoutes() {
 	s.mux.Handle("GET /api/executions/{id}/logs", authMiddleware(http.HandlerFunc(GetExecutionLogsHandler(s.db, s.logger))))
 	s.mux.Handle("GET /api/executions/{id}/ai-requests", authMiddleware(http.HandlerFunc(GetExecutionAIRequestsHandler(s.db, s.aiTracker))))
 	s.mux.Handle("GET /api/executions/{id}/email-requests", authMiddleware(http.HandlerFunc(GetExecutionEmailRequestsHandler(s.db, s.emailTracker))))
+	s.mux.Handle("GET /api/executions/{id}/http-requests", authMiddleware(http.HandlerFunc(GetExecutionHTTPRequestsHandler(s.db, s.httpTracker))))
+	s.mux.Handle("GET /api/executions/{id}/external", authMiddleware(http.HandlerFunc(GetExecutionExternalCallsHandler(s.db, s.aiTracker, s.emailTracker, s.httpTracker))))
+	s.mux.Handle("GET /api/executions/{id}/explain", authMiddleware(http.HandlerFunc(ExplainExecutionHandler(s.db, s.aiTracker, s.emailTracker, s.httpTracker))))
+	s.mux.Handle("GET /api/executions/{id}/as-curl", authMiddleware(http.HandlerFunc(ExecutionAsCurlHandler(s.db, s.execDeps.BaseURL))))
 
 	// Runtime Execution - needs all dependencies (NO AUTH - public endpoint)
 	// Register both exact match and wildcard patterns for routing support
+	// GET patterns also match HEAD; OPTIONS runs functions that handle it
 	executeHandler := ExecuteFunctionHandler(*s.execDeps)
-	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "PATCH"} {
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"} {
 		s.mux.HandleFunc(method+" /fn/{function_id}", executeHandler)
 		s.mux.HandleFunc(method+" /fn/{function_id}/{path...}", executeHandler)
 	}
 
-	// Serve frontend files (catch-all route for SPA)
-	if s.frontendHandler != nil {
-		s.mux.Handle("/", s.frontendHandler)
+	// Catch-all: functions routed by path prefix, then frontend files (SPA)
+	fallback := s.frontendHandler
+	if fallback == nil {
+		fallback = http.NotFoundHandler()
 	}
+	s.mux.Handle("/", PrefixRouteHandler(*s.execDeps, s.routes, fallback))
+
+	// CORS preflight for every other path, unless a routed function handles OPTIONS
+	s.mux.Handle("OPTIONS /", PrefixRouteHandler(*s.execDeps, s.routes, http.HandlerFunc(preflightHandler)))
 }
 
 // Handler returns the http.Handler with all middleware applied
@@ -163,18 +408,55 @@ func (s *Server) Handler() http.Handler {
 	return Chain(
 		s.mux,
 		RecoveryMiddleware,
-		LoggingMiddleware,
+		ClientMiddleware(s.trustedProxies),
+		RequestIDMiddleware(s.newID),
+		LoggingMiddleware(s.accessLogSample),
 		CORSMiddleware,
+		GzipMiddleware(s.gzipMinSize, s.routes),
 	)
 }
 
-// ListenAndServe starts the HTTP server on the specified address
+// ListenAndServe starts the HTTP server on the specified address.
+// It serves HTTPS when a TLS certificate is configured, and plain HTTP otherwise.
 func (s *Server) ListenAndServe(addr string) error {
-	s.httpServer = &http.Server{
-		Addr:    addr,
-		Handler: s.Handler(),
+	ln, err := net.Listen("tcp", cmp.Or(addr, ":http"))
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln, so callers can act once the address is bound.
+// It serves HTTPS when a TLS certificate is configured, and plain HTTP otherwise.
+func (s *Server) Serve(ln net.Listener) error {
+	s.httpServer = s.newHTTPServer(ln.Addr().String())
+	if s.tlsCertFile != "" {
+		return s.httpServer.ServeTLS(ln, s.tlsCertFile, s.tlsKeyFile)
+	}
+	return s.httpServer.Serve(ln)
+}
+
+// newHTTPServer builds the http.Server with the configured timeouts and protocols
+func (s *Server) newHTTPServer(addr string) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		ReadTimeout:       s.readTimeout,
+		WriteTimeout:      s.writeTimeout,
+		IdleTimeout:       s.idleTimeout,
 	}
-	return s.httpServer.ListenAndServe()
+
+	// HTTP/2 is negotiated automatically over TLS; h2c is opt-in because
+	// reverse proxies usually speak HTTP/1.1 to the backend
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(s.tlsCertFile != "")
+	protocols.SetUnencryptedHTTP2(s.enableH2C)
+	server.Protocols = protocols
+
+	server.SetKeepAlivesEnabled(!s.disableKeepAlives)
+	return server
 }
 
 // Shutdown gracefully shuts down the server without interrupting active connections
@@ -1,19 +1,42 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
-
+	"time"
+
+	internalcron "github.com/dimiro1/lunar/internal/cron"
+	"github.com/dimiro1/lunar/internal/ids"
+	"github.com/dimiro1/lunar/internal/killswitch"
+	"github.com/dimiro1/lunar/internal/maintenance"
+	"github.com/dimiro1/lunar/internal/queue"
+	"github.com/dimiro1/lunar/internal/runner"
+	"github.com/dimiro1/lunar/internal/runtime/crypto"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/services/env"
 	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 	"github.com/dimiro1/lunar/internal/services/kv"
 	"github.com/dimiro1/lunar/internal/services/logger"
 	"github.com/dimiro1/lunar/internal/store"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
 )
 
 // Helper function to create a test function in the database with an initial version
@@ -32,7 +55,7 @@ func createTestFunction(t *testing.T, database store.DB) store.Function {
 	}
 
 	// Create an initial version for the function
-	_, err = database.CreateVersion(context.Background(), created.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend", nil)
+	_, err = database.CreateVersion(context.Background(), created.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend", nil, nil)
 	if err != nil {
 		t.Fatalf("failed to create initial version: %v", err)
 	}
@@ -43,7 +66,7 @@ func createTestFunction(t *testing.T, database store.DB) store.Function {
 // Helper function to create a test version
 func createTestVersion(t *testing.T, database store.DB, functionID string, code string) store.FunctionVersion {
 	t.Helper()
-	version, err := database.CreateVersion(context.Background(), functionID, code, nil)
+	version, err := database.CreateVersion(context.Background(), functionID, code, nil, nil)
 	if err != nil {
 		t.Fatalf("failed to create test version: %v", err)
 	}
@@ -166,6 +189,268 @@ func TestCreateFunction(t *testing.T) {
 	}
 }
 
+func TestCreateFunction_IDGenerator(t *testing.T) {
+	server := NewServer(ServerConfig{
+		DB:          store.NewMemoryDB(),
+		Logger:      logger.NewMemoryLogger(),
+		KVStore:     kv.NewMemoryStore(),
+		EnvStore:    env.NewMemoryStore(),
+		HTTPClient:  internalhttp.NewDefaultClient(),
+		APIKey:      "test-api-key",
+		IDGenerator: ids.Prefixed(func(kind ids.Kind) string { return "custom" }),
+	})
+
+	body := []byte(`{"name":"ids","code":"function handler(ctx, event)\n  return {statusCode = 200}\nend"}`)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions", body))
+
+	var fn store.FunctionWithActiveVersion
+	if err := json.NewDecoder(w.Body).Decode(&fn); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if fn.ID != "fn_custom" {
+		t.Errorf("expected function ID fn_custom, got %q", fn.ID)
+	}
+	if fn.ActiveVersion.ID != "ver_fn_custom_v1" {
+		t.Errorf("expected the version ID to follow the function ID, got %q", fn.ActiveVersion.ID)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/fn_custom", nil))
+	if got := w.Header().Get("X-Execution-Id"); got != "exec_custom" {
+		t.Errorf("expected execution ID exec_custom, got %q", got)
+	}
+}
+
+func TestCreateFunction_Defaults(t *testing.T) {
+	retention := 30
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:               database,
+		Logger:           logger.NewMemoryLogger(),
+		KVStore:          kv.NewMemoryStore(),
+		EnvStore:         env.NewMemoryStore(),
+		HTTPClient:       internalhttp.NewDefaultClient(),
+		APIKey:           "test-api-key",
+		FunctionDefaults: FunctionDefaults{SaveResponse: true, RetentionDays: &retention},
+	})
+
+	body := []byte(`{"name":"defaults","code":"function handler(ctx, event)\n  return {statusCode = 200}\nend"}`)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions", body))
+
+	var fn store.FunctionWithActiveVersion
+	if err := json.NewDecoder(w.Body).Decode(&fn); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !fn.SaveResponse || fn.RetentionDays == nil || *fn.RetentionDays != 30 {
+		t.Errorf("expected the defaults on the new function, got save_response=%v retention_days=%v", fn.SaveResponse, fn.RetentionDays)
+	}
+
+	// Changes to the function override the defaults
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, []byte(`{"save_response":false,"retention_days":7}`)))
+	updated, err := database.GetFunction(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.SaveResponse || updated.RetentionDays == nil || *updated.RetentionDays != 7 {
+		t.Errorf("expected the update to win, got save_response=%v retention_days=%v", updated.SaveResponse, updated.RetentionDays)
+	}
+}
+
+func TestCreateFunction_ValidationErrors(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	body := []byte(`{"name":"","code":""}`)
+	req := makeAuthRequest(http.MethodPost, "/api/functions", body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error != "name: name cannot be empty; code: code cannot be empty" {
+		t.Errorf("expected joined error message, got %q", resp.Error)
+	}
+	if len(resp.Errors) != 2 || resp.Errors["name"] != "name cannot be empty" || resp.Errors["code"] != "code cannot be empty" {
+		t.Errorf("expected name and code field errors, got %v", resp.Errors)
+	}
+}
+
+func TestCreateFunction_SyntaxError(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	body := []byte(`{"name":"broken","code":"function handler(ctx, event)\n  return {statusCode = 200\nend"}`)
+	req := makeAuthRequest(http.MethodPost, "/api/functions", body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.Contains(resp.Errors["code"], "code is not valid Lua") {
+		t.Errorf("expected a code field error, got %v", resp.Errors)
+	}
+
+	if _, total, _ := database.ListFunctions(context.Background(), store.PaginationParams{Limit: 10}); total != 0 {
+		t.Errorf("expected no function to be created, got %d", total)
+	}
+
+	// Structural errors take precedence over compiling the code
+	body = []byte(`{"name":"","code":"return {"}`)
+	req = makeAuthRequest(http.MethodPost, "/api/functions", body)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestUpdateFunction_SyntaxError(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+
+	body := []byte(`{"code":"while true do end break"}`)
+	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if _, total, _ := database.ListVersions(context.Background(), fn.ID, store.PaginationParams{Limit: 10}); total != 1 {
+		t.Errorf("expected no new version, got %d versions", total)
+	}
+}
+
+func TestBatchFunctions(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	existing := createTestFunction(t, database)
+
+	body := []byte(`{"operations": [
+		{"action": "create", "name": "batch-created", "code": "function handler(ctx, event)\n  return {statusCode = 200}\nend", "version_label": "v1"},
+		{"action": "update", "id": "` + existing.ID + `", "description": "updated in batch"}
+	]}`)
+	req := makeAuthRequest(http.MethodPost, "/api/functions/batch", body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if !resp.Applied || len(resp.Results) != 2 {
+		t.Fatalf("expected 2 applied results, got %+v", resp)
+	}
+	if resp.Results[0].Status != BatchStatusCreated || resp.Results[0].Function == nil || resp.Results[0].Function.Name != "batch-created" {
+		t.Errorf("unexpected create result: %+v", resp.Results[0])
+	}
+	if resp.Results[1].Status != BatchStatusUpdated {
+		t.Errorf("expected update status, got %q", resp.Results[1].Status)
+	}
+
+	fn, err := database.GetFunction(context.Background(), existing.ID)
+	if err != nil {
+		t.Fatalf("failed to get function: %v", err)
+	}
+	if fn.Description == nil || *fn.Description != "updated in batch" {
+		t.Errorf("expected description to be updated, got %v", fn.Description)
+	}
+}
+
+func TestBatchFunctions_InvalidOperation(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	body := []byte(`{"operations": [
+		{"action": "create", "name": "valid", "code": "return {}"},
+		{"action": "create", "name": "missing-code"},
+		{"action": "delete", "id": "abc"}
+	]}`)
+	req := makeAuthRequest(http.MethodPost, "/api/functions/batch", body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+
+	var resp BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	wantStatuses := []string{BatchStatusNotApplied, BatchStatusFailed, BatchStatusFailed}
+	for i, want := range wantStatuses {
+		if resp.Results[i].Status != want {
+			t.Errorf("result %d: expected status %q, got %q", i, want, resp.Results[i].Status)
+		}
+	}
+
+	_, total, _ := database.ListFunctions(context.Background(), store.PaginationParams{})
+	if resp.Applied || total != 0 {
+		t.Errorf("expected nothing to be applied, got applied=%v and %d functions", resp.Applied, total)
+	}
+}
+
+func TestBatchFunctions_RollsBackOnFailure(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	body := []byte(`{"operations": [
+		{"action": "create", "name": "rolled-back", "code": "return {}"},
+		{"action": "update", "id": "missing", "name": "nope"}
+	]}`)
+	req := makeAuthRequest(http.MethodPost, "/api/functions/batch", body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+
+	var resp BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Applied || resp.Results[0].Status != BatchStatusNotApplied || resp.Results[1].Status != BatchStatusFailed {
+		t.Errorf("unexpected results: %+v", resp)
+	}
+
+	_, total, _ := database.ListFunctions(context.Background(), store.PaginationParams{})
+	if total != 0 {
+		t.Errorf("expected created function to be rolled back, got %d functions", total)
+	}
+}
+
 func TestListFunctions(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
@@ -218,6 +503,48 @@ func TestGetFunction(t *testing.T) {
 	}
 }
 
+func TestGetFunction_RecentExecutions(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+	version, _ := database.GetActiveVersion(context.Background(), fn.ID)
+	for i := range RecentExecutionsLimit + 2 {
+		exec := store.Execution{ID: fmt.Sprintf("exec_recent_%d", i), FunctionID: fn.ID, FunctionVersionID: version.ID, Status: store.ExecutionStatusSuccess}
+		if _, err := database.CreateExecution(context.Background(), exec); err != nil {
+			t.Fatalf("CreateExecution failed: %v", err)
+		}
+	}
+
+	// Without include the response has no executions
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID, nil))
+	if strings.Contains(w.Body.String(), "recent_executions") {
+		t.Errorf("expected no recent executions by default, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"?include=recent_executions", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp FunctionWithRecentExecutions
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ID != fn.ID || len(resp.RecentExecutions) != RecentExecutionsLimit {
+		t.Fatalf("expected %d recent executions of %s, got %+v", RecentExecutionsLimit, fn.ID, resp)
+	}
+	if exec := resp.RecentExecutions[0]; exec.ID == "" || exec.Status == "" || exec.CreatedAt == 0 {
+		t.Errorf("expected a summarized execution, got %+v", exec)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"?include=logs", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown include, got %d", w.Code)
+	}
+}
+
 func TestUpdateFunction(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
@@ -317,70 +644,66 @@ func TestGetVersion(t *testing.T) {
 	}
 }
 
-func TestActivateVersion(t *testing.T) {
+func TestGetActiveVersion(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
 
-	// Create a test function and two versions
 	fn := createTestFunction(t, database)
-	v1 := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
 	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 201}\nend")
 
-	// Use version ID (primary key) instead of version number
-	req := makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/versions/"+v1.ID+"/activate", nil)
+	active, err := database.GetActiveVersion(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("failed to get active version: %v", err)
+	}
+
+	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/versions/active", nil)
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp store.FunctionVersion
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.ID != active.ID {
+		t.Errorf("expected version ID %s, got %s", active.ID, resp.ID)
+	}
+
+	if !resp.IsActive {
+		t.Error("expected version to be active")
 	}
 }
 
-func TestGetVersionDiff(t *testing.T) {
+func TestGetActiveVersion_FunctionNotFound(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
 
-	// Create a test function and two versions with different code
-	fn := createTestFunction(t, database)
-	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
-	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 201}\nend")
-
-	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/diff/1/2", nil)
+	req := makeAuthRequest(http.MethodGet, "/api/functions/missing/versions/active", nil)
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
-	}
-
-	var resp VersionDiffResponse
-	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
-	}
-
-	if len(resp.Diff) == 0 {
-		t.Error("expected at least one diff line")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
 	}
 }
 
-func TestUpdateEnvVars(t *testing.T) {
+func TestActivateVersion(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
 
-	// Create a test function first
+	// Create a test function and two versions
 	fn := createTestFunction(t, database)
+	v1 := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 201}\nend")
 
-	reqBody := UpdateEnvVarsRequest{
-		EnvVars: map[string]string{
-			"API_KEY": "secret-123",
-			"DEBUG":   "true",
-		},
-	}
-
-	body, _ := json.Marshal(reqBody)
-	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID+"/env", body)
+	// Use version ID (primary key) instead of version number
+	req := makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/versions/"+v1.ID+"/activate", nil)
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
@@ -390,76 +713,171 @@ func TestUpdateEnvVars(t *testing.T) {
 	}
 }
 
-func TestListExecutions(t *testing.T) {
+func TestUpdateVersion(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
 
-	// Create a test function and execution
 	fn := createTestFunction(t, database)
-	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
-	createTestExecution(t, database, fn.ID, ver.ID)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 201}\nend")
 
-	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/executions", nil)
+	req := makeAuthRequest(http.MethodPatch, "/api/functions/"+fn.ID+"/versions/"+ver.ID, []byte(`{"label":"launch"}`))
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
 
-	var resp PaginatedExecutionsResponse
+	var resp store.FunctionVersion
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
+
+	if resp.Label == nil || *resp.Label != "launch" {
+		t.Errorf("expected label %q, got %v", "launch", resp.Label)
+	}
+
+	if resp.Version != ver.Version {
+		t.Errorf("expected version number %d, got %d", ver.Version, resp.Version)
+	}
 }
 
-func TestGetExecution(t *testing.T) {
+func TestUpdateVersion_NotFound(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
 
-	// Create a test function, version and execution
 	fn := createTestFunction(t, database)
-	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
-	exec := createTestExecution(t, database, fn.ID, ver.ID)
 
-	req := makeAuthRequest(http.MethodGet, "/api/executions/"+exec.ID, nil)
+	req := makeAuthRequest(http.MethodPatch, "/api/functions/"+fn.ID+"/versions/missing", []byte(`{"label":"launch"}`))
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCreateFunction_WithVersionLabel(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	body := []byte(`{"name":"labeled","code":"function handler(ctx, event)\n  return {statusCode = 200}\nend","version_label":"v1.0.0"}`)
+	req := makeAuthRequest(http.MethodPost, "/api/functions", body)
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
 
-	var resp store.Execution
+	var resp store.FunctionWithActiveVersion
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
+
+	if resp.ActiveVersion.Label == nil || *resp.ActiveVersion.Label != "v1.0.0" {
+		t.Errorf("expected label %q, got %v", "v1.0.0", resp.ActiveVersion.Label)
+	}
 }
 
-func TestGetExecutionLogs(t *testing.T) {
+func TestCreateVersionFromURL(t *testing.T) {
 	database := store.NewMemoryDB()
-	memLogger := logger.NewMemoryLogger()
-
+	httpClient := internalhttp.NewFakeClient()
+	httpClient.SetResponse("GET", "https://example.com/handler.lua", internalhttp.Response{
+		StatusCode: http.StatusOK,
+		Body:       "function handler(ctx, event)\n  return {statusCode = 202}\nend",
+	})
+	httpClient.SetResponse("GET", "https://example.com/missing.lua", internalhttp.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       "not found",
+	})
+	httpClient.SetResponse("GET", "https://example.com/page.html", internalhttp.Response{
+		StatusCode: http.StatusOK,
+		Body:       "<html><body>Sign in</body></html>",
+	})
+	httpClient.SetResponse("GET", "https://example.com/huge.lua", internalhttp.Response{
+		StatusCode: http.StatusOK,
+		Body:       strings.Repeat("-", MaxCodeLength+1),
+	})
+
 	server := NewServer(ServerConfig{
 		DB:         database,
-		Logger:     memLogger,
+		Logger:     logger.NewMemoryLogger(),
 		KVStore:    kv.NewMemoryStore(),
 		EnvStore:   env.NewMemoryStore(),
-		HTTPClient: internalhttp.NewDefaultClient(),
+		HTTPClient: httpClient,
 		APIKey:     "test-api-key",
+		BaseURL:    "http://localhost:8080",
 	})
 
-	// Create a test function, version, execution
 	fn := createTestFunction(t, database)
-	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
-	exec := createTestExecution(t, database, fn.ID, ver.ID)
 
-	// Create a log entry for the execution using the logger
-	memLogger.Info(exec.ID, "Test log message")
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "fetches code", body: `{"url":"https://example.com/handler.lua","auth_header":"Bearer token","label":"ci"}`, wantStatus: http.StatusOK},
+		{name: "invalid url", body: `{"url":"ftp://example.com/handler.lua"}`, wantStatus: http.StatusBadRequest},
+		{name: "upstream error", body: `{"url":"https://example.com/missing.lua"}`, wantStatus: http.StatusBadGateway},
+		{name: "not lua", body: `{"url":"https://example.com/page.html"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "too large", body: `{"url":"https://example.com/huge.lua"}`, wantStatus: http.StatusBadRequest},
+	}
 
-	req := makeAuthRequest(http.MethodGet, "/api/executions/"+exec.ID+"/logs", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/versions/from-url", []byte(tt.body))
+			w := httptest.NewRecorder()
+
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	active, err := database.GetActiveVersion(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("failed to get active version: %v", err)
+	}
+	if !strings.Contains(active.Code, "statusCode = 202") {
+		t.Errorf("expected fetched code to be active, got %q", active.Code)
+	}
+	if active.Label == nil || *active.Label != "ci" {
+		t.Errorf("expected label %q, got %v", "ci", active.Label)
+	}
+
+	if len(httpClient.Requests) == 0 || httpClient.Requests[0].Headers["Authorization"] != "Bearer token" {
+		t.Errorf("expected Authorization header to be sent, got %+v", httpClient.Requests)
+	}
+}
+
+func TestCreateVersionFromURL_FunctionNotFound(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	req := makeAuthRequest(http.MethodPost, "/api/functions/missing/versions/from-url", []byte(`{"url":"https://example.com/handler.lua"}`))
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestGetVersionDiff(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	// Create a test function and two versions with different code
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 201}\nend")
+
+	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/diff/1/2", nil)
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
@@ -468,265 +886,3523 @@ func TestGetExecutionLogs(t *testing.T) {
 		t.Errorf("expected status 200, got %d", w.Code)
 	}
 
-	var resp PaginatedExecutionWithLogs
+	var resp VersionDiffResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if len(resp.Logs) == 0 {
-		t.Error("expected at least one log entry")
+	if len(resp.Diff) == 0 {
+		t.Error("expected at least one diff line")
 	}
 }
 
-func TestExecuteFunction(t *testing.T) {
-	t.Run("success with simple response", func(t *testing.T) {
-		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
-			DB:         database,
-			Logger:     logger.NewMemoryLogger(),
-			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
-			HTTPClient: internalhttp.NewDefaultClient(),
-			APIKey:     "test-api-key",
-		})
+func TestGetVersionDiffSummary(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
 
-		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  log.info(\"hi\")\n  return {statusCode = 201}\nend")
+	createTestVersion(t, database, fn.ID, "function handler(ctx,event, state)\n  log.info(\"hi\")\n  return {statusCode = 201}\nend")
+
+	getSummary := func(path string) VersionDiffSummaryResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp VersionDiffSummaryResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	body := getSummary("/api/functions/" + fn.ID + "/diff/1/2/summary")
+	if body.LinesAdded != 2 || body.LinesRemoved != 1 || body.LinesUnchanged != 2 || body.Hunks != 1 {
+		t.Errorf("expected 2 added, 1 removed, 2 unchanged in 1 hunk, got %+v", body)
+	}
+	if body.HandlerSignatureChanged || body.OldHandlerSignature != "" {
+		t.Errorf("expected the handler signature unchanged, got %+v", body)
+	}
+
+	signature := getSummary("/api/functions/" + fn.ID + "/diff/2/3/summary")
+	if !signature.HandlerSignatureChanged || signature.OldHandlerSignature != "function handler(ctx, event)" || signature.NewHandlerSignature != "function handler(ctx,event, state)" {
+		t.Errorf("expected the handler signature change, got %+v", signature)
+	}
+	if signature.LinesAdded != 1 || signature.LinesRemoved != 1 {
+		t.Errorf("expected one line changed, got %+v", signature)
+	}
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/diff/1/9/summary", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing version, got %d", w.Code)
+	}
+}
+
+func TestUpdateEnvVars(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	// Create a test function first
+	fn := createTestFunction(t, database)
+
+	reqBody := UpdateEnvVarsRequest{
+		EnvVars: map[string]string{
+			"API_KEY": "secret-123",
+			"DEBUG":   "true",
+		},
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID+"/env", body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestUpdateEnvVars_Atomic(t *testing.T) {
+	database := store.NewMemoryDB()
+	envStore := failingEnvStore{MemoryStore: env.NewMemoryStore(), key: "BROKEN"}
+	server := NewServer(ServerConfig{
+		DB:       database,
+		Logger:   logger.NewMemoryLogger(),
+		KVStore:  kv.NewMemoryStore(),
+		EnvStore: envStore,
+		APIKey:   "test-api-key",
+	})
+	fn := createTestFunction(t, database)
+	_ = envStore.Set(fn.ID, "OLD", "1")
+
+	body, _ := json.Marshal(UpdateEnvVarsRequest{EnvVars: map[string]string{"NEW": "2", "BROKEN": "x"}})
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID+"/env", body))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+
+	want := map[string]string{"OLD": "1"}
+	if vars, _ := envStore.All(fn.ID); !maps.Equal(vars, want) {
+		t.Errorf("expected a failed update to change nothing, got %v", vars)
+	}
+}
+
+func TestListProviders(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/integrations/providers", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp ProvidersResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, provider := range resp.Providers {
+		for _, key := range provider.EnvKeys {
+			names[key.Name] = key.Required
+		}
+	}
+	if !names["OPENAI_API_KEY"] || !names["ANTHROPIC_API_KEY"] || !names["RESEND_API_KEY"] {
+		t.Errorf("expected the required provider API keys, got %+v", resp.Providers)
+	}
+}
+
+func TestTestFunction(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
 function handler(ctx, event)
-  return {
-    statusCode = 200,
-    body = '{"message": "success"}'
-  }
+	local resp, err = http.post("https://api.example.com/orders", {body = event.body})
+	if err then error(err) end
+	local sent, err = email.send({
+		from = "app@example.com",
+		to = "user@example.com",
+		subject = "Order " .. (event.query.id or "?"),
+		text = "Thanks"
+	})
+	if err then error(err) end
+	return {statusCode = 201, body = event.method .. " " .. event.relativePath .. " " .. sent.id}
 end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
+`)
 
-		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+	body, _ := json.Marshal(TestFunctionRequest{
+		Method: "POST",
+		Path:   "/orders",
+		Query:  map[string]string{"id": "42"},
+		Body:   `{"item":"book"}`,
+	})
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", body))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp TestFunctionResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Status != store.ExecutionStatusSuccess || resp.ExecutionID == "" {
+		t.Fatalf("expected a successful execution, got %+v", resp)
+	}
+	if resp.Response == nil || resp.Response.StatusCode != 201 || resp.Response.Body != "POST /orders fake-1" {
+		t.Errorf("expected the function's response, got %+v", resp.Response)
+	}
+
+	effects := resp.SideEffects
+	if len(effects.HTTP) != 1 || effects.HTTP[0].Method != "POST" || effects.HTTP[0].URL != "https://api.example.com/orders" || effects.HTTP[0].Body != `{"item":"book"}` {
+		t.Errorf("expected the outbound request recorded, got %+v", effects.HTTP)
+	}
+	if len(effects.Emails) != 1 || effects.Emails[0].Subject != "Order 42" || !slices.Equal(effects.Emails[0].To, []string{"user@example.com"}) {
+		t.Errorf("expected the email recorded, got %+v", effects.Emails)
+	}
+	if len(effects.AI) != 0 {
+		t.Errorf("expected no AI calls, got %+v", effects.AI)
+	}
+
+	// Test runs are recorded under their own trigger
+	exec, err := database.GetExecution(context.Background(), resp.ExecutionID)
+	if err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if exec.Trigger != store.ExecutionTriggerTest {
+		t.Errorf("expected trigger %q, got %q", store.ExecutionTriggerTest, exec.Trigger)
+	}
+
+	t.Run("empty body", func(t *testing.T) {
 		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"body":"GET / fake-1"`) {
+			t.Errorf("expected a GET of the function's root, got %s", w.Body.String())
+		}
+	})
 
-		server.Handler().ServeHTTP(w, req)
+	t.Run("http fixtures", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := createTestServer(database)
+		fn := createTestFunction(t, database)
+		createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+	local resp, err = http.get("https://api.example.com/rates")
+	if err then error(err) end
+	return {statusCode = resp.statusCode, body = resp.body}
+end
+`)
 
+		body, _ := json.Marshal(TestFunctionRequest{
+			HTTPFixtures: []HTTPFixture{{URL: "https://api.example.com/rates", StatusCode: 202, Body: `{"usd":1.08}`}},
+		})
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", body))
 		if w.Code != http.StatusOK {
-			t.Errorf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 		}
 
-		if w.Header().Get("X-Function-Id") != fn.ID {
-			t.Errorf("expected X-Function-Id %s, got %s", fn.ID, w.Header().Get("X-Function-Id"))
+		var resp TestFunctionResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
 		}
-		if w.Header().Get("X-Execution-Id") == "" {
-			t.Error("expected X-Execution-Id header")
+		if resp.Response == nil || resp.Response.StatusCode != 202 || resp.Response.Body != `{"usd":1.08}` {
+			t.Errorf("expected the fixture to answer the request, got %+v", resp.Response)
 		}
-		if w.Header().Get("X-Execution-Duration-Ms") == "" {
-			t.Error("expected X-Execution-Duration-Ms header")
+		if len(resp.SideEffects.HTTP) != 1 || resp.SideEffects.HTTP[0].Method != "GET" {
+			t.Errorf("expected the request recorded, got %+v", resp.SideEffects.HTTP)
 		}
 	})
 
-	t.Run("success with request body", func(t *testing.T) {
+	t.Run("seed and frozen time", func(t *testing.T) {
 		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
-			DB:         database,
-			Logger:     logger.NewMemoryLogger(),
-			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
-			HTTPClient: internalhttp.NewDefaultClient(),
-			APIKey:     "test-api-key",
-		})
-
+		server := createTestServer(database)
 		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
+		createTestVersion(t, database, fn.ID, `
 function handler(ctx, event)
-  return {
-    statusCode = 200,
-    body = event.body
-  }
+	return {statusCode = 200, body = random.string(16) .. " " .. time.now()}
 end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
-
-		requestBody := `{"name": "test"}`
-		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, bytes.NewReader([]byte(requestBody)))
-		w := httptest.NewRecorder()
-
-		server.Handler().ServeHTTP(w, req)
+`)
 
-		if w.Code != http.StatusOK {
-			t.Errorf("expected status 200, got %d", w.Code)
+		seed, frozen := int64(42), int64(1705312800)
+		body, _ := json.Marshal(TestFunctionRequest{Seed: &seed, FrozenTime: &frozen})
+		var bodies []string
+		for range 2 {
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", body))
+			var resp TestFunctionResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Response == nil {
+				t.Fatalf("expected a response, got %d: %v", w.Code, err)
+			}
+			bodies = append(bodies, resp.Response.Body)
 		}
-
-		if w.Body.String() != requestBody {
-			t.Errorf("expected body %s, got %s", requestBody, w.Body.String())
+		if bodies[0] != bodies[1] || !strings.HasSuffix(bodies[0], " 1705312800") {
+			t.Errorf("expected the same random values and the frozen time, got %q", bodies)
 		}
 	})
 
-	t.Run("success with custom status code", func(t *testing.T) {
+	t.Run("env overrides", func(t *testing.T) {
 		database := store.NewMemoryDB()
+		envStore := env.NewMemoryStore()
 		server := NewServer(ServerConfig{
 			DB:         database,
 			Logger:     logger.NewMemoryLogger(),
 			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
+			EnvStore:   envStore,
 			HTTPClient: internalhttp.NewDefaultClient(),
 			APIKey:     "test-api-key",
 		})
-
 		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
+		createTestVersion(t, database, fn.ID, `
 function handler(ctx, event)
-  return {
-    statusCode = 201,
-    body = '{"created": true}'
-  }
+	return {statusCode = 200, body = env.get("API_BASE_URL") .. " " .. env.get("REGION")}
 end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
+`)
+		if err := envStore.SetAll(fn.ID, map[string]string{"API_BASE_URL": "https://api.example.com", "REGION": "eu"}); err != nil {
+			t.Fatalf("failed to set env vars: %v", err)
 		}
 
-		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+		body, _ := json.Marshal(TestFunctionRequest{EnvOverrides: map[string]string{"API_BASE_URL": "https://sandbox.example.com"}})
 		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", body))
+		var resp TestFunctionResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Response == nil {
+			t.Fatalf("expected a response, got %d: %v", w.Code, err)
+		}
+		if resp.Response.Body != "https://sandbox.example.com eu" {
+			t.Errorf("expected the override layered over the env vars, got %q", resp.Response.Body)
+		}
+		if value, _ := envStore.Get(fn.ID, "API_BASE_URL"); value != "https://api.example.com" {
+			t.Errorf("expected the override not to be stored, got %q", value)
+		}
 
-		server.Handler().ServeHTTP(w, req)
+		body, _ = json.Marshal(TestFunctionRequest{EnvOverrides: map[string]string{"bad key": "value"}})
+		w = httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for an invalid key, got %d", w.Code)
+		}
+	})
 
-		if w.Code != http.StatusCreated {
-			t.Errorf("expected status 201, got %d", w.Code)
+	t.Run("invalid path", func(t *testing.T) {
+		body, _ := json.Marshal(TestFunctionRequest{Path: "orders"})
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
 		}
 	})
 
-	t.Run("success with custom headers", func(t *testing.T) {
-		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
-			DB:         database,
-			Logger:     logger.NewMemoryLogger(),
-			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
-			HTTPClient: internalhttp.NewDefaultClient(),
-			APIKey:     "test-api-key",
+	t.Run("unknown function", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/missing/test", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestExecuteFunction_RepeatedValues(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `function handler(ctx, event)
+	return { statusCode = 200, body = json.encode({
+		id = event.query.id,
+		ids = event.query_all.id,
+		tags = event.headers_all["X-Tag"],
+	}) }
+end`)
+
+	req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID+"?id=1&id=2&token=a&token=b", nil)
+	req.Header.Add("X-Tag", "red")
+	req.Header.Add("X-Tag", "blue")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		ID   string   `json:"id"`
+		IDs  []string `json:"ids"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body %s: %v", w.Body.String(), err)
+	}
+	if body.ID != "1" || !slices.Equal(body.IDs, []string{"1", "2"}) || !slices.Equal(body.Tags, []string{"red", "blue"}) {
+		t.Errorf("unexpected body %+v", body)
+	}
+
+	execution, err := database.GetExecution(context.Background(), w.Header().Get("X-Execution-Id"))
+	if err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	var event struct {
+		QueryAll map[string][]string `json:"query_all"`
+	}
+	if err := json.Unmarshal([]byte(*execution.EventJSON), &event); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if !slices.Equal(event.QueryAll["token"], []string{"[REDACTED]", "[REDACTED]"}) || !slices.Equal(event.QueryAll["id"], []string{"1", "2"}) {
+		t.Errorf("expected every token value masked in the stored event, got %v", event.QueryAll)
+	}
+}
+
+func TestTestFunction_OutputSchema(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `function handler(ctx, event)
+	return { statusCode = 200, body = json.encode({ id = event.query.id }) }
+end`)
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, []byte(`{
+		"output_schema": {"type": "object", "required": ["id"], "properties": {"id": {"type": "string"}}}
+	}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to set the output schema: %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name  string
+		query map[string]string
+		want  map[string]string
+	}{
+		{name: "matching response", query: map[string]string{"id": "42"}},
+		{name: "missing property", want: map[string]string{"body": "missing property 'id'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(TestFunctionRequest{Query: tt.query})
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+fn.ID+"/test", body))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp TestFunctionResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !maps.Equal(resp.OutputErrors, tt.want) {
+				t.Errorf("expected output errors %v, got %v", tt.want, resp.OutputErrors)
+			}
 		})
+	}
+}
 
-		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
-function handler(ctx, event)
-  return {
-    statusCode = 200,
-    headers = {
-      ["X-Custom-Header"] = "custom-value",
-      ["Content-Type"] = "text/plain"
-    },
-    body = 'hello'
-  }
-end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		wantPassed bool
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "valid response",
+			code:       `function handler(ctx, event) return {statusCode = 200, body = event.method .. " " .. event.relativePath} end`,
+			wantPassed: true,
+			wantStatus: 200,
+		},
+		{
+			name:       "error status still passes",
+			code:       `function handler(ctx, event) return {statusCode = 503, body = "down"} end`,
+			wantPassed: true,
+			wantStatus: 503,
+		},
+		{
+			name:      "runtime error",
+			code:      `function handler(ctx, event) error("boom") end`,
+			wantError: "boom",
+		},
+		{
+			name:       "invalid status code",
+			code:       `function handler(ctx, event) return {statusCode = "ok"} end`,
+			wantStatus: 0,
+			wantError:  "invalid status code",
+		},
+		{
+			name:       "invalid base64 body",
+			code:       `function handler(ctx, event) return {statusCode = 200, body = "not base64!", isBase64Encoded = true} end`,
+			wantStatus: 200,
+			wantError:  "not valid base64",
+		},
+	}
 
-		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
-		w := httptest.NewRecorder()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := store.NewMemoryDB()
+			server := createTestServer(database)
+			fn := createTestFunction(t, database)
+			createTestVersion(t, database, fn.ID, tt.code)
 
-		server.Handler().ServeHTTP(w, req)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/selftest", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
 
-		if w.Code != http.StatusOK {
-			t.Errorf("expected status 200, got %d", w.Code)
-		}
+			var resp SelfTestResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Passed != tt.wantPassed || resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected passed %v with status %d, got %+v", tt.wantPassed, tt.wantStatus, resp)
+			}
+			if !strings.Contains(resp.Error, tt.wantError) || (tt.wantError == "") != (resp.Error == "") {
+				t.Errorf("expected error containing %q, got %q", tt.wantError, resp.Error)
+			}
 
-		if w.Header().Get("X-Custom-Header") != "custom-value" {
-			t.Errorf("expected X-Custom-Header 'custom-value', got %s", w.Header().Get("X-Custom-Header"))
-		}
+			// A self-test is not an execution
+			if _, total, _ := database.ListExecutions(context.Background(), fn.ID, store.PaginationParams{Limit: 10}); total != 0 {
+				t.Errorf("expected no executions recorded, got %d", total)
+			}
+		})
+	}
 
-		if w.Header().Get("Content-Type") != "text/plain" {
-			t.Errorf("expected Content-Type 'text/plain', got %s", w.Header().Get("Content-Type"))
+	t.Run("unknown function", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := createTestServer(database)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/missing/selftest", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
 		}
 	})
+}
 
-	t.Run("success with html content type", func(t *testing.T) {
+func TestFormatLua(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	body, _ := json.Marshal(LuaCodeRequest{Code: "function handler(ctx, event)\nreturn {statusCode = 200}   \nend"})
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/lua/format", body))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp FormatLuaResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := "function handler(ctx, event)\n  return {statusCode = 200}\nend\n"; resp.Code != want || !resp.Changed {
+		t.Errorf("expected formatted code %q, got %+v", want, resp)
+	}
+
+	body, _ = json.Marshal(LuaCodeRequest{Code: "function handler(\n"})
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/lua/format", body))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for invalid Lua, got %d", w.Code)
+	}
+}
+
+func TestLintLua(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	code := "counter = 0\nfunction handler(ctx, event)\n  local unused = kv.get(\"x\")\n  return {statusCode = 200, body = jsno.encode({})}\nend"
+	body, _ := json.Marshal(LuaCodeRequest{Code: code})
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/lua/lint", body))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp LintLuaResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	var got []string
+	for _, finding := range resp.Findings {
+		got = append(got, fmt.Sprintf("%d:%s", finding.Line, finding.Rule))
+	}
+	want := []string{"1:global-assignment", "3:unused-local", "4:undefined-global"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected findings %v, got %v", want, got)
+	}
+
+	body, _ = json.Marshal(LuaCodeRequest{Code: ""})
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/lua/lint", body))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for empty code, got %d", w.Code)
+	}
+}
+
+func TestIntegrationsStatus(t *testing.T) {
+	database := store.NewMemoryDB()
+	envStore := env.NewMemoryStore()
+	integrations := killswitch.New()
+	integrations.Set(killswitch.Email, true)
+	server := NewServer(ServerConfig{
+		DB:           database,
+		Logger:       logger.NewMemoryLogger(),
+		KVStore:      kv.NewMemoryStore(),
+		EnvStore:     envStore,
+		HTTPClient:   internalhttp.NewDefaultClient(),
+		APIKey:       "test-api-key",
+		Integrations: integrations,
+	})
+
+	fn := createTestFunction(t, database)
+	_ = envStore.Set(fn.ID, "OPENAI_API_KEY", "sk-secret")
+	_ = envStore.Set(fn.ID, "RESEND_API_KEY", "re-secret")
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/integrations/status", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Fatalf("expected no env var values in the response, got %s", w.Body.String())
+	}
+
+	var resp IntegrationsStatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	statuses := make(map[string]ProviderStatus)
+	for _, status := range resp.Providers {
+		statuses[status.Name] = status
+	}
+	if openai := statuses["openai"]; !openai.Ready || !openai.Enabled || !openai.EnvKeys["OPENAI_API_KEY"] || openai.EnvKeys["OPENAI_ENDPOINT"] {
+		t.Errorf("expected openai ready without an endpoint override, got %+v", openai)
+	}
+	if anthropic := statuses["anthropic"]; anthropic.Ready {
+		t.Errorf("expected anthropic not ready, got %+v", anthropic)
+	}
+	if resend := statuses["resend"]; !resend.Ready || resend.Enabled {
+		t.Errorf("expected resend ready but switched off, got %+v", resend)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/functions/missing/integrations/status", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestUpdateEnvVars_ProviderKey(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+
+	body := []byte(`{"env_vars":{"OpenAI_API_Key":"sk-123"}}`)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID+"/env", body))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.Contains(resp.Errors["env_var_key"], "did you mean OPENAI_API_KEY?") {
+		t.Errorf("expected a hint for the provider key, got %v", resp.Errors)
+	}
+}
+
+func TestPatchEnvVars(t *testing.T) {
+	database := store.NewMemoryDB()
+	envStore := env.NewMemoryStore()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   envStore,
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	_ = envStore.Set(fn.ID, "KEEP", "1")
+	_ = envStore.Set(fn.ID, "REMOVE", "2")
+	_ = envStore.Set(fn.ID, "CHANGE", "old")
+
+	body := []byte(`{"env_vars":{"CHANGE":"new","ADD":"3","REMOVE":null}}`)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPatch, "/api/functions/"+fn.ID+"/env", body))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp EnvVarsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := map[string]string{"KEEP": "1", "CHANGE": "new", "ADD": "3"}
+	if !maps.Equal(resp.EnvVars, want) {
+		t.Errorf("expected %v in response, got %v", want, resp.EnvVars)
+	}
+	if envVars, _ := envStore.All(fn.ID); !maps.Equal(envVars, want) {
+		t.Errorf("expected %v stored, got %v", want, envVars)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPatch, "/api/functions/"+fn.ID+"/env", []byte(`{"env_vars":{}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an empty patch, got %d", w.Code)
+	}
+}
+
+func TestPatchEnvVars_Atomic(t *testing.T) {
+	database := store.NewMemoryDB()
+	envStore := failingEnvStore{MemoryStore: env.NewMemoryStore(), key: "BROKEN"}
+	server := NewServer(ServerConfig{
+		DB:       database,
+		Logger:   logger.NewMemoryLogger(),
+		KVStore:  kv.NewMemoryStore(),
+		EnvStore: envStore,
+		APIKey:   "test-api-key",
+	})
+	fn := createTestFunction(t, database)
+	_ = envStore.Set(fn.ID, "OLD", "1")
+
+	body := []byte(`{"env_vars":{"OLD":null,"NEW":"2","BROKEN":"x"}}`)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPatch, "/api/functions/"+fn.ID+"/env", body))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+
+	want := map[string]string{"OLD": "1"}
+	if vars, _ := envStore.All(fn.ID); !maps.Equal(vars, want) {
+		t.Errorf("expected a failed patch to change nothing, got %v", vars)
+	}
+}
+
+func TestDeleteEnvVar(t *testing.T) {
+	database := store.NewMemoryDB()
+	envStore := env.NewMemoryStore()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   envStore,
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	_ = envStore.Set(fn.ID, "KEEP", "1")
+	_ = envStore.Set(fn.ID, "REMOVE", "2")
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodDelete, "/api/functions/"+fn.ID+"/env/REMOVE", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if envVars, _ := envStore.All(fn.ID); len(envVars) != 1 || envVars["KEEP"] != "1" {
+		t.Errorf("expected only KEEP to remain, got %v", envVars)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodDelete, "/api/functions/"+fn.ID+"/env/REMOVE", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing key, got %d", w.Code)
+	}
+}
+
+func TestCopyEnvVars(t *testing.T) {
+	setup := func(t *testing.T) (*Server, env.Store, string, string) {
+		t.Helper()
 		database := store.NewMemoryDB()
+		envStore := env.NewMemoryStore()
 		server := NewServer(ServerConfig{
 			DB:         database,
 			Logger:     logger.NewMemoryLogger(),
 			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
+			EnvStore:   envStore,
 			HTTPClient: internalhttp.NewDefaultClient(),
 			APIKey:     "test-api-key",
 		})
 
-		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
-function handler(ctx, event)
-  return {
-    statusCode = 200,
-    headers = {
-      ["Content-Type"] = "text/html"
-    },
-    body = '<html><body><h1>Hello World</h1></body></html>'
-  }
-end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
+		source, _ := database.CreateFunction(context.Background(), store.Function{ID: "func_source", Name: "source"})
+		target, _ := database.CreateFunction(context.Background(), store.Function{ID: "func_target", Name: "target"})
 
-		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
-		w := httptest.NewRecorder()
+		_ = envStore.Set(source.ID, "API_URL", "https://staging.example.com")
+		_ = envStore.Set(source.ID, "DEBUG", "true")
+		_ = envStore.Set(target.ID, "DEBUG", "false")
+		_ = envStore.Set(target.ID, "ONLY_TARGET", "1")
+
+		return server, envStore, source.ID, target.ID
+	}
+
+	t.Run("merge keeps target-only vars", func(t *testing.T) {
+		server, envStore, sourceID, targetID := setup(t)
 
+		req := makeAuthRequest(http.MethodPost, "/api/functions/"+targetID+"/env/copy-from/"+sourceID, nil)
+		w := httptest.NewRecorder()
 		server.Handler().ServeHTTP(w, req)
 
 		if w.Code != http.StatusOK {
-			t.Errorf("expected status 200, got %d", w.Code)
-		}
-
-		if w.Header().Get("Content-Type") != "text/html" {
-			t.Errorf("expected Content-Type 'text/html', got %s", w.Header().Get("Content-Type"))
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 		}
 
-		expectedBody := "<html><body><h1>Hello World</h1></body></html>"
-		if w.Body.String() != expectedBody {
-			t.Errorf("expected body %q, got %q", expectedBody, w.Body.String())
+		envVars, _ := envStore.All(targetID)
+		if len(envVars) != 3 || envVars["DEBUG"] != "true" || envVars["ONLY_TARGET"] != "1" || envVars["API_URL"] == "" {
+			t.Errorf("unexpected env vars after merge: %v", envVars)
 		}
 	})
 
-	t.Run("default content type when not specified", func(t *testing.T) {
-		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
-			DB:         database,
-			Logger:     logger.NewMemoryLogger(),
-			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
-			HTTPClient: internalhttp.NewDefaultClient(),
-			APIKey:     "test-api-key",
-		})
+	t.Run("replace removes target-only vars", func(t *testing.T) {
+		server, envStore, sourceID, targetID := setup(t)
 
-		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
+		req := makeAuthRequest(http.MethodPost, "/api/functions/"+targetID+"/env/copy-from/"+sourceID+"?mode=replace", nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp EnvVarsResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.EnvVars) != 2 {
+			t.Errorf("expected 2 env vars in response, got %v", resp.EnvVars)
+		}
+
+		envVars, _ := envStore.All(targetID)
+		if _, exists := envVars["ONLY_TARGET"]; exists || len(envVars) != 2 {
+			t.Errorf("unexpected env vars after replace: %v", envVars)
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		server, _, sourceID, targetID := setup(t)
+
+		req := makeAuthRequest(http.MethodPost, "/api/functions/"+targetID+"/env/copy-from/"+sourceID+"?mode=overwrite", nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("source not found", func(t *testing.T) {
+		server, _, _, targetID := setup(t)
+
+		req := makeAuthRequest(http.MethodPost, "/api/functions/"+targetID+"/env/copy-from/func_missing", nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestEmailTemplates(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+	base := "/api/functions/" + fn.ID + "/email-templates"
+
+	body, _ := json.Marshal(PutEmailTemplateRequest{Subject: "Welcome, {{.name}}", HTML: "<p>Hi {{.name}}</p>"})
+	req := makeAuthRequest(http.MethodPut, base+"/welcome", body)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = makeAuthRequest(http.MethodGet, base, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	var list ListEmailTemplatesResponse
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Templates) != 1 || list.Templates[0].Name != "welcome" || list.Templates[0].Subject != "Welcome, {{.name}}" {
+		t.Errorf("unexpected templates: %+v", list.Templates)
+	}
+
+	t.Run("invalid template", func(t *testing.T) {
+		body, _ := json.Marshal(PutEmailTemplateRequest{HTML: "{{if .name}}"})
+		req := makeAuthRequest(http.MethodPut, base+"/broken", body)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("function not found", func(t *testing.T) {
+		req := makeAuthRequest(http.MethodPut, "/api/functions/func_missing/email-templates/welcome", body)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		req := makeAuthRequest(http.MethodDelete, base+"/welcome", nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", w.Code)
+		}
+
+		req = makeAuthRequest(http.MethodGet, base+"/welcome", nil)
+		w = httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 after delete, got %d", w.Code)
+		}
+	})
+}
+
+func TestModules(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+local greet = require("lib.greet")
 function handler(ctx, event)
-  return {
-    statusCode = 200,
-    body = '{"message": "hello"}'
-  }
+	return { statusCode = 200, body = greet("world") }
 end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
+`)
+	base := "/api/functions/" + fn.ID + "/modules"
+
+	body, _ := json.Marshal(PutModuleRequest{Code: `return function(name) return "hello " .. name end`})
+	req := makeAuthRequest(http.MethodPut, base+"/lib.greet", body)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
 
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = makeAuthRequest(http.MethodGet, base, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	var list ListModulesResponse
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Modules) != 1 || list.Modules[0].Name != "lib.greet" {
+		t.Errorf("unexpected modules: %+v", list.Modules)
+	}
+
+	t.Run("required by the handler", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
 		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || w.Body.String() != "hello world" {
+			t.Errorf("expected 200 'hello world', got %d %q", w.Code, w.Body.String())
+		}
+	})
 
+	t.Run("invalid code", func(t *testing.T) {
+		body, _ := json.Marshal(PutModuleRequest{Code: "return {"})
+		req := makeAuthRequest(http.MethodPut, base+"/broken", body)
+		w := httptest.NewRecorder()
 		server.Handler().ServeHTTP(w, req)
 
-		if w.Code != http.StatusOK {
-			t.Errorf("expected status 200, got %d", w.Code)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status 422, got %d", w.Code)
 		}
+	})
 
-		if w.Header().Get("Content-Type") != "application/json" {
-			t.Errorf("expected Content-Type 'application/json', got %s", w.Header().Get("Content-Type"))
+	t.Run("function not found", func(t *testing.T) {
+		req := makeAuthRequest(http.MethodPut, "/api/functions/func_missing/modules/utils", body)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		req := makeAuthRequest(http.MethodDelete, base+"/lib.greet", nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", w.Code)
+		}
+
+		req = makeAuthRequest(http.MethodGet, base+"/lib.greet", nil)
+		w = httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 after delete, got %d", w.Code)
+		}
+	})
+}
+
+func TestListExecutions(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	// Create a test function and execution
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	createTestExecution(t, database, fn.ID, ver.ID)
+
+	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/executions", nil)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	var resp PaginatedExecutionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
+
+func TestListExecutions_TagFilter(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	tagged := createTestExecution(t, database, fn.ID, ver.ID)
+	if err := database.SetExecutionTags(context.Background(), tagged.ID, map[string]string{"customer": "acme"}); err != nil {
+		t.Fatalf("failed to set tags: %v", err)
+	}
+	if _, err := database.CreateExecution(context.Background(), store.Execution{
+		ID:                "exec_untagged",
+		FunctionID:        fn.ID,
+		FunctionVersionID: ver.ID,
+		Status:            store.ExecutionStatusSuccess,
+	}); err != nil {
+		t.Fatalf("failed to create execution: %v", err)
+	}
+
+	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/executions?tag=customer:acme", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp PaginatedExecutionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Executions) != 1 || resp.Executions[0].ID != tagged.ID || resp.Pagination.Total != 1 {
+		t.Errorf("expected only the tagged execution, got %+v", resp)
+	}
+	if resp.Executions[0].Tags["customer"] != "acme" {
+		t.Errorf("expected tags in response, got %v", resp.Executions[0].Tags)
+	}
+
+	for _, query := range []string{"tag=customer", "tag=bad%20key:acme"} {
+		req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/executions?"+query, nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestListExecutions_NDJSON(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	for i, id := range []string{"exec_0", "exec_1", "exec_2"} {
+		if _, err := database.CreateExecution(context.Background(), store.Execution{
+			ID:                id,
+			FunctionID:        fn.ID,
+			FunctionVersionID: ver.ID,
+			Status:            store.ExecutionStatusSuccess,
+			CreatedAt:         int64(1000 + i),
+		}); err != nil {
+			t.Fatalf("failed to create execution: %v", err)
+		}
+	}
+
+	// Pagination does not apply to the stream
+	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/executions?limit=1", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected NDJSON content type, got %q", ct)
+	}
+
+	body := w.Body.String()
+	if lines := strings.Count(body, "\n"); lines != 3 {
+		t.Errorf("expected one line per execution, got %d lines: %s", lines, body)
+	}
+
+	var got []string
+	dec := json.NewDecoder(strings.NewReader(body))
+	for dec.More() {
+		var exec store.Execution
+		if err := dec.Decode(&exec); err != nil {
+			t.Fatalf("failed to decode record: %v", err)
 		}
+		got = append(got, exec.ID)
+	}
+	if !slices.Equal(got, []string{"exec_2", "exec_1", "exec_0"}) {
+		t.Errorf("expected all executions newest first, got %v", got)
+	}
+}
+
+func TestGetExecution(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	// Create a test function, version and execution
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	exec := createTestExecution(t, database, fn.ID, ver.ID)
+
+	req := makeAuthRequest(http.MethodGet, "/api/executions/"+exec.ID, nil)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	var resp store.Execution
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
+
+func TestGetExecutionLogs(t *testing.T) {
+	database := store.NewMemoryDB()
+	memLogger := logger.NewMemoryLogger()
+
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     memLogger,
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
 	})
 
-	t.Run("error with syntax error in lua code", func(t *testing.T) {
+	// Create a test function, version, execution
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	exec := createTestExecution(t, database, fn.ID, ver.ID)
+
+	// Create a log entry for the execution using the logger
+	memLogger.Info(exec.ID, "Test log message")
+
+	req := makeAuthRequest(http.MethodGet, "/api/executions/"+exec.ID+"/logs", nil)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	var resp PaginatedExecutionWithLogs
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Logs) == 0 {
+		t.Error("expected at least one log entry")
+	}
+}
+
+func TestGetExecutionHTTPRequests(t *testing.T) {
+	database := store.NewMemoryDB()
+	client := internalhttp.NewFakeClient()
+	client.SetResponse("GET", "https://api.example.com/items", internalhttp.Response{StatusCode: 200, Body: `[]`})
+
+	server := NewServer(ServerConfig{
+		DB:          database,
+		Logger:      logger.NewMemoryLogger(),
+		KVStore:     kv.NewMemoryStore(),
+		EnvStore:    env.NewMemoryStore(),
+		HTTPClient:  client,
+		HTTPTracker: internalhttp.NewMemoryTracker(),
+		APIKey:      "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `function handler(ctx, event)
+  http.get("https://api.example.com/items", { headers = { Authorization = "Bearer secret" } })
+  http.get("https://api.example.com/items", { capture = false })
+  return {statusCode = 200}
+end`)
+	capture := true
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{CaptureHTTP: &capture}); err != nil {
+		t.Fatalf("failed to enable capture: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	executionID := w.Header().Get("X-Execution-Id")
+
+	req := makeAuthRequest(http.MethodGet, "/api/executions/"+executionID+"/http-requests", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp PaginatedHTTPRequestsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// The call with capture = false is not recorded
+	if resp.Pagination.Total != 1 || len(resp.HTTPRequests) != 1 {
+		t.Fatalf("expected 1 captured request, got %d", len(resp.HTTPRequests))
+	}
+	captured := resp.HTTPRequests[0]
+	if captured.URL != "https://api.example.com/items" || captured.ResponseBody == nil || *captured.ResponseBody != "[]" {
+		t.Errorf("unexpected captured request: %+v", captured)
+	}
+	if captured.RequestHeaders["Authorization"] != "[REDACTED]" {
+		t.Errorf("expected Authorization header to be masked, got %q", captured.RequestHeaders["Authorization"])
+	}
+}
+
+func TestGetExecutionHTTPRequests_NotCapturedByDefault(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewFakeClient(),
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `function handler(ctx, event)
+  http.get("https://api.example.com/items")
+  return {statusCode = 200}
+end`)
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil))
+	executionID := w.Header().Get("X-Execution-Id")
+
+	req := makeAuthRequest(http.MethodGet, "/api/executions/"+executionID+"/http-requests", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	var resp PaginatedHTTPRequestsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Pagination.Total != 0 {
+		t.Errorf("expected no captured requests, got %d", resp.Pagination.Total)
+	}
+}
+
+func TestGetExecutionExternalCalls(t *testing.T) {
+	database := store.NewMemoryDB()
+	aiTracker := ai.NewMemoryTracker()
+	emailTracker := email.NewMemoryTracker()
+	httpTracker := internalhttp.NewMemoryTracker()
+	server := NewServer(ServerConfig{
+		DB:           database,
+		Logger:       logger.NewMemoryLogger(),
+		KVStore:      kv.NewMemoryStore(),
+		EnvStore:     env.NewMemoryStore(),
+		HTTPClient:   internalhttp.NewFakeClient(),
+		AITracker:    aiTracker,
+		EmailTracker: emailTracker,
+		HTTPTracker:  httpTracker,
+		APIKey:       "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	exec := createTestExecution(t, database, fn.ID, ver.ID)
+
+	errMessage := "connection refused"
+	aiTracker.Track(exec.ID, ai.TrackRequest{Provider: "openai", Model: "gpt-4o", Status: store.AIRequestStatusSuccess, DurationMs: 300})
+	emailTracker.Track(exec.ID, email.TrackRequest{To: []string{"user@example.com"}, Status: store.EmailRequestStatusSuccess, DurationMs: 120})
+	httpTracker.Track(exec.ID, internalhttp.TrackRequest{Method: "GET", URL: "https://api.example.com", Status: store.HTTPRequestStatusError, ErrorMessage: &errMessage, DurationMs: 5})
+	httpTracker.Track("other-execution", internalhttp.TrackRequest{Method: "GET", URL: "https://api.example.com", Status: store.HTTPRequestStatusSuccess})
+
+	tests := []struct {
+		name    string
+		query   string
+		targets []string
+	}{
+		{"all calls", "", []string{"openai/gpt-4o", "user@example.com", "GET https://api.example.com"}},
+		{"by kind", "?kind=email", []string{"user@example.com"}},
+		{"by status", "?status=error", []string{"GET https://api.example.com"}},
+		{"paginated", "?limit=1&offset=1", []string{"user@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeAuthRequest(http.MethodGet, "/api/executions/"+exec.ID+"/external"+tt.query, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp PaginatedExternalCallsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			var targets []string
+			for _, call := range resp.ExternalCalls {
+				targets = append(targets, call.Target)
+			}
+			if strings.Join(targets, "|") != strings.Join(tt.targets, "|") {
+				t.Errorf("expected targets %v, got %v", tt.targets, targets)
+			}
+		})
+	}
+
+	req := makeAuthRequest(http.MethodGet, "/api/executions/"+exec.ID+"/external?kind=sms", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown kind, got %d", w.Code)
+	}
+}
+
+func TestGetExecutionExternalCalls_OrderedWithinSecond(t *testing.T) {
+	database := store.NewMemoryDB()
+	aiTracker := ai.NewMemoryTracker()
+	emailTracker := email.NewMemoryTracker()
+	httpTracker := internalhttp.NewMemoryTracker()
+	server := NewServer(ServerConfig{
+		DB:           database,
+		Logger:       logger.NewMemoryLogger(),
+		KVStore:      kv.NewMemoryStore(),
+		EnvStore:     env.NewMemoryStore(),
+		HTTPClient:   internalhttp.NewFakeClient(),
+		AITracker:    aiTracker,
+		EmailTracker: emailTracker,
+		HTTPTracker:  httpTracker,
+		APIKey:       "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+	exec := createTestExecution(t, database, fn.ID, ver.ID)
+
+	// Made well within one second, in an order that differs from the kinds
+	httpTracker.Track(exec.ID, internalhttp.TrackRequest{Method: "GET", URL: "https://api.example.com/first", Status: store.HTTPRequestStatusSuccess})
+	time.Sleep(time.Millisecond)
+	emailTracker.Track(exec.ID, email.TrackRequest{To: []string{"user@example.com"}, Status: store.EmailRequestStatusSuccess})
+	time.Sleep(time.Millisecond)
+	aiTracker.Track(exec.ID, ai.TrackRequest{Provider: "openai", Model: "gpt-4o", Status: store.AIRequestStatusSuccess})
+	time.Sleep(time.Millisecond)
+	httpTracker.Track(exec.ID, internalhttp.TrackRequest{Method: "GET", URL: "https://api.example.com/last", Status: store.HTTPRequestStatusSuccess})
+
+	req := makeAuthRequest(http.MethodGet, "/api/executions/"+exec.ID+"/external", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp PaginatedExternalCallsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	var targets []string
+	for _, call := range resp.ExternalCalls {
+		if call.CreatedAtNs == 0 {
+			t.Errorf("expected created_at_ns on %s", call.Target)
+		}
+		targets = append(targets, call.Target)
+	}
+	expected := []string{"GET https://api.example.com/first", "user@example.com", "openai/gpt-4o", "GET https://api.example.com/last"}
+	if strings.Join(targets, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+}
+
+func TestExplainExecution(t *testing.T) {
+	database := store.NewMemoryDB()
+	emailTracker := email.NewMemoryTracker()
+	server := NewServer(ServerConfig{
+		DB:           database,
+		Logger:       logger.NewMemoryLogger(),
+		KVStore:      kv.NewMemoryStore(),
+		EnvStore:     env.NewMemoryStore(),
+		HTTPClient:   internalhttp.NewFakeClient(),
+		EmailTracker: emailTracker,
+		APIKey:       "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	ver := createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  trace.span(\"render\").finish()\n  return {statusCode = 200}\nend")
+
+	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID+"?debug=1", strings.NewReader(`{"name":"ana"}`))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	executionID := w.Header().Get("X-Execution-Id")
+	emailTracker.Track(executionID, email.TrackRequest{To: []string{"user@example.com"}, Status: store.EmailRequestStatusSuccess, DurationMs: 120})
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/executions/"+executionID+"/explain", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret-token") {
+		t.Errorf("expected the Authorization header to be masked, got %s", w.Body.String())
+	}
+
+	var resp ExplainExecutionResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Execution.ID != executionID || resp.Execution.Trigger != store.ExecutionTriggerHTTP || resp.Execution.EventJSON != nil {
+		t.Errorf("unexpected execution %+v", resp.Execution)
+	}
+	var event struct {
+		Method  string            `json:"method"`
+		Headers map[string]string `json:"headers"`
+		Query   map[string]string `json:"query"`
+	}
+	if err := json.Unmarshal(resp.Event, &event); err != nil {
+		t.Fatalf("failed to decode event %s: %v", resp.Event, err)
+	}
+	if event.Method != http.MethodPost || event.Query["debug"] != "1" || event.Headers["Authorization"] != "[REDACTED]" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if resp.Version == nil || resp.Version.ID != ver.ID {
+		t.Errorf("expected version %s, got %+v", ver.ID, resp.Version)
+	}
+	if resp.Timing.DurationMs == nil || resp.Timing.ExternalMs != 120 || resp.Timing.FunctionMs == nil {
+		t.Errorf("unexpected timing %+v", resp.Timing)
+	}
+	if len(resp.Timing.Spans) != 1 || resp.Timing.Spans[0].Name != "render" || resp.Execution.Spans != nil {
+		t.Errorf("expected the render span in the timing only, got %+v", resp.Timing.Spans)
+	}
+	if len(resp.ExternalCalls) != 1 || resp.ExternalCalls[0].Target != "user@example.com" {
+		t.Errorf("expected the email call, got %+v", resp.ExternalCalls)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/executions/missing/explain", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing execution, got %d", w.Code)
+	}
+}
+
+func TestExecutionAsCurl(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewFakeClient(),
+		APIKey:     "test-api-key",
+		BaseURL:    "https://lunar.example.com",
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200}\nend")
+
+	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID+"?page=2&token=abc", strings.NewReader(`{"name":"o'neil"}`))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	executionID := w.Header().Get("X-Execution-Id")
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/executions/"+executionID+"/as-curl", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp CurlCommandResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	for _, want := range []string{
+		"curl -X POST",
+		"'https://lunar.example.com/fn/" + fn.ID + "?page=2&token=<redacted:token>'",
+		"-H 'Authorization: <redacted:Authorization>'",
+		"-H 'Content-Type: application/json'",
+		`--data-raw '{"name":"o'\''neil"}'`,
+	} {
+		if !strings.Contains(resp.Command, want) {
+			t.Errorf("expected command to contain %q, got:\n%s", want, resp.Command)
+		}
+	}
+	if strings.Contains(resp.Command, "secret-token") || strings.Contains(resp.Command, "abc") {
+		t.Errorf("expected masked values to stay masked, got:\n%s", resp.Command)
+	}
+	if want := []string{"query:token", "header:Authorization"}; !slices.Equal(resp.Redacted, want) {
+		t.Errorf("expected redacted %v, got %v", want, resp.Redacted)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/executions/missing/as-curl", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing execution, got %d", w.Code)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	database := store.NewMemoryDB()
+	mode := maintenance.New(false, 2*time.Minute)
+	server := NewServer(ServerConfig{
+		DB:          database,
+		Logger:      logger.NewMemoryLogger(),
+		KVStore:     kv.NewMemoryStore(),
+		EnvStore:    env.NewMemoryStore(),
+		HTTPClient:  internalhttp.NewDefaultClient(),
+		APIKey:      "test-api-key",
+		Maintenance: mode,
+	})
+
+	fn := createTestFunction(t, database)
+
+	// Enable maintenance mode through the API
+	req := makeAuthRequest(http.MethodPut, "/api/maintenance", []byte(`{"enabled": true}`))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp MaintenanceResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Enabled || resp.RetryAfterSeconds != 120 {
+		t.Errorf("unexpected maintenance response: %+v", resp)
+	}
+	if !mode.Enabled() {
+		t.Error("expected shared mode to be enabled")
+	}
+
+	// Executions are rejected
+	req = httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "120" {
+		t.Errorf("expected Retry-After 120, got %q", got)
+	}
+
+	// The management API stays available
+	req = makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected management API to respond 200, got %d", w.Code)
+	}
+
+	// Disabling restores executions
+	req = makeAuthRequest(http.MethodPut, "/api/maintenance", []byte(`{"enabled": false}`))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	req = httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 after maintenance, got %d", w.Code)
+	}
+}
+
+func TestExecuteFunction_Trigger(t *testing.T) {
+	database := store.NewMemoryDB()
+	scheduler := internalcron.NewScheduler(database, "")
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		Scheduler:  scheduler,
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+	return { statusCode = 200, body = event.headers["X-Cron-Token"] or "" }
+end
+`)
+
+	tests := []struct {
+		name          string
+		trigger       string
+		authenticated bool
+		cronToken     string
+		wantStatus    int
+		wantTrigger   store.ExecutionTrigger
+		wantScheduled bool
+	}{
+		{name: "no header", wantStatus: http.StatusOK, wantTrigger: store.ExecutionTriggerHTTP},
+		{name: "authenticated cron", trigger: "cron", authenticated: true, wantStatus: http.StatusOK, wantTrigger: store.ExecutionTriggerCron, wantScheduled: true},
+		{name: "scheduler cron", trigger: "cron", cronToken: scheduler.Token(), wantStatus: http.StatusOK, wantTrigger: store.ExecutionTriggerCron, wantScheduled: true},
+		{name: "scheduler manual", trigger: "manual", cronToken: scheduler.Token(), wantStatus: http.StatusOK, wantTrigger: store.ExecutionTriggerHTTP},
+		{name: "wrong cron token", trigger: "cron", cronToken: "not-the-token", wantStatus: http.StatusOK, wantTrigger: store.ExecutionTriggerHTTP},
+		{name: "authenticated manual", trigger: "Manual", authenticated: true, wantStatus: http.StatusOK, wantTrigger: store.ExecutionTriggerManual},
+		{name: "spoofed cron", trigger: "cron", wantStatus: http.StatusOK, wantTrigger: store.ExecutionTriggerHTTP},
+		{name: "claimed test", trigger: "test", authenticated: true, wantStatus: http.StatusBadRequest},
+		{name: "unknown trigger", trigger: "webhook", authenticated: true, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+			if tt.authenticated {
+				req = makeAuthRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+			}
+			if tt.trigger != "" {
+				req.Header.Set("X-Trigger", tt.trigger)
+			}
+			if tt.cronToken != "" {
+				req.Header.Set(internalcron.HeaderCronToken, tt.cronToken)
+			}
+			req.Header.Set("X-Cron-Scheduled-Time", "1700000000")
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantTrigger == "" {
+				return
+			}
+			// The scheduler's token never reaches the function
+			if w.Body.String() != "" {
+				t.Errorf("expected the function not to see X-Cron-Token, got %q", w.Body.String())
+			}
+
+			exec, err := database.GetExecution(context.Background(), w.Header().Get("X-Execution-Id"))
+			if err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			if exec.Trigger != tt.wantTrigger {
+				t.Errorf("expected trigger %q, got %q", tt.wantTrigger, exec.Trigger)
+			}
+
+			// Only cron executions record the time they were scheduled for
+			if tt.wantScheduled {
+				if exec.ScheduledAt == nil || *exec.ScheduledAt != 1700000000 {
+					t.Errorf("expected scheduled_at 1700000000, got %v", exec.ScheduledAt)
+				}
+			} else if exec.ScheduledAt != nil {
+				t.Errorf("expected no scheduled_at, got %d", *exec.ScheduledAt)
+			}
+		})
+	}
+}
+
+func TestExecuteFunction_Trace(t *testing.T) {
+	database := store.NewMemoryDB()
+	log := logger.NewMemoryLogger()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     log,
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+	return { statusCode = 200, body = json.encode({ ok = true }) }
+end
+`)
+
+	tests := []struct {
+		name          string
+		authenticated bool
+		wantTraced    bool
+	}{
+		{name: "public", wantTraced: false},
+		{name: "authenticated", authenticated: true, wantTraced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+			if tt.authenticated {
+				req = makeAuthRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+			}
+			req.Header.Set("X-Trace", "true")
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			traced := len(log.Entries(w.Header().Get("X-Execution-Id"))) > 0
+			if traced != tt.wantTraced {
+				t.Errorf("expected traced=%v, got %v", tt.wantTraced, traced)
+			}
+		})
+	}
+}
+
+func TestExecuteFunction_SignedRequests(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+	return { statusCode = 200, body = event.body }
+end
+`)
+
+	secret := "0123456789abcdef"
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{SigningSecret: &secret}); err != nil {
+		t.Fatalf("failed to set signing secret: %v", err)
+	}
+
+	body := `{"event":"paid"}`
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	stale := now.Add(-10 * time.Minute)
+
+	tests := []struct {
+		name      string
+		signature string
+		timestamp string
+		auth      bool
+		want      int
+	}{
+		{name: "valid signature", signature: crypto.SignRequest(body, secret, now), timestamp: timestamp, want: http.StatusOK},
+		{name: "unsigned", want: http.StatusUnauthorized},
+		{name: "wrong secret", signature: crypto.SignRequest(body, "another-secret-value", now), timestamp: timestamp, want: http.StatusUnauthorized},
+		{name: "stale timestamp", signature: crypto.SignRequest(body, secret, stale), timestamp: strconv.FormatInt(stale.Unix(), 10), want: http.StatusUnauthorized},
+		{name: "authenticated caller", auth: true, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, strings.NewReader(body))
+			if tt.auth {
+				req.Header.Set("Authorization", "Bearer test-api-key")
+			}
+			if tt.signature != "" {
+				req.Header.Set("X-Signature", tt.signature)
+				req.Header.Set("X-Timestamp", tt.timestamp)
+			}
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Clearing the secret accepts unsigned requests again
+	empty := ""
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{SigningSecret: &empty}); err != nil {
+		t.Fatalf("failed to clear signing secret: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 without a secret, got %d", w.Code)
+	}
+}
+
+func TestExecutionQueue(t *testing.T) {
+	database := store.NewMemoryDB()
+	executionQueue := queue.New(1, 0)
+	server := NewServer(ServerConfig{
+		DB:             database,
+		Logger:         logger.NewMemoryLogger(),
+		KVStore:        kv.NewMemoryStore(),
+		EnvStore:       env.NewMemoryStore(),
+		HTTPClient:     internalhttp.NewDefaultClient(),
+		APIKey:         "test-api-key",
+		ExecutionQueue: executionQueue,
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+	return { statusCode = 200 }
+end
+`)
+
+	// Occupy the only slot so the next execution is rejected
+	release, err := executionQueue.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("failed to acquire slot: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	req = makeAuthRequest(http.MethodGet, "/api/queue", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	var resp QueueResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Running != 1 || resp.Queued != 0 || resp.MaxConcurrent != 1 || resp.Rejected != 1 {
+		t.Errorf("unexpected queue response: %+v", resp)
+	}
+
+	// Executions run again once the slot is free
+	release()
+	req = httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIntegrations(t *testing.T) {
+	database := store.NewMemoryDB()
+	integrations := killswitch.New()
+	fake := internalhttp.NewFakeClient()
+	server := NewServer(ServerConfig{
+		DB:           database,
+		Logger:       logger.NewMemoryLogger(),
+		KVStore:      kv.NewMemoryStore(),
+		EnvStore:     env.NewMemoryStore(),
+		HTTPClient:   fake,
+		APIKey:       "test-api-key",
+		Integrations: integrations,
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+	local res, err = http.get("https://example.com")
+	if err then
+		return { statusCode = 502, body = err }
+	end
+	return { statusCode = 200 }
+end
+`)
+
+	// Disable outbound HTTP through the API
+	req := makeAuthRequest(http.MethodPut, "/api/integrations", []byte(`{"http": false}`))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp IntegrationsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.HTTP || !resp.AI || !resp.Email {
+		t.Errorf("unexpected integrations response: %+v", resp)
+	}
+	if !integrations.Disabled(killswitch.HTTP) {
+		t.Error("expected shared switch to disable http")
+	}
+
+	// Outbound calls fail without reaching the client
+	req = httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "outbound http is disabled") {
+		t.Errorf("expected disabled http error, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(fake.Requests) != 0 {
+		t.Errorf("expected no outbound requests, got %d", len(fake.Requests))
+	}
+
+	// Re-enable
+	req = makeAuthRequest(http.MethodPut, "/api/integrations", []byte(`{"http": true}`))
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	req = httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 after re-enabling, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	integrations := killswitch.New()
+	integrations.Set(killswitch.Email, true)
+	server := NewServer(ServerConfig{
+		DB:               store.NewMemoryDB(),
+		Logger:           logger.NewMemoryLogger(),
+		KVStore:          kv.NewMemoryStore(),
+		EnvStore:         env.NewMemoryStore(),
+		HTTPClient:       internalhttp.NewDefaultClient(),
+		APIKey:           "test-api-key",
+		ExecutionTimeout: 90 * time.Second,
+		Integrations:     integrations,
+		MaxLogEntries:    50,
+	})
+
+	req := makeAuthRequest(http.MethodGet, "/api/config", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ConfigResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ExecutionTimeoutSeconds != 90 {
+		t.Errorf("expected execution timeout 90s, got %d", resp.ExecutionTimeoutSeconds)
+	}
+	if resp.MaxLogEntries != 50 {
+		t.Errorf("expected max log entries 50, got %d", resp.MaxLogEntries)
+	}
+	if resp.MaxCodeLength != MaxCodeLength || resp.MaxPageSize != MaxPageSize {
+		t.Errorf("unexpected limits: %+v", resp)
+	}
+	if !slices.Equal(resp.AllowedRetentionDays, AllowedRetentionDays) {
+		t.Errorf("expected retention days %v, got %v", AllowedRetentionDays, resp.AllowedRetentionDays)
+	}
+	if !resp.Integrations.HTTP || !resp.Integrations.AI || resp.Integrations.Email {
+		t.Errorf("expected only email to be disabled, got %+v", resp.Integrations)
+	}
+
+	// The default execution timeout is reported when none is configured
+	w = httptest.NewRecorder()
+	createTestServer(store.NewMemoryDB()).Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/config", nil))
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ExecutionTimeoutSeconds != 300 {
+		t.Errorf("expected default execution timeout 300s, got %d", resp.ExecutionTimeoutSeconds)
+	}
+	if resp.MaxLogEntries != runner.DefaultMaxLogEntries {
+		t.Errorf("expected default max log entries %d, got %d", runner.DefaultMaxLogEntries, resp.MaxLogEntries)
+	}
+}
+
+func TestWhoAmI(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/whoami", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var identity Identity
+	if err := json.NewDecoder(w.Body).Decode(&identity); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if identity.Principal != RoleAdmin || identity.Role != RoleAdmin || identity.AuthMethod != AuthMethodBearer {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+	if !slices.Equal(identity.Permissions, []string{"*"}) {
+		t.Errorf("expected every permission, got %v", identity.Permissions)
+	}
+
+	// The login cookie authenticates too
+	req := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: "test-api-key"})
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if err := json.NewDecoder(w.Body).Decode(&identity); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if identity.AuthMethod != AuthMethodCookie {
+		t.Errorf("expected cookie authentication, got %q", identity.AuthMethod)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/whoami", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without credentials, got %d", w.Code)
+	}
+}
+
+func TestUpdateMaintenance_MissingEnabled(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	req := makeAuthRequest(http.MethodPut, "/api/maintenance", []byte(`{}`))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestExecuteFunction_Policy(t *testing.T) {
+	policy, err := runner.NewLuaPolicy(`
+function policy(ctx, event)
+	if ctx.clientIp == "198.51.100.7" then
+		return false
+	end
+	if event.headers["X-Org"] ~= "acme" then
+		return { statusCode = 401, body = "missing X-Org header" }
+	end
+end
+`)
+	if err != nil {
+		t.Fatalf("NewLuaPolicy failed: %v", err)
+	}
+
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
+		BaseURL:    "http://localhost:8080",
+		Policy:     policy,
+	})
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `function handler(ctx, event) return { statusCode = 200, body = "ok" } end`)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		org        string
+		want       int
+		wantBody   string
+	}{
+		{name: "allowed", org: "acme", want: http.StatusOK, wantBody: "ok"},
+		{name: "missing header", want: http.StatusUnauthorized, wantBody: "missing X-Org header"},
+		{name: "blocked address", remoteAddr: "198.51.100.7:4321", org: "acme", want: http.StatusForbidden, wantBody: "Forbidden by policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.org != "" {
+				req.Header.Set("X-Org", tt.org)
+			}
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.want || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("expected %d with %q, got %d: %s", tt.want, tt.wantBody, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Denied requests are not executions
+	if _, total, _ := database.ListExecutions(context.Background(), fn.ID, store.PaginationParams{Limit: 10}); total != 1 {
+		t.Errorf("expected only the allowed execution, got %d", total)
+	}
+}
+
+func TestExecuteFunction_InputSchema(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `function handler(ctx, event)
+	if event.method == "GET" then
+		return { statusCode = 204 }
+	end
+	local input = json.decode(event.body)
+	return { statusCode = 200, body = "hello " .. input.name }
+end`)
+
+	// Setting an invalid schema is rejected
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, []byte(`{"input_schema": {"type": "text"}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid schema, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, []byte(`{
+		"input_schema": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {"name": {"type": "string", "minLength": 1}}
+		}
+	}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to set the input schema: %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		want       int
+		wantErrors map[string]string
+	}{
+		{name: "valid body", method: http.MethodPost, body: `{"name": "ana"}`, want: http.StatusOK},
+		{name: "GET without a body", method: http.MethodGet, want: http.StatusNoContent},
+		{
+			name:       "missing property",
+			method:     http.MethodPost,
+			body:       `{}`,
+			want:       http.StatusBadRequest,
+			wantErrors: map[string]string{"body": "missing property 'name'"},
+		},
+		{
+			name:       "invalid property",
+			method:     http.MethodPut,
+			body:       `{"name": 7}`,
+			want:       http.StatusBadRequest,
+			wantErrors: map[string]string{"body/name": "got number, want string"},
+		},
+		{
+			name:       "not JSON",
+			method:     http.MethodPost,
+			body:       `name=ana`,
+			want:       http.StatusBadRequest,
+			wantErrors: map[string]string{"body": "body is not valid JSON"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/fn/"+fn.ID, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.wantErrors == nil {
+				return
+			}
+			var resp ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !maps.Equal(resp.Errors, tt.wantErrors) {
+				t.Errorf("expected errors %v, got %v", tt.wantErrors, resp.Errors)
+			}
+		})
+	}
+
+	// Rejected requests never reach the handler, so only the valid body and
+	// the GET, which the schema does not apply to, are executions
+	if _, total, _ := database.ListExecutions(context.Background(), fn.ID, store.PaginationParams{Limit: 10}); total != 2 {
+		t.Errorf("expected 2 executions, got %d", total)
+	}
+}
+
+func TestExecuteFunction(t *testing.T) {
+	t.Run("success with simple response", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = '{"message": "success"}'
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if w.Header().Get("X-Function-Id") != fn.ID {
+			t.Errorf("expected X-Function-Id %s, got %s", fn.ID, w.Header().Get("X-Function-Id"))
+		}
+		if w.Header().Get("X-Execution-Id") == "" {
+			t.Error("expected X-Execution-Id header")
+		}
+		if w.Header().Get("X-Execution-Duration-Ms") == "" {
+			t.Error("expected X-Execution-Duration-Ms header")
+		}
+	})
+
+	t.Run("success with request body", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = event.body
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		requestBody := `{"name": "test"}`
+		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, bytes.NewReader([]byte(requestBody)))
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+
+		if w.Body.String() != requestBody {
+			t.Errorf("expected body %s, got %s", requestBody, w.Body.String())
+		}
+	})
+
+	t.Run("success with custom status code", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 201,
+    body = '{"created": true}'
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("expected status 201, got %d", w.Code)
+		}
+	})
+
+	t.Run("success with custom headers", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    headers = {
+      ["X-Custom-Header"] = "custom-value",
+      ["Content-Type"] = "text/plain"
+    },
+    body = 'hello'
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+
+		if w.Header().Get("X-Custom-Header") != "custom-value" {
+			t.Errorf("expected X-Custom-Header 'custom-value', got %s", w.Header().Get("X-Custom-Header"))
+		}
+
+		if w.Header().Get("Content-Type") != "text/plain" {
+			t.Errorf("expected Content-Type 'text/plain', got %s", w.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("success with html content type", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    headers = {
+      ["Content-Type"] = "text/html"
+    },
+    body = '<html><body><h1>Hello World</h1></body></html>'
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+
+		if w.Header().Get("Content-Type") != "text/html" {
+			t.Errorf("expected Content-Type 'text/html', got %s", w.Header().Get("Content-Type"))
+		}
+
+		expectedBody := "<html><body><h1>Hello World</h1></body></html>"
+		if w.Body.String() != expectedBody {
+			t.Errorf("expected body %q, got %q", expectedBody, w.Body.String())
+		}
+	})
+
+	t.Run("default content type when not specified", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = '{"message": "hello"}'
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+
+		if w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("expected Content-Type 'application/json', got %s", w.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("error with syntax error in lua code", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200
+    -- missing comma
+    body = 'test'
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+	})
+
+	t.Run("error with runtime error in lua code", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  error("Something went wrong!")
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+
+		var resp map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		if resp["error"] != "Function execution failed" {
+			t.Errorf("expected generic error message, got %q", resp["error"])
+		}
+
+		if w.Header().Get("X-Execution-Id") == "" {
+			t.Error("expected X-Execution-Id header even on error")
+		}
+	})
+
+	t.Run("error with function not found", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/fn/nonexistent", nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("error with no active version", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := store.Function{
+			ID:          "test-no-version",
+			Name:        "test",
+			Description: nil,
+			EnvVars:     map[string]string{},
+		}
+		_, err := database.CreateFunction(context.Background(), fn)
+		if err != nil {
+			t.Fatalf("Failed to create function: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+	})
+
+	t.Run("different HTTP methods", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		server := NewServer(ServerConfig{
+			DB:         database,
+			Logger:     logger.NewMemoryLogger(),
+			KVStore:    kv.NewMemoryStore(),
+			EnvStore:   env.NewMemoryStore(),
+			HTTPClient: internalhttp.NewDefaultClient(),
+			APIKey:     "test-api-key",
+		})
+
+		fn := createTestFunction(t, database)
+		_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = '{"method": "' .. event.method .. '"}'
+  }
+end
+`, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create version: %v", err)
+		}
+
+		methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+		for _, method := range methods {
+			t.Run(method, func(t *testing.T) {
+				req := httptest.NewRequest(method, "/fn/"+fn.ID, nil)
+				w := httptest.NewRecorder()
+
+				server.Handler().ServeHTTP(w, req)
+
+				if w.Code != http.StatusOK {
+					t.Errorf("expected status 200 for %s, got %d", method, w.Code)
+				}
+			})
+		}
+	})
+}
+
+func TestUpdateFunction_ToggleDisabled(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	// Create a test function first
+	fn := createTestFunction(t, database)
+
+	// Disable the function
+	disabled := true
+	reqBody := store.UpdateFunctionRequest{
+		Disabled: &disabled,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	// Verify the function is disabled
+	updated, err := database.GetFunction(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("failed to get updated function: %v", err)
+	}
+
+	if !updated.Disabled {
+		t.Error("expected function to be disabled")
+	}
+
+	// Enable the function again
+	enabled := false
+	reqBody2 := store.UpdateFunctionRequest{
+		Disabled: &enabled,
+	}
+
+	body2, _ := json.Marshal(reqBody2)
+	req2 := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body2)
+	w2 := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w2, req2)
+
+	if w2.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w2.Code)
+	}
+
+	// Verify the function is enabled
+	reenabled, err := database.GetFunction(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("failed to get re-enabled function: %v", err)
+	}
+
+	if reenabled.Disabled {
+		t.Error("expected function to be enabled")
+	}
+}
+
+func TestUpdateFunction_RetentionDays(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	// Create a test function first
+	fn := createTestFunction(t, database)
+
+	// Update retention days to 30
+	retentionDays := 30
+	reqBody := store.UpdateFunctionRequest{
+		RetentionDays: &retentionDays,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	// Verify the retention days was updated
+	updated, err := database.GetFunction(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("failed to get updated function: %v", err)
+	}
+
+	if updated.RetentionDays == nil {
+		t.Fatal("expected retention_days to be set")
+	}
+
+	if *updated.RetentionDays != 30 {
+		t.Errorf("expected retention_days to be 30, got %d", *updated.RetentionDays)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+
+	// Invalid TTLs are rejected
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, []byte(`{"cache_ttl":-1}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a negative cache_ttl, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, []byte(`{"cache_ttl":60,"cache_vary_headers":["Accept-Language"]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	call := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	miss := call()
+	if miss.Header().Get("X-Cache") != "MISS" || miss.Header().Get("Cache-Control") != "max-age=60" {
+		t.Errorf("expected a cache miss, got %v", miss.Header())
+	}
+	hit := call()
+	if hit.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected a cache hit, got %v", hit.Header())
+	}
+	if hit.Header().Get("X-Execution-Id") != miss.Header().Get("X-Execution-Id") {
+		t.Errorf("expected the hit to report the cached execution %s, got %s",
+			miss.Header().Get("X-Execution-Id"), hit.Header().Get("X-Execution-Id"))
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodDelete, "/api/functions/"+fn.ID+"/cache", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if got := call().Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("expected a miss after purging the cache, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodDelete, "/api/functions/missing/cache", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown function, got %d", w.Code)
+	}
+
+	// Deleting the function drops its cached responses
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodDelete, "/api/functions/"+fn.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if keys, _ := server.kvStore.Keys("cache:" + fn.ID); len(keys) != 0 {
+		t.Errorf("expected no cached responses after deleting the function, got %d", len(keys))
+	}
+}
+
+func TestExecuteFunction_DisabledFunction(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
+	})
+
+	// Create a test function
+	fn := createTestFunction(t, database)
+	_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = '{"message": "success"}'
+  }
+end
+`, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	// Disable the function
+	disabled := true
+	updates := store.UpdateFunctionRequest{
+		Disabled: &disabled,
+	}
+	if err := database.UpdateFunction(context.Background(), fn.ID, updates); err != nil {
+		t.Fatalf("Failed to disable function: %v", err)
+	}
+
+	// Try to execute the disabled function
+	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	// Should return 403 Forbidden
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp["error"] != "Function is disabled" {
+		t.Errorf("expected error 'Function is disabled', got %q", resp["error"])
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	server := createTestServer(store.NewMemoryDB())
+
+	req := makeAuthRequest(http.MethodOptions, "/api/functions", nil)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 for OPTIONS, got %d", w.Code)
+	}
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("expected CORS headers")
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200, body = ctx.requestId}\nend")
+
+	// A generated ID is returned and passed to the function
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil))
+	id := w.Header().Get(HeaderRequestID)
+	if id == "" || w.Body.String() != id {
+		t.Errorf("expected the request ID %q in the body, got %q", id, w.Body.String())
+	}
+
+	// A usable ID from the client is kept, others are replaced
+	for sent, kept := range map[string]bool{"proxy-123": true, "bad id": false, strings.Repeat("a", 200): false} {
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		req.Header.Set(HeaderRequestID, sent)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if got := w.Header().Get(HeaderRequestID); (got == sent) != kept || got == "" {
+			t.Errorf("sent %q: got request ID %q, expected kept=%v", sent, got, kept)
+		}
+	}
+}
+
+func TestClientMiddleware(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:             database,
+		Logger:         logger.NewMemoryLogger(),
+		KVStore:        kv.NewMemoryStore(),
+		EnvStore:       env.NewMemoryStore(),
+		HTTPClient:     internalhttp.NewFakeClient(),
+		APIKey:         "test-api-key",
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  return {statusCode = 200, body = event.remote_ip .. \" \" .. event.scheme}\nend")
+
+	tests := []struct {
+		name  string
+		peer  string
+		xff   string
+		proto string
+		want  string
+	}{
+		{name: "untrusted peer without headers", peer: "203.0.113.9:4000", want: "203.0.113.9 http"},
+		{name: "untrusted peer spoofing headers", peer: "203.0.113.9:4000", xff: "198.51.100.1", proto: "https", want: "203.0.113.9 http"},
+		{name: "trusted peer", peer: "10.0.0.2:4000", xff: "198.51.100.1", proto: "https", want: "198.51.100.1 https"},
+		{name: "trusted peer without headers", peer: "10.0.0.2:4000", want: "10.0.0.2 http"},
+		{name: "client prepending an address", peer: "10.0.0.2:4000", xff: "1.2.3.4, 198.51.100.1, 10.0.0.3", want: "198.51.100.1 http"},
+		{name: "only trusted hops", peer: "10.0.0.2:4000", xff: "10.0.0.4, 10.0.0.3", want: "10.0.0.4 http"},
+		{name: "malformed hop", peer: "10.0.0.2:4000", xff: "198.51.100.1, not-an-ip", want: "10.0.0.2 http"},
+		{name: "unknown proto", peer: "10.0.0.2:4000", proto: "gopher", want: "10.0.0.2 http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+			req.RemoteAddr = tt.peer
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK || w.Body.String() != tt.want {
+				t.Errorf("expected %q, got %d %q", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}), RequestIDMiddleware(func(ids.Kind) string { return "req-1" }), LoggingMiddleware(1e-12))
+
+	for _, path := range []string{"/ok", HealthPath, "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	logged := buf.String()
+	if strings.Count(logged, "HTTP request") != 1 {
+		t.Fatalf("expected only the server error to be logged, got:\n%s", logged)
+	}
+	for _, want := range []string{"path=/fail", "status=500", "request_id=req-1", "duration_ms="} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected %q in the access log, got %s", want, logged)
+		}
+	}
+
+	// The health check answers without authentication
+	w := httptest.NewRecorder()
+	createTestServer(store.NewMemoryDB()).Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected health check status 200, got %d", w.Code)
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:          database,
+		Logger:      logger.NewMemoryLogger(),
+		KVStore:     kv.NewMemoryStore(),
+		EnvStore:    env.NewMemoryStore(),
+		HTTPClient:  internalhttp.NewDefaultClient(),
+		APIKey:      "test-api-key",
+		GzipMinSize: 100,
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  local size = tonumber(event.query.size or "500")
+  return {
+    statusCode = 200,
+    headers = { ["Content-Type"] = event.query.type or "text/plain" },
+    body = string.rep("a", size)
+  }
+end
+`)
+
+	tests := []struct {
+		name           string
+		query          string
+		acceptEncoding string
+		compressed     bool
+		size           int
+	}{
+		{name: "large body", query: "", acceptEncoding: "gzip, deflate, br", compressed: true, size: 500},
+		{name: "small body", query: "?size=50", acceptEncoding: "gzip", compressed: false, size: 50},
+		{name: "gzip not accepted", query: "", acceptEncoding: "br", compressed: false, size: 500},
+		{name: "gzip refused", query: "", acceptEncoding: "gzip;q=0, br", compressed: false, size: 500},
+		{name: "already compressed type", query: "?type=image/png", acceptEncoding: "gzip", compressed: false, size: 500},
+		{name: "svg is compressed", query: "?type=image/svg%2Bxml", acceptEncoding: "gzip", compressed: true, size: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID+tt.query, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("expected Vary: Accept-Encoding, got %q", got)
+			}
+			if got := w.Header().Get("X-Execution-Id"); got == "" {
+				t.Error("expected execution headers to be kept")
+			}
+
+			body := w.Body.Bytes()
+			if tt.compressed {
+				if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+					t.Fatalf("expected Content-Encoding gzip, got %q", got)
+				}
+				reader, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("failed to read gzip body: %v", err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatalf("failed to decompress body: %v", err)
+				}
+			} else if got := w.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("expected no Content-Encoding, got %q", got)
+			}
+			if string(body) != strings.Repeat("a", tt.size) {
+				t.Errorf("unexpected body of %d bytes", len(body))
+			}
+		})
+	}
+
+	// The management API is compressed too
+	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/versions/active", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("expected API response to be compressed, got Content-Encoding %q", got)
+	}
+
+	// So are functions routed by prefix, but not the paths no function owns
+	body, _ := json.Marshal(map[string]string{"route_prefix": "/app"})
+	req = makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 setting route prefix, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/app/page", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("expected prefix-routed response to be compressed, got Content-Encoding %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/other", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Errorf("expected unrouted path to be left alone, got Vary %q", got)
+	}
+}
+
+func TestGzipMiddleware_Streaming(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:          database,
+		Logger:      logger.NewMemoryLogger(),
+		KVStore:     kv.NewMemoryStore(),
+		EnvStore:    env.NewMemoryStore(),
+		HTTPClient:  internalhttp.NewDefaultClient(),
+		APIKey:      "test-api-key",
+		GzipMinSize: 1,
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  sse.send("progress", "50")
+end
+`)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/fn/"+fn.ID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("expected event stream to be sent uncompressed, got Content-Encoding %q", got)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "event: progress\ndata: 50\n\n" {
+		t.Errorf("unexpected event stream %q", body)
+	}
+}
+
+func TestExecuteFunction_EventJSONStorage(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = '{"message": "success"}'
+  }
+end
+`, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	// Create a request with specific headers, query params, and body
+	requestBody := `{"test": "data", "number": 42}`
+	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID+"?param1=value1&param2=value2", bytes.NewReader([]byte(requestBody)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Custom-Header", "custom-value")
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Get the execution ID from the response header
+	executionID := w.Header().Get("X-Execution-Id")
+	if executionID == "" {
+		t.Fatal("expected X-Execution-Id header")
+	}
+
+	// Retrieve the execution from the database
+	execution, err := database.GetExecution(context.Background(), executionID)
+	if err != nil {
+		t.Fatalf("Failed to get execution: %v", err)
+	}
+
+	// Verify event JSON was stored
+	if execution.EventJSON == nil {
+		t.Fatal("Expected EventJSON to be stored")
+	}
+
+	// Parse and verify the event JSON content
+	var eventData map[string]any
+	if err := json.Unmarshal([]byte(*execution.EventJSON), &eventData); err != nil {
+		t.Fatalf("Failed to parse event JSON: %v", err)
+	}
+
+	// Verify method
+	if method, ok := eventData["method"].(string); !ok || method != "POST" {
+		t.Errorf("Expected method POST, got %v", eventData["method"])
+	}
+
+	// Verify path
+	if path, ok := eventData["path"].(string); !ok || path != "/fn/"+fn.ID {
+		t.Errorf("Expected path /fn/%s, got %v", fn.ID, eventData["path"])
+	}
+
+	// Verify body is present (JSON order may vary, so just check it's not empty)
+	if body, ok := eventData["body"].(string); !ok || body == "" {
+		t.Errorf("Expected body to be present, got %v", eventData["body"])
+	}
+
+	// Verify headers are present
+	headers, ok := eventData["headers"].(map[string]any)
+	if !ok {
+		t.Fatal("Expected headers to be present")
+	}
+
+	if contentType, ok := headers["Content-Type"].(string); !ok || contentType != "application/json" {
+		t.Errorf("Expected Content-Type header, got %v", headers["Content-Type"])
+	}
+
+	if customHeader, ok := headers["X-Custom-Header"].(string); !ok || customHeader != "custom-value" {
+		t.Errorf("Expected X-Custom-Header, got %v", headers["X-Custom-Header"])
+	}
+
+	// Authorization header should be masked now
+	if authHeader, ok := headers["Authorization"].(string); !ok || authHeader != "[REDACTED]" {
+		t.Errorf("Expected Authorization header to be [REDACTED], got %v", headers["Authorization"])
+	}
+
+	// Verify query parameters
+	query, ok := eventData["query"].(map[string]any)
+	if !ok {
+		t.Fatal("Expected query to be present")
+	}
+
+	if param1, ok := query["param1"].(string); !ok || param1 != "value1" {
+		t.Errorf("Expected param1=value1, got %v", query["param1"])
+	}
+
+	if param2, ok := query["param2"].(string); !ok || param2 != "value2" {
+		t.Errorf("Expected param2=value2, got %v", query["param2"])
+	}
+}
+
+func TestExecuteFunction_EventJSONWithDifferentMethods(t *testing.T) {
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+
+	for _, method := range methods {
+		t.Run(method, func(t *testing.T) {
+			database := store.NewMemoryDB()
+			server := NewServer(ServerConfig{
+				DB:         database,
+				Logger:     logger.NewMemoryLogger(),
+				KVStore:    kv.NewMemoryStore(),
+				EnvStore:   env.NewMemoryStore(),
+				HTTPClient: internalhttp.NewDefaultClient(),
+				APIKey:     "test-api-key",
+			})
+
+			fn := createTestFunction(t, database)
+			_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = '{"ok": true}'
+  }
+end
+`, nil, nil)
+			if err != nil {
+				t.Fatalf("Failed to create version: %v", err)
+			}
+
+			req := httptest.NewRequest(method, "/fn/"+fn.ID, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+
+			executionID := w.Header().Get("X-Execution-Id")
+			if executionID == "" {
+				t.Fatal("expected X-Execution-Id header")
+			}
+
+			execution, err := database.GetExecution(context.Background(), executionID)
+			if err != nil {
+				t.Fatalf("Failed to get execution: %v", err)
+			}
+
+			if execution.EventJSON == nil {
+				t.Fatal("Expected EventJSON to be stored")
+			}
+
+			var eventData map[string]interface{}
+			if err := json.Unmarshal([]byte(*execution.EventJSON), &eventData); err != nil {
+				t.Fatalf("Failed to parse event JSON: %v", err)
+			}
+
+			if eventMethod, ok := eventData["method"].(string); !ok || eventMethod != method {
+				t.Errorf("Expected method %s, got %v", method, eventData["method"])
+			}
+		})
+	}
+}
+
+func TestExecuteFunction_SensitiveDataMasking(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	_, err := database.CreateVersion(context.Background(), fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    body = '{"message": "success"}'
+  }
+end
+`, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+
+	// Create a request with sensitive headers and body
+	requestBody := `{"username":"john","password":"secret123","api_key":"my-secret-api-key"}`
+	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID+"?api_key=secret-query-key&limit=10", bytes.NewReader([]byte(requestBody)))
+	req.Header.Set("Authorization", "Bearer secret_token_12345")
+	req.Header.Set("Cookie", "auth_token=f150e53a96f53affce140b818440d8aef5e499038cdc2860ff07b3e6f036d6f1")
+	req.Header.Set("X-API-Key", "my-api-key-123")
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	executionID := w.Header().Get("X-Execution-Id")
+	if executionID == "" {
+		t.Fatal("expected X-Execution-Id header")
+	}
+
+	// Retrieve the execution from the database
+	execution, err := database.GetExecution(context.Background(), executionID)
+	if err != nil {
+		t.Fatalf("Failed to get execution: %v", err)
+	}
+
+	if execution.EventJSON == nil {
+		t.Fatal("Expected EventJSON to be stored")
+	}
+
+	// Parse and verify the event JSON has masked sensitive data
+	var eventData map[string]any
+	if err := json.Unmarshal([]byte(*execution.EventJSON), &eventData); err != nil {
+		t.Fatalf("Failed to parse event JSON: %v", err)
+	}
+
+	// Verify sensitive headers are masked
+	headers, ok := eventData["headers"].(map[string]any)
+	if !ok {
+		t.Fatal("Expected headers to be present")
+	}
+
+	if auth, ok := headers["Authorization"].(string); !ok || auth != "[REDACTED]" {
+		t.Errorf("Expected Authorization header to be [REDACTED], got %v", headers["Authorization"])
+	}
+
+	if cookie, ok := headers["Cookie"].(string); !ok || cookie != "[REDACTED]" {
+		t.Errorf("Expected Cookie header to be [REDACTED], got %v", headers["Cookie"])
+	}
+
+	// X-API-Key header might be stored with different casing
+	apiKeyFound := false
+	for key, value := range headers {
+		if strings.ToLower(key) == "x-api-key" {
+			apiKeyFound = true
+			if strValue, ok := value.(string); !ok || strValue != "[REDACTED]" {
+				t.Errorf("Expected X-API-Key header to be [REDACTED], got %v", value)
+			}
+			break
+		}
+	}
+	if !apiKeyFound {
+		t.Error("Expected X-API-Key header to be present in headers")
+	}
+
+	// Verify non-sensitive headers are not masked
+	if contentType, ok := headers["Content-Type"].(string); !ok || contentType != "application/json" {
+		t.Errorf("Expected Content-Type header to be unchanged, got %v", headers["Content-Type"])
+	}
+
+	// Verify sensitive query params are masked
+	query, ok := eventData["query"].(map[string]any)
+	if !ok {
+		t.Fatal("Expected query to be present")
+	}
+
+	if apiKeyQuery, ok := query["api_key"].(string); !ok || apiKeyQuery != "[REDACTED]" {
+		t.Errorf("Expected api_key query param to be [REDACTED], got %v", query["api_key"])
+	}
+
+	// Verify non-sensitive query params are not masked
+	if limit, ok := query["limit"].(string); !ok || limit != "10" {
+		t.Errorf("Expected limit query param to be unchanged, got %v", query["limit"])
+	}
+
+	// Verify sensitive body fields are masked
+	body, ok := eventData["body"].(string)
+	if !ok {
+		t.Fatal("Expected body to be present")
+	}
+
+	// Parse the body JSON
+	var bodyData map[string]any
+	if err := json.Unmarshal([]byte(body), &bodyData); err != nil {
+		t.Fatalf("Failed to parse body JSON: %v", err)
+	}
+
+	if password, ok := bodyData["password"].(string); !ok || password != "[REDACTED]" {
+		t.Errorf("Expected password field to be [REDACTED], got %v", bodyData["password"])
+	}
+
+	if apiKeyBody, ok := bodyData["api_key"].(string); !ok || apiKeyBody != "[REDACTED]" {
+		t.Errorf("Expected api_key field to be [REDACTED], got %v", bodyData["api_key"])
+	}
+
+	// Verify non-sensitive body fields are not masked
+	if username, ok := bodyData["username"].(string); !ok || username != "john" {
+		t.Errorf("Expected username field to be unchanged, got %v", bodyData["username"])
+	}
+}
+
+func TestUpdateFunction_SaveResponse(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	// Create a test function first
+	fn := createTestFunction(t, database)
+
+	// Enable save_response
+	saveResponse := true
+	reqBody := store.UpdateFunctionRequest{
+		SaveResponse: &saveResponse,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
+	w := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	// Verify the save_response was updated
+	updated, err := database.GetFunction(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("failed to get updated function: %v", err)
+	}
+
+	if !updated.SaveResponse {
+		t.Error("expected save_response to be true")
+	}
+
+	// Disable save_response
+	saveResponseFalse := false
+	reqBody2 := store.UpdateFunctionRequest{
+		SaveResponse: &saveResponseFalse,
+	}
+
+	body2, _ := json.Marshal(reqBody2)
+	req2 := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body2)
+	w2 := httptest.NewRecorder()
+
+	server.Handler().ServeHTTP(w2, req2)
+
+	if w2.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w2.Code)
+	}
+
+	// Verify the save_response was updated
+	updated2, err := database.GetFunction(context.Background(), fn.ID)
+	if err != nil {
+		t.Fatalf("failed to get updated function: %v", err)
+	}
+
+	if updated2.SaveResponse {
+		t.Error("expected save_response to be false")
+	}
+}
+
+func TestExecuteFunction_BasicAuth(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  local username, password, ok = event.basic_auth()
+  if not ok or password ~= "s3cret" then
+    return { statusCode = 401, headers = { ["WWW-Authenticate"] = "Basic" } }
+  end
+  return { statusCode = 200, body = "hello " .. username }
+end
+`)
+
+	req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	req.SetBasicAuth("ana", "s3cret")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "hello ana" {
+		t.Fatalf("expected the function to see the real credentials, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The stored event keeps the header masked
+	execution, err := database.GetExecution(context.Background(), w.Header().Get("X-Execution-Id"))
+	if err != nil {
+		t.Fatalf("Failed to get execution: %v", err)
+	}
+	var event struct {
+		Headers map[string]string `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(*execution.EventJSON), &event); err != nil {
+		t.Fatalf("Failed to parse event JSON: %v", err)
+	}
+	if got := event.Headers["Authorization"]; got != "[REDACTED]" {
+		t.Errorf("expected the stored Authorization header to be masked, got %q", got)
+	}
+}
+
+func TestExecuteFunction_SaveResponse(t *testing.T) {
+	t.Run("saves response when enabled", func(t *testing.T) {
 		database := store.NewMemoryDB()
 		server := NewServer(ServerConfig{
 			DB:         database,
@@ -741,27 +4417,79 @@ end
 		_, err := database.CreateVersion(context.Background(), fn.ID, `
 function handler(ctx, event)
   return {
-    statusCode = 200
-    -- missing comma
-    body = 'test'
+    statusCode = 200,
+    headers = {
+      ["Content-Type"] = "application/json"
+    },
+    body = '{"message": "success"}'
   }
 end
-`, nil)
+`, nil, nil)
 		if err != nil {
 			t.Fatalf("Failed to create version: %v", err)
 		}
 
+		// Enable save_response
+		saveResponse := true
+		if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{
+			SaveResponse: &saveResponse,
+		}); err != nil {
+			t.Fatalf("Failed to enable save_response: %v", err)
+		}
+
 		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
 		w := httptest.NewRecorder()
 
 		server.Handler().ServeHTTP(w, req)
 
-		if w.Code != http.StatusInternalServerError {
-			t.Errorf("expected status 500, got %d", w.Code)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		// Get the execution ID from the response header
+		executionID := w.Header().Get("X-Execution-Id")
+		if executionID == "" {
+			t.Fatal("expected X-Execution-Id header")
+		}
+
+		// Retrieve the execution from the database
+		execution, err := database.GetExecution(context.Background(), executionID)
+		if err != nil {
+			t.Fatalf("Failed to get execution: %v", err)
+		}
+
+		// Verify response JSON was stored
+		if execution.ResponseJSON == nil {
+			t.Fatal("Expected ResponseJSON to be stored when save_response is enabled")
+		}
+
+		// Parse and verify the response JSON content
+		var responseData map[string]any
+		if err := json.Unmarshal([]byte(*execution.ResponseJSON), &responseData); err != nil {
+			t.Fatalf("Failed to parse response JSON: %v", err)
+		}
+
+		// Verify status code
+		if statusCode, ok := responseData["statusCode"].(float64); !ok || int(statusCode) != 200 {
+			t.Errorf("Expected statusCode 200, got %v", responseData["statusCode"])
+		}
+
+		// Verify body
+		if body, ok := responseData["body"].(string); !ok || body != `{"message": "success"}` {
+			t.Errorf("Expected body to be stored, got %v", responseData["body"])
+		}
+
+		// Verify headers
+		if headers, ok := responseData["headers"].(map[string]any); ok {
+			if contentType, ok := headers["Content-Type"].(string); !ok || contentType != "application/json" {
+				t.Errorf("Expected Content-Type header, got %v", headers["Content-Type"])
+			}
+		} else {
+			t.Error("Expected headers to be present in response JSON")
 		}
 	})
 
-	t.Run("error with runtime error in lua code", func(t *testing.T) {
+	t.Run("does not save response when disabled", func(t *testing.T) {
 		database := store.NewMemoryDB()
 		server := NewServer(ServerConfig{
 			DB:         database,
@@ -775,804 +4503,1533 @@ end
 		fn := createTestFunction(t, database)
 		_, err := database.CreateVersion(context.Background(), fn.ID, `
 function handler(ctx, event)
-  error("Something went wrong!")
+  return {
+    statusCode = 200,
+    body = '{"message": "success"}'
+  }
 end
-`, nil)
+`, nil, nil)
 		if err != nil {
 			t.Fatalf("Failed to create version: %v", err)
 		}
 
+		// save_response is disabled by default
+
 		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
 		w := httptest.NewRecorder()
 
 		server.Handler().ServeHTTP(w, req)
 
-		if w.Code != http.StatusInternalServerError {
-			t.Errorf("expected status 500, got %d", w.Code)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 		}
 
-		var resp map[string]string
-		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
-			t.Fatalf("failed to decode response: %v", err)
+		// Get the execution ID from the response header
+		executionID := w.Header().Get("X-Execution-Id")
+		if executionID == "" {
+			t.Fatal("expected X-Execution-Id header")
 		}
 
-		if resp["error"] != "Function execution failed" {
-			t.Errorf("expected generic error message, got %q", resp["error"])
+		// Retrieve the execution from the database
+		execution, err := database.GetExecution(context.Background(), executionID)
+		if err != nil {
+			t.Fatalf("Failed to get execution: %v", err)
 		}
 
-		if w.Header().Get("X-Execution-Id") == "" {
-			t.Error("expected X-Execution-Id header even on error")
+		// Verify response JSON was NOT stored
+		if execution.ResponseJSON != nil {
+			t.Error("Expected ResponseJSON to be nil when save_response is disabled")
 		}
 	})
+}
 
-	t.Run("error with function not found", func(t *testing.T) {
-		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
-			DB:         database,
-			Logger:     logger.NewMemoryLogger(),
-			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
-			HTTPClient: internalhttp.NewDefaultClient(),
-			APIKey:     "test-api-key",
-		})
+func TestExecuteFunction_ETag(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
 
-		req := httptest.NewRequest(http.MethodPost, "/fn/nonexistent", nil)
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    etag = "v1",
+    body = '{"message": "cached"}'
+  }
+end
+`)
+
+	t.Run("matching If-None-Match returns 304", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		req.Header.Set("If-None-Match", `"v1"`)
 		w := httptest.NewRecorder()
 
 		server.Handler().ServeHTTP(w, req)
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("expected status 404, got %d", w.Code)
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("expected status 304, got %d: %s", w.Code, w.Body.String())
 		}
-	})
-
-	t.Run("error with no active version", func(t *testing.T) {
-		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
-			DB:         database,
-			Logger:     logger.NewMemoryLogger(),
-			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
-			HTTPClient: internalhttp.NewDefaultClient(),
-			APIKey:     "test-api-key",
-		})
-
-		fn := store.Function{
-			ID:          "test-no-version",
-			Name:        "test",
-			Description: nil,
-			EnvVars:     map[string]string{},
+		if w.Body.Len() != 0 {
+			t.Errorf("expected empty body, got %q", w.Body.String())
 		}
-		_, err := database.CreateFunction(context.Background(), fn)
-		if err != nil {
-			t.Fatalf("Failed to create function: %v", err)
+		if got := w.Header().Get("ETag"); got != `"v1"` {
+			t.Errorf("expected ETag %q, got %q", `"v1"`, got)
 		}
+	})
 
-		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+	t.Run("non-matching If-None-Match returns full response", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		req.Header.Set("If-None-Match", `"v0", W/"other"`)
 		w := httptest.NewRecorder()
 
 		server.Handler().ServeHTTP(w, req)
 
-		if w.Code != http.StatusInternalServerError {
-			t.Errorf("expected status 500, got %d", w.Code)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if w.Body.String() != `{"message": "cached"}` {
+			t.Errorf("unexpected body: %s", w.Body.String())
+		}
+		if got := w.Header().Get("ETag"); got != `"v1"` {
+			t.Errorf("expected ETag %q, got %q", `"v1"`, got)
 		}
 	})
 
-	t.Run("different HTTP methods", func(t *testing.T) {
-		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
-			DB:         database,
-			Logger:     logger.NewMemoryLogger(),
-			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
-			HTTPClient: internalhttp.NewDefaultClient(),
-			APIKey:     "test-api-key",
-		})
+	t.Run("matching If-None-Match on POST returns full response", func(t *testing.T) {
+		for _, ifNoneMatch := range []string{`"v1"`, "*"} {
+			req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+			req.Header.Set("If-None-Match", ifNoneMatch)
+			w := httptest.NewRecorder()
 
-		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("If-None-Match %s: expected status 200, got %d: %s", ifNoneMatch, w.Code, w.Body.String())
+			}
+			if w.Body.String() != `{"message": "cached"}` {
+				t.Errorf("If-None-Match %s: unexpected body: %s", ifNoneMatch, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("matching If-None-Match on an error returns full response", func(t *testing.T) {
+		failing, err := database.CreateFunction(context.Background(), store.Function{ID: "func_etag_error", Name: "etag-error"})
+		if err != nil {
+			t.Fatalf("failed to create function: %v", err)
+		}
+		createTestVersion(t, database, failing.ID, `
 function handler(ctx, event)
   return {
-    statusCode = 200,
-    body = '{"method": "' .. event.method .. '"}'
+    statusCode = 500,
+    etag = "v1",
+    body = '{"error": "boom"}'
   }
 end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
-
-		methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
-		for _, method := range methods {
-			t.Run(method, func(t *testing.T) {
-				req := httptest.NewRequest(method, "/fn/"+fn.ID, nil)
-				w := httptest.NewRecorder()
+`)
+		for _, ifNoneMatch := range []string{`"v1"`, "*"} {
+			req := httptest.NewRequest(http.MethodGet, "/fn/"+failing.ID, nil)
+			req.Header.Set("If-None-Match", ifNoneMatch)
+			w := httptest.NewRecorder()
 
-				server.Handler().ServeHTTP(w, req)
+			server.Handler().ServeHTTP(w, req)
 
-				if w.Code != http.StatusOK {
-					t.Errorf("expected status 200 for %s, got %d", method, w.Code)
-				}
-			})
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("If-None-Match %s: expected status 500, got %d: %s", ifNoneMatch, w.Code, w.Body.String())
+			}
+			if w.Body.String() != `{"error": "boom"}` {
+				t.Errorf("If-None-Match %s: unexpected body: %s", ifNoneMatch, w.Body.String())
+			}
 		}
 	})
 }
 
-func TestUpdateFunction_ToggleDisabled(t *testing.T) {
+func TestExecuteFunction_AllowedMethods(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
 
-	// Create a test function first
 	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return { statusCode = 200, body = event.method }
+end
+`)
 
-	// Disable the function
-	disabled := true
-	reqBody := store.UpdateFunctionRequest{
-		Disabled: &disabled,
+	methods := []string{"POST"}
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{AllowedMethods: &methods}); err != nil {
+		t.Fatalf("Failed to update function: %v", err)
 	}
 
-	body, _ := json.Marshal(reqBody)
-	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
-	w := httptest.NewRecorder()
+	t.Run("allowed method executes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
 
-	server.Handler().ServeHTTP(w, req)
+		server.Handler().ServeHTTP(w, req)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if w.Body.String() != "POST" {
+			t.Errorf("expected body POST, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("disallowed method returns 405", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected status 405, got %d: %s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Allow"); got != "POST" {
+			t.Errorf("expected Allow header POST, got %q", got)
+		}
+		if w.Header().Get("X-Execution-Id") != "" {
+			t.Error("expected no execution for a disallowed method")
+		}
+	})
+}
+
+func TestExecuteFunction_AllowedMethodsCron(t *testing.T) {
+	database := store.NewMemoryDB()
+	scheduler := internalcron.NewScheduler(database, "")
+	server := NewServer(ServerConfig{
+		DB:         database,
+		Logger:     logger.NewMemoryLogger(),
+		KVStore:    kv.NewMemoryStore(),
+		EnvStore:   env.NewMemoryStore(),
+		HTTPClient: internalhttp.NewDefaultClient(),
+		Scheduler:  scheduler,
+		APIKey:     "test-api-key",
+	})
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return { statusCode = 200, body = event.method }
+end
+`)
+
+	methods := []string{"GET"}
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{AllowedMethods: &methods}); err != nil {
+		t.Fatalf("Failed to update function: %v", err)
 	}
 
-	// Verify the function is disabled
-	updated, err := database.GetFunction(context.Background(), fn.ID)
-	if err != nil {
-		t.Fatalf("failed to get updated function: %v", err)
+	tests := []struct {
+		name       string
+		cronToken  string
+		wantStatus int
+	}{
+		{name: "scheduler fire runs", cronToken: scheduler.Token(), wantStatus: http.StatusOK},
+		{name: "wrong cron token returns 405", cronToken: "not-the-token", wantStatus: http.StatusMethodNotAllowed},
 	}
 
-	if !updated.Disabled {
-		t.Error("expected function to be disabled")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+			req.Header.Set("X-Trigger", "cron")
+			req.Header.Set(internalcron.HeaderCronToken, tt.cronToken)
+			w := httptest.NewRecorder()
+
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			exec, err := database.GetExecution(context.Background(), w.Header().Get("X-Execution-Id"))
+			if err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			if exec.Trigger != store.ExecutionTriggerCron {
+				t.Errorf("expected trigger cron, got %q", exec.Trigger)
+			}
+		})
 	}
+}
 
-	// Enable the function again
-	enabled := false
-	reqBody2 := store.UpdateFunctionRequest{
-		Disabled: &enabled,
-	}
+func TestExecuteFunction_Timeout(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := NewServer(ServerConfig{
+		DB:               database,
+		Logger:           logger.NewMemoryLogger(),
+		KVStore:          kv.NewMemoryStore(),
+		EnvStore:         env.NewMemoryStore(),
+		HTTPClient:       internalhttp.NewDefaultClient(),
+		APIKey:           "test-api-key",
+		ExecutionTimeout: 100 * time.Millisecond,
+	})
 
-	body2, _ := json.Marshal(reqBody2)
-	req2 := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body2)
-	w2 := httptest.NewRecorder()
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  while true do end\nend")
 
-	server.Handler().ServeHTTP(w2, req2)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil))
 
-	if w2.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w2.Code)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status 504, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "timed out after 100ms") {
+		t.Errorf("expected the timeout in the message, got %s", w.Body.String())
 	}
 
-	// Verify the function is enabled
-	reenabled, err := database.GetFunction(context.Background(), fn.ID)
+	exec, err := database.GetExecution(context.Background(), w.Header().Get("X-Execution-Id"))
 	if err != nil {
-		t.Fatalf("failed to get re-enabled function: %v", err)
+		t.Fatalf("GetExecution failed: %v", err)
 	}
-
-	if reenabled.Disabled {
-		t.Error("expected function to be enabled")
+	if exec.Status != store.ExecutionStatusError || exec.ErrorMessage == nil || !strings.Contains(*exec.ErrorMessage, "timed out") {
+		t.Errorf("expected a failed execution recording the timeout, got %+v", exec)
 	}
 }
 
-func TestUpdateFunction_RetentionDays(t *testing.T) {
+func TestExecuteFunction_Head(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
 
-	// Create a test function first
 	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return {
+    statusCode = 200,
+    headers = { ["Content-Type"] = "text/plain", ["X-Method"] = event.method },
+    body = "hello"
+  }
+end
+`)
 
-	// Update retention days to 30
-	retentionDays := 30
-	reqBody := store.UpdateFunctionRequest{
-		RetentionDays: &retentionDays,
+	methods := []string{"GET"}
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{AllowedMethods: &methods}); err != nil {
+		t.Fatalf("Failed to update function: %v", err)
 	}
 
-	body, _ := json.Marshal(reqBody)
-	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
+	req := httptest.NewRequest(http.MethodHead, "/fn/"+fn.ID, nil)
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
-
-	// Verify the retention days was updated
-	updated, err := database.GetFunction(context.Background(), fn.ID)
-	if err != nil {
-		t.Fatalf("failed to get updated function: %v", err)
+	if w.Body.Len() != 0 {
+		t.Errorf("expected no body for HEAD, got %q", w.Body.String())
 	}
-
-	if updated.RetentionDays == nil {
-		t.Fatal("expected retention_days to be set")
+	if got := w.Header().Get("Content-Length"); got != "5" {
+		t.Errorf("expected Content-Length 5, got %q", got)
 	}
-
-	if *updated.RetentionDays != 30 {
-		t.Errorf("expected retention_days to be 30, got %d", *updated.RetentionDays)
+	if got := w.Header().Get("Content-Type"); got != "text/plain" {
+		t.Errorf("expected Content-Type text/plain, got %q", got)
+	}
+	if got := w.Header().Get("X-Method"); got != "HEAD" {
+		t.Errorf("expected the function to see HEAD, got %q", got)
 	}
 }
 
-func TestExecuteFunction_DisabledFunction(t *testing.T) {
+func TestExecuteFunction_Options(t *testing.T) {
 	database := store.NewMemoryDB()
-	server := NewServer(ServerConfig{
-		DB:         database,
-		Logger:     logger.NewMemoryLogger(),
-		KVStore:    kv.NewMemoryStore(),
-		EnvStore:   env.NewMemoryStore(),
-		HTTPClient: internalhttp.NewDefaultClient(),
-		APIKey:     "test-api-key",
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return { statusCode = 200, headers = { ["Allow"] = "GET, OPTIONS" }, body = event.method }
+end
+`)
+
+	options := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/fn/"+fn.ID+"/items", nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("preflight by default", func(t *testing.T) {
+		w := options()
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Error("expected CORS headers")
+		}
+		if w.Header().Get("X-Execution-Id") != "" {
+			t.Error("expected no execution for a preflight request")
+		}
 	})
 
-	// Create a test function
+	t.Run("function handles OPTIONS when listed", func(t *testing.T) {
+		methods := []string{"GET", "OPTIONS"}
+		if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{AllowedMethods: &methods}); err != nil {
+			t.Fatalf("Failed to update function: %v", err)
+		}
+
+		w := options()
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if w.Body.String() != "OPTIONS" || w.Header().Get("Allow") != "GET, OPTIONS" {
+			t.Errorf("expected the function's response, got %q (Allow %q)", w.Body.String(), w.Header().Get("Allow"))
+		}
+	})
+}
+
+func TestExecuteFunction_DefaultHeaders(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
 	fn := createTestFunction(t, database)
-	_, err := database.CreateVersion(context.Background(), fn.ID, `
+	createTestVersion(t, database, fn.ID, `
 function handler(ctx, event)
   return {
     statusCode = 200,
-    body = '{"message": "success"}'
+    headers = { ["X-Frame-Options"] = "SAMEORIGIN" },
+    body = "ok"
   }
 end
-`, nil)
-	if err != nil {
-		t.Fatalf("Failed to create version: %v", err)
-	}
+`)
 
-	// Disable the function
-	disabled := true
-	updates := store.UpdateFunctionRequest{
-		Disabled: &disabled,
+	defaultHeaders := map[string]string{
+		"X-Frame-Options":         "DENY",
+		"Content-Security-Policy": "default-src 'self'",
 	}
-	if err := database.UpdateFunction(context.Background(), fn.ID, updates); err != nil {
-		t.Fatalf("Failed to disable function: %v", err)
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{DefaultHeaders: &defaultHeaders}); err != nil {
+		t.Fatalf("Failed to update function: %v", err)
 	}
 
-	// Try to execute the disabled function
-	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+	req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
 	w := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(w, req)
 
-	// Should return 403 Forbidden
-	if w.Code != http.StatusForbidden {
-		t.Errorf("expected status 403, got %d", w.Code)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
-
-	var resp map[string]string
-	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
+	if got := w.Header().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Errorf("expected default Content-Security-Policy header, got %q", got)
 	}
-
-	if resp["error"] != "Function is disabled" {
-		t.Errorf("expected error 'Function is disabled', got %q", resp["error"])
+	if got := w.Header().Get("X-Frame-Options"); got != "SAMEORIGIN" {
+		t.Errorf("expected function header to override default, got %q", got)
 	}
 }
 
-func TestCORSMiddleware(t *testing.T) {
-	server := createTestServer(store.NewMemoryDB())
+func TestExecuteFunction_ResponseHeaderSanitization(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		want    int
+	}{
+		{name: "CRLF injection", headers: `["X-Test"] = "a\r\nSet-Cookie: session=stolen"`, want: http.StatusInternalServerError},
+		{name: "bare LF", headers: `["X-Test"] = "a\nb"`, want: http.StatusInternalServerError},
+		{name: "invalid name", headers: `["X Test"] = "a"`, want: http.StatusInternalServerError},
+		{name: "value too long", headers: `["X-Test"] = string.rep("a", 8193)`, want: http.StatusInternalServerError},
+		{name: "hop-by-hop dropped", headers: `["Connection"] = "close", ["transfer-encoding"] = "gzip", ["X-Test"] = "ok"`, want: http.StatusOK},
+	}
 
-	req := makeAuthRequest(http.MethodOptions, "/api/functions", nil)
-	w := httptest.NewRecorder()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := store.NewMemoryDB()
+			server := createTestServer(database)
 
-	server.Handler().ServeHTTP(w, req)
+			fn := createTestFunction(t, database)
+			createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return { statusCode = 200, headers = { `+tt.headers+` }, body = "ok" }
+end
+`)
 
-	if w.Code != http.StatusNoContent {
-		t.Errorf("expected status 204 for OPTIONS, got %d", w.Code)
-	}
+			req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
 
-	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-		t.Error("expected CORS headers")
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Set-Cookie"); got != "" {
+				t.Errorf("expected no injected Set-Cookie header, got %q", got)
+			}
+			if tt.want == http.StatusOK {
+				if got := w.Header().Get("X-Test"); got != "ok" {
+					t.Errorf("expected X-Test header, got %q", got)
+				}
+				if w.Header().Get("Connection") != "" || w.Header().Get("Transfer-Encoding") != "" {
+					t.Errorf("expected hop-by-hop headers to be dropped, got %v", w.Header())
+				}
+			}
+		})
 	}
 }
 
-func TestExecuteFunction_EventJSONStorage(t *testing.T) {
+func TestExecuteFunction_TooManyResponseHeaders(t *testing.T) {
 	database := store.NewMemoryDB()
-	server := NewServer(ServerConfig{
-		DB:         database,
-		Logger:     logger.NewMemoryLogger(),
-		KVStore:    kv.NewMemoryStore(),
-		EnvStore:   env.NewMemoryStore(),
-		HTTPClient: internalhttp.NewDefaultClient(),
-		APIKey:     "test-api-key",
-	})
+	server := createTestServer(database)
 
 	fn := createTestFunction(t, database)
-	_, err := database.CreateVersion(context.Background(), fn.ID, `
+	createTestVersion(t, database, fn.ID, `
 function handler(ctx, event)
-  return {
-    statusCode = 200,
-    body = '{"message": "success"}'
-  }
+  local headers = {}
+  for i = 1, 51 do
+    headers["X-Header-" .. i] = "value"
+  end
+  return { statusCode = 200, headers = headers, body = "ok" }
 end
-`, nil)
-	if err != nil {
-		t.Fatalf("Failed to create version: %v", err)
-	}
-
-	// Create a request with specific headers, query params, and body
-	requestBody := `{"test": "data", "number": 42}`
-	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID+"?param1=value1&param2=value2", bytes.NewReader([]byte(requestBody)))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Custom-Header", "custom-value")
-	req.Header.Set("Authorization", "Bearer test-token")
+`)
 
+	req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
 	w := httptest.NewRecorder()
 	server.Handler().ServeHTTP(w, req)
 
-	if w.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
 	}
-
-	// Get the execution ID from the response header
-	executionID := w.Header().Get("X-Execution-Id")
-	if executionID == "" {
-		t.Fatal("expected X-Execution-Id header")
+	if w.Header().Get("X-Header-1") != "" {
+		t.Error("expected no function headers on the error response")
 	}
+}
 
-	// Retrieve the execution from the database
-	execution, err := database.GetExecution(context.Background(), executionID)
-	if err != nil {
-		t.Fatalf("Failed to get execution: %v", err)
+func TestExecuteFunction_Base64Body(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        string
+		want        int
+		body        string
+		contentType string
+	}{
+		{
+			name:        "file download",
+			code:        `return respond.file("\137PNG\0\255", "image/png", "logo.png")`,
+			want:        http.StatusOK,
+			body:        "\x89PNG\x00\xff",
+			contentType: "image/png",
+		},
+		{
+			name:        "default content type",
+			code:        `return { statusCode = 200, body = base64.encode("raw"), isBase64Encoded = true }`,
+			want:        http.StatusOK,
+			body:        "raw",
+			contentType: "application/octet-stream",
+		},
+		{
+			name: "invalid base64",
+			code: `return { statusCode = 200, body = "not base64!", isBase64Encoded = true }`,
+			want: http.StatusInternalServerError,
+		},
 	}
 
-	// Verify event JSON was stored
-	if execution.EventJSON == nil {
-		t.Fatal("Expected EventJSON to be stored")
-	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := store.NewMemoryDB()
+			server := createTestServer(database)
 
-	// Parse and verify the event JSON content
-	var eventData map[string]any
-	if err := json.Unmarshal([]byte(*execution.EventJSON), &eventData); err != nil {
-		t.Fatalf("Failed to parse event JSON: %v", err)
-	}
+			fn := createTestFunction(t, database)
+			createTestVersion(t, database, fn.ID, "function handler(ctx, event)\n  "+tt.code+"\nend")
 
-	// Verify method
-	if method, ok := eventData["method"].(string); !ok || method != "POST" {
-		t.Errorf("Expected method POST, got %v", eventData["method"])
-	}
+			req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
 
-	// Verify path
-	if path, ok := eventData["path"].(string); !ok || path != "/fn/"+fn.ID {
-		t.Errorf("Expected path /fn/%s, got %v", fn.ID, eventData["path"])
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("expected decoded body %q, got %q", tt.body, got)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("expected Content-Type %q, got %q", tt.contentType, got)
+			}
+		})
 	}
+}
 
-	// Verify body is present (JSON order may vary, so just check it's not empty)
-	if body, ok := eventData["body"].(string); !ok || body == "" {
-		t.Errorf("Expected body to be present, got %v", eventData["body"])
-	}
+func TestExecuteFunction_Redirect(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
 
-	// Verify headers are present
-	headers, ok := eventData["headers"].(map[string]any)
-	if !ok {
-		t.Fatal("Expected headers to be present")
-	}
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return respond.redirect("https://example.com/callback?code=abc", 303)
+end
+`)
 
-	if contentType, ok := headers["Content-Type"].(string); !ok || contentType != "application/json" {
-		t.Errorf("Expected Content-Type header, got %v", headers["Content-Type"])
+	req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Location"); got != "https://example.com/callback?code=abc" {
+		t.Errorf("unexpected Location: %q", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", w.Body.String())
 	}
+	if got := w.Header().Get("Content-Type"); got != "" {
+		t.Errorf("expected no Content-Type for an empty body, got %q", got)
+	}
+}
 
-	if customHeader, ok := headers["X-Custom-Header"].(string); !ok || customHeader != "custom-value" {
-		t.Errorf("Expected X-Custom-Header, got %v", headers["X-Custom-Header"])
+func TestExecuteFunction_RoutePrefix(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  return { statusCode = 200, body = event.relativePath }
+end
+`)
+
+	body, _ := json.Marshal(map[string]string{"route_prefix": "/app/foo"})
+	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 setting route prefix, got %d: %s", w.Code, w.Body.String())
 	}
 
-	// Authorization header should be masked now
-	if authHeader, ok := headers["Authorization"].(string); !ok || authHeader != "[REDACTED]" {
-		t.Errorf("Expected Authorization header to be [REDACTED], got %v", headers["Authorization"])
+	tests := []struct {
+		path         string
+		expectStatus int
+		expectBody   string
+	}{
+		{path: "/app/foo/bar", expectStatus: http.StatusOK, expectBody: "/bar"},
+		{path: "/app/foo", expectStatus: http.StatusOK, expectBody: "/"},
+		{path: "/fn/" + fn.ID + "/bar", expectStatus: http.StatusOK, expectBody: "/bar"},
+		{path: "/app/foobar", expectStatus: http.StatusNotFound},
+		{path: "/other", expectStatus: http.StatusNotFound},
 	}
 
-	// Verify query parameters
-	query, ok := eventData["query"].(map[string]any)
-	if !ok {
-		t.Fatal("Expected query to be present")
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.expectStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectStatus, w.Code, w.Body.String())
+			}
+			if tt.expectBody != "" && w.Body.String() != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, w.Body.String())
+			}
+		})
 	}
 
-	if param1, ok := query["param1"].(string); !ok || param1 != "value1" {
-		t.Errorf("Expected param1=value1, got %v", query["param1"])
-	}
+	t.Run("prefix already taken", func(t *testing.T) {
+		other, _ := database.CreateFunction(context.Background(), store.Function{ID: "func_other", Name: "other"})
+
+		req := makeAuthRequest(http.MethodPut, "/api/functions/"+other.ID, body)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d: %s", w.Code, w.Body.String())
+		}
+	})
 
-	if param2, ok := query["param2"].(string); !ok || param2 != "value2" {
-		t.Errorf("Expected param2=value2, got %v", query["param2"])
-	}
-}
+	t.Run("deleting the function removes the route", func(t *testing.T) {
+		req := makeAuthRequest(http.MethodDelete, "/api/functions/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
 
-func TestExecuteFunction_EventJSONWithDifferentMethods(t *testing.T) {
-	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+		req = httptest.NewRequest(http.MethodGet, "/app/foo/bar", nil)
+		w = httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
 
-	for _, method := range methods {
-		t.Run(method, func(t *testing.T) {
-			database := store.NewMemoryDB()
-			server := NewServer(ServerConfig{
-				DB:         database,
-				Logger:     logger.NewMemoryLogger(),
-				KVStore:    kv.NewMemoryStore(),
-				EnvStore:   env.NewMemoryStore(),
-				HTTPClient: internalhttp.NewDefaultClient(),
-				APIKey:     "test-api-key",
-			})
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 after delete, got %d", w.Code)
+		}
+	})
+}
 
-			fn := createTestFunction(t, database)
-			_, err := database.CreateVersion(context.Background(), fn.ID, `
+func TestExecuteFunction_WebSocket(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
 function handler(ctx, event)
-  return {
-    statusCode = 200,
-    body = '{"ok": true}'
-  }
+  if ws == nil then
+    return { statusCode = 200, body = "plain" }
+  end
+  while true do
+    local msg, err = ws.receive()
+    if err then
+      return
+    end
+    ws.send("echo: " .. msg)
+  end
 end
-`, nil)
-			if err != nil {
-				t.Fatalf("Failed to create version: %v", err)
-			}
+`)
 
-			req := httptest.NewRequest(method, "/fn/"+fn.ID, nil)
-			w := httptest.NewRecorder()
-			server.Handler().ServeHTTP(w, req)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/fn/" + fn.ID
 
-			if w.Code != http.StatusOK {
-				t.Fatalf("expected status 200, got %d", w.Code)
-			}
+	t.Run("disabled by default", func(t *testing.T) {
+		_, _, _, err := ws.Dial(context.Background(), wsURL)
+		if err == nil {
+			t.Fatal("expected upgrade to fail when websocket is not enabled")
+		}
+	})
 
-			executionID := w.Header().Get("X-Execution-Id")
-			if executionID == "" {
-				t.Fatal("expected X-Execution-Id header")
-			}
+	enabled := true
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{WebSocketEnabled: &enabled}); err != nil {
+		t.Fatalf("failed to enable websocket: %v", err)
+	}
 
-			execution, err := database.GetExecution(context.Background(), executionID)
+	t.Run("echo", func(t *testing.T) {
+		conn, _, _, err := ws.Dial(context.Background(), wsURL)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		defer func() { _ = conn.Close() }()
+
+		for _, msg := range []string{"hello", "world"} {
+			if err := wsutil.WriteClientText(conn, []byte(msg)); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			reply, err := wsutil.ReadServerText(conn)
 			if err != nil {
-				t.Fatalf("Failed to get execution: %v", err)
+				t.Fatalf("failed to read: %v", err)
 			}
-
-			if execution.EventJSON == nil {
-				t.Fatal("Expected EventJSON to be stored")
+			if string(reply) != "echo: "+msg {
+				t.Errorf("expected %q, got %q", "echo: "+msg, reply)
 			}
+		}
+	})
 
-			var eventData map[string]interface{}
-			if err := json.Unmarshal([]byte(*execution.EventJSON), &eventData); err != nil {
-				t.Fatalf("Failed to parse event JSON: %v", err)
-			}
+	t.Run("plain requests still work", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
 
-			if eventMethod, ok := eventData["method"].(string); !ok || eventMethod != method {
-				t.Errorf("Expected method %s, got %v", method, eventData["method"])
-			}
-		})
-	}
+		if w.Code != http.StatusOK || w.Body.String() != "plain" {
+			t.Errorf("expected 200 plain, got %d %q", w.Code, w.Body.String())
+		}
+	})
 }
 
-func TestExecuteFunction_SensitiveDataMasking(t *testing.T) {
+func TestExecuteFunction_WebSocketQueue(t *testing.T) {
 	database := store.NewMemoryDB()
+	executionQueue := queue.New(1, 0)
 	server := NewServer(ServerConfig{
-		DB:         database,
-		Logger:     logger.NewMemoryLogger(),
-		KVStore:    kv.NewMemoryStore(),
-		EnvStore:   env.NewMemoryStore(),
-		HTTPClient: internalhttp.NewDefaultClient(),
-		APIKey:     "test-api-key",
+		DB:             database,
+		Logger:         logger.NewMemoryLogger(),
+		KVStore:        kv.NewMemoryStore(),
+		EnvStore:       env.NewMemoryStore(),
+		HTTPClient:     internalhttp.NewDefaultClient(),
+		APIKey:         "test-api-key",
+		ExecutionQueue: executionQueue,
 	})
 
 	fn := createTestFunction(t, database)
-	_, err := database.CreateVersion(context.Background(), fn.ID, `
+	createTestVersion(t, database, fn.ID, `
 function handler(ctx, event)
-  return {
-    statusCode = 200,
-    body = '{"message": "success"}'
-  }
+  while true do
+    local msg, err = ws.receive()
+    if err then
+      return
+    end
+    ws.send("echo: " .. msg)
+  end
 end
-`, nil)
-	if err != nil {
-		t.Fatalf("Failed to create version: %v", err)
+`)
+	enabled := true
+	if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{WebSocketEnabled: &enabled}); err != nil {
+		t.Fatalf("failed to enable websocket: %v", err)
 	}
 
-	// Create a request with sensitive headers and body
-	requestBody := `{"username":"john","password":"secret123","api_key":"my-secret-api-key"}`
-	req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID+"?api_key=secret-query-key&limit=10", bytes.NewReader([]byte(requestBody)))
-	req.Header.Set("Authorization", "Bearer secret_token_12345")
-	req.Header.Set("Cookie", "auth_token=f150e53a96f53affce140b818440d8aef5e499038cdc2860ff07b3e6f036d6f1")
-	req.Header.Set("X-API-Key", "my-api-key-123")
-	req.Header.Set("Content-Type", "application/json")
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/fn/" + fn.ID
 
-	w := httptest.NewRecorder()
-	server.Handler().ServeHTTP(w, req)
+	conn, _, _, err := ws.Dial(context.Background(), wsURL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	if err := wsutil.WriteClientText(conn, []byte("hello")); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if _, err := wsutil.ReadServerText(conn); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
 
-	if w.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	// The open session holds the only slot
+	if running := executionQueue.Stats().Running; running != 1 {
+		t.Errorf("expected the session to hold a slot, got %d running", running)
+	}
+	if _, _, _, err := ws.Dial(context.Background(), wsURL); !errors.Is(err, ws.StatusError(http.StatusServiceUnavailable)) {
+		t.Errorf("expected a second session to get 503, got %v", err)
+	}
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fn/"+fn.ID, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 while the session is open, got %d", w.Code)
 	}
 
-	executionID := w.Header().Get("X-Execution-Id")
-	if executionID == "" {
-		t.Fatal("expected X-Execution-Id header")
+	// Closing the session frees the slot
+	_ = conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for executionQueue.Stats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the slot to be released once the session closed")
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
+}
 
-	// Retrieve the execution from the database
-	execution, err := database.GetExecution(context.Background(), executionID)
+func TestExecuteFunction_ServerSentEvents(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+
+	fn := createTestFunction(t, database)
+	createTestVersion(t, database, fn.ID, `
+function handler(ctx, event)
+  sse.send("progress", "50")
+  sse.send(nil, "line one\nline two")
+end
+`)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/fn/" + fn.ID)
 	if err != nil {
-		t.Fatalf("Failed to get execution: %v", err)
+		t.Fatalf("request failed: %v", err)
 	}
+	defer func() { _ = resp.Body.Close() }()
 
-	if execution.EventJSON == nil {
-		t.Fatal("Expected EventJSON to be stored")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected Content-Type text/event-stream, got %q", ct)
 	}
 
-	// Parse and verify the event JSON has masked sensitive data
-	var eventData map[string]any
-	if err := json.Unmarshal([]byte(*execution.EventJSON), &eventData); err != nil {
-		t.Fatalf("Failed to parse event JSON: %v", err)
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		t.Helper()
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read event: %v", err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
 	}
 
-	// Verify sensitive headers are masked
-	headers, ok := eventData["headers"].(map[string]any)
-	if !ok {
-		t.Fatal("Expected headers to be present")
+	if got, want := readEvent(), "event: progress\ndata: 50\n"; got != want {
+		t.Errorf("expected first event %q, got %q", want, got)
+	}
+	if got, want := readEvent(), "data: line one\ndata: line two\n"; got != want {
+		t.Errorf("expected second event %q, got %q", want, got)
 	}
+}
 
-	if auth, ok := headers["Authorization"].(string); !ok || auth != "[REDACTED]" {
-		t.Errorf("Expected Authorization header to be [REDACTED], got %v", headers["Authorization"])
+func TestNewServer_Timeouts(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		server := createTestServer(store.NewMemoryDB())
+
+		if server.readHeaderTimeout != DefaultReadHeaderTimeout {
+			t.Errorf("expected read header timeout %v, got %v", DefaultReadHeaderTimeout, server.readHeaderTimeout)
+		}
+		if server.readTimeout != DefaultReadTimeout {
+			t.Errorf("expected read timeout %v, got %v", DefaultReadTimeout, server.readTimeout)
+		}
+		if server.idleTimeout != DefaultIdleTimeout {
+			t.Errorf("expected idle timeout %v, got %v", DefaultIdleTimeout, server.idleTimeout)
+		}
+		if want := DefaultReadTimeout + 5*time.Minute + writeTimeoutMargin; server.writeTimeout != want {
+			t.Errorf("expected write timeout %v, got %v", want, server.writeTimeout)
+		}
+	})
+
+	t.Run("write timeout follows execution timeout", func(t *testing.T) {
+		server := NewServer(ServerConfig{
+			DB:               store.NewMemoryDB(),
+			Logger:           logger.NewMemoryLogger(),
+			KVStore:          kv.NewMemoryStore(),
+			EnvStore:         env.NewMemoryStore(),
+			HTTPClient:       internalhttp.NewDefaultClient(),
+			ExecutionTimeout: time.Minute,
+			ReadTimeout:      10 * time.Second,
+		})
+
+		if want := 10*time.Second + time.Minute + writeTimeoutMargin; server.writeTimeout != want {
+			t.Errorf("expected write timeout %v, got %v", want, server.writeTimeout)
+		}
+	})
+}
+
+func TestNewHTTPServer_Protocols(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    ServerConfig
+		expectH2  bool
+		expectH2C bool
+	}{
+		{name: "plain HTTP by default"},
+		{name: "h2c enabled", config: ServerConfig{EnableH2C: true}, expectH2C: true},
+		{name: "TLS enables HTTP/2", config: ServerConfig{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}, expectH2: true},
 	}
 
-	if cookie, ok := headers["Cookie"].(string); !ok || cookie != "[REDACTED]" {
-		t.Errorf("Expected Cookie header to be [REDACTED], got %v", headers["Cookie"])
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.DB = store.NewMemoryDB()
+			tt.config.Logger = logger.NewMemoryLogger()
+			server := NewServer(tt.config).newHTTPServer(":0")
+
+			if !server.Protocols.HTTP1() {
+				t.Error("expected HTTP/1 to be enabled")
+			}
+			if server.Protocols.HTTP2() != tt.expectH2 {
+				t.Errorf("expected HTTP2 %v, got %v", tt.expectH2, server.Protocols.HTTP2())
+			}
+			if server.Protocols.UnencryptedHTTP2() != tt.expectH2C {
+				t.Errorf("expected UnencryptedHTTP2 %v, got %v", tt.expectH2C, server.Protocols.UnencryptedHTTP2())
+			}
+			if server.ReadHeaderTimeout != DefaultReadHeaderTimeout {
+				t.Errorf("expected ReadHeaderTimeout %v, got %v", DefaultReadHeaderTimeout, server.ReadHeaderTimeout)
+			}
+		})
 	}
+}
 
-	// X-API-Key header might be stored with different casing
-	apiKeyFound := false
-	for key, value := range headers {
-		if strings.ToLower(key) == "x-api-key" {
-			apiKeyFound = true
-			if strValue, ok := value.(string); !ok || strValue != "[REDACTED]" {
-				t.Errorf("Expected X-API-Key header to be [REDACTED], got %v", value)
+// fakeUsageReporter returns fixed AI usage for any function
+type fakeUsageReporter struct {
+	usage []store.AIUsage
+	since int64
+}
+
+func (f *fakeUsageReporter) Usage(_ string, since int64) ([]store.AIUsage, error) {
+	f.since = since
+	return append([]store.AIUsage(nil), f.usage...), nil
+}
+
+func (f *fakeUsageReporter) TotalUsage(since int64) ([]store.AIUsage, error) {
+	return f.Usage("", since)
+}
+
+func TestGetAIUsage(t *testing.T) {
+	database := store.NewMemoryDB()
+	fn := createTestFunction(t, database)
+
+	reporter := &fakeUsageReporter{usage: []store.AIUsage{
+		{Provider: "openai", Model: "gpt-4o", Requests: 2, InputTokens: 1_000_000, OutputTokens: 100_000},
+		{Provider: "local", Model: "llama", Requests: 1, InputTokens: 10, OutputTokens: 10},
+	}}
+	prices := ai.PriceTable{"openai/gpt-4o": {Input: 2.5, Output: 10}}
+	handler := GetAIUsageHandler(database, reporter, prices)
+
+	t.Run("aggregates usage and cost", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/functions/"+fn.ID+"/ai/usage?window=7d", nil)
+		req.SetPathValue("id", fn.ID)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp AIUsageResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Window != "7d" || resp.TotalRequests != 3 || resp.TotalInputTokens != 1_000_010 {
+			t.Errorf("unexpected totals: %+v", resp)
+		}
+		if resp.EstimatedCostUSD != 3.5 {
+			t.Errorf("expected estimated cost 3.5, got %v", resp.EstimatedCostUSD)
+		}
+		if len(resp.UnpricedModels) != 1 || resp.UnpricedModels[0] != "local/llama" {
+			t.Errorf("expected local/llama to be unpriced, got %v", resp.UnpricedModels)
+		}
+		if want := time.Now().Add(-7 * 24 * time.Hour).Unix(); reporter.since < want-5 || reporter.since > want+5 {
+			t.Errorf("expected since around %d, got %d", want, reporter.since)
+		}
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		for _, window := range []string{"abc", "0d", "-1h", "30m", "0.5h", "400d"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/functions/"+fn.ID+"/ai/usage?window="+window, nil)
+			req.SetPathValue("id", fn.ID)
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("window %q: expected status 400, got %d", window, w.Code)
 			}
-			break
 		}
-	}
-	if !apiKeyFound {
-		t.Error("Expected X-API-Key header to be present in headers")
-	}
+	})
 
-	// Verify non-sensitive headers are not masked
-	if contentType, ok := headers["Content-Type"].(string); !ok || contentType != "application/json" {
-		t.Errorf("Expected Content-Type header to be unchanged, got %v", headers["Content-Type"])
-	}
+	t.Run("unknown function", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/functions/missing/ai/usage", nil)
+		req.SetPathValue("id", "missing")
+		w := httptest.NewRecorder()
+		handler(w, req)
 
-	// Verify sensitive query params are masked
-	query, ok := eventData["query"].(map[string]any)
-	if !ok {
-		t.Fatal("Expected query to be present")
-	}
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
 
-	if apiKeyQuery, ok := query["api_key"].(string); !ok || apiKeyQuery != "[REDACTED]" {
-		t.Errorf("Expected api_key query param to be [REDACTED], got %v", query["api_key"])
-	}
+	t.Run("tracker without usage reporting", func(t *testing.T) {
+		server := createTestServer(database)
+		req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/ai/usage", nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
 
-	// Verify non-sensitive query params are not masked
-	if limit, ok := query["limit"].(string); !ok || limit != "10" {
-		t.Errorf("Expected limit query param to be unchanged, got %v", query["limit"])
-	}
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("expected status 501, got %d", w.Code)
+		}
+	})
+}
 
-	// Verify sensitive body fields are masked
-	body, ok := eventData["body"].(string)
-	if !ok {
-		t.Fatal("Expected body to be present")
-	}
+func TestGetMetricsTimeseries(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
 
-	// Parse the body JSON
-	var bodyData map[string]any
-	if err := json.Unmarshal([]byte(body), &bodyData); err != nil {
-		t.Fatalf("Failed to parse body JSON: %v", err)
+	ms := func(v int64) *int64 { return &v }
+	now := time.Now().Unix()
+	for i, exec := range []store.Execution{
+		{Status: store.ExecutionStatusSuccess, DurationMs: ms(10), CreatedAt: now},
+		{Status: store.ExecutionStatusError, DurationMs: ms(30), CreatedAt: now},
+		{Status: store.ExecutionStatusSuccess, DurationMs: ms(20), CreatedAt: now - 2*3600},
+	} {
+		exec.ID = "exec_" + strconv.Itoa(i)
+		exec.FunctionID = fn.ID
+		if _, err := database.CreateExecution(context.Background(), exec); err != nil {
+			t.Fatalf("failed to create execution: %v", err)
+		}
 	}
 
-	if password, ok := bodyData["password"].(string); !ok || password != "[REDACTED]" {
-		t.Errorf("Expected password field to be [REDACTED], got %v", bodyData["password"])
+	req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/metrics/timeseries?window=6h&bucket=1h", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
 
-	if apiKeyBody, ok := bodyData["api_key"].(string); !ok || apiKeyBody != "[REDACTED]" {
-		t.Errorf("Expected api_key field to be [REDACTED], got %v", bodyData["api_key"])
+	var resp MetricsTimeseriesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Buckets) != 6 {
+		t.Fatalf("expected 6 continuous buckets, got %d", len(resp.Buckets))
+	}
+	if resp.Until-resp.Since != 6*3600 || resp.Since%3600 != 0 {
+		t.Errorf("expected 6 hour-aligned buckets, got since %d until %d", resp.Since, resp.Until)
 	}
 
-	// Verify non-sensitive body fields are not masked
-	if username, ok := bodyData["username"].(string); !ok || username != "john" {
-		t.Errorf("Expected username field to be unchanged, got %v", bodyData["username"])
+	last := resp.Buckets[5]
+	if last.Count != 2 || last.Errors != 1 || last.AvgDurationMs == nil || *last.AvgDurationMs != 20 {
+		t.Errorf("unexpected current bucket: %+v", last)
+	}
+	if b := resp.Buckets[3]; b.Count != 1 || b.P50DurationMs == nil || *b.P50DurationMs != 20 {
+		t.Errorf("unexpected bucket two hours ago: %+v", b)
+	}
+	var total int64
+	for _, b := range resp.Buckets {
+		total += b.Count
+	}
+	if total != 3 {
+		t.Errorf("expected 3 executions in total, got %d", total)
 	}
 }
 
-func TestUpdateFunction_SaveResponse(t *testing.T) {
+func TestGetMetricsTimeseries_InvalidParams(t *testing.T) {
 	database := store.NewMemoryDB()
 	server := createTestServer(database)
-
-	// Create a test function first
 	fn := createTestFunction(t, database)
 
-	// Enable save_response
-	saveResponse := true
-	reqBody := store.UpdateFunctionRequest{
-		SaveResponse: &saveResponse,
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "defaults", query: "", wantStatus: http.StatusOK},
+		{name: "days window", query: "?window=7d&bucket=6h", wantStatus: http.StatusOK},
+		{name: "invalid window", query: "?window=forever", wantStatus: http.StatusBadRequest},
+		{name: "invalid bucket", query: "?bucket=hourly", wantStatus: http.StatusBadRequest},
+		{name: "bucket too small", query: "?bucket=30s", wantStatus: http.StatusBadRequest},
+		{name: "bucket longer than window", query: "?window=1h&bucket=2h", wantStatus: http.StatusBadRequest},
+		{name: "too many buckets", query: "?window=30d&bucket=1m", wantStatus: http.StatusBadRequest},
 	}
 
-	body, _ := json.Marshal(reqBody)
-	req := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body)
-	w := httptest.NewRecorder()
-
-	server.Handler().ServeHTTP(w, req)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeAuthRequest(http.MethodGet, "/api/functions/"+fn.ID+"/metrics/timeseries"+tt.query, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
 	}
 
-	// Verify the save_response was updated
-	updated, err := database.GetFunction(context.Background(), fn.ID)
-	if err != nil {
-		t.Fatalf("failed to get updated function: %v", err)
+	req := makeAuthRequest(http.MethodGet, "/api/functions/missing/metrics/timeseries", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown function, got %d", w.Code)
 	}
+}
 
-	if !updated.SaveResponse {
-		t.Error("expected save_response to be true")
+func TestListRecentErrors(t *testing.T) {
+	database := store.NewMemoryDB()
+	server := createTestServer(database)
+	fn := createTestFunction(t, database)
+
+	message := "attempt to index a nil value"
+	now := time.Now().Unix()
+	for i, exec := range []store.Execution{
+		{Status: store.ExecutionStatusError, ErrorMessage: &message, CreatedAt: now - 60},
+		{Status: store.ExecutionStatusSuccess, CreatedAt: now - 30},
+		{Status: store.ExecutionStatusError, ErrorMessage: &message, CreatedAt: now - 2*3600},
+	} {
+		exec.ID = "exec_" + strconv.Itoa(i)
+		exec.FunctionID = fn.ID
+		if _, err := database.CreateExecution(context.Background(), exec); err != nil {
+			t.Fatalf("failed to create execution: %v", err)
+		}
 	}
 
-	// Disable save_response
-	saveResponseFalse := false
-	reqBody2 := store.UpdateFunctionRequest{
-		SaveResponse: &saveResponseFalse,
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTotal  int64
+	}{
+		{name: "default window", query: "", wantStatus: http.StatusOK, wantTotal: 1},
+		{name: "wider window", query: "?window=3h", wantStatus: http.StatusOK, wantTotal: 2},
+		{name: "invalid window", query: "?window=forever", wantStatus: http.StatusBadRequest},
 	}
 
-	body2, _ := json.Marshal(reqBody2)
-	req2 := makeAuthRequest(http.MethodPut, "/api/functions/"+fn.ID, body2)
-	w2 := httptest.NewRecorder()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeAuthRequest(http.MethodGet, "/api/admin/errors"+tt.query, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
 
-	server.Handler().ServeHTTP(w2, req2)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
 
-	if w2.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w2.Code)
+			var resp RecentErrorsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Pagination.Total != tt.wantTotal || int64(len(resp.Errors)) != tt.wantTotal {
+				t.Fatalf("expected %d errors, got %d (total %d)", tt.wantTotal, len(resp.Errors), resp.Pagination.Total)
+			}
+			first := resp.Errors[0]
+			if first.ID != "exec_0" || first.FunctionName != fn.Name {
+				t.Errorf("expected the latest error with its function name, got %+v", first)
+			}
+			if first.ErrorMessage == nil || *first.ErrorMessage != message {
+				t.Errorf("expected error message %q, got %v", message, first.ErrorMessage)
+			}
+		})
 	}
+}
 
-	// Verify the save_response was updated
-	updated2, err := database.GetFunction(context.Background(), fn.ID)
-	if err != nil {
-		t.Fatalf("failed to get updated function: %v", err)
-	}
+// backupDB adds a Backup method writing fixed content to a memory store
+type backupDB struct {
+	store.DB
+	content string
+}
 
-	if updated2.SaveResponse {
-		t.Error("expected save_response to be false")
-	}
+func (db backupDB) Backup(_ context.Context, path string) error {
+	return os.WriteFile(path, []byte(db.content), 0o600)
 }
 
-func TestExecuteFunction_SaveResponse(t *testing.T) {
-	t.Run("saves response when enabled", func(t *testing.T) {
+func TestBackup(t *testing.T) {
+	t.Run("requires authentication", func(t *testing.T) {
+		server := createTestServer(store.NewMemoryDB())
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/backup", nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("streams the database file", func(t *testing.T) {
+		server := createTestServer(backupDB{DB: store.NewMemoryDB(), content: "SQLite format 3"})
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/admin/backup", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if w.Body.String() != "SQLite format 3" {
+			t.Errorf("unexpected backup content: %q", w.Body.String())
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/vnd.sqlite3" {
+			t.Errorf("unexpected Content-Type: %q", got)
+		}
+		if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="lunar-backup-`) {
+			t.Errorf("unexpected Content-Disposition: %q", got)
+		}
+	})
+
+	t.Run("stores without backups", func(t *testing.T) {
+		server := createTestServer(store.NewMemoryDB())
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/admin/backup", nil))
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("expected status 501, got %d", w.Code)
+		}
+	})
+
+	t.Run("json export", func(t *testing.T) {
 		database := store.NewMemoryDB()
-		server := NewServer(ServerConfig{
+		server := createTestServer(database)
+		fn := createTestFunction(t, database)
+		createTestVersion(t, database, fn.ID, "function handler() end")
+
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/admin/backup?format=json", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var export ExportResponse
+		if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+			t.Fatalf("failed to decode export: %v", err)
+		}
+		if export.ExportedAt == 0 || len(export.Functions) != 1 || export.Functions[0].ID != fn.ID {
+			t.Fatalf("unexpected export: %+v", export)
+		}
+		versions := export.Functions[0].Versions
+		if len(versions) != 2 || versions[0].Version != 1 || versions[1].Code != "function handler() end" {
+			t.Errorf("expected both versions oldest first, got %+v", versions)
+		}
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		server := createTestServer(store.NewMemoryDB())
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/admin/backup?format=csv", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+}
+
+func TestImport(t *testing.T) {
+	newServer := func(database store.DB, envStore env.Store) *Server {
+		return NewServer(ServerConfig{
 			DB:         database,
 			Logger:     logger.NewMemoryLogger(),
 			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
+			EnvStore:   envStore,
 			HTTPClient: internalhttp.NewDefaultClient(),
 			APIKey:     "test-api-key",
+			BaseURL:    "http://localhost:8080",
 		})
+	}
 
-		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
-function handler(ctx, event)
-  return {
-    statusCode = 200,
-    headers = {
-      ["Content-Type"] = "application/json"
-    },
-    body = '{"message": "success"}'
-  }
-end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
+	// Export a function with two versions, the first one active, and an env var
+	sourceDB := store.NewMemoryDB()
+	sourceEnv := env.NewMemoryStore()
+	fn := createTestFunction(t, sourceDB)
+	v2 := createTestVersion(t, sourceDB, fn.ID, "function handler() return { statusCode = 201 } end")
+	if err := sourceDB.ActivateVersion(context.Background(), fmt.Sprintf("ver_%s_v1", fn.ID)); err != nil {
+		t.Fatalf("ActivateVersion failed: %v", err)
+	}
+	_ = sourceEnv.Set(fn.ID, "API_KEY", "secret")
 
-		// Enable save_response
-		saveResponse := true
-		if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{
-			SaveResponse: &saveResponse,
-		}); err != nil {
-			t.Fatalf("Failed to enable save_response: %v", err)
-		}
+	w := httptest.NewRecorder()
+	newServer(sourceDB, sourceEnv).Handler().ServeHTTP(w, makeAuthRequest(http.MethodGet, "/api/admin/backup?format=json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export failed with status %d: %s", w.Code, w.Body.String())
+	}
+	export := w.Body.Bytes()
 
-		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+	importInto := func(t *testing.T, server *Server, query string, body []byte) (int, ImportResponse) {
+		t.Helper()
 		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/admin/import"+query, body))
+		var resp ImportResponse
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
 
-		server.Handler().ServeHTTP(w, req)
+	t.Run("creates missing functions", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		envStore := env.NewMemoryStore()
+		status, resp := importInto(t, newServer(database, envStore), "", export)
+		if status != http.StatusOK || !resp.Applied {
+			t.Fatalf("expected the import to be applied, got %d %+v", status, resp)
+		}
+		if resp.Results[0].Status != ImportStatusCreated || resp.Results[0].ID != fn.ID {
+			t.Errorf("unexpected result: %+v", resp.Results[0])
+		}
 
-		if w.Code != http.StatusOK {
-			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		active, err := database.GetActiveVersion(context.Background(), fn.ID)
+		if err != nil || active.Version != 1 {
+			t.Errorf("expected version 1 to be active, got %+v, %v", active, err)
+		}
+		latest, err := database.GetVersion(context.Background(), fn.ID, 2)
+		if err != nil || latest.Code != v2.Code {
+			t.Errorf("expected version 2 to be imported, got %+v, %v", latest, err)
+		}
+		if value, _ := envStore.Get(fn.ID, "API_KEY"); value != "secret" {
+			t.Errorf("expected the env var to be imported, got %q", value)
 		}
+	})
 
-		// Get the execution ID from the response header
-		executionID := w.Header().Get("X-Execution-Id")
-		if executionID == "" {
-			t.Fatal("expected X-Execution-Id header")
+	// existing returns a database holding a function with the exported ID
+	// and a setting the export leaves out
+	existing := func(t *testing.T) store.DB {
+		database := store.NewMemoryDB()
+		createTestFunction(t, database)
+		name := "local"
+		secret := "local-secret"
+		if err := database.UpdateFunction(context.Background(), fn.ID, store.UpdateFunctionRequest{Name: &name, SigningSecret: &secret}); err != nil {
+			t.Fatalf("UpdateFunction failed: %v", err)
 		}
+		return database
+	}
 
-		// Retrieve the execution from the database
-		execution, err := database.GetExecution(context.Background(), executionID)
-		if err != nil {
-			t.Fatalf("Failed to get execution: %v", err)
+	t.Run("skip", func(t *testing.T) {
+		database := existing(t)
+		status, resp := importInto(t, newServer(database, env.NewMemoryStore()), "?on_conflict=skip", export)
+		if status != http.StatusOK || resp.Results[0].Status != ImportStatusSkipped {
+			t.Fatalf("expected the function to be skipped, got %d %+v", status, resp)
+		}
+		got, _ := database.GetFunction(context.Background(), fn.ID)
+		if got.Name != "local" {
+			t.Errorf("expected the existing function to be unchanged, got %q", got.Name)
 		}
+	})
 
-		// Verify response JSON was stored
-		if execution.ResponseJSON == nil {
-			t.Fatal("Expected ResponseJSON to be stored when save_response is enabled")
+	t.Run("overwrite", func(t *testing.T) {
+		database := existing(t)
+		envStore := env.NewMemoryStore()
+		_ = envStore.Set(fn.ID, "LOCAL_ONLY", "x")
+		status, resp := importInto(t, newServer(database, envStore), "?on_conflict=overwrite", export)
+		if status != http.StatusOK || resp.Results[0].Status != ImportStatusOverwritten {
+			t.Fatalf("expected the function to be overwritten, got %d %+v", status, resp)
+		}
+		got, _ := database.GetFunction(context.Background(), fn.ID)
+		if got.Name != fn.Name {
+			t.Errorf("expected the exported name, got %q", got.Name)
+		}
+		if got.SigningSecret != nil {
+			t.Errorf("expected settings missing from the export to be reset, got signing secret %q", *got.SigningSecret)
+		}
+		// The imported versions follow the existing version 1
+		active, err := database.GetActiveVersion(context.Background(), fn.ID)
+		if err != nil || active.Version != 2 {
+			t.Errorf("expected imported version 2 to be active, got %+v, %v", active, err)
+		}
+		if latest, err := database.GetVersion(context.Background(), fn.ID, 3); err != nil || latest.Code != v2.Code {
+			t.Errorf("expected version 3 to be imported, got %+v, %v", latest, err)
 		}
+		if vars, _ := envStore.All(fn.ID); len(vars) != 1 || vars["API_KEY"] != "secret" {
+			t.Errorf("expected the env vars to be replaced, got %v", vars)
+		}
+	})
 
-		// Parse and verify the response JSON content
-		var responseData map[string]any
-		if err := json.Unmarshal([]byte(*execution.ResponseJSON), &responseData); err != nil {
-			t.Fatalf("Failed to parse response JSON: %v", err)
+	t.Run("rename", func(t *testing.T) {
+		database := existing(t)
+		status, resp := importInto(t, newServer(database, env.NewMemoryStore()), "?on_conflict=rename", export)
+		if status != http.StatusOK || resp.Results[0].Status != ImportStatusRenamed {
+			t.Fatalf("expected the function to be renamed, got %d %+v", status, resp)
+		}
+		if resp.Results[0].ID == fn.ID || resp.Results[0].SourceID != fn.ID {
+			t.Errorf("expected a new ID, got %+v", resp.Results[0])
+		}
+		got, _ := database.GetFunction(context.Background(), fn.ID)
+		if got.Name != "local" {
+			t.Errorf("expected the existing function to be unchanged, got %q", got.Name)
 		}
+		if _, err := database.GetActiveVersion(context.Background(), resp.Results[0].ID); err != nil {
+			t.Errorf("expected the renamed function to have an active version: %v", err)
+		}
+	})
 
-		// Verify status code
-		if statusCode, ok := responseData["statusCode"].(float64); !ok || int(statusCode) != 200 {
-			t.Errorf("Expected statusCode 200, got %v", responseData["statusCode"])
+	t.Run("invalid function rejects the import", func(t *testing.T) {
+		database := store.NewMemoryDB()
+		body := []byte(`{"functions": [
+			{"id": "func_a", "name": "a", "versions": [{"code": "function handler() end", "is_active": true}]},
+			{"id": "func_b", "name": "b", "versions": []}
+		]}`)
+		status, resp := importInto(t, newServer(database, env.NewMemoryStore()), "", body)
+		if status != http.StatusBadRequest || resp.Applied {
+			t.Fatalf("expected the import to be rejected, got %d %+v", status, resp)
+		}
+		if resp.Results[0].Status != ImportStatusNotApplied || resp.Results[1].Status != ImportStatusFailed {
+			t.Errorf("unexpected results: %+v", resp.Results)
+		}
+		if _, err := database.GetFunction(context.Background(), "func_a"); !errors.Is(err, store.ErrFunctionNotFound) {
+			t.Errorf("expected nothing to be imported, got %v", err)
 		}
+	})
 
-		// Verify body
-		if body, ok := responseData["body"].(string); !ok || body != `{"message": "success"}` {
-			t.Errorf("Expected body to be stored, got %v", responseData["body"])
+	t.Run("env failure imports nothing", func(t *testing.T) {
+		database := existing(t)
+		envStore := failingEnvStore{MemoryStore: env.NewMemoryStore(), key: "API_KEY"}
+		_ = envStore.Set(fn.ID, "LOCAL_ONLY", "x")
+		status, resp := importInto(t, newServer(database, envStore), "?on_conflict=overwrite", export)
+		if status != http.StatusInternalServerError || resp.Applied || resp.Results[0].Status != ImportStatusFailed {
+			t.Fatalf("expected the import to fail, got %d %+v", status, resp)
 		}
+		got, _ := database.GetFunction(context.Background(), fn.ID)
+		if got.Name != "local" || got.SigningSecret == nil {
+			t.Errorf("expected the existing function to be unchanged, got %+v", got)
+		}
+		if vars, _ := envStore.All(fn.ID); len(vars) != 1 || vars["LOCAL_ONLY"] != "x" {
+			t.Errorf("expected the env vars to be unchanged, got %v", vars)
+		}
+	})
 
-		// Verify headers
-		if headers, ok := responseData["headers"].(map[string]any); ok {
-			if contentType, ok := headers["Content-Type"].(string); !ok || contentType != "application/json" {
-				t.Errorf("Expected Content-Type header, got %v", headers["Content-Type"])
-			}
-		} else {
-			t.Error("Expected headers to be present in response JSON")
+	t.Run("invalid conflict policy", func(t *testing.T) {
+		status, _ := importInto(t, createTestServer(store.NewMemoryDB()), "?on_conflict=merge", export)
+		if status != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", status)
 		}
 	})
+}
 
-	t.Run("does not save response when disabled", func(t *testing.T) {
+// failingEnvStore is an env store whose writes of one key fail
+type failingEnvStore struct {
+	*env.MemoryStore
+	key string
+}
+
+func (s failingEnvStore) Set(functionID, key, value string) error {
+	if key == s.key {
+		return errors.New("env store unavailable")
+	}
+	return s.MemoryStore.Set(functionID, key, value)
+}
+
+func (s failingEnvStore) SetAll(functionID string, envVars map[string]string) error {
+	if _, ok := envVars[s.key]; ok {
+		return errors.New("env store unavailable")
+	}
+	return s.MemoryStore.SetAll(functionID, envVars)
+}
+
+func TestDeploy(t *testing.T) {
+	setup := func(t *testing.T, envStore env.Store) (*Server, store.DB, store.Function) {
+		t.Helper()
 		database := store.NewMemoryDB()
 		server := NewServer(ServerConfig{
 			DB:         database,
 			Logger:     logger.NewMemoryLogger(),
 			KVStore:    kv.NewMemoryStore(),
-			EnvStore:   env.NewMemoryStore(),
+			EnvStore:   envStore,
 			HTTPClient: internalhttp.NewDefaultClient(),
 			APIKey:     "test-api-key",
+			BaseURL:    "http://localhost:8080",
 		})
-
 		fn := createTestFunction(t, database)
-		_, err := database.CreateVersion(context.Background(), fn.ID, `
-function handler(ctx, event)
-  return {
-    statusCode = 200,
-    body = '{"message": "success"}'
-  }
-end
-`, nil)
-		if err != nil {
-			t.Fatalf("Failed to create version: %v", err)
-		}
-
-		// save_response is disabled by default
+		_ = envStore.Set(fn.ID, "OLD", "1")
+		_ = envStore.Set(fn.ID, "KEEP", "yes")
+		return server, database, fn
+	}
 
-		req := httptest.NewRequest(http.MethodPost, "/fn/"+fn.ID, nil)
+	deploy := func(server *Server, id, body string) *httptest.ResponseRecorder {
 		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, makeAuthRequest(http.MethodPost, "/api/functions/"+id+"/deploy", []byte(body)))
+		return w
+	}
 
-		server.Handler().ServeHTTP(w, req)
+	t.Run("applies code, settings and env vars", func(t *testing.T) {
+		envStore := env.NewMemoryStore()
+		server, database, fn := setup(t, envStore)
 
+		w := deploy(server, fn.ID, `{
+			"code": "function handler() return { statusCode = 204 } end",
+			"version_label": "release",
+			"save_response": true,
+			"env_vars": {"NEW": "2", "OLD": null}
+		}`)
 		if w.Code != http.StatusOK {
 			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 		}
+		var resp store.FunctionWithActiveVersion
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.ActiveVersion.Version != 2 || !resp.SaveResponse || resp.ActiveVersion.Label == nil || *resp.ActiveVersion.Label != "release" {
+			t.Errorf("expected the labeled version 2 with save_response, got %+v", resp)
+		}
+		want := map[string]string{"NEW": "2", "KEEP": "yes"}
+		if vars, _ := envStore.All(fn.ID); !maps.Equal(vars, want) || !maps.Equal(resp.EnvVars, want) {
+			t.Errorf("expected env vars %v, got %v (response %v)", want, vars, resp.EnvVars)
+		}
+		if _, err := database.GetVersion(context.Background(), fn.ID, 2); err != nil {
+			t.Errorf("expected version 2 to exist: %v", err)
+		}
+	})
 
-		// Get the execution ID from the response header
-		executionID := w.Header().Get("X-Execution-Id")
-		if executionID == "" {
-			t.Fatal("expected X-Execution-Id header")
+	t.Run("failed env update leaves no new version", func(t *testing.T) {
+		envStore := failingEnvStore{MemoryStore: env.NewMemoryStore(), key: "BROKEN"}
+		server, database, fn := setup(t, envStore)
+
+		w := deploy(server, fn.ID, `{"code": "function handler() end", "env_vars": {"OLD": null, "BROKEN": "x"}}`)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d: %s", w.Code, w.Body.String())
+		}
+		if _, err := database.GetVersion(context.Background(), fn.ID, 2); !errors.Is(err, store.ErrVersionNotFound) {
+			t.Errorf("expected no new version, got %v", err)
 		}
+		want := map[string]string{"OLD": "1", "KEEP": "yes"}
+		if vars, _ := envStore.All(fn.ID); !maps.Equal(vars, want) {
+			t.Errorf("expected the env vars to be restored to %v, got %v", want, vars)
+		}
+	})
 
-		// Retrieve the execution from the database
-		execution, err := database.GetExecution(context.Background(), executionID)
-		if err != nil {
-			t.Fatalf("Failed to get execution: %v", err)
+	t.Run("failed settings update restores env vars", func(t *testing.T) {
+		envStore := env.NewMemoryStore()
+		server, database, fn := setup(t, envStore)
+		other := store.Function{ID: "func_other", Name: "other", EnvVars: map[string]string{}}
+		if _, err := database.CreateFunction(context.Background(), other); err != nil {
+			t.Fatalf("CreateFunction failed: %v", err)
+		}
+		prefix := "/app/taken"
+		if err := database.UpdateFunction(context.Background(), other.ID, store.UpdateFunctionRequest{RoutePrefix: &prefix}); err != nil {
+			t.Fatalf("UpdateFunction failed: %v", err)
 		}
 
-		// Verify response JSON was NOT stored
-		if execution.ResponseJSON != nil {
-			t.Error("Expected ResponseJSON to be nil when save_response is disabled")
+		w := deploy(server, fn.ID, `{"code": "function handler() end", "route_prefix": "/app/taken", "env_vars": {"NEW": "2"}}`)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d: %s", w.Code, w.Body.String())
+		}
+		if _, err := database.GetVersion(context.Background(), fn.ID, 2); !errors.Is(err, store.ErrVersionNotFound) {
+			t.Errorf("expected no new version, got %v", err)
+		}
+		want := map[string]string{"OLD": "1", "KEEP": "yes"}
+		if vars, _ := envStore.All(fn.ID); !maps.Equal(vars, want) {
+			t.Errorf("expected the env vars to be restored to %v, got %v", want, vars)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		server, _, fn := setup(t, env.NewMemoryStore())
+		if w := deploy(server, fn.ID, `{"env_vars": {"NEW": "2"}}`); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 without code, got %d", w.Code)
+		}
+		if w := deploy(server, "func_missing", `{"code": "function handler() end"}`); w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 for a missing function, got %d", w.Code)
 		}
 	})
 }
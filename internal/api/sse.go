@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dimiro1/lunar/internal/events"
+)
+
+// Compile-time check that sseStream implements events.EventStream
+var _ events.EventStream = (*sseStream)(nil)
+
+// sseStream writes server-sent events to an HTTP response. The response
+// headers are committed lazily on the first event, so functions that never
+// stream keep the regular response path.
+type sseStream struct {
+	w              http.ResponseWriter
+	rc             *http.ResponseController
+	defaultHeaders map[string]string
+	functionID     string
+
+	mu      sync.Mutex
+	started bool
+}
+
+func newSSEStream(w http.ResponseWriter, functionID string, defaultHeaders map[string]string) *sseStream {
+	return &sseStream{
+		w:              w,
+		rc:             http.NewResponseController(w),
+		defaultHeaders: defaultHeaders,
+		functionID:     functionID,
+	}
+}
+
+// Send writes a single event in the text/event-stream format and flushes it
+func (s *sseStream) Send(event, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		for key, value := range s.defaultHeaders {
+			s.w.Header().Set(key, value)
+		}
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.Header().Set("X-Function-Id", s.functionID)
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	if _, err := s.w.Write([]byte(formatSSEEvent(event, data))); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// Started reports whether the response was committed as an event stream
+func (s *sseStream) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+// formatSSEEvent encodes an event, splitting multi-line data into several data fields
+func formatSSEEvent(event, data string) string {
+	var b strings.Builder
+	if event != "" {
+		// Newlines would terminate the field early
+		event = strings.NewReplacer("\r", "", "\n", "").Replace(event)
+		b.WriteString("event: " + event + "\n")
+	}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -1,6 +1,16 @@
 package api
 
-import "github.com/dimiro1/lunar/internal/store"
+import (
+	"encoding/json"
+
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/killswitch"
+	"github.com/dimiro1/lunar/internal/luacode"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	"github.com/dimiro1/lunar/internal/services/modules"
+	"github.com/dimiro1/lunar/internal/store"
+)
 
 // LogLevel represents the severity level of a log entry
 type LogLevel string
@@ -38,9 +48,136 @@ type DiffLine struct {
 
 // CreateFunctionRequest is the request body for creating a function
 type CreateFunctionRequest struct {
-	Name        string  `json:"name"`
-	Description *string `json:"description,omitempty"`
-	Code        string  `json:"code"`
+	Name         string  `json:"name"`
+	Description  *string `json:"description,omitempty"`
+	Code         string  `json:"code"`
+	VersionLabel *string `json:"version_label,omitempty"` // Label for the initial version
+}
+
+// UpdateMaintenanceRequest is the request body for turning maintenance mode on or off
+type UpdateMaintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// MaintenanceResponse describes the maintenance mode
+type MaintenanceResponse struct {
+	Enabled           bool `json:"enabled"`
+	RetryAfterSeconds int  `json:"retry_after_seconds"` // Retry-After sent to rejected requests
+}
+
+// QueueResponse reports the execution queue depth and limits
+type QueueResponse struct {
+	Running       int64 `json:"running"`        // Executions in progress
+	Queued        int64 `json:"queued"`         // Executions waiting for a slot
+	MaxConcurrent int   `json:"max_concurrent"` // Zero means unlimited
+	MaxQueued     int   `json:"max_queued"`
+	Rejected      int64 `json:"rejected"` // Executions rejected with 503 since startup
+}
+
+// UpdateIntegrationsRequest is the request body for switching outbound integrations on or off.
+// Omitted integrations keep their current state.
+type UpdateIntegrationsRequest struct {
+	HTTP  *bool `json:"http,omitempty"`
+	AI    *bool `json:"ai,omitempty"`
+	Email *bool `json:"email,omitempty"`
+}
+
+// IntegrationsResponse reports which outbound integrations are enabled
+type IntegrationsResponse struct {
+	HTTP  bool `json:"http"`
+	AI    bool `json:"ai"`
+	Email bool `json:"email"`
+}
+
+// ProvidersResponse lists the providers functions can use and the
+// environment variables each one reads
+type ProvidersResponse struct {
+	Providers []Provider `json:"providers"`
+}
+
+// ProviderStatus reports whether a function can use a provider. Values of the
+// environment variables are never included, only whether they are set.
+type ProviderStatus struct {
+	Name        string                 `json:"name"`
+	Integration killswitch.Integration `json:"integration"`
+	Enabled     bool                   `json:"enabled"` // The integration is not switched off by the operator
+	Ready       bool                   `json:"ready"`   // Every required environment variable is set
+	EnvKeys     map[string]bool        `json:"env_keys"`
+}
+
+// IntegrationsStatusResponse reports which providers a function is configured for
+type IntegrationsStatusResponse struct {
+	Providers []ProviderStatus `json:"providers"`
+}
+
+// ConfigResponse reports the server's effective limits and which outbound
+// integrations are enabled, so clients do not have to hardcode them
+type ConfigResponse struct {
+	ExecutionTimeoutSeconds int                  `json:"execution_timeout_seconds"`
+	MaxCodeLength           int                  `json:"max_code_length"` // In bytes
+	MaxFunctionNameLength   int                  `json:"max_function_name_length"`
+	MaxDescriptionLength    int                  `json:"max_description_length"`
+	MaxEnvVars              int                  `json:"max_env_vars"`
+	MaxPageSize             int                  `json:"max_page_size"`
+	MaxBatchOperations      int                  `json:"max_batch_operations"`
+	MaxLogEntries           int                  `json:"max_log_entries"` // Per execution, unless the function sets its own
+	AllowedRetentionDays    []int                `json:"allowed_retention_days"`
+	Integrations            IntegrationsResponse `json:"integrations"`
+}
+
+// Batch operation actions
+const (
+	BatchActionCreate = "create"
+	BatchActionUpdate = "update"
+)
+
+// Batch operation result statuses
+const (
+	BatchStatusCreated    = "created"
+	BatchStatusUpdated    = "updated"
+	BatchStatusFailed     = "failed"
+	BatchStatusNotApplied = "not_applied" // The batch was rejected or rolled back because of another operation
+)
+
+// BatchRequest is the request body for creating and updating several functions at once
+type BatchRequest struct {
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+// BatchOperationRequest creates a function or updates the function with ID.
+// It accepts the same fields as the update request; creates require name and code.
+type BatchOperationRequest struct {
+	Action string `json:"action"`       // BatchActionCreate or BatchActionUpdate
+	ID     string `json:"id,omitempty"` // Function to update
+	store.UpdateFunctionRequest
+}
+
+// BatchOperationResult reports the outcome of one batch operation
+type BatchOperationResult struct {
+	Index    int                              `json:"index"`
+	Action   string                           `json:"action"`
+	Status   string                           `json:"status"`
+	Error    string                           `json:"error,omitempty"`
+	Function *store.FunctionWithActiveVersion `json:"function,omitempty"`
+}
+
+// BatchResponse is the response for a batch request. Applied is false when
+// nothing was changed because an operation was invalid or failed.
+type BatchResponse struct {
+	Applied bool                   `json:"applied"`
+	Results []BatchOperationResult `json:"results"`
+}
+
+// CreateVersionFromURLRequest is the request body for creating a version from code hosted at a URL
+type CreateVersionFromURLRequest struct {
+	URL        string  `json:"url"`
+	AuthHeader *string `json:"auth_header,omitempty"` // Sent as the Authorization header when fetching
+	Label      *string `json:"label,omitempty"`
+}
+
+// UpdateVersionRequest is the request body for updating a version
+type UpdateVersionRequest struct {
+	Label *string `json:"label"` // An empty string removes the label
 }
 
 // UpdateEnvVarsRequest is the request body for updating environment variables
@@ -48,6 +185,63 @@ type UpdateEnvVarsRequest struct {
 	EnvVars map[string]string `json:"env_vars"`
 }
 
+// DeployRequest is the request body for deploying a function. It accepts the
+// same fields as the update request, with code required, plus env var changes
+// where a null value deletes the key.
+type DeployRequest struct {
+	store.UpdateFunctionRequest
+	EnvVars map[string]*string `json:"env_vars,omitempty"`
+}
+
+// PatchEnvVarsRequest is the request body for changing individual environment
+// variables. A null value deletes the key; keys not listed are left untouched.
+type PatchEnvVarsRequest struct {
+	EnvVars map[string]*string `json:"env_vars"`
+}
+
+// EnvVarsResponse is the response containing a function's environment variables
+type EnvVarsResponse struct {
+	EnvVars map[string]string `json:"env_vars"`
+}
+
+// PutEmailTemplateRequest is the request body for creating or replacing an email template
+type PutEmailTemplateRequest struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// ListEmailTemplatesResponse is the response for listing a function's email templates
+type ListEmailTemplatesResponse struct {
+	Templates []email.Template `json:"templates"`
+}
+
+// PutModuleRequest is the request body for creating or replacing a Lua module
+type PutModuleRequest struct {
+	Code string `json:"code"`
+}
+
+// LuaCodeRequest is the request body for formatting or linting Lua code
+type LuaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// FormatLuaResponse is the response for formatting Lua code
+type FormatLuaResponse struct {
+	Code    string `json:"code"`
+	Changed bool   `json:"changed"` // The formatted code differs from the request's
+}
+
+// LintLuaResponse is the response for linting Lua code
+type LintLuaResponse struct {
+	Findings []luacode.Finding `json:"findings"`
+}
+
+// ListModulesResponse is the response for listing a function's Lua modules
+type ListModulesResponse struct {
+	Modules []modules.Module `json:"modules"`
+}
+
 // ListFunctionsResponse is the response for listing functions
 type ListFunctionsResponse struct {
 	Functions []store.FunctionWithActiveVersion `json:"functions"`
@@ -69,6 +263,89 @@ type ExecutionWithLogs struct {
 	Logs []LogEntry `json:"logs"`
 }
 
+// TestFunctionRequest is the request body for a sandboxed test execution. It
+// describes the HTTP request the function receives; Path is relative to the
+// function's URL and defaults to "/". HTTPFixtures answer the function's
+// outbound requests; requests without a fixture get an empty 200.
+type TestFunctionRequest struct {
+	Method       string            `json:"method"` // Defaults to GET
+	Path         string            `json:"path"`
+	Query        map[string]string `json:"query"`
+	Headers      map[string]string `json:"headers"`
+	Body         string            `json:"body"`
+	HTTPFixtures []HTTPFixture     `json:"http_fixtures"`
+	Seed         *int64            `json:"seed,omitempty"`          // Makes the random module deterministic
+	FrozenTime   *int64            `json:"frozen_time,omitempty"`   // Unix time returned by time.now
+	EnvOverrides map[string]string `json:"env_overrides,omitempty"` // Layered over the function's env vars for this run only
+}
+
+// HTTPFixture is the canned response to outbound requests with Method and URL.
+// URL is matched exactly, without the query parameters passed in the options.
+type HTTPFixture struct {
+	Method     string            `json:"method"` // Defaults to GET
+	URL        string            `json:"url"`
+	StatusCode int               `json:"status_code"` // Defaults to 200
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+// TestHTTPCall is an outbound HTTP request a sandboxed execution would have sent
+type TestHTTPCall struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// TestAICall is an AI chat a sandboxed execution would have sent
+type TestAICall struct {
+	Provider string       `json:"provider"`
+	Model    string       `json:"model"`
+	Messages []ai.Message `json:"messages"`
+}
+
+// TestEmail is an email a sandboxed execution would have sent
+type TestEmail struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Cc      []string `json:"cc,omitempty"`
+	Bcc     []string `json:"bcc,omitempty"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text,omitempty"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// TestSideEffects lists the outbound calls a sandboxed execution made, in order
+type TestSideEffects struct {
+	HTTP   []TestHTTPCall `json:"http"`
+	AI     []TestAICall   `json:"ai"`
+	Emails []TestEmail    `json:"emails"`
+}
+
+// TestFunctionResponse is the response for a sandboxed test execution
+type TestFunctionResponse struct {
+	ExecutionID string                `json:"execution_id"`
+	Status      store.ExecutionStatus `json:"status"`
+	DurationMs  int64                 `json:"duration_ms"`
+	Error       string                `json:"error,omitempty"`
+	Response    *events.HTTPResponse  `json:"response,omitempty"`
+	SideEffects TestSideEffects       `json:"side_effects"`
+
+	// OutputErrors lists where the response does not match the function's
+	// output schema, keyed by location such as "body/name"
+	OutputErrors map[string]string `json:"output_errors,omitempty"`
+}
+
+// SelfTestResponse is the response for a function self-test. StatusCode is
+// the status the function returned, zero when it returned no response.
+type SelfTestResponse struct {
+	Passed     bool   `json:"passed"`
+	StatusCode int    `json:"status_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
 // VersionDiffResponse is the response for version diff
 type VersionDiffResponse struct {
 	OldVersion int        `json:"old_version"`
@@ -76,13 +353,52 @@ type VersionDiffResponse struct {
 	Diff       []DiffLine `json:"diff"`
 }
 
+// VersionDiffSummaryResponse summarizes the diff between two versions. The
+// handler signatures are those found on changed lines, empty when the
+// signature line did not change.
+type VersionDiffSummaryResponse struct {
+	OldVersion              int    `json:"old_version"`
+	NewVersion              int    `json:"new_version"`
+	LinesAdded              int    `json:"lines_added"`
+	LinesRemoved            int    `json:"lines_removed"`
+	LinesUnchanged          int    `json:"lines_unchanged"`
+	Hunks                   int    `json:"hunks"` // Contiguous blocks of changed lines
+	HandlerSignatureChanged bool   `json:"handler_signature_changed"`
+	OldHandlerSignature     string `json:"old_handler_signature,omitempty"`
+	NewHandlerSignature     string `json:"new_handler_signature,omitempty"`
+}
+
 // ErrorResponse is the standard error response
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error  string            `json:"error"`
+	Errors map[string]string `json:"errors,omitempty"` // Field name -> message, for validation errors
 }
 
 // Pagination types moved to internal/db package - re-exported in store.go for compatibility
 
+// IncludeRecentExecutions is the include value that embeds a function's
+// latest executions when getting it
+const IncludeRecentExecutions = "recent_executions"
+
+// RecentExecutionsLimit is the number of executions embedded by IncludeRecentExecutions
+const RecentExecutionsLimit = 5
+
+// FunctionWithRecentExecutions is the response for getting a function with
+// ?include=recent_executions
+type FunctionWithRecentExecutions struct {
+	store.FunctionWithActiveVersion
+	RecentExecutions []ExecutionSummary `json:"recent_executions"` // Newest first
+}
+
+// ExecutionSummary is the short form of an execution embedded in other responses
+type ExecutionSummary struct {
+	ID         string                 `json:"id"`
+	Status     store.ExecutionStatus  `json:"status"`
+	Trigger    store.ExecutionTrigger `json:"trigger"`
+	DurationMs *int64                 `json:"duration_ms,omitempty"`
+	CreatedAt  int64                  `json:"created_at"`
+}
+
 // PaginatedFunctionsResponse is the paginated response for listing functions
 type PaginatedFunctionsResponse struct {
 	Functions  []store.FunctionWithActiveVersion `json:"functions"`
@@ -120,12 +436,52 @@ type PaginatedAIRequestsResponse struct {
 	Pagination store.PaginationInfo `json:"pagination"`
 }
 
+// PaginatedHTTPRequestsResponse is the paginated response for captured HTTP requests
+type PaginatedHTTPRequestsResponse struct {
+	HTTPRequests []store.HTTPRequest  `json:"http_requests"`
+	Pagination   store.PaginationInfo `json:"pagination"`
+}
+
 // PaginatedEmailRequestsResponse is the paginated response for email requests
 type PaginatedEmailRequestsResponse struct {
 	EmailRequests []store.EmailRequest `json:"email_requests"`
 	Pagination    store.PaginationInfo `json:"pagination"`
 }
 
+// PaginatedExternalCallsResponse is the paginated response for the external calls of an execution
+type PaginatedExternalCallsResponse struct {
+	ExternalCalls []store.ExternalCall `json:"external_calls"`
+	Pagination    store.PaginationInfo `json:"pagination"`
+}
+
+// ExplainExecutionResponse is everything known about an execution, for debugging
+type ExplainExecutionResponse struct {
+	Execution     store.Execution        `json:"execution"`          // The record, without the event, response and spans
+	Event         json.RawMessage        `json:"event,omitempty"`    // The stored event, with sensitive data masked
+	Response      json.RawMessage        `json:"response,omitempty"` // Only stored for functions that save responses
+	Version       *store.FunctionVersion `json:"version,omitempty"`  // Unset when the version has since been deleted
+	Timing        ExecutionTiming        `json:"timing"`
+	ExternalCalls []store.ExternalCall   `json:"external_calls"`
+}
+
+// CurlCommandResponse is an execution's request as a curl command
+type CurlCommandResponse struct {
+	Command  string   `json:"command"`
+	Redacted []string `json:"redacted,omitempty"` // Values masked in storage to fill in: header:Name, query:name or body
+}
+
+// ExecutionTiming breaks down how an execution spent its time
+type ExecutionTiming struct {
+	DurationMs   *int64 `json:"duration_ms,omitempty"`   // Total duration, unset while the execution runs
+	ExternalMs   int64  `json:"external_ms"`             // Time spent in outbound AI, email and HTTP calls
+	FunctionMs   *int64 `json:"function_ms,omitempty"`   // Duration not spent in outbound calls
+	DelaySeconds *int64 `json:"delay_seconds,omitempty"` // Time a cron execution started after it was scheduled
+
+	// Spans recorded with trace.span, in start order; with their depth they
+	// lay out as a flame graph
+	Spans []store.Span `json:"spans,omitempty"`
+}
+
 // NextRunResponse is the response for getting the next scheduled run time
 type NextRunResponse struct {
 	HasSchedule  bool    `json:"has_schedule"`
@@ -135,3 +491,82 @@ type NextRunResponse struct {
 	NextRun      *int64  `json:"next_run,omitempty"`
 	NextRunHuman *string `json:"next_run_human,omitempty"`
 }
+
+// MetricsTimeseriesResponse is the response for a function's bucketed execution metrics
+type MetricsTimeseriesResponse struct {
+	FunctionID string                  `json:"function_id"`
+	Window     string                  `json:"window"`
+	Bucket     string                  `json:"bucket"`
+	Since      int64                   `json:"since"`
+	Until      int64                   `json:"until"`
+	Buckets    []store.ExecutionBucket `json:"buckets"`
+}
+
+// RecentErrorsResponse is the paginated response for failed executions across functions
+type RecentErrorsResponse struct {
+	Window     string                    `json:"window"`
+	Since      int64                     `json:"since"`
+	Errors     []store.FunctionExecution `json:"errors"`
+	Pagination store.PaginationInfo      `json:"pagination"`
+}
+
+// ExportResponse is the logical export of every function with its env vars
+// and versions. It is partial: Lua modules, email templates, KV data and
+// executions are only in the database backup.
+type ExportResponse struct {
+	ExportedAt int64            `json:"exported_at"`
+	Functions  []FunctionExport `json:"functions"`
+}
+
+// FunctionExport is a function with all of its versions, oldest first
+type FunctionExport struct {
+	store.Function
+	Versions []store.FunctionVersion `json:"versions"`
+}
+
+// Import conflict policies for functions whose ID already exists
+const (
+	ImportConflictSkip      = "skip"      // Leave the existing function unchanged (default)
+	ImportConflictOverwrite = "overwrite" // Replace its settings and env vars and add the imported versions
+	ImportConflictRename    = "rename"    // Import the function under a new ID, without its route prefix
+)
+
+// Import result statuses
+const (
+	ImportStatusCreated     = "created"
+	ImportStatusOverwritten = "overwritten"
+	ImportStatusRenamed     = "renamed"
+	ImportStatusSkipped     = "skipped"
+	ImportStatusFailed      = "failed"
+	ImportStatusNotApplied  = "not_applied" // The import was rejected or rolled back because of another function
+)
+
+// ImportResult reports the outcome of importing one function
+type ImportResult struct {
+	Index    int    `json:"index"`
+	SourceID string `json:"source_id"`    // ID of the function in the export
+	ID       string `json:"id,omitempty"` // ID of the function in this instance
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportResponse is the response for an import request. Applied is false when
+// nothing was changed because a function was invalid or failed.
+type ImportResponse struct {
+	Applied bool           `json:"applied"`
+	Results []ImportResult `json:"results"`
+}
+
+// AIUsageResponse is the response for a function's aggregated AI usage
+type AIUsageResponse struct {
+	FunctionID        string          `json:"function_id"`
+	Window            string          `json:"window"`
+	Since             int64           `json:"since"`
+	Usage             []store.AIUsage `json:"usage"`
+	TotalRequests     int64           `json:"total_requests"`
+	TotalInputTokens  int64           `json:"total_input_tokens"`
+	TotalOutputTokens int64           `json:"total_output_tokens"`
+	EstimatedCostUSD  float64         `json:"estimated_cost_usd"`
+	UnpricedModels    []string        `json:"unpriced_models,omitempty"`
+}
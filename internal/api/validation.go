@@ -1,12 +1,22 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"net/mail"
+	"net/url"
 	"slices"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/dimiro1/lunar/internal/runner"
+	"github.com/dimiro1/lunar/internal/schema"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/store"
 	"github.com/robfig/cron/v3"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
 )
 
 const (
@@ -24,10 +34,69 @@ const (
 	MaxEnvVarValueLength = 10000
 	// MaxEnvVars is the maximum number of environment variables per function
 	MaxEnvVars = 100
+	// MaxVersionsLimit is the maximum value allowed for a function's max_versions
+	MaxVersionsLimit = 1000
+	// MaxLogEntriesLimit is the maximum value allowed for a function's max_log_entries
+	MaxLogEntriesLimit = 100000
+	// MaxOutboundCallsLimit is the maximum value allowed for a function's max_outbound_calls
+	MaxOutboundCallsLimit = 10000
+	// MaxOutboundBudget is the maximum value in seconds allowed for a function's outbound_time_budget
+	MaxOutboundBudget = 3600
+	// MaxCacheTTL is the maximum value in seconds allowed for a function's cache_ttl
+	MaxCacheTTL = 86400
+	// MaxCacheVaryHeaders is the maximum number of request headers in a function's cache key
+	MaxCacheVaryHeaders = 10
+	// MaxCronJitter is the maximum value in seconds allowed for a function's cron_jitter
+	MaxCronJitter = 300
+	// MaxDefaultHeaders is the maximum number of default response headers per function
+	MaxDefaultHeaders = 20
+	// MaxHeaderValueLength is the maximum length for a default response header value
+	MaxHeaderValueLength = 2048
+	// MaxResponseHeaders is the maximum number of headers a function response may set
+	MaxResponseHeaders = 50
+	// MaxResponseHeaderValueLength is the maximum length for a header value set by a function response
+	MaxResponseHeaderValueLength = 8192
+	// MaxRoutePrefixLength is the maximum length for a function route prefix
+	MaxRoutePrefixLength = 200
+	// MaxAIModelLength is the maximum length for a function's default AI model
+	MaxAIModelLength = 200
+	// MaxEmailAllowedFrom is the maximum number of entries in a function's email sender allowlist
+	MaxEmailAllowedFrom = 20
+	// MaxEmailTemplateNameLength is the maximum length for email template names
+	MaxEmailTemplateNameLength = 100
+	// MaxEmailTemplateLength is the maximum length for each part of an email template
+	MaxEmailTemplateLength = 256 * 1024 // 256KB
+	// MaxModuleNameLength is the maximum length for Lua module names
+	MaxModuleNameLength = 100
+	// MaxMiddleware is the maximum number of middleware modules per function
+	MaxMiddleware = 10
+	// MaxVersionLabelLength is the maximum length for version labels
+	MaxVersionLabelLength = 100
+	// MaxBatchOperations is the maximum number of operations in a batch request
+	MaxBatchOperations = 100
+	// MaxImportFunctions is the maximum number of functions in an import request
+	MaxImportFunctions = 1000
+	// MaxHTTPFixtures is the maximum number of HTTP fixtures in a test request
+	MaxHTTPFixtures = 100
+	// MinSigningSecretLength is the minimum length for a function's request signing secret
+	MinSigningSecretLength = 16
+	// MaxSigningSecretLength is the maximum length for a function's request signing secret
+	MaxSigningSecretLength = 256
+	// MaxSchemaLength is the maximum length for a function's input and output schemas
+	MaxSchemaLength = 64 * 1024 // 64KB
 )
 
 var AllowedRetentionDays = []int{7, 15, 30, 365}
 var AllowedCronStatuses = []string{string(store.CronStatusActive), string(store.CronStatusPaused)}
+var AllowedCronMisfirePolicies = []string{string(store.CronMisfireSkip), string(store.CronMisfireRunOnce)}
+var AllowedHTTPMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// FixtureHTTPMethods are the methods of the http module, which HTTP fixtures answer
+var FixtureHTTPMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// ReservedRouteSegments are first path segments used by the server itself,
+// which function route prefixes cannot claim
+var ReservedRouteSegments = []string{"api", "fn", "docs", "css", "js", "vendor", "index.html", "llms.txt", "healthz"}
 
 // ValidationError represents a validation error
 type ValidationError struct {
@@ -39,30 +108,101 @@ func (e *ValidationError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Field, e.Message)
 }
 
+// SyntaxError reports Lua code that does not compile. The request itself is
+// well formed, so the API answers it with a 422 instead of a 400
+type SyntaxError struct {
+	ValidationError
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return &e.ValidationError
+}
+
+// isSyntaxError reports whether err is a *SyntaxError
+func isSyntaxError(err error) bool {
+	var syntaxErr *SyntaxError
+	return errors.As(err, &syntaxErr)
+}
+
+// ValidationErrors collects the errors of every invalid field of a request,
+// so form UIs can show them all at once
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add appends err, which is a *ValidationError or nil
+func (e *ValidationErrors) add(err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		*e = append(*e, validationErr)
+	}
+}
+
+// err returns the collected errors, or nil when there are none
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// fieldErrors maps each invalid field of a validation error to its message,
+// keeping the first message of a field. It returns nil for other errors.
+func fieldErrors(err error) map[string]string {
+	var list ValidationErrors
+	var single *ValidationError
+	switch {
+	case errors.As(err, &list):
+	case errors.As(err, &single):
+		list = ValidationErrors{single}
+	default:
+		return nil
+	}
+
+	fields := make(map[string]string, len(list))
+	for _, fieldErr := range list {
+		if _, exists := fields[fieldErr.Field]; !exists {
+			fields[fieldErr.Field] = fieldErr.Message
+		}
+	}
+	return fields
+}
+
 // ValidateCreateFunctionRequest validates a CreateFunctionRequest
 func ValidateCreateFunctionRequest(req *CreateFunctionRequest) error {
 	if req == nil {
 		return &ValidationError{Field: "request", Message: "request cannot be nil"}
 	}
 
+	var errs ValidationErrors
+
 	// Validate name
-	if err := validateFunctionName(req.Name); err != nil {
-		return err
-	}
+	errs.add(validateFunctionName(req.Name))
 
 	// Validate description if provided
 	if req.Description != nil {
-		if err := validateDescription(*req.Description); err != nil {
-			return err
-		}
+		errs.add(validateDescription(*req.Description))
 	}
 
 	// Validate code
-	if err := validateCode(req.Code); err != nil {
-		return err
+	errs.add(validateCode(req.Code))
+
+	// Validate version_label if provided
+	if req.VersionLabel != nil {
+		errs.add(validateVersionLabel("version_label", *req.VersionLabel))
 	}
 
-	return nil
+	// Only compile code once the request is otherwise valid
+	if err := errs.err(); err != nil {
+		return err
+	}
+	return checkSyntax("code", req.Code)
 }
 
 // ValidateUpdateFunctionRequest validates an UpdateFunctionRequest
@@ -72,53 +212,195 @@ func ValidateUpdateFunctionRequest(req *store.UpdateFunctionRequest) error {
 	}
 
 	// At least one field must be provided
-	if req.Name == nil && req.Description == nil && req.Code == nil && req.Disabled == nil && req.RetentionDays == nil && req.CronSchedule == nil && req.CronStatus == nil && req.SaveResponse == nil {
+	if req.Code == nil && !req.HasMetadata() {
 		return &ValidationError{Field: "request", Message: "at least one field must be provided for update"}
 	}
 
+	var errs ValidationErrors
+
 	// Validate name if provided
 	if req.Name != nil {
-		if err := validateFunctionName(*req.Name); err != nil {
-			return err
-		}
+		errs.add(validateFunctionName(*req.Name))
 	}
 
 	// Validate description if provided
 	if req.Description != nil {
-		if err := validateDescription(*req.Description); err != nil {
-			return err
-		}
+		errs.add(validateDescription(*req.Description))
 	}
 
 	// Validate code if provided
 	if req.Code != nil {
-		if err := validateCode(*req.Code); err != nil {
-			return err
+		errs.add(validateCode(*req.Code))
+	}
+
+	// Validate version_label if provided; it labels the version created from code
+	if req.VersionLabel != nil {
+		if req.Code == nil {
+			errs.add(&ValidationError{Field: "version_label", Message: "version_label requires code"})
+		} else {
+			errs.add(validateVersionLabel("version_label", *req.VersionLabel))
 		}
 	}
 
 	// Validate retention_days if provided
 	if req.RetentionDays != nil {
-		if err := validateRetentionDays(*req.RetentionDays); err != nil {
-			return err
-		}
+		errs.add(validateRetentionDays(*req.RetentionDays))
 	}
 
 	// Validate cron_schedule if provided
 	if req.CronSchedule != nil {
-		if err := validateCronSchedule(*req.CronSchedule); err != nil {
-			return err
-		}
+		errs.add(validateCronSchedule(*req.CronSchedule))
 	}
 
 	// Validate cron_status if provided
 	if req.CronStatus != nil {
-		if err := validateCronStatus(*req.CronStatus); err != nil {
-			return err
+		errs.add(validateCronStatus(*req.CronStatus))
+	}
+
+	// Validate cron_jitter if provided, zero clears it
+	if req.CronJitter != nil && (*req.CronJitter < 0 || *req.CronJitter > MaxCronJitter) {
+		errs.add(&ValidationError{
+			Field:   "cron_jitter",
+			Message: fmt.Sprintf("cron_jitter must be between 0 and %d seconds", MaxCronJitter),
+		})
+	}
+
+	// Validate cron_misfire_policy if provided, empty clears it
+	if req.CronMisfirePolicy != nil && *req.CronMisfirePolicy != "" &&
+		!slices.Contains(AllowedCronMisfirePolicies, *req.CronMisfirePolicy) {
+		errs.add(&ValidationError{
+			Field:   "cron_misfire_policy",
+			Message: fmt.Sprintf("cron_misfire_policy must be one of: %v", AllowedCronMisfirePolicies),
+		})
+	}
+
+	// Validate max_versions if provided
+	if req.MaxVersions != nil {
+		errs.add(validateMaxVersions(*req.MaxVersions))
+	}
+
+	// Validate max_log_entries if provided, zero clears it
+	if req.MaxLogEntries != nil && (*req.MaxLogEntries < 0 || *req.MaxLogEntries > MaxLogEntriesLimit) {
+		errs.add(&ValidationError{
+			Field:   "max_log_entries",
+			Message: fmt.Sprintf("max_log_entries must be between 0 and %d", MaxLogEntriesLimit),
+		})
+	}
+
+	// Validate max_outbound_calls if provided, zero clears it
+	if req.MaxOutboundCalls != nil && (*req.MaxOutboundCalls < 0 || *req.MaxOutboundCalls > MaxOutboundCallsLimit) {
+		errs.add(&ValidationError{
+			Field:   "max_outbound_calls",
+			Message: fmt.Sprintf("max_outbound_calls must be between 0 and %d", MaxOutboundCallsLimit),
+		})
+	}
+
+	// Validate outbound_time_budget if provided, zero clears it
+	if req.OutboundBudget != nil && (*req.OutboundBudget < 0 || *req.OutboundBudget > MaxOutboundBudget) {
+		errs.add(&ValidationError{
+			Field:   "outbound_time_budget",
+			Message: fmt.Sprintf("outbound_time_budget must be between 0 and %d seconds", MaxOutboundBudget),
+		})
+	}
+
+	// Validate cache_ttl if provided, zero disables the cache
+	if req.CacheTTL != nil && (*req.CacheTTL < 0 || *req.CacheTTL > MaxCacheTTL) {
+		errs.add(&ValidationError{
+			Field:   "cache_ttl",
+			Message: fmt.Sprintf("cache_ttl must be between 0 and %d seconds", MaxCacheTTL),
+		})
+	}
+
+	// Validate cache_vary_headers if provided
+	if req.CacheVaryHeaders != nil {
+		errs.add(validateCacheVaryHeaders(*req.CacheVaryHeaders))
+	}
+
+	// Validate ai_budget_tokens if provided
+	if req.AIBudgetTokens != nil && *req.AIBudgetTokens < 0 {
+		errs.add(&ValidationError{Field: "ai_budget_tokens", Message: "ai_budget_tokens cannot be negative"})
+	}
+
+	// Validate ai_budget_usd if provided
+	if req.AIBudgetUSD != nil && *req.AIBudgetUSD < 0 {
+		errs.add(&ValidationError{Field: "ai_budget_usd", Message: "ai_budget_usd cannot be negative"})
+	}
+
+	// Validate default_headers if provided
+	if req.DefaultHeaders != nil {
+		errs.add(validateDefaultHeaders(*req.DefaultHeaders))
+	}
+
+	// Validate route_prefix if provided
+	if req.RoutePrefix != nil {
+		errs.add(validateRoutePrefix(*req.RoutePrefix))
+	}
+
+	// Validate allowed_methods if provided
+	if req.AllowedMethods != nil {
+		errs.add(validateAllowedMethods(*req.AllowedMethods))
+	}
+
+	// Validate ai_default_provider if provided, empty clears it
+	if req.AIDefaultProvider != nil && *req.AIDefaultProvider != "" && !ai.IsSupportedProvider(*req.AIDefaultProvider) {
+		errs.add(&ValidationError{Field: "ai_default_provider", Message: "ai_default_provider must be 'openai' or 'anthropic'"})
+	}
+
+	// Validate ai_default_model if provided
+	if req.AIDefaultModel != nil && len(*req.AIDefaultModel) > MaxAIModelLength {
+		errs.add(&ValidationError{
+			Field:   "ai_default_model",
+			Message: fmt.Sprintf("ai_default_model cannot be longer than %d characters", MaxAIModelLength),
+		})
+	}
+
+	// Validate email_default_from if provided, empty clears it
+	if req.EmailDefaultFrom != nil && *req.EmailDefaultFrom != "" {
+		if _, err := mail.ParseAddress(*req.EmailDefaultFrom); err != nil {
+			errs.add(&ValidationError{Field: "email_default_from", Message: "email_default_from must be a valid email address"})
 		}
 	}
 
-	return nil
+	// Validate email_allowed_from if provided
+	if req.EmailAllowedFrom != nil {
+		errs.add(validateEmailAllowedFrom(*req.EmailAllowedFrom))
+	}
+
+	// Validate signing_secret if provided, empty clears it
+	if req.SigningSecret != nil && *req.SigningSecret != "" {
+		if len(*req.SigningSecret) < MinSigningSecretLength || len(*req.SigningSecret) > MaxSigningSecretLength {
+			errs.add(&ValidationError{
+				Field:   "signing_secret",
+				Message: fmt.Sprintf("signing_secret must be between %d and %d characters", MinSigningSecretLength, MaxSigningSecretLength),
+			})
+		}
+	}
+
+	// Validate disabled_modules if provided
+	if req.DisabledModules != nil {
+		errs.add(validateDisabledModules(*req.DisabledModules))
+	}
+
+	// Validate middleware if provided, empty removes it
+	if req.Middleware != nil {
+		errs.add(validateMiddleware(*req.Middleware))
+	}
+
+	// Validate input_schema if provided, {} removes it
+	if req.InputSchema != nil {
+		errs.add(validateSchema("input_schema", *req.InputSchema))
+	}
+
+	// Validate output_schema if provided, {} removes it
+	if req.OutputSchema != nil {
+		errs.add(validateSchema("output_schema", *req.OutputSchema))
+	}
+
+	// Only compile code once the request is otherwise valid
+	if err := errs.err(); err != nil || req.Code == nil {
+		return err
+	}
+	return checkSyntax("code", *req.Code)
 }
 
 // ValidateUpdateEnvVarsRequest validates an UpdateEnvVarsRequest
@@ -145,11 +427,230 @@ func ValidateUpdateEnvVarsRequest(req *UpdateEnvVarsRequest) error {
 		if err := validateEnvVarValue(value); err != nil {
 			return err
 		}
+		if err := validateProviderEnvVar(key, value); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// ValidatePatchEnvVarsRequest validates a PatchEnvVarsRequest
+func ValidatePatchEnvVarsRequest(req *PatchEnvVarsRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+
+	if len(req.EnvVars) == 0 {
+		return &ValidationError{Field: "env_vars", Message: "env_vars must contain at least one key"}
+	}
+
+	if len(req.EnvVars) > MaxEnvVars {
+		return &ValidationError{
+			Field:   "env_vars",
+			Message: fmt.Sprintf("cannot change more than %d environment variables", MaxEnvVars),
+		}
+	}
+
+	for key, value := range req.EnvVars {
+		if err := validateEnvVarKey(key); err != nil {
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		if err := validateEnvVarValue(*value); err != nil {
+			return err
+		}
+		if err := validateProviderEnvVar(key, *value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateDeployRequest validates a DeployRequest
+func ValidateDeployRequest(req *DeployRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+	if req.Code == nil {
+		return &ValidationError{Field: "code", Message: "code is required"}
+	}
+	if len(req.EnvVars) > 0 {
+		if err := ValidatePatchEnvVarsRequest(&PatchEnvVarsRequest{EnvVars: req.EnvVars}); err != nil {
+			return err
+		}
+	}
+	return ValidateUpdateFunctionRequest(&req.UpdateFunctionRequest)
+}
+
+// ValidateTestFunctionRequest validates a sandboxed test execution request,
+// defaulting the method to GET and the path to "/"
+func ValidateTestFunctionRequest(req *TestFunctionRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	if !slices.Contains(AllowedHTTPMethods, req.Method) {
+		return &ValidationError{
+			Field:   "method",
+			Message: fmt.Sprintf("method must be one of: %v", AllowedHTTPMethods),
+		}
+	}
+
+	if req.Path == "" {
+		req.Path = "/"
+	}
+	if !strings.HasPrefix(req.Path, "/") || strings.ContainsAny(req.Path, "?#") {
+		return &ValidationError{Field: "path", Message: "path must start with / and cannot contain a query or fragment"}
+	}
+
+	if len(req.HTTPFixtures) > MaxHTTPFixtures {
+		return &ValidationError{
+			Field:   "http_fixtures",
+			Message: fmt.Sprintf("cannot have more than %d HTTP fixtures", MaxHTTPFixtures),
+		}
+	}
+	for i := range req.HTTPFixtures {
+		if err := validateHTTPFixture(&req.HTTPFixtures[i]); err != nil {
+			return err
+		}
+	}
+
+	if len(req.EnvOverrides) > MaxEnvVars {
+		return &ValidationError{
+			Field:   "env_overrides",
+			Message: fmt.Sprintf("cannot have more than %d environment variables", MaxEnvVars),
+		}
+	}
+	for key, value := range req.EnvOverrides {
+		if err := validateEnvVarKey(key); err != nil {
+			return err
+		}
+		if err := validateEnvVarValue(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateHTTPFixture validates a canned HTTP response, defaulting the method
+// to GET and the status code to 200
+func validateHTTPFixture(fixture *HTTPFixture) error {
+	if fixture.Method == "" {
+		fixture.Method = "GET"
+	}
+	if !slices.Contains(FixtureHTTPMethods, fixture.Method) {
+		return &ValidationError{
+			Field:   "http_fixtures",
+			Message: fmt.Sprintf("fixture method must be one of: %v", FixtureHTTPMethods),
+		}
+	}
+
+	parsed, err := url.Parse(fixture.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return &ValidationError{Field: "http_fixtures", Message: "fixture url must be an absolute http or https URL"}
+	}
+
+	if fixture.StatusCode == 0 {
+		fixture.StatusCode = 200
+	}
+	if fixture.StatusCode < 100 || fixture.StatusCode > 599 {
+		return &ValidationError{Field: "http_fixtures", Message: "fixture status_code must be between 100 and 599"}
+	}
+
+	return nil
+}
+
+// ValidateLuaCodeRequest validates the code to format or lint
+func ValidateLuaCodeRequest(req *LuaCodeRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+	return validateCode(req.Code)
+}
+
+// ValidatePutEmailTemplateRequest validates an email template name and body
+func ValidatePutEmailTemplateRequest(name string, req *PutEmailTemplateRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+
+	if name == "" || len(name) > MaxEmailTemplateNameLength || !isValidEmailTemplateName(name) {
+		return &ValidationError{
+			Field:   "name",
+			Message: fmt.Sprintf("name must be 1 to %d letters, numbers, hyphens or underscores", MaxEmailTemplateNameLength),
+		}
+	}
+
+	if req.HTML == "" && req.Text == "" {
+		return &ValidationError{Field: "html", Message: "html or text is required"}
+	}
+
+	parts := []struct{ field, value string }{{"subject", req.Subject}, {"html", req.HTML}, {"text", req.Text}}
+	for _, part := range parts {
+		if len(part.value) > MaxEmailTemplateLength {
+			return &ValidationError{
+				Field:   part.field,
+				Message: fmt.Sprintf("%s cannot be longer than %d bytes", part.field, MaxEmailTemplateLength),
+			}
+		}
+	}
+
+	tmpl := email.Template{Name: name, Subject: req.Subject, HTML: req.HTML, Text: req.Text}
+	if err := tmpl.Parse(); err != nil {
+		return &ValidationError{Field: "template", Message: fmt.Sprintf("invalid template: %v", err)}
+	}
+
+	return nil
+}
+
+// isValidEmailTemplateName reports whether name only has letters, numbers, hyphens and underscores
+func isValidEmailTemplateName(name string) bool {
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePutModuleRequest validates a Lua module name and code
+func ValidatePutModuleRequest(name string, req *PutModuleRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+
+	if name == "" || len(name) > MaxModuleNameLength || !isValidModuleName(name) {
+		return &ValidationError{
+			Field:   "name",
+			Message: fmt.Sprintf("name must be 1 to %d letters, numbers, hyphens or underscores, optionally separated by dots", MaxModuleNameLength),
+		}
+	}
+
+	if err := validateCode(req.Code); err != nil {
+		return err
+	}
+	return checkSyntax(name, req.Code)
+}
+
+// isValidModuleName reports whether name is made of dot-separated segments of
+// letters, numbers, hyphens and underscores, like "utils" or "auth.jwt"
+func isValidModuleName(name string) bool {
+	for segment := range strings.SplitSeq(name, ".") {
+		if segment == "" || !isValidEmailTemplateName(segment) {
+			return false
+		}
+	}
+	return true
+}
+
 // validateFunctionName validates a function name
 func validateFunctionName(name string) error {
 	trimmed := strings.TrimSpace(name)
@@ -176,6 +677,159 @@ func validateDescription(description string) error {
 	return nil
 }
 
+// ValidateBatchRequest validates the size of a BatchRequest. Operations are
+// validated individually with ValidateBatchOperation.
+func ValidateBatchRequest(req *BatchRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+	if len(req.Operations) == 0 {
+		return &ValidationError{Field: "operations", Message: "operations cannot be empty"}
+	}
+	if len(req.Operations) > MaxBatchOperations {
+		return &ValidationError{
+			Field:   "operations",
+			Message: fmt.Sprintf("cannot have more than %d operations", MaxBatchOperations),
+		}
+	}
+	return nil
+}
+
+// ValidateBatchOperation validates a single BatchOperationRequest
+func ValidateBatchOperation(op *BatchOperationRequest) error {
+	if op == nil {
+		return &ValidationError{Field: "operation", Message: "operation cannot be nil"}
+	}
+
+	switch op.Action {
+	case BatchActionCreate:
+		if op.ID != "" {
+			return &ValidationError{Field: "id", Message: "id cannot be set when creating a function"}
+		}
+		if op.Name == nil {
+			return &ValidationError{Field: "name", Message: "name is required"}
+		}
+		if op.Code == nil {
+			return &ValidationError{Field: "code", Message: "code is required"}
+		}
+		create := CreateFunctionRequest{Name: *op.Name, Description: op.Description, Code: *op.Code, VersionLabel: op.VersionLabel}
+		if err := ValidateCreateFunctionRequest(&create); err != nil {
+			return err
+		}
+	case BatchActionUpdate:
+		if strings.TrimSpace(op.ID) == "" {
+			return &ValidationError{Field: "id", Message: "id is required when updating a function"}
+		}
+	default:
+		return &ValidationError{Field: "action", Message: fmt.Sprintf("action must be %q or %q", BatchActionCreate, BatchActionUpdate)}
+	}
+
+	return ValidateUpdateFunctionRequest(&op.UpdateFunctionRequest)
+}
+
+// ValidateImportRequest validates the size of an import request. Functions are
+// validated individually with ValidateFunctionExport.
+func ValidateImportRequest(req *ExportResponse) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+	if len(req.Functions) == 0 {
+		return &ValidationError{Field: "functions", Message: "functions cannot be empty"}
+	}
+	if len(req.Functions) > MaxImportFunctions {
+		return &ValidationError{
+			Field:   "functions",
+			Message: fmt.Sprintf("cannot import more than %d functions", MaxImportFunctions),
+		}
+	}
+	return nil
+}
+
+// ValidateFunctionExport validates a single function of an import request
+func ValidateFunctionExport(fn *FunctionExport) error {
+	if fn == nil {
+		return &ValidationError{Field: "function", Message: "function cannot be nil"}
+	}
+
+	if strings.TrimSpace(fn.ID) == "" {
+		return &ValidationError{Field: "id", Message: "id is required"}
+	}
+
+	settings := functionSettings(fn.Function)
+	if err := ValidateUpdateFunctionRequest(&settings); err != nil {
+		return err
+	}
+
+	var errs ValidationErrors
+	for key, value := range fn.EnvVars {
+		errs.add(validateEnvVarKey(key))
+		errs.add(validateEnvVarValue(value))
+	}
+
+	if len(fn.Versions) == 0 {
+		errs.add(&ValidationError{Field: "versions", Message: "versions cannot be empty"})
+	}
+	active := 0
+	for _, v := range fn.Versions {
+		errs.add(validateCode(v.Code))
+		if v.Label != nil {
+			errs.add(validateVersionLabel("label", *v.Label))
+		}
+		if v.IsActive {
+			active++
+		}
+	}
+	if active > 1 {
+		errs.add(&ValidationError{Field: "versions", Message: "only one version can be active"})
+	}
+
+	return errs.err()
+}
+
+// ValidateCreateVersionFromURLRequest validates a CreateVersionFromURLRequest
+func ValidateCreateVersionFromURLRequest(req *CreateVersionFromURLRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return &ValidationError{Field: "url", Message: "url must be an absolute http or https URL"}
+	}
+
+	if req.Label != nil {
+		if err := validateVersionLabel("label", *req.Label); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateUpdateVersionRequest validates an UpdateVersionRequest
+func ValidateUpdateVersionRequest(req *UpdateVersionRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil"}
+	}
+
+	if req.Label == nil {
+		return &ValidationError{Field: "label", Message: "label is required (use an empty string to remove it)"}
+	}
+
+	return validateVersionLabel("label", *req.Label)
+}
+
+// validateVersionLabel validates a version label. Empty labels are allowed and mean no label.
+func validateVersionLabel(field, label string) error {
+	if len(strings.TrimSpace(label)) > MaxVersionLabelLength {
+		return &ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("%s cannot be longer than %d characters", field, MaxVersionLabelLength),
+		}
+	}
+	return nil
+}
+
 // validateCode validates function code
 func validateCode(code string) error {
 	trimmed := strings.TrimSpace(code)
@@ -191,6 +845,42 @@ func validateCode(code string) error {
 	return nil
 }
 
+// validateFetchedCode validates code downloaded from a URL: besides the usual
+// code limits it must be UTF-8 text that parses as Lua, which rejects error
+// pages and binaries served with a success status
+func validateFetchedCode(code string) error {
+	if err := validateCode(code); err != nil {
+		return err
+	}
+	if !utf8.ValidString(code) {
+		return &ValidationError{Field: "code", Message: "fetched code is not valid UTF-8 text"}
+	}
+	if err := compileLua("code", code); err != nil {
+		return &SyntaxError{ValidationError{Field: "code", Message: fmt.Sprintf("fetched code is not valid Lua: %v", err)}}
+	}
+	return nil
+}
+
+// checkSyntax reports code that does not compile as a *SyntaxError on the
+// code field; name is the chunk name shown in the message
+func checkSyntax(name, code string) error {
+	if err := compileLua(name, code); err != nil {
+		return &SyntaxError{ValidationError{Field: "code", Message: fmt.Sprintf("code is not valid Lua: %v", err)}}
+	}
+	return nil
+}
+
+// compileLua parses and compiles code without running it, catching the errors
+// the parser lets through, like a break outside of a loop
+func compileLua(name, code string) error {
+	chunk, err := parse.Parse(strings.NewReader(code), name)
+	if err != nil {
+		return err
+	}
+	_, err = lua.Compile(chunk, name)
+	return err
+}
+
 // validateEnvVarKey validates an environment variable key
 func validateEnvVarKey(key string) error {
 	trimmed := strings.TrimSpace(key)
@@ -282,3 +972,240 @@ func validateCronStatus(status string) error {
 		Message: fmt.Sprintf("cron_status must be one of: %v", AllowedCronStatuses),
 	}
 }
+
+// validateAllowedMethods validates a list of allowed HTTP methods.
+// An empty list is allowed and means every method is accepted.
+func validateAllowedMethods(methods []string) error {
+	for _, method := range methods {
+		if !slices.Contains(AllowedHTTPMethods, method) {
+			return &ValidationError{
+				Field:   "allowed_methods",
+				Message: fmt.Sprintf("allowed_methods entries must be one of: %v", AllowedHTTPMethods),
+			}
+		}
+	}
+	return nil
+}
+
+// validateCacheVaryHeaders validates the request headers that are part of a
+// function's cache key
+func validateCacheVaryHeaders(headers []string) error {
+	if len(headers) > MaxCacheVaryHeaders {
+		return &ValidationError{
+			Field:   "cache_vary_headers",
+			Message: fmt.Sprintf("cache_vary_headers cannot have more than %d entries", MaxCacheVaryHeaders),
+		}
+	}
+	for _, name := range headers {
+		if !isValidHeaderName(name) {
+			return &ValidationError{
+				Field:   "cache_vary_headers",
+				Message: fmt.Sprintf("invalid header name %q", name),
+			}
+		}
+	}
+	return nil
+}
+
+// validateDisabledModules validates a list of stdlib modules to disable.
+// An empty list is allowed and enables every module.
+func validateDisabledModules(modules []string) error {
+	for _, module := range modules {
+		if !slices.Contains(runner.SandboxModules, module) {
+			return &ValidationError{
+				Field:   "disabled_modules",
+				Message: fmt.Sprintf("disabled_modules entries must be one of: %v", runner.SandboxModules),
+			}
+		}
+	}
+	return nil
+}
+
+// validateMiddleware validates a function's middleware, a list of module
+// names. Whether the modules exist is only checked when the function runs.
+func validateMiddleware(names []string) error {
+	if len(names) > MaxMiddleware {
+		return &ValidationError{
+			Field:   "middleware",
+			Message: fmt.Sprintf("middleware cannot have more than %d modules", MaxMiddleware),
+		}
+	}
+	for i, name := range names {
+		if len(name) > MaxModuleNameLength || !isValidModuleName(name) {
+			return &ValidationError{
+				Field:   "middleware",
+				Message: fmt.Sprintf("middleware entry %q is not a valid module name", name),
+			}
+		}
+		if slices.Contains(names[:i], name) {
+			return &ValidationError{
+				Field:   "middleware",
+				Message: fmt.Sprintf("middleware module %s is listed more than once", name),
+			}
+		}
+	}
+	return nil
+}
+
+// validateSchema validates a function's input or output schema by compiling it
+func validateSchema(field string, raw []byte) error {
+	if len(raw) > MaxSchemaLength {
+		return &ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("%s cannot exceed %d bytes", field, MaxSchemaLength),
+		}
+	}
+	if _, err := schema.Compile(raw); err != nil {
+		return &ValidationError{Field: field, Message: field + " is invalid: " + err.Error()}
+	}
+	return nil
+}
+
+// validateEmailAllowedFrom validates a function's email sender allowlist.
+// Entries are plain email addresses or domains, optionally prefixed with "@".
+// An empty list is allowed and means any sender is accepted.
+func validateEmailAllowedFrom(entries []string) error {
+	if len(entries) > MaxEmailAllowedFrom {
+		return &ValidationError{
+			Field:   "email_allowed_from",
+			Message: fmt.Sprintf("email_allowed_from cannot have more than %d entries", MaxEmailAllowedFrom),
+		}
+	}
+	for _, entry := range entries {
+		if !validEmailAllowedFromEntry(entry) {
+			return &ValidationError{
+				Field:   "email_allowed_from",
+				Message: fmt.Sprintf("email_allowed_from entry %q must be an email address or a domain", entry),
+			}
+		}
+	}
+	return nil
+}
+
+// validEmailAllowedFromEntry reports whether entry is a bare email address or domain
+func validEmailAllowedFromEntry(entry string) bool {
+	if strings.ContainsAny(entry, ", <>") {
+		return false
+	}
+	if domain, ok := strings.CutPrefix(entry, "@"); ok {
+		entry = domain
+	}
+	if strings.Contains(entry, "@") {
+		addr, err := mail.ParseAddress(entry)
+		return err == nil && addr.Address == entry
+	}
+	return entry != "" && strings.Contains(entry, ".") && !strings.HasPrefix(entry, ".") && !strings.HasSuffix(entry, ".")
+}
+
+// validateMaxVersions validates the maximum number of retained versions.
+// Zero is allowed and removes the limit.
+func validateMaxVersions(maxVersions int) error {
+	if maxVersions < 0 || maxVersions > MaxVersionsLimit {
+		return &ValidationError{
+			Field:   "max_versions",
+			Message: fmt.Sprintf("max_versions must be between 0 and %d", MaxVersionsLimit),
+		}
+	}
+	return nil
+}
+
+// validateDefaultHeaders validates default response header names and values
+func validateDefaultHeaders(headers map[string]string) error {
+	if len(headers) > MaxDefaultHeaders {
+		return &ValidationError{
+			Field:   "default_headers",
+			Message: fmt.Sprintf("cannot have more than %d default headers", MaxDefaultHeaders),
+		}
+	}
+
+	for name, value := range headers {
+		if !isValidHeaderName(name) {
+			return &ValidationError{
+				Field:   "default_headers",
+				Message: fmt.Sprintf("invalid header name %q", name),
+			}
+		}
+		if len(value) > MaxHeaderValueLength {
+			return &ValidationError{
+				Field:   "default_headers",
+				Message: fmt.Sprintf("header %q value cannot be longer than %d characters", name, MaxHeaderValueLength),
+			}
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return &ValidationError{
+				Field:   "default_headers",
+				Message: fmt.Sprintf("header %q value cannot contain control characters", name),
+			}
+		}
+		if isHopByHopHeader(name) {
+			return &ValidationError{
+				Field:   "default_headers",
+				Message: fmt.Sprintf("header %q is managed by the server", name),
+			}
+		}
+	}
+	return nil
+}
+
+// isValidHeaderName checks if a string is a valid HTTP header field name (RFC 9110 token)
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, char := range name {
+		if (char < 'a' || char > 'z') && (char < 'A' || char > 'Z') && (char < '0' || char > '9') &&
+			!strings.ContainsRune("!#$%&'*+-.^_`|~", char) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRoutePrefix validates a function route prefix such as "/app/foo".
+// An empty prefix is allowed and removes the route.
+func validateRoutePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if len(prefix) > MaxRoutePrefixLength {
+		return &ValidationError{
+			Field:   "route_prefix",
+			Message: fmt.Sprintf("route_prefix cannot be longer than %d characters", MaxRoutePrefixLength),
+		}
+	}
+	if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+		return &ValidationError{
+			Field:   "route_prefix",
+			Message: "route_prefix must start with '/' and must not end with '/'",
+		}
+	}
+
+	segments := strings.Split(prefix[1:], "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." || !isValidRouteSegment(segment) {
+			return &ValidationError{
+				Field:   "route_prefix",
+				Message: "route_prefix segments can only contain letters, numbers, '-', '_' and '.'",
+			}
+		}
+	}
+
+	if slices.Contains(ReservedRouteSegments, segments[0]) {
+		return &ValidationError{
+			Field:   "route_prefix",
+			Message: fmt.Sprintf("route_prefix cannot start with a reserved segment: %v", ReservedRouteSegments),
+		}
+	}
+	return nil
+}
+
+// isValidRouteSegment checks if a path segment only contains URL-safe characters
+func isValidRouteSegment(segment string) bool {
+	for _, char := range segment {
+		if (char < 'a' || char > 'z') && (char < 'A' || char > 'Z') && (char < '0' || char > '9') &&
+			char != '-' && char != '_' && char != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,10 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -91,6 +95,15 @@ func TestValidateCreateFunctionRequest(t *testing.T) {
 			wantErr: true,
 			errMsg:  "code cannot be longer",
 		},
+		{
+			name: "code does not compile",
+			req: &CreateFunctionRequest{
+				Name: "test-function",
+				Code: "function handler() return {",
+			},
+			wantErr: true,
+			errMsg:  "code is not valid Lua",
+		},
 		{
 			name: "description too long",
 			req: &CreateFunctionRequest{
@@ -175,6 +188,14 @@ func TestValidateUpdateFunctionRequest(t *testing.T) {
 			wantErr: true,
 			errMsg:  "code cannot be empty",
 		},
+		{
+			name: "break outside of a loop",
+			req: &store.UpdateFunctionRequest{
+				Code: strPtr("function handler() break end"),
+			},
+			wantErr: true,
+			errMsg:  "code is not valid Lua",
+		},
 	}
 
 	for _, tt := range tests {
@@ -191,6 +212,47 @@ func TestValidateUpdateFunctionRequest(t *testing.T) {
 	}
 }
 
+func TestCheckSyntax(t *testing.T) {
+	if err := checkSyntax("code", "function handler(ctx, event) return {statusCode = 200} end"); err != nil {
+		t.Errorf("expected valid code, got %v", err)
+	}
+
+	err := checkSyntax("code", "function handler(")
+	if !isSyntaxError(err) {
+		t.Fatalf("expected a *SyntaxError, got %T", err)
+	}
+	if fields := fieldErrors(err); !strings.Contains(fields["code"], "code is not valid Lua") {
+		t.Errorf("expected a code field error, got %v", fields)
+	}
+}
+
+func TestValidateUpdateFunctionRequest_FieldErrors(t *testing.T) {
+	negative := -1
+	err := ValidateUpdateFunctionRequest(&store.UpdateFunctionRequest{
+		Name:          strPtr(" "),
+		RetentionDays: &negative,
+		CronStatus:    strPtr("sometimes"),
+	})
+
+	fields := fieldErrors(err)
+	if len(fields) != 3 {
+		t.Fatalf("expected 3 field errors, got %v", fields)
+	}
+	for _, field := range []string{"name", "retention_days", "cron_status"} {
+		if fields[field] == "" {
+			t.Errorf("expected an error for %s, got %v", field, fields)
+		}
+	}
+
+	single := fieldErrors(&ValidationError{Field: "label", Message: "label is required"})
+	if len(single) != 1 || single["label"] != "label is required" {
+		t.Errorf("expected a single field error, got %v", single)
+	}
+	if fieldErrors(errors.New("boom")) != nil {
+		t.Error("expected no field errors for other errors")
+	}
+}
+
 func TestValidateUpdateEnvVarsRequest(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -319,6 +381,107 @@ func TestValidateUpdateEnvVarsRequest(t *testing.T) {
 	}
 }
 
+func TestValidatePatchEnvVarsRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *PatchEnvVarsRequest
+		wantErr bool
+		errMsg  string
+	}{
+		{name: "set and delete", req: &PatchEnvVarsRequest{EnvVars: map[string]*string{"API_KEY": strPtr("secret"), "OLD": nil}}},
+		{name: "nil request", req: nil, wantErr: true, errMsg: "request cannot be nil"},
+		{name: "no keys", req: &PatchEnvVarsRequest{}, wantErr: true, errMsg: "at least one key"},
+		{name: "invalid key", req: &PatchEnvVarsRequest{EnvVars: map[string]*string{"BAD-KEY": nil}}, wantErr: true, errMsg: "can only contain"},
+		{name: "empty value", req: &PatchEnvVarsRequest{EnvVars: map[string]*string{"API_KEY": strPtr(" ")}}, wantErr: true, errMsg: "value cannot be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePatchEnvVarsRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePatchEnvVarsRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidatePatchEnvVarsRequest() error = %v, should contain %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateTestFunctionRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *TestFunctionRequest
+		wantErr bool
+		errMsg  string
+	}{
+		{name: "defaults", req: &TestFunctionRequest{}},
+		{name: "method and path", req: &TestFunctionRequest{Method: "POST", Path: "/orders/42"}},
+		{name: "nil request", req: nil, wantErr: true, errMsg: "request cannot be nil"},
+		{name: "unknown method", req: &TestFunctionRequest{Method: "get"}, wantErr: true, errMsg: "method must be one of"},
+		{name: "relative path", req: &TestFunctionRequest{Path: "orders"}, wantErr: true, errMsg: "path must start with /"},
+		{name: "path with query", req: &TestFunctionRequest{Path: "/orders?id=1"}, wantErr: true, errMsg: "cannot contain a query"},
+		{name: "http fixture", req: &TestFunctionRequest{HTTPFixtures: []HTTPFixture{{Method: "POST", URL: "https://api.example.com/orders", StatusCode: 201}}}},
+		{name: "fixture without URL", req: &TestFunctionRequest{HTTPFixtures: []HTTPFixture{{Body: "{}"}}}, wantErr: true, errMsg: "fixture url must be an absolute"},
+		{name: "fixture method", req: &TestFunctionRequest{HTTPFixtures: []HTTPFixture{{Method: "HEAD", URL: "https://api.example.com"}}}, wantErr: true, errMsg: "fixture method must be one of"},
+		{name: "fixture status", req: &TestFunctionRequest{HTTPFixtures: []HTTPFixture{{URL: "https://api.example.com", StatusCode: 700}}}, wantErr: true, errMsg: "between 100 and 599"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTestFunctionRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTestFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateTestFunctionRequest() error = %v, should contain %v", err, tt.errMsg)
+			}
+		})
+	}
+
+	req := &TestFunctionRequest{HTTPFixtures: []HTTPFixture{{URL: "https://api.example.com"}}}
+	_ = ValidateTestFunctionRequest(req)
+	if req.Method != "GET" || req.Path != "/" {
+		t.Errorf("expected GET / by default, got %s %s", req.Method, req.Path)
+	}
+	if fixture := req.HTTPFixtures[0]; fixture.Method != "GET" || fixture.StatusCode != 200 {
+		t.Errorf("expected fixtures to default to GET and 200, got %+v", fixture)
+	}
+}
+
+func TestValidateProviderEnvVar(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr string
+	}{
+		{name: "unrelated key", key: "MY_KEY", value: " anything "},
+		{name: "provider key", key: "OPENAI_API_KEY", value: "sk-123"},
+		{name: "provider URL", key: "RESEND_BASE_URL", value: "https://resend.internal"},
+		{name: "wrong case", key: "openai_api_key", value: "sk-123", wantErr: "did you mean OPENAI_API_KEY?"},
+		{name: "padded key", key: "ANTHROPIC_API_KEY", value: "sk-123\n", wantErr: "whitespace"},
+		{name: "relative URL", key: "OPENAI_ENDPOINT", value: "api.openai.com/v1", wantErr: "absolute http or https URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProviderEnvVar(tt.key, tt.value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestIsValidEnvVarKey(t *testing.T) {
 	tests := []struct {
 		key   string
@@ -734,3 +897,414 @@ func TestValidateUpdateFunctionRequest_WithCronStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateUpdateFunctionRequest_WithAllowedMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods []string
+		wantErr bool
+	}{
+		{name: "single method", methods: []string{"POST"}, wantErr: false},
+		{name: "multiple methods", methods: []string{"GET", "POST", "DELETE"}, wantErr: false},
+		{name: "empty list allows all", methods: []string{}, wantErr: false},
+		{name: "lowercase method", methods: []string{"post"}, wantErr: true},
+		{name: "unknown method", methods: []string{"TRACE"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&store.UpdateFunctionRequest{AllowedMethods: &tt.methods})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithDefaultHeaders(t *testing.T) {
+	tooMany := make(map[string]string)
+	for i := range MaxDefaultHeaders + 1 {
+		tooMany["X-Header-"+strconv.Itoa(i)] = "value"
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr bool
+	}{
+		{name: "valid headers", headers: map[string]string{"X-Frame-Options": "DENY", "Content-Security-Policy": "default-src 'self'"}, wantErr: false},
+		{name: "empty map clears", headers: map[string]string{}, wantErr: false},
+		{name: "invalid header name", headers: map[string]string{"X Frame": "DENY"}, wantErr: true},
+		{name: "empty header name", headers: map[string]string{"": "DENY"}, wantErr: true},
+		{name: "header value with newline", headers: map[string]string{"X-Test": "a\r\nSet-Cookie: x"}, wantErr: true},
+		{name: "header value too long", headers: map[string]string{"X-Test": strings.Repeat("a", MaxHeaderValueLength+1)}, wantErr: true},
+		{name: "hop-by-hop header", headers: map[string]string{"transfer-encoding": "chunked"}, wantErr: true},
+		{name: "too many headers", headers: tooMany, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&store.UpdateFunctionRequest{DefaultHeaders: &tt.headers})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithRoutePrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		wantErr bool
+	}{
+		{name: "single segment", prefix: "/app", wantErr: false},
+		{name: "nested segments", prefix: "/app/foo-bar_v1.2", wantErr: false},
+		{name: "empty clears", prefix: "", wantErr: false},
+		{name: "missing leading slash", prefix: "app", wantErr: true},
+		{name: "trailing slash", prefix: "/app/", wantErr: true},
+		{name: "root", prefix: "/", wantErr: true},
+		{name: "empty segment", prefix: "/app//foo", wantErr: true},
+		{name: "dot segment", prefix: "/app/../api", wantErr: true},
+		{name: "invalid characters", prefix: "/app?x=1", wantErr: true},
+		{name: "reserved api", prefix: "/api/things", wantErr: true},
+		{name: "reserved fn", prefix: "/fn", wantErr: true},
+		{name: "reserved healthz", prefix: "/healthz", wantErr: true},
+		{name: "too long", prefix: "/" + strings.Repeat("a", MaxRoutePrefixLength), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&store.UpdateFunctionRequest{RoutePrefix: &tt.prefix})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithAIBudget(t *testing.T) {
+	tokens := func(n int64) *int64 { return &n }
+	usd := func(n float64) *float64 { return &n }
+
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "token budget", req: store.UpdateFunctionRequest{AIBudgetTokens: tokens(100000)}, wantErr: false},
+		{name: "cost budget", req: store.UpdateFunctionRequest{AIBudgetUSD: usd(12.5)}, wantErr: false},
+		{name: "zero clears", req: store.UpdateFunctionRequest{AIBudgetTokens: tokens(0), AIBudgetUSD: usd(0)}, wantErr: false},
+		{name: "negative tokens", req: store.UpdateFunctionRequest{AIBudgetTokens: tokens(-1)}, wantErr: true},
+		{name: "negative cost", req: store.UpdateFunctionRequest{AIBudgetUSD: usd(-0.5)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "provider and model", req: store.UpdateFunctionRequest{AIDefaultProvider: strPtr("anthropic"), AIDefaultModel: strPtr("claude-3-haiku-20240307")}, wantErr: false},
+		{name: "empty clears", req: store.UpdateFunctionRequest{AIDefaultProvider: strPtr(""), EmailDefaultFrom: strPtr("")}, wantErr: false},
+		{name: "from with name", req: store.UpdateFunctionRequest{EmailDefaultFrom: strPtr("Lunar <noreply@example.com>")}, wantErr: false},
+		{name: "unknown provider", req: store.UpdateFunctionRequest{AIDefaultProvider: strPtr("gemini")}, wantErr: true},
+		{name: "model too long", req: store.UpdateFunctionRequest{AIDefaultModel: strPtr(strings.Repeat("m", MaxAIModelLength+1))}, wantErr: true},
+		{name: "invalid from", req: store.UpdateFunctionRequest{EmailDefaultFrom: strPtr("not-an-email")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithEmailAllowedFrom(t *testing.T) {
+	list := func(entries ...string) *[]string { return &entries }
+
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "addresses and domains", req: store.UpdateFunctionRequest{EmailAllowedFrom: list("noreply@example.com", "example.org", "@example.net")}, wantErr: false},
+		{name: "empty clears", req: store.UpdateFunctionRequest{EmailAllowedFrom: list()}, wantErr: false},
+		{name: "display name", req: store.UpdateFunctionRequest{EmailAllowedFrom: list("Lunar <noreply@example.com>")}, wantErr: true},
+		{name: "comma", req: store.UpdateFunctionRequest{EmailAllowedFrom: list("a@example.com,b@example.com")}, wantErr: true},
+		{name: "not a domain", req: store.UpdateFunctionRequest{EmailAllowedFrom: list("localhost")}, wantErr: true},
+		{name: "empty entry", req: store.UpdateFunctionRequest{EmailAllowedFrom: list("")}, wantErr: true},
+		{name: "too many", req: store.UpdateFunctionRequest{EmailAllowedFrom: list(slices.Repeat([]string{"example.com"}, MaxEmailAllowedFrom+1)...)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithDisabledModules(t *testing.T) {
+	list := func(entries ...string) *[]string { return &entries }
+
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "network modules", req: store.UpdateFunctionRequest{DisabledModules: list("http", "ai", "email")}, wantErr: false},
+		{name: "empty enables all", req: store.UpdateFunctionRequest{DisabledModules: list()}, wantErr: false},
+		{name: "utility module", req: store.UpdateFunctionRequest{DisabledModules: list("json")}, wantErr: true},
+		{name: "unknown module", req: store.UpdateFunctionRequest{DisabledModules: list("HTTP")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithMiddleware(t *testing.T) {
+	list := func(entries ...string) *[]string { return &entries }
+	tooMany := make([]string, MaxMiddleware+1)
+	for i := range tooMany {
+		tooMany[i] = "mw" + strconv.Itoa(i)
+	}
+
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "modules", req: store.UpdateFunctionRequest{Middleware: list("auth", "mw.envelope")}, wantErr: false},
+		{name: "empty removes middleware", req: store.UpdateFunctionRequest{Middleware: list()}, wantErr: false},
+		{name: "invalid name", req: store.UpdateFunctionRequest{Middleware: list("auth/jwt")}, wantErr: true},
+		{name: "empty name", req: store.UpdateFunctionRequest{Middleware: list("")}, wantErr: true},
+		{name: "duplicate", req: store.UpdateFunctionRequest{Middleware: list("auth", "auth")}, wantErr: true},
+		{name: "too many", req: store.UpdateFunctionRequest{Middleware: &tooMany}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithInputSchema(t *testing.T) {
+	raw := func(s string) *json.RawMessage { r := json.RawMessage(s); return &r }
+
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "valid schema", req: store.UpdateFunctionRequest{InputSchema: raw(`{"type": "object", "required": ["name"]}`)}, wantErr: false},
+		{name: "empty removes schema", req: store.UpdateFunctionRequest{InputSchema: raw(`{}`)}, wantErr: false},
+		{name: "not an object", req: store.UpdateFunctionRequest{InputSchema: raw(`"object"`)}, wantErr: true},
+		{name: "invalid keyword", req: store.UpdateFunctionRequest{InputSchema: raw(`{"type": "text"}`)}, wantErr: true},
+		{name: "external reference", req: store.UpdateFunctionRequest{InputSchema: raw(`{"$ref": "https://example.com/schema.json"}`)}, wantErr: true},
+		{name: "too long", req: store.UpdateFunctionRequest{InputSchema: raw(`{"description": "` + strings.Repeat("s", MaxSchemaLength) + `"}`)}, wantErr: true},
+		{name: "valid output schema", req: store.UpdateFunctionRequest{OutputSchema: raw(`{"type": "array"}`)}, wantErr: false},
+		{name: "invalid output schema", req: store.UpdateFunctionRequest{OutputSchema: raw(`{"type": "text"}`)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithSigningSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "valid secret", req: store.UpdateFunctionRequest{SigningSecret: strPtr(strings.Repeat("s", MinSigningSecretLength))}, wantErr: false},
+		{name: "empty clears", req: store.UpdateFunctionRequest{SigningSecret: strPtr("")}, wantErr: false},
+		{name: "too short", req: store.UpdateFunctionRequest{SigningSecret: strPtr("secret")}, wantErr: true},
+		{name: "too long", req: store.UpdateFunctionRequest{SigningSecret: strPtr(strings.Repeat("s", MaxSigningSecretLength+1))}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithCronJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "valid jitter", req: store.UpdateFunctionRequest{CronJitter: intPtr(30)}, wantErr: false},
+		{name: "zero clears", req: store.UpdateFunctionRequest{CronJitter: intPtr(0)}, wantErr: false},
+		{name: "maximum", req: store.UpdateFunctionRequest{CronJitter: intPtr(MaxCronJitter)}, wantErr: false},
+		{name: "negative", req: store.UpdateFunctionRequest{CronJitter: intPtr(-1)}, wantErr: true},
+		{name: "too long", req: store.UpdateFunctionRequest{CronJitter: intPtr(MaxCronJitter + 1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithCronMisfirePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "skip", req: store.UpdateFunctionRequest{CronMisfirePolicy: strPtr("skip")}, wantErr: false},
+		{name: "run once", req: store.UpdateFunctionRequest{CronMisfirePolicy: strPtr("run_once")}, wantErr: false},
+		{name: "empty clears", req: store.UpdateFunctionRequest{CronMisfirePolicy: strPtr("")}, wantErr: false},
+		{name: "unknown", req: store.UpdateFunctionRequest{CronMisfirePolicy: strPtr("run_all")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePutEmailTemplateRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		req      PutEmailTemplateRequest
+		wantErr  bool
+	}{
+		{name: "html only", template: "welcome", req: PutEmailTemplateRequest{Subject: "Hi {{.name}}", HTML: "<p>{{.name}}</p>"}, wantErr: false},
+		{name: "text only", template: "order_shipped-v2", req: PutEmailTemplateRequest{Text: "Shipped"}, wantErr: false},
+		{name: "no body", template: "welcome", req: PutEmailTemplateRequest{Subject: "Hi"}, wantErr: true},
+		{name: "invalid name", template: "wel come", req: PutEmailTemplateRequest{Text: "Hi"}, wantErr: true},
+		{name: "name too long", template: strings.Repeat("a", MaxEmailTemplateNameLength+1), req: PutEmailTemplateRequest{Text: "Hi"}, wantErr: true},
+		{name: "html too long", template: "big", req: PutEmailTemplateRequest{HTML: strings.Repeat("a", MaxEmailTemplateLength+1)}, wantErr: true},
+		{name: "syntax error", template: "broken", req: PutEmailTemplateRequest{Text: "{{.name"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePutEmailTemplateRequest(tt.template, &tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePutEmailTemplateRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePutModuleRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		module  string
+		req     PutModuleRequest
+		wantErr bool
+	}{
+		{name: "valid", module: "utils", req: PutModuleRequest{Code: "return {}"}, wantErr: false},
+		{name: "dotted name", module: "auth.jwt-v2", req: PutModuleRequest{Code: "return {}"}, wantErr: false},
+		{name: "empty segment", module: "auth..jwt", req: PutModuleRequest{Code: "return {}"}, wantErr: true},
+		{name: "trailing dot", module: "utils.", req: PutModuleRequest{Code: "return {}"}, wantErr: true},
+		{name: "invalid name", module: "my utils", req: PutModuleRequest{Code: "return {}"}, wantErr: true},
+		{name: "name too long", module: strings.Repeat("a", MaxModuleNameLength+1), req: PutModuleRequest{Code: "return {}"}, wantErr: true},
+		{name: "empty code", module: "utils", req: PutModuleRequest{Code: "  "}, wantErr: true},
+		{name: "syntax error", module: "utils", req: PutModuleRequest{Code: "return {"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePutModuleRequest(tt.module, &tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePutModuleRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateFunctionRequest_WithVersionLabel(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		req     store.UpdateFunctionRequest
+		wantErr bool
+	}{
+		{name: "label with code", req: store.UpdateFunctionRequest{Code: str("return {}"), VersionLabel: str("v1.2.0")}, wantErr: false},
+		{name: "label without code", req: store.UpdateFunctionRequest{Name: str("fn"), VersionLabel: str("prod")}, wantErr: true},
+		{name: "label too long", req: store.UpdateFunctionRequest{Code: str("return {}"), VersionLabel: str(strings.Repeat("a", MaxVersionLabelLength+1))}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateFunctionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateFunctionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateVersionRequest(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		req     UpdateVersionRequest
+		wantErr bool
+	}{
+		{name: "label", req: UpdateVersionRequest{Label: str("launch")}, wantErr: false},
+		{name: "empty removes", req: UpdateVersionRequest{Label: str("")}, wantErr: false},
+		{name: "missing label", req: UpdateVersionRequest{}, wantErr: true},
+		{name: "label too long", req: UpdateVersionRequest{Label: str(strings.Repeat("a", MaxVersionLabelLength+1))}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdateVersionRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateVersionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
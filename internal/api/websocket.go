@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/store"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// Compile-time check that wsConn implements events.WebSocket
+var _ events.WebSocket = (*wsConn)(nil)
+
+// wsConn adapts an upgraded server-side connection to events.WebSocket
+type wsConn struct {
+	conn      net.Conn
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+func newWSConn(conn net.Conn) *wsConn {
+	return &wsConn{conn: conn}
+}
+
+// Send writes a text frame to the client
+func (c *wsConn) Send(message string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := wsutil.WriteServerText(c.conn, []byte(message)); err != nil {
+		return translateWSError(err)
+	}
+	return nil
+}
+
+// Receive reads the next text or binary message, answering pings along the way
+func (c *wsConn) Receive() (string, error) {
+	data, _, err := wsutil.ReadClientData(c.conn)
+	if err != nil {
+		return "", translateWSError(err)
+	}
+	return string(data), nil
+}
+
+// Close sends a normal closure frame and closes the underlying connection
+func (c *wsConn) Close() error {
+	return c.closeWith(ws.StatusNormalClosure, "")
+}
+
+// closeWith sends a close frame with the given status and closes the connection
+func (c *wsConn) closeWith(status ws.StatusCode, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.writeMu.Lock()
+		_ = ws.WriteFrame(c.conn, ws.NewCloseFrame(ws.NewCloseFrameBody(status, reason)))
+		c.writeMu.Unlock()
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// translateWSError maps errors caused by a closed connection to events.ErrWebSocketClosed
+func translateWSError(err error) error {
+	var closed wsutil.ClosedError
+	if errors.As(err, &closed) || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return events.ErrWebSocketClosed
+	}
+	return err
+}
+
+// isWebSocketUpgrade reports whether the request asks to switch to the WebSocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveWebSocket upgrades the request and runs the function with the connection
+// until the handler returns. Once upgraded, errors can only be reported to the
+// client through the close frame.
+func serveWebSocket(w http.ResponseWriter, r *http.Request, deps ExecuteFunctionDeps, functionID string, event events.HTTPEvent) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		// The upgrader already replied with an HTTP error
+		slog.Debug("WebSocket upgrade failed", "function_id", functionID, "error", err)
+		return
+	}
+
+	// The hijacked connection keeps the server's read and write deadlines,
+	// which would cut long-lived sessions short
+	_ = conn.SetDeadline(time.Time{})
+
+	socket := newWSConn(conn)
+
+	// The request context is not tied to the hijacked connection, so the
+	// execution timeout is what bounds the session
+	result, err := deps.Engine.Execute(r.Context(), engine.ExecutionRequest{
+		FunctionID: functionID,
+		Event:      event,
+		Trigger:    store.ExecutionTriggerHTTP,
+		BaseURL:    deps.BaseURL,
+		WebSocket:  socket,
+		Trace:      traceRequested(r, deps.APIKey),
+		ClientIP:   clientIP(r),
+		RequestID:  RequestIDFromContext(r.Context()),
+	})
+	if err != nil {
+		_ = socket.closeWith(ws.StatusInternalServerError, err.Error())
+		return
+	}
+	if result.Error != nil {
+		_ = socket.closeWith(ws.StatusInternalServerError, "Function execution failed")
+		return
+	}
+	_ = socket.Close()
+}
@@ -11,16 +11,35 @@
 // When a cron job triggers, it makes an HTTP POST request to the function's endpoint
 // with special headers to indicate the execution source. The function handler then
 // records the execution with the appropriate trigger type.
+//
+// Functions with a cron jitter wait a random delay of up to that many seconds
+// before the request, so functions sharing a schedule do not all start at once.
+// The execution still records the time it was scheduled for.
+//
+// Functions with cron_no_overlap skip a fire while a previous execution of
+// theirs is still in flight, recording it as a skipped execution instead.
+// Besides its own cron runs, the scheduler consults the in-flight registry
+// set with SetInFlight, so manual and HTTP executions also hold back a fire.
+//
+// Fires missed while the server was down are dropped, unless the function's
+// misfire policy is run_once: then, when at least one fire was missed since its
+// last successful cron execution, the function runs once on startup. A fire
+// that ran but failed is missed too, so it is caught up on the next startup.
 package cron
 
 import (
 	"context"
+	crand "crypto/rand"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/dimiro1/lunar/internal/ids"
+	"github.com/dimiro1/lunar/internal/inflight"
+	"github.com/dimiro1/lunar/internal/maintenance"
 	"github.com/dimiro1/lunar/internal/store"
 	"github.com/robfig/cron/v3"
 )
@@ -37,6 +56,8 @@ const (
 	HeaderCronFunctionName = "X-Cron-Function-Name"
 	// HeaderCronScheduledTime contains the scheduled execution time (Unix timestamp)
 	HeaderCronScheduledTime = "X-Cron-Scheduled-Time"
+	// HeaderCronToken authenticates the scheduler with the function endpoint
+	HeaderCronToken = "X-Cron-Token"
 
 	// TriggerValueCron is the value for X-Trigger header when triggered by cron
 	TriggerValueCron = "cron"
@@ -55,8 +76,51 @@ type FunctionScheduler struct {
 	cron    *cron.Cron
 	baseURL string
 	jobs    map[string]cron.EntryID // functionID -> entryID
+	running map[string]bool         // functionID -> cron execution in flight, for functions without overlap
 	mu      sync.RWMutex
 	client  *http.Client
+
+	maintenance *maintenance.Mode  // Schedules are skipped while enabled
+	inFlight    *inflight.Registry // Executions in progress of any trigger, consulted for functions without overlap
+	token       string             // Authenticates the cron trigger with the function endpoint
+	ready       <-chan struct{}    // Closed once functions can be invoked; nil starts right away
+	newID       ids.Generator      // Generates the IDs of skipped execution records
+	stopped     bool               // Set by Stop so a pending readiness wait does not start the cron
+	done        chan struct{}      // Closed by Stop to cancel executions waiting out their jitter
+	missed      []missedFire       // Catch-up executions found by Start, run once the cron starts
+}
+
+// maxMissedFires bounds the fires walked through when looking for the latest
+// missed one, for frequent schedules after a long downtime
+const maxMissedFires = 100000
+
+// cronJob holds the settings of a function needed to run its schedule
+type cronJob struct {
+	functionID   string
+	functionName string
+	schedule     string
+	jitter       time.Duration
+	noOverlap    bool
+}
+
+// newCronJob captures the schedule settings of fn
+func newCronJob(fn store.Function) cronJob {
+	job := cronJob{
+		functionID:   fn.ID,
+		functionName: fn.Name,
+		schedule:     *fn.CronSchedule,
+		noOverlap:    fn.CronNoOverlap,
+	}
+	if fn.CronJitter != nil && *fn.CronJitter > 0 {
+		job.jitter = time.Duration(*fn.CronJitter) * time.Second
+	}
+	return job
+}
+
+// missedFire is a fire missed while the server was down that runs on startup
+type missedFire struct {
+	job           cronJob
+	scheduledTime time.Time
 }
 
 // NewScheduler creates a new function scheduler.
@@ -67,27 +131,105 @@ func NewScheduler(db store.DB, baseURL string) *FunctionScheduler {
 		cron:    cron.New(),
 		baseURL: baseURL,
 		jobs:    make(map[string]cron.EntryID),
+		running: make(map[string]bool),
+		done:    make(chan struct{}),
+		newID:   ids.XID,
+		token:   crand.Text(),
 		client: &http.Client{
 			Timeout: 5 * time.Minute, // Match execution timeout
 		},
 	}
 }
 
+// SetMaintenance makes the scheduler skip executions while mode is enabled.
+// It must be called before Start.
+func (s *FunctionScheduler) SetMaintenance(mode *maintenance.Mode) {
+	s.maintenance = mode
+}
+
+// SetInFlight makes functions without overlap skip a fire while any
+// execution of theirs in registry is in flight, not only a cron one.
+// It must be called before Start.
+func (s *FunctionScheduler) SetInFlight(registry *inflight.Registry) {
+	s.inFlight = registry
+}
+
+// Token returns the random token the scheduler sends in X-Cron-Token, which
+// the function endpoint requires before recording an execution as
+// cron-triggered. It is generated on every boot, so it is not the API key and
+// grants nothing else.
+func (s *FunctionScheduler) Token() string {
+	return s.token
+}
+
+// SetReady makes the scheduler wait until ready is closed before its first
+// tick, e.g. until the server accepts the requests that execute functions.
+// Schedules that come due while waiting are skipped, not run late.
+// It must be called before Start.
+func (s *FunctionScheduler) SetReady(ready <-chan struct{}) {
+	s.ready = ready
+}
+
+// SetIDGenerator makes the scheduler generate the IDs of the execution records
+// it writes itself with gen, matching the IDs of the server.
+// It must be called before Start.
+func (s *FunctionScheduler) SetIDGenerator(gen ids.Generator) {
+	s.newID = gen
+}
+
 // Start initializes and starts the scheduler.
-// It loads all functions with active cron schedules and begins scheduling them.
+// It loads all functions with active cron schedules and begins scheduling them,
+// once the channel given to SetReady is closed.
 func (s *FunctionScheduler) Start() error {
 	if err := s.loadSchedules(); err != nil {
 		return fmt.Errorf("failed to load schedules: %w", err)
 	}
 
+	if s.ready == nil {
+		s.startCron()
+		return nil
+	}
+
+	slog.Info("Function cron scheduler waiting for the server to be ready")
+	go func() {
+		<-s.ready
+		s.startCron()
+	}()
+	return nil
+}
+
+// startCron starts ticking unless the scheduler was stopped in the meantime
+func (s *FunctionScheduler) startCron() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
 	s.cron.Start()
 	slog.Info("Function cron scheduler started")
-	return nil
+
+	for _, m := range s.missed {
+		slog.Info("Running missed cron execution",
+			"function_id", m.job.functionID,
+			"function_name", m.job.functionName,
+			"schedule", m.job.schedule,
+			"scheduled_time", m.scheduledTime.Format(time.RFC3339))
+		go s.fire(m.job, m.scheduledTime)
+	}
+	s.missed = nil
 }
 
 // Stop gracefully stops the scheduler.
 func (s *FunctionScheduler) Stop() {
 	slog.Info("Stopping function cron scheduler...")
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+
 	ctx := s.cron.Stop()
 	<-ctx.Done()
 	slog.Info("Function cron scheduler stopped")
@@ -101,12 +243,21 @@ func (s *FunctionScheduler) loadSchedules() error {
 		return fmt.Errorf("failed to list functions with active cron: %w", err)
 	}
 
+	now := time.Now()
 	for _, fn := range functions {
 		if err := s.addJob(fn); err != nil {
 			slog.Error("Failed to add cron job for function",
 				"function_id", fn.ID,
 				"function_name", fn.Name,
 				"error", err)
+			continue
+		}
+
+		if fn.CronMisfirePolicy == nil || *fn.CronMisfirePolicy != string(store.CronMisfireRunOnce) || fn.CronLastSuccessAt == nil {
+			continue
+		}
+		if scheduledTime, ok := lastMissedFire(*fn.CronSchedule, time.Unix(*fn.CronLastSuccessAt, 0), now); ok {
+			s.missed = append(s.missed, missedFire{job: newCronJob(fn), scheduledTime: scheduledTime})
 		}
 	}
 
@@ -157,24 +308,130 @@ func (s *FunctionScheduler) addJobLocked(fn store.Function) error {
 		return nil
 	}
 
-	schedule := *fn.CronSchedule
-	functionID := fn.ID
-	functionName := fn.Name
-
-	entryID, err := s.cron.AddFunc(schedule, func() {
-		s.executeFunction(functionID, functionName, schedule)
+	job := newCronJob(fn)
+	entryID, err := s.cron.AddFunc(job.schedule, func() {
+		s.fire(job, time.Now())
 	})
 	if err != nil {
 		return fmt.Errorf("failed to add cron job: %w", err)
 	}
 
-	s.jobs[functionID] = entryID
+	s.jobs[job.functionID] = entryID
 	slog.Info("Added cron job for function",
+		"function_id", job.functionID,
+		"function_name", job.functionName,
+		"schedule", job.schedule,
+		"jitter", job.jitter,
+		"no_overlap", job.noOverlap)
+
+	return nil
+}
+
+// fire runs a job scheduled for scheduledTime, honoring its overlap and
+// jitter settings
+func (s *FunctionScheduler) fire(job cronJob, scheduledTime time.Time) {
+	if job.noOverlap {
+		if !s.startRun(job.functionID) {
+			s.recordSkipped(job.functionID, job.functionName, job.schedule, scheduledTime)
+			return
+		}
+		defer s.finishRun(job.functionID)
+	}
+	if !s.waitJitter(job.jitter) {
+		return
+	}
+	s.executeFunction(job.functionID, job.functionName, job.schedule, scheduledTime)
+}
+
+// lastMissedFire returns the latest fire of schedule after lastRun and not
+// after now, reporting false when no fire was missed
+func lastMissedFire(schedule string, lastRun, now time.Time) (time.Time, bool) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var missed time.Time
+	next := sched.Next(lastRun)
+	for i := 0; i < maxMissedFires && !next.IsZero() && !next.After(now); i++ {
+		missed = next
+		next = sched.Next(next)
+	}
+	return missed, !missed.IsZero()
+}
+
+// startRun marks a cron execution of the function as in flight.
+// It returns false when the previous one has not finished yet, or another
+// execution of the function is in flight.
+func (s *FunctionScheduler) startRun(functionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[functionID] || s.inFlight.Running(functionID) {
+		return false
+	}
+	s.running[functionID] = true
+	return true
+}
+
+// finishRun clears the in-flight mark set by startRun
+func (s *FunctionScheduler) finishRun(functionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, functionID)
+}
+
+// recordSkipped stores a skipped execution for a fire that overlapped a
+// previous run, so it shows up in the function's execution history.
+func (s *FunctionScheduler) recordSkipped(functionID, functionName, schedule string, scheduledTime time.Time) {
+	slog.Warn("Skipping cron execution, a previous one is still running",
 		"function_id", functionID,
 		"function_name", functionName,
 		"schedule", schedule)
 
-	return nil
+	ctx := context.Background()
+	version, err := s.db.GetActiveVersion(ctx, functionID)
+	if err != nil {
+		slog.Error("Failed to record skipped cron execution",
+			"function_id", functionID,
+			"error", err)
+		return
+	}
+
+	message := "Skipped: a previous execution was still running"
+	scheduledAt := scheduledTime.Unix()
+	_, err = s.db.CreateExecution(ctx, store.Execution{
+		ID:                s.newID(ids.Execution),
+		FunctionID:        functionID,
+		FunctionVersionID: version.ID,
+		Status:            store.ExecutionStatusSkipped,
+		ErrorMessage:      &message,
+		Trigger:           store.ExecutionTriggerCron,
+		ScheduledAt:       &scheduledAt,
+	})
+	if err != nil {
+		slog.Error("Failed to record skipped cron execution",
+			"function_id", functionID,
+			"error", err)
+	}
+}
+
+// waitJitter sleeps for a random delay below jitter. It returns false when the
+// scheduler is stopped while waiting, and the execution must be skipped.
+func (s *FunctionScheduler) waitJitter(jitter time.Duration) bool {
+	if jitter <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(rand.N(jitter))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	}
 }
 
 // executeFunction triggers a function execution via internal HTTP call.
@@ -187,13 +444,21 @@ func (s *FunctionScheduler) addJobLocked(fn store.Function) error {
 //   - Enables the same function code to work identically whether triggered by HTTP or cron
 //
 // The following headers are included in the request to provide context to the execution:
-//   - X-Trigger: "cron" - indicates this is a cron-triggered execution
+//   - X-Trigger: "cron" - indicates this is a cron-triggered execution (honored only with X-Cron-Token)
+//   - X-Cron-Token: the scheduler's per-boot token, see Token
 //   - X-Cron-Schedule: the cron expression that triggered the execution
 //   - X-Cron-Function-Id: the function ID being executed
 //   - X-Cron-Function-Name: the function name being executed
-//   - X-Cron-Scheduled-Time: Unix timestamp of when the execution was scheduled
-func (s *FunctionScheduler) executeFunction(functionID, functionName, schedule string) {
-	scheduledTime := time.Now()
+//   - X-Cron-Scheduled-Time: Unix timestamp of when the execution was scheduled, before any jitter
+func (s *FunctionScheduler) executeFunction(functionID, functionName, schedule string, scheduledTime time.Time) {
+	if s.maintenance.Enabled() {
+		slog.Info("Skipping cron execution during maintenance mode",
+			"function_id", functionID,
+			"function_name", functionName,
+			"schedule", schedule)
+		return
+	}
+
 	url := fmt.Sprintf("%s/fn/%s", s.baseURL, functionID)
 
 	req, err := http.NewRequest(http.MethodPost, url, nil)
@@ -212,6 +477,7 @@ func (s *FunctionScheduler) executeFunction(functionID, functionName, schedule s
 	req.Header.Set(HeaderCronFunctionID, functionID)
 	req.Header.Set(HeaderCronFunctionName, functionName)
 	req.Header.Set(HeaderCronScheduledTime, fmt.Sprintf("%d", scheduledTime.Unix()))
+	req.Header.Set(HeaderCronToken, s.token)
 	req.Header.Set("Content-Type", "application/json")
 
 	slog.Info("Executing function via cron",
@@ -220,6 +486,12 @@ func (s *FunctionScheduler) executeFunction(functionID, functionName, schedule s
 		"schedule", schedule,
 		"scheduled_time", scheduledTime.Format(time.RFC3339))
 
+	if err := s.db.SetCronLastRun(context.Background(), functionID, scheduledTime.Unix()); err != nil {
+		slog.Error("Failed to record cron last run",
+			"function_id", functionID,
+			"error", err)
+	}
+
 	resp, err := s.client.Do(req)
 	if err != nil {
 		slog.Error("Cron execution failed",
@@ -235,6 +507,13 @@ func (s *FunctionScheduler) executeFunction(functionID, functionName, schedule s
 	durationMs := resp.Header.Get("X-Execution-Duration-Ms")
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// The misfire policy looks for fires missed since the last success,
+		// so a failed run is caught up on the next startup
+		if err := s.db.SetCronLastSuccess(context.Background(), functionID, scheduledTime.Unix()); err != nil {
+			slog.Error("Failed to record cron last success",
+				"function_id", functionID,
+				"error", err)
+		}
 		slog.Info("Cron execution completed successfully",
 			"function_id", functionID,
 			"function_name", functionName,
@@ -269,7 +548,11 @@ func (s *FunctionScheduler) GetNextRun(functionID string) *time.Time {
 		return nil
 	}
 
+	// Entries have no next run until the cron starts ticking
 	next := entry.Next
+	if next.IsZero() {
+		next = entry.Schedule.Next(time.Now())
+	}
 	return &next
 }
 
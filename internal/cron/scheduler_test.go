@@ -4,9 +4,13 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/dimiro1/lunar/internal/inflight"
+	"github.com/dimiro1/lunar/internal/maintenance"
 	"github.com/dimiro1/lunar/internal/store"
 )
 
@@ -368,7 +372,8 @@ func TestFunctionScheduler_ExecuteFunction_Headers(t *testing.T) {
 	scheduler := NewScheduler(db, server.URL)
 
 	// Directly call executeFunction to test headers
-	scheduler.executeFunction("func-1", "test-function", "*/5 * * * *")
+	scheduledTime := time.Unix(1700000000, 0)
+	scheduler.executeFunction("func-1", "test-function", "*/5 * * * *", scheduledTime)
 
 	if !requestReceived {
 		t.Fatal("request was not received by test server")
@@ -383,6 +388,12 @@ func TestFunctionScheduler_ExecuteFunction_Headers(t *testing.T) {
 	if got := receivedHeaders.Get(HeaderTrigger); got != TriggerValueCron {
 		t.Errorf("X-Trigger = %q, expected %q", got, TriggerValueCron)
 	}
+	if got := receivedHeaders.Get(HeaderCronToken); got == "" || got != scheduler.Token() {
+		t.Errorf("X-Cron-Token = %q, expected the scheduler token", got)
+	}
+	if got := receivedHeaders.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, expected no credentials", got)
+	}
 	if got := receivedHeaders.Get(HeaderCronSchedule); got != "*/5 * * * *" {
 		t.Errorf("X-Cron-Schedule = %q, expected %q", got, "*/5 * * * *")
 	}
@@ -392,8 +403,8 @@ func TestFunctionScheduler_ExecuteFunction_Headers(t *testing.T) {
 	if got := receivedHeaders.Get(HeaderCronFunctionName); got != "test-function" {
 		t.Errorf("X-Cron-Function-Name = %q, expected %q", got, "test-function")
 	}
-	if got := receivedHeaders.Get(HeaderCronScheduledTime); got == "" {
-		t.Error("X-Cron-Scheduled-Time header is missing")
+	if got := receivedHeaders.Get(HeaderCronScheduledTime); got != "1700000000" {
+		t.Errorf("X-Cron-Scheduled-Time = %q, expected %q", got, "1700000000")
 	}
 	if got := receivedHeaders.Get("Content-Type"); got != "application/json" {
 		t.Errorf("Content-Type = %q, expected %q", got, "application/json")
@@ -411,15 +422,506 @@ func TestFunctionScheduler_ExecuteFunction_ErrorHandling(t *testing.T) {
 	scheduler := NewScheduler(db, server.URL)
 
 	// This should not panic and should log a warning
-	scheduler.executeFunction("func-1", "test-function", "*/5 * * * *")
+	scheduler.executeFunction("func-1", "test-function", "*/5 * * * *", time.Now())
 
 	// Test with invalid URL (connection refused)
 	scheduler2 := NewScheduler(db, "http://localhost:1") // Invalid port
-	scheduler2.executeFunction("func-1", "test-function", "*/5 * * * *")
+	scheduler2.executeFunction("func-1", "test-function", "*/5 * * * *", time.Now())
 
 	// Both should complete without panicking
 }
 
+func TestFunctionScheduler_ExecuteFunction_SkipsDuringMaintenance(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	mode := maintenance.New(true, 0)
+	scheduler := NewScheduler(store.NewMemoryDB(), server.URL)
+	scheduler.SetMaintenance(mode)
+
+	scheduler.executeFunction("func-1", "test-function", "*/5 * * * *", time.Now())
+	if called {
+		t.Error("expected execution to be skipped during maintenance mode")
+	}
+
+	mode.Set(false)
+	scheduler.executeFunction("func-1", "test-function", "*/5 * * * *", time.Now())
+	if !called {
+		t.Error("expected execution to run after maintenance mode ends")
+	}
+}
+
+func TestFunctionScheduler_WaitsUntilReady(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	db := store.NewMemoryDB()
+	_, err := db.CreateFunction(context.Background(), store.Function{
+		ID:           "func-1",
+		Name:         "test-function",
+		CronSchedule: strPtr("@every 1s"),
+		CronStatus:   strPtr("active"),
+	})
+	if err != nil {
+		t.Fatalf("CreateFunction() failed: %v", err)
+	}
+
+	ready := make(chan struct{})
+	scheduler := NewScheduler(db, server.URL)
+	scheduler.SetReady(ready)
+	if err := scheduler.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer scheduler.Stop()
+
+	if next := scheduler.GetNextRun("func-1"); next == nil || next.IsZero() {
+		t.Errorf("expected next run while waiting, got %v", next)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("expected no executions before ready, got %d", n)
+	}
+
+	close(ready)
+	deadline := time.Now().Add(3 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if calls.Load() == 0 {
+		t.Error("expected an execution once ready")
+	}
+}
+
+func TestFunctionScheduler_StopBeforeReady(t *testing.T) {
+	ready := make(chan struct{})
+	scheduler := NewScheduler(store.NewMemoryDB(), "http://localhost:8080")
+	scheduler.SetReady(ready)
+	if err := scheduler.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop() hung while waiting for readiness")
+	}
+
+	// Becoming ready after Stop must not start ticking
+	close(ready)
+	time.Sleep(50 * time.Millisecond)
+	scheduler.mu.RLock()
+	stopped := scheduler.stopped
+	scheduler.mu.RUnlock()
+	if !stopped {
+		t.Error("expected scheduler to stay stopped")
+	}
+}
+
+func TestFunctionScheduler_WaitJitter(t *testing.T) {
+	scheduler := NewScheduler(store.NewMemoryDB(), "http://localhost:8080")
+
+	if !scheduler.waitJitter(0) {
+		t.Error("expected no wait without jitter")
+	}
+
+	start := time.Now()
+	if !scheduler.waitJitter(50 * time.Millisecond) {
+		t.Error("expected wait to complete")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected wait below the jitter window, took %v", elapsed)
+	}
+
+	// Stopping cancels a pending wait so shutdown does not sit out the jitter
+	done := make(chan bool)
+	go func() { done <- scheduler.waitJitter(time.Hour) }()
+	scheduler.Stop()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("expected wait to be cancelled by Stop")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waitJitter did not return after Stop")
+	}
+}
+
+func TestFunctionScheduler_JitterKeepsScheduledTime(t *testing.T) {
+	type request struct {
+		scheduled string
+		received  time.Time
+	}
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- request{scheduled: r.Header.Get(HeaderCronScheduledTime), received: time.Now()}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	jitter := 2
+	db := store.NewMemoryDB()
+	_, err := db.CreateFunction(context.Background(), store.Function{
+		ID:           "func-1",
+		Name:         "test-function",
+		CronSchedule: strPtr("@every 1s"),
+		CronStatus:   strPtr("active"),
+		CronJitter:   &jitter,
+	})
+	if err != nil {
+		t.Fatalf("CreateFunction() failed: %v", err)
+	}
+
+	scheduler := NewScheduler(db, server.URL)
+	fireTime := time.Now()
+	if err := scheduler.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer scheduler.Stop()
+
+	select {
+	case req := <-requests:
+		scheduled, err := strconv.ParseInt(req.scheduled, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid X-Cron-Scheduled-Time %q: %v", req.scheduled, err)
+		}
+		// The scheduled time is the tick, not the delayed start
+		if scheduled < fireTime.Unix() || scheduled > req.received.Unix() {
+			t.Errorf("scheduled time %d outside [%d, %d]", scheduled, fireTime.Unix(), req.received.Unix())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a cron execution")
+	}
+}
+
+func TestFunctionScheduler_NoOverlap(t *testing.T) {
+	tests := []struct {
+		name      string
+		noOverlap bool
+		wantCalls int32
+	}{
+		{name: "overlap allowed", noOverlap: false, wantCalls: 2},
+		{name: "no overlap", noOverlap: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			started := make(chan struct{}, 2)
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				started <- struct{}{}
+				<-release // A slow function
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			db := store.NewMemoryDB()
+			ctx := context.Background()
+			_, err := db.CreateFunction(ctx, store.Function{
+				ID:            "func-1",
+				Name:          "test-function",
+				CronSchedule:  strPtr("* * * * *"),
+				CronStatus:    strPtr("active"),
+				CronNoOverlap: tt.noOverlap,
+			})
+			if err != nil {
+				t.Fatalf("CreateFunction() failed: %v", err)
+			}
+			if _, err := db.CreateVersion(ctx, "func-1", "code", nil, nil); err != nil {
+				t.Fatalf("CreateVersion() failed: %v", err)
+			}
+
+			scheduler := NewScheduler(db, server.URL)
+			if err := scheduler.Start(); err != nil {
+				t.Fatalf("Start() failed: %v", err)
+			}
+			defer scheduler.Stop()
+
+			// Fire the job twice in quick succession, the first run still in flight
+			scheduler.mu.RLock()
+			job := scheduler.cron.Entry(scheduler.jobs["func-1"]).Job
+			scheduler.mu.RUnlock()
+
+			go job.Run()
+			<-started
+			second := make(chan struct{})
+			go func() {
+				job.Run()
+				close(second)
+			}()
+
+			if tt.noOverlap {
+				select {
+				case <-second:
+				case <-time.After(2 * time.Second):
+					t.Fatal("expected the overlapping fire to be skipped right away")
+				}
+			} else {
+				<-started
+			}
+			close(release)
+
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("expected %d executions, got %d", tt.wantCalls, n)
+			}
+
+			executions, _, err := db.ListExecutions(ctx, "func-1", store.PaginationParams{Limit: 10})
+			if err != nil {
+				t.Fatalf("ListExecutions() failed: %v", err)
+			}
+			if !tt.noOverlap {
+				if len(executions) != 0 {
+					t.Errorf("expected no skipped executions, got %+v", executions)
+				}
+				return
+			}
+			if len(executions) != 1 {
+				t.Fatalf("expected 1 skipped execution, got %d", len(executions))
+			}
+			skipped := executions[0]
+			if skipped.Status != store.ExecutionStatusSkipped || skipped.Trigger != store.ExecutionTriggerCron {
+				t.Errorf("expected skipped cron execution, got status %q trigger %q", skipped.Status, skipped.Trigger)
+			}
+			if skipped.ScheduledAt == nil {
+				t.Error("expected skipped execution to record its scheduled time")
+			}
+
+			// Once the previous run finished, the next fire runs again
+			scheduler.mu.RLock()
+			_, inFlight := scheduler.running["func-1"]
+			scheduler.mu.RUnlock()
+			for inFlight {
+				time.Sleep(10 * time.Millisecond)
+				scheduler.mu.RLock()
+				_, inFlight = scheduler.running["func-1"]
+				scheduler.mu.RUnlock()
+			}
+			go job.Run()
+			select {
+			case <-started:
+			case <-time.After(2 * time.Second):
+				t.Fatal("expected the next fire to run")
+			}
+		})
+	}
+}
+
+func TestLastMissedFire(t *testing.T) {
+	day := func(d, hour int) time.Time {
+		return time.Date(2025, time.March, d, hour, 0, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name       string
+		schedule   string
+		lastRun    time.Time
+		now        time.Time
+		wantMissed time.Time
+		wantOK     bool
+	}{
+		{name: "missed one", schedule: "0 9 * * *", lastRun: day(10, 9), now: day(11, 10), wantMissed: day(11, 9), wantOK: true},
+		{name: "missed several", schedule: "0 9 * * *", lastRun: day(7, 9), now: day(11, 10), wantMissed: day(11, 9), wantOK: true},
+		{name: "nothing missed", schedule: "0 9 * * *", lastRun: day(10, 9), now: day(11, 8), wantOK: false},
+		{name: "descriptor", schedule: "@daily", lastRun: day(10, 0), now: day(11, 1), wantMissed: day(11, 0), wantOK: true},
+		{name: "invalid schedule", schedule: "not a schedule", lastRun: day(10, 9), now: day(11, 10), wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missed, ok := lastMissedFire(tt.schedule, tt.lastRun, tt.now)
+			if ok != tt.wantOK {
+				t.Fatalf("lastMissedFire() ok = %v, expected %v", ok, tt.wantOK)
+			}
+			if ok && !missed.Equal(tt.wantMissed) {
+				t.Errorf("lastMissedFire() = %v, expected %v", missed, tt.wantMissed)
+			}
+		})
+	}
+}
+
+func TestFunctionScheduler_NoOverlapInFlight(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+	_, err := db.CreateFunction(ctx, store.Function{
+		ID:            "func-1",
+		Name:          "test-function",
+		CronSchedule:  strPtr("* * * * *"),
+		CronStatus:    strPtr("active"),
+		CronNoOverlap: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateFunction() failed: %v", err)
+	}
+	if _, err := db.CreateVersion(ctx, "func-1", "code", nil, nil); err != nil {
+		t.Fatalf("CreateVersion() failed: %v", err)
+	}
+
+	registry := inflight.New()
+	scheduler := NewScheduler(db, server.URL)
+	scheduler.SetInFlight(registry)
+	if err := scheduler.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer scheduler.Stop()
+
+	scheduler.mu.RLock()
+	job := scheduler.cron.Entry(scheduler.jobs["func-1"]).Job
+	scheduler.mu.RUnlock()
+
+	// A manual or HTTP execution is still running when the schedule fires
+	done := registry.Start("func-1")
+	job.Run()
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("expected the fire to be skipped, got %d executions", n)
+	}
+	executions, _, err := db.ListExecutions(ctx, "func-1", store.PaginationParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListExecutions() failed: %v", err)
+	}
+	if len(executions) != 1 || executions[0].Status != store.ExecutionStatusSkipped {
+		t.Fatalf("expected 1 skipped execution, got %+v", executions)
+	}
+
+	// Once it finished, the next fire runs
+	done()
+	job.Run()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the next fire to run, got %d executions", n)
+	}
+}
+
+func TestFunctionScheduler_MisfirePolicy(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	requests := make(chan http.Header, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Header.Clone()
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	// Both functions last succeeded two years ago; the latest yearly fire ran
+	// but failed
+	wantScheduled := time.Date(time.Now().Year(), time.January, 1, 0, 0, 0, 0, time.Local).Unix()
+	lastSuccess := time.Now().AddDate(-2, 0, 0).Unix()
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+	for _, policy := range []string{"run_once", "skip"} {
+		_, err := db.CreateFunction(ctx, store.Function{
+			ID:                "func-" + policy,
+			Name:              "test-function",
+			CronSchedule:      strPtr("0 0 1 1 *"),
+			CronStatus:        strPtr("active"),
+			CronMisfirePolicy: strPtr(policy),
+			CronLastRunAt:     &wantScheduled,
+			CronLastSuccessAt: &lastSuccess,
+		})
+		if err != nil {
+			t.Fatalf("CreateFunction() failed: %v", err)
+		}
+	}
+
+	// start runs a scheduler as if the server restarted and returns the
+	// function it caught up, if any
+	start := func(t *testing.T) string {
+		t.Helper()
+		ready := make(chan struct{})
+		scheduler := NewScheduler(db, server.URL)
+		scheduler.SetReady(ready)
+		if err := scheduler.Start(); err != nil {
+			t.Fatalf("Start() failed: %v", err)
+		}
+		defer scheduler.Stop()
+
+		// Catch-up waits for readiness like regular fires
+		select {
+		case <-requests:
+			t.Fatal("expected no catch-up before ready")
+		case <-time.After(100 * time.Millisecond):
+		}
+		close(ready)
+
+		var headers http.Header
+		select {
+		case headers = <-requests:
+		case <-time.After(500 * time.Millisecond):
+			return ""
+		}
+		if got := headers.Get(HeaderCronScheduledTime); got != strconv.FormatInt(wantScheduled, 10) {
+			t.Errorf("X-Cron-Scheduled-Time = %q, expected the missed fire %d", got, wantScheduled)
+		}
+		select {
+		case extra := <-requests:
+			t.Errorf("expected a single catch-up, got another for %q", extra.Get(HeaderCronFunctionID))
+		case <-time.After(200 * time.Millisecond):
+		}
+		return headers.Get(HeaderCronFunctionID)
+	}
+
+	// waitRecorded waits for the catch-up run to be recorded
+	waitRecorded := func(t *testing.T, wantSuccess int64) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			fn, err := db.GetFunction(ctx, "func-run_once")
+			if err != nil {
+				t.Fatalf("GetFunction() failed: %v", err)
+			}
+			if fn.CronLastRunAt != nil && *fn.CronLastRunAt == wantScheduled &&
+				fn.CronLastSuccessAt != nil && *fn.CronLastSuccessAt == wantSuccess {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected last run %d and last success %d, got %v and %v",
+					wantScheduled, wantSuccess, fn.CronLastRunAt, fn.CronLastSuccessAt)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The failed fire is caught up, and failing again it stays missed
+	if got := start(t); got != "func-run_once" {
+		t.Fatalf("expected catch-up for func-run_once, got %q", got)
+	}
+	waitRecorded(t, lastSuccess)
+	if got := start(t); got != "func-run_once" {
+		t.Fatalf("expected the failed catch-up to be retried, got %q", got)
+	}
+	waitRecorded(t, lastSuccess)
+
+	// A successful catch-up is recorded, so the next startup has nothing to do
+	status.Store(http.StatusOK)
+	if got := start(t); got != "func-run_once" {
+		t.Fatalf("expected catch-up for func-run_once, got %q", got)
+	}
+	waitRecorded(t, wantScheduled)
+	if got := start(t); got != "" {
+		t.Errorf("expected no catch-up after a success, got %q", got)
+	}
+}
+
 func TestHeaderConstants(t *testing.T) {
 	// Verify header constants are set correctly
 	if HeaderTrigger != "X-Trigger" {
@@ -102,3 +102,35 @@ func Compare(oldText, newText string) Result {
 
 	return Result{Lines: lines}
 }
+
+// Summary counts the lines of a diff by type. Hunks is the number of
+// contiguous blocks of added or removed lines.
+type Summary struct {
+	Added     int
+	Removed   int
+	Unchanged int
+	Hunks     int
+}
+
+// Summary counts the lines and hunks of the result
+func (r Result) Summary() Summary {
+	var summary Summary
+	inHunk := false
+	for _, line := range r.Lines {
+		switch line.Type {
+		case LineTypeAdded:
+			summary.Added++
+		case LineTypeRemoved:
+			summary.Removed++
+		default:
+			summary.Unchanged++
+			inHunk = false
+			continue
+		}
+		if !inHunk {
+			summary.Hunks++
+			inHunk = true
+		}
+	}
+	return summary
+}
@@ -151,3 +151,19 @@ end`
 		t.Error("expected to find added line with 'New version'")
 	}
 }
+
+func TestResult_Summary(t *testing.T) {
+	oldText := "line 1\nline 2\nline 3\nline 4\nline 5"
+	newText := "line 1\nline 2 changed\nline 3\nline 4\nline 5\nline 6"
+
+	summary := Compare(oldText, newText).Summary()
+
+	want := Summary{Added: 2, Removed: 1, Unchanged: 4, Hunks: 2}
+	if summary != want {
+		t.Errorf("expected %+v, got %+v", want, summary)
+	}
+
+	if empty := Compare("same", "same").Summary(); empty != (Summary{Unchanged: 1}) {
+		t.Errorf("expected no changes, got %+v", empty)
+	}
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/services/kv"
+)
+
+// Response headers describing how a response was served from the cache
+const (
+	HeaderCache        = "X-Cache" // HIT or MISS
+	headerAge          = "Age"
+	headerCacheControl = "Cache-Control"
+)
+
+// cacheNamespacePrefix keeps cached responses apart from the KV entries of
+// the function itself
+const cacheNamespacePrefix = "cache:"
+
+// DefaultMaxCacheEntries bounds the responses cached per function. Cache keys
+// come from the request, so without a bound callers could fill the store by
+// varying the query or body.
+const DefaultMaxCacheEntries = 1000
+
+// ResponseCache stores the responses of functions with a cache TTL in the KV
+// store, so repeated requests are answered without running the function.
+// Entries expire lazily: an expired entry is ignored and replaced by the next
+// execution. Once a function has maxEntries cached, expired entries and then
+// the oldest ones are evicted to make room.
+type ResponseCache struct {
+	kv         kv.Store
+	now        func() time.Time
+	maxEntries int
+}
+
+// NewResponseCache creates a response cache backed by store
+func NewResponseCache(store kv.Store) *ResponseCache {
+	return &ResponseCache{kv: store, now: time.Now, maxEntries: DefaultMaxCacheEntries}
+}
+
+// cachedResponse is a response stored in the cache
+type cachedResponse struct {
+	ExecutionID string              `json:"execution_id"` // Execution that produced the response
+	StoredAt    int64               `json:"stored_at"`
+	ExpiresAt   int64               `json:"expires_at"`
+	Response    events.HTTPResponse `json:"response"`
+}
+
+// Purge removes every cached response of a function
+func (c *ResponseCache) Purge(functionID string) error {
+	return c.kv.DeleteAll(cacheNamespacePrefix + functionID)
+}
+
+// get returns the unexpired response cached under key, marked as a hit
+func (c *ResponseCache) get(functionID, key string) (*events.HTTPResponse, string, bool) {
+	value, err := c.kv.Get(cacheNamespacePrefix+functionID, key)
+	if err != nil {
+		return nil, "", false
+	}
+	var entry cachedResponse
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		return nil, "", false
+	}
+	now := c.now().Unix()
+	if now >= entry.ExpiresAt {
+		return nil, "", false
+	}
+
+	resp := entry.Response
+	resp.Headers = maps.Clone(resp.Headers)
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	resp.Headers[HeaderCache] = "HIT"
+	resp.Headers[headerAge] = strconv.FormatInt(now-entry.StoredAt, 10)
+	if !hasHeader(resp.Headers, headerCacheControl) {
+		resp.Headers[headerCacheControl] = "max-age=" + strconv.FormatInt(entry.ExpiresAt-now, 10)
+	}
+	return &resp, entry.ExecutionID, true
+}
+
+// set caches resp under key for ttl seconds and marks resp as a miss.
+// Responses the function marked as no-store or private are not cached.
+func (c *ResponseCache) set(functionID, key, executionID string, resp *events.HTTPResponse, ttl int) error {
+	if cacheControl := headerValue(resp.Headers, headerCacheControl); strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+		return nil
+	}
+
+	now := c.now().Unix()
+	value, err := json.Marshal(cachedResponse{
+		ExecutionID: executionID,
+		StoredAt:    now,
+		ExpiresAt:   now + int64(ttl),
+		Response:    *resp,
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.makeRoom(functionID, key); err != nil {
+		return err
+	}
+	if err := c.kv.Set(cacheNamespacePrefix+functionID, key, string(value)); err != nil {
+		return err
+	}
+
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	resp.Headers[HeaderCache] = "MISS"
+	if !hasHeader(resp.Headers, headerCacheControl) {
+		resp.Headers[headerCacheControl] = "max-age=" + strconv.Itoa(ttl)
+	}
+	return nil
+}
+
+// makeRoom evicts entries of a function about to cache a new key once it has
+// maxEntries cached: the expired ones, then the oldest. It evicts down to 90%
+// of the limit, so a full cache is not scanned on every miss.
+func (c *ResponseCache) makeRoom(functionID, key string) error {
+	namespace := cacheNamespacePrefix + functionID
+	if _, err := c.kv.Get(namespace, key); err == nil {
+		return nil // Replacing an entry does not grow the cache
+	}
+
+	keys, err := c.kv.Keys(namespace)
+	if err != nil {
+		return err
+	}
+	if len(keys) < c.maxEntries {
+		return nil
+	}
+
+	type storedEntry struct {
+		key      string
+		storedAt int64
+	}
+	now := c.now().Unix()
+	var kept []storedEntry
+	for _, k := range keys {
+		value, err := c.kv.Get(namespace, k)
+		if err != nil {
+			continue
+		}
+		var entry cachedResponse
+		if json.Unmarshal([]byte(value), &entry) != nil || now >= entry.ExpiresAt {
+			if err := c.kv.Delete(namespace, k); err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, storedEntry{key: k, storedAt: entry.StoredAt})
+	}
+
+	target := c.maxEntries * 9 / 10
+	if len(kept) <= target {
+		return nil
+	}
+	slices.SortFunc(kept, func(a, b storedEntry) int { return cmp.Compare(a.storedAt, b.storedAt) })
+	for _, entry := range kept[:len(kept)-target] {
+		if err := c.kv.Delete(namespace, entry.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheKey identifies a request to a function version by its method, path,
+// query (every value of repeated parameters), body and the values of the vary headers. Only HTTP events are cached.
+func cacheKey(versionID string, event events.Event, varyHeaders []string) (string, bool) {
+	httpEvent, ok := event.(events.HTTPEvent)
+	if !ok {
+		return "", false
+	}
+
+	h := sha256.New()
+	write := func(parts ...string) {
+		for _, part := range parts {
+			h.Write([]byte(strconv.Itoa(len(part))))
+			h.Write([]byte{':'})
+			h.Write([]byte(part))
+		}
+	}
+	write(versionID, httpEvent.Method, httpEvent.Path, httpEvent.Body)
+	for _, name := range slices.Sorted(maps.Keys(httpEvent.Query)) {
+		values := httpEvent.QueryValues(name)
+		write("q", name, strconv.Itoa(len(values)))
+		write(values...)
+	}
+	for _, name := range varyHeaders {
+		write("h", strings.ToLower(name), headerValue(httpEvent.Headers, name))
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// bypassesCache reports whether the client asked for a fresh response with
+// Cache-Control: no-cache. The fresh response still replaces the cached one.
+func bypassesCache(event events.Event) bool {
+	httpEvent, ok := event.(events.HTTPEvent)
+	return ok && strings.Contains(headerValue(httpEvent.Headers, headerCacheControl), "no-cache")
+}
+
+// headerValue returns the value of a header, matching its name case-insensitively
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// hasHeader reports whether headers contain name, matched case-insensitively
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+// CostRates prices executions for chargeback. The cost of an execution adds
+// its compute time, the estimated cost of its AI requests and the emails it
+// sent.
+type CostRates struct {
+	ComputePerSecond float64       // USD per second of execution time
+	PerEmail         float64       // USD per email sent
+	AIPrices         ai.PriceTable // Prices of AI tokens; models without a price add nothing
+}
+
+// Cost returns the cost in USD of an execution that ran for duration and made
+// the given requests. Failed emails are not charged.
+func (r CostRates) Cost(duration time.Duration, aiRequests []store.AIRequest, emailRequests []store.EmailRequest) float64 {
+	cost := duration.Seconds() * r.ComputePerSecond
+	for _, req := range aiRequests {
+		var input, output int64
+		if req.InputTokens != nil {
+			input = int64(*req.InputTokens)
+		}
+		if req.OutputTokens != nil {
+			output = int64(*req.OutputTokens)
+		}
+		if aiCost, ok := r.AIPrices.Cost(req.Provider, req.Model, input, output); ok {
+			cost += aiCost
+		}
+	}
+	for _, req := range emailRequests {
+		if req.Status == store.EmailRequestStatusSuccess {
+			cost += r.PerEmail
+		}
+	}
+	return cost
+}
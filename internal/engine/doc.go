@@ -13,7 +13,9 @@
 // that handle the actual code execution.
 //
 // Engine: Orchestrates the complete execution lifecycle including:
+//   - Checking the server's Policy, which may deny an execution
 //   - Function and version retrieval
+//   - Validating HTTP request bodies against the function's input schema
 //   - Execution record management
 //   - Event masking for storage
 //   - Runtime invocation
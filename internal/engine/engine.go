@@ -2,13 +2,21 @@ package engine
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/inflight"
 	"github.com/dimiro1/lunar/internal/masking"
+	"github.com/dimiro1/lunar/internal/schema"
 	"github.com/dimiro1/lunar/internal/services/ai"
 	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/services/env"
@@ -38,6 +46,10 @@ type Config struct {
 	EmailTracker     email.Tracker
 	ExecutionTimeout time.Duration
 	IDGenerator      func() string
+	Policy           Policy             // Checked before every execution (nil allows all)
+	DevMode          bool               // Check every response against its function's output schema
+	Costs            *CostRates         // Prices each execution, stored as its cost (nil leaves costs unset)
+	InFlight         *inflight.Registry // Marks executions in flight while they run (nil tracks nothing)
 }
 
 // DefaultEngine is the default implementation of the Engine interface.
@@ -54,10 +66,20 @@ type DefaultEngine struct {
 	emailTracker     email.Tracker
 	executionTimeout time.Duration
 	idGenerator      func() string
+	policy           Policy
+	devMode          bool
+	costs            *CostRates
+	inFlight         *inflight.Registry
+	cache            *ResponseCache
+	schemas          *schema.Cache
 }
 
 // New creates a new DefaultEngine with the given configuration.
 func New(cfg Config) *DefaultEngine {
+	var cache *ResponseCache
+	if cfg.KVStore != nil {
+		cache = NewResponseCache(cfg.KVStore)
+	}
 	return &DefaultEngine{
 		db:               cfg.DB,
 		runtime:          cfg.Runtime,
@@ -71,6 +93,12 @@ func New(cfg Config) *DefaultEngine {
 		emailTracker:     cfg.EmailTracker,
 		executionTimeout: cfg.ExecutionTimeout,
 		idGenerator:      cfg.IDGenerator,
+		policy:           cfg.Policy,
+		devMode:          cfg.DevMode,
+		costs:            cfg.Costs,
+		inFlight:         cfg.InFlight,
+		cache:            cache,
+		schemas:          schema.NewCache(),
 	}
 }
 
@@ -79,6 +107,18 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 	startTime := time.Now()
 	executionID := e.idGenerator()
 
+	if req.Trigger == "" {
+		req.Trigger = store.ExecutionTriggerHTTP
+	}
+	if !req.Trigger.Valid() {
+		return nil, &InvalidTriggerError{Trigger: req.Trigger}
+	}
+
+	// Enforce the server's policy before anything about the function is revealed
+	if err := e.checkPolicy(ctx, req); err != nil {
+		return nil, err
+	}
+
 	// Get the function
 	fn, err := e.db.GetFunction(ctx, req.FunctionID)
 	if err != nil {
@@ -90,12 +130,33 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 		return nil, &FunctionDisabledError{FunctionID: req.FunctionID}
 	}
 
+	// Reject requests that do not match the function's input schema before
+	// anything runs or is recorded
+	if err := e.checkInput(fn, req.Event); err != nil {
+		return nil, err
+	}
+
 	// Get the active version
 	version, err := e.db.GetActiveVersion(ctx, req.FunctionID)
 	if err != nil {
 		return nil, &NoActiveVersionError{FunctionID: req.FunctionID}
 	}
 
+	// Answer repeated requests to functions with a cache TTL from the cache
+	cacheKey, cacheable := e.cacheKey(fn, version.ID, req)
+	if cacheable && !bypassesCache(req.Event) {
+		if resp, cachedExecutionID, ok := e.cache.get(fn.ID, cacheKey); ok {
+			return &ExecutionResult{
+				ExecutionID:       cachedExecutionID,
+				FunctionVersionID: version.ID,
+				Response:          resp,
+				Duration:          time.Since(startTime),
+				Status:            store.ExecutionStatusSuccess,
+				Cached:            true,
+			}, nil
+		}
+	}
+
 	// Create execution context
 	execContext := &events.ExecutionContext{
 		ExecutionID: executionID,
@@ -103,6 +164,7 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 		StartedAt:   time.Now().Unix(),
 		Version:     strconv.Itoa(version.Version),
 		BaseURL:     req.BaseURL,
+		RequestID:   req.RequestID,
 	}
 
 	// Mask and serialize the event for storage
@@ -119,20 +181,52 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 		Status:            store.ExecutionStatusPending,
 		EventJSON:         &eventJSONStr,
 		Trigger:           req.Trigger,
+		ScheduledAt:       req.ScheduledAt,
+		RequestBytes:      requestBytes(req.Event),
 	}
 
-	if _, err := e.db.CreateExecution(ctx, execution); err != nil {
-		return nil, &ExecutionRecordError{Err: err}
+	if !req.DryRun {
+		if _, err := e.db.CreateExecution(ctx, execution); err != nil {
+			return nil, &ExecutionRecordError{Err: err}
+		}
 	}
+	defer e.inFlight.Start(req.FunctionID)()
 
 	// Execute via runtime
 	runtimeReq := RuntimeRequest{
-		Code:    version.Code,
-		Context: execContext,
-		Event:   req.Event,
+		Code:         version.Code,
+		Context:      execContext,
+		Event:        req.Event,
+		WebSocket:    req.WebSocket,
+		EventStream:  req.EventStream,
+		EnvOverrides: req.EnvOverrides,
+		Trace:        req.Trace,
+		CaptureHTTP:  fn.CaptureHTTP,
+		AIDefaults: ai.Defaults{
+			Provider: derefString(fn.AIDefaultProvider),
+			Model:    derefString(fn.AIDefaultModel),
+		},
+		EmailDefaults: email.Defaults{
+			From: derefString(fn.EmailDefaultFrom),
+		},
+		EmailAllowed:    email.SenderAllowlist(fn.EmailAllowedFrom),
+		DisabledModules: fn.DisabledModules,
+		Tags:            make(map[string]string),
+		Spans:           new([]store.Span),
+		ReuseState:      fn.ReuseState,
+		MaxLogEntries:   derefInt(fn.MaxLogEntries),
+		MaxOutbound:     derefInt(fn.MaxOutboundCalls),
+		OutboundTime:    time.Duration(derefInt(fn.OutboundBudget)) * time.Second,
+		Sandbox:         req.Sandbox,
+		Seed:            req.Seed,
+		FrozenTime:      req.FrozenTime,
+		Middleware:      fn.Middleware,
+		DryRun:          req.DryRun,
 	}
 
+	runStart := time.Now()
 	runtimeResult, runErr := e.runtime.Execute(ctx, runtimeReq)
+	spans := finishSpans(*runtimeReq.Spans, time.Since(runStart))
 
 	// Calculate duration
 	duration := time.Since(startTime)
@@ -150,6 +244,24 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 		status = store.ExecutionStatusError
 	}
 
+	// Report responses that drift from the function's output schema
+	var outputErrors map[string]string
+	if (e.devMode || req.ValidateOutput) && runErr == nil && runtimeResult != nil {
+		outputErrors = e.checkOutput(fn, runtimeResult.Response)
+		if len(outputErrors) > 0 && !req.DryRun {
+			e.logger.Warn(executionID, "Response does not match the output schema: "+formatSchemaErrors(outputErrors))
+		}
+	}
+
+	// Cache successful responses, before the cache headers are added. Streamed
+	// responses were already sent as events and are not cached.
+	streamed := req.EventStream != nil && req.EventStream.Started()
+	if cacheable && !streamed && status == store.ExecutionStatusSuccess && runtimeResult != nil && runtimeResult.Response != nil {
+		if err := e.cache.set(fn.ID, cacheKey, executionID, runtimeResult.Response, *fn.CacheTTL); err != nil {
+			slog.Error("Failed to cache response", "execution_id", executionID, "error", err)
+		}
+	}
+
 	// Save response JSON if function has SaveResponse enabled
 	var responseJSON *string
 	if fn.SaveResponse && runtimeResult != nil && runtimeResult.Response != nil {
@@ -157,14 +269,41 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 		responseJSON = &responseJSONStr
 	}
 
+	var respBytes *int64
+	if runtimeResult != nil && runtimeResult.Response != nil {
+		respBytes = responseBytes(runtimeResult.Response)
+	}
+
 	// Update execution record
-	if err := e.db.UpdateExecution(ctx, executionID, status, &durationMs, errorMsg, responseJSON); err != nil {
-		slog.Error("Failed to update execution status", "execution_id", executionID, "error", err)
+	if !req.DryRun {
+		update := store.ExecutionUpdate{
+			Status:        status,
+			DurationMs:    &durationMs,
+			ErrorMessage:  errorMsg,
+			ResponseJSON:  responseJSON,
+			ResponseBytes: respBytes,
+			CostUSD:       e.cost(executionID, duration),
+		}
+		if err := e.db.UpdateExecution(ctx, executionID, update); err != nil {
+			slog.Error("Failed to update execution status", "execution_id", executionID, "error", err)
+		}
+		if len(runtimeReq.Tags) > 0 {
+			if err := e.db.SetExecutionTags(ctx, executionID, runtimeReq.Tags); err != nil {
+				slog.Error("Failed to save execution tags", "execution_id", executionID, "error", err)
+			}
+		}
+		if len(spans) > 0 {
+			if err := e.db.SetExecutionSpans(ctx, executionID, spans); err != nil {
+				slog.Error("Failed to save execution spans", "execution_id", executionID, "error", err)
+			}
+		}
 	}
 
 	// Log error if execution failed
 	if runErr != nil {
-		e.logger.Error(req.FunctionID, runErr.Error())
+		if !req.DryRun {
+			e.logger.Error(req.FunctionID, runErr.Error())
+		}
 		slog.Error("Function execution failed",
 			"execution_id", executionID,
 			"function_id", req.FunctionID,
@@ -178,6 +317,7 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 		Duration:          duration,
 		Status:            status,
 		Error:             runErr,
+		OutputErrors:      outputErrors,
 	}
 
 	if runtimeResult != nil {
@@ -187,6 +327,105 @@ func (e *DefaultEngine) Execute(ctx context.Context, req ExecutionRequest) (*Exe
 	return result, nil
 }
 
+// checkPolicy asks the server's policy whether req may run. A policy that
+// fails denies the execution.
+func (e *DefaultEngine) checkPolicy(ctx context.Context, req ExecutionRequest) error {
+	if e.policy == nil {
+		return nil
+	}
+
+	err := e.policy.Check(ctx, PolicyRequest{
+		FunctionID: req.FunctionID,
+		Trigger:    req.Trigger,
+		Event:      req.Event,
+		ClientIP:   req.ClientIP,
+	})
+	if err == nil {
+		return nil
+	}
+
+	var denied *PolicyDeniedError
+	if errors.As(err, &denied) {
+		return denied
+	}
+	slog.Error("Policy check failed", "function_id", req.FunctionID, "error", err)
+	return &PolicyError{Err: err}
+}
+
+// cacheKey returns the key the request's response is cached under, and false
+// when it is not cached: the function has no cache TTL, or the execution
+// differs from a plain request (dry runs, sandboxes, env overrides, traces,
+// WebSockets and non-HTTP triggers).
+func (e *DefaultEngine) cacheKey(fn store.Function, versionID string, req ExecutionRequest) (string, bool) {
+	if e.cache == nil || fn.CacheTTL == nil || *fn.CacheTTL <= 0 {
+		return "", false
+	}
+	if req.DryRun || req.Sandbox != nil || req.EnvOverrides != nil || req.Trace ||
+		req.WebSocket != nil || req.Trigger != store.ExecutionTriggerHTTP {
+		return "", false
+	}
+	return cacheKey(versionID, req.Event, fn.CacheVaryHeaders)
+}
+
+// checkInput validates the body of an HTTP event against the function's
+// input schema. POST, PUT and PATCH requests are always checked, other
+// methods only when they carry a body.
+func (e *DefaultEngine) checkInput(fn store.Function, event events.Event) error {
+	httpEvent, ok := event.(events.HTTPEvent)
+	if !ok || len(fn.InputSchema) == 0 {
+		return nil
+	}
+	switch httpEvent.Method {
+	case "POST", "PUT", "PATCH":
+	default:
+		if httpEvent.Body == "" {
+			return nil
+		}
+	}
+
+	sch, err := e.schemas.Get(fn.InputSchema)
+	if err != nil {
+		return fmt.Errorf("invalid input schema for function %s: %w", fn.ID, err)
+	}
+	if errs := sch.Validate([]byte(httpEvent.Body)); len(errs) > 0 {
+		return &InvalidInputError{Errors: errs}
+	}
+	return nil
+}
+
+// checkOutput validates the body of an HTTP response against the function's
+// output schema and returns the errors, nil when it matches or there is no
+// schema. Base64 bodies are decoded first.
+func (e *DefaultEngine) checkOutput(fn store.Function, resp *events.HTTPResponse) map[string]string {
+	if resp == nil || len(fn.OutputSchema) == 0 {
+		return nil
+	}
+
+	sch, err := e.schemas.Get(fn.OutputSchema)
+	if err != nil {
+		slog.Error("Invalid output schema", "function_id", fn.ID, "error", err)
+		return nil
+	}
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			return map[string]string{"body": "body is not valid base64"}
+		}
+		body = decoded
+	}
+	return sch.Validate(body)
+}
+
+// formatSchemaErrors joins schema errors into one line, ordered by location
+func formatSchemaErrors(errs map[string]string) string {
+	parts := make([]string, 0, len(errs))
+	for _, location := range slices.Sorted(maps.Keys(errs)) {
+		parts = append(parts, location+": "+errs[location])
+	}
+	return strings.Join(parts, "; ")
+}
+
 // serializeEvent masks sensitive data and serializes the event to JSON.
 func (e *DefaultEngine) serializeEvent(event events.Event) (string, error) {
 	switch ev := event.(type) {
@@ -228,3 +467,72 @@ func serializeHTTPResponse(resp *events.HTTPResponse) string {
 	}
 	return string(jsonBytes)
 }
+
+// cost prices the execution with the engine's cost rates, or returns nil when
+// costs are not tracked
+func (e *DefaultEngine) cost(executionID string, duration time.Duration) *float64 {
+	if e.costs == nil {
+		return nil
+	}
+	var aiRequests []store.AIRequest
+	if e.aiTracker != nil {
+		aiRequests = e.aiTracker.Requests(executionID)
+	}
+	var emailRequests []store.EmailRequest
+	if e.emailTracker != nil {
+		emailRequests = e.emailTracker.Requests(executionID)
+	}
+	cost := e.costs.Cost(duration, aiRequests, emailRequests)
+	return &cost
+}
+
+// finishSpans ends the spans the function never finished at the end of the
+// run, which lasted runDuration
+func finishSpans(spans []store.Span, runDuration time.Duration) []store.Span {
+	runMs := float64(runDuration.Microseconds()) / 1000
+	for i, span := range spans {
+		if span.Unfinished {
+			spans[i].DurationMs = max(runMs-span.StartMs, 0)
+		}
+	}
+	return spans
+}
+
+// requestBytes returns the size of the event's request body, or nil for
+// events without one
+func requestBytes(event events.Event) *int64 {
+	ev, ok := event.(events.HTTPEvent)
+	if !ok {
+		return nil
+	}
+	size := int64(len(ev.Body))
+	return &size
+}
+
+// responseBytes returns the size of the response body as sent to the client,
+// decoding base64 bodies
+func responseBytes(resp *events.HTTPResponse) *int64 {
+	size := int64(len(resp.Body))
+	if resp.IsBase64Encoded {
+		if decoded, err := base64.StdEncoding.DecodeString(resp.Body); err == nil {
+			size = int64(len(decoded))
+		}
+	}
+	return &size
+}
+
+// derefInt returns the int i points to, or 0 when i is nil
+func derefInt(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+// derefString returns the string s points to, or "" when s is nil
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -2,10 +2,19 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"maps"
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/inflight"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	"github.com/dimiro1/lunar/internal/services/kv"
 	"github.com/dimiro1/lunar/internal/services/logger"
 	"github.com/dimiro1/lunar/internal/store"
 )
@@ -14,9 +23,17 @@ import (
 type mockRuntime struct {
 	result *RuntimeResult
 	err    error
+	tags   map[string]string // Tags set during the run
+	calls  int
+	during func() // Called while the run is in progress
 }
 
 func (m *mockRuntime) Execute(ctx context.Context, req RuntimeRequest) (*RuntimeResult, error) {
+	m.calls++
+	if m.during != nil {
+		m.during()
+	}
+	maps.Copy(req.Tags, m.tags)
 	return m.result, m.err
 }
 
@@ -29,7 +46,7 @@ func TestEngine_Execute_Success(t *testing.T) {
 		ID:   "test-func",
 		Name: "Test Function",
 	})
-	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil)
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
 
 	runtime := &mockRuntime{
 		result: &RuntimeResult{
@@ -78,6 +95,445 @@ func TestEngine_Execute_Success(t *testing.T) {
 	}
 }
 
+func TestEngine_Execute_SavesTags(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+
+	// Tags are kept when the run fails after setting them
+	eng := New(Config{
+		DB:          db,
+		Runtime:     &mockRuntime{err: errors.New("boom"), tags: map[string]string{"customer": "acme"}},
+		Logger:      logger.NewMemoryLogger(),
+		IDGenerator: func() string { return "exec-123" },
+	})
+
+	if _, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exec, err := db.GetExecution(ctx, "exec-123")
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if exec.Tags["customer"] != "acme" {
+		t.Errorf("expected customer tag, got %v", exec.Tags)
+	}
+}
+
+func TestEngine_Execute_RecordsPayloadSizes(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+
+	// Base64 bodies are counted as the bytes sent to the client
+	eng := New(Config{
+		DB: db,
+		Runtime: &mockRuntime{result: &RuntimeResult{
+			Response: &events.HTTPResponse{StatusCode: 200, Body: "aGVsbG8=", IsBase64Encoded: true},
+		}},
+		Logger:      logger.NewMemoryLogger(),
+		IDGenerator: func() string { return "exec-123" },
+	})
+
+	event := events.HTTPEvent{Method: "POST", Body: `{"name":"Ana"}`}
+	if _, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: event}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exec, err := db.GetExecution(ctx, "exec-123")
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if exec.RequestBytes == nil || *exec.RequestBytes != 14 {
+		t.Errorf("RequestBytes = %v, want 14", exec.RequestBytes)
+	}
+	if exec.ResponseBytes == nil || *exec.ResponseBytes != 5 {
+		t.Errorf("ResponseBytes = %v, want 5", exec.ResponseBytes)
+	}
+}
+
+func TestEngine_Execute_RecordsCost(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+
+	// One million input tokens at $2 plus one sent and one failed email
+	tokens := 1_000_000
+	aiTracker := ai.NewMemoryTracker()
+	aiTracker.Track("exec-123", ai.TrackRequest{Provider: "openai", Model: "gpt-test", Status: store.AIRequestStatusSuccess, InputTokens: &tokens})
+	emailTracker := email.NewMemoryTracker()
+	emailTracker.Track("exec-123", email.TrackRequest{Status: store.EmailRequestStatusSuccess})
+	emailTracker.Track("exec-123", email.TrackRequest{Status: store.EmailRequestStatusError})
+
+	eng := New(Config{
+		DB:           db,
+		Runtime:      &mockRuntime{result: &RuntimeResult{Response: &events.HTTPResponse{StatusCode: 200}}},
+		Logger:       logger.NewMemoryLogger(),
+		AITracker:    aiTracker,
+		EmailTracker: emailTracker,
+		IDGenerator:  func() string { return "exec-123" },
+		Costs: &CostRates{
+			PerEmail: 0.5,
+			AIPrices: ai.PriceTable{"openai/gpt-test": {Input: 2}},
+		},
+	})
+
+	if _, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exec, err := db.GetExecution(ctx, "exec-123")
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if exec.CostUSD == nil || *exec.CostUSD != 2.5 {
+		t.Errorf("CostUSD = %v, want 2.5", exec.CostUSD)
+	}
+}
+
+func TestEngine_Execute_ResponseCache(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+	ttl := 60
+	vary := []string{"Accept-Language"}
+	_ = db.UpdateFunction(ctx, fn.ID, store.UpdateFunctionRequest{CacheTTL: &ttl, CacheVaryHeaders: &vary})
+
+	runtime := &mockRuntime{result: &RuntimeResult{}}
+	var n int
+	eng := New(Config{
+		DB:          db,
+		Runtime:     runtime,
+		Logger:      logger.NewMemoryLogger(),
+		KVStore:     kv.NewMemoryStore(),
+		IDGenerator: func() string { n++; return "exec-" + strconv.Itoa(n) },
+	})
+	now := time.Unix(1700000000, 0)
+	eng.cache.now = func() time.Time { return now }
+
+	execute := func(query, language, cacheControl string) *ExecutionResult {
+		t.Helper()
+		// Each run returns a fresh response, as the Lua runtime does
+		runtime.result.Response = &events.HTTPResponse{StatusCode: 200, Body: "hello"}
+		event := events.HTTPEvent{
+			Method:  "GET",
+			Path:    "/fn/test-func",
+			Query:   map[string]string{"q": query},
+			Headers: map[string]string{"Accept-Language": language, "Cache-Control": cacheControl},
+		}
+		result, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: event})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result
+	}
+
+	first := execute("1", "en", "")
+	if first.Cached || first.Response.Headers[HeaderCache] != "MISS" || first.Response.Headers["Cache-Control"] != "max-age=60" {
+		t.Errorf("expected a cache miss, got cached=%v headers=%v", first.Cached, first.Response.Headers)
+	}
+
+	now = now.Add(10 * time.Second)
+	hit := execute("1", "en", "")
+	if !hit.Cached || runtime.calls != 1 {
+		t.Fatalf("expected a cache hit without running the function, got cached=%v calls=%d", hit.Cached, runtime.calls)
+	}
+	if hit.ExecutionID != first.ExecutionID || hit.Response.Body != "hello" {
+		t.Errorf("expected the cached response of %s, got %s: %+v", first.ExecutionID, hit.ExecutionID, hit.Response)
+	}
+	if hit.Response.Headers[HeaderCache] != "HIT" || hit.Response.Headers["Age"] != "10" || hit.Response.Headers["Cache-Control"] != "max-age=50" {
+		t.Errorf("unexpected cache headers: %v", hit.Response.Headers)
+	}
+
+	// Other queries and vary header values are cached separately, and
+	// no-cache requests run the function
+	for _, result := range []*ExecutionResult{execute("2", "en", ""), execute("1", "pt", ""), execute("1", "en", "no-cache")} {
+		if result.Cached {
+			t.Errorf("expected a cache miss, got a hit of %s", result.ExecutionID)
+		}
+	}
+	if runtime.calls != 4 {
+		t.Errorf("expected 4 runs, got %d", runtime.calls)
+	}
+
+	// Expired entries are replaced by the next run
+	now = now.Add(time.Minute)
+	if execute("1", "en", "").Cached {
+		t.Error("expected an expired entry to be ignored")
+	}
+
+	if err := eng.cache.Purge(fn.ID); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if execute("1", "en", "").Cached {
+		t.Error("expected a purged entry to be ignored")
+	}
+	if runtime.calls != 6 {
+		t.Errorf("expected 6 runs, got %d", runtime.calls)
+	}
+
+	// Dry runs neither read nor fill the cache
+	runtime.result.Response = &events.HTTPResponse{StatusCode: 200}
+	result, _ := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, DryRun: true, Event: events.HTTPEvent{
+		Method: "GET", Path: "/fn/test-func", Query: map[string]string{"q": "1"}, Headers: map[string]string{"Accept-Language": "en"},
+	}})
+	if result.Cached || result.Response.Headers[HeaderCache] != "" {
+		t.Errorf("expected a dry run to bypass the cache, got %v", result.Response.Headers)
+	}
+}
+
+func TestResponseCache_MaxEntries(t *testing.T) {
+	kvStore := kv.NewMemoryStore()
+	cache := NewResponseCache(kvStore)
+	cache.maxEntries = 10
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	set := func(key string, ttl int) {
+		t.Helper()
+		if err := cache.set("fn", key, "exec-"+key, &events.HTTPResponse{StatusCode: 200}, ttl); err != nil {
+			t.Fatalf("set failed: %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+	count := func() int {
+		t.Helper()
+		keys, err := kvStore.Keys(cacheNamespacePrefix + "fn")
+		if err != nil {
+			t.Fatalf("Keys failed: %v", err)
+		}
+		return len(keys)
+	}
+
+	// One entry expires before the cache fills up
+	set("short", 1)
+	for i := range 9 {
+		set("k"+strconv.Itoa(i), 3600)
+	}
+
+	// Replacing an entry does not evict
+	set("k8", 3600)
+	if count() != 10 {
+		t.Fatalf("expected a replaced entry to keep 10 entries, got %d", count())
+	}
+
+	// A new key evicts the expired entries first
+	set("new-1", 3600)
+	if count() != 10 {
+		t.Errorf("expected 9 kept entries and the new one, got %d", count())
+	}
+	if keys, _ := kvStore.Keys(cacheNamespacePrefix + "fn"); slices.Contains(keys, "short") {
+		t.Error("expected the expired entry to be evicted")
+	}
+
+	// Then the oldest ones, down to 90% of the limit
+	set("new-2", 3600)
+	if count() != 10 {
+		t.Errorf("expected 9 kept entries and the new one, got %d", count())
+	}
+	for key, want := range map[string]bool{"k0": false, "k1": true, "new-1": true, "new-2": true} {
+		if _, _, ok := cache.get("fn", key); ok != want {
+			t.Errorf("expected %s kept=%v, got %v", key, want, ok)
+		}
+	}
+}
+
+func TestEngine_Execute_DryRun(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+
+	log := logger.NewMemoryLogger()
+	eng := New(Config{
+		DB:          db,
+		Runtime:     &mockRuntime{err: errors.New("boom"), tags: map[string]string{"customer": "acme"}},
+		Logger:      log,
+		IDGenerator: func() string { return "exec-123" },
+	})
+
+	result, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}, DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != store.ExecutionStatusError || result.Error == nil {
+		t.Errorf("expected the failed run to be reported, got status %v and error %v", result.Status, result.Error)
+	}
+
+	if _, err := db.GetExecution(ctx, "exec-123"); err == nil {
+		t.Error("expected no execution record for a dry run")
+	}
+	if entries := log.Entries(fn.ID); len(entries) != 0 {
+		t.Errorf("expected no logs for a dry run, got %v", entries)
+	}
+}
+
+// policyFunc adapts a function to the Policy interface
+type policyFunc func(ctx context.Context, req PolicyRequest) error
+
+func (f policyFunc) Check(ctx context.Context, req PolicyRequest) error {
+	return f(ctx, req)
+}
+
+func TestEngine_Execute_Policy(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+
+	// Only requests from 10.0.0.1 are allowed, and cron runs fail the policy
+	policy := policyFunc(func(ctx context.Context, req PolicyRequest) error {
+		if req.Trigger == store.ExecutionTriggerCron {
+			return errors.New("boom")
+		}
+		if req.ClientIP != "10.0.0.1" || req.FunctionID != fn.ID {
+			return &PolicyDeniedError{StatusCode: 401, Message: "not from the office"}
+		}
+		return nil
+	})
+	runtime := &mockRuntime{result: &RuntimeResult{Response: &events.HTTPResponse{StatusCode: 200}}}
+	eng := New(Config{
+		DB:          db,
+		Runtime:     runtime,
+		Logger:      logger.NewMemoryLogger(),
+		IDGenerator: func() string { return "exec-123" },
+		Policy:      policy,
+	})
+
+	_, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}, ClientIP: "192.0.2.1"})
+	var denied *PolicyDeniedError
+	if !errors.As(err, &denied) || denied.StatusCode != 401 || denied.Message != "not from the office" {
+		t.Fatalf("expected the policy's denial, got %v", err)
+	}
+	if _, err := db.GetExecution(ctx, "exec-123"); err == nil {
+		t.Error("expected no execution record for a denied execution")
+	}
+
+	_, err = eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}, Trigger: store.ExecutionTriggerCron})
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected a failing policy to deny the execution, got %v", err)
+	}
+
+	result, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}, ClientIP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != store.ExecutionStatusSuccess {
+		t.Errorf("Status = %v, want %v", result.Status, store.ExecutionStatusSuccess)
+	}
+}
+
+func TestEngine_Execute_InputSchema(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+	schema := json.RawMessage(`{"type": "object", "required": ["name"]}`)
+	_ = db.UpdateFunction(ctx, fn.ID, store.UpdateFunctionRequest{InputSchema: &schema})
+
+	runtime := &mockRuntime{result: &RuntimeResult{Response: &events.HTTPResponse{StatusCode: 200}}}
+	eng := New(Config{
+		DB:          db,
+		Runtime:     runtime,
+		Logger:      logger.NewMemoryLogger(),
+		IDGenerator: func() string { return "exec-123" },
+	})
+
+	_, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "POST", Body: `{"nickname": "ana"}`}})
+	var invalid *InvalidInputError
+	if !errors.As(err, &invalid) || invalid.Errors["body"] != "missing property 'name'" {
+		t.Fatalf("expected the schema's errors, got %v", err)
+	}
+	if _, err := db.GetExecution(ctx, "exec-123"); err == nil {
+		t.Error("expected no execution record for an invalid body")
+	}
+
+	// GET requests without a body and bodies that match run the function
+	for _, event := range []events.HTTPEvent{
+		{Method: "GET"},
+		{Method: "POST", Body: `{"name": "ana"}`},
+	} {
+		result, err := eng.Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: event})
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", event.Method, err)
+		}
+		if result.Status != store.ExecutionStatusSuccess {
+			t.Errorf("Status = %v, want %v", result.Status, store.ExecutionStatusSuccess)
+		}
+	}
+}
+
+func TestEngine_Execute_OutputSchema(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{ID: "test-func", Name: "Test Function"})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+	schema := json.RawMessage(`{"type": "object", "required": ["id"]}`)
+	_ = db.UpdateFunction(ctx, fn.ID, store.UpdateFunctionRequest{OutputSchema: &schema})
+
+	runtime := &mockRuntime{result: &RuntimeResult{Response: &events.HTTPResponse{StatusCode: 200, Body: `{"name": "ana"}`}}}
+	log := logger.NewMemoryLogger()
+	newEngine := func(devMode bool) Engine {
+		return New(Config{
+			DB:          db,
+			Runtime:     runtime,
+			Logger:      log,
+			IDGenerator: func() string { return "exec-123" },
+			DevMode:     devMode,
+		})
+	}
+	want := map[string]string{"body": "missing property 'id'"}
+
+	// Production skips the check unless the request asks for it
+	result, err := newEngine(false).Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.OutputErrors != nil {
+		t.Errorf("expected no output check outside dev mode, got %v", result.OutputErrors)
+	}
+	result, err = newEngine(false).Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}, ValidateOutput: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !maps.Equal(result.OutputErrors, want) {
+		t.Errorf("OutputErrors = %v, want %v", result.OutputErrors, want)
+	}
+	if entries := log.Entries("exec-123"); len(entries) != 0 {
+		t.Errorf("expected no warning for a dry run, got %v", entries)
+	}
+
+	// Dev mode checks every response and warns in the execution's logs
+	result, err = newEngine(true).Execute(ctx, ExecutionRequest{FunctionID: fn.ID, Event: events.HTTPEvent{Method: "GET"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !maps.Equal(result.OutputErrors, want) {
+		t.Errorf("OutputErrors = %v, want %v", result.OutputErrors, want)
+	}
+	if result.Status != store.ExecutionStatusSuccess {
+		t.Errorf("Status = %v, want %v", result.Status, store.ExecutionStatusSuccess)
+	}
+	entries := log.Entries("exec-123")
+	if len(entries) != 1 || entries[0].Level != logger.Warn || entries[0].Message != "Response does not match the output schema: body: missing property 'id'" {
+		t.Errorf("expected a warning in the execution logs, got %v", entries)
+	}
+}
+
 func TestEngine_Execute_FunctionNotFound(t *testing.T) {
 	db := store.NewMemoryDB()
 
@@ -98,6 +554,25 @@ func TestEngine_Execute_FunctionNotFound(t *testing.T) {
 	}
 }
 
+func TestEngine_Execute_InvalidTrigger(t *testing.T) {
+	eng := New(Config{
+		DB:          store.NewMemoryDB(),
+		Runtime:     &mockRuntime{},
+		Logger:      logger.NewMemoryLogger(),
+		IDGenerator: func() string { return "exec-123" },
+	})
+
+	_, err := eng.Execute(context.Background(), ExecutionRequest{
+		FunctionID: "test-func",
+		Trigger:    "webhook",
+	})
+
+	var invalidTrigger *InvalidTriggerError
+	if !errors.As(err, &invalidTrigger) {
+		t.Errorf("expected InvalidTriggerError, got %T: %v", err, err)
+	}
+}
+
 func TestEngine_Execute_FunctionDisabled(t *testing.T) {
 	db := store.NewMemoryDB()
 	ctx := context.Background()
@@ -160,7 +635,7 @@ func TestEngine_Execute_RuntimeError(t *testing.T) {
 		ID:   "test-func",
 		Name: "Test Function",
 	})
-	_, _ = db.CreateVersion(ctx, fn.ID, "invalid code", nil)
+	_, _ = db.CreateVersion(ctx, fn.ID, "invalid code", nil, nil)
 
 	runtime := &mockRuntime{
 		err: errors.New("runtime error: syntax error"),
@@ -204,7 +679,7 @@ func TestEngine_Execute_ErrorStatusCode(t *testing.T) {
 		ID:   "test-func",
 		Name: "Test Function",
 	})
-	_, _ = db.CreateVersion(ctx, fn.ID, "return {statusCode=500}", nil)
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {statusCode=500}", nil, nil)
 
 	runtime := &mockRuntime{
 		result: &RuntimeResult{
@@ -239,3 +714,43 @@ func TestEngine_Execute_ErrorStatusCode(t *testing.T) {
 		t.Errorf("Status = %v, want %v", result.Status, store.ExecutionStatusError)
 	}
 }
+
+func TestEngine_Execute_InFlight(t *testing.T) {
+	db := store.NewMemoryDB()
+	ctx := context.Background()
+
+	fn, _ := db.CreateFunction(ctx, store.Function{
+		ID:   "test-func",
+		Name: "Test Function",
+	})
+	_, _ = db.CreateVersion(ctx, fn.ID, "return {}", nil, nil)
+
+	registry := inflight.New()
+	var running bool
+	runtime := &mockRuntime{
+		result: &RuntimeResult{Response: &events.HTTPResponse{StatusCode: 200}},
+		during: func() { running = registry.Running(fn.ID) },
+	}
+
+	eng := New(Config{
+		DB:          db,
+		Runtime:     runtime,
+		Logger:      logger.NewMemoryLogger(),
+		IDGenerator: func() string { return "exec-123" },
+		InFlight:    registry,
+	})
+
+	if _, err := eng.Execute(ctx, ExecutionRequest{
+		FunctionID: fn.ID,
+		Event:      events.HTTPEvent{Method: "GET", Path: "/fn/test-func"},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !running {
+		t.Error("expected the execution to be in flight while it ran")
+	}
+	if registry.Running(fn.ID) {
+		t.Error("expected the execution to leave the registry once finished")
+	}
+}
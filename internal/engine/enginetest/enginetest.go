@@ -0,0 +1,141 @@
+// Package enginetest provides an in-memory engine and a fake runtime for
+// testing code built on the engine package, in the spirit of net/http/httptest.
+//
+// The engine runs against memory stores and a FakeRuntime, so tests can create
+// functions, execute them and inspect the execution records and logs without
+// a Lua runtime or a database:
+//
+//	env := enginetest.New()
+//	env.Runtime.Result = &engine.RuntimeResult{Response: &events.HTTPResponse{StatusCode: 201}}
+//	fn, _ := env.CreateFunction(ctx, store.Function{Name: "hello"}, "function handler() end")
+//	result, err := env.Engine.Execute(ctx, engine.ExecutionRequest{FunctionID: fn.ID, ...})
+//
+// The package is for tests within this module only and is not a public test
+// harness: its API is made of the internal engine, store and events types,
+// which other modules cannot name.
+package enginetest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	"github.com/dimiro1/lunar/internal/services/env"
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+	"github.com/dimiro1/lunar/internal/services/kv"
+	"github.com/dimiro1/lunar/internal/services/logger"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+// FakeRuntime is an engine.Runtime that returns a canned result instead of
+// running the function code. It is safe for concurrent use.
+type FakeRuntime struct {
+	// Result is returned by Execute, a 200 response with an empty body when nil
+	Result *engine.RuntimeResult
+	// Err is returned by Execute when set
+	Err error
+	// Tags are added to the execution as if the function had set them
+	Tags map[string]string
+	// ExecuteFunc replaces Result and Err when set
+	ExecuteFunc func(ctx context.Context, req engine.RuntimeRequest) (*engine.RuntimeResult, error)
+
+	mu       sync.Mutex
+	requests []engine.RuntimeRequest
+}
+
+// Execute records req and returns the configured result
+func (f *FakeRuntime) Execute(ctx context.Context, req engine.RuntimeRequest) (*engine.RuntimeResult, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	result, err, tags, execute := f.Result, f.Err, f.Tags, f.ExecuteFunc
+	f.mu.Unlock()
+
+	if req.Tags != nil {
+		maps.Copy(req.Tags, tags)
+	}
+	if execute != nil {
+		return execute(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &engine.RuntimeResult{Response: &events.HTTPResponse{StatusCode: 200}}
+	}
+	return result, nil
+}
+
+// Requests returns the requests the runtime received, oldest first
+func (f *FakeRuntime) Requests() []engine.RuntimeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]engine.RuntimeRequest(nil), f.requests...)
+}
+
+// Env is an engine wired with a FakeRuntime and memory stores. The stores
+// are exported so tests can seed and inspect them.
+type Env struct {
+	Engine       engine.Engine
+	Runtime      *FakeRuntime
+	DB           *store.MemoryDB
+	Logger       *logger.MemoryLogger
+	KV           *kv.MemoryStore
+	EnvStore     *env.MemoryStore
+	HTTPClient   *internalhttp.FakeClient
+	AITracker    *ai.MemoryTracker
+	EmailTracker *email.MemoryTracker
+}
+
+// New returns an Env whose engine numbers execution IDs exec-1, exec-2...
+func New() *Env {
+	var executions atomic.Int64
+	e := &Env{
+		Runtime:      &FakeRuntime{},
+		DB:           store.NewMemoryDB(),
+		Logger:       logger.NewMemoryLogger(),
+		KV:           kv.NewMemoryStore(),
+		EnvStore:     env.NewMemoryStore(),
+		HTTPClient:   internalhttp.NewFakeClient(),
+		AITracker:    ai.NewMemoryTracker(),
+		EmailTracker: email.NewMemoryTracker(),
+	}
+	e.Engine = engine.New(engine.Config{
+		DB:           e.DB,
+		Runtime:      e.Runtime,
+		Logger:       e.Logger,
+		KVStore:      e.KV,
+		EnvStore:     e.EnvStore,
+		HTTPClient:   e.HTTPClient,
+		AITracker:    e.AITracker,
+		EmailTracker: e.EmailTracker,
+		IDGenerator: func() string {
+			return fmt.Sprintf("exec-%d", executions.Add(1))
+		},
+	})
+	return e
+}
+
+// CreateFunction stores fn with code as its active version. An empty ID is
+// derived from the function name.
+func (e *Env) CreateFunction(ctx context.Context, fn store.Function, code string) (store.Function, error) {
+	if fn.ID == "" {
+		fn.ID = "fn-" + fn.Name
+	}
+	if fn.EnvVars == nil {
+		fn.EnvVars = make(map[string]string)
+	}
+	created, err := e.DB.CreateFunction(ctx, fn)
+	if err != nil {
+		return store.Function{}, err
+	}
+	if _, err := e.DB.CreateVersion(ctx, created.ID, code, nil, nil); err != nil {
+		return store.Function{}, err
+	}
+	return created, nil
+}
@@ -0,0 +1,70 @@
+package enginetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/engine/enginetest"
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+func TestEnv_Execute(t *testing.T) {
+	ctx := context.Background()
+	env := enginetest.New()
+	env.Runtime.Result = &engine.RuntimeResult{Response: &events.HTTPResponse{StatusCode: 201, Body: "created"}}
+	env.Runtime.Tags = map[string]string{"tenant": "acme"}
+
+	fn, err := env.CreateFunction(ctx, store.Function{Name: "hello"}, "function handler() end")
+	if err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	result, err := env.Engine.Execute(ctx, engine.ExecutionRequest{
+		FunctionID: fn.ID,
+		Event:      events.HTTPEvent{Method: "POST", Path: "/"},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.ExecutionID != "exec-1" || result.Response.StatusCode != 201 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	requests := env.Runtime.Requests()
+	if len(requests) != 1 || requests[0].Code != "function handler() end" {
+		t.Fatalf("expected the runtime to receive the active version, got %+v", requests)
+	}
+
+	execution, err := env.DB.GetExecution(ctx, "exec-1")
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if execution.Status != store.ExecutionStatusSuccess || execution.Tags["tenant"] != "acme" {
+		t.Errorf("unexpected execution record: %+v", execution)
+	}
+}
+
+func TestEnv_Execute_RuntimeError(t *testing.T) {
+	ctx := context.Background()
+	env := enginetest.New()
+	env.Runtime.Err = errors.New("boom")
+
+	fn, err := env.CreateFunction(ctx, store.Function{Name: "broken"}, "")
+	if err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	result, err := env.Engine.Execute(ctx, engine.ExecutionRequest{
+		FunctionID: fn.ID,
+		Event:      events.HTTPEvent{Method: "GET", Path: "/"},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Status != store.ExecutionStatusError || result.Error == nil || result.Error.Error() != "boom" {
+		t.Errorf("expected a failed execution, got %+v", result)
+	}
+}
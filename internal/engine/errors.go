@@ -1,6 +1,11 @@
 package engine
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/store"
+)
 
 // FunctionNotFoundError indicates the requested function does not exist.
 type FunctionNotFoundError struct {
@@ -29,6 +34,63 @@ func (e *NoActiveVersionError) Error() string {
 	return fmt.Sprintf("no active version found for function: %s", e.FunctionID)
 }
 
+// InvalidTriggerError indicates the request names an unknown trigger.
+type InvalidTriggerError struct {
+	Trigger store.ExecutionTrigger
+}
+
+func (e *InvalidTriggerError) Error() string {
+	return fmt.Sprintf("invalid execution trigger: %q", e.Trigger)
+}
+
+// PolicyDeniedError indicates the server's policy denied the execution.
+// StatusCode and Message are what the client is answered with.
+type PolicyDeniedError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *PolicyDeniedError) Error() string {
+	return fmt.Sprintf("denied by policy: %s", e.Message)
+}
+
+// InvalidInputError indicates the request body does not match the function's
+// input schema. Errors are keyed by location in the body, such as "body/name".
+type InvalidInputError struct {
+	Errors map[string]string
+}
+
+func (e *InvalidInputError) Error() string {
+	return fmt.Sprintf("request body does not match the input schema (%d errors)", len(e.Errors))
+}
+
+// PolicyError indicates the server's policy failed, which denies the execution.
+type PolicyError struct {
+	Err error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy error: %v", e.Err)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError indicates the function ran past its execution timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error // Error the runtime stopped the function with
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("function timed out after %s", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
 // ExecutionRecordError indicates a failure to create/update execution record.
 type ExecutionRecordError struct {
 	Err error
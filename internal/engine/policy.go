@@ -0,0 +1,28 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+// Policy decides whether an execution may run. The engine checks it before
+// every execution of every function, whatever the trigger, so operators can
+// enforce access rules without changing the functions.
+type Policy interface {
+	// Check returns nil to allow the execution or a *PolicyDeniedError to
+	// deny it. Any other error also denies it, as a failure of the policy.
+	Check(ctx context.Context, req PolicyRequest) error
+}
+
+// PolicyRequest is what a Policy knows about an execution
+type PolicyRequest struct {
+	FunctionID string
+	Trigger    store.ExecutionTrigger
+	Event      events.Event
+
+	// ClientIP is the address of the client that triggered the execution,
+	// empty when there is none
+	ClientIP string
+}
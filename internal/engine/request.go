@@ -13,9 +13,57 @@ type ExecutionRequest struct {
 	// Event is the trigger event (HTTP request, cron trigger, etc.)
 	Event events.Event
 
-	// Trigger indicates how the execution was triggered (HTTP, cron, etc.)
+	// Trigger indicates how the execution was triggered (HTTP, cron, etc.).
+	// Empty means HTTP; unknown triggers are rejected.
 	Trigger store.ExecutionTrigger
 
+	// ScheduledAt is the Unix time a cron execution was scheduled for, which
+	// precedes the actual start when the schedule has jitter. Nil otherwise.
+	ScheduledAt *int64
+
 	// BaseURL is the base URL of the server for generating function URLs
 	BaseURL string
+
+	// WebSocket is the upgraded connection for WebSocket requests, nil otherwise
+	WebSocket events.WebSocket
+
+	// EventStream streams server-sent events to HTTP clients, nil when streaming is unavailable
+	EventStream events.EventStream
+
+	// EnvOverrides replaces the function's env vars for this execution only,
+	// e.g. to point a test or replay at a sandbox API. They are never persisted.
+	EnvOverrides map[string]string
+
+	// Trace logs every stdlib call with its arguments and timing
+	Trace bool
+
+	// Sandbox replaces the outbound http, ai and email clients with fakes that
+	// record the calls instead of making them. Nil runs with the real clients.
+	Sandbox *Sandbox
+
+	// Seed makes the random module produce the same values on every run with
+	// the same seed. Nil seeds it from a secure source.
+	Seed *int64
+
+	// FrozenTime pins time.now to this Unix time, for reproducible runs. Nil
+	// uses the real time.
+	FrozenTime *int64
+
+	// DryRun runs the function without recording it: no execution record is
+	// created and its logs and captured calls are discarded. The result's
+	// ExecutionID identifies nothing stored.
+	DryRun bool
+
+	// ValidateOutput checks the response against the function's output
+	// schema, as the engine does for every execution in dev mode. Mismatches
+	// are reported in the result's OutputErrors.
+	ValidateOutput bool
+
+	// ClientIP is the address of the client that triggered the execution, for
+	// the server's policy. Empty when there is none.
+	ClientIP string
+
+	// RequestID identifies the HTTP request that triggered the execution, for
+	// correlating it with the server's access log. Empty when there is none.
+	RequestID string
 }
@@ -24,6 +24,16 @@ type ExecutionResult struct {
 	// Status indicates whether execution succeeded or failed
 	Status store.ExecutionStatus
 
+	// Cached reports that the response was served from the function's
+	// response cache without running it. ExecutionID then identifies the
+	// execution that produced the cached response.
+	Cached bool
+
 	// Error contains the error if execution failed
 	Error error
+
+	// OutputErrors lists where the response does not match the function's
+	// output schema, keyed by location such as "body/name". Only set when
+	// the response was checked, in dev mode or with ValidateOutput.
+	OutputErrors map[string]string
 }
@@ -2,8 +2,12 @@ package engine
 
 import (
 	"context"
+	"time"
 
 	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	"github.com/dimiro1/lunar/internal/store"
 )
 
 // Runtime is the interface for language-specific code executors.
@@ -25,6 +29,74 @@ type RuntimeRequest struct {
 
 	// Event is the trigger event (HTTP request, cron trigger, etc.)
 	Event events.Event
+
+	// WebSocket is the upgraded connection for WebSocket requests, nil otherwise
+	WebSocket events.WebSocket
+
+	// EventStream streams server-sent events to HTTP clients, nil when streaming is unavailable
+	EventStream events.EventStream
+
+	// EnvOverrides are layered on top of the env store for this execution only
+	EnvOverrides map[string]string
+
+	// Trace logs every stdlib call with its arguments and timing
+	Trace bool
+
+	// CaptureHTTP records outbound HTTP requests, set from the function's capture_http setting
+	CaptureHTTP bool
+
+	// AIDefaults are the function's default AI provider and model
+	AIDefaults ai.Defaults
+
+	// EmailDefaults are the function's default email settings
+	EmailDefaults email.Defaults
+
+	// EmailAllowed restricts email senders, set from the function's email_allowed_from setting
+	EmailAllowed email.SenderAllowlist
+
+	// DisabledModules lists the stdlib modules the function may not use
+	DisabledModules []string
+
+	// Tags collects the tags the function sets on its execution; the runtime
+	// adds to it during the run
+	Tags map[string]string
+
+	// Spans collects the timing spans the function records; the runtime
+	// appends to it during the run, leaving unfinished spans marked as such
+	Spans *[]store.Span
+
+	// ReuseState keeps the runtime state warm between executions, set from
+	// the function's reuse_state setting
+	ReuseState bool
+
+	// MaxLogEntries overrides the runtime's log limit when set, from the
+	// function's max_log_entries setting
+	MaxLogEntries int
+
+	// MaxOutbound lowers the runtime's limit of outbound http, ai and
+	// email calls when set, from the function's max_outbound_calls setting
+	MaxOutbound int
+
+	// OutboundTime lowers the runtime's limit of the total time spent in
+	// outbound calls when set, from the function's outbound_time_budget setting
+	OutboundTime time.Duration
+
+	// Sandbox replaces the runtime's outbound clients for this execution when set
+	Sandbox *Sandbox
+
+	// Seed makes the runtime's random values deterministic when set
+	Seed *int64
+
+	// FrozenTime pins the runtime's current time to this Unix time when set
+	FrozenTime *int64
+
+	// Middleware names the function modules run around the handler, in
+	// order, from the function's middleware setting
+	Middleware []string
+
+	// DryRun keeps the execution's logs and captured calls in memory instead
+	// of the runtime's stores when set
+	DryRun bool
 }
 
 // RuntimeResult contains the output from executing function code.
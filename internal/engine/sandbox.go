@@ -0,0 +1,29 @@
+package engine
+
+import (
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	"github.com/dimiro1/lunar/internal/services/http"
+)
+
+// SandboxAIContent is the content of every AI response in a sandbox
+const SandboxAIContent = "This is a sandboxed AI response."
+
+// Sandbox replaces the outbound clients of an execution with fakes, so a
+// function can be tested without sending requests or emails and without
+// spending AI tokens. The fakes record what would have been sent.
+type Sandbox struct {
+	HTTP  *http.FakeClient
+	AI    *ai.FakeClient
+	Email *email.FakeClient
+}
+
+// NewSandbox creates a Sandbox that answers HTTP requests with an empty 200,
+// AI chats with SandboxAIContent and accepts every email
+func NewSandbox() *Sandbox {
+	return &Sandbox{
+		HTTP:  http.NewFakeClient(),
+		AI:    ai.NewFakeClient(SandboxAIContent),
+		Email: email.NewFakeClient(),
+	}
+}
@@ -1,13 +1,20 @@
 package events
 
-// HTTPEvent represents an incoming HTTP request
+// HTTPEvent represents an incoming HTTP request.
+// Headers and Query hold the first value of each name; HeadersAll and
+// QueryAll hold every value, in the order they were sent. RemoteIP and Scheme
+// describe the client, behind any trusted proxies.
 type HTTPEvent struct {
-	Method       string            `json:"method"`
-	Path         string            `json:"path"`
-	RelativePath string            `json:"relativePath"`
-	Headers      map[string]string `json:"headers"`
-	Body         string            `json:"body"`
-	Query        map[string]string `json:"query"`
+	Method       string              `json:"method"`
+	Path         string              `json:"path"`
+	RelativePath string              `json:"relativePath"`
+	Headers      map[string]string   `json:"headers"`
+	Body         string              `json:"body"`
+	Query        map[string]string   `json:"query"`
+	HeadersAll   map[string][]string `json:"headers_all,omitempty"`
+	QueryAll     map[string][]string `json:"query_all,omitempty"`
+	RemoteIP     string              `json:"remote_ip,omitempty"`
+	Scheme       string              `json:"scheme,omitempty"`
 }
 
 // Type returns the event type for HTTPEvent
@@ -15,10 +22,33 @@ func (h HTTPEvent) Type() EventType {
 	return EventTypeHTTP
 }
 
+// HeaderValues returns every value of the header name, falling back to its
+// single value for events built without HeadersAll
+func (h HTTPEvent) HeaderValues(name string) []string {
+	return values(h.HeadersAll, h.Headers, name)
+}
+
+// QueryValues returns every value of the query parameter name, falling back
+// to its single value for events built without QueryAll
+func (h HTTPEvent) QueryValues(name string) []string {
+	return values(h.QueryAll, h.Query, name)
+}
+
+func values(all map[string][]string, first map[string]string, name string) []string {
+	if list, ok := all[name]; ok {
+		return list
+	}
+	if value, ok := first[name]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
 // HTTPResponse represents the HTTP response from a Lua function handler
 type HTTPResponse struct {
 	StatusCode      int               `json:"statusCode"`
 	Headers         map[string]string `json:"headers"`
 	Body            string            `json:"body"`
 	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	ETag            string            `json:"etag,omitempty"`
 }
@@ -0,0 +1,12 @@
+package events
+
+// EventStream delivers server-sent events to the client while the function runs
+type EventStream interface {
+	// Send writes a single event and flushes it to the client.
+	// An empty event name sends an unnamed message.
+	Send(event, data string) error
+
+	// Started reports whether an event was sent, in which case the
+	// response is already committed as a text/event-stream
+	Started() bool
+}
@@ -0,0 +1,20 @@
+package events
+
+import "errors"
+
+// ErrWebSocketClosed is returned by WebSocket operations once the connection is closed
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// WebSocket is a message-oriented connection handed to functions that
+// handle an upgraded HTTP request
+type WebSocket interface {
+	// Send writes a text message to the client
+	Send(message string) error
+
+	// Receive blocks until the client sends a message.
+	// It returns ErrWebSocketClosed once the connection is closed.
+	Receive() (string, error)
+
+	// Close closes the connection. It is safe to call more than once.
+	Close() error
+}
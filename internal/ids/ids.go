@@ -0,0 +1,66 @@
+// Package ids generates the IDs of functions, executions and HTTP requests.
+//
+// A Generator is injected into the server so deployments can follow their own
+// ID conventions. The default, XID, produces the 20 character sortable IDs
+// Lunar has always used; UUIDv7 produces time-ordered UUIDs and Prefixed adds
+// the kind of the entity (fn_, exec_) in front of any generator's IDs.
+// Version IDs are derived from the function ID, so they share its scheme.
+package ids
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rs/xid"
+)
+
+// Kind identifies the entity an ID is generated for
+type Kind string
+
+const (
+	Function  Kind = "fn"
+	Execution Kind = "exec"
+	Request   Kind = "req"
+)
+
+// Generator returns a new unique ID for an entity of the given kind
+type Generator func(kind Kind) string
+
+// XID generates a globally unique sortable xid, regardless of kind
+func XID(Kind) string {
+	return xid.New().String()
+}
+
+// UUIDv7 generates a time-ordered UUID (RFC 9562), regardless of kind
+func UUIDv7(Kind) string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// Prefixed returns a Generator that prefixes the IDs of gen with their kind
+// and an underscore, e.g. fn_d3m2k1... or exec_d3m2k1...
+func Prefixed(gen Generator) Generator {
+	return func(kind Kind) string {
+		return string(kind) + "_" + gen(kind)
+	}
+}
+
+// Formats lists the names accepted by Parse
+var Formats = []string{"xid", "uuidv7"}
+
+// Parse returns the Generator for a format name, prefixed when prefix is set.
+// An empty format means xid.
+func Parse(format string, prefix bool) (Generator, error) {
+	var gen Generator
+	switch format {
+	case "", "xid":
+		gen = XID
+	case "uuidv7":
+		gen = UUIDv7
+	default:
+		return nil, fmt.Errorf("unknown ID format %q, must be one of: %v", format, Formats)
+	}
+	if prefix {
+		gen = Prefixed(gen)
+	}
+	return gen, nil
+}
@@ -0,0 +1,38 @@
+package ids
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		format  string
+		prefix  bool
+		kind    Kind
+		pattern string
+	}{
+		{"", false, Function, `^[0-9a-v]{20}$`},
+		{"xid", true, Execution, `^exec_[0-9a-v]{20}$`},
+		{"uuidv7", false, Execution, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"uuidv7", true, Function, `^fn_[0-9a-f]{8}-[0-9a-f]{4}-7`},
+	}
+
+	for _, tt := range tests {
+		gen, err := Parse(tt.format, tt.prefix)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.format, err)
+		}
+		id := gen(tt.kind)
+		if !regexp.MustCompile(tt.pattern).MatchString(id) {
+			t.Errorf("Parse(%q, %v) generated %q, want match for %s", tt.format, tt.prefix, id, tt.pattern)
+		}
+		if next := gen(tt.kind); next == id {
+			t.Errorf("Parse(%q) generated the same ID twice: %q", tt.format, id)
+		}
+	}
+
+	if _, err := Parse("snowflake", false); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,6 @@
+// Package inflight tracks the function executions in progress in this process.
+//
+// The engine marks every execution as in flight while its code runs, whatever
+// triggered it, so the cron scheduler can skip fires of functions with
+// cron_no_overlap while any execution of them is still running.
+package inflight
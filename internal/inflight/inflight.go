@@ -0,0 +1,49 @@
+package inflight
+
+import "sync"
+
+// Registry counts the executions in flight per function. It is safe for
+// concurrent use. A nil *Registry tracks nothing and reports none running.
+type Registry struct {
+	mu      sync.Mutex
+	running map[string]int // functionID -> executions in flight
+}
+
+// New creates an empty Registry
+func New() *Registry {
+	return &Registry{running: make(map[string]int)}
+}
+
+// Start marks an execution of the function as in flight. The returned func
+// marks it as finished and must be called exactly once.
+func (r *Registry) Start(functionID string) (done func()) {
+	if r == nil {
+		return func() {}
+	}
+
+	r.mu.Lock()
+	r.running[functionID]++
+	r.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.running[functionID]--; r.running[functionID] <= 0 {
+				delete(r.running, functionID)
+			}
+		})
+	}
+}
+
+// Running reports whether any execution of the function is in flight
+func (r *Registry) Running(functionID string) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running[functionID] > 0
+}
@@ -0,0 +1,39 @@
+package inflight
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	r := New()
+	if r.Running("fn-1") {
+		t.Error("expected no execution in flight")
+	}
+
+	first := r.Start("fn-1")
+	second := r.Start("fn-1")
+	if !r.Running("fn-1") {
+		t.Error("expected fn-1 in flight")
+	}
+	if r.Running("fn-2") {
+		t.Error("expected fn-2 not in flight")
+	}
+
+	first()
+	first() // Calling done again has no effect
+	if !r.Running("fn-1") {
+		t.Error("expected fn-1 in flight until its last execution finishes")
+	}
+
+	second()
+	if r.Running("fn-1") {
+		t.Error("expected fn-1 no longer in flight")
+	}
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var r *Registry
+	done := r.Start("fn-1")
+	if r.Running("fn-1") {
+		t.Error("expected a nil registry to report nothing in flight")
+	}
+	done()
+}
@@ -0,0 +1,8 @@
+// Package killswitch provides process-wide switches that turn off outbound
+// integrations used by functions.
+//
+// During an incident, such as a provider outage causing cascading timeouts,
+// an operator can disable the http, ai or email integration. Calls made
+// through a disabled integration fail immediately with a *DisabledError
+// instead of waiting on the unavailable dependency.
+package killswitch
@@ -0,0 +1,77 @@
+package killswitch
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+)
+
+// Integration names an outbound integration that can be switched off
+type Integration string
+
+const (
+	HTTP  Integration = "http"
+	AI    Integration = "ai"
+	Email Integration = "email"
+)
+
+// Integrations lists every integration that can be switched off
+var Integrations = []Integration{HTTP, AI, Email}
+
+// Valid reports whether i names a known integration
+func (i Integration) Valid() bool {
+	return slices.Contains(Integrations, i)
+}
+
+// DisabledError is returned for calls made while an integration is switched off
+type DisabledError struct {
+	Integration Integration
+}
+
+func (e *DisabledError) Error() string {
+	return fmt.Sprintf("outbound %s is disabled by the operator", e.Integration)
+}
+
+// Switch holds the on/off state of each integration and is safe for concurrent use.
+// A nil *Switch never disables anything.
+type Switch struct {
+	disabled map[Integration]*atomic.Bool
+}
+
+// New creates a Switch with the given integrations initially disabled
+func New(disabled ...Integration) *Switch {
+	s := &Switch{disabled: make(map[Integration]*atomic.Bool, len(Integrations))}
+	for _, i := range Integrations {
+		s.disabled[i] = &atomic.Bool{}
+	}
+	for _, i := range disabled {
+		if flag, ok := s.disabled[i]; ok {
+			flag.Store(true)
+		}
+	}
+	return s
+}
+
+// Disabled reports whether the integration is switched off
+func (s *Switch) Disabled(i Integration) bool {
+	if s == nil {
+		return false
+	}
+	flag, ok := s.disabled[i]
+	return ok && flag.Load()
+}
+
+// Set switches an integration off (disabled true) or back on. Unknown integrations are ignored.
+func (s *Switch) Set(i Integration, disabled bool) {
+	if flag, ok := s.disabled[i]; ok {
+		flag.Store(disabled)
+	}
+}
+
+// Check returns a *DisabledError when the integration is switched off
+func (s *Switch) Check(i Integration) error {
+	if s.Disabled(i) {
+		return &DisabledError{Integration: i}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package killswitch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSwitch(t *testing.T) {
+	s := New(AI)
+	if !s.Disabled(AI) {
+		t.Error("expected ai to start disabled")
+	}
+	if s.Disabled(HTTP) || s.Disabled(Email) {
+		t.Error("expected http and email to start enabled")
+	}
+
+	s.Set(HTTP, true)
+	s.Set(AI, false)
+	if !s.Disabled(HTTP) || s.Disabled(AI) {
+		t.Error("expected http disabled and ai enabled after Set")
+	}
+
+	s.Set("ftp", true)
+	if s.Disabled("ftp") {
+		t.Error("expected unknown integration to be ignored")
+	}
+}
+
+func TestSwitch_Check(t *testing.T) {
+	s := New(Email)
+
+	if err := s.Check(HTTP); err != nil {
+		t.Errorf("expected no error for enabled integration, got %v", err)
+	}
+
+	err := s.Check(Email)
+	var disabledErr *DisabledError
+	if !errors.As(err, &disabledErr) || disabledErr.Integration != Email {
+		t.Fatalf("expected DisabledError for email, got %v", err)
+	}
+	if err.Error() != "outbound email is disabled by the operator" {
+		t.Errorf("unexpected error message: %q", err.Error())
+	}
+}
+
+func TestSwitch_Nil(t *testing.T) {
+	var s *Switch
+	if s.Disabled(HTTP) || s.Check(AI) != nil {
+		t.Error("expected nil switch to never disable")
+	}
+}
+
+func TestIntegration_Valid(t *testing.T) {
+	if !HTTP.Valid() || !AI.Valid() || !Email.Valid() {
+		t.Error("expected known integrations to be valid")
+	}
+	if Integration("ftp").Valid() {
+		t.Error("expected unknown integration to be invalid")
+	}
+}
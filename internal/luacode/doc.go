@@ -0,0 +1,13 @@
+// Package luacode formats and lints Lua function code.
+//
+// Format re-indents code by its block structure and tidies whitespace
+// without changing what it does. Lint reports likely mistakes, such as
+// assignments to undeclared globals, with their line numbers.
+//
+// Example:
+//
+//	formatted, err := luacode.Format(code)
+//	for _, finding := range luacode.Lint(code, luacode.Globals) {
+//	    fmt.Printf("%d: %s\n", finding.Line, finding.Message)
+//	}
+package luacode
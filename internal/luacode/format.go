@@ -0,0 +1,158 @@
+package luacode
+
+import (
+	"strings"
+
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Indent is the indentation of one block level in formatted code
+const Indent = "  "
+
+// blockEffects says how a token changes the open blocks: whether it closes
+// the innermost one and whether it opens a new one
+var blockEffects = map[string]struct{ closes, opens bool }{
+	"function": {opens: true},
+	"do":       {opens: true},
+	"then":     {opens: true},
+	"repeat":   {opens: true},
+	"{":        {opens: true},
+	"(":        {opens: true},
+	"[":        {opens: true},
+	"end":      {closes: true},
+	"until":    {closes: true},
+	"}":        {closes: true},
+	")":        {closes: true},
+	"]":        {closes: true},
+	"else":     {closes: true, opens: true},
+	"elseif":   {closes: true},
+}
+
+// binaryOperators continue an expression on the next line when they end a
+// line, or continue the previous line when they start one
+var binaryOperators = map[string]bool{
+	"..": true, "and": true, "or": true, "+": true, "-": true, "*": true, "/": true,
+	"//": true, "%": true, "^": true, "==": true, "~=": true, "<": true, ">": true,
+	"<=": true, ">=": true,
+}
+
+// Format re-indents Lua code by its block structure with Indent, removes
+// trailing whitespace and repeated blank lines, and ends the code with a
+// single newline. The content of long strings and comments spanning several
+// lines is kept as is. Code that does not parse is returned with the error.
+func Format(code string) (string, error) {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	if _, err := parse.Parse(strings.NewReader(code), "<string>"); err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(code, "\n")
+	lineTokens := make([][]token, len(lines)+1)
+	verbatim := make([]bool, len(lines)+1)  // The line starts inside a multi-line token
+	openAtEnd := make([]bool, len(lines)+1) // The line ends inside a multi-line token
+	for _, tok := range scan(code) {
+		lineTokens[tok.line] = append(lineTokens[tok.line], tok)
+		openAtEnd[tok.line] = tok.endLine > tok.line
+		for line := tok.line + 1; line <= tok.endLine; line++ {
+			verbatim[line] = true
+			openAtEnd[line] = line < tok.endLine
+		}
+	}
+
+	var (
+		out          []string
+		blocks       []int // Line of each open block, innermost last
+		continuation bool  // The previous code line ends in the middle of an expression
+	)
+	for i, text := range lines {
+		line := i + 1
+		tokens := codeTokens(lineTokens[line])
+
+		// Blocks closed at the start of the line are dedented with it
+		leading := 0
+		for leading < len(tokens) && closesBlock(tokens[leading]) {
+			blocks = closeBlock(blocks)
+			leading++
+		}
+
+		switch {
+		case verbatim[line]:
+			out = append(out, text)
+		case strings.TrimSpace(text) == "":
+			if len(out) > 0 && out[len(out)-1] != "" {
+				out = append(out, "")
+			}
+		default:
+			depth := openLines(blocks)
+			// A leading - is more likely a negative number than a continuation
+			if continuation || (len(tokens) > 0 && binaryOperators[tokens[0].text] && tokens[0].text != "-") {
+				depth++
+			}
+			text = strings.TrimLeft(text, " \t")
+			if !openAtEnd[line] {
+				text = strings.TrimRight(text, " \t")
+			}
+			out = append(out, strings.Repeat(Indent, depth)+text)
+		}
+
+		for j, tok := range tokens {
+			if tok.kind != tokenName && tok.kind != tokenSymbol {
+				continue
+			}
+			effect := blockEffects[tok.text]
+			if effect.closes && j >= leading {
+				blocks = closeBlock(blocks)
+			}
+			if effect.opens {
+				blocks = append(blocks, line)
+			}
+		}
+		if len(tokens) > 0 {
+			last := tokens[len(tokens)-1].text
+			continuation = binaryOperators[last] || last == "="
+		}
+	}
+
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return "", nil
+	}
+	return strings.Join(out, "\n") + "\n", nil
+}
+
+// codeTokens returns tokens without comments
+func codeTokens(tokens []token) []token {
+	var code []token
+	for _, tok := range tokens {
+		if tok.kind != tokenComment {
+			code = append(code, tok)
+		}
+	}
+	return code
+}
+
+// closesBlock reports whether tok is a keyword or bracket closing a block
+func closesBlock(tok token) bool {
+	return (tok.kind == tokenName || tok.kind == tokenSymbol) && blockEffects[tok.text].closes
+}
+
+func closeBlock(blocks []int) []int {
+	if len(blocks) == 0 {
+		return blocks
+	}
+	return blocks[:len(blocks)-1]
+}
+
+// openLines counts the lines that opened the open blocks, so that several
+// blocks opened on one line, as in foo(function(), indent only once
+func openLines(blocks []int) int {
+	count := 0
+	for i, line := range blocks {
+		if i == 0 || blocks[i-1] != line {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,94 @@
+package luacode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "blocks",
+			code: "function handler(ctx, event)   \nif event.method == \"GET\" then\nreturn {statusCode = 200}\n    elseif event.method == \"POST\" then\nlog.info(\"post\")\n        else\nfor i = 1, 3 do\nlog.info(i)\nend\nend\nreturn {statusCode = 404}\nend",
+			want: "function handler(ctx, event)\n  if event.method == \"GET\" then\n    return {statusCode = 200}\n  elseif event.method == \"POST\" then\n    log.info(\"post\")\n  else\n    for i = 1, 3 do\n      log.info(i)\n    end\n  end\n  return {statusCode = 404}\nend\n",
+		},
+		{
+			name: "tables and callbacks",
+			code: "router.get(\"/\", function(req)\nreturn {\nstatusCode = 200,\nheaders = {\n[\"X-Id\"] = 1,\n},\n}\nend)",
+			want: "router.get(\"/\", function(req)\n  return {\n    statusCode = 200,\n    headers = {\n      [\"X-Id\"] = 1,\n    },\n  }\nend)\n",
+		},
+		{
+			name: "continuation lines",
+			code: "local s = \"a\" ..\n\"b\"\n.. \"c\"\nlocal n = {\n-1,\n}",
+			want: "local s = \"a\" ..\n  \"b\"\n  .. \"c\"\nlocal n = {\n  -1,\n}\n",
+		},
+		{
+			name: "repeat",
+			code: "repeat\nx = x - 1\nuntil x == 0",
+			want: "repeat\n  x = x - 1\nuntil x == 0\n",
+		},
+		{
+			name: "blank lines",
+			code: "\n\nlocal a = 1\n\n\n\nlocal b = 2\n\n\n",
+			want: "local a = 1\n\nlocal b = 2\n",
+		},
+		{
+			name: "long strings and comments kept",
+			code: "local html = [[\n  <p>   \n]]\n  --[[ note\n     more ]]\nlocal x = 1 -- end",
+			want: "local html = [[\n  <p>   \n]]\n--[[ note\n     more ]]\nlocal x = 1 -- end\n",
+		},
+		{
+			name: "keywords in strings and comments",
+			code: "if a then\nlocal s = \"end do then\" -- end\nend",
+			want: "if a then\n  local s = \"end do then\" -- end\nend\n",
+		},
+		{
+			name: "crlf",
+			code: "if a then\r\nb()\r\nend\r\n",
+			want: "if a then\n  b()\nend\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.code)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			again, err := Format(got)
+			if err != nil || again != got {
+				t.Errorf("expected formatting to be stable, got\n%s", again)
+			}
+		})
+	}
+}
+
+func TestFormat_SyntaxError(t *testing.T) {
+	if _, err := Format("function handler(\n"); err == nil {
+		t.Error("expected an error for code that does not parse")
+	}
+}
+
+func TestScan(t *testing.T) {
+	tokens := scan("local s = [==[a\n]]b]==] .. 'x\\'y' -- c\nx = 0x1Fp-2 + 1e-3")
+
+	var texts []string
+	for _, tok := range tokens {
+		texts = append(texts, tok.text)
+	}
+	want := []string{"local", "s", "=", "[==[a\n]]b]==]", "..", "'x\\'y'", "-- c", "x", "=", "0x1Fp-2", "+", "1e-3"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("scan() = %q, want %q", texts, want)
+	}
+	if tokens[3].line != 1 || tokens[3].endLine != 2 || tokens[7].line != 3 {
+		t.Errorf("expected line numbers across the long string, got %+v", tokens)
+	}
+}
@@ -0,0 +1,23 @@
+package luacode
+
+// Globals lists the global names function code can use without defining
+// them: the Lua standard library, the runtime modules and app. The runner
+// tests keep it in step with what executions register.
+var Globals = []string{
+	// Lua standard library
+	"_G", "_GOPHER_LUA_VERSION", "_VERSION", "_printregs", "assert", "channel",
+	"collectgarbage", "coroutine", "debug", "dofile", "error", "getfenv",
+	"getmetatable", "io", "ipairs", "load", "loadfile", "loadstring", "math",
+	"module", "newproxy", "next", "os", "package", "pairs", "pcall", "print",
+	"rawequal", "rawget", "rawset", "require", "select", "setfenv",
+	"setmetatable", "string", "table", "tonumber", "tostring", "type", "unpack",
+	"xpcall",
+
+	// Runtime modules
+	"log", "trace", "kv", "env", "http", "json", "base64", "crypto", "time",
+	"url", "strings", "random", "respond", "router", "ai", "email", "ws", "sse",
+	"execution",
+
+	// Shared table of the function's state
+	"app",
+}
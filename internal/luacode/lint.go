@@ -0,0 +1,318 @@
+package luacode
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Severity is how serious a lint finding is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // The code cannot run
+	SeverityWarning Severity = "warning" // The code runs but is likely wrong
+)
+
+// Lint rules
+const (
+	RuleSyntax           = "syntax"
+	RuleMissingHandler   = "missing-handler"
+	RuleGlobalAssignment = "global-assignment"
+	RuleUndefinedGlobal  = "undefined-global"
+	RuleUnusedLocal      = "unused-local"
+)
+
+// LifecycleFunctions are the global functions the runtime calls, which code
+// defines as globals on purpose
+var LifecycleFunctions = []string{"handler", "init", "cleanup"}
+
+// Finding is a problem found by Lint on a 1-based line of the code
+type Finding struct {
+	Line     int      `json:"line"`
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+}
+
+// Lint reports likely mistakes in Lua code: syntax errors, a missing handler
+// function, assignments to undeclared globals, reads of globals that are not
+// in globals or assigned by the code, and unused locals. Locals whose name
+// starts with _ may go unused. Findings are sorted by line.
+func Lint(code string, globals []string) []Finding {
+	chunk, err := parse.Parse(strings.NewReader(code), "<string>")
+	if err != nil {
+		return []Finding{syntaxFinding(err, code)}
+	}
+
+	l := &linter{
+		globals:  make(map[string]bool, len(globals)),
+		assigned: make(map[string]bool),
+	}
+	for _, name := range globals {
+		l.globals[name] = true
+	}
+	l.block(chunk, nil)
+
+	// Globals read before the statement assigning them are not undefined
+	reported := make(map[string]bool)
+	for _, read := range l.reads {
+		if l.assigned[read.name] || reported[read.name] {
+			continue
+		}
+		reported[read.name] = true
+		l.report(read.line, RuleUndefinedGlobal, "undefined global %q", read.name)
+	}
+	if !l.assigned["handler"] {
+		l.report(1, RuleMissingHandler, "no global handler function is defined")
+	}
+
+	slices.SortStableFunc(l.findings, func(a, b Finding) int { return a.Line - b.Line })
+	return l.findings
+}
+
+// syntaxFinding reports a parse error at its line, or the last line when
+// the code ended unexpectedly
+func syntaxFinding(err error, code string) Finding {
+	line := strings.Count(code, "\n") + 1
+	message := err.Error()
+	var parseErr *parse.Error
+	if errors.As(err, &parseErr) {
+		if parseErr.Pos.Line > 0 {
+			line = parseErr.Pos.Line
+		}
+		message = parseErr.Message
+		if parseErr.Token != "" {
+			message += fmt.Sprintf(" near '%s'", parseErr.Token)
+		}
+	}
+	return Finding{Line: line, Severity: SeverityError, Rule: RuleSyntax, Message: strings.TrimSpace(message)}
+}
+
+// variable is a local variable or parameter in scope
+type variable struct {
+	name  string
+	line  int
+	param bool // Parameters and loop variables may go unused
+	used  bool
+}
+
+type scope struct {
+	parent *scope
+	vars   []*variable
+}
+
+// globalRead is a read of a name that is not a local
+type globalRead struct {
+	name string
+	line int
+}
+
+type linter struct {
+	globals  map[string]bool // Provided by the runtime
+	assigned map[string]bool // Assigned by the code
+	reads    []globalRead
+	scope    *scope
+	findings []Finding
+}
+
+func (l *linter) report(line int, rule, format string, args ...any) {
+	l.findings = append(l.findings, Finding{
+		Line:     line,
+		Severity: SeverityWarning,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// block walks stmts in a new scope holding params
+func (l *linter) block(stmts []ast.Stmt, params []*variable) {
+	l.scope = &scope{parent: l.scope, vars: params}
+	l.stmts(stmts)
+	l.closeScope()
+}
+
+func (l *linter) closeScope() {
+	for _, v := range l.scope.vars {
+		if !v.used && !v.param && !strings.HasPrefix(v.name, "_") {
+			l.report(v.line, RuleUnusedLocal, "unused local %q", v.name)
+		}
+	}
+	l.scope = l.scope.parent
+}
+
+func (l *linter) declare(name string, line int, param bool) *variable {
+	v := &variable{name: name, line: line, param: param}
+	l.scope.vars = append(l.scope.vars, v)
+	return v
+}
+
+// lookup returns the innermost local named name, or nil for a global
+func (l *linter) lookup(name string) *variable {
+	for s := l.scope; s != nil; s = s.parent {
+		for i := len(s.vars) - 1; i >= 0; i-- {
+			if s.vars[i].name == name {
+				return s.vars[i]
+			}
+		}
+	}
+	return nil
+}
+
+// assignGlobal records an assignment to a global, warning about globals
+// that are neither provided by the runtime nor a lifecycle function
+func (l *linter) assignGlobal(name string, line int, function bool) {
+	first := !l.assigned[name]
+	l.assigned[name] = true
+	if !first || l.globals[name] || slices.Contains(LifecycleFunctions, name) {
+		return
+	}
+	if function {
+		l.report(line, RuleGlobalAssignment, "function %q is global; declare it with local function", name)
+	} else {
+		l.report(line, RuleGlobalAssignment, "assignment to undeclared global %q; declare it with local", name)
+	}
+}
+
+func (l *linter) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		l.stmt(stmt)
+	}
+}
+
+func (l *linter) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		l.exprs(s.Rhs)
+		for _, lhs := range s.Lhs {
+			if ident, ok := lhs.(*ast.IdentExpr); ok {
+				if l.lookup(ident.Value) == nil {
+					l.assignGlobal(ident.Value, s.Line(), false)
+				}
+				continue
+			}
+			l.expr(lhs)
+		}
+	case *ast.LocalAssignStmt:
+		// local function f can call itself, so f is in scope of its body
+		if len(s.Names) == 1 && len(s.Exprs) == 1 {
+			if fn, ok := s.Exprs[0].(*ast.FunctionExpr); ok {
+				l.declare(s.Names[0], s.Line(), false)
+				l.function(fn, nil)
+				return
+			}
+		}
+		l.exprs(s.Exprs)
+		for _, name := range s.Names {
+			l.declare(name, s.Line(), false)
+		}
+	case *ast.FuncCallStmt:
+		l.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		l.block(s.Stmts, nil)
+	case *ast.WhileStmt:
+		l.expr(s.Condition)
+		l.block(s.Stmts, nil)
+	case *ast.RepeatStmt:
+		// The condition sees the locals of the body
+		l.scope = &scope{parent: l.scope}
+		l.stmts(s.Stmts)
+		l.expr(s.Condition)
+		l.closeScope()
+	case *ast.IfStmt:
+		l.expr(s.Condition)
+		l.block(s.Then, nil)
+		l.block(s.Else, nil)
+	case *ast.NumberForStmt:
+		l.expr(s.Init)
+		l.expr(s.Limit)
+		l.expr(s.Step)
+		l.block(s.Stmts, []*variable{{name: s.Name, line: s.Line(), param: true}})
+	case *ast.GenericForStmt:
+		l.exprs(s.Exprs)
+		vars := make([]*variable, len(s.Names))
+		for i, name := range s.Names {
+			vars[i] = &variable{name: name, line: s.Line(), param: true}
+		}
+		l.block(s.Stmts, vars)
+	case *ast.FuncDefStmt:
+		var self []*variable
+		switch {
+		case s.Name.Receiver != nil:
+			l.expr(s.Name.Receiver)
+			self = append(self, &variable{name: "self", line: s.Line(), param: true})
+		case s.Name.Func != nil:
+			if ident, ok := s.Name.Func.(*ast.IdentExpr); ok && l.lookup(ident.Value) == nil {
+				l.assignGlobal(ident.Value, s.Line(), true)
+			} else {
+				l.expr(s.Name.Func)
+			}
+		}
+		l.function(s.Func, self)
+	case *ast.ReturnStmt:
+		l.exprs(s.Exprs)
+	}
+}
+
+// function walks the body of fn with its parameters in scope, after params
+func (l *linter) function(fn *ast.FunctionExpr, params []*variable) {
+	if fn.ParList != nil {
+		for _, name := range fn.ParList.Names {
+			params = append(params, &variable{name: name, line: fn.Line(), param: true})
+		}
+	}
+	l.block(fn.Stmts, params)
+}
+
+func (l *linter) exprs(exprs []ast.Expr) {
+	for _, expr := range exprs {
+		l.expr(expr)
+	}
+}
+
+func (l *linter) expr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		if v := l.lookup(e.Value); v != nil {
+			v.used = true
+		} else if !l.globals[e.Value] {
+			l.reads = append(l.reads, globalRead{name: e.Value, line: e.Line()})
+		}
+	case *ast.AttrGetExpr:
+		l.expr(e.Object)
+		l.expr(e.Key)
+	case *ast.TableExpr:
+		for _, field := range e.Fields {
+			l.expr(field.Key)
+			l.expr(field.Value)
+		}
+	case *ast.FuncCallExpr:
+		l.expr(e.Func)
+		l.expr(e.Receiver)
+		l.exprs(e.Args)
+	case *ast.LogicalOpExpr:
+		l.expr(e.Lhs)
+		l.expr(e.Rhs)
+	case *ast.RelationalOpExpr:
+		l.expr(e.Lhs)
+		l.expr(e.Rhs)
+	case *ast.StringConcatOpExpr:
+		l.expr(e.Lhs)
+		l.expr(e.Rhs)
+	case *ast.ArithmeticOpExpr:
+		l.expr(e.Lhs)
+		l.expr(e.Rhs)
+	case *ast.UnaryMinusOpExpr:
+		l.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		l.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		l.expr(e.Expr)
+	case *ast.FunctionExpr:
+		l.function(e, nil)
+	}
+}
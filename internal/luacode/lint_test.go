@@ -0,0 +1,130 @@
+package luacode
+
+import (
+	"fmt"
+	"testing"
+)
+
+var testGlobals = []string{"log", "json", "app", "pairs", "tostring", "string"}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want []string // line:rule
+	}{
+		{
+			name: "clean",
+			code: `
+local json = json
+
+local function double(n)
+	return n * 2
+end
+
+function handler(ctx, event)
+	local body = json.decode(event.body)
+	for key, value in pairs(body) do
+		log.info(key)
+	end
+	app.count = (app.count or 0) + double(1)
+	return {statusCode = 200}
+end
+`,
+		},
+		{
+			name: "global assignment",
+			code: `
+counter = 0
+function helper() end
+function handler(ctx, event)
+	counter = counter + 1
+	return {statusCode = 200, body = tostring(counter)}
+end
+`,
+			want: []string{"2:global-assignment", "3:global-assignment"},
+		},
+		{
+			name: "undefined global",
+			code: `
+function handler(ctx, event)
+	local data = jsno.decode(event.body)
+	return {statusCode = 200, body = data .. jsno.x}
+end
+`,
+			want: []string{"3:undefined-global"},
+		},
+		{
+			name: "unused local",
+			code: `
+local unused = 1
+local _ignored = 2
+function handler(ctx, event)
+	local result, err = string.find("a", "b")
+	if err then return end
+	return {statusCode = 200}
+end
+`,
+			want: []string{"2:unused-local", "5:unused-local"},
+		},
+		{
+			name: "missing handler",
+			code: `
+local function handler(ctx, event)
+	return {statusCode = 200}
+end
+return handler
+`,
+			want: []string{"1:missing-handler"},
+		},
+		{
+			name: "scopes",
+			code: `
+function handler(ctx, event)
+	if event.body then
+		local inner = 1
+		log.info(tostring(inner))
+	end
+	return {statusCode = 200, body = inner}
+end
+`,
+			want: []string{"7:undefined-global"},
+		},
+		{
+			name: "methods and recursion",
+			code: `
+local M = {}
+function M:greet() return self.name end
+local function fact(n) if n <= 1 then return 1 end return n * fact(n - 1) end
+handler = function(ctx, event)
+	return {statusCode = 200, body = M:greet() .. fact(3)}
+end
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, finding := range Lint(tt.code, testGlobals) {
+				got = append(got, fmt.Sprintf("%d:%s", finding.Line, finding.Rule))
+				if finding.Severity != SeverityWarning {
+					t.Errorf("expected a warning, got %+v", finding)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Lint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLint_SyntaxError(t *testing.T) {
+	findings := Lint("function handler(ctx, event)\n\treturn {statusCode = 200\nend\n", testGlobals)
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Rule != RuleSyntax || f.Severity != SeverityError || f.Line != 3 {
+		t.Errorf("expected a syntax error on line 3, got %+v", f)
+	}
+}
@@ -0,0 +1,170 @@
+package luacode
+
+import "strings"
+
+// tokenKind classifies the tokens of Lua source
+type tokenKind int
+
+const (
+	tokenName tokenKind = iota // Identifiers and keywords
+	tokenNumber
+	tokenString
+	tokenComment
+	tokenSymbol
+)
+
+// token is a lexical token of Lua source. Line and EndLine are 1-based and
+// differ for long strings and comments spanning several lines.
+type token struct {
+	kind    tokenKind
+	text    string
+	line    int
+	endLine int
+}
+
+// symbols lists the multi-character operators, longest first
+var symbols = []string{"...", "..", "==", "~=", "<=", ">=", "::", "//", "<<", ">>"}
+
+// scan splits Lua source into tokens. It is lenient: unterminated strings
+// and comments run to the end of the source instead of failing.
+func scan(src string) []token {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		start, startLine := i, line
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+		case strings.HasPrefix(src[i:], "--"):
+			if level, ok := longBracket(src[i+2:]); ok {
+				i = skipLongBracket(src, i+2, level)
+			} else {
+				i += 2
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			}
+			tokens = append(tokens, newToken(tokenComment, src[start:i], startLine))
+		case c == '"' || c == '\'':
+			i = skipQuoted(src, i)
+			tokens = append(tokens, newToken(tokenString, src[start:i], startLine))
+		case c == '[':
+			if level, ok := longBracket(src[i:]); ok {
+				i = skipLongBracket(src, i, level)
+				tokens = append(tokens, newToken(tokenString, src[start:i], startLine))
+			} else {
+				i++
+				tokens = append(tokens, newToken(tokenSymbol, "[", startLine))
+			}
+		case isNameStart(c):
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, newToken(tokenName, src[start:i], startLine))
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			i = skipNumber(src, i)
+			tokens = append(tokens, newToken(tokenNumber, src[start:i], startLine))
+		default:
+			text := src[i : i+1]
+			for _, symbol := range symbols {
+				if strings.HasPrefix(src[i:], symbol) {
+					text = symbol
+					break
+				}
+			}
+			i += len(text)
+			tokens = append(tokens, newToken(tokenSymbol, text, startLine))
+		}
+
+		line += strings.Count(src[start:i], "\n")
+	}
+	return tokens
+}
+
+func newToken(kind tokenKind, text string, line int) token {
+	return token{kind: kind, text: text, line: line, endLine: line + strings.Count(text, "\n")}
+}
+
+// longBracket reports whether s starts with an opening long bracket, [[ or
+// [==[, and returns its level, the number of equal signs
+func longBracket(s string) (int, bool) {
+	if !strings.HasPrefix(s, "[") {
+		return 0, false
+	}
+	level := 1
+	for level < len(s) && s[level] == '=' {
+		level++
+	}
+	if level < len(s) && s[level] == '[' {
+		return level - 1, true
+	}
+	return 0, false
+}
+
+// skipLongBracket returns the position after the long bracket of level
+// opened at i
+func skipLongBracket(src string, i, level int) int {
+	closing := "]" + strings.Repeat("=", level) + "]"
+	if end := strings.Index(src[i+level+2:], closing); end >= 0 {
+		return i + level + 2 + end + len(closing)
+	}
+	return len(src)
+}
+
+// skipQuoted returns the position after the quoted string opened at i
+func skipQuoted(src string, i int) int {
+	quote := src[i]
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			// An unterminated string ends at the line break
+			return i
+		}
+	}
+	return len(src)
+}
+
+// skipNumber returns the position after the number starting at i
+func skipNumber(src string, i int) int {
+	exponents := "eE"
+	if strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X") {
+		exponents = "pP"
+		i += 2
+	}
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case isNameChar(c) || c == '.':
+			i++
+			if strings.IndexByte(exponents, c) >= 0 && i < len(src) && (src[i] == '+' || src[i] == '-') {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,6 @@
+// Package maintenance provides a process-wide switch that pauses function execution.
+//
+// While maintenance mode is enabled, function endpoints respond with
+// 503 Service Unavailable and a Retry-After header, and cron schedules do not
+// fire. The management API stays available so the mode can be turned off again.
+package maintenance
@@ -0,0 +1,44 @@
+package maintenance
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryAfter is the Retry-After delay suggested to clients when none is configured
+const DefaultRetryAfter = 60 * time.Second
+
+// Mode is a maintenance switch that is safe for concurrent use.
+// A nil *Mode is never enabled.
+type Mode struct {
+	enabled    atomic.Bool
+	retryAfter time.Duration
+}
+
+// New creates a Mode with the given initial state. A zero retryAfter uses DefaultRetryAfter.
+func New(enabled bool, retryAfter time.Duration) *Mode {
+	if retryAfter <= 0 {
+		retryAfter = DefaultRetryAfter
+	}
+	m := &Mode{retryAfter: retryAfter}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether maintenance mode is on
+func (m *Mode) Enabled() bool {
+	return m != nil && m.enabled.Load()
+}
+
+// Set turns maintenance mode on or off
+func (m *Mode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// RetryAfter returns the delay clients are asked to wait before retrying
+func (m *Mode) RetryAfter() time.Duration {
+	if m == nil {
+		return DefaultRetryAfter
+	}
+	return m.retryAfter
+}
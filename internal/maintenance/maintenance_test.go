@@ -0,0 +1,46 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMode(t *testing.T) {
+	m := New(false, 0)
+	if m.Enabled() {
+		t.Error("expected mode to start disabled")
+	}
+	if m.RetryAfter() != DefaultRetryAfter {
+		t.Errorf("expected default retry after, got %v", m.RetryAfter())
+	}
+
+	m.Set(true)
+	if !m.Enabled() {
+		t.Error("expected mode to be enabled")
+	}
+
+	m.Set(false)
+	if m.Enabled() {
+		t.Error("expected mode to be disabled")
+	}
+}
+
+func TestMode_Nil(t *testing.T) {
+	var m *Mode
+	if m.Enabled() {
+		t.Error("expected nil mode to be disabled")
+	}
+	if m.RetryAfter() != DefaultRetryAfter {
+		t.Errorf("expected default retry after, got %v", m.RetryAfter())
+	}
+}
+
+func TestNew_RetryAfter(t *testing.T) {
+	m := New(true, 5*time.Minute)
+	if !m.Enabled() {
+		t.Error("expected mode to start enabled")
+	}
+	if m.RetryAfter() != 5*time.Minute {
+		t.Errorf("expected 5m retry after, got %v", m.RetryAfter())
+	}
+}
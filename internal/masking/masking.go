@@ -3,6 +3,7 @@ package masking
 import (
 	"encoding/json"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/dimiro1/lunar/internal/events"
@@ -135,6 +136,36 @@ func MaskQueryParams(query map[string]string) map[string]string {
 	return masked
 }
 
+// MaskHeaderValues masks every value of sensitive multi-value headers
+func MaskHeaderValues(headers map[string][]string) map[string][]string {
+	return maskValues(headers, IsSensitiveKey)
+}
+
+// MaskQueryValues masks every value of sensitive multi-value query parameters
+func MaskQueryValues(query map[string][]string) map[string][]string {
+	return maskValues(query, IsSensitiveQueryParam)
+}
+
+// maskValues replaces each value of the keys matched by sensitive
+func maskValues(values map[string][]string, sensitive func(string) bool) map[string][]string {
+	if values == nil {
+		return nil
+	}
+	masked := make(map[string][]string, len(values))
+	for key, list := range values {
+		if sensitive(key) {
+			redacted := make([]string, len(list))
+			for i := range redacted {
+				redacted[i] = redactedValue
+			}
+			masked[key] = redacted
+		} else {
+			masked[key] = slices.Clone(list)
+		}
+	}
+	return masked
+}
+
 // MaskJSONBody attempts to parse the body as JSON and mask sensitive fields
 // If parsing fails, returns the original body unchanged
 func MaskJSONBody(body string) string {
@@ -195,6 +226,10 @@ func MaskHTTPEvent(event events.HTTPEvent) events.HTTPEvent {
 		Headers:      MaskHeaders(event.Headers),
 		Body:         MaskJSONBody(event.Body),
 		Query:        MaskQueryParams(event.Query),
+		HeadersAll:   MaskHeaderValues(event.HeadersAll),
+		QueryAll:     MaskQueryValues(event.QueryAll),
+		RemoteIP:     event.RemoteIP,
+		Scheme:       event.Scheme,
 	}
 }
 
@@ -329,6 +329,15 @@ func TestMaskHTTPEvent(t *testing.T) {
 					"api_key": "key123",
 					"limit":   "10",
 				},
+				HeadersAll: map[string][]string{
+					"Authorization": {"Bearer secret_token"},
+					"Content-Type":  {"application/json"},
+					"Cookie":        {"session=abc123"},
+				},
+				QueryAll: map[string][]string{
+					"api_key": {"key123", "key456"},
+					"limit":   {"10"},
+				},
 			},
 		},
 	}
@@ -371,6 +380,17 @@ func TestMaskHTTPEvent(t *testing.T) {
 				t.Errorf("limit query = %q, want 10", result.Query["limit"])
 			}
 
+			// Every value of sensitive multi-value fields should be masked
+			if got := result.QueryAll["api_key"]; len(got) != 2 || got[0] != "[REDACTED]" || got[1] != "[REDACTED]" {
+				t.Errorf("api_key query values = %q, want both [REDACTED]", got)
+			}
+			if got := result.HeadersAll["Cookie"]; len(got) != 1 || got[0] != "[REDACTED]" {
+				t.Errorf("Cookie header values = %q, want [REDACTED]", got)
+			}
+			if got := result.QueryAll["limit"]; len(got) != 1 || got[0] != "10" {
+				t.Errorf("limit query values = %q, want [10]", got)
+			}
+
 			// Body should have sensitive fields masked
 			if !strings.Contains(result.Body, "[REDACTED]") {
 				t.Errorf("Body should contain [REDACTED], got %q", result.Body)
@@ -0,0 +1,2 @@
+-- Remove allowed HTTP methods from functions
+ALTER TABLE functions DROP COLUMN allowed_methods;
@@ -0,0 +1,2 @@
+-- Add allowed HTTP methods to functions (comma-separated, empty means all methods)
+ALTER TABLE functions ADD COLUMN allowed_methods TEXT;
@@ -0,0 +1,2 @@
+-- Remove maximum number of retained versions from functions
+ALTER TABLE functions DROP COLUMN max_versions;
@@ -0,0 +1,2 @@
+-- Add optional maximum number of retained versions to functions
+ALTER TABLE functions ADD COLUMN max_versions INTEGER;
@@ -0,0 +1,2 @@
+-- Remove default response headers from functions
+ALTER TABLE functions DROP COLUMN default_headers;
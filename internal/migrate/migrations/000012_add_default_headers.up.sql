@@ -0,0 +1,2 @@
+-- Add default response headers (JSON object) to functions
+ALTER TABLE functions ADD COLUMN default_headers TEXT;
@@ -0,0 +1,3 @@
+-- Remove path prefix routing from functions
+DROP INDEX IF EXISTS idx_functions_route_prefix;
+ALTER TABLE functions DROP COLUMN route_prefix;
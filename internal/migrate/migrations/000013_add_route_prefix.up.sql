@@ -0,0 +1,3 @@
+-- Add optional path prefix routing to functions
+ALTER TABLE functions ADD COLUMN route_prefix TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_functions_route_prefix ON functions(route_prefix) WHERE route_prefix IS NOT NULL;
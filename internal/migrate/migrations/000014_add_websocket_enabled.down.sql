@@ -0,0 +1,2 @@
+-- Remove WebSocket support toggle from functions
+ALTER TABLE functions DROP COLUMN websocket_enabled;
@@ -0,0 +1,2 @@
+-- Add WebSocket support toggle to functions
+ALTER TABLE functions ADD COLUMN websocket_enabled BOOLEAN DEFAULT 0;
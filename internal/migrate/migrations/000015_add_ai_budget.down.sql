@@ -0,0 +1,4 @@
+-- Remove monthly AI budget caps and budget override from functions
+ALTER TABLE functions DROP COLUMN ai_budget_override;
+ALTER TABLE functions DROP COLUMN ai_budget_usd;
+ALTER TABLE functions DROP COLUMN ai_budget_tokens;
@@ -0,0 +1,4 @@
+-- Add monthly AI budget caps and budget override to functions
+ALTER TABLE functions ADD COLUMN ai_budget_tokens INTEGER;
+ALTER TABLE functions ADD COLUMN ai_budget_usd REAL;
+ALTER TABLE functions ADD COLUMN ai_budget_override BOOLEAN DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove label from function versions
+ALTER TABLE function_versions DROP COLUMN label;
@@ -0,0 +1,2 @@
+-- Add optional human readable label to function versions
+ALTER TABLE function_versions ADD COLUMN label TEXT;
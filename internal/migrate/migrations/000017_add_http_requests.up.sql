@@ -0,0 +1,23 @@
+-- Opt-in capture of outbound HTTP requests made by functions
+ALTER TABLE functions ADD COLUMN capture_http BOOLEAN DEFAULT 0;
+
+-- HTTP Requests tracking table
+CREATE TABLE IF NOT EXISTS http_requests (
+    id TEXT PRIMARY KEY,
+    execution_id TEXT NOT NULL,
+    method TEXT NOT NULL,
+    url TEXT NOT NULL,
+    request_headers TEXT,
+    request_body TEXT,
+    status_code INTEGER,
+    response_headers TEXT,
+    response_body TEXT,
+    status TEXT NOT NULL,
+    error_message TEXT,
+    duration_ms INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (execution_id) REFERENCES executions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_http_requests_execution_id ON http_requests(execution_id);
+CREATE INDEX IF NOT EXISTS idx_http_requests_created_at ON http_requests(created_at);
@@ -0,0 +1,4 @@
+-- Remove default AI provider/model and email sender
+ALTER TABLE functions DROP COLUMN email_default_from;
+ALTER TABLE functions DROP COLUMN ai_default_model;
+ALTER TABLE functions DROP COLUMN ai_default_provider;
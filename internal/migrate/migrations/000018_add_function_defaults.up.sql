@@ -0,0 +1,4 @@
+-- Default AI provider/model and email sender used when a call leaves them out
+ALTER TABLE functions ADD COLUMN ai_default_provider TEXT;
+ALTER TABLE functions ADD COLUMN ai_default_model TEXT;
+ALTER TABLE functions ADD COLUMN email_default_from TEXT;
@@ -0,0 +1,2 @@
+-- Remove the email sender allowlist from functions
+ALTER TABLE functions DROP COLUMN email_allowed_from;
@@ -0,0 +1,2 @@
+-- Comma-separated addresses and domains a function may send email from
+ALTER TABLE functions ADD COLUMN email_allowed_from TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_email_templates_function_id;
+DROP TABLE IF EXISTS email_templates;
@@ -0,0 +1,13 @@
+-- Email templates rendered by email.send_template
+CREATE TABLE IF NOT EXISTS email_templates (
+	function_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	subject TEXT NOT NULL DEFAULT '',
+	html TEXT NOT NULL DEFAULT '',
+	text TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (function_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_templates_function_id ON email_templates(function_id);
@@ -0,0 +1,2 @@
+-- Remove the disabled stdlib modules from functions
+ALTER TABLE functions DROP COLUMN disabled_modules;
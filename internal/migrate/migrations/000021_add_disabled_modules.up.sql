@@ -0,0 +1,2 @@
+-- Comma-separated stdlib modules disabled for a function
+ALTER TABLE functions ADD COLUMN disabled_modules TEXT;
@@ -0,0 +1,2 @@
+-- Remove the execution tags
+ALTER TABLE executions DROP COLUMN tags;
@@ -0,0 +1,2 @@
+-- JSON object of the tags a function set on its execution with execution.tag
+ALTER TABLE executions ADD COLUMN tags TEXT;
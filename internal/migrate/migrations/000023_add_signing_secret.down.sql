@@ -0,0 +1,2 @@
+-- Remove the per-function request signing secret
+ALTER TABLE functions DROP COLUMN signing_secret;
@@ -0,0 +1,2 @@
+-- Per-function secret for HMAC signed requests to /fn/{id}
+ALTER TABLE functions ADD COLUMN signing_secret TEXT;
@@ -0,0 +1,3 @@
+-- Remove the cron jitter and the scheduled time of executions
+ALTER TABLE executions DROP COLUMN scheduled_at;
+ALTER TABLE functions DROP COLUMN cron_jitter;
@@ -0,0 +1,5 @@
+-- Maximum random delay in seconds before a function's cron execution starts
+ALTER TABLE functions ADD COLUMN cron_jitter INTEGER;
+
+-- Time a cron execution was scheduled for, before any jitter delay
+ALTER TABLE executions ADD COLUMN scheduled_at INTEGER;
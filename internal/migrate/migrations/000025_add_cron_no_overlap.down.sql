@@ -0,0 +1,2 @@
+-- Remove the cron overlap setting
+ALTER TABLE functions DROP COLUMN cron_no_overlap;
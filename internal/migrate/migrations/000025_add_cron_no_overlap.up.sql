@@ -0,0 +1,2 @@
+-- Skip a function's cron execution while its previous one is still running
+ALTER TABLE functions ADD COLUMN cron_no_overlap BOOLEAN DEFAULT 0;
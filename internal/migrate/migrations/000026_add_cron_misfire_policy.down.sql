@@ -0,0 +1,3 @@
+-- Remove the cron misfire policy and last run time
+ALTER TABLE functions DROP COLUMN cron_last_run_at;
+ALTER TABLE functions DROP COLUMN cron_misfire_policy;
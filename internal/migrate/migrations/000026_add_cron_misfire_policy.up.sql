@@ -0,0 +1,5 @@
+-- What to do on startup with cron fires missed while the server was down
+ALTER TABLE functions ADD COLUMN cron_misfire_policy TEXT;
+
+-- Scheduled time of the last successful cron execution
+ALTER TABLE functions ADD COLUMN cron_last_run_at INTEGER;
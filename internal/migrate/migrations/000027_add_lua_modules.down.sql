@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_lua_modules_function_id;
+DROP TABLE IF EXISTS lua_modules;
@@ -0,0 +1,11 @@
+-- Lua modules loaded with require by a function's code
+CREATE TABLE IF NOT EXISTS lua_modules (
+	function_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	code TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (function_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lua_modules_function_id ON lua_modules(function_id);
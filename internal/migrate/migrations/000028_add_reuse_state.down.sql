@@ -0,0 +1,2 @@
+-- Remove the warm state setting
+ALTER TABLE functions DROP COLUMN reuse_state;
@@ -0,0 +1,2 @@
+-- Keep the function's Lua state warm between executions
+ALTER TABLE functions ADD COLUMN reuse_state BOOLEAN DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Remove the execution payload sizes
+ALTER TABLE executions DROP COLUMN response_bytes;
+ALTER TABLE executions DROP COLUMN request_bytes;
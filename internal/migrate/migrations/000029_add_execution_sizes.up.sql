@@ -0,0 +1,3 @@
+-- Payload sizes of each execution, for capacity planning
+ALTER TABLE executions ADD COLUMN request_bytes INTEGER;
+ALTER TABLE executions ADD COLUMN response_bytes INTEGER;
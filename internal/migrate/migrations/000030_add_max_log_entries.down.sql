@@ -0,0 +1,2 @@
+-- Remove maximum number of log entries per execution from functions
+ALTER TABLE functions DROP COLUMN max_log_entries;
@@ -0,0 +1,2 @@
+-- Add optional maximum number of log entries per execution to functions
+ALTER TABLE functions ADD COLUMN max_log_entries INTEGER;
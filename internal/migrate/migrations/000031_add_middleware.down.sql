@@ -0,0 +1,2 @@
+-- Remove middleware from functions
+ALTER TABLE functions DROP COLUMN middleware;
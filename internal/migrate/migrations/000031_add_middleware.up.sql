@@ -0,0 +1,2 @@
+-- Add the comma-separated names of the modules run as middleware around the handler
+ALTER TABLE functions ADD COLUMN middleware TEXT;
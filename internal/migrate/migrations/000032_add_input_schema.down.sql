@@ -0,0 +1,2 @@
+-- Remove input_schema from functions
+ALTER TABLE functions DROP COLUMN input_schema;
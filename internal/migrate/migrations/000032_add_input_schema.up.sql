@@ -0,0 +1,2 @@
+-- Add the JSON Schema that request bodies must match before reaching the handler
+ALTER TABLE functions ADD COLUMN input_schema TEXT;
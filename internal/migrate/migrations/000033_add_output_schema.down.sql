@@ -0,0 +1,2 @@
+-- Remove output_schema from functions
+ALTER TABLE functions DROP COLUMN output_schema;
@@ -0,0 +1,2 @@
+-- Add the JSON Schema response bodies are checked against in dev mode and tests
+ALTER TABLE functions ADD COLUMN output_schema TEXT;
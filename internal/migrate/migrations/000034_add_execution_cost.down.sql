@@ -0,0 +1,2 @@
+-- Remove the execution cost
+ALTER TABLE executions DROP COLUMN cost_usd;
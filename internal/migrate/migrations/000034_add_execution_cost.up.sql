@@ -0,0 +1,2 @@
+-- Estimated cost of each execution in USD, for chargeback
+ALTER TABLE executions ADD COLUMN cost_usd REAL;
@@ -0,0 +1,3 @@
+-- Remove the response cache settings
+ALTER TABLE functions DROP COLUMN cache_vary_headers;
+ALTER TABLE functions DROP COLUMN cache_ttl;
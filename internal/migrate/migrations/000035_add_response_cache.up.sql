@@ -0,0 +1,4 @@
+-- Optional response cache: seconds responses are cached for, and the
+-- comma-separated request headers that are part of the cache key
+ALTER TABLE functions ADD COLUMN cache_ttl INTEGER;
+ALTER TABLE functions ADD COLUMN cache_vary_headers TEXT;
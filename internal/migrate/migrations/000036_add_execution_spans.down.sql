@@ -0,0 +1,2 @@
+-- Remove the execution spans
+ALTER TABLE executions DROP COLUMN spans;
@@ -0,0 +1,2 @@
+-- JSON array of the timing spans a function recorded with trace.span
+ALTER TABLE executions ADD COLUMN spans TEXT;
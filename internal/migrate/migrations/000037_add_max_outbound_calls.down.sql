@@ -0,0 +1,2 @@
+-- Remove the per-function outbound call limit
+ALTER TABLE functions DROP COLUMN max_outbound_calls;
@@ -0,0 +1,3 @@
+-- Outbound http, ai and email calls an execution of the function may make,
+-- lowering the server limit (NULL keeps the server limit)
+ALTER TABLE functions ADD COLUMN max_outbound_calls INTEGER;
@@ -0,0 +1,2 @@
+-- Remove the per-function outbound time budget
+ALTER TABLE functions DROP COLUMN outbound_time_budget;
@@ -0,0 +1,3 @@
+-- Seconds an execution of the function may spend in outbound calls in total,
+-- lowering the server budget (NULL keeps the server budget)
+ALTER TABLE functions ADD COLUMN outbound_time_budget INTEGER;
@@ -0,0 +1,2 @@
+-- Remove the recent errors index
+DROP INDEX IF EXISTS idx_executions_status_created_at;
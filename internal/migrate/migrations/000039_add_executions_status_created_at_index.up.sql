@@ -0,0 +1,3 @@
+-- Index the recent errors query, which filters executions of every function
+-- by status and creation time
+CREATE INDEX IF NOT EXISTS idx_executions_status_created_at ON executions(status, created_at);
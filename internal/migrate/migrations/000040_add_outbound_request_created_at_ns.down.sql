@@ -0,0 +1,4 @@
+-- Remove the nanosecond recording time of outbound requests
+ALTER TABLE http_requests DROP COLUMN created_at_ns;
+ALTER TABLE email_requests DROP COLUMN created_at_ns;
+ALTER TABLE ai_requests DROP COLUMN created_at_ns;
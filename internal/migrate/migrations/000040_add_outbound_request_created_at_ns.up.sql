@@ -0,0 +1,6 @@
+-- Time each outbound request was recorded, in Unix nanoseconds, so the calls
+-- of an execution can be ordered across the ai, email and http trackers
+-- (NULL for requests recorded before this column existed)
+ALTER TABLE ai_requests ADD COLUMN created_at_ns INTEGER;
+ALTER TABLE email_requests ADD COLUMN created_at_ns INTEGER;
+ALTER TABLE http_requests ADD COLUMN created_at_ns INTEGER;
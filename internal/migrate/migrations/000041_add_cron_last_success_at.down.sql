@@ -0,0 +1,2 @@
+-- Remove the last successful cron execution time
+ALTER TABLE functions DROP COLUMN cron_last_success_at;
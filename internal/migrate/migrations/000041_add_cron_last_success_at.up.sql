@@ -0,0 +1,5 @@
+-- Scheduled time of the last successful cron execution, the baseline the
+-- run_once misfire policy catches up from. cron_last_run_at keeps the last
+-- attempt; until now it was only written on success, so it seeds this column.
+ALTER TABLE functions ADD COLUMN cron_last_success_at INTEGER;
+UPDATE functions SET cron_last_success_at = cron_last_run_at;
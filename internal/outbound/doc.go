@@ -0,0 +1,13 @@
+// Package outbound limits the outbound calls a single execution may make.
+//
+// A function looping on http requests, or sending one email per row of a
+// large table, can hammer external services on behalf of every tenant of the
+// server. Each execution gets its own Budget shared by its http, ai and email
+// clients; calls made once the budget is spent fail immediately with a
+// *LimitError instead of reaching the destination.
+//
+// A budget may also cap the total time spent in outbound calls, so a chain of
+// slow calls cannot use up the whole execution timeout and leave the function
+// no time to respond. Calls made after that time is spent fail immediately
+// with a *TimeBudgetError.
+package outbound
@@ -0,0 +1,96 @@
+package outbound
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// LimitError is returned for calls made after an execution spent its budget
+type LimitError struct {
+	Integration string // Integration of the rejected call: http, ai or email
+	Limit       int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("outbound %s call rejected: the execution reached its limit of %d outbound calls", e.Integration, e.Limit)
+}
+
+// TimeBudgetError is returned for calls made after an execution's outbound
+// calls took longer in total than its time budget
+type TimeBudgetError struct {
+	Integration string // Integration of the rejected call: http, ai or email
+	Budget      time.Duration
+}
+
+func (e *TimeBudgetError) Error() string {
+	return fmt.Sprintf("outbound %s call rejected: the execution spent its outbound time budget of %s", e.Integration, e.Budget)
+}
+
+// Budget counts the outbound calls of one execution and the time they took,
+// and is safe for concurrent use. A nil *Budget never rejects anything.
+type Budget struct {
+	maxCalls int64
+	maxTime  time.Duration
+	calls    atomic.Int64
+	spent    atomic.Int64 // Nanoseconds spent in finished calls
+}
+
+// NewBudget creates a budget allowing maxCalls outbound calls taking maxTime
+// in total; a limit that is not positive does not apply. It returns nil, which
+// allows anything, when neither applies.
+func NewBudget(maxCalls int, maxTime time.Duration) *Budget {
+	if maxCalls <= 0 && maxTime <= 0 {
+		return nil
+	}
+	return &Budget{maxCalls: int64(maxCalls), maxTime: maxTime}
+}
+
+// Acquire counts a call through integration, returning a *LimitError or a
+// *TimeBudgetError without counting it once the budget is spent. Calls that
+// started before the time budget ran out are not interrupted.
+func (b *Budget) Acquire(integration string) error {
+	if b == nil {
+		return nil
+	}
+	if b.maxTime > 0 && time.Duration(b.spent.Load()) >= b.maxTime {
+		return &TimeBudgetError{Integration: integration, Budget: b.maxTime}
+	}
+	if b.maxCalls <= 0 {
+		b.calls.Add(1)
+		return nil
+	}
+	for {
+		calls := b.calls.Load()
+		if calls >= b.maxCalls {
+			return &LimitError{Integration: integration, Limit: int(b.maxCalls)}
+		}
+		if b.calls.CompareAndSwap(calls, calls+1) {
+			return nil
+		}
+	}
+}
+
+// Record adds the duration of a finished call to the time spent
+func (b *Budget) Record(d time.Duration) {
+	if b == nil {
+		return
+	}
+	b.spent.Add(int64(d))
+}
+
+// Calls returns the number of calls counted so far
+func (b *Budget) Calls() int {
+	if b == nil {
+		return 0
+	}
+	return int(b.calls.Load())
+}
+
+// Spent returns the total duration of the finished calls
+func (b *Budget) Spent() time.Duration {
+	if b == nil {
+		return 0
+	}
+	return time.Duration(b.spent.Load())
+}
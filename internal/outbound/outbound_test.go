@@ -0,0 +1,83 @@
+package outbound
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBudget_Acquire(t *testing.T) {
+	b := NewBudget(2, 0)
+
+	for i := range 2 {
+		if err := b.Acquire("http"); err != nil {
+			t.Fatalf("call %d: expected no error, got %v", i+1, err)
+		}
+	}
+
+	err := b.Acquire("email")
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Integration != "email" || limitErr.Limit != 2 {
+		t.Fatalf("expected LimitError for email, got %v", err)
+	}
+	if b.Calls() != 2 {
+		t.Errorf("expected rejected calls not to be counted, got %d calls", b.Calls())
+	}
+}
+
+func TestBudget_Unlimited(t *testing.T) {
+	b := NewBudget(0, 0)
+	if b != nil {
+		t.Fatal("expected no budget without a limit")
+	}
+	for range 100 {
+		if err := b.Acquire("ai"); err != nil {
+			t.Fatalf("expected a nil budget to allow every call, got %v", err)
+		}
+	}
+}
+
+func TestBudget_Concurrent(t *testing.T) {
+	b := NewBudget(10, 0)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 50 {
+		wg.Go(func() {
+			if b.Acquire("http") == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if allowed != 10 {
+		t.Errorf("expected exactly 10 calls to be allowed, got %d", allowed)
+	}
+}
+
+func TestBudget_TimeBudget(t *testing.T) {
+	b := NewBudget(0, time.Second)
+
+	if err := b.Acquire("http"); err != nil {
+		t.Fatalf("expected the first call to pass, got %v", err)
+	}
+	b.Record(600 * time.Millisecond)
+	if err := b.Acquire("http"); err != nil {
+		t.Fatalf("expected a call within the budget to pass, got %v", err)
+	}
+	b.Record(500 * time.Millisecond)
+
+	err := b.Acquire("ai")
+	var budgetErr *TimeBudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Integration != "ai" || budgetErr.Budget != time.Second {
+		t.Fatalf("expected TimeBudgetError once the time is spent, got %v", err)
+	}
+	if b.Calls() != 2 || b.Spent() != 1100*time.Millisecond {
+		t.Errorf("expected 2 calls taking 1.1s, got %d calls taking %s", b.Calls(), b.Spent())
+	}
+}
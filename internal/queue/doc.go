@@ -0,0 +1,6 @@
+// Package queue bounds how many function executions run at the same time.
+//
+// Executions beyond the concurrency limit wait in a bounded queue for a free
+// slot. Once the queue is full new executions are rejected, so the server sheds
+// load with 503 Service Unavailable instead of piling up requests.
+package queue
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// DefaultMaxQueued is the number of executions allowed to wait when none is configured
+const DefaultMaxQueued = 100
+
+// ErrFull is returned when the execution queue has no room left
+var ErrFull = errors.New("execution queue is full")
+
+// Stats is a snapshot of the queue
+type Stats struct {
+	Running       int64 // Executions holding a slot
+	Queued        int64 // Executions waiting for a slot
+	MaxConcurrent int   // Zero means unlimited
+	MaxQueued     int
+	Rejected      int64 // Executions rejected since startup because the queue was full
+}
+
+// Queue limits concurrent executions and is safe for concurrent use.
+// A nil *Queue never limits executions.
+type Queue struct {
+	slots     chan struct{}
+	maxQueued int
+
+	running  atomic.Int64
+	queued   atomic.Int64
+	rejected atomic.Int64
+}
+
+// New creates a Queue running at most maxConcurrent executions with at most
+// maxQueued waiting. A zero maxConcurrent disables the limit.
+func New(maxConcurrent, maxQueued int) *Queue {
+	q := &Queue{maxQueued: max(maxQueued, 0)}
+	if maxConcurrent > 0 {
+		q.slots = make(chan struct{}, maxConcurrent)
+	}
+	return q
+}
+
+// Acquire waits for an execution slot. It returns ErrFull without waiting when
+// the queue is full, or the context error when ctx is done first.
+// The returned function releases the slot and must be called exactly once.
+func (q *Queue) Acquire(ctx context.Context) (release func(), err error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	if q.slots == nil {
+		q.running.Add(1)
+		return q.release, nil
+	}
+
+	select {
+	case q.slots <- struct{}{}:
+		q.running.Add(1)
+		return q.release, nil
+	default:
+	}
+
+	if q.queued.Add(1) > int64(q.maxQueued) {
+		q.queued.Add(-1)
+		q.rejected.Add(1)
+		return nil, ErrFull
+	}
+	defer q.queued.Add(-1)
+
+	select {
+	case q.slots <- struct{}{}:
+		q.running.Add(1)
+		return q.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release frees a slot taken by Acquire
+func (q *Queue) release() {
+	q.running.Add(-1)
+	if q.slots != nil {
+		<-q.slots
+	}
+}
+
+// Stats returns the current queue depth and limits
+func (q *Queue) Stats() Stats {
+	if q == nil {
+		return Stats{}
+	}
+	return Stats{
+		Running:       q.running.Load(),
+		Queued:        q.queued.Load(),
+		MaxConcurrent: cap(q.slots),
+		MaxQueued:     q.maxQueued,
+		Rejected:      q.rejected.Load(),
+	}
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueue_RejectsWhenFull(t *testing.T) {
+	q := New(1, 1)
+
+	release, err := q.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected first execution to run, got %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		waiting, err := q.Acquire(context.Background())
+		if err != nil {
+			t.Errorf("expected second execution to wait, got %v", err)
+		}
+		acquired <- waiting
+	}()
+
+	waitFor(t, func() bool { return q.Stats().Queued == 1 })
+
+	if _, err := q.Acquire(context.Background()); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+
+	stats := q.Stats()
+	if stats.Running != 1 || stats.Queued != 1 || stats.Rejected != 1 || stats.MaxConcurrent != 1 || stats.MaxQueued != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	release()
+	waiting := <-acquired
+	if stats := q.Stats(); stats.Running != 1 || stats.Queued != 0 {
+		t.Errorf("expected queued execution to take the slot, got %+v", stats)
+	}
+	waiting()
+
+	if stats := q.Stats(); stats.Running != 0 {
+		t.Errorf("expected no running executions, got %+v", stats)
+	}
+}
+
+func TestQueue_ContextCancelled(t *testing.T) {
+	q := New(1, 5)
+	release, _ := q.Acquire(context.Background())
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := q.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if stats := q.Stats(); stats.Queued != 0 {
+		t.Errorf("expected cancelled execution to leave the queue, got %+v", stats)
+	}
+}
+
+func TestQueue_Unlimited(t *testing.T) {
+	q := New(0, 0)
+	for range 10 {
+		if _, err := q.Acquire(context.Background()); err != nil {
+			t.Fatalf("expected unlimited queue to accept, got %v", err)
+		}
+	}
+	if stats := q.Stats(); stats.Running != 10 || stats.MaxConcurrent != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestQueue_Nil(t *testing.T) {
+	var q *Queue
+	release, err := q.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil queue to accept, got %v", err)
+	}
+	release()
+	if q.Stats() != (Stats{}) {
+		t.Errorf("expected empty stats, got %+v", q.Stats())
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
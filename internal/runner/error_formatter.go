@@ -129,6 +129,7 @@ func detectErrorPattern(errMsg string) string {
 		{`attempt to index a non-table`, "non_table_index"},
 		{`attempt to call.*nil`, "nil_call"},
 		{`bad argument.*expected.*got`, "bad_argument"},
+		{`\w+\.\w+ expects .*, got \w+`, "bad_argument"},
 		{`unexpected symbol`, "syntax_error"},
 		{`syntax error`, "syntax_error"},
 		{`'end' expected`, "missing_end"},
@@ -471,6 +471,7 @@ func TestDetectErrorPattern(t *testing.T) {
 		{"attempt to index a non-table value", "non_table_index"},
 		{"attempt to call a nil value", "nil_call"},
 		{"bad argument #1 expected string got number", "bad_argument"},
+		{"crypto.sha256 expects a string, got table", "bad_argument"},
 		{"unexpected symbol near '='", "syntax_error"},
 		{"'end' expected to close 'if'", "missing_end"},
 		{"attempt to perform arithmetic on nil", "arithmetic_error"},
@@ -1,14 +1,23 @@
 package runner
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/dimiro1/lunar/internal/services/ai"
 	stdlibai "github.com/dimiro1/lunar/internal/runtime/ai"
+	"github.com/dimiro1/lunar/internal/services/kv"
+	"github.com/rs/xid"
 	lua "github.com/yuin/gopher-lua"
 )
 
 // registerAI creates the global 'ai' table with AI provider functions.
 // This is a thin wrapper using the stdlib/ai TrackedClient decorator.
-func registerAI(L *lua.LState, client ai.Client, functionID string, tracker ai.Tracker, executionID string) {
+// Transient provider errors are retried according to retry, tracking every attempt.
+// Conversation history is kept in the function's KV store.
+// Calls that leave out the provider or model use the function's defaults.
+func registerAI(L *lua.LState, ctx context.Context, client ai.Client, functionID string, tracker ai.Tracker, executionID string, retry ai.RetryPolicy, kvStore kv.Store, defaults ai.Defaults) {
 	trackedClient := stdlibai.NewTrackedClient(client, tracker, executionID)
 
 	aiTable := L.NewTable()
@@ -18,23 +27,70 @@ func registerAI(L *lua.LState, client ai.Client, functionID string, tracker ai.T
 		options := L.CheckTable(1)
 
 		// Extract and validate parameters
-		req, errMsg := parseAIChatRequest(options)
+		req, errMsg := parseAIChatRequest(L, options, defaults)
+		if errMsg != "" {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(errMsg))
+			return 2
+		}
+
+		policy, errMsg := parseAIRetryPolicy(options, retry)
 		if errMsg != "" {
 			L.Push(lua.LNil)
 			L.Push(lua.LString(errMsg))
 			return 2
 		}
 
-		// Execute with automatic tracking via decorator
-		result := trackedClient.ChatWithTracking(functionID, req)
+		// Execute with retries and automatic tracking via decorators
+		response, err := ai.NewRetryingClient(ctx, trackedClient, policy).Chat(functionID, req)
 
-		if result.Error != nil {
+		if err != nil {
 			L.Push(lua.LNil)
-			L.Push(lua.LString(result.Error.Error()))
+			L.Push(lua.LString(err.Error()))
 			return 2
 		}
 
-		L.Push(aiResponseToLuaTable(L, result.Response))
+		L.Push(aiResponseToLuaTable(L, response))
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	// ai.conversation(id, options)
+	L.SetField(aiTable, "conversation", L.NewFunction(func(L *lua.LState) int {
+		id := L.OptString(1, "")
+		options := L.CheckTable(2)
+
+		if id == "" {
+			id = xid.New().String()
+		}
+
+		// Extract and validate parameters
+		req, message, opts, errMsg := parseAIConversationRequest(L, options, defaults)
+		if errMsg != "" {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(errMsg))
+			return 2
+		}
+
+		policy, errMsg := parseAIRetryPolicy(options, retry)
+		if errMsg != "" {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(errMsg))
+			return 2
+		}
+
+		// Send the turn with retries and tracking; the history is loaded and saved in KV
+		conversation := stdlibai.NewConversation(kvStore, functionID, id)
+		response, err := conversation.Send(ai.NewRetryingClient(ctx, trackedClient, policy), req, message, opts)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+
+		result := aiResponseToLuaTable(L, response)
+		L.SetField(result, "conversation_id", lua.LString(id))
+		L.Push(result)
 		L.Push(lua.LNil)
 		return 2
 	}))
@@ -43,26 +99,45 @@ func registerAI(L *lua.LState, client ai.Client, functionID string, tracker ai.T
 }
 
 // parseAIChatRequest extracts ai.ChatRequest from Lua options table
-func parseAIChatRequest(options *lua.LTable) (ai.ChatRequest, string) {
-	provider := lua.LVAsString(options.RawGetString("provider"))
-	model := lua.LVAsString(options.RawGetString("model"))
-	messagesLV := options.RawGetString("messages")
-
-	// Validate required parameters
-	if provider == "" {
-		return ai.ChatRequest{}, "provider is required (openai or anthropic)"
-	}
-	if model == "" {
-		return ai.ChatRequest{}, "model is required"
+func parseAIChatRequest(L *lua.LState, options *lua.LTable, defaults ai.Defaults) (ai.ChatRequest, string) {
+	req, errMsg := parseAIChatOptions(L, options, defaults)
+	if errMsg != "" {
+		return ai.ChatRequest{}, errMsg
 	}
+
+	messagesLV := options.RawGetString("messages")
 	if messagesLV.Type() != lua.LTTable {
 		return ai.ChatRequest{}, "messages is required and must be a table"
 	}
 
 	// Convert messages from Lua to Go
-	messages := luaMessagesToGo(messagesLV.(*lua.LTable))
-	if len(messages) == 0 {
-		return ai.ChatRequest{}, "messages cannot be empty"
+	messages, errMsg := luaMessagesToGo(messagesLV.(*lua.LTable))
+	if errMsg != "" {
+		return ai.ChatRequest{}, errMsg
+	}
+	if err := ai.ValidateMessages(messages); err != nil {
+		return ai.ChatRequest{}, err.Error()
+	}
+
+	req.Messages = messages
+	return req, ""
+}
+
+// parseAIChatOptions extracts the provider, model and optional chat
+// parameters shared by ai.chat and ai.conversation
+func parseAIChatOptions(L *lua.LState, options *lua.LTable, defaults ai.Defaults) (ai.ChatRequest, string) {
+	target := ai.ChatRequest{
+		Provider: lua.LVAsString(options.RawGetString("provider")),
+		Model:    lua.LVAsString(options.RawGetString("model")),
+	}
+	defaults.Apply(&target)
+
+	// Validate required parameters
+	if target.Provider == "" {
+		return ai.ChatRequest{}, "provider is required (openai or anthropic)"
+	}
+	if target.Model == "" {
+		return ai.ChatRequest{}, "model is required"
 	}
 
 	// Extract optional parameters
@@ -75,31 +150,95 @@ func parseAIChatRequest(options *lua.LTable) (ai.ChatRequest, string) {
 		maxTokens = 1024
 	}
 
+	var responseFormat ai.ResponseFormat
+	if formatLV := options.RawGetString("response_format"); formatLV != lua.LNil {
+		formatTbl, ok := formatLV.(*lua.LTable)
+		if !ok {
+			return ai.ChatRequest{}, "response_format must be a table"
+		}
+		format, _ := luaValueToGo(L, formatTbl).(map[string]any)
+		responseFormat = format
+		if err := responseFormat.Validate(); err != nil {
+			return ai.ChatRequest{}, err.Error()
+		}
+	}
+
 	return ai.ChatRequest{
-		Provider:    provider,
-		Model:       model,
-		Messages:    messages,
-		MaxTokens:   maxTokens,
-		Temperature: float64(temperature),
-		Endpoint:    endpoint,
+		Provider:       target.Provider,
+		Model:          target.Model,
+		MaxTokens:      maxTokens,
+		Temperature:    float64(temperature),
+		ResponseFormat: responseFormat,
+		Endpoint:       endpoint,
 	}, ""
 }
 
-// luaMessagesToGo converts a Lua table of messages to Go
-func luaMessagesToGo(tbl *lua.LTable) []ai.Message {
-	var messages []ai.Message
-	tbl.ForEach(func(_, v lua.LValue) {
-		if msgTbl, ok := v.(*lua.LTable); ok {
-			msg := ai.Message{
-				Role:    lua.LVAsString(msgTbl.RawGetString("role")),
-				Content: lua.LVAsString(msgTbl.RawGetString("content")),
-			}
-			if msg.Role != "" && msg.Content != "" {
-				messages = append(messages, msg)
-			}
+// parseAIConversationRequest extracts the chat options, the user message and
+// the history options of an ai.conversation turn
+func parseAIConversationRequest(L *lua.LState, options *lua.LTable, defaults ai.Defaults) (ai.ChatRequest, string, stdlibai.ConversationOptions, string) {
+	opts := stdlibai.ConversationOptions{
+		System:      lua.LVAsString(options.RawGetString("system")),
+		MaxMessages: stdlibai.DefaultMaxHistoryMessages,
+	}
+
+	req, errMsg := parseAIChatOptions(L, options, defaults)
+	if errMsg != "" {
+		return ai.ChatRequest{}, "", opts, errMsg
+	}
+
+	message, ok := options.RawGetString("message").(lua.LString)
+	if !ok || strings.TrimSpace(string(message)) == "" {
+		return ai.ChatRequest{}, "", opts, "message is required and must be a non-empty string"
+	}
+
+	if lv := options.RawGetString("max_history"); lv != lua.LNil {
+		n, ok := lv.(lua.LNumber)
+		if !ok || n < 2 || n > stdlibai.MaxHistoryMessagesLimit || n != lua.LNumber(int(n)) {
+			return ai.ChatRequest{}, "", opts, fmt.Sprintf("max_history must be an integer between 2 and %d", stdlibai.MaxHistoryMessagesLimit)
+		}
+		opts.MaxMessages = int(n)
+	}
+
+	if lv := options.RawGetString("max_history_tokens"); lv != lua.LNil {
+		n, ok := lv.(lua.LNumber)
+		if !ok || n < 0 || n != lua.LNumber(int(n)) {
+			return ai.ChatRequest{}, "", opts, "max_history_tokens must be a non-negative integer"
+		}
+		opts.MaxHistoryTokens = int(n)
+	}
+
+	return req, string(message), opts, ""
+}
+
+// parseAIRetryPolicy applies the optional max_retries option to the default retry policy
+func parseAIRetryPolicy(options *lua.LTable, policy ai.RetryPolicy) (ai.RetryPolicy, string) {
+	maxRetries := options.RawGetString("max_retries")
+	if maxRetries == lua.LNil {
+		return policy, ""
+	}
+
+	n, ok := maxRetries.(lua.LNumber)
+	if !ok || n < 0 || n > ai.MaxRetriesLimit || n != lua.LNumber(int(n)) {
+		return policy, fmt.Sprintf("max_retries must be an integer between 0 and %d", ai.MaxRetriesLimit)
+	}
+	policy.MaxRetries = int(n)
+	return policy, ""
+}
+
+// luaMessagesToGo converts a Lua array of messages to Go, in order
+func luaMessagesToGo(tbl *lua.LTable) ([]ai.Message, string) {
+	messages := make([]ai.Message, 0, tbl.Len())
+	for i := 1; i <= tbl.Len(); i++ {
+		msgTbl, ok := tbl.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			return nil, fmt.Sprintf("messages[%d] must be a table", i)
 		}
-	})
-	return messages
+		messages = append(messages, ai.Message{
+			Role:    lua.LVAsString(msgTbl.RawGetString("role")),
+			Content: lua.LVAsString(msgTbl.RawGetString("content")),
+		})
+	}
+	return messages, ""
 }
 
 // aiResponseToLuaTable converts an AI response to a Lua table
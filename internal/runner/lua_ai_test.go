@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -275,6 +276,8 @@ end
 	}
 }
 
+// TestRun_AI_MissingProvider checks that the provider is required when the
+// function has no default provider configured
 func TestRun_AI_MissingProvider(t *testing.T) {
 	envStore := env.NewMemoryStore()
 	deps := Dependencies{
@@ -1017,3 +1020,506 @@ end
 		t.Errorf("expected error about empty messages, got: %s", resp.HTTP.Body)
 	}
 }
+
+func TestRun_AI_RetriesTransientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"message": "Rate limit exceeded"}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model":   "gpt-4o-mini",
+			"choices": []map[string]any{{"message": map[string]any{"content": "Hello!"}}},
+			"usage":   map[string]any{"prompt_tokens": 3, "completion_tokens": 2},
+		})
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "OPENAI_API_KEY", "test-api-key")
+	tracker := ai.NewMemoryTracker()
+
+	deps := Dependencies{
+		Logger:    logger.NewMemoryLogger(),
+		KV:        kv.NewMemoryStore(),
+		Env:       envStore,
+		HTTP:      internalhttp.NewDefaultClient(),
+		AI:        ai.NewDefaultClient(internalhttp.NewDefaultClient(), envStore),
+		AITracker: tracker,
+		AIRetry:   ai.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-retry",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local response, err = ai.chat({
+		provider = "openai",
+		model = "gpt-4o-mini",
+		messages = {{role = "user", content = "Hello!"}},
+		endpoint = "` + server.URL + `"
+	})
+	if err then
+		return { statusCode = 500, body = err }
+	end
+	return { statusCode = 200, body = response.content }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.StatusCode != 200 || resp.HTTP.Body != "Hello!" {
+		t.Fatalf("expected the retried request to succeed, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+
+	requests := tracker.Requests("exec-retry")
+	if len(requests) != 2 {
+		t.Fatalf("expected both attempts to be tracked, got %d", len(requests))
+	}
+	if requests[0].Status != "error" || requests[1].Status != "success" {
+		t.Errorf("expected an error then a success, got %s then %s", requests[0].Status, requests[1].Status)
+	}
+}
+
+func TestRun_AI_InvalidMaxRetries(t *testing.T) {
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    env.NewMemoryStore(),
+		HTTP:   internalhttp.NewDefaultClient(),
+		AI:     ai.NewDefaultClient(internalhttp.NewDefaultClient(), env.NewMemoryStore()),
+	}
+
+	execCtx := &events.ExecutionContext{ExecutionID: "exec-123", FunctionID: "test-function"}
+
+	luaCode := `
+function handler(ctx, event)
+	local _, err = ai.chat({
+		provider = "openai",
+		model = "gpt-4o-mini",
+		messages = {{role = "user", content = "Hello!"}},
+		max_retries = 50
+	})
+	return { statusCode = 400, body = err }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(resp.HTTP.Body, "max_retries must be an integer between 0 and 10") {
+		t.Errorf("expected max_retries validation error, got: %s", resp.HTTP.Body)
+	}
+}
+
+func TestRun_AI_InvalidMessages(t *testing.T) {
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "OPENAI_API_KEY", "test-key")
+
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    envStore,
+		HTTP:   internalhttp.NewDefaultClient(),
+		AI:     ai.NewDefaultClient(internalhttp.NewDefaultClient(), envStore),
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	tests := []struct {
+		name     string
+		messages string
+		wantErr  string
+	}{
+		{
+			name:     "invalid role",
+			messages: `{{role = "user", content = "Hi"}, {role = "bot", content = "Hello"}}`,
+			wantErr:  `messages[2].role invalid: "bot" (use system, user or assistant)`,
+		},
+		{
+			name:     "missing content",
+			messages: `{{role = "user"}}`,
+			wantErr:  "messages[1].content is required",
+		},
+		{
+			name:     "message is not a table",
+			messages: `{{role = "user", content = "Hi"}, "hello"}`,
+			wantErr:  "messages[2] must be a table",
+		},
+		{
+			name:     "system prompt after conversation",
+			messages: `{{role = "user", content = "Hi"}, {role = "system", content = "Be brief"}}`,
+			wantErr:  "messages[2].role invalid: system messages must come before user and assistant messages",
+		},
+		{
+			name:     "only system prompt",
+			messages: `{{role = "system", content = "Be brief"}}`,
+			wantErr:  "messages must include at least one user or assistant message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			luaCode := `
+function handler(ctx, event)
+	local response, err = ai.chat({
+		provider = "openai",
+		model = "gpt-4o-mini",
+		messages = ` + tt.messages + `
+	})
+	if err then
+		return { statusCode = 400, body = err }
+	end
+	return { statusCode = 200 }
+end
+`
+
+			resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if resp.HTTP.StatusCode != 400 {
+				t.Errorf("expected status 400, got %d", resp.HTTP.StatusCode)
+			}
+			if resp.HTTP.Body != tt.wantErr {
+				t.Errorf("expected error %q, got %q", tt.wantErr, resp.HTTP.Body)
+			}
+		})
+	}
+}
+
+func TestRun_AI_OpenAI_WithResponseFormat(t *testing.T) {
+	var receivedFormat map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var reqBody map[string]any
+		_ = json.Unmarshal(body, &reqBody)
+
+		receivedFormat, _ = reqBody["response_format"].(map[string]any)
+
+		resp := map[string]any{
+			"id":    "chatcmpl-123",
+			"model": "gpt-4o-mini",
+			"choices": []map[string]any{
+				{"message": map[string]any{"content": `{"name":"Ada"}`}},
+			},
+			"usage": map[string]any{
+				"prompt_tokens":     5,
+				"completion_tokens": 4,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "OPENAI_API_KEY", "test-api-key")
+
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    envStore,
+		HTTP:   internalhttp.NewDefaultClient(),
+		AI:     ai.NewDefaultClient(internalhttp.NewDefaultClient(), envStore),
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	event := events.HTTPEvent{
+		Method: "POST",
+		Path:   "/chat",
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local response, err = ai.chat({
+		provider = "openai",
+		model = "gpt-4o-mini",
+		messages = {
+			{role = "user", content = "Extract the name: Ada Lovelace"}
+		},
+		response_format = {
+			type = "json_schema",
+			json_schema = {
+				name = "person",
+				schema = {type = "object", properties = {name = {type = "string"}}}
+			}
+		},
+		endpoint = "` + server.URL + `"
+	})
+
+	if err then
+		return { statusCode = 500, body = err }
+	end
+
+	return { statusCode = 200, body = json.decode(response.content).name }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: event, Code: luaCode})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if resp.HTTP.StatusCode != 200 || resp.HTTP.Body != "Ada" {
+		t.Errorf("expected status 200 with Ada, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+
+	if receivedFormat["type"] != "json_schema" {
+		t.Fatalf("expected response_format type json_schema, got %v", receivedFormat)
+	}
+	jsonSchema, _ := receivedFormat["json_schema"].(map[string]any)
+	if jsonSchema["name"] != "person" {
+		t.Errorf("expected json_schema name person, got %v", jsonSchema)
+	}
+}
+
+func TestRun_AI_InvalidResponseFormat(t *testing.T) {
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    env.NewMemoryStore(),
+		HTTP:   internalhttp.NewDefaultClient(),
+		AI:     ai.NewDefaultClient(internalhttp.NewDefaultClient(), env.NewMemoryStore()),
+	}
+
+	execCtx := &events.ExecutionContext{ExecutionID: "exec-123", FunctionID: "test-function"}
+
+	tests := map[string]string{
+		`"json"`:                 "response_format must be a table",
+		`{type = "yaml"}`:        `response_format.type invalid: "yaml" (use text, json_object or json_schema)`,
+		`{type = "json_schema"}`: "response_format.json_schema is required for type json_schema",
+		`{strict = true}`:        "response_format.type is required",
+	}
+
+	for format, wantErr := range tests {
+		luaCode := `
+function handler(ctx, event)
+	local _, err = ai.chat({
+		provider = "openai",
+		model = "gpt-4o-mini",
+		messages = {{role = "user", content = "Hello!"}},
+		response_format = ` + format + `
+	})
+	return { statusCode = 400, body = err }
+end
+`
+
+		resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if resp.HTTP.Body != wantErr {
+			t.Errorf("response_format %s: expected error %q, got %q", format, wantErr, resp.HTTP.Body)
+		}
+	}
+}
+
+func TestRun_AI_Conversation(t *testing.T) {
+	var receivedMessages [][]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&reqBody)
+		messages, _ := reqBody["messages"].([]any)
+		receivedMessages = append(receivedMessages, messages)
+
+		resp := map[string]any{
+			"model":   "gpt-4o-mini",
+			"choices": []map[string]any{{"message": map[string]any{"content": "Reply " + strconv.Itoa(len(receivedMessages))}}},
+			"usage":   map[string]any{"prompt_tokens": 5, "completion_tokens": 2},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "OPENAI_API_KEY", "test-api-key")
+	kvStore := kv.NewMemoryStore()
+
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kvStore,
+		Env:    envStore,
+		HTTP:   internalhttp.NewDefaultClient(),
+		AI:     ai.NewDefaultClient(internalhttp.NewDefaultClient(), envStore),
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local first, err = ai.conversation(nil, {
+		provider = "openai",
+		model = "gpt-4o-mini",
+		system = "Be brief",
+		message = "Hello",
+		endpoint = "` + server.URL + `"
+	})
+	if err then
+		return { statusCode = 500, body = err }
+	end
+
+	local second, err = ai.conversation(first.conversation_id, {
+		provider = "openai",
+		model = "gpt-4o-mini",
+		system = "Be brief",
+		message = "And again",
+		endpoint = "` + server.URL + `"
+	})
+	if err then
+		return { statusCode = 500, body = err }
+	end
+
+	return { statusCode = 200, body = second.conversation_id .. "|" .. second.content }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+
+	conversationID, content, _ := strings.Cut(resp.HTTP.Body, "|")
+	if conversationID == "" || content != "Reply 2" {
+		t.Errorf("unexpected result: %s", resp.HTTP.Body)
+	}
+
+	if len(receivedMessages) != 2 || len(receivedMessages[1]) != 4 {
+		t.Fatalf("expected the second turn to send system, history and new message, got %v", receivedMessages)
+	}
+
+	stored, err := kvStore.Get("test-function", "ai:conversation:"+conversationID)
+	if err != nil {
+		t.Fatalf("expected the history to be stored: %v", err)
+	}
+	if !strings.Contains(stored, "Reply 1") || !strings.Contains(stored, "And again") {
+		t.Errorf("unexpected stored history: %s", stored)
+	}
+}
+
+func TestRun_AI_ConversationMissingMessage(t *testing.T) {
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    env.NewMemoryStore(),
+		HTTP:   internalhttp.NewDefaultClient(),
+		AI:     ai.NewDefaultClient(internalhttp.NewDefaultClient(), env.NewMemoryStore()),
+	}
+
+	execCtx := &events.ExecutionContext{ExecutionID: "exec-123", FunctionID: "test-function"}
+
+	luaCode := `
+function handler(ctx, event)
+	local _, err = ai.conversation("chat-1", {provider = "openai", model = "gpt-4o-mini"})
+	return { statusCode = 400, body = err }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.Body != "message is required and must be a non-empty string" {
+		t.Errorf("unexpected error: %s", resp.HTTP.Body)
+	}
+}
+
+func TestRun_AI_FunctionDefaults(t *testing.T) {
+	// Record the model of every request
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&reqBody)
+		models = append(models, reqBody["model"].(string))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model":   reqBody["model"],
+			"choices": []map[string]any{{"message": map[string]any{"content": "ok"}}},
+		})
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "OPENAI_API_KEY", "test-api-key")
+
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    envStore,
+		HTTP:   internalhttp.NewDefaultClient(),
+		AI:     ai.NewDefaultClient(internalhttp.NewDefaultClient(), envStore),
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local messages = {{role = "user", content = "Hello!"}}
+	local _, err = ai.chat({ messages = messages, endpoint = "` + server.URL + `" })
+	if err then
+		return { statusCode = 500, body = err }
+	end
+
+	-- The model can still be overridden per call
+	_, err = ai.chat({ model = "gpt-4o", messages = messages, endpoint = "` + server.URL + `" })
+	if err then
+		return { statusCode = 500, body = err }
+	end
+	return { statusCode = 200 }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{
+		Context:    execCtx,
+		Event:      events.HTTPEvent{Method: "POST", Path: "/chat"},
+		Code:       luaCode,
+		AIDefaults: ai.Defaults{Provider: "openai", Model: "gpt-4o-mini"},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if resp.HTTP.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+	if strings.Join(models, ",") != "gpt-4o-mini,gpt-4o" {
+		t.Errorf("expected models gpt-4o-mini,gpt-4o, got %v", models)
+	}
+}
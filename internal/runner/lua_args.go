@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Argument helpers shared by the stdlib bindings. They coerce values the way
+// Lua itself does (numbers to strings and numeric strings to numbers) and
+// otherwise raise an error naming the binding, e.g.
+// "crypto.sha256 expects a string, got table".
+
+// checkString returns argument n as a string, coercing numbers
+func checkString(L *lua.LState, n int, fn string) string {
+	switch v := L.Get(n).(type) {
+	case lua.LString:
+		return string(v)
+	case lua.LNumber:
+		return v.String()
+	}
+	argTypeError(L, n, fn, "a string")
+	return ""
+}
+
+// optString returns argument n as a string, or def when it is nil or absent
+func optString(L *lua.LState, n int, fn string, def string) string {
+	if L.Get(n) == lua.LNil {
+		return def
+	}
+	return checkString(L, n, fn)
+}
+
+// checkNumber returns argument n as a number, coercing numeric strings
+func checkNumber(L *lua.LState, n int, fn string) lua.LNumber {
+	switch v := L.Get(n).(type) {
+	case lua.LNumber:
+		return v
+	case lua.LString:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64); err == nil {
+			return lua.LNumber(f)
+		}
+	}
+	argTypeError(L, n, fn, "a number")
+	return 0
+}
+
+// checkInt returns argument n as an integer, truncating any fractional part
+func checkInt(L *lua.LState, n int, fn string) int {
+	return int(checkNumber(L, n, fn))
+}
+
+// optInt returns argument n as an integer, or def when it is nil or absent
+func optInt(L *lua.LState, n int, fn string, def int) int {
+	if L.Get(n) == lua.LNil {
+		return def
+	}
+	return checkInt(L, n, fn)
+}
+
+// checkTable returns argument n as a table
+func checkTable(L *lua.LState, n int, fn string) *lua.LTable {
+	if tbl, ok := L.Get(n).(*lua.LTable); ok {
+		return tbl
+	}
+	argTypeError(L, n, fn, "a table")
+	return nil
+}
+
+// optTable returns argument n as a table, or nil when it is nil or absent
+func optTable(L *lua.LState, n int, fn string) *lua.LTable {
+	if L.Get(n) == lua.LNil {
+		return nil
+	}
+	return checkTable(L, n, fn)
+}
+
+// argTypeError raises a Lua error describing a bad argument to fn. The
+// position is only mentioned for arguments after the first.
+func argTypeError(L *lua.LState, n int, fn, expected string) {
+	got := L.Get(n).Type().String()
+	if n == 1 {
+		L.RaiseError("%s expects %s, got %s", fn, expected, got)
+		return
+	}
+	L.RaiseError("%s expects %s as argument #%d, got %s", fn, expected, n, got)
+}
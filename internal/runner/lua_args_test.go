@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/runtime/random"
+	stdlibtime "github.com/dimiro1/lunar/internal/runtime/time"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func newStdlibState() *lua.LState {
+	L := lua.NewState()
+	registerJSON(L)
+	registerBase64(L)
+	registerCrypto(L)
+	registerTime(L, stdlibtime.Now)
+	registerURL(L)
+	registerStrings(L)
+	registerRandom(L, random.Secure)
+	return L
+}
+
+func TestStdlibArguments_Misuse(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"table as string", `crypto.sha256({})`, "crypto.sha256 expects a string, got table"},
+		{"missing argument", `base64.encode()`, "base64.encode expects a string, got nil"},
+		{"second argument", `crypto.hmac_sha256("msg", {})`, "crypto.hmac_sha256 expects a string as argument #2, got table"},
+		{"string as table", `strings.join("a,b", ",")`, "strings.join expects a table, got string"},
+		{"non-numeric string", `random.int("one", 10)`, "random.int expects a number, got string"},
+		{"boolean as number", `time.format(true, "2006")`, "time.format expects a number, got boolean"},
+		{"optional table", `crypto.sign_url("/a", "x", "secret", 60)`, "crypto.sign_url expects a table as argument #2, got string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			L := newStdlibState()
+			defer L.Close()
+
+			err := L.DoString(tt.code)
+			if err == nil {
+				t.Fatalf("expected error for %s", tt.code)
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %q", tt.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestStdlibArguments_Coercion(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"number as string", `return crypto.sha256(123) == crypto.sha256("123")`, "true"},
+		{"numeric string as number", `return strings["repeat"]("ab", "2")`, "abab"},
+		{"nil optional argument", `return strings.replace("aaa", "a", "b", nil)`, "bbb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			L := newStdlibState()
+			defer L.Close()
+
+			if err := L.DoString(tt.code); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := L.Get(-1).String(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -19,14 +19,14 @@ func registerBase64(L *lua.LState) {
 // base64Encode encodes a string to base64
 // Usage: local encoded = base64.encode(str)
 func base64Encode(L *lua.LState) int {
-	L.Push(lua.LString(stdlibbase64.Encode(L.CheckString(1))))
+	L.Push(lua.LString(stdlibbase64.Encode(checkString(L, 1, "base64.encode"))))
 	return 1
 }
 
 // base64Decode decodes a base64 string
 // Usage: local decoded, err = base64.decode(str)
 func base64Decode(L *lua.LState) int {
-	decoded, err := stdlibbase64.Decode(L.CheckString(1))
+	decoded, err := stdlibbase64.Decode(checkString(L, 1, "base64.decode"))
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
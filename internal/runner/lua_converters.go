@@ -1,7 +1,12 @@
 package runner
 
 import (
+	"mime"
+	"net/http"
+	"strings"
+
 	"github.com/dimiro1/lunar/internal/events"
+	stdlibjson "github.com/dimiro1/lunar/internal/runtime/json"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -13,6 +18,8 @@ func httpEventToLuaTable(L *lua.LState, event events.HTTPEvent) *lua.LTable {
 	L.SetField(tbl, "path", lua.LString(event.Path))
 	L.SetField(tbl, "relativePath", lua.LString(event.RelativePath))
 	L.SetField(tbl, "body", lua.LString(event.Body))
+	L.SetField(tbl, "remote_ip", lua.LString(event.RemoteIP))
+	L.SetField(tbl, "scheme", lua.LString(event.Scheme))
 
 	// Convert headers to Lua table
 	headersTbl := L.NewTable()
@@ -28,9 +35,125 @@ func httpEventToLuaTable(L *lua.LState, event events.HTTPEvent) *lua.LTable {
 	}
 	L.SetField(tbl, "query", queryTbl)
 
+	L.SetField(tbl, "headers_all", valuesToLuaTable(L, event.HeadersAll, event.Headers))
+	L.SetField(tbl, "query_all", valuesToLuaTable(L, event.QueryAll, event.Query))
+
+	setLazyFields(L, tbl, event)
+
+	return tbl
+}
+
+// valuesToLuaTable converts multi-value headers or query parameters to a Lua
+// table of arrays. Events built without them, such as manual runs, fall back
+// to a single value per name from first.
+func valuesToLuaTable(L *lua.LState, all map[string][]string, first map[string]string) *lua.LTable {
+	tbl := L.NewTable()
+	if all == nil {
+		for k, v := range first {
+			list := L.NewTable()
+			list.Append(lua.LString(v))
+			L.SetField(tbl, k, list)
+		}
+		return tbl
+	}
+	for k, values := range all {
+		list := L.NewTable()
+		for _, v := range values {
+			list.Append(lua.LString(v))
+		}
+		L.SetField(tbl, k, list)
+	}
 	return tbl
 }
 
+// setLazyFields exposes computed event fields through a metatable, keeping
+// them out of the table itself and of json.encode(event):
+//   - event.json and event.json_error: when the request has a JSON content
+//     type, the body is decoded the first time either field is read;
+//     event.json is nil and event.json_error holds the message if decoding fails.
+//   - event.basic_auth(): the username and password of an "Authorization: Basic"
+//     header and whether the header was present and well formed. It reads the
+//     request's real header, which is only masked when the event is stored.
+func setLazyFields(L *lua.LState, tbl *lua.LTable, event events.HTTPEvent) {
+	isJSON := isJSONContentType(headerValue(event.Headers, "Content-Type"))
+	basicAuth := L.NewFunction(func(L *lua.LState) int {
+		username, password, ok := parseBasicAuth(headerValue(event.Headers, "Authorization"))
+		if !ok {
+			L.Push(lua.LNil)
+			L.Push(lua.LNil)
+			L.Push(lua.LFalse)
+			return 3
+		}
+		L.Push(lua.LString(username))
+		L.Push(lua.LString(password))
+		L.Push(lua.LTrue)
+		return 3
+	})
+
+	parsed := false
+	var value, parseErr lua.LValue = lua.LNil, lua.LNil
+
+	mt := L.NewTable()
+	L.SetField(mt, "__index", L.NewFunction(func(L *lua.LState) int {
+		key := L.CheckString(2)
+		if key == "basic_auth" {
+			L.Push(basicAuth)
+			return 1
+		}
+		if !isJSON || (key != "json" && key != "json_error") {
+			L.Push(lua.LNil)
+			return 1
+		}
+
+		if !parsed {
+			parsed = true
+			goValue, err := stdlibjson.Decode(event.Body)
+			if err != nil {
+				parseErr = lua.LString(err.Error())
+			} else {
+				value = goValueToLua(L, goValue)
+			}
+		}
+
+		if key == "json" {
+			L.Push(value)
+		} else {
+			L.Push(parseErr)
+		}
+		return 1
+	}))
+	L.SetMetatable(tbl, mt)
+}
+
+// parseBasicAuth parses an HTTP Basic Authorization header value
+func parseBasicAuth(header string) (username, password string, ok bool) {
+	req := http.Request{Header: http.Header{"Authorization": {header}}}
+	return req.BasicAuth()
+}
+
+// headerValue returns the value of a header using a case-insensitive lookup
+func headerValue(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// isJSONContentType reports whether a Content-Type header denotes JSON,
+// including structured syntax suffixes such as application/problem+json
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
 // contextToLuaTable converts an ExecutionContext to a Lua table
 func contextToLuaTable(L *lua.LState, ctx *events.ExecutionContext) *lua.LTable {
 	tbl := L.NewTable()
@@ -89,5 +212,10 @@ func luaTableToHTTPResponse(_ *lua.LState, tbl *lua.LTable) events.HTTPResponse
 		response.IsBase64Encoded = lua.LVAsBool(isBase64)
 	}
 
+	// Get etag
+	if etag := tbl.RawGetString("etag"); etag != lua.LNil {
+		response.ETag = lua.LVAsString(etag)
+	}
+
 	return response
 }
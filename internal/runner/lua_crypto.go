@@ -1,11 +1,14 @@
 package runner
 
 import (
+	"net/url"
+	"time"
+
 	"github.com/dimiro1/lunar/internal/runtime/crypto"
 	lua "github.com/yuin/gopher-lua"
 )
 
-// registerCrypto registers the crypto module with hashing and UUID functions.
+// registerCrypto registers the crypto module with hashing, UUID and signed URL functions.
 // This is a thin wrapper around the stdlib/crypto package.
 func registerCrypto(L *lua.LState) {
 	cryptoModule := L.NewTable()
@@ -24,55 +27,59 @@ func registerCrypto(L *lua.LState) {
 	// UUID function
 	L.SetField(cryptoModule, "uuid", L.NewFunction(cryptoUUID))
 
+	// Signed URL functions
+	L.SetField(cryptoModule, "sign_url", L.NewFunction(cryptoSignURL))
+	L.SetField(cryptoModule, "verify_url", L.NewFunction(cryptoVerifyURL))
+
 	L.SetGlobal("crypto", cryptoModule)
 }
 
 // cryptoMD5 computes MD5 hash of a string
 // Usage: local hash = crypto.md5(str)
 func cryptoMD5(L *lua.LState) int {
-	L.Push(lua.LString(crypto.MD5(L.CheckString(1))))
+	L.Push(lua.LString(crypto.MD5(checkString(L, 1, "crypto.md5"))))
 	return 1
 }
 
 // cryptoSHA1 computes SHA1 hash of a string
 // Usage: local hash = crypto.sha1(str)
 func cryptoSHA1(L *lua.LState) int {
-	L.Push(lua.LString(crypto.SHA1(L.CheckString(1))))
+	L.Push(lua.LString(crypto.SHA1(checkString(L, 1, "crypto.sha1"))))
 	return 1
 }
 
 // cryptoSHA256 computes SHA256 hash of a string
 // Usage: local hash = crypto.sha256(str)
 func cryptoSHA256(L *lua.LState) int {
-	L.Push(lua.LString(crypto.SHA256(L.CheckString(1))))
+	L.Push(lua.LString(crypto.SHA256(checkString(L, 1, "crypto.sha256"))))
 	return 1
 }
 
 // cryptoSHA512 computes SHA512 hash of a string
 // Usage: local hash = crypto.sha512(str)
 func cryptoSHA512(L *lua.LState) int {
-	L.Push(lua.LString(crypto.SHA512(L.CheckString(1))))
+	L.Push(lua.LString(crypto.SHA512(checkString(L, 1, "crypto.sha512"))))
 	return 1
 }
 
 // cryptoHMACSHA1 computes HMAC-SHA1 of a message with a secret key
 // Usage: local hash = crypto.hmac_sha1(message, key)
 func cryptoHMACSHA1(L *lua.LState) int {
-	L.Push(lua.LString(crypto.HMACSHA1(L.CheckString(1), L.CheckString(2))))
+	L.Push(lua.LString(crypto.HMACSHA1(checkString(L, 1, "crypto.hmac_sha1"), checkString(L, 2, "crypto.hmac_sha1"))))
 	return 1
 }
 
 // cryptoHMACSHA256 computes HMAC-SHA256 of a message with a secret key
 // Usage: local hash = crypto.hmac_sha256(message, key)
 func cryptoHMACSHA256(L *lua.LState) int {
-	L.Push(lua.LString(crypto.HMACSHA256(L.CheckString(1), L.CheckString(2))))
+	L.Push(lua.LString(crypto.HMACSHA256(checkString(L, 1, "crypto.hmac_sha256"), checkString(L, 2, "crypto.hmac_sha256"))))
 	return 1
 }
 
 // cryptoHMACSHA512 computes HMAC-SHA512 of a message with a secret key
 // Usage: local hash = crypto.hmac_sha512(message, key)
 func cryptoHMACSHA512(L *lua.LState) int {
-	L.Push(lua.LString(crypto.HMACSHA512(L.CheckString(1), L.CheckString(2))))
+	L.Push(lua.LString(crypto.HMACSHA512(checkString(L, 1, "crypto.hmac_sha512"), checkString(L, 2, "crypto.hmac_sha512"))))
 	return 1
 }
 
@@ -82,3 +89,63 @@ func cryptoUUID(L *lua.LState) int {
 	L.Push(lua.LString(crypto.UUID()))
 	return 1
 }
+
+// cryptoSignURL signs a URL with an expiry, valid for ttl seconds
+// Usage: local url, err = crypto.sign_url(path, params, secret, ttl)
+func cryptoSignURL(L *lua.LState) int {
+	path := checkString(L, 1, "crypto.sign_url")
+	paramsTable := optTable(L, 2, "crypto.sign_url")
+	secret := checkString(L, 3, "crypto.sign_url")
+	ttl := checkNumber(L, 4, "crypto.sign_url")
+
+	params := make(map[string]string)
+	if paramsTable != nil {
+		paramsTable.ForEach(func(key, value lua.LValue) {
+			params[lua.LVAsString(key)] = lua.LVAsString(value)
+		})
+	}
+
+	signed, err := crypto.SignURL(path, params, secret, time.Duration(float64(ttl)*float64(time.Second)), time.Now())
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LString(signed))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// cryptoVerifyURL checks the signature and expiry of a URL produced by sign_url.
+// The optional query table is merged into the URL, which lets handlers verify
+// event.path together with event.query.
+// Usage: local ok, reason = crypto.verify_url(url, secret [, query])
+func cryptoVerifyURL(L *lua.LState) int {
+	rawURL := checkString(L, 1, "crypto.verify_url")
+	secret := checkString(L, 2, "crypto.verify_url")
+
+	if queryTable := optTable(L, 3, "crypto.verify_url"); queryTable != nil {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString(crypto.ReasonMalformedURL))
+			return 2
+		}
+		query := u.Query()
+		queryTable.ForEach(func(key, value lua.LValue) {
+			query.Set(lua.LVAsString(key), lua.LVAsString(value))
+		})
+		u.RawQuery = query.Encode()
+		rawURL = u.String()
+	}
+
+	ok, reason := crypto.VerifyURL(rawURL, secret, time.Now())
+	if !ok {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(reason))
+		return 2
+	}
+	L.Push(lua.LTrue)
+	L.Push(lua.LNil)
+	return 2
+}
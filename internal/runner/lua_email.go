@@ -1,6 +1,8 @@
 package runner
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/dimiro1/lunar/internal/services/email"
@@ -10,10 +12,48 @@ import (
 
 // registerEmail creates the global 'email' table with email sending functions.
 // This is a thin wrapper using the stdlib/email TrackedClient decorator.
-func registerEmail(L *lua.LState, emailClient email.Client, functionID string, emailTracker email.Tracker, executionID string) {
+// Sends that leave out the sender use the function's default from address.
+// Transient provider errors are retried within the execution deadline, and
+// every attempt is tracked.
+func registerEmail(L *lua.LState, ctx context.Context, emailClient email.Client, functionID string, emailTracker email.Tracker, templates email.TemplateStore, executionID string, retry email.RetryPolicy, defaults email.Defaults, allowlists ...email.SenderAllowlist) {
 	// Create tracked client (decorator pattern)
 	trackedClient := stdlibemail.NewTrackedClient(emailClient, emailTracker, executionID)
 
+	// send validates and sends a parsed request, pushing the Lua results
+	send := func(L *lua.LState, options *lua.LTable, req email.SendRequest) int {
+		defaults.Apply(&req)
+
+		// Validate using reusable validation
+		if validationErr := stdlibemail.ValidateSendRequest(req, allowlists...); validationErr != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(validationErr.Error()))
+			return 2
+		}
+
+		policy, errMsg := parseEmailRetryPolicy(options, retry)
+		if errMsg != "" {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(errMsg))
+			return 2
+		}
+
+		// Send with retries and automatic tracking via decorators
+		resp, err := email.NewRetryingClient(ctx, trackedClient, policy).Send(functionID, req)
+
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+
+		// Convert response to Lua table
+		resultTbl := L.NewTable()
+		L.SetField(resultTbl, "id", lua.LString(resp.ID))
+		L.Push(resultTbl)
+		L.Push(lua.LNil)
+		return 2
+	}
+
 	emailTable := L.NewTable()
 
 	// email.send(options)
@@ -28,33 +68,74 @@ func registerEmail(L *lua.LState, emailClient email.Client, functionID string, e
 			return 2
 		}
 
-		// Validate using reusable validation
-		if validationErr := stdlibemail.ValidateSendRequest(req); validationErr != nil {
+		return send(L, options, req)
+	}))
+
+	// email.send_template(options)
+	// Renders a stored template with options.data; options.subject overrides the template subject
+	L.SetField(emailTable, "send_template", L.NewFunction(func(L *lua.LState) int {
+		options := L.CheckTable(1)
+
+		name := lua.LVAsString(options.RawGetString("template"))
+		if name == "" {
 			L.Push(lua.LNil)
-			L.Push(lua.LString(validationErr.Error()))
+			L.Push(lua.LString("template is required"))
+			return 2
+		}
+		if templates == nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString("email templates are not available"))
 			return 2
 		}
 
-		// Send with automatic tracking via decorator
-		result := trackedClient.SendWithTracking(functionID, req)
+		tmpl, tmplErr := templates.Get(functionID, name)
+		if tmplErr != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(fmt.Sprintf("%s: %s", tmplErr.Error(), name)))
+			return 2
+		}
 
-		if result.Error != nil {
+		req, err := parseEmailSendRequest(options)
+		if err != "" {
 			L.Push(lua.LNil)
-			L.Push(lua.LString(result.Error.Error()))
+			L.Push(lua.LString(err))
 			return 2
 		}
 
-		// Convert response to Lua table
-		resultTbl := L.NewTable()
-		L.SetField(resultTbl, "id", lua.LString(result.Response.ID))
-		L.Push(resultTbl)
-		L.Push(lua.LNil)
-		return 2
+		rendered, renderErr := tmpl.Render(luaValueToGo(L, options.RawGetString("data")))
+		if renderErr != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(fmt.Sprintf("failed to render email template %s: %s", name, renderErr.Error())))
+			return 2
+		}
+		if req.Subject == "" {
+			req.Subject = rendered.Subject
+		}
+		req.HTML = rendered.HTML
+		req.Text = rendered.Text
+
+		return send(L, options, req)
 	}))
 
 	L.SetGlobal("email", emailTable)
 }
 
+// parseEmailRetryPolicy applies the optional max_retries option to the
+// server's retry policy
+func parseEmailRetryPolicy(options *lua.LTable, policy email.RetryPolicy) (email.RetryPolicy, string) {
+	maxRetries := options.RawGetString("max_retries")
+	if maxRetries == lua.LNil {
+		return policy, ""
+	}
+
+	n, ok := maxRetries.(lua.LNumber)
+	if !ok || n < 0 || n > email.MaxRetriesLimit || n != lua.LNumber(int(n)) {
+		return policy, fmt.Sprintf("max_retries must be an integer between 0 and %d", email.MaxRetriesLimit)
+	}
+	policy.MaxRetries = int(n)
+	return policy, ""
+}
+
 // parseEmailSendRequest extracts email.SendRequest from Lua options table
 func parseEmailSendRequest(options *lua.LTable) (email.SendRequest, string) {
 	from := lua.LVAsString(options.RawGetString("from"))
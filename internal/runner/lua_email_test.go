@@ -595,3 +595,342 @@ end
 		t.Errorf("expected scheduled_at '%s', got '%s'", expectedScheduledAt, receivedScheduledAt)
 	}
 }
+
+func TestRun_Email_DefaultFrom(t *testing.T) {
+	// Create mock Resend server
+	var from any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&reqBody)
+		from = reqBody["from"]
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "email_123456"})
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "RESEND_API_KEY", "test-resend-key")
+	_ = envStore.Set("test-function", "RESEND_BASE_URL", server.URL)
+
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    envStore,
+		HTTP:   internalhttp.NewDefaultClient(),
+		Email:  email.NewDefaultClient(envStore),
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local _, err = email.send({
+		to = "recipient@example.com",
+		subject = "Test",
+		text = "Hello"
+	})
+	if err then
+		return { statusCode = 500, body = err }
+	end
+	return { statusCode = 200 }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{
+		Context:       execCtx,
+		Event:         events.HTTPEvent{Method: "POST", Path: "/send"},
+		Code:          luaCode,
+		EmailDefaults: email.Defaults{From: "noreply@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if resp.HTTP.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+	if from != "noreply@example.com" {
+		t.Errorf("expected default from 'noreply@example.com', got %v", from)
+	}
+}
+
+func TestRun_Email_SenderNotAllowed(t *testing.T) {
+	// Create mock Resend server
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "email_123456"})
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "RESEND_API_KEY", "test-resend-key")
+	_ = envStore.Set("test-function", "RESEND_BASE_URL", server.URL)
+
+	deps := Dependencies{
+		Logger:       logger.NewMemoryLogger(),
+		KV:           kv.NewMemoryStore(),
+		Env:          envStore,
+		HTTP:         internalhttp.NewDefaultClient(),
+		Email:        email.NewDefaultClient(envStore),
+		EmailAllowed: email.SenderAllowlist{"example.com"},
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local _, err = email.send({
+		from = event.body,
+		to = "recipient@example.com",
+		subject = "Test",
+		text = "Hello"
+	})
+	if err then
+		return { statusCode = 403, body = err }
+	end
+	return { statusCode = 200 }
+end
+`
+
+	tests := []struct {
+		name       string
+		from       string
+		allowed    email.SenderAllowlist
+		wantStatus int
+	}{
+		{name: "global allowlist rejects", from: "noreply@other.com", wantStatus: 403},
+		{name: "function allowlist rejects", from: "sales@example.com", allowed: email.SenderAllowlist{"noreply@example.com"}, wantStatus: 403},
+		{name: "both allow", from: "noreply@example.com", allowed: email.SenderAllowlist{"noreply@example.com"}, wantStatus: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			resp, err := Run(context.Background(), deps, Request{
+				Context:      execCtx,
+				Event:        events.HTTPEvent{Method: "POST", Path: "/send", Body: tt.from},
+				Code:         luaCode,
+				EmailAllowed: tt.allowed,
+			})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if resp.HTTP.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, resp.HTTP.StatusCode, resp.HTTP.Body)
+			}
+			if tt.wantStatus == 403 {
+				if called {
+					t.Error("expected rejected sender not to reach the provider")
+				}
+				if !strings.Contains(resp.HTTP.Body, "not allowed") {
+					t.Errorf("expected not allowed error, got %q", resp.HTTP.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Email_SendTemplate(t *testing.T) {
+	// Create mock Resend server
+	var reqBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&reqBody)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "email_123456"})
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "RESEND_API_KEY", "test-resend-key")
+	_ = envStore.Set("test-function", "RESEND_BASE_URL", server.URL)
+
+	templates := email.NewMemoryTemplateStore()
+	_, _ = templates.Set("test-function", email.Template{
+		Name:    "welcome",
+		Subject: "Welcome, {{.name}}!",
+		HTML:    "<p>Hello {{.name}}, you have {{len .items}} items</p>",
+	})
+
+	deps := Dependencies{
+		Logger:         logger.NewMemoryLogger(),
+		KV:             kv.NewMemoryStore(),
+		Env:            envStore,
+		HTTP:           internalhttp.NewDefaultClient(),
+		Email:          email.NewDefaultClient(envStore),
+		EmailTemplates: templates,
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local result, err = email.send_template({
+		template = event.body,
+		from = "noreply@example.com",
+		to = "recipient@example.com",
+		data = { name = "<Ana>", items = {"a", "b"} }
+	})
+	if err then
+		return { statusCode = 500, body = err }
+	end
+	return { statusCode = 200, body = result.id }
+end
+`
+
+	t.Run("renders template", func(t *testing.T) {
+		resp, err := Run(context.Background(), deps, Request{
+			Context: execCtx,
+			Event:   events.HTTPEvent{Method: "POST", Path: "/send", Body: "welcome"},
+			Code:    luaCode,
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if resp.HTTP.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+		}
+		if reqBody["subject"] != "Welcome, <Ana>!" {
+			t.Errorf("expected rendered subject, got %v", reqBody["subject"])
+		}
+		if reqBody["html"] != "<p>Hello &lt;Ana&gt;, you have 2 items</p>" {
+			t.Errorf("expected rendered html, got %v", reqBody["html"])
+		}
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		resp, err := Run(context.Background(), deps, Request{
+			Context: execCtx,
+			Event:   events.HTTPEvent{Method: "POST", Path: "/send", Body: "missing"},
+			Code:    luaCode,
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if resp.HTTP.StatusCode != 500 || !strings.Contains(resp.HTTP.Body, "email template not found: missing") {
+			t.Errorf("expected template not found error, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+		}
+	})
+}
+
+func TestRun_Email_RetriesTransientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message":"rate limited"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "email_retried"})
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "RESEND_API_KEY", "test-resend-key")
+	_ = envStore.Set("test-function", "RESEND_BASE_URL", server.URL)
+	tracker := email.NewMemoryTracker()
+
+	deps := Dependencies{
+		Logger:       logger.NewMemoryLogger(),
+		KV:           kv.NewMemoryStore(),
+		Env:          envStore,
+		HTTP:         internalhttp.NewDefaultClient(),
+		Email:        email.NewDefaultClient(envStore),
+		EmailTracker: tracker,
+		EmailRetry:   email.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+	}
+
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-retry",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	luaCode := `
+function handler(ctx, event)
+	local result, err = email.send({
+		from = "sender@example.com",
+		to = "recipient@example.com",
+		subject = "Test Subject",
+		text = "Hello, World!"
+	})
+	if err then
+		return { statusCode = 500, body = err }
+	end
+	return { statusCode = 200, body = result.id }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.StatusCode != 200 || resp.HTTP.Body != "email_retried" {
+		t.Fatalf("expected the retried send to succeed, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+
+	requests := tracker.Requests("exec-retry")
+	if len(requests) != 2 {
+		t.Fatalf("expected both attempts to be tracked, got %d", len(requests))
+	}
+	if requests[0].Status != "error" || requests[1].Status != "success" {
+		t.Errorf("expected an error then a success, got %s then %s", requests[0].Status, requests[1].Status)
+	}
+}
+
+func TestRun_Email_InvalidMaxRetries(t *testing.T) {
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "RESEND_API_KEY", "test-resend-key")
+
+	deps := Dependencies{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    envStore,
+		HTTP:   internalhttp.NewDefaultClient(),
+		Email:  email.NewDefaultClient(envStore),
+	}
+
+	execCtx := &events.ExecutionContext{ExecutionID: "exec-123", FunctionID: "test-function"}
+
+	luaCode := `
+function handler(ctx, event)
+	local _, err = email.send({
+		from = "sender@example.com",
+		to = "recipient@example.com",
+		subject = "Test Subject",
+		text = "Hello, World!",
+		max_retries = 50
+	})
+	return { statusCode = 400, body = err }
+end
+`
+
+	resp, err := Run(context.Background(), deps, Request{Context: execCtx, Event: events.HTTPEvent{Method: "POST"}, Code: luaCode})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(resp.HTTP.Body, "max_retries must be an integer between 0 and 10") {
+		t.Errorf("expected max_retries validation error, got: %s", resp.HTTP.Body)
+	}
+}
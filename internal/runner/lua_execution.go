@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"regexp"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Execution tag limits
+const (
+	MaxExecutionTags  = 20
+	MaxTagKeyLength   = 64
+	MaxTagValueLength = 256
+)
+
+var tagKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidTagKey reports whether key can name an execution tag:
+// 1-64 letters, digits, '_' or '-'
+func ValidTagKey(key string) bool {
+	return len(key) <= MaxTagKeyLength && tagKeyPattern.MatchString(key)
+}
+
+// registerExecution registers the execution module. execution.tag(key, value)
+// labels the current execution, e.g. with a customer, so executions can be
+// filtered by it later. Tags are collected in tags for the engine to store;
+// setting a key again replaces its value. A nil tags map discards them.
+func registerExecution(L *lua.LState, tags map[string]string) {
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+
+	executionModule := L.NewTable()
+
+	L.SetField(executionModule, "tag", L.NewFunction(func(L *lua.LState) int {
+		key := checkString(L, 1, "execution.tag")
+		value := checkString(L, 2, "execution.tag")
+
+		if !ValidTagKey(key) {
+			L.ArgError(1, "tag key must be 1-64 letters, digits, '_' or '-'")
+			return 0
+		}
+		if len(value) > MaxTagValueLength {
+			L.ArgError(2, "tag value must be at most 256 bytes")
+			return 0
+		}
+		if _, exists := tags[key]; !exists && len(tags) >= MaxExecutionTags {
+			L.RaiseError("execution.tag: at most %d tags per execution", MaxExecutionTags)
+			return 0
+		}
+
+		tags[key] = value
+		return 0
+	}))
+
+	L.SetGlobal("execution", executionModule)
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func runTagged(t *testing.T, code string) (map[string]string, error) {
+	t.Helper()
+
+	tags := make(map[string]string)
+	_, _, err := runCode(t, code, func(_ *Dependencies, req *Request) {
+		req.Tags = tags
+	})
+	return tags, err
+}
+
+func TestExecution_Tag(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	execution.tag("customer", "acme")
+	execution.tag("plan", "free")
+	execution.tag("plan", "pro")
+	return { statusCode = 200 }
+end
+`
+
+	tags, err := runTagged(t, code)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(tags) != 2 || tags["customer"] != "acme" || tags["plan"] != "pro" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestExecution_TagInvalidKey(t *testing.T) {
+	for _, key := range []string{"", "has space", "a.b", strings.Repeat("k", MaxTagKeyLength+1)} {
+		code := fmt.Sprintf(`
+function handler(ctx, event)
+	execution.tag(%q, "value")
+	return { statusCode = 200 }
+end
+`, key)
+
+		if _, err := runTagged(t, code); err == nil {
+			t.Errorf("expected error for key %q", key)
+		}
+	}
+}
+
+func TestExecution_TagValueTooLong(t *testing.T) {
+	code := fmt.Sprintf(`
+function handler(ctx, event)
+	execution.tag("key", %q)
+	return { statusCode = 200 }
+end
+`, strings.Repeat("v", MaxTagValueLength+1))
+
+	if _, err := runTagged(t, code); err == nil {
+		t.Error("expected error for long value")
+	}
+}
+
+func TestExecution_TagLimit(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	for i = 1, 20 do
+		execution.tag("key" .. i, "value")
+	end
+	execution.tag("key1", "replaced")
+	execution.tag("key21", "value")
+	return { statusCode = 200 }
+end
+`
+
+	tags, err := runTagged(t, code)
+	if err == nil || !strings.Contains(err.Error(), "at most 20 tags") {
+		t.Fatalf("expected tag limit error, got %v", err)
+	}
+	if len(tags) != MaxExecutionTags || tags["key1"] != "replaced" {
+		t.Errorf("expected %d tags with key1 replaced, got %v", MaxExecutionTags, tags)
+	}
+}
+
+func TestExecution_TagsKeptOnError(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	execution.tag("customer", "acme")
+	error("boom")
+end
+`
+
+	tags, err := runTagged(t, code)
+	if err == nil {
+		t.Fatal("expected handler error")
+	}
+	if tags["customer"] != "acme" {
+		t.Errorf("expected tag set before the error, got %v", tags)
+	}
+}
@@ -1,14 +1,28 @@
 package runner
 
 import (
+	stdlibhttp "github.com/dimiro1/lunar/internal/runtime/http"
 	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 	lua "github.com/yuin/gopher-lua"
 )
 
-// registerHTTP creates the global 'http' table with HTTP client functions
-func registerHTTP(L *lua.LState, httpClient internalhttp.Client) {
+// registerHTTP creates the global 'http' table with HTTP client functions.
+// When capture is set, requests and their bodies are recorded with the
+// tracker unless a call passes capture = false in its options.
+func registerHTTP(L *lua.LState, httpClient internalhttp.Client, tracker internalhttp.Tracker, executionID string, capture bool) {
 	httpTable := L.NewTable()
 
+	var trackedClient internalhttp.Client
+	if capture && tracker != nil {
+		trackedClient = stdlibhttp.NewTrackedClient(httpClient, tracker, executionID)
+	}
+	clientFor := func(options *lua.LTable) internalhttp.Client {
+		if trackedClient == nil || options.RawGetString("capture") == lua.LFalse {
+			return httpClient
+		}
+		return trackedClient
+	}
+
 	// http.get(url, options)
 	L.SetField(httpTable, "get", L.NewFunction(func(L *lua.LState) int {
 		url := L.CheckString(1)
@@ -20,7 +34,7 @@ func registerHTTP(L *lua.LState, httpClient internalhttp.Client) {
 			Query:   luaTableToQuery(options.RawGetString("query")),
 		}
 
-		resp, err := httpClient.Get(req)
+		resp, err := clientFor(options).Get(req)
 		if err != nil {
 			L.Push(lua.LNil)
 			L.Push(lua.LString(err.Error()))
@@ -44,7 +58,7 @@ func registerHTTP(L *lua.LState, httpClient internalhttp.Client) {
 			Body:    lua.LVAsString(options.RawGetString("body")),
 		}
 
-		resp, err := httpClient.Post(req)
+		resp, err := clientFor(options).Post(req)
 		if err != nil {
 			L.Push(lua.LNil)
 			L.Push(lua.LString(err.Error()))
@@ -68,7 +82,7 @@ func registerHTTP(L *lua.LState, httpClient internalhttp.Client) {
 			Body:    lua.LVAsString(options.RawGetString("body")),
 		}
 
-		resp, err := httpClient.Put(req)
+		resp, err := clientFor(options).Put(req)
 		if err != nil {
 			L.Push(lua.LNil)
 			L.Push(lua.LString(err.Error()))
@@ -91,7 +105,7 @@ func registerHTTP(L *lua.LState, httpClient internalhttp.Client) {
 			Query:   luaTableToQuery(options.RawGetString("query")),
 		}
 
-		resp, err := httpClient.Delete(req)
+		resp, err := clientFor(options).Delete(req)
 		if err != nil {
 			L.Push(lua.LNil)
 			L.Push(lua.LString(err.Error()))
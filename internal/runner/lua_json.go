@@ -39,7 +39,7 @@ func jsonEncode(L *lua.LState) int {
 // jsonDecode converts a JSON string to a Lua value
 // Usage: local data = json.decode(str)
 func jsonDecode(L *lua.LState) int {
-	jsonStr := L.CheckString(1)
+	jsonStr := checkString(L, 1, "json.decode")
 
 	// Decode using stdlib
 	goValue, err := stdlibjson.Decode(jsonStr)
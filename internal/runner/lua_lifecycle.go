@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/dimiro1/lunar/internal/services/logger"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// runInit calls the optional init() hook of the loaded code and returns what
+// it returned, which the handler receives as its third argument. Code without
+// an init hook gets nil. The hook runs once per Lua state, after the code is
+// loaded and before the first request.
+func runInit(L *lua.LState, code string) (lua.LValue, error) {
+	initFn, ok := L.GetGlobal("init").(*lua.LFunction)
+	if !ok {
+		return lua.LNil, nil
+	}
+
+	if err := L.CallByParam(lua.P{Fn: initFn, NRet: 1, Protect: true}); err != nil {
+		if panicErr, ok := asPanicError(err); ok {
+			return lua.LNil, panicErr
+		}
+		return lua.LNil, EnhanceError(fmt.Errorf("failed to run init: %w", err), code)
+	}
+
+	ret := L.Get(-1)
+	L.Pop(1)
+	return ret, nil
+}
+
+// runCleanup calls the optional cleanup() hook before the Lua state is
+// discarded: after the execution, or when a warm state is evicted. The
+// response is already decided by then, so errors are written to the logs of
+// the last execution instead of failing it.
+func runCleanup(L *lua.LState, log logger.Logger, executionID string) {
+	cleanupFn, ok := L.GetGlobal("cleanup").(*lua.LFunction)
+	if !ok {
+		return
+	}
+
+	// Cleanup runs while the state is discarded, where a panic has nothing
+	// left to recover it
+	defer func() {
+		if rec := recover(); rec != nil && log != nil {
+			log.Error(executionID, fmt.Sprintf("cleanup failed: panic: %v", rec))
+		}
+	}()
+
+	if err := L.CallByParam(lua.P{Fn: cleanupFn, NRet: 0, Protect: true}); err != nil && log != nil {
+		log.Error(executionID, fmt.Sprintf("cleanup failed: %v", err))
+	}
+}
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/services/logger"
+)
+
+func TestRun_Lifecycle_InitResultPassedToHandler(t *testing.T) {
+	code := `
+local calls = 0
+
+function init()
+	calls = calls + 1
+	return { codes = { us = "United States", br = "Brazil" } }
+end
+
+function handler(ctx, event, state)
+	return { statusCode = 200, body = state.codes.br .. " " .. calls }
+end
+`
+
+	resp, _, err := runCode(t, code, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.Body != "Brazil 1" {
+		t.Errorf("expected body 'Brazil 1', got %q", resp.HTTP.Body)
+	}
+}
+
+func TestRun_Lifecycle_WithoutHooks(t *testing.T) {
+	code := `
+function handler(ctx, event, state)
+	return { statusCode = 200, body = tostring(state) }
+end
+`
+
+	resp, _, err := runCode(t, code, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.Body != "nil" {
+		t.Errorf("expected nil state without init, got %q", resp.HTTP.Body)
+	}
+}
+
+func TestRun_Lifecycle_InitError(t *testing.T) {
+	code := `
+function init()
+	error("lookup table unavailable")
+end
+
+function handler(ctx, event)
+	return { statusCode = 200 }
+end
+`
+
+	_, _, err := runCode(t, code, nil)
+	if err == nil || !strings.Contains(err.Error(), "lookup table unavailable") {
+		t.Errorf("expected init error, got %v", err)
+	}
+}
+
+func TestRun_Lifecycle_Cleanup(t *testing.T) {
+	code := `
+function cleanup()
+	log.info("cleaned up")
+	error("close failed")
+end
+
+function handler(ctx, event)
+	log.info("handled")
+	return { statusCode = 200 }
+end
+`
+
+	resp, log, err := runCode(t, code, nil)
+	if err != nil {
+		t.Fatalf("expected cleanup errors not to fail the execution, got %v", err)
+	}
+	if resp.HTTP.StatusCode != 200 {
+		t.Errorf("expected status 200, got %d", resp.HTTP.StatusCode)
+	}
+
+	entries := log.Entries("exec-123")
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 log entries, got %+v", entries)
+	}
+	if entries[0].Message != "handled" || entries[1].Message != "cleaned up" {
+		t.Errorf("expected cleanup to run after the handler, got %+v", entries)
+	}
+	if entries[2].Level != logger.Error || !strings.Contains(entries[2].Message, "close failed") {
+		t.Errorf("expected the cleanup error to be logged, got %+v", entries[2])
+	}
+}
@@ -1,10 +1,73 @@
 package runner
 
 import (
+	"fmt"
+	"sync/atomic"
+
 	"github.com/dimiro1/lunar/internal/services/logger"
 	lua "github.com/yuin/gopher-lua"
 )
 
+// DefaultMaxLogEntries is the number of log entries an execution may write
+// when neither the server nor the function sets a limit
+const DefaultMaxLogEntries = 1000
+
+// limitedLogger drops the log entries of an execution past max, writing a
+// single warning in their place so the cut is visible in the logs
+type limitedLogger struct {
+	logger.Logger
+	max   int64
+	count atomic.Int64
+}
+
+// newLimitedLogger wraps log so that at most max entries are written
+func newLimitedLogger(log logger.Logger, max int) *limitedLogger {
+	return &limitedLogger{Logger: log, max: int64(max)}
+}
+
+// Log writes the entry while the execution is within its limit
+func (l *limitedLogger) Log(executionID string, level logger.LogLevel, message string) {
+	switch n := l.count.Add(1); {
+	case n <= l.max:
+		l.Logger.Log(executionID, level, message)
+	case n == l.max+1:
+		l.Logger.Log(executionID, logger.Warn, fmt.Sprintf("log limit reached (%d entries), further logs dropped", l.max))
+	}
+}
+
+// Info logs an informational message
+func (l *limitedLogger) Info(executionID string, message string) {
+	l.Log(executionID, logger.Info, message)
+}
+
+// Debug logs a debug message
+func (l *limitedLogger) Debug(executionID string, message string) {
+	l.Log(executionID, logger.Debug, message)
+}
+
+// Warn logs a warning message
+func (l *limitedLogger) Warn(executionID string, message string) {
+	l.Log(executionID, logger.Warn, message)
+}
+
+// Error logs an error message
+func (l *limitedLogger) Error(executionID string, message string) {
+	l.Log(executionID, logger.Error, message)
+}
+
+// maxLogEntries returns the log limit of req, the function's own limit when
+// it sets one and the server's otherwise
+func maxLogEntries(deps Dependencies, req Request) int {
+	switch {
+	case req.MaxLogEntries > 0:
+		return req.MaxLogEntries
+	case deps.MaxLogEntries > 0:
+		return deps.MaxLogEntries
+	default:
+		return DefaultMaxLogEntries
+	}
+}
+
 // registerLogger creates the global 'log' table with logging functions
 func registerLogger(L *lua.LState, log logger.Logger, executionID string) {
 	logTable := L.NewTable()
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/services/logger"
+)
+
+const loopLogCode = `
+function handler(ctx, event)
+	for i = 1, 20 do
+		log.info("line " .. i)
+	end
+	return { statusCode = 200 }
+end
+`
+
+func runLogLoop(t *testing.T, serverMax, functionMax int) []logger.LogEntry {
+	t.Helper()
+	_, log, err := runCode(t, loopLogCode, func(deps *Dependencies, req *Request) {
+		deps.MaxLogEntries = serverMax
+		req.MaxLogEntries = functionMax
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return log.Entries("exec-123")
+}
+
+func TestRun_MaxLogEntries(t *testing.T) {
+	entries := runLogLoop(t, 5, 0)
+	if len(entries) != 6 {
+		t.Fatalf("expected 5 entries and the limit notice, got %d", len(entries))
+	}
+	if entries[4].Message != "line 5" {
+		t.Errorf("expected the first 5 lines to be kept, got %q", entries[4].Message)
+	}
+	notice := entries[5]
+	if notice.Level != logger.Warn || notice.Message != "log limit reached (5 entries), further logs dropped" {
+		t.Errorf("unexpected limit notice: %+v", notice)
+	}
+}
+
+func TestRun_MaxLogEntries_FunctionOverride(t *testing.T) {
+	if entries := runLogLoop(t, 5, 10); len(entries) != 11 {
+		t.Errorf("expected the function limit to win, got %d entries", len(entries))
+	}
+}
+
+func TestRun_MaxLogEntries_Default(t *testing.T) {
+	if entries := runLogLoop(t, 0, 0); len(entries) != 20 {
+		t.Errorf("expected all 20 entries under the default limit, got %d", len(entries))
+	}
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// middleware holds the hooks of a function module listed in the function's
+// middleware setting
+type middleware struct {
+	name   string
+	before *lua.LFunction // nil when the module has no before hook
+	after  *lua.LFunction // nil when the module has no after hook
+}
+
+// loadMiddleware requires each named module, which must return a table with
+// a before function, an after function or both
+func loadMiddleware(L *lua.LState, names []string) ([]middleware, error) {
+	chain := make([]middleware, 0, len(names))
+	for _, name := range names {
+		if err := L.CallByParam(lua.P{Fn: L.GetGlobal("require"), NRet: 1, Protect: true}, lua.LString(name)); err != nil {
+			if panicErr, ok := asPanicError(err); ok {
+				return nil, panicErr
+			}
+			return nil, fmt.Errorf("failed to load middleware %s: %w", name, err)
+		}
+		ret := L.Get(-1)
+		L.Pop(1)
+
+		tbl, ok := ret.(*lua.LTable)
+		if !ok {
+			return nil, fmt.Errorf("middleware %s must return a table with before or after functions", name)
+		}
+		m := middleware{name: name}
+		m.before, _ = tbl.RawGetString("before").(*lua.LFunction)
+		m.after, _ = tbl.RawGetString("after").(*lua.LFunction)
+		if m.before == nil && m.after == nil {
+			return nil, fmt.Errorf("middleware %s must return a table with before or after functions", name)
+		}
+		chain = append(chain, m)
+	}
+	return chain, nil
+}
+
+// callWithMiddleware calls handler(ctx, event, state) wrapped by chain.
+// The before hooks run in order with (ctx, event) and may change the event
+// table in place; one returning a table answers the request with it, skipping
+// the handler and the remaining before hooks. The after hooks of the
+// middleware whose before hook ran then run in reverse order with
+// (ctx, event, response), and a table they return replaces the response.
+// Without middleware this is a plain call of the handler.
+func callWithMiddleware(L *lua.LState, chain []middleware, handler lua.LValue, ctxTable, eventTable *lua.LTable, state lua.LValue, code string) (lua.LValue, error) {
+	var ret lua.LValue = lua.LNil
+	entered := 0
+	answered := false
+	for entered < len(chain) && !answered {
+		m := chain[entered]
+		entered++
+		if m.before == nil {
+			continue
+		}
+		out, err := callHook(L, m.name, m.before, ctxTable, eventTable)
+		if err != nil {
+			return lua.LNil, err
+		}
+		if _, ok := out.(*lua.LTable); ok {
+			ret, answered = out, true
+		}
+	}
+
+	if !answered {
+		if err := L.CallByParam(lua.P{
+			Fn:      handler,
+			NRet:    1,
+			Protect: true,
+		}, ctxTable, eventTable, state); err != nil {
+			if panicErr, ok := asPanicError(err); ok {
+				return lua.LNil, panicErr
+			}
+			return lua.LNil, EnhanceError(fmt.Errorf("failed to execute handler: %w", err), code)
+		}
+		ret = L.Get(-1)
+		L.Pop(1)
+	}
+
+	for i := entered - 1; i >= 0; i-- {
+		m := chain[i]
+		// A handler that already answered over a stream has no response to change
+		if m.after == nil || ret.Type() != lua.LTTable {
+			continue
+		}
+		out, err := callHook(L, m.name, m.after, ctxTable, eventTable, ret)
+		if err != nil {
+			return lua.LNil, err
+		}
+		if _, ok := out.(*lua.LTable); ok {
+			ret = out
+		}
+	}
+	return ret, nil
+}
+
+// callHook calls a hook of the named middleware and returns its first result
+func callHook(L *lua.LState, name string, hook *lua.LFunction, args ...lua.LValue) (lua.LValue, error) {
+	if err := L.CallByParam(lua.P{Fn: hook, NRet: 1, Protect: true}, args...); err != nil {
+		if panicErr, ok := asPanicError(err); ok {
+			return lua.LNil, panicErr
+		}
+		return lua.LNil, fmt.Errorf("middleware %s failed: %w", name, err)
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+	return ret, nil
+}
@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/services/modules"
+)
+
+func runWithMiddleware(t *testing.T, code string, store modules.Store, middleware []string, event events.HTTPEvent) (Response, error) {
+	t.Helper()
+
+	resp, _, err := runCode(t, code, func(deps *Dependencies, req *Request) {
+		deps.Modules = store
+		req.Event = event
+		req.Middleware = middleware
+	})
+	return resp, err
+}
+
+func TestRun_Middleware(t *testing.T) {
+	store := modules.NewMemoryStore()
+	_, _ = store.Set("test-function", modules.Module{Name: "auth", Code: `
+return {
+	before = function(ctx, event)
+		if event.headers["Authorization"] ~= "Bearer secret" then
+			return { statusCode = 401, body = "unauthorized" }
+		end
+		event.user = "ana"
+	end,
+	after = function(ctx, event, response)
+		response.headers = response.headers or {}
+		response.headers["X-Auth"] = "checked"
+		return response
+	end
+}
+`})
+	_, _ = store.Set("test-function", modules.Module{Name: "mw.envelope", Code: `
+return {
+	after = function(ctx, event, response)
+		return { statusCode = response.statusCode, body = json.encode({ data = response.body }) }
+	end
+}
+`})
+
+	code := `
+function handler(ctx, event)
+	return { statusCode = 200, body = "hello " .. event.user }
+end
+`
+	middleware := []string{"auth", "mw.envelope"}
+
+	resp, err := runWithMiddleware(t, code, store, middleware, events.HTTPEvent{
+		Method:  "GET",
+		Path:    "/",
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// The envelope wraps the handler's response before auth adds its header
+	if resp.HTTP.StatusCode != 200 || resp.HTTP.Body != `{"data":"hello ana"}` {
+		t.Errorf("expected the enveloped response, got %d %q", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+	if resp.HTTP.Headers["X-Auth"] != "checked" {
+		t.Errorf("expected the auth after hook to run last, got headers %v", resp.HTTP.Headers)
+	}
+
+	// A before hook that returns a response skips the handler and the
+	// middleware after it
+	resp, err = runWithMiddleware(t, code, store, middleware, events.HTTPEvent{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.StatusCode != 401 || resp.HTTP.Body != "unauthorized" {
+		t.Errorf("expected the auth response, got %d %q", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+	if resp.HTTP.Headers["X-Auth"] != "checked" {
+		t.Errorf("expected the after hook of auth to run, got headers %v", resp.HTTP.Headers)
+	}
+}
+
+func TestRun_Middleware_Errors(t *testing.T) {
+	store := modules.NewMemoryStore()
+	_, _ = store.Set("test-function", modules.Module{Name: "empty", Code: `return {}`})
+	_, _ = store.Set("test-function", modules.Module{Name: "broken", Code: `
+return {
+	before = function(ctx, event) error("no token") end
+}
+`})
+
+	code := `function handler(ctx, event) return { statusCode = 200 } end`
+
+	tests := []struct {
+		name       string
+		middleware []string
+		wantErr    string
+	}{
+		{name: "missing module", middleware: []string{"missing"}, wantErr: "failed to load middleware missing"},
+		{name: "no hooks", middleware: []string{"empty"}, wantErr: "middleware empty must return a table"},
+		{name: "hook error", middleware: []string{"broken"}, wantErr: "no token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runWithMiddleware(t, code, store, tt.middleware, events.HTTPEvent{Method: "GET", Path: "/"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/events"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Compile-time check that LuaPolicy implements engine.Policy
+var _ engine.Policy = (*LuaPolicy)(nil)
+
+// PolicyTimeout bounds each call of a Lua policy
+const PolicyTimeout = time.Second
+
+// DefaultPolicyMessage answers requests a policy denies without a body
+const DefaultPolicyMessage = "Forbidden by policy"
+
+// LuaPolicy is an engine.Policy written in Lua. The code defines a global
+// policy(ctx, event) function, where ctx holds functionId, trigger and
+// clientIp and event is the HTTP event a handler receives. Returning nil or
+// true allows the execution, false denies it with a 403, and a table
+// { statusCode = 401, body = "..." } denies it with that status and message.
+// The json, strings, url, base64 and crypto modules are available.
+type LuaPolicy struct {
+	proto *lua.FunctionProto
+}
+
+// NewLuaPolicy compiles a Lua policy and checks that it defines policy
+func NewLuaPolicy(code string) (*LuaPolicy, error) {
+	chunk, err := parse.Parse(strings.NewReader(code), "policy")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	proto, err := lua.Compile(chunk, "policy")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile policy: %w", err)
+	}
+
+	p := &LuaPolicy{proto: proto}
+	L, err := p.newState(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	L.Close()
+	return p, nil
+}
+
+// LoadLuaPolicy reads and compiles the Lua policy at path
+func LoadLuaPolicy(path string) (*LuaPolicy, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return NewLuaPolicy(string(code))
+}
+
+// newState runs the policy code in a new state, which the caller closes
+func (p *LuaPolicy) newState(ctx context.Context) (*lua.LState, error) {
+	L := lua.NewState()
+	L.SetContext(ctx)
+	registerJSON(L)
+	registerStrings(L)
+	registerURL(L)
+	registerBase64(L)
+	registerCrypto(L)
+
+	L.Push(L.NewFunctionFromProto(p.proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("failed to load policy: %w", err)
+	}
+	if L.GetGlobal("policy").Type() != lua.LTFunction {
+		L.Close()
+		return nil, errors.New("policy function not found in policy code")
+	}
+	return L, nil
+}
+
+// Check calls policy(ctx, event) in a new state
+func (p *LuaPolicy) Check(ctx context.Context, req engine.PolicyRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, PolicyTimeout)
+	defer cancel()
+
+	L, err := p.newState(ctx)
+	if err != nil {
+		return err
+	}
+	defer L.Close()
+
+	ctxTable := L.NewTable()
+	L.SetField(ctxTable, "functionId", lua.LString(req.FunctionID))
+	L.SetField(ctxTable, "trigger", lua.LString(req.Trigger))
+	L.SetField(ctxTable, "clientIp", lua.LString(req.ClientIP))
+	var eventTable lua.LValue = lua.LNil
+	if event, ok := req.Event.(events.HTTPEvent); ok {
+		eventTable = httpEventToLuaTable(L, event)
+	}
+
+	if err := L.CallByParam(lua.P{Fn: L.GetGlobal("policy"), NRet: 1, Protect: true}, ctxTable, eventTable); err != nil {
+		return fmt.Errorf("policy failed: %w", err)
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+
+	switch ret := ret.(type) {
+	case *lua.LNilType:
+		return nil
+	case lua.LBool:
+		if ret {
+			return nil
+		}
+		return &engine.PolicyDeniedError{StatusCode: http.StatusForbidden, Message: DefaultPolicyMessage}
+	case *lua.LTable:
+		denied := &engine.PolicyDeniedError{StatusCode: http.StatusForbidden, Message: DefaultPolicyMessage}
+		if status, ok := ret.RawGetString("statusCode").(lua.LNumber); ok {
+			denied.StatusCode = int(status)
+		}
+		if denied.StatusCode < 400 || denied.StatusCode > 599 {
+			return fmt.Errorf("policy returned status code %d, want a 4xx or 5xx status", denied.StatusCode)
+		}
+		if body, ok := ret.RawGetString("body").(lua.LString); ok && body != "" {
+			denied.Message = string(body)
+		}
+		return denied
+	default:
+		return fmt.Errorf("policy returned a %s, want nil, a boolean or a table", ret.Type())
+	}
+}
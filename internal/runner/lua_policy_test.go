@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+func TestLuaPolicy_Check(t *testing.T) {
+	policy, err := NewLuaPolicy(`
+local blocked = { ["192.0.2.1"] = true }
+
+function policy(ctx, event)
+	if blocked[ctx.clientIp] then
+		return false
+	end
+	if ctx.trigger == "http" and event.headers["X-Org"] ~= "acme" then
+		return { statusCode = 401, body = "missing X-Org header" }
+	end
+	if event.query.status then
+		return { statusCode = tonumber(event.query.status) }
+	end
+end
+`)
+	if err != nil {
+		t.Fatalf("NewLuaPolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		req         engine.PolicyRequest
+		wantStatus  int // Zero when the execution is allowed
+		wantMessage string
+		wantErr     string
+	}{
+		{
+			name: "allowed",
+			req: engine.PolicyRequest{
+				Trigger: store.ExecutionTriggerHTTP,
+				Event:   events.HTTPEvent{Headers: map[string]string{"X-Org": "acme"}},
+			},
+		},
+		{
+			name: "other triggers skip the header check",
+			req:  engine.PolicyRequest{Trigger: store.ExecutionTriggerCron, Event: events.HTTPEvent{}},
+		},
+		{
+			name:        "blocked address",
+			req:         engine.PolicyRequest{Trigger: store.ExecutionTriggerCron, Event: events.HTTPEvent{}, ClientIP: "192.0.2.1"},
+			wantStatus:  403,
+			wantMessage: DefaultPolicyMessage,
+		},
+		{
+			name:        "denied with status and message",
+			req:         engine.PolicyRequest{Trigger: store.ExecutionTriggerHTTP, Event: events.HTTPEvent{}},
+			wantStatus:  401,
+			wantMessage: "missing X-Org header",
+		},
+		{
+			name: "denied without a message",
+			req: engine.PolicyRequest{
+				Trigger: store.ExecutionTriggerManual,
+				Event:   events.HTTPEvent{Query: map[string]string{"status": "429"}},
+			},
+			wantStatus:  429,
+			wantMessage: DefaultPolicyMessage,
+		},
+		{
+			name: "invalid status",
+			req: engine.PolicyRequest{
+				Trigger: store.ExecutionTriggerManual,
+				Event:   events.HTTPEvent{Query: map[string]string{"status": "200"}},
+			},
+			wantErr: "policy returned status code 200",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(context.Background(), tt.req)
+
+			var denied *engine.PolicyDeniedError
+			switch {
+			case tt.wantErr != "":
+				if err == nil || errors.As(err, &denied) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+			case tt.wantStatus == 0:
+				if err != nil {
+					t.Errorf("expected the execution to be allowed, got %v", err)
+				}
+			default:
+				if !errors.As(err, &denied) || denied.StatusCode != tt.wantStatus || denied.Message != tt.wantMessage {
+					t.Errorf("expected denial %d %q, got %v", tt.wantStatus, tt.wantMessage, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLuaPolicy_Errors(t *testing.T) {
+	if _, err := NewLuaPolicy(`function policy(ctx, event`); err == nil {
+		t.Error("expected a syntax error")
+	}
+	if _, err := NewLuaPolicy(`local x = 1`); err == nil || !strings.Contains(err.Error(), "policy function not found") {
+		t.Errorf("expected a missing policy function error, got %v", err)
+	}
+	if _, err := LoadLuaPolicy(filepath.Join(t.TempDir(), "missing.lua")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	path := filepath.Join(t.TempDir(), "policy.lua")
+	if err := os.WriteFile(path, []byte(`function policy(ctx, event) error("boom") end`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadLuaPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadLuaPolicy failed: %v", err)
+	}
+	err = policy.Check(context.Background(), engine.PolicyRequest{Event: events.HTTPEvent{}})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the policy's error, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dimiro1/lunar/internal/services/modules"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// registerRequire makes require resolve module names against the modules
+// stored for the function instead of the filesystem. package.preload still
+// takes precedence. A nil store leaves only package.preload.
+func registerRequire(L *lua.LState, store modules.Store, functionID string) {
+	loaders, ok := L.GetField(L.Get(lua.RegistryIndex), "_LOADERS").(*lua.LTable)
+	if !ok {
+		return
+	}
+
+	// Keep the preload loader and drop the filesystem one
+	for i := loaders.Len(); i > 1; i-- {
+		loaders.RawSetInt(i, lua.LNil)
+	}
+
+	loaders.Append(L.NewFunction(func(L *lua.LState) int {
+		name := L.CheckString(1)
+		if store == nil {
+			L.Push(lua.LString(fmt.Sprintf("no stored module '%s'", name)))
+			return 1
+		}
+
+		module, err := store.Get(functionID, name)
+		if errors.Is(err, modules.ErrModuleNotFound) {
+			L.Push(lua.LString(fmt.Sprintf("no stored module '%s'", name)))
+			return 1
+		}
+		if err != nil {
+			L.RaiseError("failed to load module %s: %v", name, err)
+			return 0
+		}
+
+		fn, err := L.Load(strings.NewReader(module.Code), name)
+		if err != nil {
+			L.RaiseError("failed to load module %s: %v", name, err)
+			return 0
+		}
+		L.Push(fn)
+		return 1
+	}))
+}
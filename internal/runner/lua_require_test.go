@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/services/modules"
+)
+
+func runWithModules(t *testing.T, code string, store modules.Store) (Response, error) {
+	t.Helper()
+
+	resp, _, err := runCode(t, code, func(deps *Dependencies, _ *Request) {
+		deps.Modules = store
+	})
+	return resp, err
+}
+
+func TestRun_Require_StoredModule(t *testing.T) {
+	store := modules.NewMemoryStore()
+	_, _ = store.Set("test-function", modules.Module{Name: "greet", Code: `
+local M = {}
+function M.hello(name) return "hello " .. name end
+return M
+`})
+	_, _ = store.Set("test-function", modules.Module{Name: "lib.shout", Code: `
+local greet = require("greet")
+return function(name) return string.upper(greet.hello(name)) end
+`})
+
+	code := `
+local greet = require("greet")
+local shout = require("lib.shout")
+function handler(ctx, event)
+	local again = require("greet")
+	return { statusCode = 200, body = shout("ana") .. " " .. tostring(again == greet) }
+end
+`
+
+	resp, err := runWithModules(t, code, store)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.Body != "HELLO ANA true" {
+		t.Errorf("expected body 'HELLO ANA true', got %q", resp.HTTP.Body)
+	}
+}
+
+func TestRun_Require_MissingModule(t *testing.T) {
+	store := modules.NewMemoryStore()
+	_, _ = store.Set("other-function", modules.Module{Name: "secret", Code: "return {}"})
+
+	// Modules of other functions are not visible and files on disk are not searched
+	_, err := runWithModules(t, `local m = require("secret")`, store)
+	if err == nil {
+		t.Fatal("expected error requiring a missing module")
+	}
+	if !strings.Contains(err.Error(), "no stored module 'secret'") {
+		t.Errorf("expected missing module error, got %v", err)
+	}
+	if strings.Contains(err.Error(), ".lua") {
+		t.Errorf("expected no filesystem lookup, got %v", err)
+	}
+}
+
+func TestRun_Require_ModuleSyntaxError(t *testing.T) {
+	store := modules.NewMemoryStore()
+	_, _ = store.Set("test-function", modules.Module{Name: "broken", Code: "return {"})
+
+	_, err := runWithModules(t, `local m = require("broken")`, store)
+	if err == nil || !strings.Contains(err.Error(), "failed to load module broken") {
+		t.Errorf("expected module load error, got %v", err)
+	}
+}
+
+func TestRun_Require_Preload(t *testing.T) {
+	code := `
+package.preload["inline"] = function() return { value = 42 } end
+function handler(ctx, event)
+	return { statusCode = 200, body = tostring(require("inline").value) }
+end
+`
+
+	resp, err := runWithModules(t, code, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.Body != "42" {
+		t.Errorf("expected body '42', got %q", resp.HTTP.Body)
+	}
+}
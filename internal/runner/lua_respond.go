@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	stdlibbase64 "github.com/dimiro1/lunar/internal/runtime/base64"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// DefaultFileContentType is used by respond.file when no content type is given
+const DefaultFileContentType = "application/octet-stream"
+
+// DefaultRedirectStatus is used by respond.redirect when no status is given
+const DefaultRedirectStatus = http.StatusFound
+
+// registerRespond registers the respond module with helpers that build
+// response tables for the handler to return.
+func registerRespond(L *lua.LState) {
+	respondModule := L.NewTable()
+
+	L.SetField(respondModule, "file", L.NewFunction(respondFile))
+	L.SetField(respondModule, "redirect", L.NewFunction(respondRedirect))
+
+	L.SetGlobal("respond", respondModule)
+}
+
+// respondFile builds a response serving bytes as a file. The body is base64
+// encoded with isBase64Encoded set, so binary content survives being stored
+// with the execution and is decoded by the server before it is written. With
+// a filename, Content-Disposition asks clients to download the file.
+// Usage: return respond.file(bytes, content_type, filename)
+func respondFile(L *lua.LState) int {
+	body := checkString(L, 1, "respond.file")
+	contentType := optString(L, 2, "respond.file", DefaultFileContentType)
+	filename := optString(L, 3, "respond.file", "")
+
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		L.ArgError(2, "invalid content type: "+err.Error())
+		return 0
+	}
+
+	headers := L.NewTable()
+	L.SetField(headers, "Content-Type", lua.LString(contentType))
+	if filename != "" {
+		// FormatMediaType quotes the name and encodes non-ASCII names (RFC 2231)
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+		if disposition == "" {
+			L.ArgError(3, "invalid filename")
+			return 0
+		}
+		L.SetField(headers, "Content-Disposition", lua.LString(disposition))
+	}
+
+	response := L.NewTable()
+	L.SetField(response, "statusCode", lua.LNumber(200))
+	L.SetField(response, "headers", headers)
+	L.SetField(response, "body", lua.LString(stdlibbase64.Encode(body)))
+	L.SetField(response, "isBase64Encoded", lua.LTrue)
+
+	L.Push(response)
+	return 1
+}
+
+// respondRedirect builds a response redirecting the client to location with
+// an empty body. The status defaults to 302 Found and must be a 3xx status.
+// Usage: return respond.redirect(location, status)
+func respondRedirect(L *lua.LState) int {
+	location := checkString(L, 1, "respond.redirect")
+	status := optInt(L, 2, "respond.redirect", DefaultRedirectStatus)
+
+	if location == "" || strings.ContainsAny(location, "\r\n") {
+		L.ArgError(1, "invalid location")
+		return 0
+	}
+	if status < 300 || status > 399 {
+		L.ArgError(2, "status must be a 3xx redirect status")
+		return 0
+	}
+
+	headers := L.NewTable()
+	L.SetField(headers, "Location", lua.LString(location))
+
+	response := L.NewTable()
+	L.SetField(response, "statusCode", lua.LNumber(status))
+	L.SetField(response, "headers", headers)
+	L.SetField(response, "body", lua.LString(""))
+
+	L.Push(response)
+	return 1
+}
@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRespond_File(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	return respond.file("id,name\n1,\0ana\n", "text/csv", "report.csv")
+end
+`
+
+	resp, _, err := runCode(t, code, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.StatusCode != 200 {
+		t.Errorf("expected status 200, got %d", resp.HTTP.StatusCode)
+	}
+	if !resp.HTTP.IsBase64Encoded {
+		t.Error("expected body to be base64 encoded")
+	}
+	if resp.HTTP.Body != "aWQsbmFtZQoxLABhbmEK" {
+		t.Errorf("unexpected body: %q", resp.HTTP.Body)
+	}
+	if got := resp.HTTP.Headers["Content-Type"]; got != "text/csv" {
+		t.Errorf("expected Content-Type text/csv, got %q", got)
+	}
+	if got := resp.HTTP.Headers["Content-Disposition"]; got != "attachment; filename=report.csv" {
+		t.Errorf("unexpected Content-Disposition: %q", got)
+	}
+}
+
+func TestRespond_FileDefaults(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	local response = respond.file("bytes")
+	response.statusCode = 201
+	return response
+end
+`
+
+	resp, _, err := runCode(t, code, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.StatusCode != 201 {
+		t.Errorf("expected the returned table to be editable, got status %d", resp.HTTP.StatusCode)
+	}
+	if got := resp.HTTP.Headers["Content-Type"]; got != DefaultFileContentType {
+		t.Errorf("expected default content type, got %q", got)
+	}
+	if _, ok := resp.HTTP.Headers["Content-Disposition"]; ok {
+		t.Error("expected no Content-Disposition without a filename")
+	}
+}
+
+func TestRespond_FileQuotesFilename(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	return respond.file("x", "text/plain", 'my "notes".txt')
+end
+`
+
+	resp, _, err := runCode(t, code, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := resp.HTTP.Headers["Content-Disposition"]; got != `attachment; filename="my \"notes\".txt"` {
+		t.Errorf("unexpected Content-Disposition: %q", got)
+	}
+}
+
+func TestRespond_FileInvalidContentType(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	return respond.file("x", "not a type")
+end
+`
+
+	_, _, err := runCode(t, code, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid content type") {
+		t.Errorf("expected invalid content type error, got %v", err)
+	}
+}
+
+func TestRespond_Redirect(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		wantStatus int
+	}{
+		{name: "default status", code: `return respond.redirect("/login")`, wantStatus: 302},
+		{name: "permanent", code: `return respond.redirect("/login", 301)`, wantStatus: 301},
+		{name: "preserves method", code: `return respond.redirect("/login", 307)`, wantStatus: 307},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _, err := runCode(t, "function handler(ctx, event)\n\t"+tt.code+"\nend", nil)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if resp.HTTP.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.HTTP.StatusCode)
+			}
+			if got := resp.HTTP.Headers["Location"]; got != "/login" {
+				t.Errorf("expected Location /login, got %q", got)
+			}
+			if resp.HTTP.Body != "" {
+				t.Errorf("expected empty body, got %q", resp.HTTP.Body)
+			}
+		})
+	}
+}
+
+func TestRespond_RedirectErrors(t *testing.T) {
+	tests := map[string]string{
+		`respond.redirect("/login", 200)`:         "status must be a 3xx redirect status",
+		`respond.redirect("/login", 404)`:         "status must be a 3xx redirect status",
+		`respond.redirect("")`:                    "invalid location",
+		`respond.redirect("/a\r\nSet-Cookie: x")`: "invalid location",
+		`respond.redirect({})`:                    "respond.redirect",
+	}
+
+	for code, wantErr := range tests {
+		_, _, err := runCode(t, "function handler(ctx, event)\n\treturn "+code+"\nend", nil)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", code, wantErr, err)
+		}
+	}
+}
@@ -22,7 +22,7 @@ func registerRouter(L *lua.LState, ctx *events.ExecutionContext) {
 // makeRouterPath creates a function that builds paths for the current function
 func makeRouterPath(functionID string) lua.LGFunction {
 	return func(L *lua.LState) int {
-		pattern := L.CheckString(1)
+		pattern := checkString(L, 1, "router.path")
 		params := extractStringParams(L, 2)
 		L.Push(lua.LString(router.FunctionPath(functionID, pattern, params)))
 		return 1
@@ -32,7 +32,7 @@ func makeRouterPath(functionID string) lua.LGFunction {
 // makeRouterURL creates a function that builds URLs for the current function
 func makeRouterURL(functionID, baseURL string) lua.LGFunction {
 	return func(L *lua.LState) int {
-		pattern := L.CheckString(1)
+		pattern := checkString(L, 1, "router.url")
 		params := extractStringParams(L, 2)
 		L.Push(lua.LString(router.FunctionURL(baseURL, functionID, pattern, params)))
 		return 1
@@ -61,8 +61,8 @@ func luaTableToStringMap(L *lua.LState, tbl *lua.LTable) map[string]string {
 // routerMatch checks if a path matches a pattern
 // Usage: local matched = router.match(path, pattern)
 func routerMatch(L *lua.LState) int {
-	path := L.CheckString(1)
-	pattern := L.CheckString(2)
+	path := checkString(L, 1, "router.match")
+	pattern := checkString(L, 2, "router.match")
 
 	result := router.Match(path, pattern)
 	L.Push(lua.LBool(result.Matched))
@@ -72,8 +72,8 @@ func routerMatch(L *lua.LState) int {
 // routerParams extracts parameters from a path using a pattern
 // Usage: local params = router.params(path, pattern)
 func routerParams(L *lua.LState) int {
-	path := L.CheckString(1)
-	pattern := L.CheckString(2)
+	path := checkString(L, 1, "router.params")
+	pattern := checkString(L, 2, "router.params")
 
 	result := router.Match(path, pattern)
 
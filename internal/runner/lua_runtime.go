@@ -11,6 +11,7 @@ import (
 	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 	"github.com/dimiro1/lunar/internal/services/kv"
 	"github.com/dimiro1/lunar/internal/services/logger"
+	"github.com/dimiro1/lunar/internal/services/modules"
 )
 
 // Compile-time check that LuaRuntime implements engine.Runtime
@@ -22,24 +23,43 @@ type LuaRuntime struct {
 	kv           kv.Store
 	env          env.Store
 	http         internalhttp.Client
+	httpTracker  internalhttp.Tracker
 	ai           ai.Client
 	aiTracker    ai.Tracker
+	aiRetry      ai.RetryPolicy
 	email        email.Client
 	emailTracker email.Tracker
+	emailRetry   email.RetryPolicy
+	templates    email.TemplateStore
+	emailAllowed email.SenderAllowlist
+	modules      modules.Store
+	states       *StatePool
 	timeout      time.Duration
+	maxLogs      int
+	maxOutbound  int
+	outboundTime time.Duration
 }
 
 // LuaRuntimeConfig holds the configuration for creating a LuaRuntime.
 type LuaRuntimeConfig struct {
-	Logger       logger.Logger
-	KV           kv.Store
-	Env          env.Store
-	HTTP         internalhttp.Client
-	AI           ai.Client
-	AITracker    ai.Tracker
-	Email        email.Client
-	EmailTracker email.Tracker
-	Timeout      time.Duration
+	Logger         logger.Logger
+	KV             kv.Store
+	Env            env.Store
+	HTTP           internalhttp.Client
+	HTTPTracker    internalhttp.Tracker
+	AI             ai.Client
+	AITracker      ai.Tracker
+	AIRetry        ai.RetryPolicy
+	Email          email.Client
+	EmailTracker   email.Tracker
+	EmailRetry     email.RetryPolicy
+	EmailTemplates email.TemplateStore
+	EmailAllowed   email.SenderAllowlist
+	Modules        modules.Store
+	Timeout        time.Duration
+	MaxLogEntries  int
+	MaxOutbound    int
+	OutboundTime   time.Duration
 }
 
 // NewLuaRuntime creates a new LuaRuntime with the given configuration.
@@ -49,32 +69,84 @@ func NewLuaRuntime(cfg LuaRuntimeConfig) *LuaRuntime {
 		kv:           cfg.KV,
 		env:          cfg.Env,
 		http:         cfg.HTTP,
+		httpTracker:  cfg.HTTPTracker,
 		ai:           cfg.AI,
 		aiTracker:    cfg.AITracker,
+		aiRetry:      cfg.AIRetry,
 		email:        cfg.Email,
 		emailTracker: cfg.EmailTracker,
+		emailRetry:   cfg.EmailRetry,
+		templates:    cfg.EmailTemplates,
+		emailAllowed: cfg.EmailAllowed,
+		modules:      cfg.Modules,
+		states:       NewStatePool(0, 0),
 		timeout:      cfg.Timeout,
+		maxLogs:      cfg.MaxLogEntries,
+		maxOutbound:  cfg.MaxOutbound,
+		outboundTime: cfg.OutboundTime,
 	}
 }
 
 // Execute implements the engine.Runtime interface.
 func (r *LuaRuntime) Execute(ctx context.Context, req engine.RuntimeRequest) (*engine.RuntimeResult, error) {
 	deps := Dependencies{
-		Logger:       r.logger,
-		KV:           r.kv,
-		Env:          r.env,
-		HTTP:         r.http,
-		AI:           r.ai,
-		AITracker:    r.aiTracker,
-		Email:        r.email,
-		EmailTracker: r.emailTracker,
-		Timeout:      r.timeout,
+		Logger:         r.logger,
+		KV:             r.kv,
+		Env:            r.env,
+		HTTP:           r.http,
+		HTTPTracker:    r.httpTracker,
+		AI:             r.ai,
+		AITracker:      r.aiTracker,
+		AIRetry:        r.aiRetry,
+		Email:          r.email,
+		EmailTracker:   r.emailTracker,
+		EmailRetry:     r.emailRetry,
+		EmailTemplates: r.templates,
+		EmailAllowed:   r.emailAllowed,
+		Modules:        r.modules,
+		States:         r.states,
+		Timeout:        r.timeout,
+		MaxLogEntries:  r.maxLogs,
+		MaxOutbound:    r.maxOutbound,
+		OutboundTime:   r.outboundTime,
+	}
+	if len(req.EnvOverrides) > 0 {
+		deps.Env = env.NewOverrideStore(r.env, req.Context.FunctionID, req.EnvOverrides)
+	}
+	if req.Sandbox != nil {
+		deps.HTTP = req.Sandbox.HTTP
+		deps.AI = req.Sandbox.AI
+		deps.Email = req.Sandbox.Email
+	}
+	if req.DryRun {
+		deps.Logger = logger.NewMemoryLogger()
+		deps.HTTPTracker = internalhttp.NewMemoryTracker()
+		deps.AITracker = ai.NewMemoryTracker()
+		deps.EmailTracker = email.NewMemoryTracker()
 	}
 
+	// Runs with replaced clients or env never share warm states
 	runReq := Request{
-		Context: req.Context,
-		Event:   req.Event,
-		Code:    req.Code,
+		Context:         req.Context,
+		Event:           req.Event,
+		Code:            req.Code,
+		WebSocket:       req.WebSocket,
+		EventStream:     req.EventStream,
+		Trace:           req.Trace,
+		CaptureHTTP:     req.CaptureHTTP,
+		AIDefaults:      req.AIDefaults,
+		EmailDefaults:   req.EmailDefaults,
+		EmailAllowed:    req.EmailAllowed,
+		DisabledModules: req.DisabledModules,
+		Tags:            req.Tags,
+		Spans:           req.Spans,
+		ReuseState:      req.ReuseState && req.Sandbox == nil && !req.DryRun && len(req.EnvOverrides) == 0,
+		MaxLogEntries:   req.MaxLogEntries,
+		MaxOutbound:     req.MaxOutbound,
+		OutboundTime:    req.OutboundTime,
+		Seed:            req.Seed,
+		FrozenTime:      req.FrozenTime,
+		Middleware:      req.Middleware,
 	}
 
 	resp, err := Run(ctx, deps, runReq)
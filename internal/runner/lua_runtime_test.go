@@ -0,0 +1,122 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/services/env"
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+	"github.com/dimiro1/lunar/internal/services/kv"
+	"github.com/dimiro1/lunar/internal/services/logger"
+)
+
+func TestLuaRuntime_EnvOverrides(t *testing.T) {
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("test-function", "API_URL", "https://api.example.com")
+
+	rt := NewLuaRuntime(LuaRuntimeConfig{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    envStore,
+		HTTP:   &internalhttp.FakeClient{},
+	})
+
+	luaCode := `
+function handler(ctx, event)
+	local url = env.get("API_URL")
+	env.set("API_URL", "https://changed.example.com")
+	return {
+		statusCode = 200,
+		body = url
+	}
+end
+`
+
+	result, err := rt.Execute(context.Background(), engine.RuntimeRequest{
+		Code: luaCode,
+		Context: &events.ExecutionContext{
+			ExecutionID: "exec-123",
+			FunctionID:  "test-function",
+			StartedAt:   time.Now().Unix(),
+		},
+		Event:        events.HTTPEvent{Method: "GET", Path: "/"},
+		EnvOverrides: map[string]string{"API_URL": "https://sandbox.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.Response.Body != "https://sandbox.example.com" {
+		t.Errorf("expected overridden value, got %q", result.Response.Body)
+	}
+
+	value, _ := envStore.Get("test-function", "API_URL")
+	if value != "https://api.example.com" {
+		t.Errorf("expected stored value to be unchanged, got %q", value)
+	}
+}
+
+func TestLuaRuntime_Sandbox(t *testing.T) {
+	realHTTP := internalhttp.NewFakeClient()
+	rt := NewLuaRuntime(LuaRuntimeConfig{
+		Logger: logger.NewMemoryLogger(),
+		KV:     kv.NewMemoryStore(),
+		Env:    env.NewMemoryStore(),
+		HTTP:   realHTTP,
+	})
+
+	luaCode := `
+function handler(ctx, event)
+	local resp, err = http.post("https://api.example.com/orders", {body = "{}"})
+	if err then error(err) end
+	local chat, err = ai.chat({
+		provider = "openai",
+		model = "gpt-4o-mini",
+		messages = {{role = "user", content = "Hello!"}}
+	})
+	if err then error(err) end
+	local sent, err = email.send({
+		from = "app@example.com",
+		to = "user@example.com",
+		subject = "Hi",
+		text = "Hello"
+	})
+	if err then error(err) end
+	return {statusCode = 200, body = chat.content .. " " .. sent.id}
+end
+`
+
+	sandbox := engine.NewSandbox()
+	result, err := rt.Execute(context.Background(), engine.RuntimeRequest{
+		Code: luaCode,
+		Context: &events.ExecutionContext{
+			ExecutionID: "exec-123",
+			FunctionID:  "test-function",
+			StartedAt:   time.Now().Unix(),
+		},
+		Event:   events.HTTPEvent{Method: "GET", Path: "/"},
+		Sandbox: sandbox,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if want := engine.SandboxAIContent + " fake-1"; result.Response.Body != want {
+		t.Errorf("expected body %q, got %q", want, result.Response.Body)
+	}
+	if len(realHTTP.Requests) != 0 {
+		t.Errorf("expected no request through the runtime's client, got %d", len(realHTTP.Requests))
+	}
+	if len(sandbox.HTTP.Requests) != 1 || sandbox.HTTP.Requests[0].Method != "POST" {
+		t.Errorf("expected the POST recorded by the sandbox, got %+v", sandbox.HTTP.Requests)
+	}
+	if len(sandbox.AI.Requests) != 1 || sandbox.AI.Requests[0].Model != "gpt-4o-mini" {
+		t.Errorf("expected the chat recorded by the sandbox, got %+v", sandbox.AI.Requests)
+	}
+	if len(sandbox.Email.Requests) != 1 || sandbox.Email.Requests[0].Subject != "Hi" {
+		t.Errorf("expected the email recorded by the sandbox, got %+v", sandbox.Email.Requests)
+	}
+}
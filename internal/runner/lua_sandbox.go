@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// SandboxModules lists the global modules that can be disabled per function.
+// They reach outside the function (network, email, AI) or its stored state.
+var SandboxModules = []string{"kv", "env", "http", "ai", "email"}
+
+// registerSandbox replaces each disabled module with a table that raises an
+// error on any use, so a call like http.get fails with a clear message instead
+// of indexing nil. It must run after the modules are registered.
+func registerSandbox(L *lua.LState, disabled []string) {
+	for _, module := range SandboxModules {
+		if !slices.Contains(disabled, module) {
+			continue
+		}
+
+		message := fmt.Sprintf("%s module is disabled for this function", module)
+		raise := L.NewFunction(func(L *lua.LState) int {
+			L.RaiseError("%s", message)
+			return 0
+		})
+
+		meta := L.NewTable()
+		L.SetField(meta, "__index", raise)
+		L.SetField(meta, "__newindex", raise)
+
+		stub := L.NewTable()
+		L.SetMetatable(stub, meta)
+		L.SetGlobal(module, stub)
+	}
+}
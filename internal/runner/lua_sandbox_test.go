@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+)
+
+func runSandboxed(t *testing.T, code string, disabled []string) (Response, *internalhttp.FakeClient, error) {
+	t.Helper()
+
+	client := internalhttp.NewFakeClient()
+	client.SetResponse("GET", "https://example.com", internalhttp.Response{StatusCode: 200, Body: "ok"})
+
+	resp, _, err := runCode(t, code, func(deps *Dependencies, req *Request) {
+		deps.HTTP = client
+		req.DisabledModules = disabled
+	})
+	return resp, client, err
+}
+
+func TestRun_Sandbox_DisabledHTTP(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	local res = http.get("https://example.com")
+	return { statusCode = 200, body = res.body }
+end
+`
+
+	_, client, err := runSandboxed(t, code, []string{"http"})
+	if err == nil {
+		t.Fatal("expected error calling a disabled module")
+	}
+	if !strings.Contains(err.Error(), "http module is disabled for this function") {
+		t.Errorf("expected disabled module error, got %v", err)
+	}
+	if len(client.Requests) != 0 {
+		t.Errorf("expected no outbound requests, got %d", len(client.Requests))
+	}
+}
+
+func TestRun_Sandbox_OtherModulesEnabled(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	kv.set("key", "value")
+	local res = http.get("https://example.com")
+	return { statusCode = 200, body = res.body .. kv.get("key") }
+end
+`
+
+	resp, _, err := runSandboxed(t, code, []string{"ai", "email"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.Body != "okvalue" {
+		t.Errorf("expected body 'okvalue', got %q", resp.HTTP.Body)
+	}
+}
+
+func TestRun_Sandbox_UnusedDisabledModule(t *testing.T) {
+	// Only using a disabled module fails, not loading code that mentions it
+	code := `
+function handler(ctx, event)
+	if event.path == "/kv" then
+		kv.set("key", "value")
+	end
+	return { statusCode = 200 }
+end
+`
+
+	resp, _, err := runSandboxed(t, code, []string{"kv"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.HTTP.StatusCode != 200 {
+		t.Errorf("expected status 200, got %d", resp.HTTP.StatusCode)
+	}
+}
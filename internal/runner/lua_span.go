@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/dimiro1/lunar/internal/store"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Span limits
+const (
+	MaxExecutionSpans = 100
+	MaxSpanNameLength = 128
+)
+
+// registerSpans registers the trace module. trace.span(name) starts a named
+// timing span and returns a handle whose finish() ends it and returns its
+// duration in milliseconds; finishing a span again does nothing. Spans are
+// appended to spans when they start, so the engine can store those never
+// finished too, and nest under the spans still open at the time. Offsets are
+// measured from registration. A nil spans discards them.
+func registerSpans(L *lua.LState, spans *[]store.Span) {
+	if spans == nil {
+		spans = new([]store.Span)
+	}
+	start := time.Now()
+	open := 0
+
+	traceModule := L.NewTable()
+
+	L.SetField(traceModule, "span", L.NewFunction(func(L *lua.LState) int {
+		name := checkString(L, 1, "trace.span")
+		if name == "" || len(name) > MaxSpanNameLength {
+			L.ArgError(1, "span name must be 1-128 bytes")
+			return 0
+		}
+		if len(*spans) >= MaxExecutionSpans {
+			L.RaiseError("trace.span: at most %d spans per execution", MaxExecutionSpans)
+			return 0
+		}
+
+		began := time.Now()
+		index := len(*spans)
+		*spans = append(*spans, store.Span{
+			Name:       name,
+			StartMs:    milliseconds(began.Sub(start)),
+			Depth:      open,
+			Unfinished: true,
+		})
+		open++
+
+		handle := L.NewTable()
+		L.SetField(handle, "finish", L.NewFunction(func(L *lua.LState) int {
+			span := &(*spans)[index]
+			if span.Unfinished {
+				span.DurationMs = milliseconds(time.Since(began))
+				span.Unfinished = false
+				open--
+			}
+			L.Push(lua.LNumber(span.DurationMs))
+			return 1
+		}))
+		L.Push(handle)
+		return 1
+	}))
+
+	L.SetGlobal("trace", traceModule)
+}
+
+// milliseconds converts d to fractional milliseconds, rounded to microseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+func runSpans(t *testing.T, code string) ([]store.Span, error) {
+	t.Helper()
+
+	var spans []store.Span
+	_, _, err := runCode(t, code, func(_ *Dependencies, req *Request) {
+		req.Spans = &spans
+	})
+	return spans, err
+}
+
+func TestTrace_Span(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	local request = trace.span("request")
+	local query = trace.span("db_query")
+	local elapsed = query:finish()
+	if type(elapsed) ~= "number" or elapsed < 0 then
+		error("unexpected duration " .. tostring(elapsed))
+	end
+	query:finish()
+	trace.span("render").finish()
+	request.finish()
+	return { statusCode = 200 }
+end
+`
+
+	spans, err := runSpans(t, code)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %+v", spans)
+	}
+	for i, want := range []struct {
+		name  string
+		depth int
+	}{{"request", 0}, {"db_query", 1}, {"render", 1}} {
+		span := spans[i]
+		if span.Name != want.name || span.Depth != want.depth || span.Unfinished {
+			t.Errorf("span %d: expected %s at depth %d, got %+v", i, want.name, want.depth, span)
+		}
+	}
+	if spans[2].StartMs < spans[1].StartMs || spans[0].DurationMs < spans[1].DurationMs {
+		t.Errorf("expected nested spans to fit inside their parent, got %+v", spans)
+	}
+}
+
+func TestTrace_SpanUnfinishedOnError(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	trace.span("done").finish()
+	trace.span("pending")
+	error("boom")
+end
+`
+
+	spans, err := runSpans(t, code)
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if len(spans) != 2 || spans[0].Unfinished || !spans[1].Unfinished {
+		t.Errorf("expected the pending span to stay unfinished, got %+v", spans)
+	}
+}
+
+func TestTrace_SpanInvalidName(t *testing.T) {
+	for _, name := range []string{"", strings.Repeat("s", MaxSpanNameLength+1)} {
+		code := `
+function handler(ctx, event)
+	trace.span("` + name + `")
+	return { statusCode = 200 }
+end
+`
+		if _, err := runSpans(t, code); err == nil {
+			t.Errorf("expected span name %q to be rejected", name)
+		}
+	}
+}
+
+func TestTrace_SpanLimit(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	for i = 1, 101 do
+		trace.span("step").finish()
+	end
+	return { statusCode = 200 }
+end
+`
+
+	spans, err := runSpans(t, code)
+	if err == nil || !strings.Contains(err.Error(), "at most 100 spans") {
+		t.Errorf("expected the span limit error, got %v", err)
+	}
+	if len(spans) != MaxExecutionSpans {
+		t.Errorf("expected %d spans, got %d", MaxExecutionSpans, len(spans))
+	}
+}
@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"github.com/dimiro1/lunar/internal/events"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// registerSSE creates the global 'sse' table for streaming server-sent events.
+// The first sse.send switches the response to text/event-stream, after which
+// the handler's return value is ignored. The global stays nil when the
+// execution cannot stream, e.g. for WebSocket connections.
+func registerSSE(L *lua.LState, stream events.EventStream) {
+	if stream == nil {
+		return
+	}
+
+	sseTable := L.NewTable()
+
+	// sse.send(event, data) -> true | nil, error
+	// event may be nil for an unnamed message
+	L.SetField(sseTable, "send", L.NewFunction(func(L *lua.LState) int {
+		event := L.OptString(1, "")
+		data := L.CheckString(2)
+		if err := stream.Send(event, data); err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LTrue)
+		return 1
+	}))
+
+	L.SetGlobal("sse", sseTable)
+}
@@ -0,0 +1,221 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	"github.com/dimiro1/lunar/internal/services/logger"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// State pool defaults
+const (
+	DefaultMaxIdleStates    = 4
+	DefaultStateIdleTimeout = 5 * time.Minute
+
+	// cleanupTimeout bounds the cleanup hook of a discarded state, which runs
+	// outside of any execution
+	cleanupTimeout = 5 * time.Second
+)
+
+// runtimeModules lists the global modules registered for every execution.
+// A reused state gets them registered again, bound to the new execution.
+var runtimeModules = slices.Concat([]string{"log", "trace"}, tracedModules)
+
+// luaState is a Lua state with a function's code loaded and its init hook run
+type luaState struct {
+	L           *lua.LState
+	functionID  string
+	code        string
+	config      string     // Runtime config the modules were first registered with, see stateConfig
+	init        lua.LValue // What the init hook returned
+	executionID string     // Last execution that used the state, where cleanup logs go
+	idleSince   time.Time
+}
+
+// StatePool keeps the Lua states of functions with reuse_state warm between
+// executions, so their globals, app table and init result survive. A state
+// serves one execution at a time; concurrent executions of a function use
+// separate states. A nil *StatePool keeps no states.
+type StatePool struct {
+	maxIdle     int
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu   sync.Mutex
+	idle map[string][]*luaState // functionID -> idle states, oldest first
+}
+
+// NewStatePool creates a StatePool keeping up to maxIdle idle states per
+// function for up to idleTimeout. Zero values use the defaults.
+func NewStatePool(maxIdle int, idleTimeout time.Duration) *StatePool {
+	if maxIdle <= 0 {
+		maxIdle = DefaultMaxIdleStates
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultStateIdleTimeout
+	}
+	return &StatePool{
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		now:         time.Now,
+		idle:        make(map[string][]*luaState),
+	}
+}
+
+// acquire takes the most recently used idle state of the function that has
+// code loaded under config, or returns nil. It also returns the states that
+// can no longer be used, because they expired or run older code or config,
+// for the caller to discard.
+func (p *StatePool) acquire(functionID, code, config string) (*luaState, []*luaState) {
+	if p == nil {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stale := p.expire()
+
+	var current []*luaState
+	for _, st := range p.idle[functionID] {
+		if st.code == code && st.config == config {
+			current = append(current, st)
+		} else {
+			stale = append(stale, st)
+		}
+	}
+	if len(current) == 0 {
+		delete(p.idle, functionID)
+		return nil, stale
+	}
+
+	st := current[len(current)-1]
+	p.idle[functionID] = current[:len(current)-1]
+	return st, stale
+}
+
+// release returns st to the pool and returns the states evicted to make room
+// or because they expired, for the caller to discard
+func (p *StatePool) release(st *luaState) []*luaState {
+	if p == nil {
+		return []*luaState{st}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	evicted := p.expire()
+
+	st.idleSince = p.now()
+	states := append(p.idle[st.functionID], st)
+	if len(states) > p.maxIdle {
+		evicted = append(evicted, states[:len(states)-p.maxIdle]...)
+		states = slices.Clone(states[len(states)-p.maxIdle:])
+	}
+	p.idle[st.functionID] = states
+	return evicted
+}
+
+// expire removes and returns the states idle for longer than the idle timeout.
+// The caller must hold p.mu.
+func (p *StatePool) expire() []*luaState {
+	var expired []*luaState
+	cutoff := p.now().Add(-p.idleTimeout)
+	for functionID, states := range p.idle {
+		kept := states[:0]
+		for _, st := range states {
+			if st.idleSince.Before(cutoff) {
+				expired = append(expired, st)
+			} else {
+				kept = append(kept, st)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.idle, functionID)
+		} else {
+			p.idle[functionID] = kept
+		}
+	}
+	return expired
+}
+
+// discardStates runs the cleanup hook of each state and closes it
+func discardStates(states []*luaState, log logger.Logger) {
+	for _, st := range states {
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		st.L.SetContext(ctx)
+		runCleanup(st.L, log, st.executionID)
+		cancel()
+		st.L.Close()
+	}
+}
+
+// stateConfig describes the function settings the runtime modules are
+// registered with. Code can keep the module functions of the execution that
+// loaded it (local get = http.get), which rebindModules cannot reach, so a
+// warm state is only reused under the same settings.
+func stateConfig(req Request) string {
+	config, _ := json.Marshal(struct {
+		CaptureHTTP     bool
+		AIDefaults      ai.Defaults
+		EmailDefaults   email.Defaults
+		EmailAllowed    email.SenderAllowlist
+		DisabledModules []string
+		MaxLogEntries   int
+		MaxOutbound     int
+		OutboundTime    time.Duration
+		Middleware      []string
+	}{
+		req.CaptureHTTP, req.AIDefaults, req.EmailDefaults, req.EmailAllowed, req.DisabledModules,
+		req.MaxLogEntries, req.MaxOutbound, req.OutboundTime, req.Middleware,
+	})
+	return string(config)
+}
+
+// registerApp creates the app table, which keeps values for as long as the
+// state lives: across executions when the state is reused, otherwise only
+// for the current one
+func registerApp(L *lua.LState) {
+	L.SetGlobal("app", L.NewTable())
+}
+
+// rebindModules registers the runtime modules of a reused state for a new
+// execution through register. Code may hold references to the module tables
+// from earlier executions (local log = log), so the existing tables are
+// refilled in place rather than replaced. Modules that register is not
+// providing this time, like ws without a WebSocket, are emptied.
+func rebindModules(L *lua.LState, register func()) {
+	previous := make(map[string]*lua.LTable, len(runtimeModules))
+	for _, name := range runtimeModules {
+		if table, ok := L.GetGlobal(name).(*lua.LTable); ok {
+			previous[name] = table
+		}
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	register()
+
+	for name, old := range previous {
+		// Collect first, the table can't be modified while iterating
+		var keys []lua.LValue
+		old.ForEach(func(key, _ lua.LValue) { keys = append(keys, key) })
+		for _, key := range keys {
+			old.RawSet(key, lua.LNil)
+		}
+		L.SetMetatable(old, lua.LNil)
+
+		current, ok := L.GetGlobal(name).(*lua.LTable)
+		if !ok {
+			continue
+		}
+		current.ForEach(func(key, value lua.LValue) { old.RawSet(key, value) })
+		L.SetMetatable(old, L.GetMetatable(current))
+		L.SetGlobal(name, old)
+	}
+}
@@ -0,0 +1,285 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/luacode"
+	"github.com/dimiro1/lunar/internal/services/env"
+	"github.com/dimiro1/lunar/internal/services/kv"
+	"github.com/dimiro1/lunar/internal/services/logger"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+const counterCode = `
+local log = log
+local inits = 0
+
+function init()
+	inits = inits + 1
+	return { started = true }
+end
+
+function handler(ctx, event, state)
+	app.count = (app.count or 0) + 1
+	log.info("count " .. app.count)
+	if event.path == "/fail" then
+		error("boom")
+	end
+	return { statusCode = 200, body = app.count .. " " .. inits .. " " .. tostring(state.started) }
+end
+`
+
+type warmRunner struct {
+	t     *testing.T
+	deps  Dependencies
+	log   *logger.MemoryLogger
+	runs  int
+	reuse bool
+	edit  func(*Request) // Adjusts the requests when set
+}
+
+func newWarmRunner(t *testing.T, pool *StatePool) *warmRunner {
+	log := logger.NewMemoryLogger()
+	return &warmRunner{
+		t:     t,
+		log:   log,
+		reuse: true,
+		deps: Dependencies{
+			Logger: log,
+			KV:     kv.NewMemoryStore(),
+			Env:    env.NewMemoryStore(),
+			States: pool,
+		},
+	}
+}
+
+// run executes code for functionID under a new execution ID, exec-1, exec-2...
+func (w *warmRunner) run(functionID, code, path string) (Response, error) {
+	w.t.Helper()
+	w.runs++
+	req := Request{
+		Context: &events.ExecutionContext{
+			ExecutionID: fmt.Sprintf("exec-%d", w.runs),
+			FunctionID:  functionID,
+			StartedAt:   time.Now().Unix(),
+		},
+		Event:      events.HTTPEvent{Method: "GET", Path: path},
+		Code:       code,
+		ReuseState: w.reuse,
+	}
+	if w.edit != nil {
+		w.edit(&req)
+	}
+	return Run(context.Background(), w.deps, req)
+}
+
+func (w *warmRunner) body(functionID, code string) string {
+	w.t.Helper()
+	resp, err := w.run(functionID, code, "/")
+	if err != nil {
+		w.t.Fatalf("Run failed: %v", err)
+	}
+	return resp.HTTP.Body
+}
+
+func TestRun_ReuseState_AppPersists(t *testing.T) {
+	w := newWarmRunner(t, NewStatePool(0, 0))
+
+	if body := w.body("fn-1", counterCode); body != "1 1 true" {
+		t.Errorf("expected first run '1 1 true', got %q", body)
+	}
+	if body := w.body("fn-1", counterCode); body != "2 1 true" {
+		t.Errorf("expected app to persist and init to run once, got %q", body)
+	}
+
+	// Captured module tables are bound to the current execution
+	entries := w.log.Entries("exec-2")
+	if len(entries) != 1 || entries[0].Message != "count 2" {
+		t.Errorf("expected log of the second run under exec-2, got %+v", entries)
+	}
+}
+
+func TestRun_ReuseState_IsolatedPerFunction(t *testing.T) {
+	w := newWarmRunner(t, NewStatePool(0, 0))
+
+	w.body("fn-1", counterCode)
+	if body := w.body("fn-2", counterCode); body != "1 1 true" {
+		t.Errorf("expected a fresh state for another function, got %q", body)
+	}
+}
+
+func TestRun_ReuseState_Disabled(t *testing.T) {
+	w := newWarmRunner(t, NewStatePool(0, 0))
+	w.reuse = false
+
+	w.body("fn-1", counterCode)
+	if body := w.body("fn-1", counterCode); body != "1 1 true" {
+		t.Errorf("expected a fresh state without reuse_state, got %q", body)
+	}
+}
+
+func TestRun_ReuseState_ConfigChange(t *testing.T) {
+	w := newWarmRunner(t, NewStatePool(0, 0))
+
+	w.body("fn-1", counterCode)
+	w.edit = func(req *Request) { req.DisabledModules = []string{"http"} }
+	if body := w.body("fn-1", counterCode); body != "1 1 true" {
+		t.Errorf("expected a fresh state for new settings, got %q", body)
+	}
+	if body := w.body("fn-1", counterCode); body != "2 1 true" {
+		t.Errorf("expected the state to be reused under the same settings, got %q", body)
+	}
+}
+
+func TestRun_ReuseState_Overrides(t *testing.T) {
+	seed := int64(42)
+	frozen := time.Now().Unix()
+	overrides := map[string]func(*Request){
+		"trace":       func(req *Request) { req.Trace = true },
+		"seed":        func(req *Request) { req.Seed = &seed },
+		"frozen time": func(req *Request) { req.FrozenTime = &frozen },
+	}
+
+	for name, edit := range overrides {
+		t.Run(name, func(t *testing.T) {
+			w := newWarmRunner(t, NewStatePool(0, 0))
+
+			w.body("fn-1", counterCode)
+			w.edit = edit
+			if body := w.body("fn-1", counterCode); body != "1 1 true" {
+				t.Errorf("expected a fresh state for an override, got %q", body)
+			}
+			w.edit = nil
+			if body := w.body("fn-1", counterCode); body != "2 1 true" {
+				t.Errorf("expected the override run not to take or keep the warm state, got %q", body)
+			}
+		})
+	}
+}
+
+func TestRun_ReuseState_RebindsTrace(t *testing.T) {
+	w := newWarmRunner(t, NewStatePool(0, 0))
+	var spans *[]store.Span
+	w.edit = func(req *Request) {
+		spans = new([]store.Span)
+		req.Spans = spans
+	}
+	code := `
+local trace = trace
+
+function handler(ctx, event)
+	trace.span("work"):finish()
+	return { statusCode = 200 }
+end
+`
+
+	for run := 1; run <= 2; run++ {
+		if _, err := w.run("fn-1", code, "/"); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(*spans) != 1 || (*spans)[0].Name != "work" {
+			t.Errorf("expected run %d to record its span, got %+v", run, *spans)
+		}
+	}
+}
+
+func TestRun_ReuseState_DiscardedAfterError(t *testing.T) {
+	w := newWarmRunner(t, NewStatePool(0, 0))
+
+	w.body("fn-1", counterCode)
+	if _, err := w.run("fn-1", counterCode, "/fail"); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if body := w.body("fn-1", counterCode); body != "1 1 true" {
+		t.Errorf("expected a fresh state after a failed run, got %q", body)
+	}
+}
+
+func TestRun_ReuseState_CodeChangeRunsCleanup(t *testing.T) {
+	w := newWarmRunner(t, NewStatePool(0, 0))
+	code := counterCode + `
+function cleanup()
+	log.info("cleanup " .. app.count)
+end
+`
+
+	w.body("fn-1", code)
+	if entries := w.log.Entries("exec-1"); len(entries) != 1 {
+		t.Fatalf("expected cleanup not to run while the state is warm, got %+v", entries)
+	}
+
+	if body := w.body("fn-1", code+"\n-- v2\n"); body != "1 1 true" {
+		t.Errorf("expected a fresh state for new code, got %q", body)
+	}
+	entries := w.log.Entries("exec-1")
+	if len(entries) != 2 || entries[1].Message != "cleanup 1" {
+		t.Errorf("expected cleanup of the stale state in exec-1 logs, got %+v", entries)
+	}
+}
+
+func TestStatePool_Limits(t *testing.T) {
+	pool := NewStatePool(2, time.Minute)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	var states []*luaState
+	for range 3 {
+		states = append(states, &luaState{functionID: "fn-1", code: "code"})
+	}
+
+	if evicted := pool.release(states[0]); len(evicted) != 0 {
+		t.Errorf("expected nothing evicted, got %d", len(evicted))
+	}
+	pool.release(states[1])
+	if evicted := pool.release(states[2]); len(evicted) != 1 || evicted[0] != states[0] {
+		t.Errorf("expected the oldest state evicted, got %+v", evicted)
+	}
+
+	st, stale := pool.acquire("fn-1", "code", "")
+	if st != states[2] || len(stale) != 0 {
+		t.Errorf("expected the most recent state, got %+v (stale %d)", st, len(stale))
+	}
+
+	now = now.Add(2 * time.Minute)
+	st, stale = pool.acquire("fn-1", "code", "")
+	if st != nil || len(stale) != 1 || stale[0] != states[1] {
+		t.Errorf("expected the idle state to expire, got %+v (stale %d)", st, len(stale))
+	}
+}
+
+// TestLintGlobals checks the globals the linter knows against the ones an
+// execution registers, plus the modules only some event types get
+func TestLintGlobals(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	local names = {}
+	for name in pairs(_G) do
+		if name ~= "handler" then
+			table.insert(names, name)
+		end
+	end
+	return { statusCode = 200, body = table.concat(names, ",") }
+end
+`
+
+	resp, _, err := runCode(t, code, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	registered := slices.Concat(strings.Split(resp.HTTP.Body, ","), runtimeModules)
+	slices.Sort(registered)
+	registered = slices.Compact(registered)
+
+	known := slices.Clone(luacode.Globals)
+	slices.Sort(known)
+	if !slices.Equal(known, registered) {
+		t.Errorf("luacode.Globals is out of date\nknown:      %v\nregistered: %v", known, registered)
+	}
+}
@@ -29,29 +29,29 @@ func registerStrings(L *lua.LState) {
 // stringsTrim removes leading and trailing whitespace
 // Usage: local result = strings.trim(str)
 func stringsTrim(L *lua.LState) int {
-	L.Push(lua.LString(stdlibstrings.Trim(L.CheckString(1))))
+	L.Push(lua.LString(stdlibstrings.Trim(checkString(L, 1, "strings.trim"))))
 	return 1
 }
 
 // stringsTrimLeft removes leading whitespace
 // Usage: local result = strings.trimLeft(str)
 func stringsTrimLeft(L *lua.LState) int {
-	L.Push(lua.LString(stdlibstrings.TrimLeft(L.CheckString(1))))
+	L.Push(lua.LString(stdlibstrings.TrimLeft(checkString(L, 1, "strings.trimLeft"))))
 	return 1
 }
 
 // stringsTrimRight removes trailing whitespace
 // Usage: local result = strings.trimRight(str)
 func stringsTrimRight(L *lua.LState) int {
-	L.Push(lua.LString(stdlibstrings.TrimRight(L.CheckString(1))))
+	L.Push(lua.LString(stdlibstrings.TrimRight(checkString(L, 1, "strings.trimRight"))))
 	return 1
 }
 
 // stringsSplit splits a string by a separator
 // Usage: local parts = strings.split(str, sep)
 func stringsSplit(L *lua.LState) int {
-	str := L.CheckString(1)
-	sep := L.CheckString(2)
+	str := checkString(L, 1, "strings.split")
+	sep := checkString(L, 2, "strings.split")
 
 	parts := stdlibstrings.Split(str, sep)
 
@@ -68,8 +68,8 @@ func stringsSplit(L *lua.LState) int {
 // stringsJoin joins an array of strings with a separator
 // Usage: local result = strings.join(array, sep)
 func stringsJoin(L *lua.LState) int {
-	array := L.CheckTable(1)
-	sep := L.CheckString(2)
+	array := checkTable(L, 1, "strings.join")
+	sep := checkString(L, 2, "strings.join")
 
 	// Convert Lua table to string slice
 	var parts []string
@@ -84,14 +84,14 @@ func stringsJoin(L *lua.LState) int {
 // stringsHasPrefix checks if string has prefix
 // Usage: local result = strings.hasPrefix(str, prefix)
 func stringsHasPrefix(L *lua.LState) int {
-	L.Push(lua.LBool(stdlibstrings.HasPrefix(L.CheckString(1), L.CheckString(2))))
+	L.Push(lua.LBool(stdlibstrings.HasPrefix(checkString(L, 1, "strings.hasPrefix"), checkString(L, 2, "strings.hasPrefix"))))
 	return 1
 }
 
 // stringsHasSuffix checks if string has suffix
 // Usage: local result = strings.hasSuffix(str, suffix)
 func stringsHasSuffix(L *lua.LState) int {
-	L.Push(lua.LBool(stdlibstrings.HasSuffix(L.CheckString(1), L.CheckString(2))))
+	L.Push(lua.LBool(stdlibstrings.HasSuffix(checkString(L, 1, "strings.hasSuffix"), checkString(L, 2, "strings.hasSuffix"))))
 	return 1
 }
 
@@ -99,10 +99,10 @@ func stringsHasSuffix(L *lua.LState) int {
 // Usage: local result = strings.replace(str, old, new, n)
 // n is optional: -1 means replace all (default), 1 means replace first, etc.
 func stringsReplace(L *lua.LState) int {
-	str := L.CheckString(1)
-	old := L.CheckString(2)
-	replacement := L.CheckString(3)
-	n := L.OptInt(4, -1)
+	str := checkString(L, 1, "strings.replace")
+	old := checkString(L, 2, "strings.replace")
+	replacement := checkString(L, 3, "strings.replace")
+	n := optInt(L, 4, "strings.replace", -1)
 
 	L.Push(lua.LString(stdlibstrings.Replace(str, old, replacement, n)))
 	return 1
@@ -111,27 +111,27 @@ func stringsReplace(L *lua.LState) int {
 // stringsToLower converts string to lowercase
 // Usage: local result = strings.toLower(str)
 func stringsToLower(L *lua.LState) int {
-	L.Push(lua.LString(stdlibstrings.ToLower(L.CheckString(1))))
+	L.Push(lua.LString(stdlibstrings.ToLower(checkString(L, 1, "strings.toLower"))))
 	return 1
 }
 
 // stringsToUpper converts string to uppercase
 // Usage: local result = strings.toUpper(str)
 func stringsToUpper(L *lua.LState) int {
-	L.Push(lua.LString(stdlibstrings.ToUpper(L.CheckString(1))))
+	L.Push(lua.LString(stdlibstrings.ToUpper(checkString(L, 1, "strings.toUpper"))))
 	return 1
 }
 
 // stringsContains checks if string contains substring
 // Usage: local result = strings.contains(str, substr)
 func stringsContains(L *lua.LState) int {
-	L.Push(lua.LBool(stdlibstrings.Contains(L.CheckString(1), L.CheckString(2))))
+	L.Push(lua.LBool(stdlibstrings.Contains(checkString(L, 1, "strings.contains"), checkString(L, 2, "strings.contains"))))
 	return 1
 }
 
 // stringsRepeat repeats a string n times
 // Usage: local result = strings.repeat(str, n)
 func stringsRepeat(L *lua.LState) int {
-	L.Push(lua.LString(stdlibstrings.Repeat(L.CheckString(1), L.CheckInt(2))))
+	L.Push(lua.LString(stdlibstrings.Repeat(checkString(L, 1, "strings.repeat"), checkInt(L, 2, "strings.repeat"))))
 	return 1
 }
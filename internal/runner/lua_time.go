@@ -6,11 +6,11 @@ import (
 )
 
 // registerTime registers the time module with time-related functions.
-// This is a thin wrapper around the stdlib/time package.
-func registerTime(L *lua.LState) {
+// This is a thin wrapper around the stdlib/time package. time.now reads now.
+func registerTime(L *lua.LState, now func() int64) {
 	timeModule := L.NewTable()
 
-	L.SetField(timeModule, "now", L.NewFunction(timeNow))
+	L.SetField(timeModule, "now", L.NewFunction(timeNow(now)))
 	L.SetField(timeModule, "format", L.NewFunction(timeFormat))
 	L.SetField(timeModule, "parse", L.NewFunction(timeParse))
 	L.SetField(timeModule, "sleep", L.NewFunction(timeSleep(L)))
@@ -18,19 +18,31 @@ func registerTime(L *lua.LState) {
 	L.SetGlobal("time", timeModule)
 }
 
+// timeClock returns the clock of time.now for req, pinned to its frozen
+// time when one is set
+func timeClock(req Request) func() int64 {
+	if req.FrozenTime != nil {
+		frozen := *req.FrozenTime
+		return func() int64 { return frozen }
+	}
+	return stdlibtime.Now
+}
+
 // timeNow returns the current Unix timestamp in seconds
 // Usage: local timestamp = time.now()
-func timeNow(L *lua.LState) int {
-	L.Push(lua.LNumber(stdlibtime.Now()))
-	return 1
+func timeNow(now func() int64) lua.LGFunction {
+	return func(L *lua.LState) int {
+		L.Push(lua.LNumber(now()))
+		return 1
+	}
 }
 
 // timeFormat formats a Unix timestamp to a string
 // Uses Go's time format layout (e.g., "2006-01-02 15:04:05")
 // Usage: local formatted = time.format(timestamp, layout)
 func timeFormat(L *lua.LState) int {
-	timestamp := L.CheckNumber(1)
-	layout := L.CheckString(2)
+	timestamp := checkNumber(L, 1, "time.format")
+	layout := checkString(L, 2, "time.format")
 
 	formatted := stdlibtime.Format(int64(timestamp), layout)
 	L.Push(lua.LString(formatted))
@@ -41,8 +53,8 @@ func timeFormat(L *lua.LState) int {
 // Returns Unix timestamp or nil + error
 // Usage: local timestamp, err = time.parse(timeStr, layout)
 func timeParse(L *lua.LState) int {
-	timeStr := L.CheckString(1)
-	layout := L.CheckString(2)
+	timeStr := checkString(L, 1, "time.parse")
+	layout := checkString(L, 2, "time.parse")
 
 	timestamp, err := stdlibtime.Parse(timeStr, layout)
 	if err != nil {
@@ -61,7 +73,7 @@ func timeParse(L *lua.LState) int {
 // Usage: time.sleep(1000)  -- sleep for 1 second
 func timeSleep(L *lua.LState) lua.LGFunction {
 	return func(L *lua.LState) int {
-		milliseconds := L.CheckNumber(1)
+		milliseconds := checkNumber(L, 1, "time.sleep")
 		stdlibtime.Sleep(L.Context(), int64(milliseconds))
 		return 0
 	}
@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/masking"
+	"github.com/dimiro1/lunar/internal/services/logger"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// tracedModules lists the global modules whose functions are wrapped in trace
+// mode. The log module is left out so traces don't trace themselves.
+var tracedModules = []string{
+	"kv", "env", "http", "json", "base64", "crypto", "time", "url",
+	"strings", "random", "respond", "router", "ai", "email", "ws", "sse", "execution",
+}
+
+// secretArguments lists, per binding, the argument positions holding secrets
+var secretArguments = map[string][]int{
+	"env.set":            {2},
+	"crypto.hmac_sha1":   {2},
+	"crypto.hmac_sha256": {2},
+	"crypto.hmac_sha512": {2},
+	"crypto.sign_url":    {3},
+	"crypto.verify_url":  {2},
+}
+
+const (
+	traceMaxString = 64 // Longer strings are truncated in traces
+	traceMaxFields = 10 // Tables show at most this many fields
+	traceMaxDepth  = 2  // Nested tables deeper than this are elided
+)
+
+// registerTrace wraps every function of the traced modules so each call is
+// written to the execution logs with its arguments and duration. It must run
+// after the modules are registered.
+func registerTrace(L *lua.LState, log logger.Logger, executionID string) {
+	for _, module := range tracedModules {
+		table, ok := L.GetGlobal(module).(*lua.LTable)
+		if !ok {
+			continue
+		}
+
+		// Collect first, the table can't be modified while iterating
+		var names []string
+		table.ForEach(func(key, value lua.LValue) {
+			if fn, ok := value.(*lua.LFunction); ok && fn.IsG {
+				names = append(names, lua.LVAsString(key))
+			}
+		})
+
+		for _, name := range names {
+			fn := table.RawGetString(name).(*lua.LFunction)
+			table.RawSetString(name, traceFunction(L, fn, module+"."+name, log, executionID))
+		}
+	}
+}
+
+// traceFunction returns a function that logs each call to fn before returning its results
+func traceFunction(L *lua.LState, fn *lua.LFunction, name string, log logger.Logger, executionID string) *lua.LFunction {
+	return L.NewFunction(func(L *lua.LState) int {
+		call := fmt.Sprintf("%s(%s)", name, formatTraceArgs(L, name))
+		start := time.Now()
+
+		// Lua errors unwind as panics, log them before passing them on
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Debug(executionID, masking.MaskLogMessage(fmt.Sprintf("trace: %s failed after %s", call, time.Since(start))))
+				panic(rec)
+			}
+		}()
+
+		n := fn.GFunction(L)
+		log.Debug(executionID, masking.MaskLogMessage(fmt.Sprintf("trace: %s took %s", call, time.Since(start))))
+		return n
+	})
+}
+
+// formatTraceArgs renders the arguments of the current call, redacting secrets
+func formatTraceArgs(L *lua.LState, name string) string {
+	args := make([]string, 0, L.GetTop())
+	for i := 1; i <= L.GetTop(); i++ {
+		if slices.Contains(secretArguments[name], i) {
+			args = append(args, "[REDACTED]")
+			continue
+		}
+		args = append(args, formatTraceValue(L.Get(i), 0))
+	}
+	return strings.Join(args, ", ")
+}
+
+// formatTraceValue renders a Lua value compactly, redacting sensitive table fields
+func formatTraceValue(value lua.LValue, depth int) string {
+	switch v := value.(type) {
+	case lua.LString:
+		s := string(v)
+		if len(s) > traceMaxString {
+			s = s[:traceMaxString] + "..."
+		}
+		return strconv.Quote(s)
+	case *lua.LTable:
+		if depth >= traceMaxDepth {
+			return "{...}"
+		}
+		var fields []string
+		truncated := false
+		v.ForEach(func(key, field lua.LValue) {
+			if len(fields) == traceMaxFields {
+				truncated = true
+				return
+			}
+			k, named := key.(lua.LString)
+			switch {
+			case !named:
+				fields = append(fields, formatTraceValue(field, depth+1))
+			case masking.IsSensitiveKey(string(k)):
+				fields = append(fields, string(k)+"=[REDACTED]")
+			default:
+				fields = append(fields, string(k)+"="+formatTraceValue(field, depth+1))
+			}
+		})
+		if truncated {
+			fields = append(fields, "...")
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case *lua.LFunction:
+		return "function"
+	default:
+		return value.String()
+	}
+}
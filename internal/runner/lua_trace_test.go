@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+	"github.com/dimiro1/lunar/internal/services/logger"
+)
+
+const traceTestCode = `
+function handler(ctx, event)
+	kv.set("greeting", "hello")
+	local sig = crypto.hmac_sha256("payload", "super-secret-key")
+	local body = json.encode({ user = "ana", password = "hunter2" })
+	log.info("done")
+	return { statusCode = 200, body = body }
+end
+`
+
+func runTraced(t *testing.T, trace bool) []logger.LogEntry {
+	t.Helper()
+
+	_, log, err := runCode(t, traceTestCode, func(deps *Dependencies, req *Request) {
+		deps.HTTP = &internalhttp.FakeClient{}
+		req.Trace = trace
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return log.Entries("exec-123")
+}
+
+func TestRun_Trace(t *testing.T) {
+	var traces []string
+	for _, entry := range runTraced(t, true) {
+		if strings.HasPrefix(entry.Message, "trace: ") {
+			if entry.Level != logger.Debug {
+				t.Errorf("expected trace at debug level, got %v", entry.Level)
+			}
+			traces = append(traces, entry.Message)
+		}
+	}
+
+	if len(traces) != 3 {
+		t.Fatalf("expected 3 traced calls, got %d: %v", len(traces), traces)
+	}
+
+	expected := []string{
+		`trace: kv.set("greeting", "hello") took `,
+		`trace: crypto.hmac_sha256("payload", [REDACTED]) took `,
+		`trace: json.encode({`,
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(traces[i], prefix) {
+			t.Errorf("trace %d: expected prefix %q, got %q", i, prefix, traces[i])
+		}
+	}
+
+	all := strings.Join(traces, "\n")
+	if strings.Contains(all, "super-secret-key") || strings.Contains(all, "hunter2") {
+		t.Errorf("expected secrets to be redacted, got %q", all)
+	}
+	if !strings.Contains(all, `user="ana"`) {
+		t.Errorf("expected non-secret table fields to be traced, got %q", all)
+	}
+}
+
+func TestRun_TraceDisabled(t *testing.T) {
+	for _, entry := range runTraced(t, false) {
+		if strings.HasPrefix(entry.Message, "trace: ") {
+			t.Errorf("expected no traces by default, got %q", entry.Message)
+		}
+	}
+}
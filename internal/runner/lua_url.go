@@ -21,7 +21,7 @@ func registerURL(L *lua.LState) {
 // Usage: local parsed, err = url.parse(urlStr)
 // Returns: { scheme, host, path, query, fragment, username, password }
 func urlParse(L *lua.LState) int {
-	urlStr := L.CheckString(1)
+	urlStr := checkString(L, 1, "url.parse")
 
 	parsedURL, err := stdliburl.Parse(urlStr)
 	if err != nil {
@@ -69,14 +69,14 @@ func urlParse(L *lua.LState) int {
 // urlEncode URL-encodes a string
 // Usage: local encoded = url.encode(str)
 func urlEncode(L *lua.LState) int {
-	L.Push(lua.LString(stdliburl.Encode(L.CheckString(1))))
+	L.Push(lua.LString(stdliburl.Encode(checkString(L, 1, "url.encode"))))
 	return 1
 }
 
 // urlDecode URL-decodes a string
 // Usage: local decoded, err = url.decode(str)
 func urlDecode(L *lua.LState) int {
-	decoded, err := stdliburl.Decode(L.CheckString(1))
+	decoded, err := stdliburl.Decode(checkString(L, 1, "url.decode"))
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(err.Error()))
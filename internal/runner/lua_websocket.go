@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dimiro1/lunar/internal/events"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// registerWebSocket creates the global 'ws' table for functions handling an
+// upgraded connection. The global stays nil for regular HTTP requests.
+// The connection is closed when ctx is done, so a blocked ws.receive()
+// cannot outlive the execution timeout.
+func registerWebSocket(L *lua.LState, ctx context.Context, conn events.WebSocket) {
+	if conn == nil {
+		return
+	}
+
+	context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
+	wsTable := L.NewTable()
+
+	// ws.send(message) -> true | nil, error
+	L.SetField(wsTable, "send", L.NewFunction(func(L *lua.LState) int {
+		message := L.CheckString(1)
+		if err := conn.Send(message); err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LTrue)
+		return 1
+	}))
+
+	// ws.receive() -> message | nil, error
+	// The error is "closed" once the client disconnects.
+	L.SetField(wsTable, "receive", L.NewFunction(func(L *lua.LState) int {
+		message, err := conn.Receive()
+		if err != nil {
+			L.Push(lua.LNil)
+			if errors.Is(err, events.ErrWebSocketClosed) {
+				L.Push(lua.LString("closed"))
+			} else {
+				L.Push(lua.LString(err.Error()))
+			}
+			return 2
+		}
+		L.Push(lua.LString(message))
+		return 1
+	}))
+
+	// ws.close()
+	L.SetField(wsTable, "close", L.NewFunction(func(L *lua.LState) int {
+		_ = conn.Close()
+		return 0
+	}))
+
+	L.SetGlobal("ws", wsTable)
+}
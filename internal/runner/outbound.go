@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/dimiro1/lunar/internal/outbound"
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/email"
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+)
+
+// outboundBudget returns the outbound budget of req. A function may lower the
+// server's limits but not raise them.
+func outboundBudget(deps Dependencies, req Request) *outbound.Budget {
+	return outbound.NewBudget(
+		lowerLimit(deps.MaxOutbound, req.MaxOutbound),
+		lowerLimit(deps.OutboundTime, req.OutboundTime),
+	)
+}
+
+// lowerLimit returns the lower of two limits, where zero means no limit
+func lowerLimit[T int | time.Duration](server, function T) T {
+	switch {
+	case server > 0 && function > 0:
+		return min(server, function)
+	case function > 0:
+		return function
+	default:
+		return server
+	}
+}
+
+// limitOutbound returns deps with the http, ai and email clients counting
+// their calls against budget. A nil budget leaves the clients unchanged.
+func limitOutbound(deps Dependencies, budget *outbound.Budget) Dependencies {
+	if budget == nil {
+		return deps
+	}
+	if deps.HTTP != nil {
+		deps.HTTP = internalhttp.NewLimitedClient(deps.HTTP, budget)
+	}
+	if deps.AI != nil {
+		deps.AI = ai.NewLimitedClient(deps.AI, budget)
+	}
+	if deps.Email != nil {
+		deps.Email = email.NewLimitedClient(deps.Email, budget)
+	}
+	return deps
+}
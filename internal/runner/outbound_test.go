@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/events"
+	"github.com/dimiro1/lunar/internal/services/email"
+	"github.com/dimiro1/lunar/internal/services/env"
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+	"github.com/dimiro1/lunar/internal/services/kv"
+	"github.com/dimiro1/lunar/internal/services/logger"
+)
+
+// outboundCode makes three http calls and then sends an email, reporting the
+// first error in the response body
+const outboundCode = `
+function handler(ctx, event)
+	for i = 1, 3 do
+		local res, err = http.get("https://example.com")
+		if err then
+			return { statusCode = 429, body = "http " .. i .. ": " .. err }
+		end
+	end
+	local _, err = email.send({ from = "app@example.com", to = "user@example.com", subject = "Hi", text = "Hello" })
+	if err then
+		return { statusCode = 429, body = "email: " .. err }
+	end
+	return { statusCode = 200 }
+end
+`
+
+func runOutbound(t *testing.T, serverLimit, functionLimit int) (Response, *internalhttp.FakeClient, *email.FakeClient) {
+	t.Helper()
+
+	httpClient := internalhttp.NewFakeClient()
+	httpClient.SetResponse("GET", "https://example.com", internalhttp.Response{StatusCode: 200, Body: "ok"})
+	emailClient := email.NewFakeClient()
+
+	resp, _, err := runCode(t, outboundCode, func(deps *Dependencies, req *Request) {
+		deps.HTTP = httpClient
+		deps.Email = emailClient
+		deps.MaxOutbound = serverLimit
+		req.MaxOutbound = functionLimit
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return resp, httpClient, emailClient
+}
+
+func TestRun_MaxOutbound(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverLimit   int
+		functionLimit int
+		wantBody      string
+		wantHTTP      int
+		wantEmails    int
+	}{
+		{"unlimited", 0, 0, "", 3, 1},
+		{"server limit", 2, 0, "http 3: outbound http call rejected: the execution reached its limit of 2 outbound calls", 2, 0},
+		{"shared by email", 3, 0, "email: outbound email call rejected: the execution reached its limit of 3 outbound calls", 3, 0},
+		{"function limit", 0, 1, "http 2: outbound http call rejected: the execution reached its limit of 1 outbound calls", 1, 0},
+		{"function cannot raise the server limit", 2, 10, "http 3: outbound http call rejected: the execution reached its limit of 2 outbound calls", 2, 0},
+		{"function lowers the server limit", 10, 3, "email: outbound email call rejected: the execution reached its limit of 3 outbound calls", 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, httpClient, emailClient := runOutbound(t, tt.serverLimit, tt.functionLimit)
+			if resp.HTTP.Body != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, resp.HTTP.Body)
+			}
+			if len(httpClient.Requests) != tt.wantHTTP {
+				t.Errorf("expected %d http requests, got %d", tt.wantHTTP, len(httpClient.Requests))
+			}
+			if len(emailClient.Requests) != tt.wantEmails {
+				t.Errorf("expected %d emails, got %d", tt.wantEmails, len(emailClient.Requests))
+			}
+		})
+	}
+}
+
+// slowClient answers every request after a delay
+type slowClient struct {
+	*internalhttp.FakeClient
+	delay time.Duration
+}
+
+func (c slowClient) Get(req internalhttp.Request) (internalhttp.Response, error) {
+	time.Sleep(c.delay)
+	return c.FakeClient.Get(req)
+}
+
+func TestRun_OutboundTime(t *testing.T) {
+	code := `
+function handler(ctx, event)
+	for i = 1, 20 do
+		local _, err = http.get("https://example.com")
+		if err then
+			return { statusCode = 504, body = "http " .. i .. ": " .. err }
+		end
+	end
+	return { statusCode = 200 }
+end
+`
+
+	client := slowClient{FakeClient: internalhttp.NewFakeClient(), delay: 20 * time.Millisecond}
+	deps := Dependencies{
+		Logger:       logger.NewMemoryLogger(),
+		KV:           kv.NewMemoryStore(),
+		Env:          env.NewMemoryStore(),
+		HTTP:         client,
+		Timeout:      5 * time.Second,
+		OutboundTime: time.Second,
+	}
+	execCtx := &events.ExecutionContext{
+		ExecutionID: "exec-123",
+		FunctionID:  "test-function",
+		StartedAt:   time.Now().Unix(),
+	}
+
+	start := time.Now()
+	resp, err := Run(context.Background(), deps, Request{
+		Context:      execCtx,
+		Event:        events.HTTPEvent{Method: "GET", Path: "/"},
+		Code:         code,
+		OutboundTime: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if resp.HTTP.StatusCode != 504 || !strings.Contains(resp.HTTP.Body, "outbound time budget of 50ms") {
+		t.Errorf("expected the function's budget to reject a call, got %d: %s", resp.HTTP.StatusCode, resp.HTTP.Body)
+	}
+	if calls := len(client.Requests); calls < 2 || calls > 3 {
+		t.Errorf("expected the calls to stop once 50ms were spent, got %d calls", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the budget to fail fast before the timeout, took %s", elapsed)
+	}
+}
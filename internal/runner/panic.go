@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// PanicError indicates a Go panic raised while running a function, usually by
+// a binding that received arguments it did not expect. It is reported as an
+// execution error instead of crashing the process.
+type PanicError struct {
+	Value     any    // Value passed to panic
+	Traceback string // Lua and Go stack at the point of the panic
+}
+
+func (e *PanicError) Error() string {
+	if e.Traceback == "" {
+		return fmt.Sprintf("function panicked: %v", e.Value)
+	}
+	return fmt.Sprintf("function panicked: %v\n%s", e.Value, e.Traceback)
+}
+
+// newPanicError builds a PanicError from a recovered value, capturing the
+// current Go stack as the traceback
+func newPanicError(value any) *PanicError {
+	return &PanicError{Value: value, Traceback: string(debug.Stack())}
+}
+
+// asPanicError reports whether err is a panic recovered by gopher-lua inside
+// a protected call and converts it to a PanicError
+func asPanicError(err error) (*PanicError, bool) {
+	var apiErr *lua.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Type != lua.ApiErrorPanic {
+		return nil, false
+	}
+	return &PanicError{Value: apiErr.Object.String(), Traceback: apiErr.StackTrace}, true
+}
@@ -2,16 +2,20 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/dimiro1/lunar/internal/engine"
+	"github.com/dimiro1/lunar/internal/events"
 	"github.com/dimiro1/lunar/internal/services/ai"
 	"github.com/dimiro1/lunar/internal/services/email"
 	"github.com/dimiro1/lunar/internal/services/env"
-	"github.com/dimiro1/lunar/internal/events"
 	internalhttp "github.com/dimiro1/lunar/internal/services/http"
 	"github.com/dimiro1/lunar/internal/services/kv"
 	"github.com/dimiro1/lunar/internal/services/logger"
+	"github.com/dimiro1/lunar/internal/services/modules"
+	"github.com/dimiro1/lunar/internal/store"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -24,15 +28,25 @@ type Response struct {
 
 // Dependencies holds all the dependencies needed to run a Lua function
 type Dependencies struct {
-	Logger       logger.Logger
-	KV           kv.Store
-	Env          env.Store
-	HTTP         internalhttp.Client
-	AI           ai.Client
-	AITracker    ai.Tracker
-	Email        email.Client
-	EmailTracker email.Tracker
-	Timeout      time.Duration // Execution timeout (defaults to 5 minutes if not set)
+	Logger         logger.Logger
+	KV             kv.Store
+	Env            env.Store
+	HTTP           internalhttp.Client
+	HTTPTracker    internalhttp.Tracker
+	AI             ai.Client
+	AITracker      ai.Tracker
+	AIRetry        ai.RetryPolicy // Retries for transient AI provider errors (none if zero)
+	Email          email.Client
+	EmailTracker   email.Tracker
+	EmailRetry     email.RetryPolicy     // Retries for transient email provider errors (none if zero)
+	EmailTemplates email.TemplateStore   // Templates rendered by email.send_template (nil disables it)
+	EmailAllowed   email.SenderAllowlist // Addresses and domains every function may send email from (empty allows any)
+	Modules        modules.Store         // Lua modules loaded with require (nil leaves only package.preload)
+	States         *StatePool            // Warm states of functions with reuse_state (nil keeps none)
+	MaxLogEntries  int                   // Log entries an execution may write (defaults to DefaultMaxLogEntries if not set)
+	MaxOutbound    int                   // Outbound http, ai and email calls an execution may make (unlimited if not set)
+	OutboundTime   time.Duration         // Total time an execution may spend in outbound calls (unlimited if not set)
+	Timeout        time.Duration         // Execution timeout (defaults to 5 minutes if not set)
 }
 
 // Request represents a function execution request
@@ -40,10 +54,84 @@ type Request struct {
 	Context *events.ExecutionContext
 	Event   events.Event
 	Code    string
+
+	// WebSocket is set when the function is handling an upgraded connection
+	WebSocket events.WebSocket
+
+	// EventStream is set when the function may stream server-sent events
+	EventStream events.EventStream
+
+	// Trace logs every stdlib call with its arguments and timing
+	Trace bool
+
+	// CaptureHTTP records outbound HTTP requests with the HTTP tracker
+	CaptureHTTP bool
+
+	// AIDefaults fill the provider and model of ai calls that leave them out
+	AIDefaults ai.Defaults
+
+	// EmailDefaults fill the sender of email.send calls that leave it out
+	EmailDefaults email.Defaults
+
+	// EmailAllowed restricts the senders of email.send calls for this function
+	EmailAllowed email.SenderAllowlist
+
+	// DisabledModules lists the SandboxModules that raise an error when used
+	DisabledModules []string
+
+	// Tags collects the tags set with execution.tag, including when the run fails
+	Tags map[string]string
+
+	// Spans collects the timing spans started with trace.span, including when
+	// the run fails
+	Spans *[]store.Span
+
+	// ReuseState runs the request in a warm state of the function when one is
+	// idle, and keeps the state warm afterwards. It is ignored for traced,
+	// seeded and frozen-time runs, see reusesState.
+	ReuseState bool
+
+	// MaxLogEntries overrides the log entries the execution may write when set
+	MaxLogEntries int
+
+	// MaxOutbound lowers the outbound calls the execution may make when set
+	MaxOutbound int
+
+	// OutboundTime lowers the total time the execution may spend in outbound
+	// calls when set
+	OutboundTime time.Duration
+
+	// Seed makes the random module deterministic for this run when set
+	Seed *int64
+
+	// FrozenTime pins time.now to this Unix time for this run when set
+	FrozenTime *int64
+
+	// Middleware names the function's modules whose before and after hooks
+	// run around the handler, in order
+	Middleware []string
+}
+
+// reusesState reports whether the request runs in a warm state. Runs with
+// per-execution overrides get a fresh state that is discarded afterwards:
+// code loaded by them would keep the overridden modules, and a warm state
+// would leak into them the modules of an ordinary run.
+func (r Request) reusesState() bool {
+	return r.ReuseState && !r.Trace && r.Seed == nil && r.FrozenTime == nil
+}
+
+// responseOptional reports whether the handler may return nothing because
+// the response was already delivered over a WebSocket or an event stream
+func (r Request) responseOptional() bool {
+	return r.WebSocket != nil || (r.EventStream != nil && r.EventStream.Started())
 }
 
 // Run executes a Lua function with the given event
-func Run(ctx context.Context, deps Dependencies, req Request) (Response, error) {
+//
+// A Go panic raised while the function runs, for example by a binding called
+// with unexpected arguments, is returned as a *PanicError rather than
+// propagated to the caller.
+func Run(ctx context.Context, deps Dependencies, req Request) (resp Response, err error) {
 	// Use provided timeout or default to 5 minutes
 	timeout := deps.Timeout
 	if timeout == 0 {
@@ -54,76 +142,161 @@ func Run(ctx context.Context, deps Dependencies, req Request) (Response, error)
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	L := lua.NewState()
-	defer L.Close()
+	var st *luaState
+	defer func() {
+		if rec := recover(); rec != nil {
+			resp, err = Response{}, newPanicError(rec)
+		} else if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = &engine.TimeoutError{Timeout: timeout, Err: err}
+		}
+		if st == nil {
+			return
+		}
+
+		// Keep the state warm only after a clean run; a failed run may
+		// have left it half-way through the code
+		st.L.RemoveContext()
+		if err == nil && req.reusesState() {
+			discardStates(deps.States.release(st), deps.Logger)
+		} else {
+			discardStates([]*luaState{st}, deps.Logger)
+		}
+	}()
+
+	// Reuse a warm state of the function when it opted in, with its code
+	// already loaded and init already run
+	var stale []*luaState
+	config := stateConfig(req)
+	if req.reusesState() {
+		st, stale = deps.States.acquire(req.Context.FunctionID, req.Code, config)
+		discardStates(stale, deps.Logger)
+	}
+	warm := st != nil
+	if !warm {
+		st = &luaState{
+			L:          lua.NewState(lua.Options{IncludeGoStackTrace: true}),
+			functionID: req.Context.FunctionID,
+			code:       req.Code,
+			config:     config,
+		}
+	}
+	st.executionID = req.Context.ExecutionID
+	L := st.L
 
 	// Set the context to enable timeout
 	L.SetContext(ctx)
 
+	if warm {
+		rebindModules(L, func() { registerModules(L, ctx, deps, req) })
+	} else {
+		registerModules(L, ctx, deps, req)
+		registerApp(L)
+
+		if err := loadCode(L, st, req.Code); err != nil {
+			return Response{}, err
+		}
+	}
+
+	// Handle different event types
+	switch req.Event.Type() {
+	case events.EventTypeHTTP:
+		return runHTTPEvent(L, req, st.init)
+	default:
+		return Response{}, fmt.Errorf("unsupported event type: %s", req.Event.Type())
+	}
+}
+
+// registerModules registers the global modules for the execution of req
+func registerModules(L *lua.LState, ctx context.Context, deps Dependencies, req Request) {
+	// Logs past the execution's limit are dropped, including trace entries
+	log := newLimitedLogger(deps.Logger, maxLogEntries(deps, req))
+
+	// Outbound calls share the execution's call and time budget
+	deps = limitOutbound(deps, outboundBudget(deps, req))
+
 	// Register global modules
-	registerLogger(L, deps.Logger, req.Context.ExecutionID)
+	registerLogger(L, log, req.Context.ExecutionID)
 	registerKV(L, deps.KV, req.Context.FunctionID)
 	registerEnv(L, deps.Env, req.Context.FunctionID)
-	registerHTTP(L, deps.HTTP)
+	registerHTTP(L, deps.HTTP, deps.HTTPTracker, req.Context.ExecutionID, req.CaptureHTTP)
 
 	// Register utility modules
 	registerJSON(L)
 	registerBase64(L)
 	registerCrypto(L)
-	registerTime(L)
+	registerTime(L, timeClock(req))
 	registerURL(L)
 	registerStrings(L)
-	registerRandom(L)
+	registerRandom(L, randomGenerator(req))
+	registerRespond(L)
 	registerRouter(L, req.Context)
+	registerWebSocket(L, ctx, req.WebSocket)
+	registerSSE(L, req.EventStream)
+	registerExecution(L, req.Tags)
+	registerSpans(L, req.Spans)
 
 	// Register AI module
-	registerAI(L, deps.AI, req.Context.FunctionID, deps.AITracker, req.Context.ExecutionID)
+	registerAI(L, ctx, deps.AI, req.Context.FunctionID, deps.AITracker, req.Context.ExecutionID, deps.AIRetry, deps.KV, req.AIDefaults)
 
 	// Register Email module
-	registerEmail(L, deps.Email, req.Context.FunctionID, deps.EmailTracker, req.Context.ExecutionID)
+	registerEmail(L, ctx, deps.Email, req.Context.FunctionID, deps.EmailTracker, deps.EmailTemplates, req.Context.ExecutionID, deps.EmailRetry, req.EmailDefaults, deps.EmailAllowed, req.EmailAllowed)
+
+	// Resolve require against the function's stored modules
+	registerRequire(L, deps.Modules, req.Context.FunctionID)
+
+	// Replace the modules disabled for this function
+	registerSandbox(L, req.DisabledModules)
 
+	// Wrap the modules registered above when tracing was requested
+	if req.Trace {
+		registerTrace(L, log, req.Context.ExecutionID)
+	}
+}
+
+// loadCode runs the function code in a new state, checks that it defines a
+// handler and runs the optional init hook, keeping its result in st
+func loadCode(L *lua.LState, st *luaState, code string) error {
 	// Load and execute the Lua code
-	if err := L.DoString(req.Code); err != nil {
-		enhancedErr := EnhanceError(fmt.Errorf("failed to load Lua code: %w", err), req.Code)
-		return Response{}, enhancedErr
+	if err := L.DoString(code); err != nil {
+		if panicErr, ok := asPanicError(err); ok {
+			return panicErr
+		}
+		return EnhanceError(fmt.Errorf("failed to load Lua code: %w", err), code)
 	}
 
 	// Get the handler function
 	handlerFn := L.GetGlobal("handler")
 	if handlerFn.Type() != lua.LTFunction {
-		enhancedErr := EnhanceError(fmt.Errorf("handler function not found in Lua code"), req.Code)
-		return Response{}, enhancedErr
+		return EnhanceError(fmt.Errorf("handler function not found in Lua code"), code)
 	}
 
-	// Handle different event types
-	switch req.Event.Type() {
-	case events.EventTypeHTTP:
-		return runHTTPEvent(L, req.Context, req.Event.(events.HTTPEvent), req.Code)
-	default:
-		return Response{}, fmt.Errorf("unsupported event type: %s", req.Event.Type())
+	// Run the optional init hook; cleanup runs when the state is discarded
+	result, err := runInit(L, code)
+	if err != nil {
+		return err
 	}
+	st.init = result
+	return nil
 }
 
-// runHTTPEvent executes the handler for an HTTP event
-func runHTTPEvent(L *lua.LState, execCtx *events.ExecutionContext, event events.HTTPEvent, sourceCode string) (Response, error) {
+// runHTTPEvent executes the handler for an HTTP event.
+// state is the value returned by the init hook (nil without one).
+func runHTTPEvent(L *lua.LState, req Request, state lua.LValue) (Response, error) {
+	sourceCode := req.Code
+
 	// Create context and event Lua tables
-	ctxTable := contextToLuaTable(L, execCtx)
-	eventTable := httpEventToLuaTable(L, event)
+	ctxTable := contextToLuaTable(L, req.Context)
+	eventTable := httpEventToLuaTable(L, req.Event.(events.HTTPEvent))
 
-	// Call handler(ctx, event)
-	handlerFn := L.GetGlobal("handler")
-	if err := L.CallByParam(lua.P{
-		Fn:      handlerFn,
-		NRet:    1,
-		Protect: true,
-	}, ctxTable, eventTable); err != nil {
-		enhancedErr := EnhanceError(fmt.Errorf("failed to execute handler: %w", err), sourceCode)
-		return Response{}, enhancedErr
+	// Call handler(ctx, event, state) inside the function's middleware
+	chain, err := loadMiddleware(L, req.Middleware)
+	if err != nil {
+		return Response{}, err
+	}
+	ret, err := callWithMiddleware(L, chain, L.GetGlobal("handler"), ctxTable, eventTable, state, sourceCode)
+	if err != nil {
+		return Response{}, err
 	}
-
-	// Get the response from the stack
-	ret := L.Get(-1)
-	L.Pop(1)
 
 	// Convert response table to HTTPResponse
 	if tbl, ok := ret.(*lua.LTable); ok {
@@ -134,6 +307,10 @@ func runHTTPEvent(L *lua.LState, execCtx *events.ExecutionContext, event events.
 		}, nil
 	}
 
+	if ret == lua.LNil && req.responseOptional() {
+		return Response{Type: events.EventTypeHTTP}, nil
+	}
+
 	enhancedErr := EnhanceError(fmt.Errorf("handler did not return a table"), sourceCode)
 	return Response{}, enhancedErr
 }
@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/kv"
+)
+
+// Conversation history limits
+const (
+	DefaultMaxHistoryMessages = 20
+	MaxHistoryMessagesLimit   = 200
+	conversationKeyPrefix     = "ai:conversation:"
+)
+
+// ConversationOptions controls how a conversation turn is sent and stored
+type ConversationOptions struct {
+	System           string // System prompt sent with every turn (not stored)
+	MaxMessages      int    // Messages kept in the history (default DefaultMaxHistoryMessages)
+	MaxHistoryTokens int    // Approximate token bound for the history (0 for no bound)
+}
+
+// Conversation keeps chat history in the function's KV store so each turn
+// only needs the new user message. Concurrent turns on the same conversation
+// are not serialized; the last one to finish wins.
+type Conversation struct {
+	store      kv.Store
+	functionID string
+	id         string
+}
+
+// NewConversation creates a Conversation stored under the given id
+func NewConversation(store kv.Store, functionID, id string) *Conversation {
+	return &Conversation{store: store, functionID: functionID, id: id}
+}
+
+// ID returns the conversation id
+func (c *Conversation) ID() string {
+	return c.id
+}
+
+// key returns the KV key holding the history
+func (c *Conversation) key() string {
+	return conversationKeyPrefix + c.id
+}
+
+// History loads the stored messages. A conversation without history returns no messages.
+func (c *Conversation) History() ([]ai.Message, error) {
+	value, err := c.store.Get(c.functionID, c.key())
+	var kvErr *kv.Error
+	if errors.As(err, &kvErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation: %w", err)
+	}
+
+	var messages []ai.Message
+	if err := json.Unmarshal([]byte(value), &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation: %w", err)
+	}
+	return messages, nil
+}
+
+// save stores the messages as the conversation history
+func (c *Conversation) save(messages []ai.Message) error {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversation: %w", err)
+	}
+	if err := c.store.Set(c.functionID, c.key(), string(data)); err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	return nil
+}
+
+// Send appends the user message to the history, asks the model for a reply
+// and stores the reply. The history is left unchanged when the request fails.
+// req supplies the provider, model and other chat options; its messages are replaced.
+func (c *Conversation) Send(client ai.Client, req ai.ChatRequest, message string, opts ConversationOptions) (*ai.ChatResponse, error) {
+	history, err := c.History()
+	if err != nil {
+		return nil, err
+	}
+
+	history = TrimHistory(append(history, ai.Message{Role: ai.RoleUser, Content: message}), opts.MaxMessages, opts.MaxHistoryTokens)
+
+	req.Messages = history
+	if opts.System != "" {
+		req.Messages = append([]ai.Message{{Role: ai.RoleSystem, Content: opts.System}}, history...)
+	}
+
+	resp, err := client.Chat(c.functionID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	history = TrimHistory(append(history, ai.Message{Role: ai.RoleAssistant, Content: resp.Content}), opts.MaxMessages, opts.MaxHistoryTokens)
+	if err := c.save(history); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TrimHistory drops the oldest messages until at most maxMessages remain and
+// the estimated token count fits maxTokens, always keeping the newest message.
+// The history never starts with an assistant message, since providers expect
+// conversations to open with a user message.
+func TrimHistory(messages []ai.Message, maxMessages, maxTokens int) []ai.Message {
+	if maxMessages <= 0 {
+		maxMessages = DefaultMaxHistoryMessages
+	}
+
+	start := max(len(messages)-maxMessages, 0)
+
+	if maxTokens > 0 {
+		tokens := 0
+		for _, msg := range messages[start:] {
+			tokens += estimateTokens(msg.Content)
+		}
+		for start < len(messages)-1 && tokens > maxTokens {
+			tokens -= estimateTokens(messages[start].Content)
+			start++
+		}
+	}
+
+	for start < len(messages)-1 && messages[start].Role != ai.RoleUser {
+		start++
+	}
+	return messages[start:]
+}
+
+// estimateTokens approximates the token count of text at four characters per token
+func estimateTokens(text string) int {
+	return (len(strings.TrimSpace(text)) + 3) / 4
+}
@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/services/ai"
+	"github.com/dimiro1/lunar/internal/services/kv"
+)
+
+// recordingClient replies with a fixed message and records the requests it receives
+type recordingClient struct {
+	reply    string
+	err      error
+	requests []ai.ChatRequest
+}
+
+func (r *recordingClient) Chat(_ string, req ai.ChatRequest) (*ai.ChatResponse, error) {
+	r.requests = append(r.requests, req)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &ai.ChatResponse{Content: r.reply}, nil
+}
+
+func TestConversation_Send(t *testing.T) {
+	store := kv.NewMemoryStore()
+	client := &recordingClient{reply: "Hi there"}
+	conversation := NewConversation(store, "fn-1", "conv-1")
+	opts := ConversationOptions{System: "Be brief", MaxMessages: DefaultMaxHistoryMessages}
+	req := ai.ChatRequest{Provider: "openai", Model: "gpt-4o-mini"}
+
+	if _, err := conversation.Send(client, req, "Hello", opts); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	client.reply = "Goodbye"
+	resp, err := conversation.Send(client, req, "Bye", opts)
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if resp.Content != "Goodbye" {
+		t.Errorf("expected reply Goodbye, got %q", resp.Content)
+	}
+
+	// The second turn carries the system prompt and the previous exchange
+	sent := client.requests[1].Messages
+	want := []ai.Message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hi there"},
+		{Role: "user", Content: "Bye"},
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), sent)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Errorf("message %d: expected %+v, got %+v", i, want[i], sent[i])
+		}
+	}
+
+	// The system prompt is not stored
+	history, err := conversation.History()
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 4 || history[0].Role != "user" || history[3].Content != "Goodbye" {
+		t.Errorf("unexpected history: %+v", history)
+	}
+
+	// Conversations are isolated by id
+	other, err := NewConversation(store, "fn-1", "conv-2").History()
+	if err != nil || len(other) != 0 {
+		t.Errorf("expected an empty history for another conversation, got %+v, %v", other, err)
+	}
+}
+
+func TestConversation_SendFailureKeepsHistory(t *testing.T) {
+	store := kv.NewMemoryStore()
+	conversation := NewConversation(store, "fn-1", "conv-1")
+	req := ai.ChatRequest{Provider: "openai", Model: "gpt-4o-mini"}
+
+	if _, err := conversation.Send(&recordingClient{reply: "Hi"}, req, "Hello", ConversationOptions{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	_, err := conversation.Send(&recordingClient{err: errors.New("provider down")}, req, "Are you there?", ConversationOptions{})
+	if err == nil || err.Error() != "provider down" {
+		t.Fatalf("expected the provider error, got %v", err)
+	}
+
+	history, _ := conversation.History()
+	if len(history) != 2 {
+		t.Errorf("expected the failed turn not to be stored, got %+v", history)
+	}
+}
+
+func TestConversation_CorruptHistory(t *testing.T) {
+	store := kv.NewMemoryStore()
+	_ = store.Set("fn-1", "ai:conversation:conv-1", "not json")
+
+	_, err := NewConversation(store, "fn-1", "conv-1").History()
+	if err == nil || !strings.Contains(err.Error(), "failed to decode conversation") {
+		t.Errorf("expected a decode error, got %v", err)
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	turns := func(n int) []ai.Message {
+		var messages []ai.Message
+		for i := range n {
+			role := "user"
+			if i%2 == 1 {
+				role = "assistant"
+			}
+			messages = append(messages, ai.Message{Role: role, Content: strings.Repeat("x", 40)})
+		}
+		return messages
+	}
+
+	tests := []struct {
+		name        string
+		messages    []ai.Message
+		maxMessages int
+		maxTokens   int
+		wantLen     int
+	}{
+		{name: "under limit", messages: turns(4), maxMessages: 10, wantLen: 4},
+		{name: "default limit", messages: turns(30), wantLen: DefaultMaxHistoryMessages},
+		{name: "message limit keeps a leading user message", messages: turns(7), maxMessages: 4, wantLen: 3},
+		{name: "token limit drops a leading assistant message", messages: turns(8), maxMessages: 20, maxTokens: 35, wantLen: 2},
+		{name: "newest message always kept", messages: turns(3), maxMessages: 20, maxTokens: 1, wantLen: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TrimHistory(tt.messages, tt.maxMessages, tt.maxTokens)
+			if len(got) != tt.wantLen {
+				t.Fatalf("expected %d messages, got %d", tt.wantLen, len(got))
+			}
+			if got[len(got)-1] != tt.messages[len(tt.messages)-1] {
+				t.Error("expected the newest message to be kept")
+			}
+			if len(got) > 1 && got[0].Role != "user" {
+				t.Errorf("expected history to start with a user message, got %s", got[0].Role)
+			}
+		})
+	}
+}
@@ -1,3 +1,4 @@
 // Package ai provides a TrackedClient decorator that wraps an AI client
-// with automatic request timing and tracking capabilities.
+// with automatic request timing and tracking capabilities, and a
+// Conversation helper that keeps chat history in the KV store.
 package ai
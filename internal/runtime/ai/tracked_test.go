@@ -35,6 +35,10 @@ func (m *mockTracker) RequestsPaginated(executionID string, limit, offset int) (
 	return nil, 0
 }
 
+func (m *mockTracker) RequestsFiltered(executionID string, filter ai.RequestFilter, limit, offset int) ([]store.AIRequest, int64) {
+	return nil, 0
+}
+
 func TestNewTrackedClient(t *testing.T) {
 	client := &mockClient{}
 	tracker := &mockTracker{}
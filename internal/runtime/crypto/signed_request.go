@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"strconv"
+	"time"
+)
+
+// Headers carrying a request signature made with SignRequest
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Timestamp"
+)
+
+// DefaultSignatureTolerance is how far a signed request's timestamp may be from
+// the current time before it is rejected as stale
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// Reasons returned by VerifyRequest when a request is rejected, in addition
+// to ReasonMissingSignature and ReasonInvalidSignature
+const (
+	ReasonMissingTimestamp = "missing timestamp"
+	ReasonInvalidTimestamp = "invalid timestamp"
+	ReasonStaleTimestamp   = "stale timestamp"
+)
+
+// SignRequest returns the signature of a request body sent at timestamp: the
+// hex HMAC-SHA256 of "<unix timestamp>.<body>" keyed with secret. Callers send
+// it in the X-Signature header along with the timestamp in X-Timestamp.
+func SignRequest(body, secret string, timestamp time.Time) string {
+	return signRequestPayload(strconv.FormatInt(timestamp.Unix(), 10), body, secret)
+}
+
+// VerifyRequest checks that signature was made with secret over body and
+// timestamp, and that timestamp is within tolerance of now. The window limits
+// how long a captured request can be replayed. When the request is rejected,
+// reason explains why.
+func VerifyRequest(signature, timestamp, body, secret string, now time.Time, tolerance time.Duration) (ok bool, reason string) {
+	if signature == "" {
+		return false, ReasonMissingSignature
+	}
+	if timestamp == "" {
+		return false, ReasonMissingTimestamp
+	}
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false, ReasonInvalidTimestamp
+	}
+
+	// Check the signature first so a tampered timestamp is reported as such
+	if !hmac.Equal([]byte(signature), []byte(signRequestPayload(timestamp, body, secret))) {
+		return false, ReasonInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(sentAt, 0)); age > tolerance || age < -tolerance {
+		return false, ReasonStaleTimestamp
+	}
+	return true, ""
+}
+
+// signRequestPayload computes the signature over the timestamp and body
+func signRequestPayload(timestamp, body, secret string) string {
+	return HMACSHA256(timestamp+"."+body, secret)
+}
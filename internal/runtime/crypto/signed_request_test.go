@@ -0,0 +1,54 @@
+package crypto
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	signature := SignRequest(`{"id":1}`, "secret", now)
+	if want := HMACSHA256(`1700000000.{"id":1}`, "secret"); signature != want {
+		t.Errorf("expected %q, got %q", want, signature)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := `{"event":"paid"}`
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignRequest(body, "secret", now)
+
+	tests := []struct {
+		name      string
+		signature string
+		timestamp string
+		body      string
+		secret    string
+		now       time.Time
+		ok        bool
+		reason    string
+	}{
+		{name: "valid", signature: signature, timestamp: timestamp, body: body, secret: "secret", now: now, ok: true},
+		{name: "within window", signature: signature, timestamp: timestamp, body: body, secret: "secret", now: now.Add(4 * time.Minute), ok: true},
+		{name: "stale", signature: signature, timestamp: timestamp, body: body, secret: "secret", now: now.Add(6 * time.Minute), reason: ReasonStaleTimestamp},
+		{name: "from the future", signature: signature, timestamp: timestamp, body: body, secret: "secret", now: now.Add(-6 * time.Minute), reason: ReasonStaleTimestamp},
+		{name: "wrong secret", signature: signature, timestamp: timestamp, body: body, secret: "other", now: now, reason: ReasonInvalidSignature},
+		{name: "tampered body", signature: signature, timestamp: timestamp, body: `{"event":"refunded"}`, secret: "secret", now: now, reason: ReasonInvalidSignature},
+		{name: "tampered timestamp", signature: signature, timestamp: "1700000100", body: body, secret: "secret", now: now, reason: ReasonInvalidSignature},
+		{name: "missing signature", timestamp: timestamp, body: body, secret: "secret", now: now, reason: ReasonMissingSignature},
+		{name: "missing timestamp", signature: signature, body: body, secret: "secret", now: now, reason: ReasonMissingTimestamp},
+		{name: "invalid timestamp", signature: signature, timestamp: "yesterday", body: body, secret: "secret", now: now, reason: ReasonInvalidTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := VerifyRequest(tt.signature, tt.timestamp, tt.body, tt.secret, tt.now, DefaultSignatureTolerance)
+			if ok != tt.ok || reason != tt.reason {
+				t.Errorf("expected (%v, %q), got (%v, %q)", tt.ok, tt.reason, ok, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignURL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	signed, err := SignURL("/files/report.pdf", map[string]string{"user": "42"}, "secret", time.Hour, now)
+	if err != nil {
+		t.Fatalf("SignURL failed: %v", err)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("failed to parse signed URL: %v", err)
+	}
+	if u.Path != "/files/report.pdf" {
+		t.Errorf("expected path to be preserved, got %q", u.Path)
+	}
+	if got := u.Query().Get("user"); got != "42" {
+		t.Errorf("expected user=42, got %q", got)
+	}
+	if got := u.Query().Get(ExpiresParam); got != "1700003600" {
+		t.Errorf("expected expires=1700003600, got %q", got)
+	}
+	if u.Query().Get(SignatureParam) == "" {
+		t.Error("expected a signature parameter")
+	}
+
+	if _, err := SignURL("/files", nil, "secret", 0, now); err == nil {
+		t.Error("expected error for non-positive ttl")
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signed, err := SignURL("https://example.com/files/a?download=1", map[string]string{"user": "42"}, "secret", time.Minute, now)
+	if err != nil {
+		t.Fatalf("SignURL failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		secret string
+		now    time.Time
+		ok     bool
+		reason string
+	}{
+		{name: "valid", url: signed, secret: "secret", now: now, ok: true},
+		{name: "valid on another host", url: strings.Replace(signed, "https://example.com", "http://localhost:3000", 1), secret: "secret", now: now, ok: true},
+		{name: "expired", url: signed, secret: "secret", now: now.Add(2 * time.Minute), reason: ReasonExpired},
+		{name: "wrong secret", url: signed, secret: "other", now: now, reason: ReasonInvalidSignature},
+		{name: "tampered param", url: strings.Replace(signed, "user=42", "user=43", 1), secret: "secret", now: now, reason: ReasonInvalidSignature},
+		{name: "missing signature", url: "/files/a?expires=1700000060", secret: "secret", now: now, reason: ReasonMissingSignature},
+		{name: "missing expires", url: "/files/a?signature=abc", secret: "secret", now: now, reason: ReasonMissingExpires},
+		{name: "invalid expires", url: "/files/a?signature=abc&expires=soon", secret: "secret", now: now, reason: ReasonInvalidExpires},
+		{name: "malformed", url: "%zz", secret: "secret", now: now, reason: ReasonMalformedURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := VerifyURL(tt.url, tt.secret, tt.now)
+			if ok != tt.ok || reason != tt.reason {
+				t.Errorf("VerifyURL() = (%v, %q), want (%v, %q)", ok, reason, tt.ok, tt.reason)
+			}
+		})
+	}
+}
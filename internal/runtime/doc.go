@@ -7,4 +7,5 @@
 //   - router: URL path matching and building
 //   - ai: AI provider client with automatic tracking
 //   - email: Email client with automatic tracking
+//   - http: HTTP client with automatic request capture
 package stdlib
@@ -37,6 +37,10 @@ func (m *mockTracker) RequestsPaginated(executionID string, limit, offset int) (
 	return nil, 0
 }
 
+func (m *mockTracker) RequestsFiltered(executionID string, filter email.RequestFilter, limit, offset int) ([]store.EmailRequest, int64) {
+	return nil, 0
+}
+
 func TestNewTrackedClient(t *testing.T) {
 	client := &mockClient{}
 	tracker := &mockTracker{}
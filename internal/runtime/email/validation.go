@@ -2,15 +2,22 @@ package email
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/dimiro1/lunar/internal/services/email"
 )
 
 // ValidateSendRequest validates a SendRequest and returns an error if invalid.
-func ValidateSendRequest(req email.SendRequest) error {
+// The sender must be permitted by every given allowlist.
+func ValidateSendRequest(req email.SendRequest, allowlists ...email.SenderAllowlist) error {
 	if req.From == "" {
 		return errors.New("from is required")
 	}
+	for _, allowlist := range allowlists {
+		if !allowlist.Allows(req.From) {
+			return fmt.Errorf("from address %q is not allowed", req.From)
+		}
+	}
 	if len(req.To) == 0 {
 		return errors.New("to is required")
 	}
@@ -136,3 +136,22 @@ func TestValidateSendRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSendRequest_SenderAllowlists(t *testing.T) {
+	req := email.SendRequest{
+		From:    "noreply@example.com",
+		To:      []string{"recipient@example.com"},
+		Subject: "Test Subject",
+		Text:    "Hello, World!",
+	}
+
+	if err := ValidateSendRequest(req, email.SenderAllowlist{"example.com"}, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// The sender must satisfy every allowlist that is set
+	err := ValidateSendRequest(req, email.SenderAllowlist{"example.com"}, email.SenderAllowlist{"billing@example.com"})
+	if err == nil || err.Error() != `from address "noreply@example.com" is not allowed` {
+		t.Errorf("expected disallowed sender error, got %v", err)
+	}
+}
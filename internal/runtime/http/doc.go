@@ -0,0 +1,3 @@
+// Package http provides a TrackedClient decorator that wraps an HTTP client
+// with automatic request timing and body capture.
+package http
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"time"
+
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+// Compile-time check that TrackedClient implements internalhttp.Client
+var _ internalhttp.Client = (*TrackedClient)(nil)
+
+// TrackedClient wraps an internalhttp.Client and records every request,
+// including bodies, with the tracker.
+// This implements the Decorator pattern.
+type TrackedClient struct {
+	client      internalhttp.Client
+	tracker     internalhttp.Tracker
+	executionID string
+}
+
+// NewTrackedClient creates a TrackedClient that wraps the given client and tracks requests.
+func NewTrackedClient(client internalhttp.Client, tracker internalhttp.Tracker, executionID string) *TrackedClient {
+	return &TrackedClient{
+		client:      client,
+		tracker:     tracker,
+		executionID: executionID,
+	}
+}
+
+// Get performs an HTTP GET request with automatic tracking.
+func (tc *TrackedClient) Get(req internalhttp.Request) (internalhttp.Response, error) {
+	return tc.do("GET", tc.client.Get, req)
+}
+
+// Post performs an HTTP POST request with automatic tracking.
+func (tc *TrackedClient) Post(req internalhttp.Request) (internalhttp.Response, error) {
+	return tc.do("POST", tc.client.Post, req)
+}
+
+// Put performs an HTTP PUT request with automatic tracking.
+func (tc *TrackedClient) Put(req internalhttp.Request) (internalhttp.Response, error) {
+	return tc.do("PUT", tc.client.Put, req)
+}
+
+// Patch performs an HTTP PATCH request with automatic tracking.
+func (tc *TrackedClient) Patch(req internalhttp.Request) (internalhttp.Response, error) {
+	return tc.do("PATCH", tc.client.Patch, req)
+}
+
+// Delete performs an HTTP DELETE request with automatic tracking.
+func (tc *TrackedClient) Delete(req internalhttp.Request) (internalhttp.Response, error) {
+	return tc.do("DELETE", tc.client.Delete, req)
+}
+
+// do sends the request through send, measuring its duration and tracking the result.
+// Transport failures and 4xx/5xx responses are tracked as errors.
+func (tc *TrackedClient) do(method string, send func(internalhttp.Request) (internalhttp.Response, error), req internalhttp.Request) (internalhttp.Response, error) {
+	startTime := time.Now()
+	resp, err := send(req)
+	durationMs := time.Since(startTime).Milliseconds()
+
+	if tc.tracker == nil {
+		return resp, err
+	}
+
+	trackReq := internalhttp.TrackRequest{
+		Method:         method,
+		URL:            req.URL,
+		Query:          req.Query,
+		RequestHeaders: req.Headers,
+		RequestBody:    req.Body,
+		Status:         store.HTTPRequestStatusSuccess,
+		DurationMs:     durationMs,
+	}
+
+	if err != nil {
+		errMsg := err.Error()
+		trackReq.Status = store.HTTPRequestStatusError
+		trackReq.ErrorMessage = &errMsg
+	} else {
+		trackReq.StatusCode = &resp.StatusCode
+		trackReq.ResponseHeaders = resp.Headers
+		trackReq.ResponseBody = &resp.Body
+		if resp.IsError() {
+			trackReq.Status = store.HTTPRequestStatusError
+		}
+	}
+
+	tc.tracker.Track(tc.executionID, trackReq)
+
+	return resp, err
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"errors"
+	"testing"
+
+	internalhttp "github.com/dimiro1/lunar/internal/services/http"
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+func TestTrackedClient_TracksSuccess(t *testing.T) {
+	client := internalhttp.NewFakeClient()
+	client.SetResponse("POST", "https://api.example.com/items", internalhttp.Response{
+		StatusCode: 201,
+		Headers:    internalhttp.Headers{"Content-Type": "application/json"},
+		Body:       `{"id":1}`,
+	})
+	tracker := internalhttp.NewMemoryTracker()
+
+	tc := NewTrackedClient(client, tracker, "exec-123")
+	resp, err := tc.Post(internalhttp.Request{URL: "https://api.example.com/items", Body: `{"name":"widget"}`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 201 {
+		t.Errorf("StatusCode = %d, want 201", resp.StatusCode)
+	}
+
+	requests := tracker.Requests("exec-123")
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 tracked request, got %d", len(requests))
+	}
+
+	tracked := requests[0]
+	if tracked.Method != "POST" || tracked.Status != store.HTTPRequestStatusSuccess {
+		t.Errorf("unexpected tracked request: %+v", tracked)
+	}
+	if tracked.StatusCode == nil || *tracked.StatusCode != 201 {
+		t.Errorf("StatusCode = %v, want 201", tracked.StatusCode)
+	}
+	if tracked.RequestBody == nil || *tracked.RequestBody != `{"name":"widget"}` {
+		t.Errorf("RequestBody = %v", tracked.RequestBody)
+	}
+	if tracked.ResponseBody == nil || *tracked.ResponseBody != `{"id":1}` {
+		t.Errorf("ResponseBody = %v", tracked.ResponseBody)
+	}
+}
+
+func TestTrackedClient_TracksErrors(t *testing.T) {
+	client := internalhttp.NewFakeClient()
+	client.SetError("GET", "https://down.example.com", errors.New("connection refused"))
+	client.SetResponse("DELETE", "https://api.example.com/items/1", internalhttp.Response{StatusCode: 404})
+	tracker := internalhttp.NewMemoryTracker()
+
+	tc := NewTrackedClient(client, tracker, "exec-123")
+	if _, err := tc.Get(internalhttp.Request{URL: "https://down.example.com"}); err == nil {
+		t.Fatal("expected error to be returned")
+	}
+	if _, err := tc.Delete(internalhttp.Request{URL: "https://api.example.com/items/1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := tracker.Requests("exec-123")
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 tracked requests, got %d", len(requests))
+	}
+	if requests[0].Status != store.HTTPRequestStatusError || requests[0].ErrorMessage == nil {
+		t.Errorf("expected transport failure to be tracked as error, got %+v", requests[0])
+	}
+	if requests[1].Status != store.HTTPRequestStatusError || *requests[1].StatusCode != 404 {
+		t.Errorf("expected 404 to be tracked as error, got %+v", requests[1])
+	}
+}
+
+func TestTrackedClient_NilTracker(t *testing.T) {
+	tc := NewTrackedClient(internalhttp.NewFakeClient(), nil, "exec-123")
+	if _, err := tc.Get(internalhttp.Request{URL: "https://api.example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -84,3 +84,90 @@ func Hex(length int) (string, error) {
 func ID() string {
 	return xid.New().String()
 }
+
+// Generator produces the random values of the random module
+type Generator interface {
+	Int(min, max int) (int, error)
+	Float() float64
+	String(length int) (string, error)
+	Bytes(length int) (string, error)
+	Hex(length int) (string, error)
+}
+
+// Secure is the Generator backed by the package functions, seeded from a
+// secure source
+var Secure Generator = secureGenerator{}
+
+type secureGenerator struct{}
+
+func (secureGenerator) Int(min, max int) (int, error)     { return Int(min, max) }
+func (secureGenerator) Float() float64                    { return Float() }
+func (secureGenerator) String(length int) (string, error) { return String(length) }
+func (secureGenerator) Bytes(length int) (string, error)  { return Bytes(length) }
+func (secureGenerator) Hex(length int) (string, error)    { return Hex(length) }
+
+// Seeded is a Generator that produces the same sequence of values for the
+// same seed, for reproducible executions. Its values are predictable and must
+// not be used for secrets.
+type Seeded struct {
+	rng *mathrand.Rand
+}
+
+// NewSeeded creates a Seeded generator for seed
+func NewSeeded(seed int64) *Seeded {
+	return &Seeded{rng: mathrand.New(mathrand.NewSource(seed))}
+}
+
+// Int generates an integer between min and max (inclusive).
+func (s *Seeded) Int(min, max int) (int, error) {
+	if min > max {
+		return 0, fmt.Errorf("min (%d) must be less than or equal to max (%d)", min, max)
+	}
+	return int(s.rng.Int63n(int64(max-min+1))) + min, nil
+}
+
+// Float generates a float64 between 0.0 and 1.0.
+func (s *Seeded) Float() float64 {
+	return s.rng.Float64()
+}
+
+// String generates an alphanumeric string of the specified length.
+func (s *Seeded) String(length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("length must be positive, got %d", length)
+	}
+
+	bytes := make([]byte, length)
+	for i := range bytes {
+		bytes[i] = alphanumericCharset[s.rng.Intn(len(alphanumericCharset))]
+	}
+	return string(bytes), nil
+}
+
+// Bytes generates bytes and returns them as a base64-encoded string.
+func (s *Seeded) Bytes(length int) (string, error) {
+	bytes, err := s.read(length)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bytes), nil
+}
+
+// Hex generates bytes and returns them as a hex-encoded string.
+func (s *Seeded) Hex(length int) (string, error) {
+	bytes, err := s.read(length)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func (s *Seeded) read(length int) ([]byte, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("length must be positive, got %d", length)
+	}
+
+	bytes := make([]byte, length)
+	s.rng.Read(bytes)
+	return bytes, nil
+}
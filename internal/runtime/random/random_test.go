@@ -3,6 +3,7 @@ package random
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
 	"regexp"
 	"testing"
 )
@@ -175,3 +176,32 @@ func TestID(t *testing.T) {
 		}
 	})
 }
+
+func TestSeeded(t *testing.T) {
+	draw := func(gen Generator) string {
+		n, _ := gen.Int(1, 1000)
+		s, _ := gen.String(8)
+		h, _ := gen.Hex(4)
+		b, _ := gen.Bytes(4)
+		return fmt.Sprintf("%d %f %s %s %s", n, gen.Float(), s, h, b)
+	}
+
+	first := draw(NewSeeded(42))
+	if second := draw(NewSeeded(42)); second != first {
+		t.Errorf("expected the same values for the same seed, got %q and %q", first, second)
+	}
+	if other := draw(NewSeeded(7)); other == first {
+		t.Errorf("expected different values for another seed, got %q", other)
+	}
+
+	gen := NewSeeded(1)
+	if _, err := gen.Int(10, 1); err == nil {
+		t.Error("Int(10, 1) expected error, got nil")
+	}
+	if _, err := gen.String(0); err == nil {
+		t.Error("String(0) expected error, got nil")
+	}
+	if _, err := gen.Hex(-1); err == nil {
+		t.Error("Hex(-1) expected error, got nil")
+	}
+}
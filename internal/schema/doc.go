@@ -0,0 +1,11 @@
+// Package schema validates request bodies against a function's input schema.
+//
+// An input schema is a JSON Schema document (draft 2020-12 unless it names
+// another draft with $schema). Schemas are compiled once and cached by their
+// text, and may only reference themselves: $ref to files or URLs fails to
+// compile, so a schema can never make the server read a file or the network.
+//
+// Validation errors are keyed by the location of the offending value in the
+// body, such as "body" or "body/items/0/name", matching the field-keyed
+// errors the API returns for invalid requests.
+package schema
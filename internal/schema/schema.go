@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// resourceURL is the location compiled schemas are registered under
+const resourceURL = "urn:lunar:input-schema"
+
+// maxCacheEntries bounds the number of compiled schemas kept by a Cache
+const maxCacheEntries = 256
+
+// Schema is a compiled input schema
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// noLoader refuses to load the external resources a schema references
+type noLoader struct{}
+
+func (noLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("external references are not allowed: %s", url)
+}
+
+// Compile parses and compiles a JSON Schema document
+func Compile(raw []byte) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	if _, ok := doc.(map[string]any); !ok {
+		return nil, errors.New("schema must be a JSON object")
+	}
+
+	c := jsonschema.NewCompiler()
+	c.UseLoader(noLoader{})
+	if err := c.AddResource(resourceURL, doc); err != nil {
+		return nil, err
+	}
+	sch, err := c.Compile(resourceURL)
+	var invalid *jsonschema.SchemaValidationError
+	var verr *jsonschema.ValidationError
+	if errors.As(err, &invalid) && errors.As(invalid.Err, &verr) {
+		var problems []string
+		for location, message := range leafErrors(verr) {
+			problems = append(problems, "at '"+location+"': "+message)
+		}
+		sort.Strings(problems)
+		return nil, fmt.Errorf("schema is not a valid JSON Schema: %s", strings.Join(problems, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{schema: sch}, nil
+}
+
+// Validate checks a request body against the schema and returns the errors
+// keyed by location, or nil when the body is valid
+func (s *Schema) Validate(body []byte) map[string]string {
+	v, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return map[string]string{"body": "body is not valid JSON"}
+	}
+
+	err = s.schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return map[string]string{"body": err.Error()}
+	}
+
+	errs := make(map[string]string)
+	for location, message := range leafErrors(verr) {
+		errs["body"+location] = message
+	}
+	return errs
+}
+
+// leafErrors returns the messages of the innermost causes of a validation
+// error keyed by instance location, leaving out the "allOf failed" style
+// summaries of the keywords that combine them
+func leafErrors(verr *jsonschema.ValidationError) map[string]string {
+	messages := make(map[string][]string)
+	var collect func(unit jsonschema.OutputUnit)
+	collect = func(unit jsonschema.OutputUnit) {
+		if len(unit.Errors) == 0 && unit.Error != nil {
+			messages[unit.InstanceLocation] = append(messages[unit.InstanceLocation], unit.Error.String())
+		}
+		for _, cause := range unit.Errors {
+			collect(cause)
+		}
+	}
+	collect(*verr.DetailedOutput())
+
+	errs := make(map[string]string, len(messages))
+	for location, msgs := range messages {
+		sort.Strings(msgs)
+		errs[location] = strings.Join(slices.Compact(msgs), "; ")
+	}
+	return errs
+}
+
+// Cache keeps compiled schemas by their text so each schema is compiled
+// once, not on every request
+type Cache struct {
+	mu      sync.Mutex
+	schemas map[string]*Schema
+}
+
+// NewCache creates an empty Cache
+func NewCache() *Cache {
+	return &Cache{schemas: make(map[string]*Schema)}
+}
+
+// Get returns the compiled schema for raw, compiling it on first use
+func (c *Cache) Get(raw []byte) (*Schema, error) {
+	key := string(raw)
+
+	c.mu.Lock()
+	sch, ok := c.schemas[key]
+	c.mu.Unlock()
+	if ok {
+		return sch, nil
+	}
+
+	sch, err := Compile(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Schemas are only replaced when functions change, so starting over
+	// is simpler than tracking which ones are still in use
+	if len(c.schemas) >= maxCacheEntries {
+		clear(c.schemas)
+	}
+	c.schemas[key] = sch
+	return sch, nil
+}
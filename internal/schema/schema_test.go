@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string", "minLength": 2},
+		"tags": {"type": "array", "items": {"type": "string"}}
+	},
+	"additionalProperties": false
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	sch, err := Compile([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{name: "valid", body: `{"name": "ana", "tags": ["a"]}`},
+		{
+			name: "missing and unknown properties",
+			body: `{"nickname": "ana"}`,
+			want: map[string]string{"body": "additional properties 'nickname' not allowed; missing property 'name'"},
+		},
+		{
+			name: "nested values",
+			body: `{"name": "a", "tags": ["a", 1]}`,
+			want: map[string]string{
+				"body/name":   "minLength: got 1, want 2",
+				"body/tags/1": "got number, want string",
+			},
+		},
+		{name: "wrong type", body: `[]`, want: map[string]string{"body": "got array, want object"}},
+		{name: "invalid JSON", body: `{"name":`, want: map[string]string{"body": "body is not valid JSON"}},
+		{name: "empty body", body: ``, want: map[string]string{"body": "body is not valid JSON"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sch.Validate([]byte(tt.body))
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "invalid JSON", schema: `{"type":`, wantErr: "schema is not valid JSON"},
+		{name: "not an object", schema: `true`, wantErr: "schema must be a JSON object"},
+		{name: "invalid keyword", schema: `{"type": "text"}`, wantErr: "schema is not a valid JSON Schema: at '/type'"},
+		{name: "file reference", schema: `{"$ref": "file:///etc/passwd"}`, wantErr: "external references are not allowed"},
+		{name: "remote reference", schema: `{"$ref": "https://example.com/schema.json"}`, wantErr: "external references are not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// References within the schema itself are allowed
+	if _, err := Compile([]byte(`{"$defs": {"name": {"type": "string"}}, "$ref": "#/$defs/name"}`)); err != nil {
+		t.Errorf("expected a local reference to compile, got %v", err)
+	}
+}
+
+func TestCache_Get(t *testing.T) {
+	cache := NewCache()
+
+	first, err := cache.Get([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	second, err := cache.Get([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if first != second {
+		t.Error("expected the compiled schema to be reused")
+	}
+
+	if _, err := cache.Get([]byte(`{"type": "text"}`)); err == nil {
+		t.Error("expected an invalid schema to fail")
+	}
+}
@@ -0,0 +1,18 @@
+// Package seed imports function env vars and KV entries from a file on
+// startup, so deployments and dev/test environments start with the same data.
+//
+// A seed file maps function IDs to their env vars and KV entries, as JSON or,
+// with a .yaml or .yml extension, as YAML:
+//
+//	{
+//	  "functions": {
+//	    "d4c3b2a1": {
+//	      "env": {"API_BASE_URL": "https://api.example.com"},
+//	      "kv": {"feature:beta": "on"}
+//	    }
+//	  }
+//	}
+//
+// Importing is idempotent: keys that already exist keep their values unless
+// the import is forced.
+package seed
@@ -0,0 +1,116 @@
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dimiro1/lunar/internal/services/env"
+	"github.com/dimiro1/lunar/internal/services/kv"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the content of a seed file
+type File struct {
+	Functions map[string]Function `json:"functions" yaml:"functions"`
+}
+
+// Function holds the data seeded for one function
+type Function struct {
+	Env map[string]string `json:"env" yaml:"env"`
+	KV  map[string]string `json:"kv" yaml:"kv"`
+}
+
+// Result counts the keys an import wrote and the existing keys it kept
+type Result struct {
+	Imported int
+	Skipped  int
+}
+
+// Load reads a seed file, parsing it as YAML when its extension is .yaml or
+// .yml and as JSON otherwise. Unknown fields are rejected to catch typos.
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to read seed file: %w", err)
+	}
+
+	var file File
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("failed to parse seed file: %w", err)
+	}
+	return file, nil
+}
+
+// Apply writes the env vars and KV entries of file. Keys that already exist
+// are skipped unless force is set, so applying the same file again is a no-op.
+func Apply(file File, kvStore kv.Store, envStore env.Store, force bool) (Result, error) {
+	var result Result
+	for _, functionID := range slices.Sorted(maps.Keys(file.Functions)) {
+		fn := file.Functions[functionID]
+
+		existing, err := envStore.All(functionID)
+		if err != nil {
+			return result, fmt.Errorf("failed to read env vars of %s: %w", functionID, err)
+		}
+		for _, key := range slices.Sorted(maps.Keys(fn.Env)) {
+			if _, ok := existing[key]; ok && !force {
+				result.Skipped++
+				continue
+			}
+			if err := envStore.Set(functionID, key, fn.Env[key]); err != nil {
+				return result, fmt.Errorf("failed to set env var %s of %s: %w", key, functionID, err)
+			}
+			result.Imported++
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(fn.KV)) {
+			if !force {
+				exists, err := kvExists(kvStore, functionID, key)
+				if err != nil {
+					return result, fmt.Errorf("failed to read KV entry %s of %s: %w", key, functionID, err)
+				}
+				if exists {
+					result.Skipped++
+					continue
+				}
+			}
+			if err := kvStore.Set(functionID, key, fn.KV[key]); err != nil {
+				return result, fmt.Errorf("failed to set KV entry %s of %s: %w", key, functionID, err)
+			}
+			result.Imported++
+		}
+	}
+	return result, nil
+}
+
+// kvExists reports whether the KV store holds key for the function. The
+// stores answer a missing key with a *kv.Error.
+func kvExists(kvStore kv.Store, functionID, key string) (bool, error) {
+	_, err := kvStore.Get(functionID, key)
+	var notFound *kv.Error
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &notFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,117 @@
+package seed
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/services/env"
+	"github.com/dimiro1/lunar/internal/services/kv"
+)
+
+func writeSeed(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := map[string]string{
+		"seed.json": `{"functions": {"fn-1": {"env": {"PORT": "8080"}, "kv": {"greeting": "hello"}}}}`,
+		"seed.yaml": `
+functions:
+  fn-1:
+    env:
+      PORT: 8080
+    kv:
+      greeting: hello
+`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, err := Load(writeSeed(t, name, content))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			fn := file.Functions["fn-1"]
+			if fn.Env["PORT"] != "8080" || fn.KV["greeting"] != "hello" {
+				t.Errorf("unexpected seed data: %+v", file)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown.json": `{"functions": {"fn-1": {"environment": {"PORT": "8080"}}}}`,
+		"unknown.yml":  "functions:\n  fn-1:\n    environment:\n      PORT: 8080\n",
+		"invalid.json": `{"functions": `,
+	}
+	for name, content := range tests {
+		if _, err := Load(writeSeed(t, name, content)); err == nil || !strings.Contains(err.Error(), "failed to parse seed file") {
+			t.Errorf("%s: expected a parse error, got %v", name, err)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestApply(t *testing.T) {
+	kvStore := kv.NewMemoryStore()
+	envStore := env.NewMemoryStore()
+	_ = kvStore.Set("fn-1", "counter", "41")
+	_ = envStore.Set("fn-1", "API_KEY", "production-key")
+
+	file := File{Functions: map[string]Function{
+		"fn-1": {
+			Env: map[string]string{"API_KEY": "seed-key", "REGION": "eu"},
+			KV:  map[string]string{"counter": "0", "greeting": "hello"},
+		},
+		"fn-2": {KV: map[string]string{"greeting": "hi"}},
+	}}
+
+	result, err := Apply(file, kvStore, envStore, false)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Imported != 3 || result.Skipped != 2 {
+		t.Errorf("expected 3 imported and 2 skipped, got %+v", result)
+	}
+	if value, _ := envStore.Get("fn-1", "API_KEY"); value != "production-key" {
+		t.Errorf("expected the existing env var to be kept, got %q", value)
+	}
+	if value, _ := kvStore.Get("fn-1", "counter"); value != "41" {
+		t.Errorf("expected the existing KV entry to be kept, got %q", value)
+	}
+	if value, _ := kvStore.Get("fn-2", "greeting"); value != "hi" {
+		t.Errorf("expected the KV entry of fn-2 to be imported, got %q", value)
+	}
+
+	// Applying the file again changes nothing
+	result, err = Apply(file, kvStore, envStore, false)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Imported != 0 || result.Skipped != 5 {
+		t.Errorf("expected every key to be skipped, got %+v", result)
+	}
+
+	// Forcing overwrites existing keys
+	result, err = Apply(file, kvStore, envStore, true)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Imported != 5 || result.Skipped != 0 {
+		t.Errorf("expected every key to be imported, got %+v", result)
+	}
+	if value, _ := envStore.Get("fn-1", "API_KEY"); value != "seed-key" {
+		t.Errorf("expected the forced import to overwrite the env var, got %q", value)
+	}
+}
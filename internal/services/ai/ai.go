@@ -2,7 +2,10 @@ package ai
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/dimiro1/lunar/internal/services/env"
 	internalhttp "github.com/dimiro1/lunar/internal/services/http"
@@ -34,10 +37,10 @@ const anthropicVersion = "2023-06-01"
 
 // Provider environment variable names
 const (
-	openAIAPIKeyEnv      = "OPENAI_API_KEY"
-	openAIEndpointEnv    = "OPENAI_ENDPOINT"
-	anthropicAPIKeyEnv   = "ANTHROPIC_API_KEY"
-	anthropicEndpointEnv = "ANTHROPIC_ENDPOINT"
+	OpenAIAPIKeyEnv      = "OPENAI_API_KEY"
+	OpenAIEndpointEnv    = "OPENAI_ENDPOINT"
+	AnthropicAPIKeyEnv   = "ANTHROPIC_API_KEY"
+	AnthropicEndpointEnv = "ANTHROPIC_ENDPOINT"
 )
 
 // Message represents a chat message
@@ -46,14 +49,117 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ValidateMessages checks that every message has a known role and content,
+// that system prompts come before the conversation and that the conversation
+// is not empty. Message positions in errors are 1-based, matching Lua arrays.
+func ValidateMessages(messages []Message) error {
+	if len(messages) == 0 {
+		return errors.New("messages cannot be empty")
+	}
+
+	conversation := false
+	for i, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			if conversation {
+				return fmt.Errorf("messages[%d].role invalid: system messages must come before user and assistant messages", i+1)
+			}
+		case RoleUser, RoleAssistant:
+			conversation = true
+		case "":
+			return fmt.Errorf("messages[%d].role is required", i+1)
+		default:
+			return fmt.Errorf("messages[%d].role invalid: %q (use system, user or assistant)", i+1, msg.Role)
+		}
+
+		if strings.TrimSpace(msg.Content) == "" {
+			return fmt.Errorf("messages[%d].content is required", i+1)
+		}
+	}
+
+	if !conversation {
+		return errors.New("messages must include at least one user or assistant message")
+	}
+	return nil
+}
+
+// Response format types
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat asks the model for structured output, using the shape of
+// OpenAI's response_format, e.g. {"type": "json_object"}
+type ResponseFormat map[string]any
+
+// Type returns the requested format type
+func (f ResponseFormat) Type() string {
+	formatType, _ := f["type"].(string)
+	return formatType
+}
+
+// Validate checks the format type and that json_schema formats carry a schema
+func (f ResponseFormat) Validate() error {
+	switch f.Type() {
+	case ResponseFormatText, ResponseFormatJSONObject:
+		return nil
+	case ResponseFormatJSONSchema:
+		schema, ok := f["json_schema"].(map[string]any)
+		if !ok {
+			return errors.New("response_format.json_schema is required for type json_schema")
+		}
+		if _, ok := schema["schema"].(map[string]any); !ok {
+			return errors.New("response_format.json_schema.schema is required")
+		}
+		return nil
+	case "":
+		return errors.New("response_format.type is required")
+	default:
+		return fmt.Errorf("response_format.type invalid: %q (use text, json_object or json_schema)", f.Type())
+	}
+}
+
 // ChatRequest represents a unified chat request
 type ChatRequest struct {
-	Provider    string
-	Model       string
-	Messages    []Message
-	MaxTokens   int
-	Temperature float64
-	Endpoint    string // Optional custom endpoint URL (overrides env)
+	Provider       string
+	Model          string
+	Messages       []Message
+	MaxTokens      int
+	Temperature    float64
+	ResponseFormat ResponseFormat // Optional structured output format
+	Endpoint       string         // Optional custom endpoint URL (overrides env)
+}
+
+// Defaults are the function-level provider and model used by calls that
+// leave them out
+type Defaults struct {
+	Provider string
+	Model    string
+}
+
+// Apply fills the provider and model of req that the call left empty
+func (d Defaults) Apply(req *ChatRequest) {
+	if req.Provider == "" {
+		req.Provider = d.Provider
+	}
+	if req.Model == "" {
+		req.Model = d.Model
+	}
+}
+
+// IsSupportedProvider reports whether name is a provider the client can call
+func IsSupportedProvider(name string) bool {
+	_, ok := providers[name]
+	return ok
 }
 
 // ChatResponse represents the unified response from AI providers
@@ -99,6 +205,15 @@ func (c *DefaultClient) Chat(functionID string, req ChatRequest) (*ChatResponse,
 		return nil, fmt.Errorf("unsupported provider: %s (use openai or anthropic)", req.Provider)
 	}
 
+	if err := ValidateMessages(req.Messages); err != nil {
+		return nil, err
+	}
+	if req.ResponseFormat != nil {
+		if err := req.ResponseFormat.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get API key and endpoint from environment
 	apiKey, endpoint, err := c.getProviderConfig(functionID, req.Provider)
 	if err != nil {
@@ -117,17 +232,17 @@ func (c *DefaultClient) Chat(functionID string, req ChatRequest) (*ChatResponse,
 func (c *DefaultClient) getProviderConfig(functionID, providerName string) (apiKey, endpoint string, err error) {
 	switch providerName {
 	case "openai":
-		apiKey, err = c.envStore.Get(functionID, openAIAPIKeyEnv)
+		apiKey, err = c.envStore.Get(functionID, OpenAIAPIKeyEnv)
 		if err != nil || apiKey == "" {
-			return "", "", fmt.Errorf("%s not set in function environment", openAIAPIKeyEnv)
+			return "", "", fmt.Errorf("%s not set in function environment", OpenAIAPIKeyEnv)
 		}
-		endpoint, _ = c.envStore.Get(functionID, openAIEndpointEnv)
+		endpoint, _ = c.envStore.Get(functionID, OpenAIEndpointEnv)
 	case "anthropic":
-		apiKey, err = c.envStore.Get(functionID, anthropicAPIKeyEnv)
+		apiKey, err = c.envStore.Get(functionID, AnthropicAPIKeyEnv)
 		if err != nil || apiKey == "" {
-			return "", "", fmt.Errorf("%s not set in function environment", anthropicAPIKeyEnv)
+			return "", "", fmt.Errorf("%s not set in function environment", AnthropicAPIKeyEnv)
 		}
-		endpoint, _ = c.envStore.Get(functionID, anthropicEndpointEnv)
+		endpoint, _ = c.envStore.Get(functionID, AnthropicEndpointEnv)
 	default:
 		return "", "", fmt.Errorf("unsupported provider: %s (use openai or anthropic)", providerName)
 	}
@@ -166,7 +281,7 @@ func (c *DefaultClient) callProvider(req ChatRequest, p provider, apiKey, endpoi
 		Body:    string(jsonBody),
 	})
 	if err != nil {
-		return chatResp, fmt.Errorf("HTTP request failed: %v", err)
+		return chatResp, fmt.Errorf("HTTP request failed: %w", err)
 	}
 
 	// Store response body for tracking
@@ -174,6 +289,16 @@ func (c *DefaultClient) callProvider(req ChatRequest, p provider, apiKey, endpoi
 
 	// Parse response
 	parsedResp, err := p.parseResponse(resp.Body)
+	if resp.IsError() {
+		if err == nil {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		return chatResp, &APIError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Headers["Retry-After"], time.Now()),
+			Err:        err,
+		}
+	}
 	if err != nil {
 		return chatResp, err
 	}
@@ -185,3 +310,36 @@ func (c *DefaultClient) callProvider(req ChatRequest, p provider, apiKey, endpoi
 
 	return chatResp, nil
 }
+
+// FakeClient is a stub implementation of Client that answers every chat with
+// Response instead of calling a provider
+type FakeClient struct {
+	// Response is returned for every chat, with the requested model
+	Response ChatResponse
+	// Err is returned instead of Response when set
+	Err error
+	// Requests stores all chat requests made (for verification)
+	Requests []ChatRequest
+}
+
+// NewFakeClient creates a new fake AI client answering with content
+func NewFakeClient(content string) *FakeClient {
+	return &FakeClient{
+		Response: ChatResponse{Content: content},
+		Requests: make([]ChatRequest, 0),
+	}
+}
+
+// Chat records the request and returns the configured response
+func (f *FakeClient) Chat(_ string, req ChatRequest) (*ChatResponse, error) {
+	f.Requests = append(f.Requests, req)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	resp := f.Response
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+	return &resp, nil
+}
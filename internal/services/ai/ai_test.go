@@ -2,8 +2,10 @@ package ai
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/dimiro1/lunar/internal/services/env"
@@ -234,6 +236,39 @@ func TestChat_OpenAI_APIError(t *testing.T) {
 	}
 }
 
+func TestChat_CircuitOpen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("func-1", "OPENAI_API_KEY", "test-api-key")
+
+	breaker := internalhttp.NewBreaker(internalhttp.BreakerConfig{Threshold: 2})
+	client := NewDefaultClient(internalhttp.NewBreakerClient(internalhttp.NewDefaultClient(), breaker), envStore)
+
+	req := ChatRequest{
+		Provider: "openai",
+		Model:    "gpt-4",
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+		Endpoint: server.URL,
+	}
+
+	for range 2 {
+		var apiErr *APIError
+		if _, err := client.Chat("func-1", req); !errors.As(err, &apiErr) {
+			t.Fatalf("expected APIError before the circuit opens, got %v", err)
+		}
+	}
+
+	_, err := client.Chat("func-1", req)
+	var openErr *internalhttp.CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("expected CircuitOpenError, got %v", err)
+	}
+}
+
 func TestChat_Anthropic_APIError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := map[string]any{
@@ -339,3 +374,175 @@ func TestChat_UsesEnvEndpoint(t *testing.T) {
 		t.Error("expected request to be received")
 	}
 }
+
+func TestValidateMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []Message
+		wantErr  string
+	}{
+		{
+			name:     "user message",
+			messages: []Message{{Role: "user", Content: "Hello"}},
+		},
+		{
+			name: "system prompt and conversation",
+			messages: []Message{
+				{Role: "system", Content: "Be brief"},
+				{Role: "user", Content: "Hello"},
+				{Role: "assistant", Content: "Hi"},
+				{Role: "user", Content: "Bye"},
+			},
+		},
+		{
+			name:    "empty",
+			wantErr: "messages cannot be empty",
+		},
+		{
+			name:     "invalid role",
+			messages: []Message{{Role: "user", Content: "Hello"}, {Role: "bot", Content: "Hi"}},
+			wantErr:  `messages[2].role invalid: "bot" (use system, user or assistant)`,
+		},
+		{
+			name:     "missing role",
+			messages: []Message{{Content: "Hello"}},
+			wantErr:  "messages[1].role is required",
+		},
+		{
+			name:     "missing content",
+			messages: []Message{{Role: "user", Content: "Hello"}, {Role: "assistant", Content: "Hi"}, {Role: "user", Content: "  "}},
+			wantErr:  "messages[3].content is required",
+		},
+		{
+			name:     "system prompt after conversation",
+			messages: []Message{{Role: "user", Content: "Hello"}, {Role: "system", Content: "Be brief"}},
+			wantErr:  "messages[2].role invalid: system messages must come before user and assistant messages",
+		},
+		{
+			name:     "only system prompt",
+			messages: []Message{{Role: "system", Content: "Be brief"}},
+			wantErr:  "messages must include at least one user or assistant message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMessages(tt.messages)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestChat_InvalidMessages(t *testing.T) {
+	fakeHTTP := internalhttp.NewFakeClient()
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("func-1", "OPENAI_API_KEY", "sk-test")
+	client := NewDefaultClient(fakeHTTP, envStore)
+
+	_, err := client.Chat("func-1", ChatRequest{
+		Provider: "openai",
+		Model:    "gpt-4o-mini",
+		Messages: []Message{{Role: "robot", Content: "Hello"}},
+	})
+	if err == nil || err.Error() != `messages[1].role invalid: "robot" (use system, user or assistant)` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(fakeHTTP.Requests) != 0 {
+		t.Errorf("expected no request to the provider, got %d", len(fakeHTTP.Requests))
+	}
+}
+
+func TestResponseFormat_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  ResponseFormat
+		wantErr string
+	}{
+		{name: "text", format: ResponseFormat{"type": "text"}},
+		{name: "json object", format: ResponseFormat{"type": "json_object"}},
+		{
+			name: "json schema",
+			format: ResponseFormat{"type": "json_schema", "json_schema": map[string]any{
+				"name": "person", "schema": map[string]any{"type": "object"},
+			}},
+		},
+		{name: "missing type", format: ResponseFormat{}, wantErr: "response_format.type is required"},
+		{name: "invalid type", format: ResponseFormat{"type": "xml"}, wantErr: `response_format.type invalid: "xml" (use text, json_object or json_schema)`},
+		{name: "json schema without schema", format: ResponseFormat{"type": "json_schema", "json_schema": map[string]any{"name": "person"}}, wantErr: "response_format.json_schema.schema is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.format.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestChat_Anthropic_EmulatesJSONResponseFormat(t *testing.T) {
+	var receivedSystem string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&reqBody)
+		receivedSystem, _ = reqBody["system"].(string)
+		if _, ok := reqBody["response_format"]; ok {
+			t.Error("expected response_format not to be sent to Anthropic")
+		}
+
+		resp := map[string]any{
+			"model":   "claude-3-haiku",
+			"content": []map[string]any{{"type": "text", "text": `{"ok":true}`}},
+			"usage":   map[string]any{"input_tokens": 15, "output_tokens": 4},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	envStore := env.NewMemoryStore()
+	_ = envStore.Set("func-1", "ANTHROPIC_API_KEY", "test-api-key")
+
+	client := NewDefaultClient(internalhttp.NewDefaultClient(), envStore)
+
+	req := ChatRequest{
+		Provider: "anthropic",
+		Model:    "claude-3-haiku",
+		Messages: []Message{
+			{Role: "system", Content: "You extract data."},
+			{Role: "user", Content: "Hello"},
+		},
+		ResponseFormat: ResponseFormat{"type": "json_schema", "json_schema": map[string]any{
+			"name":   "result",
+			"schema": map[string]any{"type": "object"},
+		}},
+		Endpoint: server.URL,
+	}
+
+	if _, err := client.Chat("func-1", req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(receivedSystem, "You extract data.\n\nRespond only with a valid JSON object") {
+		t.Errorf("expected JSON instruction after the system prompt, got %q", receivedSystem)
+	}
+	if !strings.HasSuffix(receivedSystem, `{"type":"object"}`) {
+		t.Errorf("expected the schema in the system prompt, got %q", receivedSystem)
+	}
+}
@@ -26,13 +26,25 @@ func (anthropicProvider) buildRequestBody(req ChatRequest) (any, error) {
 	var systemPrompt string
 	var userMessages []Message
 	for _, msg := range req.Messages {
-		if msg.Role == "system" {
+		if msg.Role == RoleSystem {
 			systemPrompt = msg.Content
 		} else {
 			userMessages = append(userMessages, msg)
 		}
 	}
 
+	// Anthropic has no response format parameter, so JSON output is requested in the system prompt
+	instruction, err := jsonInstruction(req.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	if instruction != "" {
+		if systemPrompt != "" {
+			systemPrompt += "\n\n"
+		}
+		systemPrompt += instruction
+	}
+
 	body := struct {
 		Model       string    `json:"model"`
 		MaxTokens   int       `json:"max_tokens"`
@@ -51,6 +63,24 @@ func (anthropicProvider) buildRequestBody(req ChatRequest) (any, error) {
 	return body, nil
 }
 
+// jsonInstruction returns the system prompt instruction emulating a JSON response format
+func jsonInstruction(format ResponseFormat) (string, error) {
+	switch format.Type() {
+	case ResponseFormatJSONObject:
+		return "Respond only with a valid JSON object, without any surrounding text or code fences.", nil
+	case ResponseFormatJSONSchema:
+		jsonSchema, _ := format["json_schema"].(map[string]any)
+		schema, err := json.Marshal(jsonSchema["schema"])
+		if err != nil {
+			return "", fmt.Errorf("failed to encode response_format schema: %v", err)
+		}
+		return "Respond only with a valid JSON object that conforms to the following JSON Schema, " +
+			"without any surrounding text or code fences:\n" + string(schema), nil
+	default:
+		return "", nil
+	}
+}
+
 func (anthropicProvider) parseResponse(body string) (*ChatResponse, error) {
 	var resp struct {
 		Model   string `json:"model"`
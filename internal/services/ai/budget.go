@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+// Budget caps the AI usage allowed per calendar month (UTC).
+// Zero fields are unlimited.
+type Budget struct {
+	Tokens  int64   // Input plus output tokens
+	CostUSD float64 // Estimated cost of priced models
+}
+
+// IsZero reports whether the budget has no limits
+func (b Budget) IsZero() bool {
+	return b.Tokens <= 0 && b.CostUSD <= 0
+}
+
+// FunctionBudget returns the budget configured on a function
+func FunctionBudget(fn store.Function) Budget {
+	var b Budget
+	if fn.AIBudgetTokens != nil {
+		b.Tokens = *fn.AIBudgetTokens
+	}
+	if fn.AIBudgetUSD != nil {
+		b.CostUSD = *fn.AIBudgetUSD
+	}
+	return b
+}
+
+// BudgetExceededError is returned instead of calling the provider once a
+// monthly budget has been used up
+type BudgetExceededError struct {
+	Scope string // "function" or "global"
+	Limit string // Human readable limit, e.g. "10000 tokens" or "$5.00"
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("AI budget exceeded: monthly %s budget of %s reached", e.Scope, e.Limit)
+}
+
+// exceeded returns a BudgetExceededError when usage has reached the budget.
+// Models without a known price do not count towards the cost limit.
+func (b Budget) exceeded(scope string, usage []store.AIUsage, prices PriceTable) error {
+	var tokens int64
+	var cost float64
+	for _, u := range usage {
+		tokens += u.InputTokens + u.OutputTokens
+		if c, ok := prices.Cost(u.Provider, u.Model, u.InputTokens, u.OutputTokens); ok {
+			cost += c
+		}
+	}
+
+	if b.Tokens > 0 && tokens >= b.Tokens {
+		return &BudgetExceededError{Scope: scope, Limit: strconv.FormatInt(b.Tokens, 10) + " tokens"}
+	}
+	if b.CostUSD > 0 && cost >= b.CostUSD {
+		return &BudgetExceededError{Scope: scope, Limit: fmt.Sprintf("$%.2f", b.CostUSD)}
+	}
+	return nil
+}
+
+// MonthStart returns the Unix timestamp of the start of t's month in UTC
+func MonthStart(t time.Time) int64 {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Unix()
+}
+
+// BudgetConfig configures a BudgetedClient
+type BudgetConfig struct {
+	Reporter UsageReporter    // Source of the month's usage
+	Prices   PriceTable       // Prices used for cost limits
+	Global   Budget           // Budget shared by all functions
+	DB       store.DB         // Source of per-function budgets and overrides
+	Now      func() time.Time // Defaults to time.Now
+}
+
+// BudgetedClient wraps an ai.Client and refuses requests once the function's
+// or the global monthly budget is used up. Budgets are checked before each
+// request, so the request that crosses a limit still completes.
+type BudgetedClient struct {
+	client Client
+	config BudgetConfig
+}
+
+// NewBudgetedClient creates a BudgetedClient that enforces budgets on the given client
+func NewBudgetedClient(client Client, config BudgetConfig) *BudgetedClient {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &BudgetedClient{client: client, config: config}
+}
+
+// Chat executes the chat request if the budgets allow it
+func (c *BudgetedClient) Chat(functionID string, req ChatRequest) (*ChatResponse, error) {
+	if err := c.Check(functionID); err != nil {
+		return nil, err
+	}
+	return c.client.Chat(functionID, req)
+}
+
+// Check returns a *BudgetExceededError when the function may not make
+// further AI requests this month. Functions with AIBudgetOverride set are
+// never limited.
+func (c *BudgetedClient) Check(functionID string) error {
+	var functionBudget Budget
+	if c.config.DB != nil {
+		fn, err := c.config.DB.GetFunction(context.Background(), functionID)
+		if err != nil {
+			return fmt.Errorf("failed to check AI budget: %w", err)
+		}
+		if fn.AIBudgetOverride {
+			return nil
+		}
+		functionBudget = FunctionBudget(fn)
+	}
+
+	if c.config.Reporter == nil || (functionBudget.IsZero() && c.config.Global.IsZero()) {
+		return nil
+	}
+
+	since := MonthStart(c.config.Now())
+
+	if !functionBudget.IsZero() {
+		usage, err := c.config.Reporter.Usage(functionID, since)
+		if err != nil {
+			return fmt.Errorf("failed to check AI budget: %w", err)
+		}
+		if err := functionBudget.exceeded("function", usage, c.config.Prices); err != nil {
+			return err
+		}
+	}
+
+	if !c.config.Global.IsZero() {
+		usage, err := c.config.Reporter.TotalUsage(since)
+		if err != nil {
+			return fmt.Errorf("failed to check AI budget: %w", err)
+		}
+		if err := c.config.Global.exceeded("global", usage, c.config.Prices); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
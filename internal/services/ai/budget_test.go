@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/store"
+)
+
+// fakeUsageReporter returns fixed usage for a function and for all functions
+type fakeUsageReporter struct {
+	function []store.AIUsage
+	total    []store.AIUsage
+	since    int64
+}
+
+func (f *fakeUsageReporter) Usage(_ string, since int64) ([]store.AIUsage, error) {
+	f.since = since
+	return f.function, nil
+}
+
+func (f *fakeUsageReporter) TotalUsage(since int64) ([]store.AIUsage, error) {
+	f.since = since
+	return f.total, nil
+}
+
+// countingClient counts the requests that reach the provider
+type countingClient struct {
+	calls int
+}
+
+func (c *countingClient) Chat(_ string, _ ChatRequest) (*ChatResponse, error) {
+	c.calls++
+	return &ChatResponse{Content: "ok"}, nil
+}
+
+func TestBudgetedClient(t *testing.T) {
+	ctx := context.Background()
+	prices := PriceTable{"openai/gpt-4o": {Input: 2.5, Output: 10}}
+	now := time.Date(2025, time.March, 17, 12, 0, 0, 0, time.UTC)
+
+	reporter := &fakeUsageReporter{
+		function: []store.AIUsage{{Provider: "openai", Model: "gpt-4o", InputTokens: 800_000, OutputTokens: 200_000}},
+		total:    []store.AIUsage{{Provider: "openai", Model: "gpt-4o", InputTokens: 2_000_000, OutputTokens: 1_000_000}},
+	}
+
+	newFunction := func(t *testing.T, updates store.UpdateFunctionRequest) (store.DB, string) {
+		t.Helper()
+		database := store.NewMemoryDB()
+		fn, err := database.CreateFunction(ctx, store.Function{ID: "fn-1", Name: "budgeted"})
+		if err != nil {
+			t.Fatalf("failed to create function: %v", err)
+		}
+		if err := database.UpdateFunction(ctx, fn.ID, updates); err != nil {
+			t.Fatalf("failed to update function: %v", err)
+		}
+		return database, fn.ID
+	}
+
+	tokens := func(n int64) *int64 { return &n }
+	usd := func(n float64) *float64 { return &n }
+	override := true
+
+	tests := []struct {
+		name      string
+		updates   store.UpdateFunctionRequest
+		global    Budget
+		wantScope string
+	}{
+		{name: "no budgets", wantScope: ""},
+		{name: "under function token budget", updates: store.UpdateFunctionRequest{AIBudgetTokens: tokens(2_000_000)}, wantScope: ""},
+		{name: "function token budget reached", updates: store.UpdateFunctionRequest{AIBudgetTokens: tokens(1_000_000)}, wantScope: "function"},
+		{name: "function cost budget reached", updates: store.UpdateFunctionRequest{AIBudgetUSD: usd(4)}, wantScope: "function"},
+		{name: "under function cost budget", updates: store.UpdateFunctionRequest{AIBudgetUSD: usd(4.01)}, wantScope: ""},
+		{name: "global cost budget reached", global: Budget{CostUSD: 15}, wantScope: "global"},
+		{name: "under global token budget", global: Budget{Tokens: 3_000_001}, wantScope: ""},
+		{name: "override ignores budgets", updates: store.UpdateFunctionRequest{AIBudgetTokens: tokens(1), AIBudgetOverride: &override}, global: Budget{Tokens: 1}, wantScope: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, functionID := newFunction(t, tt.updates)
+			inner := &countingClient{}
+			client := NewBudgetedClient(inner, BudgetConfig{
+				Reporter: reporter,
+				Prices:   prices,
+				Global:   tt.global,
+				DB:       database,
+				Now:      func() time.Time { return now },
+			})
+
+			_, err := client.Chat(functionID, ChatRequest{Provider: "openai", Model: "gpt-4o"})
+
+			if tt.wantScope == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if inner.calls != 1 {
+					t.Errorf("expected the provider to be called once, got %d", inner.calls)
+				}
+				return
+			}
+
+			var budgetErr *BudgetExceededError
+			if !errors.As(err, &budgetErr) {
+				t.Fatalf("expected BudgetExceededError, got %v", err)
+			}
+			if budgetErr.Scope != tt.wantScope {
+				t.Errorf("expected scope %q, got %q", tt.wantScope, budgetErr.Scope)
+			}
+			if inner.calls != 0 {
+				t.Errorf("expected the provider not to be called, got %d calls", inner.calls)
+			}
+		})
+	}
+
+	if want := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC).Unix(); reporter.since != want {
+		t.Errorf("expected usage since the start of the month %d, got %d", want, reporter.since)
+	}
+}
+
+func TestBudgetExceededError(t *testing.T) {
+	err := (Budget{Tokens: 10}).exceeded("function", []store.AIUsage{{InputTokens: 6, OutputTokens: 4}}, nil)
+	if err == nil || err.Error() != "AI budget exceeded: monthly function budget of 10 tokens reached" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = (Budget{CostUSD: 1}).exceeded("global", []store.AIUsage{{Provider: "local", Model: "llama", InputTokens: 1_000_000_000}}, PriceTable{})
+	if err != nil {
+		t.Errorf("expected unpriced models not to count towards the cost budget, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package ai
+
+import "github.com/dimiro1/lunar/internal/killswitch"
+
+// SwitchedClient wraps a Client and fails every request while the ai
+// integration is switched off, without contacting the provider.
+type SwitchedClient struct {
+	client Client
+	sw     *killswitch.Switch
+}
+
+// NewSwitchedClient creates a SwitchedClient guarding client with sw
+func NewSwitchedClient(client Client, sw *killswitch.Switch) *SwitchedClient {
+	return &SwitchedClient{client: client, sw: sw}
+}
+
+// Chat executes the chat request unless the ai integration is disabled
+func (c *SwitchedClient) Chat(functionID string, req ChatRequest) (*ChatResponse, error) {
+	if err := c.sw.Check(killswitch.AI); err != nil {
+		return nil, err
+	}
+	return c.client.Chat(functionID, req)
+}
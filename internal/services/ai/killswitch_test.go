@@ -0,0 +1,28 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/killswitch"
+)
+
+func TestSwitchedClient(t *testing.T) {
+	inner := &scriptedClient{}
+	sw := killswitch.New(killswitch.AI)
+	client := NewSwitchedClient(inner, sw)
+
+	_, err := client.Chat("fn", ChatRequest{})
+	var disabledErr *killswitch.DisabledError
+	if !errors.As(err, &disabledErr) {
+		t.Fatalf("expected DisabledError while disabled, got %v", err)
+	}
+	if inner.calls != 0 {
+		t.Errorf("expected provider not to be called, got %d calls", inner.calls)
+	}
+
+	sw.Set(killswitch.AI, false)
+	if _, err := client.Chat("fn", ChatRequest{}); err != nil {
+		t.Fatalf("expected request to pass once enabled, got %v", err)
+	}
+}
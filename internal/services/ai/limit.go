@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"time"
+
+	"github.com/dimiro1/lunar/internal/outbound"
+)
+
+// LimitedClient wraps a Client and fails requests made after the execution
+// spent its outbound call or time budget, without contacting the provider.
+// Every retry attempt counts as a call.
+type LimitedClient struct {
+	client Client
+	budget *outbound.Budget
+}
+
+// NewLimitedClient creates a LimitedClient counting requests against budget
+func NewLimitedClient(client Client, budget *outbound.Budget) *LimitedClient {
+	return &LimitedClient{client: client, budget: budget}
+}
+
+// Chat executes the chat request unless the budget is spent
+func (c *LimitedClient) Chat(functionID string, req ChatRequest) (*ChatResponse, error) {
+	if err := c.budget.Acquire("ai"); err != nil {
+		return nil, err
+	}
+	defer c.record(time.Now())
+	return c.client.Chat(functionID, req)
+}
+
+// record adds the time since start to the budget
+func (c *LimitedClient) record(start time.Time) {
+	c.budget.Record(time.Since(start))
+}
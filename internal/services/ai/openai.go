@@ -22,14 +22,16 @@ func (openAIProvider) headers(apiKey string) internalhttp.Headers {
 
 func (openAIProvider) buildRequestBody(req ChatRequest) (any, error) {
 	body := struct {
-		Model       string    `json:"model"`
-		Messages    []Message `json:"messages"`
-		MaxTokens   int       `json:"max_tokens,omitempty"`
-		Temperature float64   `json:"temperature,omitempty"`
+		Model          string         `json:"model"`
+		Messages       []Message      `json:"messages"`
+		MaxTokens      int            `json:"max_tokens,omitempty"`
+		Temperature    float64        `json:"temperature,omitempty"`
+		ResponseFormat ResponseFormat `json:"response_format,omitempty"`
 	}{
-		Model:     req.Model,
-		Messages:  req.Messages,
-		MaxTokens: req.MaxTokens,
+		Model:          req.Model,
+		Messages:       req.Messages,
+		MaxTokens:      req.MaxTokens,
+		ResponseFormat: req.ResponseFormat,
 	}
 	if req.Temperature > 0 {
 		body.Temperature = req.Temperature
@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+)
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// PriceTable maps models to their prices. Keys are either a model name
+// ("gpt-4o") or a provider-qualified model name ("openai/gpt-4o"). A key also
+// matches dated variants of the model, e.g. "gpt-4o" prices "gpt-4o-2024-08-06".
+type PriceTable map[string]ModelPrice
+
+// DefaultPrices returns the built-in price table. Prices change over time,
+// so deployments should override them with LoadPriceTable.
+func DefaultPrices() PriceTable {
+	return PriceTable{
+		"openai/gpt-4o":                 {Input: 2.50, Output: 10.00},
+		"openai/gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+		"openai/gpt-4.1":                {Input: 2.00, Output: 8.00},
+		"openai/gpt-4.1-mini":           {Input: 0.40, Output: 1.60},
+		"openai/gpt-4.1-nano":           {Input: 0.10, Output: 0.40},
+		"openai/text-embedding-3-small": {Input: 0.02},
+		"openai/text-embedding-3-large": {Input: 0.13},
+		"anthropic/claude-3-5-haiku":    {Input: 0.80, Output: 4.00},
+		"anthropic/claude-3-5-sonnet":   {Input: 3.00, Output: 15.00},
+		"anthropic/claude-3-7-sonnet":   {Input: 3.00, Output: 15.00},
+		"anthropic/claude-sonnet-4":     {Input: 3.00, Output: 15.00},
+		"anthropic/claude-opus-4":       {Input: 15.00, Output: 75.00},
+	}
+}
+
+// LoadPriceTable returns DefaultPrices overridden by the JSON price table at path.
+// An empty path returns the defaults.
+func LoadPriceTable(path string) (PriceTable, error) {
+	prices := DefaultPrices()
+	if path == "" {
+		return prices, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price table: %w", err)
+	}
+
+	var overrides PriceTable
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse price table: %w", err)
+	}
+
+	maps.Copy(prices, overrides)
+	return prices, nil
+}
+
+// Lookup finds the price of a model, preferring provider-qualified and
+// exact matches over dated variants
+func (p PriceTable) Lookup(provider, model string) (ModelPrice, bool) {
+	if price, ok := p[provider+"/"+model]; ok {
+		return price, true
+	}
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	var best string
+	for key := range p {
+		keyProvider, keyModel, qualified := strings.Cut(key, "/")
+		if !qualified {
+			keyModel = key
+		} else if keyProvider != provider {
+			continue
+		}
+		if strings.HasPrefix(model, keyModel+"-") && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return p[best], true
+}
+
+// Cost estimates the cost in USD of the given token counts
+func (p PriceTable) Cost(provider, model string, inputTokens, outputTokens int64) (float64, bool) {
+	price, ok := p.Lookup(provider, model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1_000_000, true
+}
@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPriceTable_Lookup(t *testing.T) {
+	prices := PriceTable{
+		"openai/gpt-4o":      {Input: 2.5, Output: 10},
+		"openai/gpt-4o-mini": {Input: 0.15, Output: 0.6},
+		"custom-model":       {Input: 1, Output: 2},
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		expected ModelPrice
+		found    bool
+	}{
+		{name: "exact qualified", provider: "openai", model: "gpt-4o", expected: ModelPrice{Input: 2.5, Output: 10}, found: true},
+		{name: "dated variant", provider: "openai", model: "gpt-4o-2024-08-06", expected: ModelPrice{Input: 2.5, Output: 10}, found: true},
+		{name: "longest prefix wins", provider: "openai", model: "gpt-4o-mini-2024-07-18", expected: ModelPrice{Input: 0.15, Output: 0.6}, found: true},
+		{name: "unqualified key", provider: "anthropic", model: "custom-model", expected: ModelPrice{Input: 1, Output: 2}, found: true},
+		{name: "other provider", provider: "anthropic", model: "gpt-4o", found: false},
+		{name: "unknown", provider: "openai", model: "unknown", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, ok := prices.Lookup(tt.provider, tt.model)
+			if ok != tt.found || price != tt.expected {
+				t.Errorf("Lookup(%q, %q) = (%v, %v), want (%v, %v)", tt.provider, tt.model, price, ok, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestPriceTable_Cost(t *testing.T) {
+	prices := PriceTable{"openai/gpt-4o": {Input: 2.5, Output: 10}}
+
+	cost, ok := prices.Cost("openai", "gpt-4o", 1_000_000, 500_000)
+	if !ok {
+		t.Fatal("expected model to be priced")
+	}
+	if math.Abs(cost-7.5) > 1e-9 {
+		t.Errorf("expected cost 7.5, got %v", cost)
+	}
+
+	if _, ok := prices.Cost("openai", "unknown", 1, 1); ok {
+		t.Error("expected unknown model to be unpriced")
+	}
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	content := `{"openai/gpt-4o": {"input": 1, "output": 4}, "local/llama": {"input": 0, "output": 0}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write price table: %v", err)
+	}
+
+	prices, err := LoadPriceTable(path)
+	if err != nil {
+		t.Fatalf("LoadPriceTable failed: %v", err)
+	}
+	if prices["openai/gpt-4o"] != (ModelPrice{Input: 1, Output: 4}) {
+		t.Errorf("expected override for gpt-4o, got %v", prices["openai/gpt-4o"])
+	}
+	if _, ok := prices["local/llama"]; !ok {
+		t.Error("expected new model from file")
+	}
+	if _, ok := prices["openai/gpt-4o-mini"]; !ok {
+		t.Error("expected defaults to be kept")
+	}
+
+	if _, err := LoadPriceTable(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// scriptedClient returns the scripted errors in order, then succeeds
+type scriptedClient struct {
+	errs  []error
+	calls int
+}
+
+func (c *scriptedClient) Chat(_ string, _ ChatRequest) (*ChatResponse, error) {
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return nil, c.errs[c.calls-1]
+	}
+	return &ChatResponse{Content: "ok"}, nil
+}
+
+func apiError(status int) error {
+	return &APIError{StatusCode: status, Err: errors.New(http.StatusText(status))}
+}
+
+func TestRetryingClient(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		policy    RetryPolicy
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "success", wantCalls: 1, policy: policy},
+		{name: "rate limit then success", errs: []error{apiError(429)}, policy: policy, wantCalls: 2},
+		{name: "server errors then success", errs: []error{apiError(500), apiError(503)}, policy: policy, wantCalls: 3},
+		{name: "gives up after max retries", errs: []error{apiError(502), apiError(502), apiError(502)}, policy: policy, wantCalls: 3, wantErr: true},
+		{name: "bad request is not retried", errs: []error{apiError(400)}, policy: policy, wantCalls: 1, wantErr: true},
+		{name: "unauthorized is not retried", errs: []error{apiError(401)}, policy: policy, wantCalls: 1, wantErr: true},
+		{name: "other errors are not retried", errs: []error{errors.New("HTTP request failed")}, policy: policy, wantCalls: 1, wantErr: true},
+		{name: "zero policy disables retries", errs: []error{apiError(429)}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &scriptedClient{errs: tt.errs}
+			_, err := NewRetryingClient(context.Background(), inner, tt.policy).Chat("fn", ChatRequest{})
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Chat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if inner.calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, inner.calls)
+			}
+		})
+	}
+}
+
+func TestRetryingClient_StopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	inner := &scriptedClient{errs: []error{
+		&APIError{StatusCode: 429, RetryAfter: time.Minute, Err: errors.New("rate limited")},
+	}}
+
+	start := time.Now()
+	_, err := NewRetryingClient(ctx, inner, RetryPolicy{MaxRetries: 3}).Chat("fn", ChatRequest{})
+	if err == nil {
+		t.Fatal("expected the rate limit error")
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected no retry past the deadline, got %d calls", inner.calls)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("expected to fail fast, took %v", elapsed)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+	} {
+		if got := policy.backoff(retry, 0); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", retry, got, want)
+		}
+	}
+
+	if got := policy.backoff(1, 3*time.Second); got != 3*time.Second {
+		t.Errorf("expected Retry-After to take precedence, got %v", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.March, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 17 Mar 2025 12:00:30 GMT": 30 * time.Second,
+		"Mon, 17 Mar 2025 11:00:00 GMT": 0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
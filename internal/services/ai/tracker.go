@@ -3,6 +3,7 @@ package ai
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,6 +32,51 @@ type Tracker interface {
 	Track(executionID string, req TrackRequest)
 	Requests(executionID string) []store.AIRequest
 	RequestsPaginated(executionID string, limit, offset int) ([]store.AIRequest, int64)
+	RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.AIRequest, int64)
+}
+
+// RequestFilter narrows the AI requests returned by RequestsFiltered.
+// Empty fields match any value.
+type RequestFilter struct {
+	Provider string
+	Model    string
+	Status   store.AIRequestStatus
+}
+
+// matches reports whether the request satisfies every non-empty filter field
+func (f RequestFilter) matches(req store.AIRequest) bool {
+	return (f.Provider == "" || req.Provider == f.Provider) &&
+		(f.Model == "" || req.Model == f.Model) &&
+		(f.Status == "" || req.Status == f.Status)
+}
+
+// where builds the SQL conditions and arguments for the filter
+func (f RequestFilter) where(executionID string) (string, []any) {
+	conditions := []string{"execution_id = ?"}
+	args := []any{executionID}
+	if f.Provider != "" {
+		conditions = append(conditions, "provider = ?")
+		args = append(args, f.Provider)
+	}
+	if f.Model != "" {
+		conditions = append(conditions, "model = ?")
+		args = append(args, f.Model)
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, f.Status)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// UsageReporter aggregates tracked AI usage
+type UsageReporter interface {
+	// Usage sums requests and tokens by provider and model for the
+	// function's executions since the given Unix timestamp
+	Usage(functionID string, since int64) ([]store.AIUsage, error)
+	// TotalUsage sums requests and tokens by provider and model across
+	// all functions since the given Unix timestamp
+	TotalUsage(since int64) ([]store.AIUsage, error)
 }
 
 // MemoryTracker is an in-memory implementation of Tracker
@@ -59,6 +105,7 @@ func (m *MemoryTracker) Track(executionID string, req TrackRequest) {
 		maskedResponseJSON = &masked
 	}
 
+	now := time.Now()
 	aiReq := store.AIRequest{
 		ID:           xid.New().String(),
 		ExecutionID:  executionID,
@@ -72,7 +119,8 @@ func (m *MemoryTracker) Track(executionID string, req TrackRequest) {
 		InputTokens:  req.InputTokens,
 		OutputTokens: req.OutputTokens,
 		DurationMs:   req.DurationMs,
-		CreatedAt:    time.Now().Unix(),
+		CreatedAt:    now.Unix(),
+		CreatedAtNs:  now.UnixNano(),
 	}
 
 	m.requests = append(m.requests, aiReq)
@@ -94,13 +142,18 @@ func (m *MemoryTracker) Requests(executionID string) []store.AIRequest {
 
 // RequestsPaginated returns paginated AI requests for the specified executionID
 func (m *MemoryTracker) RequestsPaginated(executionID string, limit, offset int) ([]store.AIRequest, int64) {
+	return m.RequestsFiltered(executionID, RequestFilter{}, limit, offset)
+}
+
+// RequestsFiltered returns paginated AI requests for the specified executionID matching the filter
+func (m *MemoryTracker) RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.AIRequest, int64) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// Filter requests by executionID
+	// Filter requests by executionID and the filter fields
 	filtered := make([]store.AIRequest, 0)
 	for _, req := range m.requests {
-		if req.ExecutionID == executionID {
+		if req.ExecutionID == executionID && filter.matches(req) {
 			filtered = append(filtered, req)
 		}
 	}
@@ -130,6 +183,9 @@ type SQLiteTracker struct {
 	db *sql.DB
 }
 
+// Compile-time check that SQLiteTracker reports usage
+var _ UsageReporter = (*SQLiteTracker)(nil)
+
 // NewSQLiteTracker creates a new SQLite-backed tracker
 func NewSQLiteTracker(db *sql.DB) *SQLiteTracker {
 	return &SQLiteTracker{db: db}
@@ -146,14 +202,15 @@ func (s *SQLiteTracker) Track(executionID string, req TrackRequest) {
 	}
 
 	id := xid.New().String()
+	now := time.Now()
 	_, err := s.db.Exec(
 		`INSERT INTO ai_requests
 		(id, execution_id, provider, model, endpoint, request_json, response_json,
-		 status, error_message, input_tokens, output_tokens, duration_ms, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		 status, error_message, input_tokens, output_tokens, duration_ms, created_at, created_at_ns)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id, executionID, req.Provider, req.Model, req.Endpoint, maskedRequestJSON,
 		maskedResponseJSON, req.Status, req.ErrorMessage, req.InputTokens,
-		req.OutputTokens, req.DurationMs, time.Now().Unix(),
+		req.OutputTokens, req.DurationMs, now.Unix(), now.UnixNano(),
 	)
 	if err != nil {
 		// Log error but don't fail the execution
@@ -165,8 +222,8 @@ func (s *SQLiteTracker) Track(executionID string, req TrackRequest) {
 func (s *SQLiteTracker) Requests(executionID string) []store.AIRequest {
 	rows, err := s.db.Query(
 		`SELECT id, execution_id, provider, model, endpoint, request_json, response_json,
-		        status, error_message, input_tokens, output_tokens, duration_ms, created_at
-		 FROM ai_requests WHERE execution_id = ? ORDER BY created_at`,
+		        status, error_message, input_tokens, output_tokens, duration_ms, created_at, created_at_ns
+		 FROM ai_requests WHERE execution_id = ? ORDER BY created_at, rowid`,
 		executionID,
 	)
 	if err != nil {
@@ -179,9 +236,16 @@ func (s *SQLiteTracker) Requests(executionID string) []store.AIRequest {
 
 // RequestsPaginated returns paginated AI requests for the specified executionID
 func (s *SQLiteTracker) RequestsPaginated(executionID string, limit, offset int) ([]store.AIRequest, int64) {
+	return s.RequestsFiltered(executionID, RequestFilter{}, limit, offset)
+}
+
+// RequestsFiltered returns paginated AI requests for the specified executionID matching the filter
+func (s *SQLiteTracker) RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.AIRequest, int64) {
+	where, args := filter.where(executionID)
+
 	// Get total count
 	var total int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM ai_requests WHERE execution_id = ?", executionID).Scan(&total)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM ai_requests WHERE "+where, args...).Scan(&total)
 	if err != nil {
 		return []store.AIRequest{}, 0
 	}
@@ -189,9 +253,9 @@ func (s *SQLiteTracker) RequestsPaginated(executionID string, limit, offset int)
 	// Get paginated requests
 	rows, err := s.db.Query(
 		`SELECT id, execution_id, provider, model, endpoint, request_json, response_json,
-		        status, error_message, input_tokens, output_tokens, duration_ms, created_at
-		 FROM ai_requests WHERE execution_id = ? ORDER BY created_at LIMIT ? OFFSET ?`,
-		executionID, limit, offset,
+		        status, error_message, input_tokens, output_tokens, duration_ms, created_at, created_at_ns
+		 FROM ai_requests WHERE `+where+` ORDER BY created_at LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
 	)
 	if err != nil {
 		return []store.AIRequest{}, total
@@ -201,21 +265,64 @@ func (s *SQLiteTracker) RequestsPaginated(executionID string, limit, offset int)
 	return s.scanRequests(rows), total
 }
 
+// Usage sums requests and tokens by provider and model for the function's
+// executions since the given Unix timestamp
+func (s *SQLiteTracker) Usage(functionID string, since int64) ([]store.AIUsage, error) {
+	return s.queryUsage(
+		`SELECT r.provider, r.model, COUNT(*), COALESCE(SUM(r.input_tokens), 0), COALESCE(SUM(r.output_tokens), 0)
+		 FROM ai_requests r JOIN executions e ON e.id = r.execution_id
+		 WHERE e.function_id = ? AND r.created_at >= ?
+		 GROUP BY r.provider, r.model ORDER BY r.provider, r.model`,
+		functionID, since,
+	)
+}
+
+// TotalUsage sums requests and tokens by provider and model across all
+// functions since the given Unix timestamp
+func (s *SQLiteTracker) TotalUsage(since int64) ([]store.AIUsage, error) {
+	return s.queryUsage(
+		`SELECT provider, model, COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0)
+		 FROM ai_requests WHERE created_at >= ?
+		 GROUP BY provider, model ORDER BY provider, model`,
+		since,
+	)
+}
+
+// queryUsage runs an aggregate usage query and scans its rows
+func (s *SQLiteTracker) queryUsage(query string, args ...any) ([]store.AIUsage, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AI usage: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	usage := make([]store.AIUsage, 0)
+	for rows.Next() {
+		var u store.AIUsage
+		if err := rows.Scan(&u.Provider, &u.Model, &u.Requests, &u.InputTokens, &u.OutputTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan AI usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
 // scanRequests is a helper to scan rows into AIRequest slice
 func (s *SQLiteTracker) scanRequests(rows *sql.Rows) []store.AIRequest {
 	requests := make([]store.AIRequest, 0)
 	for rows.Next() {
 		var req store.AIRequest
 		var responseJSON, errorMessage sql.NullString
-		var inputTokens, outputTokens sql.NullInt64
+		var inputTokens, outputTokens, createdAtNs sql.NullInt64
 
 		if err := rows.Scan(
 			&req.ID, &req.ExecutionID, &req.Provider, &req.Model, &req.Endpoint,
 			&req.RequestJSON, &responseJSON, &req.Status, &errorMessage,
-			&inputTokens, &outputTokens, &req.DurationMs, &req.CreatedAt,
+			&inputTokens, &outputTokens, &req.DurationMs, &req.CreatedAt, &createdAtNs,
 		); err != nil {
 			continue
 		}
+		req.CreatedAtNs = createdAtNs.Int64
 
 		if responseJSON.Valid {
 			req.ResponseJSON = &responseJSON.String
@@ -170,6 +170,47 @@ func TestMemoryTracker_RequestsPaginated(t *testing.T) {
 	}
 }
 
+func TestMemoryTracker_RequestsFiltered(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.Track("exec-1", TrackRequest{Provider: "openai", Model: "gpt-4", Status: store.AIRequestStatusSuccess})
+	tracker.Track("exec-1", TrackRequest{Provider: "openai", Model: "gpt-4o", Status: store.AIRequestStatusError})
+	tracker.Track("exec-1", TrackRequest{Provider: "anthropic", Model: "claude", Status: store.AIRequestStatusSuccess})
+	tracker.Track("exec-2", TrackRequest{Provider: "openai", Model: "gpt-4", Status: store.AIRequestStatusSuccess})
+
+	tests := []struct {
+		name     string
+		filter   RequestFilter
+		expected int64
+	}{
+		{name: "no filter", filter: RequestFilter{}, expected: 3},
+		{name: "by provider", filter: RequestFilter{Provider: "openai"}, expected: 2},
+		{name: "by model", filter: RequestFilter{Model: "claude"}, expected: 1},
+		{name: "by status", filter: RequestFilter{Status: store.AIRequestStatusError}, expected: 1},
+		{name: "combined", filter: RequestFilter{Provider: "openai", Status: store.AIRequestStatusSuccess}, expected: 1},
+		{name: "no match", filter: RequestFilter{Provider: "other"}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, total := tracker.RequestsFiltered("exec-1", tt.filter, 20, 0)
+			if total != tt.expected || int64(len(requests)) != tt.expected {
+				t.Errorf("expected %d requests, got %d (total %d)", tt.expected, len(requests), total)
+			}
+		})
+	}
+}
+
+func TestRequestFilter_Where(t *testing.T) {
+	where, args := RequestFilter{Provider: "openai", Status: store.AIRequestStatusError}.where("exec-1")
+
+	if where != "execution_id = ? AND provider = ? AND status = ?" {
+		t.Errorf("unexpected where clause: %s", where)
+	}
+	if len(args) != 3 || args[0] != "exec-1" || args[1] != "openai" || args[2] != store.AIRequestStatusError {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
 func TestMemoryTracker_Clear(t *testing.T) {
 	tracker := NewMemoryTracker()
 
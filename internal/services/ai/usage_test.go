@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/migrate"
+	"github.com/dimiro1/lunar/internal/store"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLiteTracker_Usage(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	migrate.RunTest(t, db)
+
+	now := time.Now().Unix()
+	for _, stmt := range []string{
+		`INSERT INTO functions (id, name, created_at, updated_at) VALUES ('fn-1', 'one', 0, 0), ('fn-2', 'two', 0, 0)`,
+		`INSERT INTO function_versions (id, function_id, version, code, created_at, is_active) VALUES ('v-1', 'fn-1', 1, '', 0, 1), ('v-2', 'fn-2', 1, '', 0, 1)`,
+		`INSERT INTO executions (id, function_id, function_version_id, status, created_at) VALUES ('exec-1', 'fn-1', 'v-1', 'success', 0), ('exec-2', 'fn-2', 'v-2', 'success', 0)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to seed database: %v", err)
+		}
+	}
+
+	tracker := NewSQLiteTracker(db)
+	tokens := func(n int) *int { return &n }
+	tracker.Track("exec-1", TrackRequest{Provider: "openai", Model: "gpt-4o", Status: store.AIRequestStatusSuccess, InputTokens: tokens(100), OutputTokens: tokens(50)})
+	tracker.Track("exec-1", TrackRequest{Provider: "openai", Model: "gpt-4o", Status: store.AIRequestStatusSuccess, InputTokens: tokens(10), OutputTokens: tokens(5)})
+	tracker.Track("exec-1", TrackRequest{Provider: "openai", Model: "gpt-4o", Status: store.AIRequestStatusError})
+	tracker.Track("exec-1", TrackRequest{Provider: "anthropic", Model: "claude-3-5-haiku", Status: store.AIRequestStatusSuccess, InputTokens: tokens(7), OutputTokens: tokens(3)})
+	tracker.Track("exec-2", TrackRequest{Provider: "openai", Model: "gpt-4o", Status: store.AIRequestStatusSuccess, InputTokens: tokens(1000), OutputTokens: tokens(1000)})
+
+	usage, err := tracker.Usage("fn-1", now-60)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 usage rows, got %d: %+v", len(usage), usage)
+	}
+
+	if u := usage[0]; u.Provider != "anthropic" || u.Requests != 1 || u.InputTokens != 7 || u.OutputTokens != 3 {
+		t.Errorf("unexpected anthropic usage: %+v", u)
+	}
+	if u := usage[1]; u.Provider != "openai" || u.Requests != 3 || u.InputTokens != 110 || u.OutputTokens != 55 {
+		t.Errorf("unexpected openai usage: %+v", u)
+	}
+
+	usage, err = tracker.Usage("fn-1", now+60)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if len(usage) != 0 {
+		t.Errorf("expected no usage after the window, got %+v", usage)
+	}
+
+	usage, err = tracker.TotalUsage(now - 60)
+	if err != nil {
+		t.Fatalf("TotalUsage failed: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 usage rows, got %d: %+v", len(usage), usage)
+	}
+	if u := usage[1]; u.Provider != "openai" || u.Requests != 4 || u.InputTokens != 1110 || u.OutputTokens != 1055 {
+		t.Errorf("unexpected total openai usage: %+v", u)
+	}
+}
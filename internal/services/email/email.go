@@ -1,7 +1,13 @@
 package email
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/mail"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/dimiro1/lunar/internal/services/env"
 	"github.com/resend/resend-go/v3"
@@ -32,6 +38,54 @@ type SendRequest struct {
 	Headers     map[string]string
 	Tags        []Tag
 	ScheduledAt string
+
+	// IdempotencyKey makes Resend send the email once when the request is
+	// repeated with the same key, e.g. after a timeout. RetryingClient sets
+	// it when empty.
+	IdempotencyKey string
+}
+
+// Defaults are the function-level settings used by sends that leave them out
+type Defaults struct {
+	From string
+}
+
+// Apply fills the sender of req when the send left it empty
+func (d Defaults) Apply(req *SendRequest) {
+	if req.From == "" {
+		req.From = d.From
+	}
+}
+
+// SenderAllowlist restricts the addresses email can be sent from. Entries are
+// either full addresses ("noreply@example.com") or domains ("example.com"),
+// matched case-insensitively. An empty allowlist permits every sender.
+type SenderAllowlist []string
+
+// Allows reports whether from, optionally in "Name <address>" form, is
+// permitted by the allowlist
+func (a SenderAllowlist) Allows(from string) bool {
+	if len(a) == 0 {
+		return true
+	}
+
+	address := from
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		address = parsed.Address
+	}
+	address = strings.ToLower(address)
+	_, domain, ok := strings.Cut(address, "@")
+	if !ok {
+		return false
+	}
+
+	for _, entry := range a {
+		entry = strings.ToLower(strings.TrimPrefix(entry, "@"))
+		if entry == address || entry == domain {
+			return true
+		}
+	}
+	return false
 }
 
 // SendResponse represents the response from sending an email
@@ -65,8 +119,11 @@ func (c *DefaultClient) Send(functionID string, req SendRequest) (*SendResponse,
 		return nil, &ConfigError{Field: ResendAPIKeyEnv}
 	}
 
-	// Create Resend client
-	client := resend.NewClient(apiKey)
+	// Create Resend client, recording response statuses so that transient
+	// failures can be retried
+	recorder := &statusRecorder{base: http.DefaultTransport}
+	httpClient := &http.Client{Timeout: time.Minute, Transport: recorder}
+	client := resend.NewCustomClient(httpClient, apiKey)
 
 	// Allow custom base URL for testing (read from function env)
 	if baseURL, err := c.envStore.Get(functionID, ResendBaseURLEnv); err == nil && baseURL != "" {
@@ -116,9 +173,10 @@ func (c *DefaultClient) Send(functionID string, req SendRequest) (*SendResponse,
 	requestJSON := EmailParamsToJSON(req.From, req.To, req.Subject, req.Text, req.HTML, req.ReplyTo, req.Cc, req.Bcc, req.ScheduledAt, req.Headers, tagsForJSON)
 
 	// Send email
-	sent, err := client.Emails.Send(params)
+	options := &resend.SendEmailOptions{IdempotencyKey: req.IdempotencyKey}
+	sent, err := client.Emails.SendWithOptions(context.Background(), params, options)
 	if err != nil {
-		return &SendResponse{RequestJSON: requestJSON}, err
+		return &SendResponse{RequestJSON: requestJSON}, recorder.apiError(err)
 	}
 
 	return &SendResponse{
@@ -135,3 +193,26 @@ type ConfigError struct {
 func (e *ConfigError) Error() string {
 	return e.Field + " not set in function environment"
 }
+
+// FakeClient is a stub implementation of Client that records emails instead
+// of sending them
+type FakeClient struct {
+	// Err is returned for every send when set
+	Err error
+	// Requests stores all send requests made (for verification)
+	Requests []SendRequest
+}
+
+// NewFakeClient creates a new fake email client
+func NewFakeClient() *FakeClient {
+	return &FakeClient{Requests: make([]SendRequest, 0)}
+}
+
+// Send records the request and returns a fake email ID
+func (f *FakeClient) Send(_ string, req SendRequest) (*SendResponse, error) {
+	f.Requests = append(f.Requests, req)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return &SendResponse{ID: fmt.Sprintf("fake-%d", len(f.Requests))}, nil
+}
@@ -166,9 +166,44 @@ func (m *mockEnvStore) Delete(functionID, key string) error {
 	return nil
 }
 
+func (m *mockEnvStore) SetAll(functionID string, envVars map[string]string) error {
+	m.values[functionID] = envVars
+	return nil
+}
+
 func (m *mockEnvStore) All(functionID string) (map[string]string, error) {
 	if funcVars, ok := m.values[functionID]; ok {
 		return funcVars, nil
 	}
 	return map[string]string{}, nil
 }
+
+func TestSenderAllowlist_Allows(t *testing.T) {
+	allowlist := SenderAllowlist{"example.com", "@mail.example.org", "Billing@Acme.io"}
+
+	tests := []struct {
+		from    string
+		allowed bool
+	}{
+		{"noreply@example.com", true},
+		{"Lunar <noreply@EXAMPLE.com>", true},
+		{"alerts@mail.example.org", true},
+		{"billing@acme.io", true},
+		{"support@acme.io", false},
+		{"noreply@sub.example.com", false},
+		{"noreply@example.com.evil.io", false},
+		{"not-an-address", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			if got := allowlist.Allows(tt.from); got != tt.allowed {
+				t.Errorf("Allows(%q) = %v, want %v", tt.from, got, tt.allowed)
+			}
+		})
+	}
+
+	if !(SenderAllowlist{}).Allows("anyone@anywhere.io") {
+		t.Error("expected an empty allowlist to permit every sender")
+	}
+}
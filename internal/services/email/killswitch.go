@@ -0,0 +1,23 @@
+package email
+
+import "github.com/dimiro1/lunar/internal/killswitch"
+
+// SwitchedClient wraps a Client and fails every send while the email
+// integration is switched off, without contacting Resend.
+type SwitchedClient struct {
+	client Client
+	sw     *killswitch.Switch
+}
+
+// NewSwitchedClient creates a SwitchedClient guarding client with sw
+func NewSwitchedClient(client Client, sw *killswitch.Switch) *SwitchedClient {
+	return &SwitchedClient{client: client, sw: sw}
+}
+
+// Send sends the email unless the email integration is disabled
+func (c *SwitchedClient) Send(functionID string, req SendRequest) (*SendResponse, error) {
+	if err := c.sw.Check(killswitch.Email); err != nil {
+		return nil, err
+	}
+	return c.client.Send(functionID, req)
+}
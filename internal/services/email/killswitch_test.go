@@ -0,0 +1,32 @@
+package email
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/killswitch"
+)
+
+// countingClient records how many emails were sent
+type countingClient struct {
+	sent int
+}
+
+func (c *countingClient) Send(_ string, _ SendRequest) (*SendResponse, error) {
+	c.sent++
+	return &SendResponse{ID: "email_123"}, nil
+}
+
+func TestSwitchedClient(t *testing.T) {
+	inner := &countingClient{}
+	client := NewSwitchedClient(inner, killswitch.New(killswitch.Email))
+
+	_, err := client.Send("fn", SendRequest{})
+	var disabledErr *killswitch.DisabledError
+	if !errors.As(err, &disabledErr) {
+		t.Fatalf("expected DisabledError while disabled, got %v", err)
+	}
+	if inner.sent != 0 {
+		t.Errorf("expected no email to be sent, got %d", inner.sent)
+	}
+}
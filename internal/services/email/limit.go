@@ -0,0 +1,34 @@
+package email
+
+import (
+	"time"
+
+	"github.com/dimiro1/lunar/internal/outbound"
+)
+
+// LimitedClient wraps a Client and fails sends made after the execution
+// spent its outbound call or time budget, without contacting Resend. Every
+// retry attempt counts as a call.
+type LimitedClient struct {
+	client Client
+	budget *outbound.Budget
+}
+
+// NewLimitedClient creates a LimitedClient counting sends against budget
+func NewLimitedClient(client Client, budget *outbound.Budget) *LimitedClient {
+	return &LimitedClient{client: client, budget: budget}
+}
+
+// Send sends the email unless the budget is spent
+func (c *LimitedClient) Send(functionID string, req SendRequest) (*SendResponse, error) {
+	if err := c.budget.Acquire("email"); err != nil {
+		return nil, err
+	}
+	defer c.record(time.Now())
+	return c.client.Send(functionID, req)
+}
+
+// record adds the time since start to the budget
+func (c *LimitedClient) record(start time.Time) {
+	c.budget.Record(time.Since(start))
+}
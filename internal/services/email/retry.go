@@ -0,0 +1,159 @@
+package email
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry defaults
+const (
+	DefaultMaxRetries = 2
+	MaxRetriesLimit   = 10
+	defaultBaseDelay  = 500 * time.Millisecond
+	defaultMaxDelay   = 10 * time.Second
+)
+
+// APIError is returned when the provider answers with an error status code
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // Delay requested by the Retry-After header, if any
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the send may succeed when attempted again.
+// Rate limits, timeouts and server errors are retryable; other client errors,
+// such as a rejected address, are not.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= 500
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// RetryPolicy controls how transient provider errors are retried.
+// The zero value disables retries.
+type RetryPolicy struct {
+	MaxRetries int           // Retries after the first attempt
+	BaseDelay  time.Duration // Delay before the first retry, doubled for each further retry (default 500ms)
+	MaxDelay   time.Duration // Upper bound for the backoff delay (default 10s)
+}
+
+// DefaultRetryPolicy returns the policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxRetries: DefaultMaxRetries}
+}
+
+// backoff returns the delay before the given retry (starting at 1).
+// A Retry-After value from the provider takes precedence.
+func (p RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	maxDelay := cmp.Or(p.MaxDelay, defaultMaxDelay)
+	delay := cmp.Or(p.BaseDelay, defaultBaseDelay)
+	for i := 1; i < retry && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// RetryingClient wraps an email.Client and retries sends that fail with a
+// retryable APIError, using exponential backoff. Retries stop when the
+// context is done or the next attempt would start after its deadline.
+type RetryingClient struct {
+	ctx    context.Context
+	client Client
+	policy RetryPolicy
+}
+
+// NewRetryingClient creates a RetryingClient bound to the given context
+func NewRetryingClient(ctx context.Context, client Client, policy RetryPolicy) *RetryingClient {
+	return &RetryingClient{ctx: ctx, client: client, policy: policy}
+}
+
+// Send sends the email, retrying transient failures. Every attempt carries the
+// same idempotency key, so a retry after a send that went through but failed
+// to report back does not deliver the email twice.
+func (c *RetryingClient) Send(functionID string, req SendRequest) (*SendResponse, error) {
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = rand.Text()
+	}
+
+	for retry := 1; ; retry++ {
+		resp, err := c.client.Send(functionID, req)
+
+		var apiErr *APIError
+		if err == nil || retry > c.policy.MaxRetries || !errors.As(err, &apiErr) || !apiErr.Retryable() {
+			return resp, err
+		}
+
+		delay := c.policy.backoff(retry, apiErr.RetryAfter)
+		if deadline, ok := c.ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+// statusRecorder is an http.RoundTripper that remembers the status code and
+// Retry-After header of the last response, which the Resend client does not
+// expose in its errors
+type statusRecorder struct {
+	base       http.RoundTripper
+	statusCode int
+	retryAfter string
+}
+
+func (r *statusRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err == nil {
+		r.statusCode = resp.StatusCode
+		r.retryAfter = resp.Header.Get("Retry-After")
+	}
+	return resp, err
+}
+
+// apiError wraps err in an APIError when the last response was an error status
+func (r *statusRecorder) apiError(err error) error {
+	if r.statusCode < 400 {
+		return err
+	}
+	return &APIError{
+		StatusCode: r.statusCode,
+		RetryAfter: parseRetryAfter(r.retryAfter, time.Now()),
+		Err:        err,
+	}
+}
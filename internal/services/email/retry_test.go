@@ -0,0 +1,198 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// scriptedClient returns the scripted errors in order, then succeeds
+type scriptedClient struct {
+	errs  []error
+	calls int
+	keys  []string // Idempotency key of each call
+}
+
+func (c *scriptedClient) Send(_ string, req SendRequest) (*SendResponse, error) {
+	c.calls++
+	c.keys = append(c.keys, req.IdempotencyKey)
+	if c.calls <= len(c.errs) {
+		return nil, c.errs[c.calls-1]
+	}
+	return &SendResponse{ID: "ok"}, nil
+}
+
+func apiError(status int) error {
+	return &APIError{StatusCode: status, Err: errors.New(http.StatusText(status))}
+}
+
+func TestRetryingClient(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		policy    RetryPolicy
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "success", wantCalls: 1, policy: policy},
+		{name: "rate limit then success", errs: []error{apiError(429)}, policy: policy, wantCalls: 2},
+		{name: "server errors then success", errs: []error{apiError(500), apiError(503)}, policy: policy, wantCalls: 3},
+		{name: "gives up after max retries", errs: []error{apiError(502), apiError(502), apiError(502)}, policy: policy, wantCalls: 3, wantErr: true},
+		{name: "invalid address is not retried", errs: []error{apiError(422)}, policy: policy, wantCalls: 1, wantErr: true},
+		{name: "unauthorized is not retried", errs: []error{apiError(401)}, policy: policy, wantCalls: 1, wantErr: true},
+		{name: "config errors are not retried", errs: []error{&ConfigError{Field: ResendAPIKeyEnv}}, policy: policy, wantCalls: 1, wantErr: true},
+		{name: "zero policy disables retries", errs: []error{apiError(429)}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &scriptedClient{errs: tt.errs}
+			_, err := NewRetryingClient(context.Background(), inner, tt.policy).Send("fn", SendRequest{})
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if inner.calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, inner.calls)
+			}
+		})
+	}
+}
+
+func TestRetryingClient_IdempotencyKey(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+
+	inner := &scriptedClient{errs: []error{apiError(500), apiError(503)}}
+	if _, err := NewRetryingClient(context.Background(), inner, policy).Send("fn", SendRequest{}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(inner.keys) != 3 || inner.keys[0] == "" || inner.keys[1] != inner.keys[0] || inner.keys[2] != inner.keys[0] {
+		t.Errorf("expected one key for every attempt, got %q", inner.keys)
+	}
+
+	// Another send gets another key, and a key set by the caller is kept
+	second := &scriptedClient{}
+	_, _ = NewRetryingClient(context.Background(), second, policy).Send("fn", SendRequest{})
+	if second.keys[0] == inner.keys[0] {
+		t.Errorf("expected a new key for another send, got %q twice", inner.keys[0])
+	}
+	third := &scriptedClient{}
+	_, _ = NewRetryingClient(context.Background(), third, policy).Send("fn", SendRequest{IdempotencyKey: "order-42"})
+	if third.keys[0] != "order-42" {
+		t.Errorf("expected the caller's key, got %q", third.keys[0])
+	}
+}
+
+func TestRetryingClient_StopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	inner := &scriptedClient{errs: []error{
+		&APIError{StatusCode: 429, RetryAfter: time.Minute, Err: errors.New("rate limited")},
+	}}
+
+	start := time.Now()
+	_, err := NewRetryingClient(ctx, inner, RetryPolicy{MaxRetries: 3}).Send("fn", SendRequest{})
+	if err == nil {
+		t.Fatal("expected the rate limit error")
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected no retry past the deadline, got %d calls", inner.calls)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("expected to fail fast, took %v", elapsed)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+	} {
+		if got := policy.backoff(retry, 0); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", retry, got, want)
+		}
+	}
+
+	if got := policy.backoff(1, 3*time.Second); got != 3*time.Second {
+		t.Errorf("expected Retry-After to take precedence, got %v", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.March, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 17 Mar 2025 12:00:30 GMT": 30 * time.Second,
+		"Mon, 17 Mar 2025 11:00:00 GMT": 0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestDefaultClient_Send_APIError(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		retryAfter     string
+		wantRetryable  bool
+		wantRetryAfter time.Duration
+	}{
+		{name: "rate limited", status: 429, retryAfter: "7", wantRetryable: true, wantRetryAfter: 7 * time.Second},
+		{name: "server error", status: 503, wantRetryable: true},
+		{name: "invalid address", status: 422},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Idempotency-Key"); got != "key-1" {
+					t.Errorf("expected Idempotency-Key key-1, got %q", got)
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"message":"failed"}`))
+			}))
+			defer server.Close()
+
+			client := NewDefaultClient(&mockEnvStore{values: map[string]map[string]string{
+				"func-1": {ResendAPIKeyEnv: "test-key", ResendBaseURLEnv: server.URL},
+			}})
+			_, err := client.Send("func-1", SendRequest{
+				From:           "sender@example.com",
+				To:             []string{"recipient@example.com"},
+				Subject:        "Test",
+				Text:           "Hello",
+				IdempotencyKey: "key-1",
+			})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an APIError, got %T: %v", err, err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Retryable() != tt.wantRetryable || apiErr.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("expected status %d, retryable %v and Retry-After %v, got %d, %v and %v",
+					tt.status, tt.wantRetryable, tt.wantRetryAfter, apiErr.StatusCode, apiErr.Retryable(), apiErr.RetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package email
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// ErrTemplateNotFound is returned when a function has no template with the given name
+var ErrTemplateNotFound = errors.New("email template not found")
+
+// Template is a reusable email stored per function.
+// Subject and Text are rendered with text/template, HTML with html/template.
+type Template struct {
+	Name      string `json:"name"`
+	Subject   string `json:"subject,omitempty"`
+	HTML      string `json:"html,omitempty"`
+	Text      string `json:"text,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Rendered holds the output of rendering a Template
+type Rendered struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// executor is implemented by both text/template and html/template templates
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// parse compiles the template parts, leaving empty parts nil
+func (t Template) parse() (subject, html, text executor, err error) {
+	if t.Subject != "" {
+		if subject, err = texttemplate.New("subject").Parse(t.Subject); err != nil {
+			return nil, nil, nil, fmt.Errorf("subject: %w", err)
+		}
+	}
+	if t.HTML != "" {
+		if html, err = htmltemplate.New("html").Parse(t.HTML); err != nil {
+			return nil, nil, nil, fmt.Errorf("html: %w", err)
+		}
+	}
+	if t.Text != "" {
+		if text, err = texttemplate.New("text").Parse(t.Text); err != nil {
+			return nil, nil, nil, fmt.Errorf("text: %w", err)
+		}
+	}
+	return subject, html, text, nil
+}
+
+// Parse checks that every part of the template is valid template syntax
+func (t Template) Parse() error {
+	_, _, _, err := t.parse()
+	return err
+}
+
+// Render executes the template parts with data
+func (t Template) Render(data any) (Rendered, error) {
+	subject, html, text, err := t.parse()
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	var rendered Rendered
+	if rendered.Subject, err = execute(subject, data); err != nil {
+		return Rendered{}, fmt.Errorf("subject: %w", err)
+	}
+	if rendered.HTML, err = execute(html, data); err != nil {
+		return Rendered{}, fmt.Errorf("html: %w", err)
+	}
+	if rendered.Text, err = execute(text, data); err != nil {
+		return Rendered{}, fmt.Errorf("text: %w", err)
+	}
+	return rendered, nil
+}
+
+// execute renders tmpl with data, returning "" for a nil template
+func execute(tmpl executor, data any) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// TemplateStore is an interface for email template storage operations
+// functionID is used to isolate templates between functions
+type TemplateStore interface {
+	Get(functionID, name string) (Template, error)
+	Set(functionID string, tmpl Template) (Template, error)
+	Delete(functionID, name string) error
+	All(functionID string) ([]Template, error)
+}
+
+// MemoryTemplateStore is an in-memory implementation of TemplateStore
+type MemoryTemplateStore struct {
+	mu   sync.RWMutex
+	data map[string]map[string]Template // functionID -> name -> template
+}
+
+// NewMemoryTemplateStore creates a new in-memory template store
+func NewMemoryTemplateStore() *MemoryTemplateStore {
+	return &MemoryTemplateStore{
+		data: make(map[string]map[string]Template),
+	}
+}
+
+// Get retrieves a template by functionID and name
+func (m *MemoryTemplateStore) Get(functionID, name string) (Template, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tmpl, exists := m.data[functionID][name]
+	if !exists {
+		return Template{}, ErrTemplateNotFound
+	}
+	return tmpl, nil
+}
+
+// Set creates or replaces a template, keeping the original creation time
+func (m *MemoryTemplateStore) Set(functionID string, tmpl Template) (Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.data[functionID]; !exists {
+		m.data[functionID] = make(map[string]Template)
+	}
+
+	now := time.Now().Unix()
+	tmpl.CreatedAt = now
+	if existing, exists := m.data[functionID][tmpl.Name]; exists {
+		tmpl.CreatedAt = existing.CreatedAt
+	}
+	tmpl.UpdatedAt = now
+	m.data[functionID][tmpl.Name] = tmpl
+	return tmpl, nil
+}
+
+// Delete removes a template, returning ErrTemplateNotFound if it does not exist
+func (m *MemoryTemplateStore) Delete(functionID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.data[functionID][name]; !exists {
+		return ErrTemplateNotFound
+	}
+	delete(m.data[functionID], name)
+	return nil
+}
+
+// All returns the templates of a function sorted by name
+func (m *MemoryTemplateStore) All(functionID string) ([]Template, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := slices.Collect(maps.Values(m.data[functionID]))
+	slices.SortFunc(result, func(a, b Template) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, nil
+}
+
+// SQLiteTemplateStore is a SQLite-backed implementation of TemplateStore
+type SQLiteTemplateStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteTemplateStore creates a new SQLite-backed template store
+func NewSQLiteTemplateStore(db *sql.DB) *SQLiteTemplateStore {
+	return &SQLiteTemplateStore{db: db}
+}
+
+// Get retrieves a template by functionID and name
+func (s *SQLiteTemplateStore) Get(functionID, name string) (Template, error) {
+	var tmpl Template
+	err := s.db.QueryRow(
+		"SELECT name, subject, html, text, created_at, updated_at FROM email_templates WHERE function_id = ? AND name = ?",
+		functionID, name,
+	).Scan(&tmpl.Name, &tmpl.Subject, &tmpl.HTML, &tmpl.Text, &tmpl.CreatedAt, &tmpl.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Template{}, ErrTemplateNotFound
+	}
+	if err != nil {
+		return Template{}, fmt.Errorf("failed to get email template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Set creates or replaces a template, keeping the original creation time
+func (s *SQLiteTemplateStore) Set(functionID string, tmpl Template) (Template, error) {
+	now := time.Now().Unix()
+	err := s.db.QueryRow(`
+		INSERT INTO email_templates (function_id, name, subject, html, text, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (function_id, name) DO UPDATE SET
+			subject = excluded.subject,
+			html = excluded.html,
+			text = excluded.text,
+			updated_at = excluded.updated_at
+		RETURNING created_at, updated_at`,
+		functionID, tmpl.Name, tmpl.Subject, tmpl.HTML, tmpl.Text, now, now,
+	).Scan(&tmpl.CreatedAt, &tmpl.UpdatedAt)
+	if err != nil {
+		return Template{}, fmt.Errorf("failed to set email template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Delete removes a template, returning ErrTemplateNotFound if it does not exist
+func (s *SQLiteTemplateStore) Delete(functionID, name string) error {
+	result, err := s.db.Exec(
+		"DELETE FROM email_templates WHERE function_id = ? AND name = ?",
+		functionID, name,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete email template: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// All returns the templates of a function sorted by name
+func (s *SQLiteTemplateStore) All(functionID string) ([]Template, error) {
+	rows, err := s.db.Query(
+		"SELECT name, subject, html, text, created_at, updated_at FROM email_templates WHERE function_id = ? ORDER BY name",
+		functionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email templates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := make([]Template, 0)
+	for rows.Next() {
+		var tmpl Template
+		if err := rows.Scan(&tmpl.Name, &tmpl.Subject, &tmpl.HTML, &tmpl.Text, &tmpl.CreatedAt, &tmpl.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan email template: %w", err)
+		}
+		result = append(result, tmpl)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,129 @@
+package email
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/migrate"
+	_ "modernc.org/sqlite"
+)
+
+func setupTemplateTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "templates.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrate.RunTest(t, db)
+	return db
+}
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl := Template{
+		Name:    "welcome",
+		Subject: "Welcome, {{.name}}!",
+		HTML:    "<p>Hello {{.name}}</p>",
+		Text:    "Hello {{.name}}",
+	}
+
+	rendered, err := tmpl.Render(map[string]any{"name": "<Ana>"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.Subject != "Welcome, <Ana>!" {
+		t.Errorf("unexpected subject: %q", rendered.Subject)
+	}
+	if rendered.HTML != "<p>Hello &lt;Ana&gt;</p>" {
+		t.Errorf("expected escaped html, got %q", rendered.HTML)
+	}
+	if rendered.Text != "Hello <Ana>" {
+		t.Errorf("unexpected text: %q", rendered.Text)
+	}
+}
+
+func TestTemplate_RenderEmptyParts(t *testing.T) {
+	rendered, err := Template{Name: "plain", Text: "Hi"}.Render(nil)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.Subject != "" || rendered.HTML != "" || rendered.Text != "Hi" {
+		t.Errorf("unexpected rendered template: %+v", rendered)
+	}
+}
+
+func TestTemplate_Parse(t *testing.T) {
+	if err := (Template{Subject: "{{.name}}", HTML: "<b>{{.name}}</b>"}).Parse(); err != nil {
+		t.Errorf("expected valid template, got %v", err)
+	}
+	if err := (Template{HTML: "{{if .name}}"}).Parse(); err == nil {
+		t.Error("expected error for unclosed action")
+	}
+}
+
+func testTemplateStore(t *testing.T, store TemplateStore) {
+	t.Helper()
+
+	if _, err := store.Get("fn-1", "welcome"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("expected ErrTemplateNotFound, got %v", err)
+	}
+
+	created, err := store.Set("fn-1", Template{Name: "welcome", Subject: "Hi", Text: "v1"})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if created.CreatedAt == 0 || created.UpdatedAt == 0 {
+		t.Errorf("expected timestamps to be set, got %+v", created)
+	}
+
+	updated, err := store.Set("fn-1", Template{Name: "welcome", Subject: "Hi", Text: "v2"})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if updated.CreatedAt != created.CreatedAt {
+		t.Errorf("expected created_at to be kept, got %d want %d", updated.CreatedAt, created.CreatedAt)
+	}
+
+	got, err := store.Get("fn-1", "welcome")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Text != "v2" {
+		t.Errorf("expected updated text, got %q", got.Text)
+	}
+
+	if _, err := store.Set("fn-1", Template{Name: "alert", HTML: "<p>!</p>"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := store.Set("fn-2", Template{Name: "other", Text: "x"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	all, err := store.All("fn-1")
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "alert" || all[1].Name != "welcome" {
+		t.Errorf("expected templates sorted by name, got %+v", all)
+	}
+
+	if err := store.Delete("fn-1", "welcome"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete("fn-1", "welcome"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound deleting twice, got %v", err)
+	}
+	if _, err := store.Get("fn-2", "other"); err != nil {
+		t.Errorf("expected other function's template to remain, got %v", err)
+	}
+}
+
+func TestMemoryTemplateStore(t *testing.T) {
+	testTemplateStore(t, NewMemoryTemplateStore())
+}
+
+func TestSQLiteTemplateStore(t *testing.T) {
+	testTemplateStore(t, NewSQLiteTemplateStore(setupTemplateTestDB(t)))
+}
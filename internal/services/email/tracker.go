@@ -34,6 +34,29 @@ type Tracker interface {
 	Track(executionID string, req TrackRequest)
 	Requests(executionID string) []store.EmailRequest
 	RequestsPaginated(executionID string, limit, offset int) ([]store.EmailRequest, int64)
+	RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.EmailRequest, int64)
+}
+
+// RequestFilter narrows the email requests returned by RequestsFiltered.
+// Empty fields match any value.
+type RequestFilter struct {
+	Status store.EmailRequestStatus
+}
+
+// matches reports whether the request satisfies every non-empty filter field
+func (f RequestFilter) matches(req store.EmailRequest) bool {
+	return f.Status == "" || req.Status == f.Status
+}
+
+// where builds the SQL conditions and arguments for the filter
+func (f RequestFilter) where(executionID string) (string, []any) {
+	conditions := []string{"execution_id = ?"}
+	args := []any{executionID}
+	if f.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, f.Status)
+	}
+	return strings.Join(conditions, " AND "), args
 }
 
 // MemoryTracker is an in-memory implementation of Tracker
@@ -62,6 +85,7 @@ func (m *MemoryTracker) Track(executionID string, req TrackRequest) {
 		maskedResponseJSON = &masked
 	}
 
+	now := time.Now()
 	emailReq := store.EmailRequest{
 		ID:           xid.New().String(),
 		ExecutionID:  executionID,
@@ -76,7 +100,8 @@ func (m *MemoryTracker) Track(executionID string, req TrackRequest) {
 		ErrorMessage: req.ErrorMessage,
 		EmailID:      req.EmailID,
 		DurationMs:   req.DurationMs,
-		CreatedAt:    time.Now().Unix(),
+		CreatedAt:    now.Unix(),
+		CreatedAtNs:  now.UnixNano(),
 	}
 
 	m.requests = append(m.requests, emailReq)
@@ -98,13 +123,18 @@ func (m *MemoryTracker) Requests(executionID string) []store.EmailRequest {
 
 // RequestsPaginated returns paginated email requests for the specified executionID
 func (m *MemoryTracker) RequestsPaginated(executionID string, limit, offset int) ([]store.EmailRequest, int64) {
+	return m.RequestsFiltered(executionID, RequestFilter{}, limit, offset)
+}
+
+// RequestsFiltered returns paginated email requests for the specified executionID matching the filter
+func (m *MemoryTracker) RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.EmailRequest, int64) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// Filter requests by executionID
+	// Filter requests by executionID and the filter fields
 	filtered := make([]store.EmailRequest, 0)
 	for _, req := range m.requests {
-		if req.ExecutionID == executionID {
+		if req.ExecutionID == executionID && filter.matches(req) {
 			filtered = append(filtered, req)
 		}
 	}
@@ -161,14 +191,15 @@ func (s *SQLiteTracker) Track(executionID string, req TrackRequest) {
 		hasHTML = 1
 	}
 
+	now := time.Now()
 	_, err := s.db.Exec(
 		`INSERT INTO email_requests
 		(id, execution_id, from_address, to_addresses, subject, has_text, has_html,
-		 request_json, response_json, status, error_message, email_id, duration_ms, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		 request_json, response_json, status, error_message, email_id, duration_ms, created_at, created_at_ns)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id, executionID, req.From, toAddresses, req.Subject, hasText, hasHTML,
 		maskedRequestJSON, maskedResponseJSON, req.Status, req.ErrorMessage,
-		req.EmailID, req.DurationMs, time.Now().Unix(),
+		req.EmailID, req.DurationMs, now.Unix(), now.UnixNano(),
 	)
 	if err != nil {
 		// Log error but don't fail the execution
@@ -180,8 +211,8 @@ func (s *SQLiteTracker) Track(executionID string, req TrackRequest) {
 func (s *SQLiteTracker) Requests(executionID string) []store.EmailRequest {
 	rows, err := s.db.Query(
 		`SELECT id, execution_id, from_address, to_addresses, subject, has_text, has_html,
-		        request_json, response_json, status, error_message, email_id, duration_ms, created_at
-		 FROM email_requests WHERE execution_id = ? ORDER BY created_at`,
+		        request_json, response_json, status, error_message, email_id, duration_ms, created_at, created_at_ns
+		 FROM email_requests WHERE execution_id = ? ORDER BY created_at, rowid`,
 		executionID,
 	)
 	if err != nil {
@@ -194,9 +225,16 @@ func (s *SQLiteTracker) Requests(executionID string) []store.EmailRequest {
 
 // RequestsPaginated returns paginated email requests for the specified executionID
 func (s *SQLiteTracker) RequestsPaginated(executionID string, limit, offset int) ([]store.EmailRequest, int64) {
+	return s.RequestsFiltered(executionID, RequestFilter{}, limit, offset)
+}
+
+// RequestsFiltered returns paginated email requests for the specified executionID matching the filter
+func (s *SQLiteTracker) RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.EmailRequest, int64) {
+	where, args := filter.where(executionID)
+
 	// Get total count
 	var total int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM email_requests WHERE execution_id = ?", executionID).Scan(&total)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM email_requests WHERE "+where, args...).Scan(&total)
 	if err != nil {
 		return []store.EmailRequest{}, 0
 	}
@@ -204,9 +242,9 @@ func (s *SQLiteTracker) RequestsPaginated(executionID string, limit, offset int)
 	// Get paginated requests
 	rows, err := s.db.Query(
 		`SELECT id, execution_id, from_address, to_addresses, subject, has_text, has_html,
-		        request_json, response_json, status, error_message, email_id, duration_ms, created_at
-		 FROM email_requests WHERE execution_id = ? ORDER BY created_at LIMIT ? OFFSET ?`,
-		executionID, limit, offset,
+		        request_json, response_json, status, error_message, email_id, duration_ms, created_at, created_at_ns
+		 FROM email_requests WHERE `+where+` ORDER BY created_at LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
 	)
 	if err != nil {
 		return []store.EmailRequest{}, total
@@ -224,14 +262,16 @@ func (s *SQLiteTracker) scanRequests(rows *sql.Rows) []store.EmailRequest {
 		var toAddresses string
 		var responseJSON, errorMessage, emailID sql.NullString
 		var hasText, hasHTML int
+		var createdAtNs sql.NullInt64
 
 		if err := rows.Scan(
 			&req.ID, &req.ExecutionID, &req.From, &toAddresses, &req.Subject,
 			&hasText, &hasHTML, &req.RequestJSON, &responseJSON, &req.Status,
-			&errorMessage, &emailID, &req.DurationMs, &req.CreatedAt,
+			&errorMessage, &emailID, &req.DurationMs, &req.CreatedAt, &createdAtNs,
 		); err != nil {
 			continue
 		}
+		req.CreatedAtNs = createdAtNs.Int64
 
 		req.To = strings.Split(toAddresses, ",")
 		req.HasText = hasText == 1
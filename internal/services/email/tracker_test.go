@@ -210,6 +210,26 @@ func TestMemoryTracker_RequestsPaginated(t *testing.T) {
 	}
 }
 
+func TestMemoryTracker_RequestsFiltered(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.Track("exec-1", TrackRequest{From: "a@example.com", To: []string{"b@example.com"}, Status: store.EmailRequestStatusSuccess})
+	tracker.Track("exec-1", TrackRequest{From: "a@example.com", To: []string{"c@example.com"}, Status: store.EmailRequestStatusError})
+	tracker.Track("exec-2", TrackRequest{From: "a@example.com", To: []string{"d@example.com"}, Status: store.EmailRequestStatusError})
+
+	requests, total := tracker.RequestsFiltered("exec-1", RequestFilter{Status: store.EmailRequestStatusError}, 20, 0)
+	if total != 1 || len(requests) != 1 {
+		t.Fatalf("expected 1 failed request, got %d (total %d)", len(requests), total)
+	}
+	if requests[0].To[0] != "c@example.com" {
+		t.Errorf("unexpected request: %+v", requests[0])
+	}
+
+	_, total = tracker.RequestsFiltered("exec-1", RequestFilter{}, 20, 0)
+	if total != 2 {
+		t.Errorf("expected 2 requests without filter, got %d", total)
+	}
+}
+
 func TestMemoryTracker_Clear(t *testing.T) {
 	tracker := NewMemoryTracker()
 
@@ -25,6 +25,10 @@ type Store interface {
 	Set(functionID, key, value string) error
 	Delete(functionID, key string) error
 	All(functionID string) (map[string]string, error)
+	// SetAll makes envVars the only env vars of functionID, atomically.
+	// Env vars are plain key-value pairs with no per-key metadata, such as a
+	// secret flag, so there is nothing for SetAll to carry over between sets.
+	SetAll(functionID string, envVars map[string]string) error
 }
 
 // MemoryStore is an in-memory implementation of Store
@@ -83,6 +87,14 @@ func (m *MemoryStore) All(functionID string) (map[string]string, error) {
 	return result, nil
 }
 
+// SetAll replaces all environment variables for a functionID
+func (m *MemoryStore) SetAll(functionID string, envVars map[string]string) error {
+	ns := make(map[string]string, len(envVars))
+	maps.Copy(ns, envVars)
+	m.data[functionID] = ns
+	return nil
+}
+
 // SQLiteStore is a SQLite-backed implementation of Store
 type SQLiteStore struct {
 	db *sql.DB
@@ -157,3 +169,181 @@ func (s *SQLiteStore) All(functionID string) (map[string]string, error) {
 
 	return result, nil
 }
+
+// SetAll replaces all environment variables for a functionID in a single
+// transaction, so a failure leaves the previous variables in place
+func (s *SQLiteStore) SetAll(functionID string, envVars map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query("SELECT key FROM env_vars WHERE function_id = ?", functionID)
+	if err != nil {
+		return fmt.Errorf("failed to query env vars: %w", err)
+	}
+	var removed []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan env var: %w", err)
+		}
+		if _, ok := envVars[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query env vars: %w", err)
+	}
+
+	for _, key := range removed {
+		if _, err := tx.Exec("DELETE FROM env_vars WHERE function_id = ? AND key = ?", functionID, key); err != nil {
+			return fmt.Errorf("failed to delete value: %w", err)
+		}
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO env_vars (function_id, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (function_id, key) DO UPDATE SET value = excluded.value`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for key, value := range envVars {
+		if _, err := stmt.Exec(functionID, key, value); err != nil {
+			return fmt.Errorf("failed to set value: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// OverrideStore layers per-execution overrides for one function on top of a
+// base Store. Overridden keys live only in memory: reads return the override
+// and writes to them never reach the base store, so overrides never persist.
+// Every other key, and every other function, passes through to the base.
+type OverrideStore struct {
+	base       Store
+	functionID string
+	overrides  map[string]string
+	deleted    map[string]bool
+}
+
+// NewOverrideStore creates a store that overrides the given keys for functionID
+func NewOverrideStore(base Store, functionID string, overrides map[string]string) *OverrideStore {
+	values := make(map[string]string, len(overrides))
+	maps.Copy(values, overrides)
+	return &OverrideStore{
+		base:       base,
+		functionID: functionID,
+		overrides:  values,
+		deleted:    make(map[string]bool),
+	}
+}
+
+// overridden reports whether key is handled by the override layer
+func (o *OverrideStore) overridden(functionID, key string) bool {
+	if functionID != o.functionID {
+		return false
+	}
+	_, exists := o.overrides[key]
+	return exists || o.deleted[key]
+}
+
+// Get returns the override for key, falling back to the base store
+func (o *OverrideStore) Get(functionID, key string) (string, error) {
+	if !o.overridden(functionID, key) {
+		return o.base.Get(functionID, key)
+	}
+	value, exists := o.overrides[key]
+	if !exists {
+		return "", &Error{Message: fmt.Sprintf("key not found: %s", key)}
+	}
+	return value, nil
+}
+
+// Set updates the override for an overridden key, or writes to the base store
+func (o *OverrideStore) Set(functionID, key, value string) error {
+	if !o.overridden(functionID, key) {
+		return o.base.Set(functionID, key, value)
+	}
+	o.overrides[key] = value
+	delete(o.deleted, key)
+	return nil
+}
+
+// Delete removes the override for an overridden key, or deletes from the base store
+func (o *OverrideStore) Delete(functionID, key string) error {
+	if !o.overridden(functionID, key) {
+		return o.base.Delete(functionID, key)
+	}
+	delete(o.overrides, key)
+	o.deleted[key] = true
+	return nil
+}
+
+// All returns the base variables with the overrides applied
+func (o *OverrideStore) All(functionID string) (map[string]string, error) {
+	result, err := o.base.All(functionID)
+	if err != nil || functionID != o.functionID {
+		return result, err
+	}
+	for key := range o.deleted {
+		delete(result, key)
+	}
+	maps.Copy(result, o.overrides)
+	return result, nil
+}
+
+// SetAll replaces the variables of functionID. Overridden keys keep their
+// base value, taking the new value (or deletion) in the override layer only.
+func (o *OverrideStore) SetAll(functionID string, envVars map[string]string) error {
+	if functionID != o.functionID {
+		return o.base.SetAll(functionID, envVars)
+	}
+
+	current, err := o.base.All(functionID)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if !o.overridden(functionID, key) {
+			values[key] = value
+		}
+	}
+	for key, value := range current {
+		if o.overridden(functionID, key) {
+			values[key] = value
+		}
+	}
+	if err := o.base.SetAll(functionID, values); err != nil {
+		return err
+	}
+
+	for key := range o.keys() {
+		if value, ok := envVars[key]; ok {
+			o.overrides[key] = value
+			delete(o.deleted, key)
+		} else {
+			delete(o.overrides, key)
+			o.deleted[key] = true
+		}
+	}
+	return nil
+}
+
+// keys returns every key handled by the override layer
+func (o *OverrideStore) keys() map[string]bool {
+	keys := make(map[string]bool, len(o.overrides)+len(o.deleted))
+	for key := range o.overrides {
+		keys[key] = true
+	}
+	maps.Copy(keys, o.deleted)
+	return keys
+}
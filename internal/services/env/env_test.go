@@ -226,6 +226,53 @@ func TestSQLiteStore_AllEmpty(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_SetAll(t *testing.T) {
+	db := setupTestDB(t)
+	store := NewSQLiteStore(db)
+
+	_ = store.Set("func-123", "KEEP", "old")
+	_ = store.Set("func-123", "REMOVE", "gone")
+	_ = store.Set("func-456", "OTHER", "untouched")
+
+	if err := store.SetAll("func-123", map[string]string{"KEEP": "new", "ADD": "added"}); err != nil {
+		t.Fatalf("SetAll failed: %v", err)
+	}
+
+	all, err := store.All("func-123")
+	if err != nil {
+		t.Fatalf("Failed to get all vars: %v", err)
+	}
+	if len(all) != 2 || all["KEEP"] != "new" || all["ADD"] != "added" {
+		t.Errorf("Unexpected values after SetAll: %v", all)
+	}
+	if value, _ := store.Get("func-456", "OTHER"); value != "untouched" {
+		t.Errorf("Expected other functions to be untouched, got '%s'", value)
+	}
+
+	if err := store.SetAll("func-123", nil); err != nil {
+		t.Fatalf("SetAll failed: %v", err)
+	}
+	if all, _ := store.All("func-123"); len(all) != 0 {
+		t.Errorf("Expected SetAll with no vars to clear them, got %v", all)
+	}
+}
+
+func TestMemoryStore_SetAll(t *testing.T) {
+	store := NewMemoryStore()
+	_ = store.Set("func-123", "REMOVE", "gone")
+
+	vars := map[string]string{"ADD": "added"}
+	if err := store.SetAll("func-123", vars); err != nil {
+		t.Fatalf("SetAll failed: %v", err)
+	}
+	vars["LATER"] = "ignored"
+
+	all, _ := store.All("func-123")
+	if len(all) != 1 || all["ADD"] != "added" {
+		t.Errorf("Unexpected values after SetAll: %v", all)
+	}
+}
+
 func TestMemoryStore_SetAndGet(t *testing.T) {
 	store := NewMemoryStore()
 
@@ -281,3 +328,79 @@ func TestMemoryStore_All(t *testing.T) {
 		t.Error("Unexpected values in All() result")
 	}
 }
+
+func TestOverrideStore_Get(t *testing.T) {
+	base := NewMemoryStore()
+	_ = base.Set("func-123", "API_URL", "https://api.example.com")
+	_ = base.Set("func-123", "TOKEN", "secret")
+	_ = base.Set("func-456", "API_URL", "https://other.example.com")
+
+	store := NewOverrideStore(base, "func-123", map[string]string{"API_URL": "https://sandbox.example.com"})
+
+	if value, _ := store.Get("func-123", "API_URL"); value != "https://sandbox.example.com" {
+		t.Errorf("Expected overridden value, got '%s'", value)
+	}
+	if value, _ := store.Get("func-123", "TOKEN"); value != "secret" {
+		t.Errorf("Expected base value, got '%s'", value)
+	}
+	if value, _ := store.Get("func-456", "API_URL"); value != "https://other.example.com" {
+		t.Errorf("Expected overrides to apply to func-123 only, got '%s'", value)
+	}
+
+	all, err := store.All("func-123")
+	if err != nil {
+		t.Fatalf("Failed to get all vars: %v", err)
+	}
+	if len(all) != 2 || all["API_URL"] != "https://sandbox.example.com" || all["TOKEN"] != "secret" {
+		t.Errorf("Unexpected values in All() result: %v", all)
+	}
+}
+
+func TestOverrideStore_NeverPersists(t *testing.T) {
+	base := NewMemoryStore()
+	_ = base.Set("func-123", "API_URL", "https://api.example.com")
+
+	store := NewOverrideStore(base, "func-123", map[string]string{"API_URL": "https://sandbox.example.com"})
+
+	_ = store.Set("func-123", "API_URL", "https://changed.example.com")
+	if value, _ := store.Get("func-123", "API_URL"); value != "https://changed.example.com" {
+		t.Errorf("Expected updated override, got '%s'", value)
+	}
+
+	_ = store.Delete("func-123", "API_URL")
+	if _, err := store.Get("func-123", "API_URL"); err == nil {
+		t.Error("Expected deleted override to be missing")
+	}
+
+	if value, _ := base.Get("func-123", "API_URL"); value != "https://api.example.com" {
+		t.Errorf("Expected base store to be untouched, got '%s'", value)
+	}
+
+	// Keys that are not overridden still write through
+	_ = store.Set("func-123", "OTHER", "value")
+	if value, _ := base.Get("func-123", "OTHER"); value != "value" {
+		t.Errorf("Expected write through for non-overridden key, got '%s'", value)
+	}
+}
+
+func TestOverrideStore_SetAll(t *testing.T) {
+	base := NewMemoryStore()
+	_ = base.Set("func-123", "API_URL", "https://api.example.com")
+	_ = base.Set("func-123", "OLD", "value")
+
+	store := NewOverrideStore(base, "func-123", map[string]string{"API_URL": "https://sandbox.example.com"})
+
+	if err := store.SetAll("func-123", map[string]string{"API_URL": "https://changed.example.com", "NEW": "value"}); err != nil {
+		t.Fatalf("SetAll failed: %v", err)
+	}
+
+	all, _ := store.All("func-123")
+	if len(all) != 2 || all["API_URL"] != "https://changed.example.com" || all["NEW"] != "value" {
+		t.Errorf("Unexpected values after SetAll: %v", all)
+	}
+
+	baseAll, _ := base.All("func-123")
+	if len(baseAll) != 2 || baseAll["API_URL"] != "https://api.example.com" || baseAll["NEW"] != "value" {
+		t.Errorf("Expected the overridden key to keep its base value, got %v", baseAll)
+	}
+}
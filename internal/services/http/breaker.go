@@ -0,0 +1,185 @@
+package http
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Circuit breaker defaults
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// CircuitOpenError is returned without contacting the destination while the
+// circuit for its host is open
+type CircuitOpenError struct {
+	Host    string
+	RetryIn time.Duration // Time left until a trial request is let through
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.RetryIn <= 0 {
+		return fmt.Sprintf("circuit open: %s failed repeatedly, a trial request is in progress", e.Host)
+	}
+	return fmt.Sprintf("circuit open: %s failed repeatedly, retry in %s", e.Host, e.RetryIn.Round(time.Second))
+}
+
+// BreakerConfig controls when the circuit for a host opens.
+// A zero Threshold disables the breaker.
+type BreakerConfig struct {
+	Threshold int           // Consecutive failures that open the circuit
+	Cooldown  time.Duration // Time the circuit stays open before a trial request (default 30s)
+}
+
+// circuit is the state of a single host
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool // A trial request is in flight after the cooldown
+}
+
+// Breaker tracks consecutive failures per host. After Threshold failures the
+// circuit opens and requests fail fast for the cooldown. Then it half-opens:
+// a single trial request goes through, closing the circuit on success and
+// opening it again on failure.
+type Breaker struct {
+	config BreakerConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit // host -> state
+}
+
+// NewBreaker creates a Breaker with the given config
+func NewBreaker(config BreakerConfig) *Breaker {
+	config.Cooldown = cmp.Or(config.Cooldown, DefaultBreakerCooldown)
+	return &Breaker{
+		config:   config,
+		now:      time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// Allow returns a *CircuitOpenError when requests to host must fail fast.
+// Once the cooldown is over it lets a single trial request through.
+func (b *Breaker) Allow(host string) error {
+	if b.config.Threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, exists := b.circuits[host]
+	if !exists || c.failures < b.config.Threshold {
+		return nil
+	}
+
+	now := b.now()
+	if now.Before(c.openUntil) {
+		return &CircuitOpenError{Host: host, RetryIn: c.openUntil.Sub(now)}
+	}
+	if c.probing {
+		return &CircuitOpenError{Host: host}
+	}
+	c.probing = true
+	return nil
+}
+
+// Record updates the circuit of host with the outcome of a request
+func (b *Breaker) Record(host string, failed bool) {
+	if b.config.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		delete(b.circuits, host)
+		return
+	}
+
+	c, exists := b.circuits[host]
+	if !exists {
+		c = &circuit{}
+		b.circuits[host] = c
+	}
+	c.failures++
+	c.probing = false
+	if c.failures >= b.config.Threshold {
+		c.openUntil = b.now().Add(b.config.Cooldown)
+	}
+}
+
+// BreakerClient wraps a Client and fails fast for hosts whose circuit is open.
+// Transport errors and 5xx responses count as failures.
+type BreakerClient struct {
+	client  Client
+	breaker *Breaker
+}
+
+// NewBreakerClient creates a BreakerClient guarding client with breaker
+func NewBreakerClient(client Client, breaker *Breaker) *BreakerClient {
+	return &BreakerClient{client: client, breaker: breaker}
+}
+
+// Get performs a GET request unless the circuit for the host is open
+func (c *BreakerClient) Get(req Request) (Response, error) {
+	return c.do(req, c.client.Get)
+}
+
+// Post performs a POST request unless the circuit for the host is open
+func (c *BreakerClient) Post(req Request) (Response, error) {
+	return c.do(req, c.client.Post)
+}
+
+// Put performs a PUT request unless the circuit for the host is open
+func (c *BreakerClient) Put(req Request) (Response, error) {
+	return c.do(req, c.client.Put)
+}
+
+// Patch performs a PATCH request unless the circuit for the host is open
+func (c *BreakerClient) Patch(req Request) (Response, error) {
+	return c.do(req, c.client.Patch)
+}
+
+// Delete performs a DELETE request unless the circuit for the host is open
+func (c *BreakerClient) Delete(req Request) (Response, error) {
+	return c.do(req, c.client.Delete)
+}
+
+// do sends req through send and records the outcome for its host
+func (c *BreakerClient) do(req Request, send func(Request) (Response, error)) (Response, error) {
+	host := breakerHost(req.URL)
+	if host == "" {
+		return send(req)
+	}
+	if err := c.breaker.Allow(host); err != nil {
+		return Response{}, err
+	}
+
+	resp, err := send(req)
+
+	// Requests blocked by the outbound policy never reached the host,
+	// so they are not counted against it
+	var blockedErr *BlockedError
+	failed := (err != nil && !errors.As(err, &blockedErr)) || resp.StatusCode >= 500
+	c.breaker.Record(host, failed)
+	return resp, err
+}
+
+// breakerHost returns the host a circuit is kept for, or "" for invalid URLs
+func breakerHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Host)
+}
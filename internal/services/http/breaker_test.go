@@ -0,0 +1,139 @@
+package http
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	breaker := NewBreaker(BreakerConfig{Threshold: threshold, Cooldown: cooldown})
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestBreakerClient_OpensAfterConsecutiveFailures(t *testing.T) {
+	fake := NewFakeClient()
+	fake.SetResponse("GET", "https://down.example.com/a", Response{StatusCode: 503})
+	fake.SetError("GET", "https://down.example.com/b", errors.New("connection refused"))
+	breaker, _ := newTestBreaker(3, time.Minute)
+	client := NewBreakerClient(fake, breaker)
+
+	for _, path := range []string{"/a", "/b", "/a"} {
+		if _, err := client.Get(Request{URL: "https://down.example.com" + path}); err != nil && !strings.Contains(err.Error(), "refused") {
+			t.Fatalf("unexpected error before the circuit opens: %v", err)
+		}
+	}
+
+	_, err := client.Get(Request{URL: "https://down.example.com/a"})
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("expected CircuitOpenError, got %v", err)
+	}
+	if openErr.Host != "down.example.com" || openErr.RetryIn != time.Minute {
+		t.Errorf("unexpected error details: %+v", openErr)
+	}
+	if !strings.HasPrefix(err.Error(), "circuit open:") {
+		t.Errorf("expected message to start with 'circuit open:', got %q", err.Error())
+	}
+	if len(fake.Requests) != 3 {
+		t.Errorf("expected the open circuit to skip the client, got %d requests", len(fake.Requests))
+	}
+
+	// Other hosts are not affected
+	if _, err := client.Get(Request{URL: "https://up.example.com"}); err != nil {
+		t.Errorf("expected other hosts to pass, got %v", err)
+	}
+}
+
+func TestBreakerClient_SuccessResetsFailures(t *testing.T) {
+	fake := NewFakeClient()
+	fake.SetResponse("GET", "https://flaky.example.com/fail", Response{StatusCode: 500})
+	breaker, _ := newTestBreaker(2, time.Minute)
+	client := NewBreakerClient(fake, breaker)
+
+	for _, path := range []string{"/fail", "/ok", "/fail", "/ok", "/fail"} {
+		if _, err := client.Get(Request{URL: "https://flaky.example.com" + path}); err != nil {
+			t.Fatalf("expected circuit to stay closed, got %v", err)
+		}
+	}
+}
+
+func TestBreakerClient_HalfOpen(t *testing.T) {
+	fake := NewFakeClient()
+	fake.SetResponse("GET", "https://api.example.com", Response{StatusCode: 502})
+	breaker, now := newTestBreaker(2, 30*time.Second)
+	client := NewBreakerClient(fake, breaker)
+
+	for range 2 {
+		_, _ = client.Get(Request{URL: "https://api.example.com"})
+	}
+	var openErr *CircuitOpenError
+	if _, err := client.Get(Request{URL: "https://api.example.com"}); !errors.As(err, &openErr) {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+
+	// After the cooldown a failing trial request opens the circuit again
+	*now = now.Add(30 * time.Second)
+	if _, err := client.Get(Request{URL: "https://api.example.com"}); err != nil {
+		t.Fatalf("expected trial request to pass, got %v", err)
+	}
+	if _, err := client.Get(Request{URL: "https://api.example.com"}); !errors.As(err, &openErr) {
+		t.Fatalf("expected circuit to reopen after failed trial, got %v", err)
+	}
+
+	// A successful trial request closes it
+	*now = now.Add(30 * time.Second)
+	fake.SetResponse("GET", "https://api.example.com", Response{StatusCode: 200})
+	if _, err := client.Get(Request{URL: "https://api.example.com"}); err != nil {
+		t.Fatalf("expected trial request to pass, got %v", err)
+	}
+	if _, err := client.Get(Request{URL: "https://api.example.com"}); err != nil {
+		t.Errorf("expected circuit to be closed, got %v", err)
+	}
+}
+
+func TestBreaker_SingleTrialRequest(t *testing.T) {
+	breaker, now := newTestBreaker(1, time.Second)
+	breaker.Record("api.example.com", true)
+
+	*now = now.Add(time.Second)
+	if err := breaker.Allow("api.example.com"); err != nil {
+		t.Fatalf("expected trial request to be allowed, got %v", err)
+	}
+	err := breaker.Allow("api.example.com")
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("expected concurrent requests to fail during the trial, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "trial request") {
+		t.Errorf("unexpected message: %q", err.Error())
+	}
+}
+
+func TestBreakerClient_IgnoresBlockedRequests(t *testing.T) {
+	fake := NewFakeClient()
+	fake.SetError("GET", "http://internal.example.com", &BlockedError{Address: "10.0.0.1:80"})
+	breaker, _ := newTestBreaker(1, time.Minute)
+	client := NewBreakerClient(fake, breaker)
+
+	for range 3 {
+		_, err := client.Get(Request{URL: "http://internal.example.com"})
+		var blockedErr *BlockedError
+		if !errors.As(err, &blockedErr) {
+			t.Fatalf("expected BlockedError, got %v", err)
+		}
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	breaker, _ := newTestBreaker(0, time.Minute)
+	for range 10 {
+		breaker.Record("api.example.com", true)
+	}
+	if err := breaker.Allow("api.example.com"); err != nil {
+		t.Errorf("expected disabled breaker to allow requests, got %v", err)
+	}
+}
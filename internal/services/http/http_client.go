@@ -1,8 +1,11 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -22,6 +25,22 @@ type Request struct {
 	Headers Headers
 	Query   Query
 	Body    string
+
+	// Context cancels the request; nil means context.Background()
+	Context context.Context
+
+	// MaxBodyBytes fails the request with a *BodyTooLargeError once the
+	// response body is longer, without reading the rest; zero for no limit
+	MaxBodyBytes int64
+}
+
+// BodyTooLargeError is returned when a response body exceeds the request's MaxBodyBytes
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("response body is longer than %d bytes", e.Limit)
 }
 
 // Error represents an HTTP error with additional context
@@ -66,12 +85,33 @@ type DefaultClient struct {
 	client *http.Client
 }
 
-// NewDefaultClient creates a new default HTTP client with timeout and connection pooling
+// NewDefaultClient creates a new default HTTP client with timeout and connection pooling.
+// The client can reach any address; use NewRestrictedClient to enforce a Policy.
 func NewDefaultClient() *DefaultClient {
+	return newClient(nil)
+}
+
+// NewRestrictedClient creates a new default HTTP client that only connects to
+// addresses permitted by the given policy
+func NewRestrictedClient(policy *Policy) *DefaultClient {
+	return newClient(policy)
+}
+
+// newClient creates the underlying HTTP client, enforcing policy when it is not nil
+func newClient(policy *Policy) *DefaultClient {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if policy != nil {
+		dialer.Control = policy.control
+	}
+
 	return &DefaultClient{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
 				MaxIdleConns:        100,
 				MaxIdleConnsPerHost: 10,
 				MaxConnsPerHost:     100,
@@ -128,7 +168,11 @@ func (c *DefaultClient) doHTTPRequest(method string, httpReq Request) (Response,
 		bodyReader = strings.NewReader(httpReq.Body)
 	}
 
-	req, err := http.NewRequest(method, parsedURL.String(), bodyReader)
+	ctx := httpReq.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, parsedURL.String(), bodyReader)
 	if err != nil {
 		return Response{}, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -138,22 +182,34 @@ func (c *DefaultClient) doHTTPRequest(method string, httpReq Request) (Response,
 		req.Header.Set(key, value)
 	}
 
-	return c.doRequest(req)
+	return c.doRequest(req, httpReq.MaxBodyBytes)
 }
 
-// doRequest executes the HTTP request and converts the response
-func (c *DefaultClient) doRequest(req *http.Request) (Response, error) {
+// doRequest executes the HTTP request and converts the response, reading at
+// most maxBodyBytes of the body when it is positive
+func (c *DefaultClient) doRequest(req *http.Request, maxBodyBytes int64) (Response, error) {
 	resp, err := c.client.Do(req)
 	if err != nil {
+		var blockedErr *BlockedError
+		if errors.As(err, &blockedErr) {
+			return Response{}, blockedErr
+		}
 		return Response{}, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if maxBodyBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBodyBytes+1)
+	}
+	bodyBytes, err := io.ReadAll(body)
 	if err != nil {
 		return Response{}, fmt.Errorf("failed to read response body: %w", err)
 	}
+	if maxBodyBytes > 0 && int64(len(bodyBytes)) > maxBodyBytes {
+		return Response{}, &BodyTooLargeError{Limit: maxBodyBytes}
+	}
 
 	// Convert headers
 	headers := make(Headers)
@@ -229,6 +285,7 @@ func (f *FakeClient) Delete(req Request) (Response, error) {
 // do is the internal method that handles fake request processing
 func (f *FakeClient) do(method string, req Request) (Response, error) {
 	// Store the request for verification
+	req.Method = method
 	f.Requests = append(f.Requests, req)
 
 	key := method + ":" + req.URL
@@ -240,6 +297,9 @@ func (f *FakeClient) do(method string, req Request) (Response, error) {
 
 	// Check if there's a response configured for this request
 	if resp, exists := f.Responses[key]; exists {
+		if req.MaxBodyBytes > 0 && int64(len(resp.Body)) > req.MaxBodyBytes {
+			return Response{}, &BodyTooLargeError{Limit: req.MaxBodyBytes}
+		}
 		return resp, nil
 	}
 
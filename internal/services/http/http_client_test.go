@@ -1,8 +1,11 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -278,3 +281,33 @@ func TestDefaultClient_NetworkError(t *testing.T) {
 		t.Error("Expected network error, got nil")
 	}
 }
+
+func TestDefaultClient_MaxBodyBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer server.Close()
+
+	client := NewDefaultClient()
+	resp, err := client.Get(Request{URL: server.URL, MaxBodyBytes: 100})
+	if err != nil || len(resp.Body) != 100 {
+		t.Fatalf("Expected a body at the limit to be read, got %d bytes, %v", len(resp.Body), err)
+	}
+
+	_, err = client.Get(Request{URL: server.URL, MaxBodyBytes: 99})
+	var tooLarge *BodyTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 99 {
+		t.Errorf("Expected BodyTooLargeError, got %v", err)
+	}
+}
+
+func TestDefaultClient_Context(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewDefaultClient().Get(Request{URL: server.URL, Context: ctx}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a canceled request, got %v", err)
+	}
+}
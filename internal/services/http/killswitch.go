@@ -0,0 +1,55 @@
+package http
+
+import "github.com/dimiro1/lunar/internal/killswitch"
+
+// SwitchedClient wraps a Client and fails every request while the http
+// integration is switched off, without contacting the destination.
+type SwitchedClient struct {
+	client Client
+	sw     *killswitch.Switch
+}
+
+// NewSwitchedClient creates a SwitchedClient guarding client with sw
+func NewSwitchedClient(client Client, sw *killswitch.Switch) *SwitchedClient {
+	return &SwitchedClient{client: client, sw: sw}
+}
+
+// Get performs a GET request unless the http integration is disabled
+func (c *SwitchedClient) Get(req Request) (Response, error) {
+	if err := c.sw.Check(killswitch.HTTP); err != nil {
+		return Response{}, err
+	}
+	return c.client.Get(req)
+}
+
+// Post performs a POST request unless the http integration is disabled
+func (c *SwitchedClient) Post(req Request) (Response, error) {
+	if err := c.sw.Check(killswitch.HTTP); err != nil {
+		return Response{}, err
+	}
+	return c.client.Post(req)
+}
+
+// Put performs a PUT request unless the http integration is disabled
+func (c *SwitchedClient) Put(req Request) (Response, error) {
+	if err := c.sw.Check(killswitch.HTTP); err != nil {
+		return Response{}, err
+	}
+	return c.client.Put(req)
+}
+
+// Patch performs a PATCH request unless the http integration is disabled
+func (c *SwitchedClient) Patch(req Request) (Response, error) {
+	if err := c.sw.Check(killswitch.HTTP); err != nil {
+		return Response{}, err
+	}
+	return c.client.Patch(req)
+}
+
+// Delete performs a DELETE request unless the http integration is disabled
+func (c *SwitchedClient) Delete(req Request) (Response, error) {
+	if err := c.sw.Check(killswitch.HTTP); err != nil {
+		return Response{}, err
+	}
+	return c.client.Delete(req)
+}
@@ -0,0 +1,28 @@
+package http
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/killswitch"
+)
+
+func TestSwitchedClient(t *testing.T) {
+	fake := NewFakeClient()
+	sw := killswitch.New()
+	client := NewSwitchedClient(fake, sw)
+
+	if _, err := client.Get(Request{URL: "https://example.com"}); err != nil {
+		t.Fatalf("expected request to pass while enabled, got %v", err)
+	}
+
+	sw.Set(killswitch.HTTP, true)
+	_, err := client.Post(Request{URL: "https://example.com"})
+	var disabledErr *killswitch.DisabledError
+	if !errors.As(err, &disabledErr) {
+		t.Fatalf("expected DisabledError while disabled, got %v", err)
+	}
+	if len(fake.Requests) != 1 {
+		t.Errorf("expected only the first request to reach the client, got %d", len(fake.Requests))
+	}
+}
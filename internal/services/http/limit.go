@@ -0,0 +1,69 @@
+package http
+
+import (
+	"time"
+
+	"github.com/dimiro1/lunar/internal/outbound"
+)
+
+// LimitedClient wraps a Client and fails requests made after the execution
+// spent its outbound call or time budget, without contacting the destination.
+type LimitedClient struct {
+	client Client
+	budget *outbound.Budget
+}
+
+// NewLimitedClient creates a LimitedClient counting requests against budget
+func NewLimitedClient(client Client, budget *outbound.Budget) *LimitedClient {
+	return &LimitedClient{client: client, budget: budget}
+}
+
+// Get performs a GET request unless the budget is spent
+func (c *LimitedClient) Get(req Request) (Response, error) {
+	if err := c.budget.Acquire("http"); err != nil {
+		return Response{}, err
+	}
+	defer c.record(time.Now())
+	return c.client.Get(req)
+}
+
+// Post performs a POST request unless the budget is spent
+func (c *LimitedClient) Post(req Request) (Response, error) {
+	if err := c.budget.Acquire("http"); err != nil {
+		return Response{}, err
+	}
+	defer c.record(time.Now())
+	return c.client.Post(req)
+}
+
+// Put performs a PUT request unless the budget is spent
+func (c *LimitedClient) Put(req Request) (Response, error) {
+	if err := c.budget.Acquire("http"); err != nil {
+		return Response{}, err
+	}
+	defer c.record(time.Now())
+	return c.client.Put(req)
+}
+
+// Patch performs a PATCH request unless the budget is spent
+func (c *LimitedClient) Patch(req Request) (Response, error) {
+	if err := c.budget.Acquire("http"); err != nil {
+		return Response{}, err
+	}
+	defer c.record(time.Now())
+	return c.client.Patch(req)
+}
+
+// Delete performs a DELETE request unless the budget is spent
+func (c *LimitedClient) Delete(req Request) (Response, error) {
+	if err := c.budget.Acquire("http"); err != nil {
+		return Response{}, err
+	}
+	defer c.record(time.Now())
+	return c.client.Delete(req)
+}
+
+// record adds the time since start to the budget
+func (c *LimitedClient) record(start time.Time) {
+	c.budget.Record(time.Since(start))
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/outbound"
+)
+
+func TestLimitedClient(t *testing.T) {
+	fake := NewFakeClient()
+	client := NewLimitedClient(fake, outbound.NewBudget(2, 0))
+
+	if _, err := client.Get(Request{URL: "https://example.com"}); err != nil {
+		t.Fatalf("expected the first request to pass, got %v", err)
+	}
+	if _, err := client.Post(Request{URL: "https://example.com"}); err != nil {
+		t.Fatalf("expected the second request to pass, got %v", err)
+	}
+
+	_, err := client.Delete(Request{URL: "https://example.com"})
+	var limitErr *outbound.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Integration != "http" {
+		t.Fatalf("expected LimitError once the budget is spent, got %v", err)
+	}
+	if len(fake.Requests) != 2 {
+		t.Errorf("expected only 2 requests to reach the client, got %d", len(fake.Requests))
+	}
+}
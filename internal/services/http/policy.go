@@ -0,0 +1,136 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// DefaultDeniedRanges lists the address ranges blocked by DefaultPolicy:
+// loopback, private, link-local (including cloud metadata endpoints),
+// carrier-grade NAT, benchmarking, multicast, reserved, NAT64 and
+// unspecified addresses.
+var DefaultDeniedRanges = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// BlockedError is returned when an outbound request targets an address
+// that is not permitted by the client's Policy
+type BlockedError struct {
+	Address string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("request blocked: destination %s is not allowed", e.Address)
+}
+
+// Policy restricts which network addresses outbound requests may reach.
+// Addresses in Allow are always permitted, even when they also match Deny.
+type Policy struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// DefaultPolicy returns a policy that blocks DefaultDeniedRanges
+func DefaultPolicy() *Policy {
+	policy, _ := NewPolicy(nil, nil)
+	return policy
+}
+
+// NewPolicy builds a policy from allow and deny entries. Each entry is an IP
+// address or a CIDR range. The deny entries are added to DefaultDeniedRanges.
+func NewPolicy(allow, deny []string) (*Policy, error) {
+	allowPrefixes, err := ParsePrefixes(allow)
+	if err != nil {
+		return nil, err
+	}
+
+	denyPrefixes, err := ParsePrefixes(append(append([]string{}, DefaultDeniedRanges...), deny...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Policy{Allow: allowPrefixes, Deny: denyPrefixes}, nil
+}
+
+// Allowed reports whether the policy permits connections to the given address
+func (p *Policy) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range p.Allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range p.Deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// control is used as net.Dialer.Control so the policy is enforced on the
+// resolved address right before dialing, which also covers redirects and
+// hostnames that resolve to internal addresses.
+func (p *Policy) control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	if !p.Allowed(addr) {
+		return &BlockedError{Address: addr.Unmap().String()}
+	}
+	return nil
+}
+
+// ParsePrefixes parses IP addresses and CIDR ranges into prefixes
+func ParsePrefixes(entries []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
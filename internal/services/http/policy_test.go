@@ -0,0 +1,132 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestPolicy_Allowed(t *testing.T) {
+	policy := DefaultPolicy()
+
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{"169.254.169.254", false}, // AWS/GCP/Azure metadata endpoint
+		{"fd00:ec2::254", false},   // AWS IPv6 metadata endpoint
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"::ffff:169.254.169.254", false}, // IPv4-mapped IPv6
+		{"64:ff9b::a9fe:a9fe", false},     // NAT64 of 169.254.169.254
+		{"198.18.0.1", false},             // benchmarking
+		{"224.0.0.1", false},              // multicast
+		{"239.255.255.250", false},
+		{"ff02::1", false},
+		{"240.0.0.1", false}, // reserved
+		{"255.255.255.255", false},
+		{"93.184.216.34", true},
+		{"2606:4700::1111", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := policy.Allowed(netip.MustParseAddr(tt.addr)); got != tt.allowed {
+				t.Errorf("Allowed(%s) = %v, want %v", tt.addr, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestNewPolicy_Overrides(t *testing.T) {
+	policy, err := NewPolicy([]string{"10.0.0.5", "192.168.1.0/24"}, []string{"203.0.113.0/24"})
+	if err != nil {
+		t.Fatalf("NewPolicy failed: %v", err)
+	}
+
+	if !policy.Allowed(netip.MustParseAddr("10.0.0.5")) {
+		t.Error("expected allowed IP to override default deny")
+	}
+	if policy.Allowed(netip.MustParseAddr("10.0.0.6")) {
+		t.Error("expected neighbouring private IP to stay blocked")
+	}
+	if !policy.Allowed(netip.MustParseAddr("192.168.1.20")) {
+		t.Error("expected allowed CIDR to override default deny")
+	}
+	if policy.Allowed(netip.MustParseAddr("203.0.113.7")) {
+		t.Error("expected custom deny range to be blocked")
+	}
+	if policy.Allowed(netip.MustParseAddr("169.254.169.254")) {
+		t.Error("expected metadata endpoint to stay blocked")
+	}
+}
+
+func TestNewPolicy_InvalidEntry(t *testing.T) {
+	if _, err := NewPolicy([]string{"not-an-ip"}, nil); err == nil {
+		t.Error("expected error for invalid allow entry")
+	}
+	if _, err := NewPolicy(nil, []string{"10.0.0.0/99"}); err == nil {
+		t.Error("expected error for invalid deny entry")
+	}
+}
+
+func TestRestrictedClient_BlocksMetadataEndpoint(t *testing.T) {
+	client := NewRestrictedClient(DefaultPolicy())
+
+	_, err := client.Get(Request{URL: "http://169.254.169.254/latest/meta-data/"})
+
+	var blockedErr *BlockedError
+	if !errors.As(err, &blockedErr) {
+		t.Fatalf("expected BlockedError, got %v", err)
+	}
+	if blockedErr.Address != "169.254.169.254" {
+		t.Errorf("expected blocked address 169.254.169.254, got %s", blockedErr.Address)
+	}
+}
+
+func TestRestrictedClient_BlocksResolvedLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach the server")
+	}))
+	defer server.Close()
+
+	client := NewRestrictedClient(DefaultPolicy())
+
+	// localhost resolves to a loopback address, which must be checked after resolution
+	serverURL, _ := url.Parse(server.URL)
+	_, err := client.Get(Request{URL: "http://localhost:" + serverURL.Port()})
+
+	var blockedErr *BlockedError
+	if !errors.As(err, &blockedErr) {
+		t.Fatalf("expected BlockedError, got %v", err)
+	}
+}
+
+func TestRestrictedClient_AllowOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	policy, err := NewPolicy([]string{"127.0.0.1"}, nil)
+	if err != nil {
+		t.Fatalf("NewPolicy failed: %v", err)
+	}
+
+	resp, err := NewRestrictedClient(policy).Get(Request{URL: server.URL})
+	if err != nil {
+		t.Fatalf("expected request to succeed, got %v", err)
+	}
+	if resp.Body != "ok" {
+		t.Errorf("expected body ok, got %s", resp.Body)
+	}
+}
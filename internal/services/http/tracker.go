@@ -0,0 +1,336 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimiro1/lunar/internal/masking"
+	"github.com/dimiro1/lunar/internal/store"
+	"github.com/rs/xid"
+)
+
+// MaxCapturedBodySize is the number of bytes of each body kept by a tracker
+const MaxCapturedBodySize = 64 * 1024
+
+const redactedValue = "[REDACTED]"
+
+// TrackRequest contains the data needed to track an outbound HTTP request
+type TrackRequest struct {
+	Method          string
+	URL             string
+	Query           Query
+	RequestHeaders  Headers
+	RequestBody     string
+	StatusCode      *int
+	ResponseHeaders Headers
+	ResponseBody    *string
+	Status          store.HTTPRequestStatus
+	ErrorMessage    *string
+	DurationMs      int64
+}
+
+// Tracker is an interface for tracking outbound HTTP requests
+// executionID is used to isolate requests for each function execution
+type Tracker interface {
+	Track(executionID string, req TrackRequest)
+	Requests(executionID string) []store.HTTPRequest
+	RequestsPaginated(executionID string, limit, offset int) ([]store.HTTPRequest, int64)
+	RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.HTTPRequest, int64)
+}
+
+// RequestFilter narrows the HTTP requests returned by RequestsFiltered.
+// Empty fields match any value.
+type RequestFilter struct {
+	Status store.HTTPRequestStatus
+}
+
+// matches reports whether the request satisfies every non-empty filter field
+func (f RequestFilter) matches(req store.HTTPRequest) bool {
+	return f.Status == "" || req.Status == f.Status
+}
+
+// where builds the SQL conditions and arguments for the filter
+func (f RequestFilter) where(executionID string) (string, []any) {
+	conditions := []string{"execution_id = ?"}
+	args := []any{executionID}
+	if f.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, f.Status)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// newHTTPRequest builds the stored record for req, masking sensitive headers,
+// query parameters and JSON body fields and truncating large bodies
+func newHTTPRequest(executionID string, req TrackRequest) store.HTTPRequest {
+	now := time.Now()
+	httpReq := store.HTTPRequest{
+		ID:              xid.New().String(),
+		ExecutionID:     executionID,
+		Method:          req.Method,
+		URL:             maskURL(req.URL, req.Query),
+		RequestHeaders:  masking.MaskHeaders(req.RequestHeaders),
+		StatusCode:      req.StatusCode,
+		ResponseHeaders: masking.MaskHeaders(req.ResponseHeaders),
+		Status:          req.Status,
+		ErrorMessage:    req.ErrorMessage,
+		DurationMs:      req.DurationMs,
+		CreatedAt:       now.Unix(),
+		CreatedAtNs:     now.UnixNano(),
+	}
+	if req.RequestBody != "" {
+		body := captureBody(req.RequestBody)
+		httpReq.RequestBody = &body
+	}
+	if req.ResponseBody != nil {
+		body := captureBody(*req.ResponseBody)
+		httpReq.ResponseBody = &body
+	}
+	return httpReq
+}
+
+// captureBody masks a body and truncates it to MaxCapturedBodySize
+func captureBody(body string) string {
+	body = masking.MaskJSONBody(body)
+	if len(body) > MaxCapturedBodySize {
+		return body[:MaxCapturedBodySize] + "... (truncated)"
+	}
+	return body
+}
+
+// maskURL merges query into rawURL and redacts sensitive query parameters
+func maskURL(rawURL string, query Query) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	values := u.Query()
+	for key, value := range query {
+		values.Set(key, value)
+	}
+	if len(values) == 0 {
+		return rawURL
+	}
+
+	// Build the query by hand so redacted values stay readable
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var parts []string
+	for _, key := range keys {
+		for _, value := range values[key] {
+			if masking.IsSensitiveQueryParam(key) {
+				value = redactedValue
+			} else {
+				value = url.QueryEscape(value)
+			}
+			parts = append(parts, url.QueryEscape(key)+"="+value)
+		}
+	}
+	u.RawQuery = ""
+	return u.String() + "?" + strings.Join(parts, "&")
+}
+
+// MemoryTracker is an in-memory implementation of Tracker
+type MemoryTracker struct {
+	mu       sync.RWMutex
+	requests []store.HTTPRequest
+}
+
+// NewMemoryTracker creates a new in-memory tracker
+func NewMemoryTracker() *MemoryTracker {
+	return &MemoryTracker{
+		requests: make([]store.HTTPRequest, 0),
+	}
+}
+
+// Track records an outbound HTTP request
+func (m *MemoryTracker) Track(executionID string, req TrackRequest) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, newHTTPRequest(executionID, req))
+}
+
+// Requests returns all HTTP requests for the specified executionID
+func (m *MemoryTracker) Requests(executionID string) []store.HTTPRequest {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	requests := make([]store.HTTPRequest, 0)
+	for _, req := range m.requests {
+		if req.ExecutionID == executionID {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// RequestsPaginated returns paginated HTTP requests for the specified executionID
+func (m *MemoryTracker) RequestsPaginated(executionID string, limit, offset int) ([]store.HTTPRequest, int64) {
+	return m.RequestsFiltered(executionID, RequestFilter{}, limit, offset)
+}
+
+// RequestsFiltered returns paginated HTTP requests for the specified executionID matching the filter
+func (m *MemoryTracker) RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.HTTPRequest, int64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Filter requests by executionID and the filter fields
+	filtered := make([]store.HTTPRequest, 0)
+	for _, req := range m.requests {
+		if req.ExecutionID == executionID && filter.matches(req) {
+			filtered = append(filtered, req)
+		}
+	}
+
+	total := int64(len(filtered))
+
+	// Apply pagination
+	if offset >= len(filtered) {
+		return []store.HTTPRequest{}, total
+	}
+
+	end := min(offset+limit, len(filtered))
+
+	return filtered[offset:end], total
+}
+
+// Clear removes all tracked requests
+func (m *MemoryTracker) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = make([]store.HTTPRequest, 0)
+}
+
+// SQLiteTracker is a SQLite-backed implementation of Tracker
+type SQLiteTracker struct {
+	db *sql.DB
+}
+
+// NewSQLiteTracker creates a new SQLite-backed tracker
+func NewSQLiteTracker(db *sql.DB) *SQLiteTracker {
+	return &SQLiteTracker{db: db}
+}
+
+// Track records an outbound HTTP request
+func (s *SQLiteTracker) Track(executionID string, req TrackRequest) {
+	httpReq := newHTTPRequest(executionID, req)
+
+	requestHeaders, _ := json.Marshal(httpReq.RequestHeaders)
+	responseHeaders, _ := json.Marshal(httpReq.ResponseHeaders)
+
+	_, err := s.db.Exec(
+		`INSERT INTO http_requests
+		(id, execution_id, method, url, request_headers, request_body, status_code,
+		 response_headers, response_body, status, error_message, duration_ms, created_at, created_at_ns)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		httpReq.ID, executionID, httpReq.Method, httpReq.URL, string(requestHeaders), httpReq.RequestBody,
+		httpReq.StatusCode, string(responseHeaders), httpReq.ResponseBody, httpReq.Status,
+		httpReq.ErrorMessage, httpReq.DurationMs, httpReq.CreatedAt, httpReq.CreatedAtNs,
+	)
+	if err != nil {
+		// Log error but don't fail the execution
+		fmt.Printf("Failed to track HTTP request: %v\n", err)
+	}
+}
+
+// Requests returns all HTTP requests for the specified executionID
+func (s *SQLiteTracker) Requests(executionID string) []store.HTTPRequest {
+	rows, err := s.db.Query(
+		`SELECT id, execution_id, method, url, request_headers, request_body, status_code,
+		        response_headers, response_body, status, error_message, duration_ms, created_at, created_at_ns
+		 FROM http_requests WHERE execution_id = ? ORDER BY created_at, rowid`,
+		executionID,
+	)
+	if err != nil {
+		return []store.HTTPRequest{}
+	}
+	defer func() { _ = rows.Close() }()
+
+	return s.scanRequests(rows)
+}
+
+// RequestsPaginated returns paginated HTTP requests for the specified executionID
+func (s *SQLiteTracker) RequestsPaginated(executionID string, limit, offset int) ([]store.HTTPRequest, int64) {
+	return s.RequestsFiltered(executionID, RequestFilter{}, limit, offset)
+}
+
+// RequestsFiltered returns paginated HTTP requests for the specified executionID matching the filter
+func (s *SQLiteTracker) RequestsFiltered(executionID string, filter RequestFilter, limit, offset int) ([]store.HTTPRequest, int64) {
+	where, args := filter.where(executionID)
+
+	// Get total count
+	var total int64
+	err := s.db.QueryRow("SELECT COUNT(*) FROM http_requests WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		return []store.HTTPRequest{}, 0
+	}
+
+	// Get paginated requests
+	rows, err := s.db.Query(
+		`SELECT id, execution_id, method, url, request_headers, request_body, status_code,
+		        response_headers, response_body, status, error_message, duration_ms, created_at, created_at_ns
+		 FROM http_requests WHERE `+where+` ORDER BY created_at LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return []store.HTTPRequest{}, total
+	}
+	defer func() { _ = rows.Close() }()
+
+	return s.scanRequests(rows), total
+}
+
+// scanRequests is a helper to scan rows into HTTPRequest slice
+func (s *SQLiteTracker) scanRequests(rows *sql.Rows) []store.HTTPRequest {
+	requests := make([]store.HTTPRequest, 0)
+	for rows.Next() {
+		var req store.HTTPRequest
+		var requestHeaders, requestBody, responseHeaders, responseBody, errorMessage sql.NullString
+		var statusCode, createdAtNs sql.NullInt64
+
+		if err := rows.Scan(
+			&req.ID, &req.ExecutionID, &req.Method, &req.URL, &requestHeaders, &requestBody,
+			&statusCode, &responseHeaders, &responseBody, &req.Status, &errorMessage,
+			&req.DurationMs, &req.CreatedAt, &createdAtNs,
+		); err != nil {
+			continue
+		}
+		req.CreatedAtNs = createdAtNs.Int64
+
+		if requestHeaders.Valid {
+			_ = json.Unmarshal([]byte(requestHeaders.String), &req.RequestHeaders)
+		}
+		if requestBody.Valid {
+			req.RequestBody = &requestBody.String
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			req.StatusCode = &code
+		}
+		if responseHeaders.Valid {
+			_ = json.Unmarshal([]byte(responseHeaders.String), &req.ResponseHeaders)
+		}
+		if responseBody.Valid {
+			req.ResponseBody = &responseBody.String
+		}
+		if errorMessage.Valid {
+			req.ErrorMessage = &errorMessage.String
+		}
+
+		requests = append(requests, req)
+	}
+	return requests
+}
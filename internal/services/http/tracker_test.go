@@ -0,0 +1,160 @@
+package http
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/migrate"
+	"github.com/dimiro1/lunar/internal/store"
+	_ "modernc.org/sqlite"
+)
+
+func successRequest() TrackRequest {
+	statusCode := 200
+	responseBody := `{"id":1,"access_token":"abc123"}`
+	return TrackRequest{
+		Method:          "POST",
+		URL:             "https://api.example.com/items?page=2",
+		Query:           Query{"api_key": "sk-live-123"},
+		RequestHeaders:  Headers{"Authorization": "Bearer secret", "Content-Type": "application/json"},
+		RequestBody:     `{"name":"widget","password":"hunter2"}`,
+		StatusCode:      &statusCode,
+		ResponseHeaders: Headers{"Set-Cookie": "session=abc", "Content-Type": "application/json"},
+		ResponseBody:    &responseBody,
+		Status:          store.HTTPRequestStatusSuccess,
+		DurationMs:      42,
+	}
+}
+
+func TestMemoryTracker_Track(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.Track("exec-1", successRequest())
+
+	requests := tracker.Requests("exec-1")
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+
+	tracked := requests[0]
+	if tracked.Method != "POST" {
+		t.Errorf("expected method 'POST', got '%s'", tracked.Method)
+	}
+	if tracked.StatusCode == nil || *tracked.StatusCode != 200 {
+		t.Errorf("expected status code 200, got %v", tracked.StatusCode)
+	}
+	if tracked.DurationMs != 42 {
+		t.Errorf("expected duration 42, got %d", tracked.DurationMs)
+	}
+	if tracked.Status != store.HTTPRequestStatusSuccess {
+		t.Errorf("expected status 'success', got '%s'", tracked.Status)
+	}
+}
+
+func TestMemoryTracker_MasksSensitiveData(t *testing.T) {
+	tracker := NewMemoryTracker()
+	tracker.Track("exec-1", successRequest())
+
+	tracked := tracker.Requests("exec-1")[0]
+
+	if tracked.URL != "https://api.example.com/items?api_key=[REDACTED]&page=2" {
+		t.Errorf("expected masked URL, got '%s'", tracked.URL)
+	}
+	if tracked.RequestHeaders["Authorization"] != "[REDACTED]" {
+		t.Errorf("expected Authorization to be masked, got '%s'", tracked.RequestHeaders["Authorization"])
+	}
+	if tracked.RequestHeaders["Content-Type"] != "application/json" {
+		t.Errorf("expected Content-Type to be kept, got '%s'", tracked.RequestHeaders["Content-Type"])
+	}
+	if tracked.ResponseHeaders["Set-Cookie"] != "[REDACTED]" {
+		t.Errorf("expected Set-Cookie to be masked, got '%s'", tracked.ResponseHeaders["Set-Cookie"])
+	}
+	if tracked.RequestBody == nil || strings.Contains(*tracked.RequestBody, "hunter2") {
+		t.Errorf("expected request body password to be masked, got %v", tracked.RequestBody)
+	}
+	if tracked.ResponseBody == nil || strings.Contains(*tracked.ResponseBody, "abc123") {
+		t.Errorf("expected response body token to be masked, got %v", tracked.ResponseBody)
+	}
+}
+
+func TestMemoryTracker_TruncatesLargeBodies(t *testing.T) {
+	tracker := NewMemoryTracker()
+
+	req := successRequest()
+	req.RequestBody = strings.Repeat("a", MaxCapturedBodySize+100)
+	tracker.Track("exec-1", req)
+
+	body := *tracker.Requests("exec-1")[0].RequestBody
+	if !strings.HasSuffix(body, "... (truncated)") || len(body) > MaxCapturedBodySize+len("... (truncated)") {
+		t.Errorf("expected body to be truncated, got %d bytes", len(body))
+	}
+}
+
+func TestMemoryTracker_RequestsFiltered(t *testing.T) {
+	tracker := NewMemoryTracker()
+
+	errMsg := "connection refused"
+	tracker.Track("exec-1", successRequest())
+	tracker.Track("exec-1", TrackRequest{
+		Method:       "GET",
+		URL:          "https://down.example.com",
+		Status:       store.HTTPRequestStatusError,
+		ErrorMessage: &errMsg,
+	})
+	tracker.Track("exec-2", successRequest())
+
+	requests, total := tracker.RequestsFiltered("exec-1", RequestFilter{Status: store.HTTPRequestStatusError}, 10, 0)
+	if total != 1 || len(requests) != 1 {
+		t.Fatalf("expected 1 error request, got %d (total %d)", len(requests), total)
+	}
+	if requests[0].URL != "https://down.example.com" {
+		t.Errorf("expected the failed request, got '%s'", requests[0].URL)
+	}
+
+	requests, total = tracker.RequestsPaginated("exec-1", 1, 1)
+	if total != 2 || len(requests) != 1 {
+		t.Errorf("expected 1 of 2 requests, got %d (total %d)", len(requests), total)
+	}
+}
+
+func TestSQLiteTracker_RoundTrip(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-http-*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	_ = tmpfile.Close()
+
+	db, err := sql.Open("sqlite", tmpfile.Name())
+	if err != nil {
+		_ = os.Remove(tmpfile.Name())
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	migrate.RunTest(t, db)
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.Remove(tmpfile.Name())
+	})
+
+	tracker := NewSQLiteTracker(db)
+	tracker.Track("exec-1", successRequest())
+
+	requests, total := tracker.RequestsPaginated("exec-1", 10, 0)
+	if total != 1 || len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d (total %d)", len(requests), total)
+	}
+
+	tracked := requests[0]
+	if tracked.Method != "POST" || tracked.StatusCode == nil || *tracked.StatusCode != 200 {
+		t.Errorf("unexpected request: %+v", tracked)
+	}
+	if tracked.RequestHeaders["Authorization"] != "[REDACTED]" {
+		t.Errorf("expected masked headers to round trip, got %v", tracked.RequestHeaders)
+	}
+	if tracked.ResponseBody == nil || !strings.Contains(*tracked.ResponseBody, `"id":1`) {
+		t.Errorf("expected response body to round trip, got %v", tracked.ResponseBody)
+	}
+	if tracked.ErrorMessage != nil {
+		t.Errorf("expected no error message, got %v", *tracked.ErrorMessage)
+	}
+}
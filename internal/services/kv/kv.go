@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 )
 
 // Error represents a KV store error
@@ -21,6 +23,10 @@ type Store interface {
 	Get(functionID, key string) (string, error)
 	Set(functionID, key, value string) error
 	Delete(functionID, key string) error
+	// DeleteAll removes every key of a functionID
+	DeleteAll(functionID string) error
+	// Keys lists the keys of a functionID
+	Keys(functionID string) ([]string, error)
 }
 
 // MemoryStore is an in-memory implementation of Store
@@ -66,6 +72,17 @@ func (m *MemoryStore) Delete(functionID, key string) error {
 	return nil
 }
 
+// DeleteAll removes every key-value pair of a functionID
+func (m *MemoryStore) DeleteAll(functionID string) error {
+	delete(m.data, functionID)
+	return nil
+}
+
+// Keys lists the keys of a functionID, in no particular order
+func (m *MemoryStore) Keys(functionID string) ([]string, error) {
+	return slices.Collect(maps.Keys(m.data[functionID])), nil
+}
+
 // SQLiteStore is a SQLite-backed implementation of Store
 type SQLiteStore struct {
 	db *sql.DB
@@ -117,3 +134,33 @@ func (s *SQLiteStore) Delete(functionID, key string) error {
 	}
 	return nil
 }
+
+// DeleteAll removes every key-value pair of a functionID
+func (s *SQLiteStore) DeleteAll(functionID string) error {
+	if _, err := s.db.Exec("DELETE FROM kv_store WHERE function_id = ?", functionID); err != nil {
+		return fmt.Errorf("failed to delete values: %w", err)
+	}
+	return nil
+}
+
+// Keys lists the keys of a functionID, in no particular order
+func (s *SQLiteStore) Keys(functionID string) ([]string, error) {
+	rows, err := s.db.Query("SELECT key FROM kv_store WHERE function_id = ?", functionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	return keys, nil
+}
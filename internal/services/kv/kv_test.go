@@ -3,6 +3,7 @@ package kv
 import (
 	"database/sql"
 	"os"
+	"slices"
 	"testing"
 
 	"github.com/dimiro1/lunar/internal/migrate"
@@ -123,6 +124,57 @@ func TestSQLiteStore_Delete(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_DeleteAll(t *testing.T) {
+	db := setupTestDB(t)
+	store := NewSQLiteStore(db)
+
+	for _, key := range []string{"key1", "key2"} {
+		if err := store.Set("func-123", key, "value"); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+	if err := store.Set("func-456", "key1", "value"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	if err := store.DeleteAll("func-123"); err != nil {
+		t.Fatalf("Failed to delete values: %v", err)
+	}
+
+	for _, key := range []string{"key1", "key2"} {
+		if _, err := store.Get("func-123", key); err == nil {
+			t.Errorf("Expected %s to be deleted", key)
+		}
+	}
+	// Other functions keep their keys
+	if _, err := store.Get("func-456", "key1"); err != nil {
+		t.Errorf("Expected other function's key to remain, got %v", err)
+	}
+}
+
+func TestSQLiteStore_Keys(t *testing.T) {
+	db := setupTestDB(t)
+	store := NewSQLiteStore(db)
+
+	for _, key := range []string{"key2", "key1"} {
+		if err := store.Set("func-123", key, "value"); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+	if err := store.Set("func-456", "key3", "value"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	keys, err := store.Keys("func-123")
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"key1", "key2"}) {
+		t.Errorf("Expected [key1 key2], got %v", keys)
+	}
+}
+
 func TestSQLiteStore_DeleteNonExistent(t *testing.T) {
 	db := setupTestDB(t)
 	store := NewSQLiteStore(db)
@@ -0,0 +1,4 @@
+// Package modules stores the Lua modules of a function, which its code loads
+// with require. Modules are isolated per function and shared by all of its
+// versions. Supports both in-memory and SQLite-backed implementations.
+package modules
@@ -0,0 +1,182 @@
+package modules
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrModuleNotFound is returned when a function has no module with the given name
+var ErrModuleNotFound = errors.New("lua module not found")
+
+// Module is Lua code stored per function and loaded with require(name)
+type Module struct {
+	Name      string `json:"name"`
+	Code      string `json:"code"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Store is an interface for Lua module storage operations
+// functionID is used to isolate modules between functions
+type Store interface {
+	Get(functionID, name string) (Module, error)
+	Set(functionID string, module Module) (Module, error)
+	Delete(functionID, name string) error
+	All(functionID string) ([]Module, error)
+}
+
+// MemoryStore is an in-memory implementation of Store
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string]map[string]Module // functionID -> name -> module
+}
+
+// NewMemoryStore creates a new in-memory module store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: make(map[string]map[string]Module),
+	}
+}
+
+// Get retrieves a module by functionID and name
+func (m *MemoryStore) Get(functionID, name string) (Module, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	module, exists := m.data[functionID][name]
+	if !exists {
+		return Module{}, ErrModuleNotFound
+	}
+	return module, nil
+}
+
+// Set creates or replaces a module, keeping the original creation time
+func (m *MemoryStore) Set(functionID string, module Module) (Module, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.data[functionID]; !exists {
+		m.data[functionID] = make(map[string]Module)
+	}
+
+	now := time.Now().Unix()
+	module.CreatedAt = now
+	if existing, exists := m.data[functionID][module.Name]; exists {
+		module.CreatedAt = existing.CreatedAt
+	}
+	module.UpdatedAt = now
+	m.data[functionID][module.Name] = module
+	return module, nil
+}
+
+// Delete removes a module, returning ErrModuleNotFound if it does not exist
+func (m *MemoryStore) Delete(functionID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.data[functionID][name]; !exists {
+		return ErrModuleNotFound
+	}
+	delete(m.data[functionID], name)
+	return nil
+}
+
+// All returns the modules of a function sorted by name
+func (m *MemoryStore) All(functionID string) ([]Module, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := slices.Collect(maps.Values(m.data[functionID]))
+	slices.SortFunc(result, func(a, b Module) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, nil
+}
+
+// SQLiteStore is a SQLite-backed implementation of Store
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore creates a new SQLite-backed module store
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+// Get retrieves a module by functionID and name
+func (s *SQLiteStore) Get(functionID, name string) (Module, error) {
+	var module Module
+	err := s.db.QueryRow(
+		"SELECT name, code, created_at, updated_at FROM lua_modules WHERE function_id = ? AND name = ?",
+		functionID, name,
+	).Scan(&module.Name, &module.Code, &module.CreatedAt, &module.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Module{}, ErrModuleNotFound
+	}
+	if err != nil {
+		return Module{}, fmt.Errorf("failed to get lua module: %w", err)
+	}
+	return module, nil
+}
+
+// Set creates or replaces a module, keeping the original creation time
+func (s *SQLiteStore) Set(functionID string, module Module) (Module, error) {
+	now := time.Now().Unix()
+	err := s.db.QueryRow(`
+		INSERT INTO lua_modules (function_id, name, code, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (function_id, name) DO UPDATE SET
+			code = excluded.code,
+			updated_at = excluded.updated_at
+		RETURNING created_at, updated_at`,
+		functionID, module.Name, module.Code, now, now,
+	).Scan(&module.CreatedAt, &module.UpdatedAt)
+	if err != nil {
+		return Module{}, fmt.Errorf("failed to set lua module: %w", err)
+	}
+	return module, nil
+}
+
+// Delete removes a module, returning ErrModuleNotFound if it does not exist
+func (s *SQLiteStore) Delete(functionID, name string) error {
+	result, err := s.db.Exec(
+		"DELETE FROM lua_modules WHERE function_id = ? AND name = ?",
+		functionID, name,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete lua module: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrModuleNotFound
+	}
+	return nil
+}
+
+// All returns the modules of a function sorted by name
+func (s *SQLiteStore) All(functionID string) ([]Module, error) {
+	rows, err := s.db.Query(
+		"SELECT name, code, created_at, updated_at FROM lua_modules WHERE function_id = ? ORDER BY name",
+		functionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lua modules: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := make([]Module, 0)
+	for rows.Next() {
+		var module Module
+		if err := rows.Scan(&module.Name, &module.Code, &module.CreatedAt, &module.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lua module: %w", err)
+		}
+		result = append(result, module)
+	}
+	return result, rows.Err()
+}
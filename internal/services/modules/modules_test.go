@@ -0,0 +1,87 @@
+package modules
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dimiro1/lunar/internal/migrate"
+	_ "modernc.org/sqlite"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "modules.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrate.RunTest(t, db)
+	return db
+}
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+
+	if _, err := store.Get("fn-1", "utils"); !errors.Is(err, ErrModuleNotFound) {
+		t.Fatalf("expected ErrModuleNotFound, got %v", err)
+	}
+
+	created, err := store.Set("fn-1", Module{Name: "utils", Code: "return {}"})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if created.CreatedAt == 0 || created.UpdatedAt == 0 {
+		t.Errorf("expected timestamps to be set, got %+v", created)
+	}
+
+	updated, err := store.Set("fn-1", Module{Name: "utils", Code: "return { v = 2 }"})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if updated.CreatedAt != created.CreatedAt {
+		t.Errorf("expected created_at to be kept, got %d want %d", updated.CreatedAt, created.CreatedAt)
+	}
+
+	got, err := store.Get("fn-1", "utils")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Code != "return { v = 2 }" {
+		t.Errorf("expected updated code, got %q", got.Code)
+	}
+
+	if _, err := store.Set("fn-1", Module{Name: "auth.jwt", Code: "return {}"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := store.Set("fn-2", Module{Name: "other", Code: "return {}"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	all, err := store.All("fn-1")
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "auth.jwt" || all[1].Name != "utils" {
+		t.Errorf("expected modules sorted by name, got %+v", all)
+	}
+
+	if err := store.Delete("fn-1", "utils"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete("fn-1", "utils"); !errors.Is(err, ErrModuleNotFound) {
+		t.Errorf("expected ErrModuleNotFound deleting twice, got %v", err)
+	}
+	if _, err := store.Get("fn-2", "other"); err != nil {
+		t.Errorf("expected other function's module to remain, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, NewSQLiteStore(setupTestDB(t)))
+}
@@ -1,8 +1,12 @@
 package store
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -32,6 +36,11 @@ func (db *MemoryDB) CreateFunction(_ context.Context, fn Function) (Function, er
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	return db.createFunctionLocked(fn), nil
+}
+
+// createFunctionLocked stores a new function. The caller must hold the write lock.
+func (db *MemoryDB) createFunctionLocked(fn Function) Function {
 	fn.CreatedAt = time.Now().Unix()
 	fn.UpdatedAt = fn.CreatedAt
 	if fn.EnvVars == nil {
@@ -39,7 +48,7 @@ func (db *MemoryDB) CreateFunction(_ context.Context, fn Function) (Function, er
 	}
 
 	db.functions[fn.ID] = fn
-	return fn, nil
+	return fn
 }
 
 func (db *MemoryDB) GetFunction(_ context.Context, id string) (Function, error) {
@@ -100,6 +109,11 @@ func (db *MemoryDB) UpdateFunction(_ context.Context, id string, updates UpdateF
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	return db.updateFunctionLocked(id, updates)
+}
+
+// updateFunctionLocked applies updates to a function. The caller must hold the write lock.
+func (db *MemoryDB) updateFunctionLocked(id string, updates UpdateFunctionRequest) error {
 	fn, ok := db.functions[id]
 	if !ok {
 		return ErrFunctionNotFound
@@ -123,15 +137,267 @@ func (db *MemoryDB) UpdateFunction(_ context.Context, id string, updates UpdateF
 	if updates.CronStatus != nil {
 		fn.CronStatus = updates.CronStatus
 	}
+	if updates.CronJitter != nil {
+		if *updates.CronJitter > 0 {
+			jitter := *updates.CronJitter
+			fn.CronJitter = &jitter
+		} else {
+			fn.CronJitter = nil
+		}
+	}
+	if updates.CronNoOverlap != nil {
+		fn.CronNoOverlap = *updates.CronNoOverlap
+	}
 	if updates.SaveResponse != nil {
 		fn.SaveResponse = *updates.SaveResponse
 	}
+	if updates.WebSocketEnabled != nil {
+		fn.WebSocketEnabled = *updates.WebSocketEnabled
+	}
+	if updates.CaptureHTTP != nil {
+		fn.CaptureHTTP = *updates.CaptureHTTP
+	}
+	if updates.ReuseState != nil {
+		fn.ReuseState = *updates.ReuseState
+	}
+	if updates.MaxVersions != nil {
+		if *updates.MaxVersions > 0 {
+			maxVersions := *updates.MaxVersions
+			fn.MaxVersions = &maxVersions
+		} else {
+			fn.MaxVersions = nil
+		}
+	}
+	if updates.MaxLogEntries != nil {
+		if *updates.MaxLogEntries > 0 {
+			maxLogEntries := *updates.MaxLogEntries
+			fn.MaxLogEntries = &maxLogEntries
+		} else {
+			fn.MaxLogEntries = nil
+		}
+	}
+	if updates.MaxOutboundCalls != nil {
+		if *updates.MaxOutboundCalls > 0 {
+			maxOutboundCalls := *updates.MaxOutboundCalls
+			fn.MaxOutboundCalls = &maxOutboundCalls
+		} else {
+			fn.MaxOutboundCalls = nil
+		}
+	}
+	if updates.OutboundBudget != nil {
+		if *updates.OutboundBudget > 0 {
+			outboundTimeBudget := *updates.OutboundBudget
+			fn.OutboundBudget = &outboundTimeBudget
+		} else {
+			fn.OutboundBudget = nil
+		}
+	}
+	if updates.CacheTTL != nil {
+		if *updates.CacheTTL > 0 {
+			cacheTTL := *updates.CacheTTL
+			fn.CacheTTL = &cacheTTL
+		} else {
+			fn.CacheTTL = nil
+		}
+	}
+	if updates.CacheVaryHeaders != nil {
+		if len(*updates.CacheVaryHeaders) == 0 {
+			fn.CacheVaryHeaders = nil
+		} else {
+			fn.CacheVaryHeaders = slices.Clone(*updates.CacheVaryHeaders)
+		}
+	}
+	if updates.AIBudgetTokens != nil {
+		if *updates.AIBudgetTokens > 0 {
+			budgetTokens := *updates.AIBudgetTokens
+			fn.AIBudgetTokens = &budgetTokens
+		} else {
+			fn.AIBudgetTokens = nil
+		}
+	}
+	if updates.AIBudgetUSD != nil {
+		if *updates.AIBudgetUSD > 0 {
+			budgetUSD := *updates.AIBudgetUSD
+			fn.AIBudgetUSD = &budgetUSD
+		} else {
+			fn.AIBudgetUSD = nil
+		}
+	}
+	if updates.AIBudgetOverride != nil {
+		fn.AIBudgetOverride = *updates.AIBudgetOverride
+	}
+	if updates.AIDefaultProvider != nil {
+		fn.AIDefaultProvider = optionalString(*updates.AIDefaultProvider)
+	}
+	if updates.AIDefaultModel != nil {
+		fn.AIDefaultModel = optionalString(*updates.AIDefaultModel)
+	}
+	if updates.EmailDefaultFrom != nil {
+		fn.EmailDefaultFrom = optionalString(*updates.EmailDefaultFrom)
+	}
+	if updates.SigningSecret != nil {
+		fn.SigningSecret = optionalString(*updates.SigningSecret)
+	}
+	if updates.CronMisfirePolicy != nil {
+		fn.CronMisfirePolicy = optionalString(*updates.CronMisfirePolicy)
+	}
+	if updates.RoutePrefix != nil {
+		if *updates.RoutePrefix == "" {
+			fn.RoutePrefix = nil
+		} else {
+			for otherID, other := range db.functions {
+				if otherID != id && other.RoutePrefix != nil && *other.RoutePrefix == *updates.RoutePrefix {
+					return ErrRoutePrefixTaken
+				}
+			}
+			routePrefix := *updates.RoutePrefix
+			fn.RoutePrefix = &routePrefix
+		}
+	}
+	if updates.DefaultHeaders != nil {
+		if len(*updates.DefaultHeaders) == 0 {
+			fn.DefaultHeaders = nil
+		} else {
+			fn.DefaultHeaders = maps.Clone(*updates.DefaultHeaders)
+		}
+	}
+	if updates.AllowedMethods != nil {
+		if len(*updates.AllowedMethods) == 0 {
+			fn.AllowedMethods = nil
+		} else {
+			fn.AllowedMethods = slices.Clone(*updates.AllowedMethods)
+		}
+	}
+	if updates.EmailAllowedFrom != nil {
+		if len(*updates.EmailAllowedFrom) == 0 {
+			fn.EmailAllowedFrom = nil
+		} else {
+			fn.EmailAllowedFrom = slices.Clone(*updates.EmailAllowedFrom)
+		}
+	}
+	if updates.DisabledModules != nil {
+		if len(*updates.DisabledModules) == 0 {
+			fn.DisabledModules = nil
+		} else {
+			fn.DisabledModules = slices.Clone(*updates.DisabledModules)
+		}
+	}
+	if updates.Middleware != nil {
+		if len(*updates.Middleware) == 0 {
+			fn.Middleware = nil
+		} else {
+			fn.Middleware = slices.Clone(*updates.Middleware)
+		}
+	}
+	if updates.InputSchema != nil {
+		schema, err := compactSchema(*updates.InputSchema)
+		if err != nil {
+			return err
+		}
+		fn.InputSchema = schema
+	}
+	if updates.OutputSchema != nil {
+		schema, err := compactSchema(*updates.OutputSchema)
+		if err != nil {
+			return err
+		}
+		fn.OutputSchema = schema
+	}
 
 	fn.UpdatedAt = time.Now().Unix()
 	db.functions[id] = fn
 	return nil
 }
 
+func (db *MemoryDB) ApplyBatch(_ context.Context, ops []BatchOperation) ([]FunctionWithActiveVersion, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Snapshot the state so a failed batch can be rolled back
+	functions := maps.Clone(db.functions)
+	versions := make(map[string][]FunctionVersion, len(db.versions))
+	for id, v := range db.versions {
+		versions[id] = slices.Clone(v)
+	}
+
+	results := make([]FunctionWithActiveVersion, 0, len(ops))
+	for i, op := range ops {
+		result, err := db.applyBatchOperationLocked(op)
+		if err != nil {
+			db.functions = functions
+			db.versions = versions
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// applyBatchOperationLocked applies a single batch operation. The caller must hold the write lock.
+func (db *MemoryDB) applyBatchOperationLocked(op BatchOperation) (FunctionWithActiveVersion, error) {
+	id := op.ID
+	if op.Create != nil {
+		id = db.createFunctionLocked(*op.Create).ID
+	} else if op.Reset {
+		fn, ok := db.functions[id]
+		if !ok {
+			return FunctionWithActiveVersion{}, ErrFunctionNotFound
+		}
+		db.functions[id] = Function{
+			ID:                fn.ID,
+			Name:              fn.Name,
+			EnvVars:           fn.EnvVars,
+			CronLastRunAt:     fn.CronLastRunAt,
+			CronLastSuccessAt: fn.CronLastSuccessAt,
+			CreatedAt:         fn.CreatedAt,
+			UpdatedAt:         time.Now().Unix(),
+		}
+	}
+
+	activeID := ""
+	for _, v := range op.Versions {
+		version, err := db.createVersionLocked(id, v.Code, v.CreatedBy, v.Label)
+		if err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+		if v.IsActive {
+			activeID = version.ID
+		}
+	}
+	if activeID != "" {
+		if err := db.activateVersionLocked(activeID); err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+	}
+
+	if op.Changes.Code != nil {
+		if _, err := db.createVersionLocked(id, *op.Changes.Code, nil, op.Changes.VersionLabel); err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+	}
+
+	if op.Changes.HasMetadata() {
+		if err := db.updateFunctionLocked(id, op.Changes); err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+	}
+
+	fn, ok := db.functions[id]
+	if !ok {
+		return FunctionWithActiveVersion{}, ErrFunctionNotFound
+	}
+
+	result := FunctionWithActiveVersion{Function: fn}
+	for _, v := range db.versions[id] {
+		if v.IsActive {
+			result.ActiveVersion = v
+		}
+	}
+
+	return result, nil
+}
+
 func (db *MemoryDB) DeleteFunction(_ context.Context, id string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -147,16 +413,25 @@ func (db *MemoryDB) DeleteFunction(_ context.Context, id string) error {
 
 // Version operations
 
-func (db *MemoryDB) CreateVersion(_ context.Context, functionID string, code string, createdBy *string) (FunctionVersion, error) {
+func (db *MemoryDB) CreateVersion(_ context.Context, functionID string, code string, createdBy *string, label *string) (FunctionVersion, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if _, ok := db.functions[functionID]; !ok {
+	return db.createVersionLocked(functionID, code, createdBy, label)
+}
+
+// createVersionLocked stores a new active version. The caller must hold the write lock.
+func (db *MemoryDB) createVersionLocked(functionID string, code string, createdBy *string, label *string) (FunctionVersion, error) {
+	fn, ok := db.functions[functionID]
+	if !ok {
 		return FunctionVersion{}, ErrFunctionNotFound
 	}
 
 	versions := db.versions[functionID]
-	versionNum := len(versions) + 1
+	versionNum := 1
+	if len(versions) > 0 {
+		versionNum = versions[len(versions)-1].Version + 1
+	}
 
 	// Deactivate all previous versions
 	for i := range versions {
@@ -170,12 +445,18 @@ func (db *MemoryDB) CreateVersion(_ context.Context, functionID string, code str
 		Code:       code,
 		CreatedAt:  time.Now().Unix(),
 		CreatedBy:  createdBy,
+		Label:      normalizeLabel(label),
 		IsActive:   true,
 	}
 
 	versions = append(versions, version)
 	db.versions[functionID] = versions
 
+	// Prune old versions beyond the function's limit
+	if fn.MaxVersions != nil && *fn.MaxVersions > 0 {
+		db.deleteOldVersionsLocked(functionID, *fn.MaxVersions)
+	}
+
 	return version, nil
 }
 
@@ -260,6 +541,11 @@ func (db *MemoryDB) ActivateVersion(_ context.Context, versionID string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	return db.activateVersionLocked(versionID)
+}
+
+// activateVersionLocked makes versionID the only active version of its function. The caller must hold the write lock.
+func (db *MemoryDB) activateVersionLocked(versionID string) error {
 	// Find the version by ID across all functions
 	var targetFunctionID string
 	var targetIdx = -1
@@ -291,6 +577,22 @@ func (db *MemoryDB) ActivateVersion(_ context.Context, versionID string) error {
 	return nil
 }
 
+func (db *MemoryDB) UpdateVersionLabel(_ context.Context, versionID string, label *string) (FunctionVersion, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, versions := range db.versions {
+		for i := range versions {
+			if versions[i].ID == versionID {
+				versions[i].Label = normalizeLabel(label)
+				return versions[i], nil
+			}
+		}
+	}
+
+	return FunctionVersion{}, ErrVersionNotFound
+}
+
 func (db *MemoryDB) DeleteVersion(_ context.Context, versionID string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -326,6 +628,33 @@ func (db *MemoryDB) DeleteVersion(_ context.Context, versionID string) error {
 	return nil
 }
 
+func (db *MemoryDB) DeleteOldVersions(_ context.Context, functionID string, keep int) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.deleteOldVersionsLocked(functionID, keep), nil
+}
+
+// deleteOldVersionsLocked removes inactive versions that are not among the
+// newest keep versions. The caller must hold the write lock.
+func (db *MemoryDB) deleteOldVersionsLocked(functionID string, keep int) int64 {
+	versions := db.versions[functionID]
+	cutoff := len(versions) - max(keep, 0)
+
+	var deleted int64
+	retained := make([]FunctionVersion, 0, len(versions))
+	for i, v := range versions {
+		if i < cutoff && !v.IsActive {
+			deleted++
+			continue
+		}
+		retained = append(retained, v)
+	}
+
+	db.versions[functionID] = retained
+	return deleted
+}
+
 // Execution operations
 
 func (db *MemoryDB) CreateExecution(_ context.Context, exec Execution) (Execution, error) {
@@ -355,7 +684,42 @@ func (db *MemoryDB) GetExecution(_ context.Context, executionID string) (Executi
 	return exec, nil
 }
 
-func (db *MemoryDB) UpdateExecution(_ context.Context, executionID string, status ExecutionStatus, durationMs *int64, errorMsg *string, responseJSON *string) error {
+func (db *MemoryDB) UpdateExecution(_ context.Context, executionID string, update ExecutionUpdate) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	exec, ok := db.executions[executionID]
+	if !ok {
+		return ErrExecutionNotFound
+	}
+
+	exec.Status = update.Status
+	exec.DurationMs = update.DurationMs
+	exec.ErrorMessage = update.ErrorMessage
+	exec.ResponseJSON = update.ResponseJSON
+	exec.ResponseBytes = update.ResponseBytes
+	exec.CostUSD = update.CostUSD
+	db.executions[executionID] = exec
+
+	return nil
+}
+
+func (db *MemoryDB) SetExecutionTags(_ context.Context, executionID string, tags map[string]string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	exec, ok := db.executions[executionID]
+	if !ok {
+		return ErrExecutionNotFound
+	}
+
+	exec.Tags = maps.Clone(tags)
+	db.executions[executionID] = exec
+
+	return nil
+}
+
+func (db *MemoryDB) SetExecutionSpans(_ context.Context, executionID string, spans []Span) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -364,16 +728,17 @@ func (db *MemoryDB) UpdateExecution(_ context.Context, executionID string, statu
 		return ErrExecutionNotFound
 	}
 
-	exec.Status = status
-	exec.DurationMs = durationMs
-	exec.ErrorMessage = errorMsg
-	exec.ResponseJSON = responseJSON
+	exec.Spans = slices.Clone(spans)
 	db.executions[executionID] = exec
 
 	return nil
 }
 
-func (db *MemoryDB) ListExecutions(_ context.Context, functionID string, params PaginationParams) ([]Execution, int64, error) {
+func (db *MemoryDB) ListExecutions(ctx context.Context, functionID string, params PaginationParams) ([]Execution, int64, error) {
+	return db.ListExecutionsFiltered(ctx, functionID, ExecutionFilter{}, params)
+}
+
+func (db *MemoryDB) ListExecutionsFiltered(_ context.Context, functionID string, filter ExecutionFilter, params PaginationParams) ([]Execution, int64, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
@@ -382,7 +747,8 @@ func (db *MemoryDB) ListExecutions(_ context.Context, functionID string, params
 
 	var allExecutions []Execution
 	for _, exec := range db.executions {
-		if exec.FunctionID == functionID {
+		if exec.FunctionID == functionID && filter.matches(exec) {
+			exec.Spans = nil // Only returned by GetExecution
 			allExecutions = append(allExecutions, exec)
 		}
 	}
@@ -400,6 +766,29 @@ func (db *MemoryDB) ListExecutions(_ context.Context, functionID string, params
 	return allExecutions[start:end], total, nil
 }
 
+func (db *MemoryDB) EachExecution(_ context.Context, functionID string, filter ExecutionFilter, fn func(Execution) error) error {
+	// Collect under the lock, fn may call back into the store
+	db.mu.RLock()
+	var executions []Execution
+	for _, exec := range db.executions {
+		if exec.FunctionID == functionID && filter.matches(exec) {
+			exec.Spans = nil // Only returned by GetExecution
+			executions = append(executions, exec)
+		}
+	}
+	db.mu.RUnlock()
+
+	slices.SortFunc(executions, func(a, b Execution) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+	for _, exec := range executions {
+		if err := fn(exec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *MemoryDB) DeleteOldExecutions(_ context.Context, beforeTimestamp int64) (int64, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -415,6 +804,94 @@ func (db *MemoryDB) DeleteOldExecutions(_ context.Context, beforeTimestamp int64
 	return deletedCount, nil
 }
 
+func (db *MemoryDB) ListRecentErrors(_ context.Context, since int64, params PaginationParams) ([]FunctionExecution, int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	params = params.Normalize()
+
+	var all []FunctionExecution
+	for _, exec := range db.executions {
+		fn, ok := db.functions[exec.FunctionID]
+		if !ok || exec.Status != ExecutionStatusError || exec.CreatedAt < since {
+			continue
+		}
+		exec.EventJSON = nil
+		exec.ResponseJSON = nil
+		all = append(all, FunctionExecution{Execution: exec, FunctionName: fn.Name})
+	}
+	slices.SortFunc(all, func(a, b FunctionExecution) int {
+		if a.CreatedAt != b.CreatedAt {
+			return cmp.Compare(b.CreatedAt, a.CreatedAt)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	total := int64(len(all))
+	start := min(params.Offset, len(all))
+	end := min(start+params.Limit, len(all))
+	return slices.Clip(all[start:end]), total, nil
+}
+
+func (db *MemoryDB) ExecutionTimeseries(_ context.Context, functionID string, since, until, bucketSize int64) ([]ExecutionBucket, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	found := make(map[int64]ExecutionBucket)
+	durations := make(map[int64][]int64)
+	for _, exec := range db.executions {
+		if exec.FunctionID != functionID || exec.CreatedAt < since || exec.CreatedAt >= until ||
+			exec.Status == ExecutionStatusSkipped {
+			continue
+		}
+		start := since + (exec.CreatedAt-since)/bucketSize*bucketSize
+		bucket := found[start]
+		bucket.Start = start
+		bucket.Count++
+		if exec.Status == ExecutionStatusError {
+			bucket.Errors++
+		}
+		if exec.RequestBytes != nil {
+			bucket.RequestBytes += *exec.RequestBytes
+			bucket.MaxRequestBytes = max(bucket.MaxRequestBytes, *exec.RequestBytes)
+		}
+		if exec.ResponseBytes != nil {
+			bucket.ResponseBytes += *exec.ResponseBytes
+			bucket.MaxResponseBytes = max(bucket.MaxResponseBytes, *exec.ResponseBytes)
+		}
+		if exec.CostUSD != nil {
+			bucket.CostUSD += *exec.CostUSD
+		}
+		found[start] = bucket
+		if exec.DurationMs != nil {
+			durations[start] = append(durations[start], *exec.DurationMs)
+		}
+	}
+
+	for start, values := range durations {
+		slices.Sort(values)
+		var sum int64
+		for _, v := range values {
+			sum += v
+		}
+		avg := float64(sum) / float64(len(values))
+		// Nearest rank, matching the SQLite implementation
+		rank := func(p int) *int64 {
+			v := values[(len(values)*p+99)/100-1]
+			return &v
+		}
+
+		bucket := found[start]
+		bucket.AvgDurationMs = &avg
+		bucket.P50DurationMs = rank(50)
+		bucket.P95DurationMs = rank(95)
+		bucket.P99DurationMs = rank(99)
+		found[start] = bucket
+	}
+
+	return fillBuckets(since, until, bucketSize, found), nil
+}
+
 func (db *MemoryDB) ListFunctionsWithActiveCron(_ context.Context) ([]Function, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
@@ -430,8 +907,56 @@ func (db *MemoryDB) ListFunctionsWithActiveCron(_ context.Context) ([]Function,
 	return functions, nil
 }
 
+func (db *MemoryDB) SetCronLastRun(_ context.Context, functionID string, scheduledAt int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	fn, ok := db.functions[functionID]
+	if !ok {
+		return ErrFunctionNotFound
+	}
+	fn.CronLastRunAt = &scheduledAt
+	db.functions[functionID] = fn
+	return nil
+}
+
+func (db *MemoryDB) SetCronLastSuccess(_ context.Context, functionID string, scheduledAt int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	fn, ok := db.functions[functionID]
+	if !ok {
+		return ErrFunctionNotFound
+	}
+	fn.CronLastSuccessAt = &scheduledAt
+	db.functions[functionID] = fn
+	return nil
+}
+
+func (db *MemoryDB) ListFunctionsWithRoutePrefix(_ context.Context) ([]Function, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var functions []Function
+	for _, fn := range db.functions {
+		if fn.RoutePrefix != nil && *fn.RoutePrefix != "" {
+			functions = append(functions, fn)
+		}
+	}
+
+	return functions, nil
+}
+
 // Health check
 
 func (db *MemoryDB) Ping(_ context.Context) error {
 	return nil
 }
+
+// optionalString returns nil for an empty string, which clears an optional field
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
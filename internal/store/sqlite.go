@@ -3,8 +3,11 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -15,14 +18,235 @@ type SQLiteDB struct {
 	db *sql.DB
 }
 
+// querier is implemented by *sql.DB and *sql.Tx, so helpers can run inside
+// or outside a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 // NewSQLiteDB creates a new SQLite-backed API database
 func NewSQLiteDB(db *sql.DB) *SQLiteDB {
 	return &SQLiteDB{db: db}
 }
 
+// Compile-time check that SQLiteDB implements Backuper
+var _ Backuper = (*SQLiteDB)(nil)
+
+// Backup copies the database to path with VACUUM INTO. The copy is a
+// compacted snapshot taken inside a read transaction, so other connections
+// keep reading meanwhile and writes wait only for the copy itself.
+func (db *SQLiteDB) Backup(ctx context.Context, path string) error {
+	if _, err := db.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// functionColumnNames lists the functions table columns in the order scanned by functionRow
+var functionColumnNames = []string{
+	"id", "name", "description", "disabled", "retention_days", "cron_schedule", "cron_status",
+	"save_response", "allowed_methods", "max_versions", "default_headers", "route_prefix", "websocket_enabled", "capture_http",
+	"ai_budget_tokens", "ai_budget_usd", "ai_budget_override",
+	"ai_default_provider", "ai_default_model", "email_default_from", "email_allowed_from",
+	"disabled_modules", "signing_secret", "cron_jitter", "cron_no_overlap",
+	"cron_misfire_policy", "cron_last_run_at", "cron_last_success_at", "reuse_state", "max_log_entries", "middleware",
+	"input_schema", "output_schema", "cache_ttl", "cache_vary_headers", "max_outbound_calls", "outbound_time_budget", "created_at", "updated_at",
+}
+
+// functionColumns returns the function columns as a select list, optionally qualified by a table alias
+func functionColumns(alias string) string {
+	if alias == "" {
+		return strings.Join(functionColumnNames, ", ")
+	}
+	qualified := make([]string, len(functionColumnNames))
+	for i, name := range functionColumnNames {
+		qualified[i] = alias + "." + name
+	}
+	return strings.Join(qualified, ", ")
+}
+
+// functionRow holds the scan destinations for a functions table row
+type functionRow struct {
+	fn             Function
+	description    sql.NullString
+	retentionDays  sql.NullInt64
+	cronSchedule   sql.NullString
+	cronStatus     sql.NullString
+	saveResponse   sql.NullBool
+	allowedMethods sql.NullString
+	maxVersions    sql.NullInt64
+	defaultHeaders sql.NullString
+	routePrefix    sql.NullString
+	websocket      sql.NullBool
+	captureHTTP    sql.NullBool
+	aiBudgetTokens sql.NullInt64
+	aiBudgetUSD    sql.NullFloat64
+	aiOverride     sql.NullBool
+	aiProvider     sql.NullString
+	aiModel        sql.NullString
+	emailFrom      sql.NullString
+	emailAllowed   sql.NullString
+	disabledMods   sql.NullString
+	signingSecret  sql.NullString
+	cronJitter     sql.NullInt64
+	cronNoOverlap  sql.NullBool
+	cronMisfire    sql.NullString
+	cronLastRunAt  sql.NullInt64
+	cronLastOK     sql.NullInt64
+	reuseState     sql.NullBool
+	maxLogEntries  sql.NullInt64
+	middleware     sql.NullString
+	inputSchema    sql.NullString
+	outputSchema   sql.NullString
+	cacheTTL       sql.NullInt64
+	cacheVary      sql.NullString
+	maxOutbound    sql.NullInt64
+	outboundTime   sql.NullInt64
+}
+
+// dest returns the scan destinations matching functionColumnNames
+func (r *functionRow) dest() []any {
+	return []any{
+		&r.fn.ID, &r.fn.Name, &r.description, &r.fn.Disabled, &r.retentionDays, &r.cronSchedule, &r.cronStatus,
+		&r.saveResponse, &r.allowedMethods, &r.maxVersions, &r.defaultHeaders, &r.routePrefix, &r.websocket, &r.captureHTTP,
+		&r.aiBudgetTokens, &r.aiBudgetUSD, &r.aiOverride,
+		&r.aiProvider, &r.aiModel, &r.emailFrom, &r.emailAllowed,
+		&r.disabledMods, &r.signingSecret, &r.cronJitter, &r.cronNoOverlap,
+		&r.cronMisfire, &r.cronLastRunAt, &r.cronLastOK, &r.reuseState, &r.maxLogEntries, &r.middleware,
+		&r.inputSchema, &r.outputSchema, &r.cacheTTL, &r.cacheVary, &r.maxOutbound, &r.outboundTime, &r.fn.CreatedAt, &r.fn.UpdatedAt,
+	}
+}
+
+// function converts the scanned row into a Function.
+// Malformed JSON columns are ignored rather than failing the whole query.
+func (r *functionRow) function() Function {
+	fn := r.fn
+
+	if r.description.Valid {
+		fn.Description = &r.description.String
+	}
+	if r.retentionDays.Valid {
+		days := int(r.retentionDays.Int64)
+		fn.RetentionDays = &days
+	}
+	if r.cronSchedule.Valid {
+		fn.CronSchedule = &r.cronSchedule.String
+	}
+	if r.cronStatus.Valid {
+		fn.CronStatus = &r.cronStatus.String
+	}
+	if r.saveResponse.Valid {
+		fn.SaveResponse = r.saveResponse.Bool
+	}
+	if r.allowedMethods.Valid && r.allowedMethods.String != "" {
+		fn.AllowedMethods = strings.Split(r.allowedMethods.String, ",")
+	}
+	if r.maxVersions.Valid {
+		maxVersions := int(r.maxVersions.Int64)
+		fn.MaxVersions = &maxVersions
+	}
+	if r.defaultHeaders.Valid && r.defaultHeaders.String != "" {
+		_ = json.Unmarshal([]byte(r.defaultHeaders.String), &fn.DefaultHeaders)
+	}
+	if r.routePrefix.Valid {
+		fn.RoutePrefix = &r.routePrefix.String
+	}
+	if r.websocket.Valid {
+		fn.WebSocketEnabled = r.websocket.Bool
+	}
+	if r.captureHTTP.Valid {
+		fn.CaptureHTTP = r.captureHTTP.Bool
+	}
+	if r.aiBudgetTokens.Valid {
+		fn.AIBudgetTokens = &r.aiBudgetTokens.Int64
+	}
+	if r.aiBudgetUSD.Valid {
+		fn.AIBudgetUSD = &r.aiBudgetUSD.Float64
+	}
+	if r.aiOverride.Valid {
+		fn.AIBudgetOverride = r.aiOverride.Bool
+	}
+	if r.aiProvider.Valid {
+		fn.AIDefaultProvider = &r.aiProvider.String
+	}
+	if r.aiModel.Valid {
+		fn.AIDefaultModel = &r.aiModel.String
+	}
+	if r.emailFrom.Valid {
+		fn.EmailDefaultFrom = &r.emailFrom.String
+	}
+	if r.emailAllowed.Valid && r.emailAllowed.String != "" {
+		fn.EmailAllowedFrom = strings.Split(r.emailAllowed.String, ",")
+	}
+	if r.disabledMods.Valid && r.disabledMods.String != "" {
+		fn.DisabledModules = strings.Split(r.disabledMods.String, ",")
+	}
+	if r.middleware.Valid && r.middleware.String != "" {
+		fn.Middleware = strings.Split(r.middleware.String, ",")
+	}
+	if r.signingSecret.Valid {
+		fn.SigningSecret = &r.signingSecret.String
+	}
+	if r.cronJitter.Valid {
+		jitter := int(r.cronJitter.Int64)
+		fn.CronJitter = &jitter
+	}
+	if r.cronNoOverlap.Valid {
+		fn.CronNoOverlap = r.cronNoOverlap.Bool
+	}
+	if r.cronMisfire.Valid {
+		fn.CronMisfirePolicy = &r.cronMisfire.String
+	}
+	if r.cronLastRunAt.Valid {
+		fn.CronLastRunAt = &r.cronLastRunAt.Int64
+	}
+	if r.cronLastOK.Valid {
+		fn.CronLastSuccessAt = &r.cronLastOK.Int64
+	}
+	if r.reuseState.Valid {
+		fn.ReuseState = r.reuseState.Bool
+	}
+	if r.maxLogEntries.Valid {
+		maxLogEntries := int(r.maxLogEntries.Int64)
+		fn.MaxLogEntries = &maxLogEntries
+	}
+	if r.cacheTTL.Valid {
+		cacheTTL := int(r.cacheTTL.Int64)
+		fn.CacheTTL = &cacheTTL
+	}
+	if r.maxOutbound.Valid {
+		maxOutboundCalls := int(r.maxOutbound.Int64)
+		fn.MaxOutboundCalls = &maxOutboundCalls
+	}
+	if r.outboundTime.Valid {
+		outboundTimeBudget := int(r.outboundTime.Int64)
+		fn.OutboundBudget = &outboundTimeBudget
+	}
+	if r.cacheVary.Valid && r.cacheVary.String != "" {
+		fn.CacheVaryHeaders = strings.Split(r.cacheVary.String, ",")
+	}
+	if r.inputSchema.Valid && r.inputSchema.String != "" {
+		fn.InputSchema = json.RawMessage(r.inputSchema.String)
+	}
+	if r.outputSchema.Valid && r.outputSchema.String != "" {
+		fn.OutputSchema = json.RawMessage(r.outputSchema.String)
+	}
+
+	fn.EnvVars = make(map[string]string)
+
+	return fn
+}
+
 // Function operations
 
 func (db *SQLiteDB) CreateFunction(ctx context.Context, fn Function) (Function, error) {
+	return createFunction(ctx, db.db, fn)
+}
+
+// createFunction inserts a function using q
+func createFunction(ctx context.Context, q querier, fn Function) (Function, error) {
 	fn.CreatedAt = time.Now().Unix()
 	fn.UpdatedAt = fn.CreatedAt
 
@@ -30,10 +254,10 @@ func (db *SQLiteDB) CreateFunction(ctx context.Context, fn Function) (Function,
 		fn.EnvVars = make(map[string]string)
 	}
 
-	query := `INSERT INTO functions (id, name, description, disabled, created_at, updated_at)
-	          VALUES (?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO functions (id, name, description, disabled, retention_days, save_response, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := db.db.ExecContext(ctx, query, fn.ID, fn.Name, fn.Description, fn.Disabled, fn.CreatedAt, fn.UpdatedAt)
+	_, err := q.ExecContext(ctx, query, fn.ID, fn.Name, fn.Description, fn.Disabled, fn.RetentionDays, fn.SaveResponse, fn.CreatedAt, fn.UpdatedAt)
 	if err != nil {
 		return Function{}, fmt.Errorf("failed to insert function: %w", err)
 	}
@@ -42,19 +266,16 @@ func (db *SQLiteDB) CreateFunction(ctx context.Context, fn Function) (Function,
 }
 
 func (db *SQLiteDB) GetFunction(ctx context.Context, id string) (Function, error) {
-	query := `SELECT id, name, description, disabled, retention_days, cron_schedule, cron_status, save_response, created_at, updated_at
-	          FROM functions WHERE id = ?`
+	return getFunction(ctx, db.db, id)
+}
 
-	var fn Function
-	var description sql.NullString
-	var retentionDays sql.NullInt64
-	var cronSchedule sql.NullString
-	var cronStatus sql.NullString
-	var saveResponse sql.NullBool
+// getFunction reads a function using q
+func getFunction(ctx context.Context, q querier, id string) (Function, error) {
+	query := `SELECT ` + functionColumns("") + `
+	          FROM functions WHERE id = ?`
 
-	err := db.db.QueryRowContext(ctx, query, id).Scan(
-		&fn.ID, &fn.Name, &description, &fn.Disabled, &retentionDays, &cronSchedule, &cronStatus, &saveResponse, &fn.CreatedAt, &fn.UpdatedAt,
-	)
+	var row functionRow
+	err := q.QueryRowContext(ctx, query, id).Scan(row.dest()...)
 	if errors.Is(err, sql.ErrNoRows) {
 		return Function{}, ErrFunctionNotFound
 	}
@@ -62,26 +283,7 @@ func (db *SQLiteDB) GetFunction(ctx context.Context, id string) (Function, error
 		return Function{}, fmt.Errorf("failed to query function: %w", err)
 	}
 
-	if description.Valid {
-		fn.Description = &description.String
-	}
-	if retentionDays.Valid {
-		days := int(retentionDays.Int64)
-		fn.RetentionDays = &days
-	}
-	if cronSchedule.Valid {
-		fn.CronSchedule = &cronSchedule.String
-	}
-	if cronStatus.Valid {
-		fn.CronStatus = &cronStatus.String
-	}
-	if saveResponse.Valid {
-		fn.SaveResponse = saveResponse.Bool
-	}
-
-	fn.EnvVars = make(map[string]string)
-
-	return fn, nil
+	return row.function(), nil
 }
 
 func (db *SQLiteDB) ListFunctions(ctx context.Context, params PaginationParams) ([]FunctionWithActiveVersion, int64, error) {
@@ -96,8 +298,8 @@ func (db *SQLiteDB) ListFunctions(ctx context.Context, params PaginationParams)
 	params = params.Normalize()
 
 	query := `SELECT
-		f.id, f.name, f.description, f.disabled, f.retention_days, f.cron_schedule, f.cron_status, f.save_response, f.created_at, f.updated_at,
-		fv.id, fv.version, fv.code, fv.created_at, fv.created_by
+		` + functionColumns("f") + `,
+		fv.id, fv.version, fv.code, fv.created_at, fv.created_by, fv.label
 	FROM functions f
 	LEFT JOIN function_versions fv ON f.id = fv.function_id AND fv.is_active = 1
 	ORDER BY f.created_at DESC
@@ -111,42 +313,18 @@ func (db *SQLiteDB) ListFunctions(ctx context.Context, params PaginationParams)
 
 	var functions []FunctionWithActiveVersion
 	for rows.Next() {
-		var fn FunctionWithActiveVersion
-		var description sql.NullString
-		var retentionDays sql.NullInt64
-		var cronSchedule sql.NullString
-		var cronStatus sql.NullString
-		var saveResponse sql.NullBool
+		var row functionRow
 		var versionID, versionCode sql.NullString
 		var versionNum sql.NullInt64
 		var versionCreatedAt sql.NullInt64
-		var versionCreatedBy sql.NullString
+		var versionCreatedBy, versionLabel sql.NullString
 
-		if err := rows.Scan(
-			&fn.ID, &fn.Name, &description, &fn.Disabled, &retentionDays, &cronSchedule, &cronStatus, &saveResponse, &fn.CreatedAt, &fn.UpdatedAt,
-			&versionID, &versionNum, &versionCode, &versionCreatedAt, &versionCreatedBy,
-		); err != nil {
+		dest := append(row.dest(), &versionID, &versionNum, &versionCode, &versionCreatedAt, &versionCreatedBy, &versionLabel)
+		if err := rows.Scan(dest...); err != nil {
 			return nil, 0, fmt.Errorf("failed to scan function: %w", err)
 		}
 
-		if description.Valid {
-			fn.Description = &description.String
-		}
-		if retentionDays.Valid {
-			days := int(retentionDays.Int64)
-			fn.RetentionDays = &days
-		}
-		if cronSchedule.Valid {
-			fn.CronSchedule = &cronSchedule.String
-		}
-		if cronStatus.Valid {
-			fn.CronStatus = &cronStatus.String
-		}
-		if saveResponse.Valid {
-			fn.SaveResponse = saveResponse.Bool
-		}
-
-		fn.EnvVars = make(map[string]string)
+		fn := FunctionWithActiveVersion{Function: row.function()}
 
 		// Set the active version if it exists
 		if versionID.Valid {
@@ -161,6 +339,9 @@ func (db *SQLiteDB) ListFunctions(ctx context.Context, params PaginationParams)
 			if versionCreatedBy.Valid {
 				fn.ActiveVersion.CreatedBy = &versionCreatedBy.String
 			}
+			if versionLabel.Valid {
+				fn.ActiveVersion.Label = &versionLabel.String
+			}
 		}
 
 		functions = append(functions, fn)
@@ -176,9 +357,18 @@ func (db *SQLiteDB) UpdateFunction(ctx context.Context, id string, updates Updat
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	if err := updateFunction(ctx, tx, id, updates); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateFunction applies updates to a function within tx
+func updateFunction(ctx context.Context, tx *sql.Tx, id string, updates UpdateFunctionRequest) error {
 	// Check if function exists
 	var exists bool
-	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM functions WHERE id = ?)", id).Scan(&exists)
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM functions WHERE id = ?)", id).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("failed to check function existence: %w", err)
 	}
@@ -234,6 +424,35 @@ func (db *SQLiteDB) UpdateFunction(ctx context.Context, id string, updates Updat
 		}
 	}
 
+	if updates.CronJitter != nil {
+		// Zero clears the jitter
+		var jitter *int
+		if *updates.CronJitter > 0 {
+			jitter = updates.CronJitter
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET cron_jitter = ?, updated_at = ? WHERE id = ?",
+			jitter, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update cron jitter: %w", err)
+		}
+	}
+
+	if updates.CronNoOverlap != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET cron_no_overlap = ?, updated_at = ? WHERE id = ?",
+			*updates.CronNoOverlap, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update cron_no_overlap: %w", err)
+		}
+	}
+
+	if updates.ReuseState != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET reuse_state = ?, updated_at = ? WHERE id = ?",
+			*updates.ReuseState, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update reuse_state: %w", err)
+		}
+	}
+
 	if updates.SaveResponse != nil {
 		_, err = tx.ExecContext(ctx, "UPDATE functions SET save_response = ?, updated_at = ? WHERE id = ?",
 			*updates.SaveResponse, time.Now().Unix(), id)
@@ -242,7 +461,356 @@ func (db *SQLiteDB) UpdateFunction(ctx context.Context, id string, updates Updat
 		}
 	}
 
-	return tx.Commit()
+	if updates.AllowedMethods != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET allowed_methods = ?, updated_at = ? WHERE id = ?",
+			strings.Join(*updates.AllowedMethods, ","), time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update allowed methods: %w", err)
+		}
+	}
+
+	if updates.EmailAllowedFrom != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET email_allowed_from = ?, updated_at = ? WHERE id = ?",
+			strings.Join(*updates.EmailAllowedFrom, ","), time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update email allowed from: %w", err)
+		}
+	}
+
+	if updates.DisabledModules != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET disabled_modules = ?, updated_at = ? WHERE id = ?",
+			strings.Join(*updates.DisabledModules, ","), time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update disabled modules: %w", err)
+		}
+	}
+
+	if updates.Middleware != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET middleware = ?, updated_at = ? WHERE id = ?",
+			strings.Join(*updates.Middleware, ","), time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update middleware: %w", err)
+		}
+	}
+
+	if updates.MaxVersions != nil {
+		// Zero clears the limit
+		var maxVersions *int
+		if *updates.MaxVersions > 0 {
+			maxVersions = updates.MaxVersions
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET max_versions = ?, updated_at = ? WHERE id = ?",
+			maxVersions, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update max versions: %w", err)
+		}
+	}
+
+	if updates.MaxLogEntries != nil {
+		// Zero clears the limit
+		var maxLogEntries *int
+		if *updates.MaxLogEntries > 0 {
+			maxLogEntries = updates.MaxLogEntries
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET max_log_entries = ?, updated_at = ? WHERE id = ?",
+			maxLogEntries, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update max log entries: %w", err)
+		}
+	}
+
+	if updates.MaxOutboundCalls != nil {
+		// Zero clears the limit
+		var maxOutboundCalls *int
+		if *updates.MaxOutboundCalls > 0 {
+			maxOutboundCalls = updates.MaxOutboundCalls
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET max_outbound_calls = ?, updated_at = ? WHERE id = ?",
+			maxOutboundCalls, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update max outbound calls: %w", err)
+		}
+	}
+
+	if updates.OutboundBudget != nil {
+		// Zero clears the budget
+		var outboundTimeBudget *int
+		if *updates.OutboundBudget > 0 {
+			outboundTimeBudget = updates.OutboundBudget
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET outbound_time_budget = ?, updated_at = ? WHERE id = ?",
+			outboundTimeBudget, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update outbound time budget: %w", err)
+		}
+	}
+
+	if updates.CacheTTL != nil {
+		// Zero disables the cache
+		var cacheTTL *int
+		if *updates.CacheTTL > 0 {
+			cacheTTL = updates.CacheTTL
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET cache_ttl = ?, updated_at = ? WHERE id = ?",
+			cacheTTL, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update cache ttl: %w", err)
+		}
+	}
+
+	if updates.CacheVaryHeaders != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET cache_vary_headers = ?, updated_at = ? WHERE id = ?",
+			strings.Join(*updates.CacheVaryHeaders, ","), time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update cache vary headers: %w", err)
+		}
+	}
+
+	if updates.AIBudgetTokens != nil {
+		// Zero clears the budget
+		var budgetTokens *int64
+		if *updates.AIBudgetTokens > 0 {
+			budgetTokens = updates.AIBudgetTokens
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET ai_budget_tokens = ?, updated_at = ? WHERE id = ?",
+			budgetTokens, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update AI token budget: %w", err)
+		}
+	}
+
+	if updates.AIBudgetUSD != nil {
+		// Zero clears the budget
+		var budgetUSD *float64
+		if *updates.AIBudgetUSD > 0 {
+			budgetUSD = updates.AIBudgetUSD
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET ai_budget_usd = ?, updated_at = ? WHERE id = ?",
+			budgetUSD, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update AI cost budget: %w", err)
+		}
+	}
+
+	if updates.AIBudgetOverride != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET ai_budget_override = ?, updated_at = ? WHERE id = ?",
+			*updates.AIBudgetOverride, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update AI budget override: %w", err)
+		}
+	}
+
+	// Empty strings clear the defaults, the signing secret and the misfire policy
+	defaults := []struct {
+		column string
+		value  *string
+	}{
+		{"ai_default_provider", updates.AIDefaultProvider},
+		{"ai_default_model", updates.AIDefaultModel},
+		{"email_default_from", updates.EmailDefaultFrom},
+		{"signing_secret", updates.SigningSecret},
+		{"cron_misfire_policy", updates.CronMisfirePolicy},
+	}
+	for _, d := range defaults {
+		if d.value == nil {
+			continue
+		}
+		var value *string
+		if *d.value != "" {
+			value = d.value
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET "+d.column+" = ?, updated_at = ? WHERE id = ?",
+			value, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", d.column, err)
+		}
+	}
+
+	if updates.DefaultHeaders != nil {
+		// An empty map clears the default headers
+		var defaultHeaders *string
+		if len(*updates.DefaultHeaders) > 0 {
+			headersJSON, err := json.Marshal(*updates.DefaultHeaders)
+			if err != nil {
+				return fmt.Errorf("failed to encode default headers: %w", err)
+			}
+			headersStr := string(headersJSON)
+			defaultHeaders = &headersStr
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET default_headers = ?, updated_at = ? WHERE id = ?",
+			defaultHeaders, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update default headers: %w", err)
+		}
+	}
+
+	// Empty schemas remove them
+	schemas := []struct {
+		column string
+		value  *json.RawMessage
+	}{
+		{"input_schema", updates.InputSchema},
+		{"output_schema", updates.OutputSchema},
+	}
+	for _, s := range schemas {
+		if s.value == nil {
+			continue
+		}
+		schema, err := compactSchema(*s.value)
+		if err != nil {
+			return err
+		}
+		var value *string
+		if schema != nil {
+			schemaStr := string(schema)
+			value = &schemaStr
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET "+s.column+" = ?, updated_at = ? WHERE id = ?",
+			value, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", s.column, err)
+		}
+	}
+
+	if updates.RoutePrefix != nil {
+		// An empty prefix removes the route
+		var routePrefix *string
+		if *updates.RoutePrefix != "" {
+			var taken bool
+			err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM functions WHERE route_prefix = ? AND id != ?)",
+				*updates.RoutePrefix, id).Scan(&taken)
+			if err != nil {
+				return fmt.Errorf("failed to check route prefix: %w", err)
+			}
+			if taken {
+				return ErrRoutePrefixTaken
+			}
+			routePrefix = updates.RoutePrefix
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET route_prefix = ?, updated_at = ? WHERE id = ?",
+			routePrefix, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update route prefix: %w", err)
+		}
+	}
+
+	if updates.WebSocketEnabled != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET websocket_enabled = ?, updated_at = ? WHERE id = ?",
+			*updates.WebSocketEnabled, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update websocket_enabled: %w", err)
+		}
+	}
+
+	if updates.CaptureHTTP != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE functions SET capture_http = ?, updated_at = ? WHERE id = ?",
+			*updates.CaptureHTTP, time.Now().Unix(), id)
+		if err != nil {
+			return fmt.Errorf("failed to update capture_http: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) ApplyBatch(ctx context.Context, ops []BatchOperation) ([]FunctionWithActiveVersion, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]FunctionWithActiveVersion, 0, len(ops))
+	for i, op := range ops {
+		result, err := applyBatchOperation(ctx, tx, op)
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
+
+// resetFunctionSettings restores the optional settings of a function to the
+// defaults of a new one. The cron state and the function's identity are kept.
+const resetFunctionSettings = `UPDATE functions SET
+	description = NULL, disabled = 0, retention_days = NULL, cron_schedule = NULL, cron_status = 'paused',
+	save_response = 0, allowed_methods = NULL, max_versions = NULL, default_headers = NULL, route_prefix = NULL,
+	websocket_enabled = 0, capture_http = 0, ai_budget_tokens = NULL, ai_budget_usd = NULL, ai_budget_override = 0,
+	ai_default_provider = NULL, ai_default_model = NULL, email_default_from = NULL, email_allowed_from = NULL,
+	disabled_modules = NULL, signing_secret = NULL, cron_jitter = NULL, cron_no_overlap = 0,
+	cron_misfire_policy = NULL, reuse_state = 0, max_log_entries = NULL, middleware = NULL,
+	input_schema = NULL, output_schema = NULL, cache_ttl = NULL, cache_vary_headers = NULL,
+	max_outbound_calls = NULL, outbound_time_budget = NULL, updated_at = ?
+	WHERE id = ?`
+
+// applyBatchOperation applies a single batch operation within tx
+func applyBatchOperation(ctx context.Context, tx *sql.Tx, op BatchOperation) (FunctionWithActiveVersion, error) {
+	id := op.ID
+	if op.Create != nil {
+		fn, err := createFunction(ctx, tx, *op.Create)
+		if err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+		id = fn.ID
+	} else if op.Reset {
+		result, err := tx.ExecContext(ctx, resetFunctionSettings, time.Now().Unix(), id)
+		if err != nil {
+			return FunctionWithActiveVersion{}, fmt.Errorf("failed to reset function settings: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return FunctionWithActiveVersion{}, ErrFunctionNotFound
+		}
+	}
+
+	activeID := ""
+	for _, v := range op.Versions {
+		version, err := createVersion(ctx, tx, id, v.Code, v.CreatedBy, v.Label)
+		if err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+		if v.IsActive {
+			activeID = version.ID
+		}
+	}
+	if activeID != "" {
+		if err := activateVersion(ctx, tx, activeID); err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+	}
+
+	if op.Changes.Code != nil {
+		if _, err := createVersion(ctx, tx, id, *op.Changes.Code, nil, op.Changes.VersionLabel); err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+	}
+
+	if op.Changes.HasMetadata() {
+		if err := updateFunction(ctx, tx, id, op.Changes); err != nil {
+			return FunctionWithActiveVersion{}, err
+		}
+	}
+
+	fn, err := getFunction(ctx, tx, id)
+	if err != nil {
+		return FunctionWithActiveVersion{}, err
+	}
+
+	result := FunctionWithActiveVersion{Function: fn}
+	version, err := getActiveVersion(ctx, tx, id)
+	if err != nil && !errors.Is(err, ErrNoActiveVersion) {
+		return FunctionWithActiveVersion{}, err
+	}
+	if err == nil {
+		result.ActiveVersion = version
+	}
+
+	return result, nil
 }
 
 func (db *SQLiteDB) DeleteFunction(ctx context.Context, id string) error {
@@ -265,22 +833,63 @@ func (db *SQLiteDB) DeleteFunction(ctx context.Context, id string) error {
 
 // Version operations
 
-func (db *SQLiteDB) CreateVersion(ctx context.Context, functionID string, code string, createdBy *string) (FunctionVersion, error) {
+// versionColumns lists the function_versions columns read by scanVersion
+const versionColumns = "id, function_id, version, code, created_at, created_by, label, is_active"
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanVersion scans a row selected with versionColumns
+func scanVersion(row rowScanner) (FunctionVersion, error) {
+	var v FunctionVersion
+	var createdBy, label sql.NullString
+
+	if err := row.Scan(&v.ID, &v.FunctionID, &v.Version, &v.Code, &v.CreatedAt, &createdBy, &label, &v.IsActive); err != nil {
+		return FunctionVersion{}, err
+	}
+
+	if createdBy.Valid {
+		v.CreatedBy = &createdBy.String
+	}
+	if label.Valid {
+		v.Label = &label.String
+	}
+
+	return v, nil
+}
+
+func (db *SQLiteDB) CreateVersion(ctx context.Context, functionID string, code string, createdBy *string, label *string) (FunctionVersion, error) {
 	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return FunctionVersion{}, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	// Check if function exists
-	var exists bool
-	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM functions WHERE id = ?)", functionID).Scan(&exists)
+	version, err := createVersion(ctx, tx, functionID, code, createdBy, label)
 	if err != nil {
-		return FunctionVersion{}, fmt.Errorf("failed to check function existence: %w", err)
+		return FunctionVersion{}, err
 	}
-	if !exists {
+
+	if err := tx.Commit(); err != nil {
+		return FunctionVersion{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return version, nil
+}
+
+// createVersion inserts a new active version within tx
+func createVersion(ctx context.Context, tx *sql.Tx, functionID string, code string, createdBy *string, label *string) (FunctionVersion, error) {
+	// Check if function exists and read its version limit
+	var maxVersions sql.NullInt64
+	err := tx.QueryRowContext(ctx, "SELECT max_versions FROM functions WHERE id = ?", functionID).Scan(&maxVersions)
+	if errors.Is(err, sql.ErrNoRows) {
 		return FunctionVersion{}, ErrFunctionNotFound
 	}
+	if err != nil {
+		return FunctionVersion{}, fmt.Errorf("failed to check function existence: %w", err)
+	}
 
 	// Get the next version number
 	var versionNum int
@@ -304,35 +913,34 @@ func (db *SQLiteDB) CreateVersion(ctx context.Context, functionID string, code s
 		Code:       code,
 		CreatedAt:  time.Now().Unix(),
 		CreatedBy:  createdBy,
+		Label:      normalizeLabel(label),
 		IsActive:   true,
 	}
 
-	query := `INSERT INTO function_versions (id, function_id, version, code, created_at, created_by, is_active)
-	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO function_versions (id, function_id, version, code, created_at, created_by, label, is_active)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err = tx.ExecContext(ctx, query, version.ID, version.FunctionID, version.Version,
-		version.Code, version.CreatedAt, version.CreatedBy, 1)
+		version.Code, version.CreatedAt, version.CreatedBy, version.Label, 1)
 	if err != nil {
 		return FunctionVersion{}, fmt.Errorf("failed to insert version: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return FunctionVersion{}, fmt.Errorf("failed to commit transaction: %w", err)
+	// Prune old versions beyond the function's limit
+	if maxVersions.Valid && maxVersions.Int64 > 0 {
+		if _, err := deleteOldVersions(ctx, tx, functionID, int(maxVersions.Int64)); err != nil {
+			return FunctionVersion{}, err
+		}
 	}
 
 	return version, nil
 }
 
 func (db *SQLiteDB) GetVersion(ctx context.Context, functionID string, version int) (FunctionVersion, error) {
-	query := `SELECT id, function_id, version, code, created_at, created_by, is_active
+	query := `SELECT ` + versionColumns + `
 	          FROM function_versions WHERE function_id = ? AND version = ?`
 
-	var v FunctionVersion
-	var createdBy sql.NullString
-
-	err := db.db.QueryRowContext(ctx, query, functionID, version).Scan(
-		&v.ID, &v.FunctionID, &v.Version, &v.Code, &v.CreatedAt, &createdBy, &v.IsActive,
-	)
+	v, err := scanVersion(db.db.QueryRowContext(ctx, query, functionID, version))
 	if errors.Is(err, sql.ErrNoRows) {
 		return FunctionVersion{}, ErrVersionNotFound
 	}
@@ -340,23 +948,14 @@ func (db *SQLiteDB) GetVersion(ctx context.Context, functionID string, version i
 		return FunctionVersion{}, fmt.Errorf("failed to query version: %w", err)
 	}
 
-	if createdBy.Valid {
-		v.CreatedBy = &createdBy.String
-	}
-
 	return v, nil
 }
 
 func (db *SQLiteDB) GetVersionByID(ctx context.Context, versionID string) (FunctionVersion, error) {
-	query := `SELECT id, function_id, version, code, created_at, created_by, is_active
+	query := `SELECT ` + versionColumns + `
 	          FROM function_versions WHERE id = ?`
 
-	var v FunctionVersion
-	var createdBy sql.NullString
-
-	err := db.db.QueryRowContext(ctx, query, versionID).Scan(
-		&v.ID, &v.FunctionID, &v.Version, &v.Code, &v.CreatedAt, &createdBy, &v.IsActive,
-	)
+	v, err := scanVersion(db.db.QueryRowContext(ctx, query, versionID))
 	if errors.Is(err, sql.ErrNoRows) {
 		return FunctionVersion{}, ErrVersionNotFound
 	}
@@ -364,10 +963,6 @@ func (db *SQLiteDB) GetVersionByID(ctx context.Context, versionID string) (Funct
 		return FunctionVersion{}, fmt.Errorf("failed to query version: %w", err)
 	}
 
-	if createdBy.Valid {
-		v.CreatedBy = &createdBy.String
-	}
-
 	return v, nil
 }
 
@@ -382,7 +977,7 @@ func (db *SQLiteDB) ListVersions(ctx context.Context, functionID string, params
 	// Normalize pagination parameters
 	params = params.Normalize()
 
-	query := `SELECT id, function_id, version, code, created_at, created_by, is_active
+	query := `SELECT ` + versionColumns + `
 	          FROM function_versions WHERE function_id = ?
 	          ORDER BY version DESC
 	          LIMIT ? OFFSET ?`
@@ -395,17 +990,11 @@ func (db *SQLiteDB) ListVersions(ctx context.Context, functionID string, params
 
 	var versions []FunctionVersion
 	for rows.Next() {
-		var v FunctionVersion
-		var createdBy sql.NullString
-
-		if err := rows.Scan(&v.ID, &v.FunctionID, &v.Version, &v.Code, &v.CreatedAt, &createdBy, &v.IsActive); err != nil {
+		v, err := scanVersion(rows)
+		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan version: %w", err)
 		}
 
-		if createdBy.Valid {
-			v.CreatedBy = &createdBy.String
-		}
-
 		versions = append(versions, v)
 	}
 
@@ -413,15 +1002,15 @@ func (db *SQLiteDB) ListVersions(ctx context.Context, functionID string, params
 }
 
 func (db *SQLiteDB) GetActiveVersion(ctx context.Context, functionID string) (FunctionVersion, error) {
-	query := `SELECT id, function_id, version, code, created_at, created_by, is_active
-	          FROM function_versions WHERE function_id = ? AND is_active = 1`
+	return getActiveVersion(ctx, db.db, functionID)
+}
 
-	var v FunctionVersion
-	var createdBy sql.NullString
+// getActiveVersion reads the active version of a function using q
+func getActiveVersion(ctx context.Context, q querier, functionID string) (FunctionVersion, error) {
+	query := `SELECT ` + versionColumns + `
+	          FROM function_versions WHERE function_id = ? AND is_active = 1`
 
-	err := db.db.QueryRowContext(ctx, query, functionID).Scan(
-		&v.ID, &v.FunctionID, &v.Version, &v.Code, &v.CreatedAt, &createdBy, &v.IsActive,
-	)
+	v, err := scanVersion(q.QueryRowContext(ctx, query, functionID))
 	if errors.Is(err, sql.ErrNoRows) {
 		return FunctionVersion{}, ErrNoActiveVersion
 	}
@@ -429,10 +1018,6 @@ func (db *SQLiteDB) GetActiveVersion(ctx context.Context, functionID string) (Fu
 		return FunctionVersion{}, fmt.Errorf("failed to query active version: %w", err)
 	}
 
-	if createdBy.Valid {
-		v.CreatedBy = &createdBy.String
-	}
-
 	return v, nil
 }
 
@@ -443,9 +1028,18 @@ func (db *SQLiteDB) ActivateVersion(ctx context.Context, versionID string) error
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	if err := activateVersion(ctx, tx, versionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// activateVersion makes versionID the only active version of its function
+func activateVersion(ctx context.Context, tx *sql.Tx, versionID string) error {
 	// Get the function_id for this version
 	var functionID string
-	err = tx.QueryRowContext(ctx,
+	err := tx.QueryRowContext(ctx,
 		"SELECT function_id FROM function_versions WHERE id = ?",
 		versionID).Scan(&functionID)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -467,7 +1061,24 @@ func (db *SQLiteDB) ActivateVersion(ctx context.Context, versionID string) error
 		return fmt.Errorf("failed to activate version: %w", err)
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+func (db *SQLiteDB) UpdateVersionLabel(ctx context.Context, versionID string, label *string) (FunctionVersion, error) {
+	result, err := db.db.ExecContext(ctx, "UPDATE function_versions SET label = ? WHERE id = ?", normalizeLabel(label), versionID)
+	if err != nil {
+		return FunctionVersion{}, fmt.Errorf("failed to update version label: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return FunctionVersion{}, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return FunctionVersion{}, ErrVersionNotFound
+	}
+
+	return db.GetVersionByID(ctx, versionID)
 }
 
 func (db *SQLiteDB) DeleteVersion(ctx context.Context, versionID string) error {
@@ -503,6 +1114,46 @@ func (db *SQLiteDB) DeleteVersion(ctx context.Context, versionID string) error {
 	return tx.Commit()
 }
 
+func (db *SQLiteDB) DeleteOldVersions(ctx context.Context, functionID string, keep int) (int64, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	deleted, err := deleteOldVersions(ctx, tx, functionID, keep)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// deleteOldVersions removes inactive versions that are not among the newest keep versions
+func deleteOldVersions(ctx context.Context, tx *sql.Tx, functionID string, keep int) (int64, error) {
+	query := `DELETE FROM function_versions
+	          WHERE function_id = ? AND is_active = 0 AND id NOT IN (
+	              SELECT id FROM function_versions WHERE function_id = ?
+	              ORDER BY version DESC LIMIT ?
+	          )`
+
+	result, err := tx.ExecContext(ctx, query, functionID, functionID, max(keep, 0))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old versions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
 // Execution operations
 
 func (db *SQLiteDB) CreateExecution(ctx context.Context, exec Execution) (Execution, error) {
@@ -513,11 +1164,11 @@ func (db *SQLiteDB) CreateExecution(ctx context.Context, exec Execution) (Execut
 		exec.Trigger = ExecutionTriggerHTTP
 	}
 
-	query := `INSERT INTO executions (id, function_id, function_version_id, status, duration_ms, error_message, event_json, trigger, created_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO executions (id, function_id, function_version_id, status, duration_ms, error_message, event_json, trigger, scheduled_at, request_bytes, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err := db.db.ExecContext(ctx, query, exec.ID, exec.FunctionID, exec.FunctionVersionID,
-		exec.Status, exec.DurationMs, exec.ErrorMessage, exec.EventJSON, exec.Trigger, exec.CreatedAt)
+		exec.Status, exec.DurationMs, exec.ErrorMessage, exec.EventJSON, exec.Trigger, exec.ScheduledAt, exec.RequestBytes, exec.CreatedAt)
 	if err != nil {
 		return Execution{}, fmt.Errorf("failed to insert execution: %w", err)
 	}
@@ -526,7 +1177,8 @@ func (db *SQLiteDB) CreateExecution(ctx context.Context, exec Execution) (Execut
 }
 
 func (db *SQLiteDB) GetExecution(ctx context.Context, executionID string) (Execution, error) {
-	query := `SELECT id, function_id, function_version_id, status, duration_ms, error_message, event_json, response_json, trigger, created_at
+	query := `SELECT id, function_id, function_version_id, status, duration_ms, error_message, event_json, response_json, trigger, tags, scheduled_at,
+	                 request_bytes, response_bytes, cost_usd, spans, created_at
 	          FROM executions WHERE id = ?`
 
 	var exec Execution
@@ -535,10 +1187,16 @@ func (db *SQLiteDB) GetExecution(ctx context.Context, executionID string) (Execu
 	var eventJSON sql.NullString
 	var responseJSON sql.NullString
 	var trigger sql.NullString
+	var tags sql.NullString
+	var scheduledAt sql.NullInt64
+	var requestBytes, responseBytes sql.NullInt64
+	var costUSD sql.NullFloat64
+	var spans sql.NullString
 
 	err := db.db.QueryRowContext(ctx, query, executionID).Scan(
 		&exec.ID, &exec.FunctionID, &exec.FunctionVersionID,
-		&exec.Status, &durationMs, &errorMessage, &eventJSON, &responseJSON, &trigger, &exec.CreatedAt,
+		&exec.Status, &durationMs, &errorMessage, &eventJSON, &responseJSON, &trigger, &tags, &scheduledAt,
+		&requestBytes, &responseBytes, &costUSD, &spans, &exec.CreatedAt,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return Execution{}, ErrExecutionNotFound
@@ -564,14 +1222,32 @@ func (db *SQLiteDB) GetExecution(ctx context.Context, executionID string) (Execu
 	} else {
 		exec.Trigger = ExecutionTriggerHTTP
 	}
+	if tags.Valid {
+		_ = json.Unmarshal([]byte(tags.String), &exec.Tags)
+	}
+	if scheduledAt.Valid {
+		exec.ScheduledAt = &scheduledAt.Int64
+	}
+	if requestBytes.Valid {
+		exec.RequestBytes = &requestBytes.Int64
+	}
+	if responseBytes.Valid {
+		exec.ResponseBytes = &responseBytes.Int64
+	}
+	if costUSD.Valid {
+		exec.CostUSD = &costUSD.Float64
+	}
+	if spans.Valid {
+		_ = json.Unmarshal([]byte(spans.String), &exec.Spans)
+	}
 
 	return exec, nil
 }
 
-func (db *SQLiteDB) UpdateExecution(ctx context.Context, executionID string, status ExecutionStatus, durationMs *int64, errorMsg *string, responseJSON *string) error {
-	query := `UPDATE executions SET status = ?, duration_ms = ?, error_message = ?, response_json = ? WHERE id = ?`
+func (db *SQLiteDB) UpdateExecution(ctx context.Context, executionID string, update ExecutionUpdate) error {
+	query := `UPDATE executions SET status = ?, duration_ms = ?, error_message = ?, response_json = ?, response_bytes = ?, cost_usd = ? WHERE id = ?`
 
-	result, err := db.db.ExecContext(ctx, query, status, durationMs, errorMsg, responseJSON, executionID)
+	result, err := db.db.ExecContext(ctx, query, update.Status, update.DurationMs, update.ErrorMessage, update.ResponseJSON, update.ResponseBytes, update.CostUSD, executionID)
 	if err != nil {
 		return fmt.Errorf("failed to update execution: %w", err)
 	}
@@ -588,10 +1264,62 @@ func (db *SQLiteDB) UpdateExecution(ctx context.Context, executionID string, sta
 	return nil
 }
 
+func (db *SQLiteDB) SetExecutionTags(ctx context.Context, executionID string, tags map[string]string) error {
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal execution tags: %w", err)
+	}
+
+	result, err := db.db.ExecContext(ctx, `UPDATE executions SET tags = ? WHERE id = ?`, string(tagsJSON), executionID)
+	if err != nil {
+		return fmt.Errorf("failed to update execution tags: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrExecutionNotFound
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) SetExecutionSpans(ctx context.Context, executionID string, spans []Span) error {
+	spansJSON, err := json.Marshal(spans)
+	if err != nil {
+		return fmt.Errorf("failed to marshal execution spans: %w", err)
+	}
+
+	result, err := db.db.ExecContext(ctx, `UPDATE executions SET spans = ? WHERE id = ?`, string(spansJSON), executionID)
+	if err != nil {
+		return fmt.Errorf("failed to update execution spans: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrExecutionNotFound
+	}
+
+	return nil
+}
+
 func (db *SQLiteDB) ListExecutions(ctx context.Context, functionID string, params PaginationParams) ([]Execution, int64, error) {
+	return db.ListExecutionsFiltered(ctx, functionID, ExecutionFilter{}, params)
+}
+
+func (db *SQLiteDB) ListExecutionsFiltered(ctx context.Context, functionID string, filter ExecutionFilter, params PaginationParams) ([]Execution, int64, error) {
+	where, args := executionFilterWhere(functionID, filter)
+
 	// Get total count
 	var total int64
-	err := db.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM executions WHERE function_id = ?`, functionID).Scan(&total)
+	err := db.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM executions e WHERE `+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to count executions: %w", err)
 	}
@@ -599,32 +1327,191 @@ func (db *SQLiteDB) ListExecutions(ctx context.Context, functionID string, param
 	// Normalize pagination parameters
 	params = params.Normalize()
 
-	query := `
+	query := executionListQuery(where) + ` LIMIT ? OFFSET ?`
+
+	rows, err := db.db.QueryContext(ctx, query, append(args, params.Limit, params.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query executions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var executions []Execution
+	for rows.Next() {
+		exec, err := scanListedExecution(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		executions = append(executions, exec)
+	}
+
+	return executions, total, rows.Err()
+}
+
+// executionStreamPageSize is how many executions EachExecution reads per query
+const executionStreamPageSize = 500
+
+// EachExecution reads the executions in keyset pages and closes the cursor
+// before calling fn, so a slow consumer never holds a read transaction open
+// while executions are being written
+func (db *SQLiteDB) EachExecution(ctx context.Context, functionID string, filter ExecutionFilter, fn func(Execution) error) error {
+	where, args := executionFilterWhere(functionID, filter)
+
+	pageWhere, pageArgs := where, args
+	for {
+		page, err := db.listExecutionPage(ctx, pageWhere, pageArgs)
+		if err != nil {
+			return err
+		}
+		for _, exec := range page {
+			if err := fn(exec); err != nil {
+				return err
+			}
+		}
+		if len(page) < executionStreamPageSize {
+			return nil
+		}
+
+		last := page[len(page)-1]
+		pageWhere = where + " AND (e.created_at, e.id) < (?, ?)"
+		pageArgs = append(slices.Clone(args), last.CreatedAt, last.ID)
+	}
+}
+
+// listExecutionPage returns the first executionStreamPageSize executions matching where
+func (db *SQLiteDB) listExecutionPage(ctx context.Context, where string, args []any) ([]Execution, error) {
+	rows, err := db.db.QueryContext(ctx, executionListQuery(where)+` LIMIT ?`, append(args, executionStreamPageSize)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query executions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var page []Execution
+	for rows.Next() {
+		exec, err := scanListedExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, exec)
+	}
+	return page, rows.Err()
+}
+
+// executionListQuery selects the listed columns of the executions matching
+// where, newest first and by descending ID within the same second
+func executionListQuery(where string) string {
+	return `
 		SELECT e.id, e.function_id, e.function_version_id, e.status,
-		       e.duration_ms, e.error_message, e.event_json, e.trigger, e.created_at
+		       e.duration_ms, e.error_message, e.event_json, e.trigger, e.tags, e.scheduled_at,
+		       e.request_bytes, e.response_bytes, e.cost_usd, e.created_at
+		FROM executions e
+		WHERE ` + where + `
+		ORDER BY e.created_at DESC, e.id DESC
+	`
+}
+
+// scanListedExecution scans a row selected by executionListQuery
+func scanListedExecution(rows *sql.Rows) (Execution, error) {
+	var exec Execution
+	var durationMs sql.NullInt64
+	var errorMessage sql.NullString
+	var eventJSON sql.NullString
+	var trigger sql.NullString
+	var tags sql.NullString
+	var scheduledAt sql.NullInt64
+	var requestBytes, responseBytes sql.NullInt64
+	var costUSD sql.NullFloat64
+
+	if err := rows.Scan(&exec.ID, &exec.FunctionID, &exec.FunctionVersionID,
+		&exec.Status, &durationMs, &errorMessage, &eventJSON, &trigger, &tags, &scheduledAt,
+		&requestBytes, &responseBytes, &costUSD, &exec.CreatedAt); err != nil {
+		return Execution{}, fmt.Errorf("failed to scan execution: %w", err)
+	}
+
+	if durationMs.Valid {
+		exec.DurationMs = &durationMs.Int64
+	}
+	if errorMessage.Valid {
+		exec.ErrorMessage = &errorMessage.String
+	}
+	if eventJSON.Valid {
+		exec.EventJSON = &eventJSON.String
+	}
+	if trigger.Valid {
+		exec.Trigger = ExecutionTrigger(trigger.String)
+	} else {
+		exec.Trigger = ExecutionTriggerHTTP
+	}
+	if tags.Valid {
+		_ = json.Unmarshal([]byte(tags.String), &exec.Tags)
+	}
+	if scheduledAt.Valid {
+		exec.ScheduledAt = &scheduledAt.Int64
+	}
+	if requestBytes.Valid {
+		exec.RequestBytes = &requestBytes.Int64
+	}
+	if responseBytes.Valid {
+		exec.ResponseBytes = &responseBytes.Int64
+	}
+	if costUSD.Valid {
+		exec.CostUSD = &costUSD.Float64
+	}
+	return exec, nil
+}
+
+// executionFilterWhere builds the SQL conditions and arguments for listing a
+// function's executions matching filter
+func executionFilterWhere(functionID string, filter ExecutionFilter) (string, []any) {
+	conditions := []string{"e.function_id = ?"}
+	args := []any{functionID}
+	for key, value := range filter.Tags {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(e.tags) WHERE json_each.key = ? AND json_each.value = ?)")
+		args = append(args, key, value)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func (db *SQLiteDB) ListRecentErrors(ctx context.Context, since int64, params PaginationParams) ([]FunctionExecution, int64, error) {
+	var total int64
+	err := db.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM executions WHERE status = 'error' AND created_at >= ?`, since,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count recent errors: %w", err)
+	}
+
+	params = params.Normalize()
+
+	query := `
+		SELECT e.id, e.function_id, e.function_version_id, e.status, e.duration_ms, e.error_message,
+		       e.trigger, e.tags, e.scheduled_at, e.request_bytes, e.response_bytes, e.created_at, f.name
 		FROM executions e
-		WHERE e.function_id = ?
-		ORDER BY e.created_at DESC
+		JOIN functions f ON f.id = e.function_id
+		WHERE e.status = 'error' AND e.created_at >= ?
+		ORDER BY e.created_at DESC, e.id
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := db.db.QueryContext(ctx, query, functionID, params.Limit, params.Offset)
+	rows, err := db.db.QueryContext(ctx, query, since, params.Limit, params.Offset)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to query executions: %w", err)
+		return nil, 0, fmt.Errorf("failed to query recent errors: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
-	var executions []Execution
+	errorsList := make([]FunctionExecution, 0)
 	for rows.Next() {
-		var exec Execution
+		var exec FunctionExecution
 		var durationMs sql.NullInt64
 		var errorMessage sql.NullString
-		var eventJSON sql.NullString
 		var trigger sql.NullString
-
-		if err := rows.Scan(&exec.ID, &exec.FunctionID, &exec.FunctionVersionID,
-			&exec.Status, &durationMs, &errorMessage, &eventJSON, &trigger, &exec.CreatedAt); err != nil {
-			return nil, 0, fmt.Errorf("failed to scan execution: %w", err)
+		var tags sql.NullString
+		var scheduledAt sql.NullInt64
+		var requestBytes, responseBytes sql.NullInt64
+
+		if err := rows.Scan(&exec.ID, &exec.FunctionID, &exec.FunctionVersionID, &exec.Status,
+			&durationMs, &errorMessage, &trigger, &tags, &scheduledAt, &requestBytes, &responseBytes,
+			&exec.CreatedAt, &exec.FunctionName); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan recent error: %w", err)
 		}
 
 		if durationMs.Valid {
@@ -633,23 +1520,94 @@ func (db *SQLiteDB) ListExecutions(ctx context.Context, functionID string, param
 		if errorMessage.Valid {
 			exec.ErrorMessage = &errorMessage.String
 		}
-		if eventJSON.Valid {
-			exec.EventJSON = &eventJSON.String
-		}
 		if trigger.Valid {
 			exec.Trigger = ExecutionTrigger(trigger.String)
 		} else {
 			exec.Trigger = ExecutionTriggerHTTP
 		}
+		if tags.Valid {
+			_ = json.Unmarshal([]byte(tags.String), &exec.Tags)
+		}
+		if scheduledAt.Valid {
+			exec.ScheduledAt = &scheduledAt.Int64
+		}
+		if requestBytes.Valid {
+			exec.RequestBytes = &requestBytes.Int64
+		}
+		if responseBytes.Valid {
+			exec.ResponseBytes = &responseBytes.Int64
+		}
 
-		executions = append(executions, exec)
+		errorsList = append(errorsList, exec)
 	}
 
-	return executions, total, rows.Err()
+	return errorsList, total, rows.Err()
+}
+
+func (db *SQLiteDB) ExecutionTimeseries(ctx context.Context, functionID string, since, until, bucketSize int64) ([]ExecutionBucket, error) {
+	// Percentiles use the nearest rank: the duration ranked ceil(p * n) in its
+	// bucket, with unfinished executions (no duration) ranked last
+	query := `
+		WITH bucketed AS (
+			SELECT ? + (created_at - ?) / ? * ? AS bucket, status, duration_ms, request_bytes, response_bytes, cost_usd
+			FROM executions
+			WHERE function_id = ? AND created_at >= ? AND created_at < ? AND status != 'skipped'
+		), ranked AS (
+			SELECT bucket, status, duration_ms, request_bytes, response_bytes, cost_usd,
+			       ROW_NUMBER() OVER (PARTITION BY bucket ORDER BY duration_ms IS NULL, duration_ms) AS rn,
+			       COUNT(duration_ms) OVER (PARTITION BY bucket) AS n
+			FROM bucketed
+		)
+		SELECT bucket, COUNT(*), SUM(status = 'error'), AVG(duration_ms),
+		       MIN(CASE WHEN duration_ms IS NOT NULL AND rn >= (n * 50 + 99) / 100 THEN duration_ms END),
+		       MIN(CASE WHEN duration_ms IS NOT NULL AND rn >= (n * 95 + 99) / 100 THEN duration_ms END),
+		       MIN(CASE WHEN duration_ms IS NOT NULL AND rn >= (n * 99 + 99) / 100 THEN duration_ms END),
+		       COALESCE(SUM(request_bytes), 0), COALESCE(SUM(response_bytes), 0),
+		       COALESCE(MAX(request_bytes), 0), COALESCE(MAX(response_bytes), 0),
+		       COALESCE(SUM(cost_usd), 0)
+		FROM ranked
+		GROUP BY bucket
+	`
+
+	rows, err := db.db.QueryContext(ctx, query, since, since, bucketSize, bucketSize, functionID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query execution timeseries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	found := make(map[int64]ExecutionBucket)
+	for rows.Next() {
+		var bucket ExecutionBucket
+		var avg sql.NullFloat64
+		var p50, p95, p99 sql.NullInt64
+		if err := rows.Scan(&bucket.Start, &bucket.Count, &bucket.Errors, &avg, &p50, &p95, &p99,
+			&bucket.RequestBytes, &bucket.ResponseBytes, &bucket.MaxRequestBytes, &bucket.MaxResponseBytes,
+			&bucket.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan execution bucket: %w", err)
+		}
+		if avg.Valid {
+			bucket.AvgDurationMs = &avg.Float64
+		}
+		if p50.Valid {
+			bucket.P50DurationMs = &p50.Int64
+		}
+		if p95.Valid {
+			bucket.P95DurationMs = &p95.Int64
+		}
+		if p99.Valid {
+			bucket.P99DurationMs = &p99.Int64
+		}
+		found[bucket.Start] = bucket
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fillBuckets(since, until, bucketSize, found), nil
 }
 
 func (db *SQLiteDB) ListFunctionsWithActiveCron(ctx context.Context) ([]Function, error) {
-	query := `SELECT id, name, description, disabled, retention_days, cron_schedule, cron_status, save_response, created_at, updated_at
+	query := `SELECT ` + functionColumns("") + `
 	          FROM functions WHERE cron_status = 'active' AND cron_schedule IS NOT NULL AND cron_schedule != ''`
 
 	rows, err := db.db.QueryContext(ctx, query)
@@ -660,36 +1618,71 @@ func (db *SQLiteDB) ListFunctionsWithActiveCron(ctx context.Context) ([]Function
 
 	var functions []Function
 	for rows.Next() {
-		var fn Function
-		var description sql.NullString
-		var retentionDays sql.NullInt64
-		var cronSchedule sql.NullString
-		var cronStatus sql.NullString
-		var saveResponse sql.NullBool
-
-		if err := rows.Scan(&fn.ID, &fn.Name, &description, &fn.Disabled, &retentionDays, &cronSchedule, &cronStatus, &saveResponse, &fn.CreatedAt, &fn.UpdatedAt); err != nil {
+		var row functionRow
+		if err := rows.Scan(row.dest()...); err != nil {
 			return nil, fmt.Errorf("failed to scan function: %w", err)
 		}
 
-		if description.Valid {
-			fn.Description = &description.String
-		}
-		if retentionDays.Valid {
-			days := int(retentionDays.Int64)
-			fn.RetentionDays = &days
-		}
-		if cronSchedule.Valid {
-			fn.CronSchedule = &cronSchedule.String
-		}
-		if cronStatus.Valid {
-			fn.CronStatus = &cronStatus.String
-		}
-		if saveResponse.Valid {
-			fn.SaveResponse = saveResponse.Bool
+		functions = append(functions, row.function())
+	}
+
+	return functions, rows.Err()
+}
+
+func (db *SQLiteDB) SetCronLastRun(ctx context.Context, functionID string, scheduledAt int64) error {
+	result, err := db.db.ExecContext(ctx, "UPDATE functions SET cron_last_run_at = ? WHERE id = ?", scheduledAt, functionID)
+	if err != nil {
+		return fmt.Errorf("failed to update cron last run: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrFunctionNotFound
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) SetCronLastSuccess(ctx context.Context, functionID string, scheduledAt int64) error {
+	result, err := db.db.ExecContext(ctx, "UPDATE functions SET cron_last_success_at = ? WHERE id = ?", scheduledAt, functionID)
+	if err != nil {
+		return fmt.Errorf("failed to update cron last success: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrFunctionNotFound
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) ListFunctionsWithRoutePrefix(ctx context.Context) ([]Function, error) {
+	query := `SELECT ` + functionColumns("") + `
+	          FROM functions WHERE route_prefix IS NOT NULL AND route_prefix != ''`
+
+	rows, err := db.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query functions with route prefix: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var functions []Function
+	for rows.Next() {
+		var row functionRow
+		if err := rows.Scan(row.dest()...); err != nil {
+			return nil, fmt.Errorf("failed to scan function: %w", err)
 		}
 
-		fn.EnvVars = make(map[string]string)
-		functions = append(functions, fn)
+		functions = append(functions, row.function())
 	}
 
 	return functions, rows.Err()
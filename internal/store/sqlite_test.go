@@ -3,6 +3,11 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -69,6 +74,32 @@ func TestSQLiteDB_CreateFunction(t *testing.T) {
 	}
 }
 
+func TestSQLiteDB_CreateFunction_Settings(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	retention := 30
+	_, err := sqliteDB.CreateFunction(ctx, Function{
+		ID:            "func_settings",
+		Name:          "settings",
+		RetentionDays: &retention,
+		SaveResponse:  true,
+	})
+	if err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	fn, err := sqliteDB.GetFunction(ctx, "func_settings")
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if !fn.SaveResponse || fn.RetentionDays == nil || *fn.RetentionDays != 30 {
+		t.Errorf("expected settings to be stored, got save_response=%v retention_days=%v", fn.SaveResponse, fn.RetentionDays)
+	}
+}
+
 func TestSQLiteDB_GetFunction(t *testing.T) {
 	db, sqliteDB := setupTestDB(t)
 	defer func() { _ = db.Close() }()
@@ -223,6 +254,159 @@ func TestSQLiteDB_DeleteFunction(t *testing.T) {
 	}
 }
 
+func TestSQLiteDB_ApplyBatch(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	existing := Function{ID: "func_batch_existing", Name: "existing", EnvVars: make(map[string]string)}
+	if _, err := sqliteDB.CreateFunction(ctx, existing); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	code := "function handler() end"
+	name := "renamed"
+	label := "release"
+	disabled := true
+
+	results, err := sqliteDB.ApplyBatch(ctx, []BatchOperation{
+		{
+			Create:  &Function{ID: "func_batch_new", Name: "new"},
+			Changes: UpdateFunctionRequest{Code: &code, VersionLabel: &label, Disabled: &disabled},
+		},
+		{
+			ID:      existing.ID,
+			Changes: UpdateFunctionRequest{Name: &name, Code: &code},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ApplyBatch failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != "func_batch_new" || !results[0].Disabled {
+		t.Errorf("Expected created function to be disabled, got %+v", results[0].Function)
+	}
+	if results[0].ActiveVersion.Version != 1 || results[0].ActiveVersion.Label == nil || *results[0].ActiveVersion.Label != label {
+		t.Errorf("Expected labeled first version, got %+v", results[0].ActiveVersion)
+	}
+	if results[1].Name != name || results[1].ActiveVersion.Version != 1 {
+		t.Errorf("Expected renamed function with version 1, got %+v", results[1])
+	}
+}
+
+func TestSQLiteDB_ApplyBatch_Versions(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	author := "ana"
+	label := "stable"
+	results, err := sqliteDB.ApplyBatch(ctx, []BatchOperation{{
+		Create: &Function{ID: "func_batch_versions", Name: "versions"},
+		Versions: []FunctionVersion{
+			{Code: "-- v1", CreatedBy: &author},
+			{Code: "-- v2", Label: &label, IsActive: true},
+			{Code: "-- v3"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("ApplyBatch failed: %v", err)
+	}
+
+	active := results[0].ActiveVersion
+	if active.Version != 2 || active.Code != "-- v2" || active.Label == nil || *active.Label != label {
+		t.Errorf("Expected version 2 to be active, got %+v", active)
+	}
+	first, err := sqliteDB.GetVersion(ctx, "func_batch_versions", 1)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if first.IsActive || first.CreatedBy == nil || *first.CreatedBy != author {
+		t.Errorf("Expected inactive version 1 created by %s, got %+v", author, first)
+	}
+	if _, err := sqliteDB.GetVersion(ctx, "func_batch_versions", 3); err != nil {
+		t.Errorf("Expected version 3 to exist, got %v", err)
+	}
+}
+
+func TestSQLiteDB_ApplyBatch_Reset(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{ID: "func_batch_reset", Name: "reset", EnvVars: make(map[string]string)}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+	secret := "stale"
+	ttl := 60
+	saveResponse := true
+	err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{SigningSecret: &secret, CacheTTL: &ttl, SaveResponse: &saveResponse})
+	if err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	name := "restored"
+	results, err := sqliteDB.ApplyBatch(ctx, []BatchOperation{{
+		ID:      fn.ID,
+		Reset:   true,
+		Changes: UpdateFunctionRequest{Name: &name, CacheTTL: &ttl},
+	}})
+	if err != nil {
+		t.Fatalf("ApplyBatch failed: %v", err)
+	}
+
+	got := results[0].Function
+	if got.Name != name || got.CacheTTL == nil || *got.CacheTTL != ttl {
+		t.Errorf("Expected the changes to be applied, got %+v", got)
+	}
+	if got.SigningSecret != nil || got.SaveResponse {
+		t.Errorf("Expected settings left out of the changes to be reset, got %+v", got)
+	}
+
+	_, err = sqliteDB.ApplyBatch(ctx, []BatchOperation{{ID: "missing", Reset: true}})
+	if !errors.Is(err, ErrFunctionNotFound) {
+		t.Errorf("Expected resetting a missing function to fail with ErrFunctionNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteDB_ApplyBatch_RollsBack(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	code := "function handler() end"
+	_, err := sqliteDB.ApplyBatch(ctx, []BatchOperation{
+		{
+			Create:  &Function{ID: "func_batch_rollback", Name: "rollback"},
+			Changes: UpdateFunctionRequest{Code: &code},
+		},
+		{
+			ID:      "missing",
+			Changes: UpdateFunctionRequest{Code: &code},
+		},
+	})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected BatchError, got %v", err)
+	}
+	if batchErr.Index != 1 || !errors.Is(err, ErrFunctionNotFound) {
+		t.Errorf("Expected operation 1 to fail with ErrFunctionNotFound, got %v", err)
+	}
+
+	if _, err := sqliteDB.GetFunction(ctx, "func_batch_rollback"); !errors.Is(err, ErrFunctionNotFound) {
+		t.Errorf("Expected created function to be rolled back, got %v", err)
+	}
+}
+
 // Version operations tests
 
 func TestSQLiteDB_CreateVersion(t *testing.T) {
@@ -244,7 +428,7 @@ func TestSQLiteDB_CreateVersion(t *testing.T) {
 
 	// Create a version
 	createdBy := "user@example.com"
-	version, err := sqliteDB.CreateVersion(ctx, fn.ID, "function handler() end", &createdBy)
+	version, err := sqliteDB.CreateVersion(ctx, fn.ID, "function handler() end", &createdBy, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -263,6 +447,70 @@ func TestSQLiteDB_CreateVersion(t *testing.T) {
 	}
 }
 
+func TestSQLiteDB_VersionLabel(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_ver_label",
+		Name:    "label-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	label := "  v1.2.0 "
+	version, err := sqliteDB.CreateVersion(ctx, fn.ID, "function handler() end", nil, &label)
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+	if version.Label == nil || *version.Label != "v1.2.0" {
+		t.Fatalf("Expected trimmed label v1.2.0, got %v", version.Label)
+	}
+
+	active, err := sqliteDB.GetActiveVersion(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetActiveVersion failed: %v", err)
+	}
+	if active.Label == nil || *active.Label != "v1.2.0" {
+		t.Errorf("Expected stored label v1.2.0, got %v", active.Label)
+	}
+
+	functions, _, err := sqliteDB.ListFunctions(ctx, PaginationParams{})
+	if err != nil {
+		t.Fatalf("ListFunctions failed: %v", err)
+	}
+	if len(functions) != 1 || functions[0].ActiveVersion.Label == nil || *functions[0].ActiveVersion.Label != "v1.2.0" {
+		t.Errorf("Expected active version label in function list")
+	}
+
+	prod := "prod"
+	updated, err := sqliteDB.UpdateVersionLabel(ctx, version.ID, &prod)
+	if err != nil {
+		t.Fatalf("UpdateVersionLabel failed: %v", err)
+	}
+	if updated.Label == nil || *updated.Label != "prod" {
+		t.Errorf("Expected label prod, got %v", updated.Label)
+	}
+
+	empty := ""
+	cleared, err := sqliteDB.UpdateVersionLabel(ctx, version.ID, &empty)
+	if err != nil {
+		t.Fatalf("UpdateVersionLabel failed: %v", err)
+	}
+	if cleared.Label != nil {
+		t.Errorf("Expected label to be removed, got %q", *cleared.Label)
+	}
+
+	if _, err := sqliteDB.UpdateVersionLabel(ctx, "missing", &prod); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected ErrVersionNotFound, got %v", err)
+	}
+}
+
 func TestSQLiteDB_CreateVersion_DeactivatesPrevious(t *testing.T) {
 	db, sqliteDB := setupTestDB(t)
 	defer func() { _ = db.Close() }()
@@ -281,13 +529,13 @@ func TestSQLiteDB_CreateVersion_DeactivatesPrevious(t *testing.T) {
 	}
 
 	// Create first version
-	v1, err := sqliteDB.CreateVersion(ctx, fn.ID, "version 1", nil)
+	v1, err := sqliteDB.CreateVersion(ctx, fn.ID, "version 1", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion v1 failed: %v", err)
 	}
 
 	// Create second version
-	v2, err := sqliteDB.CreateVersion(ctx, fn.ID, "version 2", nil)
+	v2, err := sqliteDB.CreateVersion(ctx, fn.ID, "version 2", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion v2 failed: %v", err)
 	}
@@ -325,7 +573,7 @@ func TestSQLiteDB_GetVersion(t *testing.T) {
 	}
 
 	code := "function handler() return 42 end"
-	created, err := sqliteDB.CreateVersion(ctx, fn.ID, code, nil)
+	created, err := sqliteDB.CreateVersion(ctx, fn.ID, code, nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -361,7 +609,7 @@ func TestSQLiteDB_GetVersionByID(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	created, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	created, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -396,7 +644,7 @@ func TestSQLiteDB_ListVersions(t *testing.T) {
 
 	// Create 3 versions
 	for i := 1; i <= 3; i++ {
-		if _, err := sqliteDB.CreateVersion(ctx, fn.ID, "code v"+string(rune('0'+i)), nil); err != nil {
+		if _, err := sqliteDB.CreateVersion(ctx, fn.ID, "code v"+string(rune('0'+i)), nil, nil); err != nil {
 			t.Fatalf("CreateVersion v%d failed: %v", i, err)
 		}
 	}
@@ -420,6 +668,43 @@ func TestSQLiteDB_ListVersions(t *testing.T) {
 	}
 }
 
+func TestSQLiteDB_Backup(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{ID: "func_backup", Name: "backup-test", EnvVars: make(map[string]string)}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+	if _, err := sqliteDB.CreateVersion(ctx, fn.ID, "code v1", nil, nil); err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := sqliteDB.Backup(ctx, path); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := sqliteDB.Backup(ctx, path); err == nil {
+		t.Error("expected an error when the backup file already exists")
+	}
+
+	backup, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer func() { _ = backup.Close() }()
+
+	restored, err := NewSQLiteDB(backup).GetActiveVersion(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetActiveVersion on the backup failed: %v", err)
+	}
+	if restored.Code != "code v1" {
+		t.Errorf("expected the backup to hold the active version, got %q", restored.Code)
+	}
+}
+
 func TestSQLiteDB_GetActiveVersion(t *testing.T) {
 	db, sqliteDB := setupTestDB(t)
 	defer func() { _ = db.Close() }()
@@ -438,10 +723,10 @@ func TestSQLiteDB_GetActiveVersion(t *testing.T) {
 	}
 
 	// Create versions
-	if _, err := sqliteDB.CreateVersion(ctx, fn.ID, "v1", nil); err != nil {
+	if _, err := sqliteDB.CreateVersion(ctx, fn.ID, "v1", nil, nil); err != nil {
 		t.Fatalf("CreateVersion v1 failed: %v", err)
 	}
-	v2, err := sqliteDB.CreateVersion(ctx, fn.ID, "v2", nil)
+	v2, err := sqliteDB.CreateVersion(ctx, fn.ID, "v2", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion v2 failed: %v", err)
 	}
@@ -478,11 +763,11 @@ func TestSQLiteDB_ActivateVersion(t *testing.T) {
 	}
 
 	// Create 2 versions
-	v1, err := sqliteDB.CreateVersion(ctx, fn.ID, "v1", nil)
+	v1, err := sqliteDB.CreateVersion(ctx, fn.ID, "v1", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion v1 failed: %v", err)
 	}
-	if _, err := sqliteDB.CreateVersion(ctx, fn.ID, "v2", nil); err != nil {
+	if _, err := sqliteDB.CreateVersion(ctx, fn.ID, "v2", nil, nil); err != nil {
 		t.Fatalf("CreateVersion v2 failed: %v", err)
 	}
 
@@ -502,6 +787,109 @@ func TestSQLiteDB_ActivateVersion(t *testing.T) {
 	}
 }
 
+func TestSQLiteDB_DeleteOldVersions(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_prune",
+		Name:    "prune-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	var versions []FunctionVersion
+	for i := 1; i <= 5; i++ {
+		v, err := sqliteDB.CreateVersion(ctx, fn.ID, fmt.Sprintf("v%d", i), nil, nil)
+		if err != nil {
+			t.Fatalf("CreateVersion v%d failed: %v", i, err)
+		}
+		versions = append(versions, v)
+	}
+
+	// Activate the oldest version so it must survive pruning
+	if err := sqliteDB.ActivateVersion(ctx, versions[0].ID); err != nil {
+		t.Fatalf("ActivateVersion failed: %v", err)
+	}
+
+	deleted, err := sqliteDB.DeleteOldVersions(ctx, fn.ID, 2)
+	if err != nil {
+		t.Fatalf("DeleteOldVersions failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted versions, got %d", deleted)
+	}
+
+	remaining, total, err := sqliteDB.ListVersions(ctx, fn.ID, PaginationParams{})
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("Expected 3 remaining versions, got %d", total)
+	}
+
+	got := []int{remaining[0].Version, remaining[1].Version, remaining[2].Version}
+	if got[0] != 5 || got[1] != 4 || got[2] != 1 {
+		t.Errorf("Expected versions [5 4 1] to remain, got %v", got)
+	}
+}
+
+func TestSQLiteDB_CreateVersion_PrunesToMaxVersions(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_max_versions",
+		Name:    "max-versions-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	maxVersions := 3
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{MaxVersions: &maxVersions}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		if _, err := sqliteDB.CreateVersion(ctx, fn.ID, fmt.Sprintf("v%d", i), nil, nil); err != nil {
+			t.Fatalf("CreateVersion v%d failed: %v", i, err)
+		}
+	}
+
+	remaining, total, err := sqliteDB.ListVersions(ctx, fn.ID, PaginationParams{})
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("Expected 3 remaining versions, got %d", total)
+	}
+	if remaining[0].Version != 5 || !remaining[0].IsActive {
+		t.Errorf("Expected newest version 5 to be active, got version %d (active=%v)", remaining[0].Version, remaining[0].IsActive)
+	}
+	if remaining[2].Version != 3 {
+		t.Errorf("Expected oldest remaining version 3, got %d", remaining[2].Version)
+	}
+
+	// Version numbering continues after pruning
+	next, err := sqliteDB.CreateVersion(ctx, fn.ID, "v6", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateVersion v6 failed: %v", err)
+	}
+	if next.Version != 6 {
+		t.Errorf("Expected version 6, got %d", next.Version)
+	}
+}
+
 // Execution operations tests
 
 func TestSQLiteDB_CreateExecution(t *testing.T) {
@@ -521,7 +909,7 @@ func TestSQLiteDB_CreateExecution(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -567,7 +955,7 @@ func TestSQLiteDB_GetExecution(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -614,7 +1002,7 @@ func TestSQLiteDB_UpdateExecution(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -633,7 +1021,7 @@ func TestSQLiteDB_UpdateExecution(t *testing.T) {
 	// Update execution
 	duration := int64(250)
 	errorMsg := "test error"
-	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionStatusError, &duration, &errorMsg, nil); err != nil {
+	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionUpdate{Status: ExecutionStatusError, DurationMs: &duration, ErrorMessage: &errorMsg}); err != nil {
 		t.Fatalf("UpdateExecution failed: %v", err)
 	}
 
@@ -671,7 +1059,7 @@ func TestSQLiteDB_ListExecutions(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -703,65 +1091,188 @@ func TestSQLiteDB_ListExecutions(t *testing.T) {
 	}
 }
 
-// CASCADE delete tests
-
-func TestSQLiteDB_DeleteFunction_CascadesVersions(t *testing.T) {
+func TestSQLiteDB_ExecutionTags(t *testing.T) {
 	db, sqliteDB := setupTestDB(t)
 	defer func() { _ = db.Close() }()
 
 	ctx := context.Background()
 
-	// Create function with version
 	fn := Function{
-		ID:      "func_cascade",
-		Name:    "cascade-test",
+		ID:      "func_tags",
+		Name:    "tags-test",
 		EnvVars: make(map[string]string),
 	}
-
 	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
-
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
 
-	// Delete function
-	if err := sqliteDB.DeleteFunction(ctx, fn.ID); err != nil {
-		t.Fatalf("DeleteFunction failed: %v", err)
+	tags := map[string]map[string]string{
+		"exec_tags_1": {"customer": "acme", "plan": "pro"},
+		"exec_tags_2": {"customer": "acme", "plan": "free"},
+		"exec_tags_3": {"customer": "globex"},
+		"exec_tags_4": nil,
+	}
+	for id, execTags := range tags {
+		exec := Execution{ID: id, FunctionID: fn.ID, FunctionVersionID: ver.ID, Status: ExecutionStatusSuccess}
+		if _, err := sqliteDB.CreateExecution(ctx, exec); err != nil {
+			t.Fatalf("CreateExecution failed: %v", err)
+		}
+		if execTags != nil {
+			if err := sqliteDB.SetExecutionTags(ctx, id, execTags); err != nil {
+				t.Fatalf("SetExecutionTags failed: %v", err)
+			}
+		}
 	}
 
-	// Verify version is also deleted
-	_, err = sqliteDB.GetVersionByID(ctx, ver.ID)
-	if err == nil {
-		t.Error("Expected version to be cascade deleted")
+	got, err := sqliteDB.GetExecution(ctx, "exec_tags_1")
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if got.Tags["customer"] != "acme" || got.Tags["plan"] != "pro" {
+		t.Errorf("unexpected tags: %v", got.Tags)
+	}
+
+	executions, total, err := sqliteDB.ListExecutionsFiltered(ctx, fn.ID, ExecutionFilter{Tags: map[string]string{"customer": "acme"}}, PaginationParams{})
+	if err != nil {
+		t.Fatalf("ListExecutionsFiltered failed: %v", err)
+	}
+	if total != 2 || len(executions) != 2 {
+		t.Errorf("expected 2 acme executions, got %d (total %d)", len(executions), total)
+	}
+
+	executions, total, err = sqliteDB.ListExecutionsFiltered(ctx, fn.ID, ExecutionFilter{Tags: map[string]string{"customer": "acme", "plan": "free"}}, PaginationParams{})
+	if err != nil {
+		t.Fatalf("ListExecutionsFiltered failed: %v", err)
+	}
+	if total != 1 || len(executions) != 1 || executions[0].ID != "exec_tags_2" || executions[0].Tags["plan"] != "free" {
+		t.Errorf("expected only exec_tags_2, got %+v (total %d)", executions, total)
+	}
+
+	if _, total, _ := sqliteDB.ListExecutions(ctx, fn.ID, PaginationParams{}); total != 4 {
+		t.Errorf("expected 4 executions without filter, got %d", total)
+	}
+
+	if err := sqliteDB.SetExecutionTags(ctx, "missing", map[string]string{"a": "b"}); !errors.Is(err, ErrExecutionNotFound) {
+		t.Errorf("expected ErrExecutionNotFound, got %v", err)
 	}
 }
 
-func TestSQLiteDB_DeleteFunction_CascadesExecutions(t *testing.T) {
+func TestSQLiteDB_ExecutionSpans(t *testing.T) {
 	db, sqliteDB := setupTestDB(t)
 	defer func() { _ = db.Close() }()
 
 	ctx := context.Background()
 
-	// Create function, version, and execution
 	fn := Function{
-		ID:      "func_cascade_exec",
-		Name:    "cascade-exec-test",
+		ID:      "func_spans",
+		Name:    "spans-test",
 		EnvVars: make(map[string]string),
 	}
-
 	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
-
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
+	exec := Execution{ID: "exec_spans", FunctionID: fn.ID, FunctionVersionID: ver.ID, Status: ExecutionStatusSuccess}
+	if _, err := sqliteDB.CreateExecution(ctx, exec); err != nil {
+		t.Fatalf("CreateExecution failed: %v", err)
+	}
 
-	exec := Execution{
+	spans := []Span{
+		{Name: "request", StartMs: 0, DurationMs: 20.5},
+		{Name: "db_query", StartMs: 1.25, DurationMs: 12, Depth: 1},
+		{Name: "render", StartMs: 14, DurationMs: 6.5, Depth: 1, Unfinished: true},
+	}
+	if err := sqliteDB.SetExecutionSpans(ctx, exec.ID, spans); err != nil {
+		t.Fatalf("SetExecutionSpans failed: %v", err)
+	}
+
+	got, err := sqliteDB.GetExecution(ctx, exec.ID)
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if !slices.Equal(got.Spans, spans) {
+		t.Errorf("expected spans %+v, got %+v", spans, got.Spans)
+	}
+
+	executions, _, err := sqliteDB.ListExecutions(ctx, fn.ID, PaginationParams{})
+	if err != nil {
+		t.Fatalf("ListExecutions failed: %v", err)
+	}
+	if len(executions) != 1 || executions[0].Spans != nil {
+		t.Errorf("expected listings without spans, got %+v", executions)
+	}
+
+	if err := sqliteDB.SetExecutionSpans(ctx, "missing", spans); !errors.Is(err, ErrExecutionNotFound) {
+		t.Errorf("expected ErrExecutionNotFound, got %v", err)
+	}
+}
+
+// CASCADE delete tests
+
+func TestSQLiteDB_DeleteFunction_CascadesVersions(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	// Create function with version
+	fn := Function{
+		ID:      "func_cascade",
+		Name:    "cascade-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+
+	// Delete function
+	if err := sqliteDB.DeleteFunction(ctx, fn.ID); err != nil {
+		t.Fatalf("DeleteFunction failed: %v", err)
+	}
+
+	// Verify version is also deleted
+	_, err = sqliteDB.GetVersionByID(ctx, ver.ID)
+	if err == nil {
+		t.Error("Expected version to be cascade deleted")
+	}
+}
+
+func TestSQLiteDB_DeleteFunction_CascadesExecutions(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	// Create function, version, and execution
+	fn := Function{
+		ID:      "func_cascade_exec",
+		Name:    "cascade-exec-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+
+	exec := Execution{
 		ID:                "exec_cascade",
 		FunctionID:        fn.ID,
 		FunctionVersionID: ver.ID,
@@ -970,7 +1481,7 @@ func TestSQLiteDB_CreateExecution_WithEventJSON(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1018,7 +1529,7 @@ func TestSQLiteDB_GetExecution_WithEventJSON(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1067,7 +1578,7 @@ func TestSQLiteDB_GetExecution_WithoutEventJSON(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1112,7 +1623,7 @@ func TestSQLiteDB_ListExecutions_WithEventJSON(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1275,7 +1786,7 @@ func TestSQLiteDB_DeleteOldExecutions(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1357,7 +1868,7 @@ func TestSQLiteDB_DeleteOldExecutions_AllNew(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1474,7 +1985,7 @@ func TestSQLiteDB_UpdateExecution_WithResponseJSON(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1495,7 +2006,7 @@ func TestSQLiteDB_UpdateExecution_WithResponseJSON(t *testing.T) {
 	durationMs := int64(100)
 	responseJSON := `{"statusCode":200,"headers":{"Content-Type":"application/json"},"body":"{\"success\":true}","isBase64Encoded":false}`
 
-	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionStatusSuccess, &durationMs, nil, &responseJSON); err != nil {
+	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionUpdate{Status: ExecutionStatusSuccess, DurationMs: &durationMs, ResponseJSON: &responseJSON}); err != nil {
 		t.Fatalf("UpdateExecution failed: %v", err)
 	}
 
@@ -1539,7 +2050,7 @@ func TestSQLiteDB_GetExecution_WithResponseJSON(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1559,7 +2070,7 @@ func TestSQLiteDB_GetExecution_WithResponseJSON(t *testing.T) {
 	responseJSON := `{"statusCode":201,"headers":{"Location":"/items/123"},"body":"created","isBase64Encoded":false}`
 	durationMs := int64(50)
 
-	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionStatusSuccess, &durationMs, nil, &responseJSON); err != nil {
+	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionUpdate{Status: ExecutionStatusSuccess, DurationMs: &durationMs, ResponseJSON: &responseJSON}); err != nil {
 		t.Fatalf("UpdateExecution failed: %v", err)
 	}
 
@@ -1595,7 +2106,7 @@ func TestSQLiteDB_GetExecution_WithoutResponseJSON(t *testing.T) {
 		t.Fatalf("CreateFunction failed: %v", err)
 	}
 
-	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil)
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
 	if err != nil {
 		t.Fatalf("CreateVersion failed: %v", err)
 	}
@@ -1613,7 +2124,7 @@ func TestSQLiteDB_GetExecution_WithoutResponseJSON(t *testing.T) {
 	}
 
 	durationMs := int64(25)
-	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionStatusSuccess, &durationMs, nil, nil); err != nil {
+	if err := sqliteDB.UpdateExecution(ctx, exec.ID, ExecutionUpdate{Status: ExecutionStatusSuccess, DurationMs: &durationMs}); err != nil {
 		t.Fatalf("UpdateExecution failed: %v", err)
 	}
 
@@ -1627,3 +2138,1186 @@ func TestSQLiteDB_GetExecution_WithoutResponseJSON(t *testing.T) {
 		t.Errorf("Expected ResponseJSON to be nil, got %s", *retrieved.ResponseJSON)
 	}
 }
+
+func TestSQLiteDB_UpdateFunction_AllowedMethods(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_allowed_methods",
+		Name:    "allowed-methods-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	methods := []string{"GET", "POST"}
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{AllowedMethods: &methods}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if len(updated.AllowedMethods) != 2 || updated.AllowedMethods[0] != "GET" || updated.AllowedMethods[1] != "POST" {
+		t.Errorf("Expected AllowedMethods [GET POST], got %v", updated.AllowedMethods)
+	}
+
+	// An empty list clears the restriction
+	empty := []string{}
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{AllowedMethods: &empty}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if len(cleared.AllowedMethods) != 0 {
+		t.Errorf("Expected AllowedMethods to be empty, got %v", cleared.AllowedMethods)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_DefaultHeaders(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_default_headers",
+		Name:    "default-headers-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	headers := map[string]string{"X-Frame-Options": "DENY"}
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{DefaultHeaders: &headers}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.DefaultHeaders["X-Frame-Options"] != "DENY" {
+		t.Errorf("Expected X-Frame-Options default header, got %v", updated.DefaultHeaders)
+	}
+
+	// An empty map clears the default headers
+	empty := map[string]string{}
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{DefaultHeaders: &empty}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if len(cleared.DefaultHeaders) != 0 {
+		t.Errorf("Expected no default headers, got %v", cleared.DefaultHeaders)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_AIBudget(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_ai_budget",
+		Name:    "ai-budget-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	tokens := int64(50000)
+	usd := 7.5
+	override := true
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{
+		AIBudgetTokens:   &tokens,
+		AIBudgetUSD:      &usd,
+		AIBudgetOverride: &override,
+	}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.AIBudgetTokens == nil || *updated.AIBudgetTokens != 50000 {
+		t.Errorf("Expected token budget 50000, got %v", updated.AIBudgetTokens)
+	}
+	if updated.AIBudgetUSD == nil || *updated.AIBudgetUSD != 7.5 {
+		t.Errorf("Expected cost budget 7.5, got %v", updated.AIBudgetUSD)
+	}
+	if !updated.AIBudgetOverride {
+		t.Error("Expected budget override to be set")
+	}
+
+	// Zero clears the budgets
+	zeroTokens := int64(0)
+	zeroUSD := 0.0
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{AIBudgetTokens: &zeroTokens, AIBudgetUSD: &zeroUSD}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.AIBudgetTokens != nil || cleared.AIBudgetUSD != nil {
+		t.Errorf("Expected budgets to be cleared, got %v and %v", cleared.AIBudgetTokens, cleared.AIBudgetUSD)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_Defaults(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_defaults",
+		Name:    "defaults-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	provider := "anthropic"
+	model := "claude-3-haiku-20240307"
+	from := "noreply@example.com"
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{
+		AIDefaultProvider: &provider,
+		AIDefaultModel:    &model,
+		EmailDefaultFrom:  &from,
+	}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.AIDefaultProvider == nil || *updated.AIDefaultProvider != provider {
+		t.Errorf("Expected default provider %q, got %v", provider, updated.AIDefaultProvider)
+	}
+	if updated.AIDefaultModel == nil || *updated.AIDefaultModel != model {
+		t.Errorf("Expected default model %q, got %v", model, updated.AIDefaultModel)
+	}
+	if updated.EmailDefaultFrom == nil || *updated.EmailDefaultFrom != from {
+		t.Errorf("Expected default from %q, got %v", from, updated.EmailDefaultFrom)
+	}
+
+	// Empty strings clear the defaults
+	empty := ""
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{AIDefaultProvider: &empty, EmailDefaultFrom: &empty}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.AIDefaultProvider != nil || cleared.EmailDefaultFrom != nil {
+		t.Errorf("Expected defaults to be cleared, got %v and %v", cleared.AIDefaultProvider, cleared.EmailDefaultFrom)
+	}
+	if cleared.AIDefaultModel == nil {
+		t.Error("Expected default model to be kept")
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_EmailAllowedFrom(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_email_allowed",
+		Name:    "email-allowed-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	allowed := []string{"noreply@example.com", "@example.org"}
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{EmailAllowedFrom: &allowed}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if !slices.Equal(updated.EmailAllowedFrom, allowed) {
+		t.Errorf("Expected email allowed from %v, got %v", allowed, updated.EmailAllowedFrom)
+	}
+
+	// An empty list clears the allowlist
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{EmailAllowedFrom: &[]string{}}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.EmailAllowedFrom != nil {
+		t.Errorf("Expected email allowed from to be cleared, got %v", cleared.EmailAllowedFrom)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_DisabledModules(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_sandbox",
+		Name:    "sandbox-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	disabled := []string{"http", "ai"}
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{DisabledModules: &disabled}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if !slices.Equal(updated.DisabledModules, disabled) {
+		t.Errorf("Expected disabled modules %v, got %v", disabled, updated.DisabledModules)
+	}
+
+	// An empty list enables every module again
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{DisabledModules: &[]string{}}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.DisabledModules != nil {
+		t.Errorf("Expected disabled modules to be cleared, got %v", cleared.DisabledModules)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_Middleware(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_middleware",
+		Name:    "middleware-test",
+		EnvVars: make(map[string]string),
+	}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	middleware := []string{"auth", "mw.envelope"}
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{Middleware: &middleware}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if !slices.Equal(updated.Middleware, middleware) {
+		t.Errorf("Expected middleware %v, got %v", middleware, updated.Middleware)
+	}
+
+	// An empty list removes the middleware
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{Middleware: &[]string{}}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.Middleware != nil {
+		t.Errorf("Expected middleware to be cleared, got %v", cleared.Middleware)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_InputSchema(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_input_schema",
+		Name:    "input-schema-test",
+		EnvVars: make(map[string]string),
+	}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	schema := json.RawMessage(`{
+		"type": "object",
+		"required": ["name"]
+	}`)
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{InputSchema: &schema}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if want := `{"type":"object","required":["name"]}`; string(updated.InputSchema) != want {
+		t.Errorf("Expected input schema %s, got %s", want, updated.InputSchema)
+	}
+
+	// An empty schema removes it
+	empty := json.RawMessage(`{ }`)
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{InputSchema: &empty}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.InputSchema != nil {
+		t.Errorf("Expected input schema to be cleared, got %s", cleared.InputSchema)
+	}
+
+	// The output schema is stored the same way
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{OutputSchema: &schema}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	withOutput, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if want := `{"type":"object","required":["name"]}`; string(withOutput.OutputSchema) != want || withOutput.InputSchema != nil {
+		t.Errorf("Expected output schema %s only, got input %s and output %s", want, withOutput.InputSchema, withOutput.OutputSchema)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_SigningSecret(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_signed",
+		Name:    "signed-test",
+		EnvVars: make(map[string]string),
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	secret := "0123456789abcdef"
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{SigningSecret: &secret}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.SigningSecret == nil || *updated.SigningSecret != secret {
+		t.Errorf("Expected signing secret %q, got %v", secret, updated.SigningSecret)
+	}
+
+	// An empty secret accepts unsigned requests again
+	empty := ""
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{SigningSecret: &empty}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.SigningSecret != nil {
+		t.Errorf("Expected signing secret to be cleared, got %q", *cleared.SigningSecret)
+	}
+}
+
+func TestSQLiteDB_CronJitterAndScheduledAt(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_jitter",
+		Name:    "jitter-test",
+		EnvVars: make(map[string]string),
+	}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	jitter := 30
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{CronJitter: &jitter}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.CronJitter == nil || *updated.CronJitter != jitter {
+		t.Errorf("Expected cron jitter %d, got %v", jitter, updated.CronJitter)
+	}
+
+	// Zero clears the jitter
+	zero := 0
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{CronJitter: &zero}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.CronJitter != nil {
+		t.Errorf("Expected cron jitter to be cleared, got %d", *cleared.CronJitter)
+	}
+
+	ver, err := sqliteDB.CreateVersion(ctx, fn.ID, "code", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+	scheduledAt := int64(1700000000)
+	exec := Execution{ID: "exec_scheduled", FunctionID: fn.ID, FunctionVersionID: ver.ID,
+		Status: ExecutionStatusPending, Trigger: ExecutionTriggerCron, ScheduledAt: &scheduledAt}
+	if _, err := sqliteDB.CreateExecution(ctx, exec); err != nil {
+		t.Fatalf("CreateExecution failed: %v", err)
+	}
+
+	got, err := sqliteDB.GetExecution(ctx, exec.ID)
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if got.ScheduledAt == nil || *got.ScheduledAt != scheduledAt {
+		t.Errorf("Expected scheduled_at %d, got %v", scheduledAt, got.ScheduledAt)
+	}
+
+	list, _, err := sqliteDB.ListExecutions(ctx, fn.ID, PaginationParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListExecutions failed: %v", err)
+	}
+	if len(list) != 1 || list[0].ScheduledAt == nil || *list[0].ScheduledAt != scheduledAt {
+		t.Errorf("Expected listed execution with scheduled_at %d, got %+v", scheduledAt, list)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_CronNoOverlap(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_overlap",
+		Name:    "overlap-test",
+		EnvVars: make(map[string]string),
+	}
+	created, err := sqliteDB.CreateFunction(ctx, fn)
+	if err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+	if created.CronNoOverlap {
+		t.Error("Expected overlap to be allowed by default")
+	}
+
+	noOverlap := true
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{CronNoOverlap: &noOverlap}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if !updated.CronNoOverlap {
+		t.Error("Expected cron_no_overlap to be set")
+	}
+}
+
+func TestSQLiteDB_CronMisfirePolicy(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_misfire",
+		Name:    "misfire-test",
+		EnvVars: make(map[string]string),
+	}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	policy := string(CronMisfireRunOnce)
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{CronMisfirePolicy: &policy}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	if err := sqliteDB.SetCronLastRun(ctx, fn.ID, 1700000000); err != nil {
+		t.Fatalf("SetCronLastRun failed: %v", err)
+	}
+	if err := sqliteDB.SetCronLastSuccess(ctx, fn.ID, 1600000000); err != nil {
+		t.Fatalf("SetCronLastSuccess failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.CronMisfirePolicy == nil || *updated.CronMisfirePolicy != policy {
+		t.Errorf("Expected misfire policy %q, got %v", policy, updated.CronMisfirePolicy)
+	}
+	if updated.CronLastRunAt == nil || *updated.CronLastRunAt != 1700000000 {
+		t.Errorf("Expected last run 1700000000, got %v", updated.CronLastRunAt)
+	}
+	if updated.CronLastSuccessAt == nil || *updated.CronLastSuccessAt != 1600000000 {
+		t.Errorf("Expected last success 1600000000, got %v", updated.CronLastSuccessAt)
+	}
+
+	// An empty policy falls back to skipping missed fires
+	empty := ""
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{CronMisfirePolicy: &empty}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	cleared, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if cleared.CronMisfirePolicy != nil {
+		t.Errorf("Expected misfire policy to be cleared, got %q", *cleared.CronMisfirePolicy)
+	}
+
+	if err := sqliteDB.SetCronLastRun(ctx, "missing", 1700000000); !errors.Is(err, ErrFunctionNotFound) {
+		t.Errorf("Expected ErrFunctionNotFound, got %v", err)
+	}
+	if err := sqliteDB.SetCronLastSuccess(ctx, "missing", 1700000000); !errors.Is(err, ErrFunctionNotFound) {
+		t.Errorf("Expected ErrFunctionNotFound, got %v", err)
+	}
+}
+
+func TestExecutionTimeseries(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	memoryDB := NewMemoryDB()
+
+	type row struct {
+		id         string
+		functionID string
+		status     ExecutionStatus
+		durationMs *int64
+		createdAt  int64
+	}
+	ms := func(v int64) *int64 { return &v }
+	rows := []row{
+		{"e1", "func_ts", ExecutionStatusSuccess, ms(10), 1000},
+		{"e2", "func_ts", ExecutionStatusSuccess, ms(40), 1010},
+		{"e3", "func_ts", ExecutionStatusSuccess, ms(20), 1020},
+		{"e4", "func_ts", ExecutionStatusSuccess, ms(30), 1030},
+		{"e5", "func_ts", ExecutionStatusError, ms(100), 1059},
+		{"e6", "func_ts", ExecutionStatusPending, nil, 1130},
+		{"e7", "func_ts", ExecutionStatusSkipped, nil, 1190},
+		{"e8", "func_ts", ExecutionStatusSuccess, ms(5), 999},      // Before the window
+		{"e9", "func_ts", ExecutionStatusSuccess, ms(5), 1240},     // At the end of the window
+		{"e10", "func_other", ExecutionStatusSuccess, ms(5), 1000}, // Another function
+	}
+
+	versions := make(map[string]string)
+	for _, id := range []string{"func_ts", "func_other"} {
+		fn := Function{ID: id, Name: id, EnvVars: make(map[string]string)}
+		if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+			t.Fatalf("CreateFunction failed: %v", err)
+		}
+		ver, err := sqliteDB.CreateVersion(ctx, id, "code", nil, nil)
+		if err != nil {
+			t.Fatalf("CreateVersion failed: %v", err)
+		}
+		versions[id] = ver.ID
+	}
+	for _, r := range rows {
+		_, err := db.ExecContext(ctx, `INSERT INTO executions (id, function_id, function_version_id, status, duration_ms, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			r.id, r.functionID, versions[r.functionID], r.status, r.durationMs, r.createdAt)
+		if err != nil {
+			t.Fatalf("Failed to insert execution: %v", err)
+		}
+		exec := Execution{ID: r.id, FunctionID: r.functionID, FunctionVersionID: versions[r.functionID], Status: r.status, DurationMs: r.durationMs, CreatedAt: r.createdAt}
+		if _, err := memoryDB.CreateExecution(ctx, exec); err != nil {
+			t.Fatalf("CreateExecution failed: %v", err)
+		}
+	}
+
+	for name, database := range map[string]DB{"sqlite": sqliteDB, "memory": memoryDB} {
+		t.Run(name, func(t *testing.T) {
+			buckets, err := database.ExecutionTimeseries(ctx, "func_ts", 1000, 1240, 60)
+			if err != nil {
+				t.Fatalf("ExecutionTimeseries failed: %v", err)
+			}
+			if len(buckets) != 4 {
+				t.Fatalf("Expected 4 buckets, got %d: %+v", len(buckets), buckets)
+			}
+			for i, b := range buckets {
+				if want := int64(1000 + i*60); b.Start != want {
+					t.Errorf("Bucket %d: expected start %d, got %d", i, want, b.Start)
+				}
+			}
+
+			first := buckets[0]
+			if first.Count != 5 || first.Errors != 1 {
+				t.Errorf("Expected 5 executions with 1 error, got %d with %d", first.Count, first.Errors)
+			}
+			if first.AvgDurationMs == nil || *first.AvgDurationMs != 40 {
+				t.Errorf("Expected average 40ms, got %v", first.AvgDurationMs)
+			}
+			if first.P50DurationMs == nil || *first.P50DurationMs != 30 {
+				t.Errorf("Expected p50 30ms, got %v", first.P50DurationMs)
+			}
+			if first.P95DurationMs == nil || *first.P95DurationMs != 100 {
+				t.Errorf("Expected p95 100ms, got %v", first.P95DurationMs)
+			}
+			if first.P99DurationMs == nil || *first.P99DurationMs != 100 {
+				t.Errorf("Expected p99 100ms, got %v", first.P99DurationMs)
+			}
+
+			// Gaps, unfinished and skipped executions
+			if b := buckets[1]; b.Count != 0 || b.AvgDurationMs != nil || b.P50DurationMs != nil {
+				t.Errorf("Expected an empty bucket, got %+v", b)
+			}
+			if b := buckets[2]; b.Count != 1 || b.AvgDurationMs != nil || b.P95DurationMs != nil {
+				t.Errorf("Expected one unfinished execution without durations, got %+v", b)
+			}
+			if b := buckets[3]; b.Count != 0 {
+				t.Errorf("Expected skipped executions not to be counted, got %+v", b)
+			}
+		})
+	}
+}
+
+func TestListRecentErrors(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	memoryDB := NewMemoryDB()
+
+	type row struct {
+		id         string
+		functionID string
+		status     ExecutionStatus
+		message    string
+		createdAt  int64
+	}
+	rows := []row{
+		{"e1", "func_a", ExecutionStatusError, "boom", 1000},
+		{"e2", "func_b", ExecutionStatusError, "timeout", 1020},
+		{"e3", "func_a", ExecutionStatusSuccess, "", 1030},
+		{"e4", "func_b", ExecutionStatusError, "old", 990}, // Before the window
+		{"e5", "func_a", ExecutionStatusError, "latest", 1040},
+	}
+
+	versions := make(map[string]string)
+	for _, id := range []string{"func_a", "func_b"} {
+		fn := Function{ID: id, Name: "Function " + id, EnvVars: make(map[string]string)}
+		for _, database := range []DB{sqliteDB, memoryDB} {
+			if _, err := database.CreateFunction(ctx, fn); err != nil {
+				t.Fatalf("CreateFunction failed: %v", err)
+			}
+		}
+		ver, err := sqliteDB.CreateVersion(ctx, id, "code", nil, nil)
+		if err != nil {
+			t.Fatalf("CreateVersion failed: %v", err)
+		}
+		versions[id] = ver.ID
+	}
+	for _, r := range rows {
+		_, err := db.ExecContext(ctx, `INSERT INTO executions (id, function_id, function_version_id, status, error_message, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			r.id, r.functionID, versions[r.functionID], r.status, r.message, r.createdAt)
+		if err != nil {
+			t.Fatalf("Failed to insert execution: %v", err)
+		}
+		message := r.message
+		exec := Execution{ID: r.id, FunctionID: r.functionID, FunctionVersionID: versions[r.functionID], Status: r.status, ErrorMessage: &message, CreatedAt: r.createdAt}
+		if _, err := memoryDB.CreateExecution(ctx, exec); err != nil {
+			t.Fatalf("CreateExecution failed: %v", err)
+		}
+	}
+
+	for name, database := range map[string]DB{"sqlite": sqliteDB, "memory": memoryDB} {
+		t.Run(name, func(t *testing.T) {
+			failures, total, err := database.ListRecentErrors(ctx, 1000, PaginationParams{Limit: 2})
+			if err != nil {
+				t.Fatalf("ListRecentErrors failed: %v", err)
+			}
+			if total != 3 {
+				t.Errorf("Expected 3 errors in the window, got %d", total)
+			}
+			if len(failures) != 2 {
+				t.Fatalf("Expected 2 errors on the page, got %d", len(failures))
+			}
+
+			latest := failures[0]
+			if latest.ID != "e5" || latest.FunctionName != "Function func_a" {
+				t.Errorf("Expected the latest error first with its function name, got %+v", latest)
+			}
+			if latest.ErrorMessage == nil || *latest.ErrorMessage != "latest" {
+				t.Errorf("Expected error message 'latest', got %v", latest.ErrorMessage)
+			}
+			if failures[1].ID != "e2" || failures[1].FunctionName != "Function func_b" {
+				t.Errorf("Expected the error of func_b second, got %+v", failures[1])
+			}
+
+			rest, _, err := database.ListRecentErrors(ctx, 1000, PaginationParams{Limit: 2, Offset: 2})
+			if err != nil {
+				t.Fatalf("ListRecentErrors failed: %v", err)
+			}
+			if len(rest) != 1 || rest[0].ID != "e1" {
+				t.Errorf("Expected the oldest error on the second page, got %+v", rest)
+			}
+		})
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_ReuseState(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_reuse",
+		Name:    "reuse-test",
+		EnvVars: make(map[string]string),
+	}
+	created, err := sqliteDB.CreateFunction(ctx, fn)
+	if err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+	if created.ReuseState {
+		t.Error("Expected a fresh state per execution by default")
+	}
+
+	reuse := true
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{ReuseState: &reuse}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if !updated.ReuseState {
+		t.Error("Expected reuse_state to be set")
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_MaxLogEntries(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_max_logs",
+		Name:    "max-logs-test",
+		EnvVars: make(map[string]string),
+	}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	maxLogEntries := 200
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{MaxLogEntries: &maxLogEntries}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.MaxLogEntries == nil || *updated.MaxLogEntries != 200 {
+		t.Errorf("Expected max_log_entries 200, got %v", updated.MaxLogEntries)
+	}
+
+	// Zero clears the limit
+	zero := 0
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{MaxLogEntries: &zero}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	updated, err = sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.MaxLogEntries != nil {
+		t.Errorf("Expected max_log_entries to be cleared, got %d", *updated.MaxLogEntries)
+	}
+}
+
+func TestSQLiteDB_UpdateFunction_MaxOutboundCalls(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	fn := Function{
+		ID:      "func_max_outbound",
+		Name:    "max-outbound-test",
+		EnvVars: make(map[string]string),
+	}
+	if _, err := sqliteDB.CreateFunction(ctx, fn); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	maxOutboundCalls := 25
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{MaxOutboundCalls: &maxOutboundCalls}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	updated, err := sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.MaxOutboundCalls == nil || *updated.MaxOutboundCalls != 25 {
+		t.Errorf("Expected max_outbound_calls 25, got %v", updated.MaxOutboundCalls)
+	}
+
+	// Zero clears the limit
+	zero := 0
+	if err := sqliteDB.UpdateFunction(ctx, fn.ID, UpdateFunctionRequest{MaxOutboundCalls: &zero}); err != nil {
+		t.Fatalf("UpdateFunction failed: %v", err)
+	}
+	updated, err = sqliteDB.GetFunction(ctx, fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunction failed: %v", err)
+	}
+	if updated.MaxOutboundCalls != nil {
+		t.Errorf("Expected max_outbound_calls to be cleared, got %d", *updated.MaxOutboundCalls)
+	}
+}
+
+func TestExecutionSizes(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	size := func(v int64) *int64 { return &v }
+
+	for name, database := range map[string]DB{"sqlite": sqliteDB, "memory": NewMemoryDB()} {
+		t.Run(name, func(t *testing.T) {
+			fn := Function{ID: "func_sizes", Name: "sizes", EnvVars: make(map[string]string)}
+			if _, err := database.CreateFunction(ctx, fn); err != nil {
+				t.Fatalf("CreateFunction failed: %v", err)
+			}
+			ver, err := database.CreateVersion(ctx, fn.ID, "code", nil, nil)
+			if err != nil {
+				t.Fatalf("CreateVersion failed: %v", err)
+			}
+
+			executions := []struct {
+				id            string
+				requestBytes  int64
+				responseBytes *int64
+			}{
+				{"exec_small", 10, size(200)},
+				{"exec_large", 500, size(20)},
+				{"exec_failed", 30, nil},
+			}
+			for _, e := range executions {
+				exec := Execution{
+					ID:                e.id,
+					FunctionID:        fn.ID,
+					FunctionVersionID: ver.ID,
+					Status:            ExecutionStatusPending,
+					RequestBytes:      size(e.requestBytes),
+				}
+				if _, err := database.CreateExecution(ctx, exec); err != nil {
+					t.Fatalf("CreateExecution failed: %v", err)
+				}
+				if err := database.UpdateExecution(ctx, e.id, ExecutionUpdate{Status: ExecutionStatusSuccess, DurationMs: size(5), ResponseBytes: e.responseBytes}); err != nil {
+					t.Fatalf("UpdateExecution failed: %v", err)
+				}
+			}
+
+			got, err := database.GetExecution(ctx, "exec_small")
+			if err != nil {
+				t.Fatalf("GetExecution failed: %v", err)
+			}
+			if got.RequestBytes == nil || *got.RequestBytes != 10 || got.ResponseBytes == nil || *got.ResponseBytes != 200 {
+				t.Errorf("Expected 10 request and 200 response bytes, got %v and %v", got.RequestBytes, got.ResponseBytes)
+			}
+
+			failed, err := database.GetExecution(ctx, "exec_failed")
+			if err != nil {
+				t.Fatalf("GetExecution failed: %v", err)
+			}
+			if failed.ResponseBytes != nil {
+				t.Errorf("Expected no response bytes without a response, got %d", *failed.ResponseBytes)
+			}
+
+			now := time.Now().Unix()
+			buckets, err := database.ExecutionTimeseries(ctx, fn.ID, now-60, now+60, 120)
+			if err != nil {
+				t.Fatalf("ExecutionTimeseries failed: %v", err)
+			}
+			b := buckets[0]
+			if b.RequestBytes != 540 || b.ResponseBytes != 220 || b.MaxRequestBytes != 500 || b.MaxResponseBytes != 200 {
+				t.Errorf("Unexpected size aggregates: %+v", b)
+			}
+		})
+	}
+}
+
+func TestExecutionCost(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	cost := func(v float64) *float64 { return &v }
+
+	for name, database := range map[string]DB{"sqlite": sqliteDB, "memory": NewMemoryDB()} {
+		t.Run(name, func(t *testing.T) {
+			fn := Function{ID: "func_cost", Name: "cost", EnvVars: make(map[string]string)}
+			if _, err := database.CreateFunction(ctx, fn); err != nil {
+				t.Fatalf("CreateFunction failed: %v", err)
+			}
+			ver, err := database.CreateVersion(ctx, fn.ID, "code", nil, nil)
+			if err != nil {
+				t.Fatalf("CreateVersion failed: %v", err)
+			}
+
+			costs := map[string]*float64{"exec_cheap": cost(0.25), "exec_ai": cost(1.5), "exec_untracked": nil}
+			for id, c := range costs {
+				exec := Execution{ID: id, FunctionID: fn.ID, FunctionVersionID: ver.ID, Status: ExecutionStatusPending}
+				if _, err := database.CreateExecution(ctx, exec); err != nil {
+					t.Fatalf("CreateExecution failed: %v", err)
+				}
+				duration := int64(5)
+				if err := database.UpdateExecution(ctx, id, ExecutionUpdate{Status: ExecutionStatusSuccess, DurationMs: &duration, CostUSD: c}); err != nil {
+					t.Fatalf("UpdateExecution failed: %v", err)
+				}
+			}
+
+			got, err := database.GetExecution(ctx, "exec_ai")
+			if err != nil {
+				t.Fatalf("GetExecution failed: %v", err)
+			}
+			if got.CostUSD == nil || *got.CostUSD != 1.5 {
+				t.Errorf("Expected a cost of 1.5, got %v", got.CostUSD)
+			}
+			untracked, err := database.GetExecution(ctx, "exec_untracked")
+			if err != nil {
+				t.Fatalf("GetExecution failed: %v", err)
+			}
+			if untracked.CostUSD != nil {
+				t.Errorf("Expected no cost, got %v", *untracked.CostUSD)
+			}
+
+			listed, _, err := database.ListExecutions(ctx, fn.ID, PaginationParams{Limit: 10})
+			if err != nil {
+				t.Fatalf("ListExecutions failed: %v", err)
+			}
+			for _, exec := range listed {
+				if (exec.CostUSD == nil) != (costs[exec.ID] == nil) {
+					t.Errorf("Unexpected listed cost of %s: %v", exec.ID, exec.CostUSD)
+				}
+			}
+
+			now := time.Now().Unix()
+			buckets, err := database.ExecutionTimeseries(ctx, fn.ID, now-60, now+60, 120)
+			if err != nil {
+				t.Fatalf("ExecutionTimeseries failed: %v", err)
+			}
+			if buckets[0].CostUSD != 1.75 {
+				t.Errorf("Expected a total cost of 1.75, got %v", buckets[0].CostUSD)
+			}
+		})
+	}
+}
+
+func TestEachExecution(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	stop := errors.New("stop")
+
+	for name, database := range map[string]DB{"sqlite": sqliteDB, "memory": NewMemoryDB()} {
+		t.Run(name, func(t *testing.T) {
+			fn := Function{ID: "func_each", Name: "each", EnvVars: make(map[string]string)}
+			if _, err := database.CreateFunction(ctx, fn); err != nil {
+				t.Fatalf("CreateFunction failed: %v", err)
+			}
+			ver, err := database.CreateVersion(ctx, fn.ID, "code", nil, nil)
+			if err != nil {
+				t.Fatalf("CreateVersion failed: %v", err)
+			}
+			for _, id := range []string{"exec_a", "exec_b", "exec_c"} {
+				exec := Execution{ID: id, FunctionID: fn.ID, FunctionVersionID: ver.ID, Status: ExecutionStatusSuccess}
+				if _, err := database.CreateExecution(ctx, exec); err != nil {
+					t.Fatalf("CreateExecution failed: %v", err)
+				}
+			}
+			if err := database.SetExecutionTags(ctx, "exec_b", map[string]string{"kind": "b"}); err != nil {
+				t.Fatalf("SetExecutionTags failed: %v", err)
+			}
+
+			var seen []string
+			err = database.EachExecution(ctx, fn.ID, ExecutionFilter{}, func(exec Execution) error {
+				seen = append(seen, exec.ID)
+				return nil
+			})
+			if err != nil || len(seen) != 3 {
+				t.Errorf("expected all 3 executions, got %v (err %v)", seen, err)
+			}
+
+			seen = nil
+			err = database.EachExecution(ctx, fn.ID, ExecutionFilter{Tags: map[string]string{"kind": "b"}}, func(exec Execution) error {
+				seen = append(seen, exec.ID)
+				return nil
+			})
+			if err != nil || !slices.Equal(seen, []string{"exec_b"}) {
+				t.Errorf("expected only the tagged execution, got %v (err %v)", seen, err)
+			}
+
+			calls := 0
+			err = database.EachExecution(ctx, fn.ID, ExecutionFilter{}, func(Execution) error {
+				calls++
+				return stop
+			})
+			if !errors.Is(err, stop) || calls != 1 {
+				t.Errorf("expected the callback error after one call, got %v after %d calls", err, calls)
+			}
+		})
+	}
+}
+
+func TestSQLiteDB_EachExecution_Pages(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	for _, id := range []string{"func_pages", "func_other"} {
+		if _, err := sqliteDB.CreateFunction(ctx, Function{ID: id, Name: id, EnvVars: make(map[string]string)}); err != nil {
+			t.Fatalf("CreateFunction failed: %v", err)
+		}
+	}
+	ver, err := sqliteDB.CreateVersion(ctx, "func_pages", "code", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+	other, err := sqliteDB.CreateVersion(ctx, "func_other", "code", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+
+	count := 2*executionStreamPageSize + 1
+	for i := range count {
+		exec := Execution{ID: fmt.Sprintf("exec_%04d", i), FunctionID: "func_pages", FunctionVersionID: ver.ID, Status: ExecutionStatusSuccess}
+		if _, err := sqliteDB.CreateExecution(ctx, exec); err != nil {
+			t.Fatalf("CreateExecution failed: %v", err)
+		}
+	}
+
+	var seen []Execution
+	err = sqliteDB.EachExecution(ctx, "func_pages", ExecutionFilter{}, func(exec Execution) error {
+		seen = append(seen, exec)
+		// Writes go through while the executions are streamed
+		if len(seen)%executionStreamPageSize == 0 {
+			_, err := sqliteDB.CreateExecution(ctx, Execution{ID: "exec_other_" + exec.ID, FunctionID: "func_other", FunctionVersionID: other.ID, Status: ExecutionStatusSuccess})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachExecution failed: %v", err)
+	}
+
+	if len(seen) != count {
+		t.Fatalf("Expected %d executions, got %d", count, len(seen))
+	}
+	for i := 1; i < len(seen); i++ {
+		prev, cur := seen[i-1], seen[i]
+		if cur.CreatedAt > prev.CreatedAt || (cur.CreatedAt == prev.CreatedAt && cur.ID >= prev.ID) {
+			t.Fatalf("Expected newest first without repeats, got %s after %s", cur.ID, prev.ID)
+		}
+	}
+}
+
+// envMap is an EnvReader over a fixed map of function ID to env vars
+type envMap map[string]map[string]string
+
+func (m envMap) All(functionID string) (map[string]string, error) {
+	if vars, ok := m[functionID]; ok {
+		return vars, nil
+	}
+	return map[string]string{}, nil
+}
+
+func TestGetFunctionWithEnv(t *testing.T) {
+	db, sqliteDB := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	envs := envMap{"func_env": {"API_KEY": "secret"}}
+
+	if _, err := GetFunctionWithEnv(ctx, sqliteDB, envs, "missing"); !errors.Is(err, ErrFunctionNotFound) {
+		t.Errorf("expected ErrFunctionNotFound, got %v", err)
+	}
+
+	if _, err := sqliteDB.CreateFunction(ctx, Function{ID: "func_env", Name: "env"}); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+	if _, err := GetFunctionWithEnv(ctx, sqliteDB, envs, "func_env"); !errors.Is(err, ErrNoActiveVersion) {
+		t.Errorf("expected ErrNoActiveVersion, got %v", err)
+	}
+
+	version, err := sqliteDB.CreateVersion(ctx, "func_env", "function handler() end", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+
+	fn, err := GetFunctionWithEnv(ctx, sqliteDB, envs, "func_env")
+	if err != nil {
+		t.Fatalf("GetFunctionWithEnv failed: %v", err)
+	}
+	if fn.ActiveVersion.ID != version.ID {
+		t.Errorf("expected active version %s, got %s", version.ID, fn.ActiveVersion.ID)
+	}
+	if fn.EnvVars["API_KEY"] != "secret" {
+		t.Errorf("expected env vars from the env reader, got %v", fn.EnvVars)
+	}
+}
@@ -6,6 +6,7 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
 )
 
 var (
@@ -14,6 +15,7 @@ var (
 	ErrNoActiveVersion           = errors.New("no active version")
 	ErrExecutionNotFound         = errors.New("execution not found")
 	ErrCannotDeleteActiveVersion = errors.New("cannot delete active version")
+	ErrRoutePrefixTaken          = errors.New("route prefix already in use")
 )
 
 // DB defines the database interface for the Lunar API.
@@ -31,15 +33,25 @@ type DB interface {
 
 	// UpdateFunction updates a function's fields.
 	// Returns ErrFunctionNotFound if the function does not exist.
+	// Returns ErrRoutePrefixTaken if the route prefix belongs to another function.
 	UpdateFunction(ctx context.Context, id string, updates UpdateFunctionRequest) error
 
 	// DeleteFunction removes a function and its associated data.
 	// Returns ErrFunctionNotFound if the function does not exist.
 	DeleteFunction(ctx context.Context, id string) error
 
+	// ApplyBatch applies function creations and updates as a single unit:
+	// either every operation is applied or, when one fails, none are and a
+	// *BatchError identifying the failed operation is returned. Returns each
+	// function with its active version after the batch, in operation order.
+	ApplyBatch(ctx context.Context, ops []BatchOperation) ([]FunctionWithActiveVersion, error)
+
 	// CreateVersion creates a new version for a function and sets it as active.
+	// When the function has MaxVersions set, the oldest inactive versions
+	// beyond that limit are pruned.
+	// The label is optional and may be nil.
 	// Returns ErrFunctionNotFound if the function does not exist.
-	CreateVersion(ctx context.Context, functionID string, code string, createdBy *string) (FunctionVersion, error)
+	CreateVersion(ctx context.Context, functionID string, code string, createdBy *string, label *string) (FunctionVersion, error)
 
 	// GetVersion retrieves a specific version by function ID and version number.
 	// Returns ErrVersionNotFound if the version does not exist.
@@ -60,11 +72,21 @@ type DB interface {
 	// Returns ErrVersionNotFound if the version does not exist.
 	ActivateVersion(ctx context.Context, versionID string) error
 
+	// UpdateVersionLabel sets the label of a version by its ID. A nil or empty
+	// label removes it. Returns the updated version.
+	// Returns ErrVersionNotFound if the version does not exist.
+	UpdateVersionLabel(ctx context.Context, versionID string, label *string) (FunctionVersion, error)
+
 	// DeleteVersion removes a specific version by its ID.
 	// Returns ErrVersionNotFound if the version does not exist.
 	// Returns ErrCannotDeleteActiveVersion if attempting to delete the active version.
 	DeleteVersion(ctx context.Context, versionID string) error
 
+	// DeleteOldVersions removes the oldest versions of a function so that only
+	// the newest keep versions remain. The active version is always retained.
+	// Returns the number of deleted versions.
+	DeleteOldVersions(ctx context.Context, functionID string, keep int) (int64, error)
+
 	// CreateExecution records a new execution. Returns the execution with
 	// timestamps populated.
 	CreateExecution(ctx context.Context, exec Execution) (Execution, error)
@@ -73,20 +95,95 @@ type DB interface {
 	// Returns ErrExecutionNotFound if the execution does not exist.
 	GetExecution(ctx context.Context, executionID string) (Execution, error)
 
-	// UpdateExecution updates an execution's status and results.
+	// UpdateExecution records an execution's status and results.
+	// Returns ErrExecutionNotFound if the execution does not exist.
+	UpdateExecution(ctx context.Context, executionID string, update ExecutionUpdate) error
+
+	// SetExecutionTags stores the tags a function set on its execution.
 	// Returns ErrExecutionNotFound if the execution does not exist.
-	UpdateExecution(ctx context.Context, executionID string, status ExecutionStatus, durationMs *int64, errorMsg *string, responseJSON *string) error
+	SetExecutionTags(ctx context.Context, executionID string, tags map[string]string) error
+
+	// SetExecutionSpans stores the timing spans a function recorded during
+	// its execution. They are returned by GetExecution but not by listings.
+	// Returns ErrExecutionNotFound if the execution does not exist.
+	SetExecutionSpans(ctx context.Context, executionID string, spans []Span) error
 
 	// ListExecutions returns paginated executions for a function.
 	ListExecutions(ctx context.Context, functionID string, params PaginationParams) ([]Execution, int64, error)
 
+	// ListExecutionsFiltered returns paginated executions for a function matching the filter.
+	ListExecutionsFiltered(ctx context.Context, functionID string, filter ExecutionFilter, params PaginationParams) ([]Execution, int64, error)
+
+	// EachExecution calls fn with every execution of a function matching the
+	// filter, newest first, as they are read rather than collected first.
+	// It stops at the first error returned by fn and returns it.
+	EachExecution(ctx context.Context, functionID string, filter ExecutionFilter, fn func(Execution) error) error
+
 	// DeleteOldExecutions removes executions older than the given timestamp.
 	// Returns the number of deleted records.
 	DeleteOldExecutions(ctx context.Context, beforeTimestamp int64) (int64, error)
 
+	// ListRecentErrors returns paginated failed executions of all functions
+	// created since the given Unix timestamp, newest first.
+	ListRecentErrors(ctx context.Context, since int64, params PaginationParams) ([]FunctionExecution, int64, error)
+
+	// ExecutionTimeseries aggregates a function's executions created between since
+	// and until into buckets of bucketSize seconds, including empty ones.
+	// Skipped executions are not counted.
+	ExecutionTimeseries(ctx context.Context, functionID string, since, until, bucketSize int64) ([]ExecutionBucket, error)
+
 	// ListFunctionsWithActiveCron returns all functions that have an active cron schedule.
 	ListFunctionsWithActiveCron(ctx context.Context) ([]Function, error)
 
+	// SetCronLastRun records the scheduled time of a function's last attempted cron execution.
+	// Returns ErrFunctionNotFound if the function does not exist.
+	SetCronLastRun(ctx context.Context, functionID string, scheduledAt int64) error
+
+	// SetCronLastSuccess records the scheduled time of a function's last successful cron execution.
+	// Returns ErrFunctionNotFound if the function does not exist.
+	SetCronLastSuccess(ctx context.Context, functionID string, scheduledAt int64) error
+
+	// ListFunctionsWithRoutePrefix returns all functions that have a route prefix.
+	ListFunctionsWithRoutePrefix(ctx context.Context) ([]Function, error)
+
 	// Ping verifies the database connection is alive.
 	Ping(ctx context.Context) error
 }
+
+// Backuper is implemented by databases that can copy themselves to a file
+// while in use. SQLiteDB implements it; the in-memory store does not.
+type Backuper interface {
+	// Backup writes a consistent copy of the database to path, which must
+	// not exist yet.
+	Backup(ctx context.Context, path string) error
+}
+
+// EnvReader reads the environment variables of a function. env.Store
+// implements it; environment variables are kept apart from DB.
+type EnvReader interface {
+	All(functionID string) (map[string]string, error)
+}
+
+// GetFunctionWithEnv returns a function with its active version and its
+// environment variables read from envs, the one place that merges them.
+// Returns ErrFunctionNotFound if the function does not exist and
+// ErrNoActiveVersion if no version is active.
+func GetFunctionWithEnv(ctx context.Context, db DB, envs EnvReader, id string) (FunctionWithActiveVersion, error) {
+	fn, err := db.GetFunction(ctx, id)
+	if err != nil {
+		return FunctionWithActiveVersion{}, err
+	}
+
+	activeVersion, err := db.GetActiveVersion(ctx, id)
+	if err != nil {
+		return FunctionWithActiveVersion{}, err
+	}
+
+	envVars, err := envs.All(id)
+	if err != nil {
+		return FunctionWithActiveVersion{}, fmt.Errorf("failed to get env vars: %w", err)
+	}
+	fn.EnvVars = envVars
+
+	return FunctionWithActiveVersion{Function: fn, ActiveVersion: activeVersion}, nil
+}
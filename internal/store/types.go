@@ -1,5 +1,14 @@
 package store
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
 // LogLevel represents the severity level of a log entry
 type LogLevel string
 
@@ -17,16 +26,33 @@ const (
 	ExecutionStatusPending ExecutionStatus = "pending"
 	ExecutionStatusSuccess ExecutionStatus = "success"
 	ExecutionStatusError   ExecutionStatus = "error"
+	ExecutionStatusSkipped ExecutionStatus = "skipped" // A cron run not started because a previous execution was still running
 )
 
 // ExecutionTrigger represents how an execution was triggered
 type ExecutionTrigger string
 
 const (
-	ExecutionTriggerHTTP ExecutionTrigger = "http"
-	ExecutionTriggerCron ExecutionTrigger = "cron"
+	ExecutionTriggerHTTP   ExecutionTrigger = "http"
+	ExecutionTriggerCron   ExecutionTrigger = "cron"
+	ExecutionTriggerManual ExecutionTrigger = "manual" // Run from the dashboard or the API by an authenticated caller
+	ExecutionTriggerTest   ExecutionTrigger = "test"   // Sandboxed run of POST /api/functions/{id}/test
 )
 
+// Valid reports whether t is a known trigger
+func (t ExecutionTrigger) Valid() bool {
+	switch t {
+	case ExecutionTriggerHTTP, ExecutionTriggerCron, ExecutionTriggerManual, ExecutionTriggerTest:
+		return true
+	}
+	return false
+}
+
+// Privileged reports whether only authenticated callers may claim the trigger
+func (t ExecutionTrigger) Privileged() bool {
+	return t == ExecutionTriggerCron || t == ExecutionTriggerManual || t == ExecutionTriggerTest
+}
+
 // CronStatus represents the status of a cron schedule
 type CronStatus string
 
@@ -35,6 +61,15 @@ const (
 	CronStatusPaused CronStatus = "paused"
 )
 
+// CronMisfirePolicy decides what happens on startup with cron fires missed
+// while the server was down
+type CronMisfirePolicy string
+
+const (
+	CronMisfireSkip    CronMisfirePolicy = "skip"     // Missed fires are dropped (default)
+	CronMisfireRunOnce CronMisfirePolicy = "run_once" // Run once on startup when at least one fire was missed
+)
+
 // AIRequestStatus represents the status of an AI API request
 type AIRequestStatus string
 
@@ -58,6 +93,17 @@ type AIRequest struct {
 	OutputTokens *int            `json:"output_tokens,omitempty"`
 	DurationMs   int64           `json:"duration_ms"`
 	CreatedAt    int64           `json:"created_at"`
+	CreatedAtNs  int64           `json:"created_at_ns,omitempty"` // CreatedAt in Unix nanoseconds, unset for older requests
+}
+
+// AIUsage aggregates the tracked AI requests of a provider and model
+type AIUsage struct {
+	Provider         string   `json:"provider"`
+	Model            string   `json:"model"`
+	Requests         int64    `json:"requests"`
+	InputTokens      int64    `json:"input_tokens"`
+	OutputTokens     int64    `json:"output_tokens"`
+	EstimatedCostUSD *float64 `json:"estimated_cost_usd,omitempty"`
 }
 
 // EmailRequestStatus represents the status of an email request
@@ -84,21 +130,158 @@ type EmailRequest struct {
 	EmailID      *string            `json:"email_id,omitempty"`
 	DurationMs   int64              `json:"duration_ms"`
 	CreatedAt    int64              `json:"created_at"`
+	CreatedAtNs  int64              `json:"created_at_ns,omitempty"` // CreatedAt in Unix nanoseconds, unset for older requests
+}
+
+// HTTPRequestStatus represents the status of an outbound HTTP request
+type HTTPRequestStatus string
+
+const (
+	HTTPRequestStatusSuccess HTTPRequestStatus = "success"
+	HTTPRequestStatusError   HTTPRequestStatus = "error"
+)
+
+// HTTPRequest represents a captured outbound HTTP request made by a function
+type HTTPRequest struct {
+	ID              string            `json:"id"`
+	ExecutionID     string            `json:"execution_id"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	RequestBody     *string           `json:"request_body,omitempty"`
+	StatusCode      *int              `json:"status_code,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    *string           `json:"response_body,omitempty"`
+	Status          HTTPRequestStatus `json:"status"`
+	ErrorMessage    *string           `json:"error_message,omitempty"`
+	DurationMs      int64             `json:"duration_ms"`
+	CreatedAt       int64             `json:"created_at"`
+	CreatedAtNs     int64             `json:"created_at_ns,omitempty"` // CreatedAt in Unix nanoseconds, unset for older requests
+}
+
+// ExternalCallKind identifies the service an outbound call was made to
+type ExternalCallKind string
+
+const (
+	ExternalCallKindAI    ExternalCallKind = "ai"
+	ExternalCallKindEmail ExternalCallKind = "email"
+	ExternalCallKindHTTP  ExternalCallKind = "http"
+)
+
+// ExternalCall is the common view of an outbound call made during an
+// execution, whatever tracker recorded it
+type ExternalCall struct {
+	ID           string           `json:"id"`
+	ExecutionID  string           `json:"execution_id"`
+	Kind         ExternalCallKind `json:"kind"`
+	Target       string           `json:"target"`
+	Status       string           `json:"status"`
+	ErrorMessage *string          `json:"error_message,omitempty"`
+	DurationMs   int64            `json:"duration_ms"`
+	CreatedAt    int64            `json:"created_at"`
+	CreatedAtNs  int64            `json:"created_at_ns,omitempty"` // CreatedAt in Unix nanoseconds, unset for older calls
+}
+
+// Time returns when the call was recorded, to the nanosecond when known
+func (c ExternalCall) Time() time.Time {
+	if c.CreatedAtNs != 0 {
+		return time.Unix(0, c.CreatedAtNs)
+	}
+	return time.Unix(c.CreatedAt, 0)
+}
+
+// OutboundRequest is implemented by every tracked outbound request
+type OutboundRequest interface {
+	ExternalCall() ExternalCall
+}
+
+// ExternalCall returns the request as an external call targeting the provider and model
+func (r AIRequest) ExternalCall() ExternalCall {
+	return ExternalCall{
+		ID:           r.ID,
+		ExecutionID:  r.ExecutionID,
+		Kind:         ExternalCallKindAI,
+		Target:       r.Provider + "/" + r.Model,
+		Status:       string(r.Status),
+		ErrorMessage: r.ErrorMessage,
+		DurationMs:   r.DurationMs,
+		CreatedAt:    r.CreatedAt,
+		CreatedAtNs:  r.CreatedAtNs,
+	}
+}
+
+// ExternalCall returns the request as an external call targeting the recipients
+func (r EmailRequest) ExternalCall() ExternalCall {
+	return ExternalCall{
+		ID:           r.ID,
+		ExecutionID:  r.ExecutionID,
+		Kind:         ExternalCallKindEmail,
+		Target:       strings.Join(r.To, ", "),
+		Status:       string(r.Status),
+		ErrorMessage: r.ErrorMessage,
+		DurationMs:   r.DurationMs,
+		CreatedAt:    r.CreatedAt,
+		CreatedAtNs:  r.CreatedAtNs,
+	}
+}
+
+// ExternalCall returns the request as an external call targeting the method and URL
+func (r HTTPRequest) ExternalCall() ExternalCall {
+	return ExternalCall{
+		ID:           r.ID,
+		ExecutionID:  r.ExecutionID,
+		Kind:         ExternalCallKindHTTP,
+		Target:       r.Method + " " + r.URL,
+		Status:       string(r.Status),
+		ErrorMessage: r.ErrorMessage,
+		DurationMs:   r.DurationMs,
+		CreatedAt:    r.CreatedAt,
+		CreatedAtNs:  r.CreatedAtNs,
+	}
 }
 
 // Function represents a serverless function
 type Function struct {
-	ID            string            `json:"id"`
-	Name          string            `json:"name"`
-	Description   *string           `json:"description,omitempty"`
-	EnvVars       map[string]string `json:"env_vars"`
-	Disabled      bool              `json:"disabled"`
-	RetentionDays *int              `json:"retention_days,omitempty"`
-	CronSchedule  *string           `json:"cron_schedule,omitempty"`
-	CronStatus    *string           `json:"cron_status,omitempty"`
-	SaveResponse  bool              `json:"save_response"`
-	CreatedAt     int64             `json:"created_at"`
-	UpdatedAt     int64             `json:"updated_at"`
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Description       *string           `json:"description,omitempty"`
+	EnvVars           map[string]string `json:"env_vars"`
+	Disabled          bool              `json:"disabled"`
+	RetentionDays     *int              `json:"retention_days,omitempty"`
+	CronSchedule      *string           `json:"cron_schedule,omitempty"`
+	CronStatus        *string           `json:"cron_status,omitempty"`
+	CronJitter        *int              `json:"cron_jitter,omitempty"` // Maximum random delay in seconds before a cron execution starts
+	CronNoOverlap     bool              `json:"cron_no_overlap"`       // Skip cron executions while a previous execution is still running
+	CronMisfirePolicy *string           `json:"cron_misfire_policy,omitempty"`
+	CronLastRunAt     *int64            `json:"cron_last_run_at,omitempty"`     // Scheduled time of the last attempted cron execution
+	CronLastSuccessAt *int64            `json:"cron_last_success_at,omitempty"` // Scheduled time of the last successful cron execution
+	SaveResponse      bool              `json:"save_response"`
+	AllowedMethods    []string          `json:"allowed_methods,omitempty"`
+	MaxVersions       *int              `json:"max_versions,omitempty"`
+	DefaultHeaders    map[string]string `json:"default_headers,omitempty"`
+	RoutePrefix       *string           `json:"route_prefix,omitempty"`
+	WebSocketEnabled  bool              `json:"websocket_enabled"`
+	CaptureHTTP       bool              `json:"capture_http"`
+	ReuseState        bool              `json:"reuse_state"`                    // Keep the Lua state warm between executions
+	MaxLogEntries     *int              `json:"max_log_entries,omitempty"`      // Log entries an execution may write, overriding the server limit
+	MaxOutboundCalls  *int              `json:"max_outbound_calls,omitempty"`   // Outbound http, ai and email calls an execution may make, lowering the server limit
+	OutboundBudget    *int              `json:"outbound_time_budget,omitempty"` // Seconds an execution may spend in outbound calls in total, lowering the server budget
+	CacheTTL          *int              `json:"cache_ttl,omitempty"`            // Seconds successful responses are cached for, unset disables the cache
+	CacheVaryHeaders  []string          `json:"cache_vary_headers,omitempty"`
+	AIBudgetTokens    *int64            `json:"ai_budget_tokens,omitempty"`
+	AIBudgetUSD       *float64          `json:"ai_budget_usd,omitempty"`
+	AIBudgetOverride  bool              `json:"ai_budget_override"`
+	AIDefaultProvider *string           `json:"ai_default_provider,omitempty"`
+	AIDefaultModel    *string           `json:"ai_default_model,omitempty"`
+	EmailDefaultFrom  *string           `json:"email_default_from,omitempty"`
+	EmailAllowedFrom  []string          `json:"email_allowed_from,omitempty"`
+	DisabledModules   []string          `json:"disabled_modules,omitempty"`
+	Middleware        []string          `json:"middleware,omitempty"`     // Modules whose before and after hooks run around the handler, in order
+	SigningSecret     *string           `json:"signing_secret,omitempty"` // Requires HMAC signed requests to /fn/{id} when set
+	InputSchema       json.RawMessage   `json:"input_schema,omitempty"`   // JSON Schema that request bodies must match before reaching the handler
+	OutputSchema      json.RawMessage   `json:"output_schema,omitempty"`  // JSON Schema response bodies are checked against in dev mode and tests
+	CreatedAt         int64             `json:"created_at"`
+	UpdatedAt         int64             `json:"updated_at"`
 }
 
 // FunctionVersion represents a specific version of a function
@@ -109,21 +292,145 @@ type FunctionVersion struct {
 	Code       string  `json:"code"`
 	CreatedAt  int64   `json:"created_at"`
 	CreatedBy  *string `json:"created_by,omitempty"`
+	Label      *string `json:"label,omitempty"` // Human readable label, e.g. "v1.2.0" or "prod"
 	IsActive   bool    `json:"is_active"`
 }
 
+// BatchOperation creates or updates a function as part of DB.ApplyBatch
+type BatchOperation struct {
+	Create  *Function             // Function to create; nil to update the function with ID
+	ID      string                // Function to update when Create is nil
+	Changes UpdateFunctionRequest // Changes to apply; Changes.Code creates a new active version
+
+	// Versions are created oldest first before Changes, keeping their code,
+	// author and label. The one marked IsActive is the active version afterwards.
+	Versions []FunctionVersion
+
+	// Reset restores the optional settings of an existing function to their
+	// defaults before Changes, so afterwards they match Changes exactly
+	Reset bool
+}
+
+// BatchError reports the operation that failed and caused a batch to be rolled back
+type BatchError struct {
+	Index int // Position of the failed operation
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// normalizeLabel trims a version label, returning nil for empty labels
+func normalizeLabel(label *string) *string {
+	if label == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*label)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
 // Execution represents a function execution record
 type Execution struct {
-	ID                string           `json:"id"`
-	FunctionID        string           `json:"function_id"`
-	FunctionVersionID string           `json:"function_version_id"`
-	Status            ExecutionStatus  `json:"status"`
-	DurationMs        *int64           `json:"duration_ms,omitempty"`
-	ErrorMessage      *string          `json:"error_message,omitempty"`
-	EventJSON         *string          `json:"event_json,omitempty"`
-	ResponseJSON      *string          `json:"response_json,omitempty"`
-	Trigger           ExecutionTrigger `json:"trigger"`
-	CreatedAt         int64            `json:"created_at"`
+	ID                string            `json:"id"`
+	FunctionID        string            `json:"function_id"`
+	FunctionVersionID string            `json:"function_version_id"`
+	Status            ExecutionStatus   `json:"status"`
+	DurationMs        *int64            `json:"duration_ms,omitempty"`
+	ErrorMessage      *string           `json:"error_message,omitempty"`
+	EventJSON         *string           `json:"event_json,omitempty"`
+	ResponseJSON      *string           `json:"response_json,omitempty"`
+	Trigger           ExecutionTrigger  `json:"trigger"`
+	Tags              map[string]string `json:"tags,omitempty"`           // Labels set by the function with execution.tag
+	ScheduledAt       *int64            `json:"scheduled_at,omitempty"`   // Time a cron execution was scheduled for, before jitter
+	RequestBytes      *int64            `json:"request_bytes,omitempty"`  // Size of the request body
+	ResponseBytes     *int64            `json:"response_bytes,omitempty"` // Size of the response body, unset when there was none
+	CostUSD           *float64          `json:"cost_usd,omitempty"`       // Estimated cost of compute time, AI requests and emails
+	Spans             []Span            `json:"spans,omitempty"`          // Timings recorded with trace.span
+	CreatedAt         int64             `json:"created_at"`
+}
+
+// ExecutionUpdate holds the results DB.UpdateExecution records on an
+// execution. Every field is written, so nil clears a previous value.
+type ExecutionUpdate struct {
+	Status        ExecutionStatus
+	DurationMs    *int64
+	ErrorMessage  *string
+	ResponseJSON  *string
+	ResponseBytes *int64   // Size of the response body, nil when there was none
+	CostUSD       *float64 // Estimated cost, nil when costs are not tracked
+}
+
+// ExecutionFilter narrows the executions returned by ListExecutionsFiltered.
+// An execution matches when it has every tag with the given value.
+type ExecutionFilter struct {
+	Tags map[string]string
+}
+
+// matches reports whether the execution has every tag of the filter
+func (f ExecutionFilter) matches(exec Execution) bool {
+	for key, value := range f.Tags {
+		if tag, ok := exec.Tags[key]; !ok || tag != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FunctionExecution is an execution together with the name of its function,
+// for lists that span functions
+type FunctionExecution struct {
+	Execution
+	FunctionName string `json:"function_name"`
+}
+
+// Span is a named timing a function recorded with trace.span
+type Span struct {
+	Name       string  `json:"name"`
+	StartMs    float64 `json:"start_ms"` // Offset from the start of the run
+	DurationMs float64 `json:"duration_ms"`
+	Depth      int     `json:"depth"`                // Spans still open when it started, for nesting
+	Unfinished bool    `json:"unfinished,omitempty"` // finish was never called, so it lasted until the end of the run
+}
+
+// ExecutionBucket aggregates the executions of a function created within a
+// time bucket. The duration statistics are nil when none of them finished.
+type ExecutionBucket struct {
+	Start         int64    `json:"start"` // Unix timestamp the bucket begins at
+	Count         int64    `json:"count"`
+	Errors        int64    `json:"errors"`
+	AvgDurationMs *float64 `json:"avg_duration_ms"`
+	P50DurationMs *int64   `json:"p50_duration_ms"`
+	P95DurationMs *int64   `json:"p95_duration_ms"`
+	P99DurationMs *int64   `json:"p99_duration_ms"`
+
+	RequestBytes     int64 `json:"request_bytes"`  // Total request body size
+	ResponseBytes    int64 `json:"response_bytes"` // Total response body size
+	MaxRequestBytes  int64 `json:"max_request_bytes"`
+	MaxResponseBytes int64 `json:"max_response_bytes"`
+
+	CostUSD float64 `json:"cost_usd"` // Total estimated cost of the executions
+}
+
+// fillBuckets returns one bucket per size seconds from since until until,
+// taking the aggregates from found (keyed by bucket start) and leaving gaps empty
+func fillBuckets(since, until, size int64, found map[int64]ExecutionBucket) []ExecutionBucket {
+	buckets := make([]ExecutionBucket, 0, (until-since+size-1)/size)
+	for start := since; start < until; start += size {
+		bucket, ok := found[start]
+		if !ok {
+			bucket = ExecutionBucket{Start: start}
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
 }
 
 // FunctionWithActiveVersion includes the function and its active version
@@ -161,12 +468,86 @@ type PaginationInfo struct {
 
 // UpdateFunctionRequest is the request body for updating a function
 type UpdateFunctionRequest struct {
-	Name          *string `json:"name,omitempty"`
-	Description   *string `json:"description,omitempty"`
-	Code          *string `json:"code,omitempty"`
-	Disabled      *bool   `json:"disabled,omitempty"`
-	RetentionDays *int    `json:"retention_days,omitempty"`
-	CronSchedule  *string `json:"cron_schedule,omitempty"`
-	CronStatus    *string `json:"cron_status,omitempty"`
-	SaveResponse  *bool   `json:"save_response,omitempty"`
+	Name              *string            `json:"name,omitempty"`
+	Description       *string            `json:"description,omitempty"`
+	Code              *string            `json:"code,omitempty"`
+	Disabled          *bool              `json:"disabled,omitempty"`
+	RetentionDays     *int               `json:"retention_days,omitempty"`
+	CronSchedule      *string            `json:"cron_schedule,omitempty"`
+	CronStatus        *string            `json:"cron_status,omitempty"`
+	CronJitter        *int               `json:"cron_jitter,omitempty"`
+	CronNoOverlap     *bool              `json:"cron_no_overlap,omitempty"`
+	CronMisfirePolicy *string            `json:"cron_misfire_policy,omitempty"`
+	SaveResponse      *bool              `json:"save_response,omitempty"`
+	AllowedMethods    *[]string          `json:"allowed_methods,omitempty"`
+	MaxVersions       *int               `json:"max_versions,omitempty"`
+	DefaultHeaders    *map[string]string `json:"default_headers,omitempty"`
+	RoutePrefix       *string            `json:"route_prefix,omitempty"`
+	WebSocketEnabled  *bool              `json:"websocket_enabled,omitempty"`
+	CaptureHTTP       *bool              `json:"capture_http,omitempty"`
+	ReuseState        *bool              `json:"reuse_state,omitempty"`
+	MaxLogEntries     *int               `json:"max_log_entries,omitempty"`
+	MaxOutboundCalls  *int               `json:"max_outbound_calls,omitempty"`
+	OutboundBudget    *int               `json:"outbound_time_budget,omitempty"`
+	CacheTTL          *int               `json:"cache_ttl,omitempty"`
+	CacheVaryHeaders  *[]string          `json:"cache_vary_headers,omitempty"`
+	AIBudgetTokens    *int64             `json:"ai_budget_tokens,omitempty"`
+	AIBudgetUSD       *float64           `json:"ai_budget_usd,omitempty"`
+	AIBudgetOverride  *bool              `json:"ai_budget_override,omitempty"`
+	AIDefaultProvider *string            `json:"ai_default_provider,omitempty"`
+	AIDefaultModel    *string            `json:"ai_default_model,omitempty"`
+	EmailDefaultFrom  *string            `json:"email_default_from,omitempty"`
+	EmailAllowedFrom  *[]string          `json:"email_allowed_from,omitempty"`
+	DisabledModules   *[]string          `json:"disabled_modules,omitempty"`
+	Middleware        *[]string          `json:"middleware,omitempty"`
+	SigningSecret     *string            `json:"signing_secret,omitempty"`
+	InputSchema       *json.RawMessage   `json:"input_schema,omitempty"`
+	OutputSchema      *json.RawMessage   `json:"output_schema,omitempty"`
+	VersionLabel      *string            `json:"version_label,omitempty"` // Label for the version created from Code
+}
+
+// HasMetadata reports whether the request updates any function field other than code
+func (r UpdateFunctionRequest) HasMetadata() bool {
+	return r.Name != nil || r.Description != nil || r.Disabled != nil || r.RetentionDays != nil ||
+		r.CronSchedule != nil || r.CronStatus != nil || r.CronJitter != nil || r.CronNoOverlap != nil || r.CronMisfirePolicy != nil || r.SaveResponse != nil || r.AllowedMethods != nil || r.MaxVersions != nil ||
+		r.DefaultHeaders != nil || r.RoutePrefix != nil || r.WebSocketEnabled != nil || r.CaptureHTTP != nil || r.ReuseState != nil || r.MaxLogEntries != nil || r.MaxOutboundCalls != nil || r.OutboundBudget != nil ||
+		r.CacheTTL != nil || r.CacheVaryHeaders != nil ||
+		r.AIBudgetTokens != nil || r.AIBudgetUSD != nil || r.AIBudgetOverride != nil ||
+		r.AIDefaultProvider != nil || r.AIDefaultModel != nil || r.EmailDefaultFrom != nil || r.EmailAllowedFrom != nil ||
+		r.DisabledModules != nil || r.Middleware != nil || r.SigningSecret != nil ||
+		r.InputSchema != nil || r.OutputSchema != nil
+}
+
+// compactSchema returns the compacted input or output schema to store, or
+// nil when the schema is empty. An empty schema ({}) accepts every body, so
+// setting it removes the function's schema.
+func compactSchema(raw json.RawMessage) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	if buf.String() == "{}" {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// AllowsMethod reports whether the function accepts the given HTTP method.
+// An empty AllowedMethods list accepts every method, and HEAD is accepted
+// wherever GET is.
+func (f Function) AllowsMethod(method string) bool {
+	if len(f.AllowedMethods) == 0 {
+		return true
+	}
+	if method == "HEAD" && slices.Contains(f.AllowedMethods, "GET") {
+		return true
+	}
+	return slices.Contains(f.AllowedMethods, method)
+}
+
+// HandlesOptions reports whether the function runs for OPTIONS requests.
+// It must list OPTIONS in AllowedMethods; otherwise OPTIONS requests are
+// answered as CORS preflight requests without running it.
+func (f Function) HandlesOptions() bool {
+	return slices.Contains(f.AllowedMethods, "OPTIONS")
 }